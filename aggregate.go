@@ -0,0 +1,281 @@
+/*
+Copyright © 2024  M.Watermann, 10247 Berlin, Germany
+
+	    All rights reserved
+	EMail : <support@mwat.de>
+*/
+package apachelogger
+
+import (
+	"net"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+//lint:file-ignore ST1017 – I prefer Yoda conditions
+
+type (
+	// `tAggregateKey` identifies a group of aggregated requests.
+	tAggregateKey struct {
+		path    string // the request path (without query)
+		status  int    // the response status
+		country string // the client's country code
+	}
+
+	// `tAggregateCount` holds the counts of a group of requests.
+	tAggregateCount struct {
+		requests int // number of requests
+		bytes    int // number of bytes sent
+	}
+)
+
+const (
+	// Max. number of groups per aggregation period.
+	alAggregateMaxGroups = 10000
+)
+
+var (
+	// The aggregation mode's state.
+	alAggregate struct {
+		sync.Mutex
+		counts  map[tAggregateKey]*tAggregateCount // the current counts
+		country func(net.IP) string                // the country lookup
+		since   time.Time                          // start of the current period
+		stop    chan struct{}                      // stops the periodic output
+	}
+)
+
+// `aggregateEntry()` counts `aEntry` if the aggregation-only mode is
+// active.
+//
+// Parameters:
+// - `aEntry`: The (prepared) access log entry.
+// - `aRequest`: The request the entry belongs to.
+//
+// Returns:
+// - `bool`: `true` if the entry was aggregated (and mustn't be logged).
+func aggregateEntry(aEntry *TEntry, aRequest *http.Request) bool {
+	alAggregate.Lock()
+	defer alAggregate.Unlock()
+	if nil == alAggregate.counts {
+		return false
+	}
+
+	key := tAggregateKey{path: aEntry.Path, status: aEntry.Status, country: "-"}
+	if idx := strings.IndexByte(key.path, '?'); 0 <= idx {
+		key.path = key.path[:idx]
+	}
+	if nil != alAggregate.country {
+		host := getRemoteAddr(aRequest)
+		if h, _, err := net.SplitHostPort(host); nil == err {
+			host = h
+		}
+		if ip := net.ParseIP(host); nil != ip {
+			if country := alAggregate.country(ip); "" != country {
+				key.country = country
+			}
+		}
+	}
+
+	count, ok := alAggregate.counts[key]
+	if !ok {
+		if alAggregateMaxGroups <= len(alAggregate.counts) {
+			key.path = alTrafficOther
+		}
+		if count, ok = alAggregate.counts[key]; !ok {
+			count = &tAggregateCount{}
+			alAggregate.counts[key] = count
+		}
+	}
+	count.requests++
+	count.bytes += aEntry.Size
+
+	return true
+} // aggregateEntry()
+
+// `aggregateEntryOf()` returns the log entry of a group of aggregated
+// requests.
+//
+// Parameters:
+// - `aKey`: The group's identification.
+// - `aCount`: The group's counts.
+// - `aStart`: The start of the aggregation period.
+// - `aEnd`: The end of the aggregation period.
+//
+// Returns:
+// - `*TEntry`: The summary entry.
+func aggregateEntryOf(aKey tAggregateKey, aCount *tAggregateCount, aStart, aEnd time.Time) *TEntry {
+	settings := accessQueue().logSettings()
+
+	return &TEntry{
+		Remote:   "127.0.0.1",
+		User:     "-",
+		When:     aEnd,
+		Method:   "AGGREGATE",
+		Path:     aKey.path,
+		Proto:    "HTTP/1.0",
+		Status:   aKey.status,
+		Size:     aCount.bytes,
+		Referrer: "apachelogger",
+		Agent:    "mwat56/apachelogger",
+		Fields: map[string]string{
+			"aggregate_start":    settings.in(aStart).Format(time.RFC3339),
+			"aggregate_end":      settings.in(aEnd).Format(time.RFC3339),
+			"aggregate_country":  aKey.country,
+			"aggregate_requests": strconv.Itoa(aCount.requests),
+		},
+		settings: settings,
+		summary:  true,
+	}
+} // aggregateEntryOf()
+
+// `aggregating()` reports whether the aggregation-only mode is active.
+//
+// Returns:
+// - `bool`: `true` if no per-request lines must be written.
+func aggregating() bool {
+	alAggregate.Lock()
+	defer alAggregate.Unlock()
+
+	return nil != alAggregate.counts
+} // aggregating()
+
+// `aggregateLines()` returns the log lines of the aggregated counts,
+// resetting the counts.
+//
+// The caller must hold the lock of `alAggregate`.
+//
+// Returns:
+// - `[]string`: The log lines, one per group of requests.
+func aggregateLines() []string {
+	now := time.Now()
+	keys := make([]tAggregateKey, 0, len(alAggregate.counts))
+	for key := range alAggregate.counts {
+		keys = append(keys, key)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		ci, cj := alAggregate.counts[keys[i]], alAggregate.counts[keys[j]]
+		if ci.requests != cj.requests {
+			return ci.requests > cj.requests
+		}
+		if keys[i].path != keys[j].path {
+			return keys[i].path < keys[j].path
+		}
+		if keys[i].status != keys[j].status {
+			return keys[i].status < keys[j].status
+		}
+		return keys[i].country < keys[j].country
+	})
+
+	lines := make([]string, 0, len(keys))
+	for _, key := range keys {
+		entry := aggregateEntryOf(key, alAggregate.counts[key], alAggregate.since, now)
+		lines = append(lines, formatEntry(entry))
+	}
+	alAggregate.counts = make(map[tAggregateKey]*tAggregateCount)
+	alAggregate.since = now
+
+	return lines
+} // aggregateLines()
+
+// `goAggregateOutput()` periodically writes the aggregated counts to
+// the access log.
+//
+// Parameters:
+// - `aInterval`: The length of an aggregation period.
+// - `aStop`: Closed to stop the output.
+func goAggregateOutput(aInterval time.Duration, aStop <-chan struct{}) {
+	ticker := time.NewTicker(aInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-aStop:
+			return
+		case <-ticker.C:
+			alAggregate.Lock()
+			lines := aggregateLines()
+			alAggregate.Unlock()
+			goSendLines(lines, accessQueue())
+		}
+	}
+} // goAggregateOutput()
+
+/* * * * * * * * * * * * * * * * * * * * * * * * * * * * * * * * * * * * * */
+
+// `SetAggregationMode()` switches the aggregation-only privacy mode on
+// or off.
+//
+// In this mode no per-request lines are written at all (neither to
+// the access log nor to the auth-failure log, the captures, or as
+// in-progress entries). Instead, at the end of every period of length
+// `aInterval`, the number of requests and bytes sent per path (without
+// query; see also `AddPathTemplate()`), status, and client country are
+// written to the access log as entries in the current output format
+// with the method `AGGREGATE`, the bytes as size, and the fields
+// `aggregate_start`, `aggregate_end`, `aggregate_country`, and
+// `aggregate_requests`, e.g.
+//
+//	127.0.0.1 - - [25/Apr/2024:20:15:00 +0200] "AGGREGATE /index.html HTTP/1.0" 200 123456 "apachelogger" "mwat56/apachelogger" aggregate_country=DE aggregate_end=2024-04-25T20:15:00+02:00 aggregate_requests=42 aggregate_start=2024-04-25T20:00:00+02:00
+//
+// The Apache-like lines always carry these fields (regardless of
+// `AppendFields`).
+//
+// Messages of `Log()` are still written. The country is determined by
+// the function set by `SetCountryLookup()` (`-` if none).
+//
+// Switching the mode off writes the counts of the current period.
+//
+// Parameters:
+// - `aInterval`: The length of an aggregation period (`0` switches the
+// mode off).
+func SetAggregationMode(aInterval time.Duration) {
+	alAggregate.Lock()
+	defer alAggregate.Unlock()
+
+	if nil != alAggregate.stop {
+		close(alAggregate.stop)
+		alAggregate.stop = nil
+		go goSendLines(aggregateLines(), accessQueue())
+	}
+	if 0 >= aInterval {
+		alAggregate.counts = nil
+		return
+	}
+	alAggregate.counts = make(map[tAggregateKey]*tAggregateCount)
+	alAggregate.since = time.Now()
+	alAggregate.stop = make(chan struct{})
+	go goAggregateOutput(aInterval, alAggregate.stop)
+} // SetAggregationMode()
+
+// `SetCountryLookup()` sets the function determining the country of
+// the clients for the aggregation-only mode (see `SetAggregationMode()`).
+//
+// The function gets the client's full address and should return e.g.
+// the ISO 3166 country code (empty if unknown), typically by means of
+// a GeoIP database.
+//
+// Parameters:
+// - `aLookup`: The country lookup (`nil` for none).
+func SetCountryLookup(aLookup func(aIP net.IP) string) {
+	alAggregate.Lock()
+	alAggregate.country = aLookup
+	alAggregate.Unlock()
+} // SetCountryLookup()
+
+// `goSendLines()` sends `aLines` to `aLogQueue`.
+//
+// Parameters:
+// - `aLines`: The log lines to send.
+// - `aLogQueue`: The queue to send the lines to.
+func goSendLines(aLines []string, aLogQueue *tRing) {
+	for _, line := range aLines {
+		aLogQueue.push(line)
+	}
+} // goSendLines()
+
+/* _EoF_ */
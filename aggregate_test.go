@@ -0,0 +1,104 @@
+/*
+Copyright © 2024  M.Watermann, 10247 Berlin, Germany
+
+	    All rights reserved
+	EMail : <support@mwat.de>
+*/
+package apachelogger
+
+import (
+	"net"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+//lint:file-ignore ST1017 – I prefer Yoda conditions
+
+func Test_aggregateEntry(t *testing.T) {
+	defer func() {
+		SetCountryLookup(nil)
+		alAggregate.Lock()
+		if nil != alAggregate.stop {
+			close(alAggregate.stop)
+			alAggregate.stop = nil
+		}
+		alAggregate.counts = nil
+		alAggregate.Unlock()
+	}()
+	req := httptest.NewRequest("GET", "/path/to/file?lang=en", nil)
+	req.RemoteAddr = "192.168.1.23:4567"
+	e := prepEntry()
+
+	if aggregateEntry(e, req) {
+		t.Error("aggregateEntry() = true, want false (mode off)")
+	}
+
+	SetAggregationMode(time.Hour)
+	SetCountryLookup(func(aIP net.IP) string {
+		if aIP.Equal(net.ParseIP("192.168.1.23")) {
+			return "DE"
+		}
+		return ""
+	})
+	for i := 0; 2 > i; i++ {
+		if !aggregateEntry(e, req) {
+			t.Fatal("aggregateEntry() = false, want true")
+		}
+	}
+	req.RemoteAddr = "10.0.0.1:80"
+	e.Status, e.Size = 404, 10
+	aggregateEntry(e, req)
+
+	alAggregate.Lock()
+	lines := aggregateLines()
+	alAggregate.Unlock()
+	if 2 != len(lines) {
+		t.Fatalf("aggregateLines() = %q, want 2 lines", lines)
+	}
+	if !strings.Contains(lines[0], `"AGGREGATE /path/to/file HTTP/1.0" 200 54310 `) ||
+		!strings.Contains(lines[0], " aggregate_country=DE ") ||
+		!strings.Contains(lines[0], " aggregate_requests=2 ") {
+		t.Errorf("line = %q", lines[0])
+	}
+	if !strings.Contains(lines[1], `"AGGREGATE /path/to/file HTTP/1.0" 404 10 `) ||
+		!strings.Contains(lines[1], " aggregate_country=- ") ||
+		!strings.Contains(lines[1], " aggregate_requests=1 ") {
+		t.Errorf("line = %q", lines[1])
+	}
+
+	alAggregate.Lock()
+	lines = aggregateLines()
+	alAggregate.Unlock()
+	if 0 != len(lines) {
+		t.Errorf("aggregateLines() = %q, want none after reset", lines)
+	}
+} // Test_aggregateEntry()
+
+func Test_webLog_aggregating(t *testing.T) {
+	queue, authQueue := newRing(16), newRing(16)
+	defer setLogQueues(setLogQueues(queue, nil))
+	alAuthLog.Lock()
+	alAuthLog.queue, alAuthLog.statuses = authQueue, map[int]bool{401: true}
+	alAuthLog.Unlock()
+	defer func() {
+		alAuthLog.Lock()
+		alAuthLog.queue, alAuthLog.statuses = nil, nil
+		alAuthLog.Unlock()
+		SetAggregationMode(0)
+	}()
+	SetAggregationMode(time.Hour)
+
+	req := httptest.NewRequest("GET", "/admin", nil)
+	lw := &tLogWriter{ResponseWriter: httptest.NewRecorder(), when: time.Now(), status: 401}
+	if entry := webLog(lw, req, queue); nil != entry {
+		t.Errorf("webLog() = %v, want nil (aggregated)", entry)
+	}
+	if (0 != queue.length()) || (0 != authQueue.length()) {
+		t.Errorf("queued %d access and %d auth-failure lines, want none",
+			queue.length(), authQueue.length())
+	}
+} // Test_webLog_aggregating()
+
+/* _EoF_ */
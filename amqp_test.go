@@ -0,0 +1,304 @@
+/*
+Copyright © 2024  M.Watermann, 10247 Berlin, Germany
+
+	    All rights reserved
+	EMail : <support@mwat.de>
+*/
+package apachelogger
+
+import (
+	"bufio"
+	"encoding/binary"
+	"io"
+	"net"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+//lint:file-ignore ST1017 – I prefer Yoda conditions
+
+type (
+	// `tFakeBroker` is a minimal AMQP broker recording the messages
+	// published.
+	tFakeBroker struct {
+		listener net.Listener
+		mtx      sync.Mutex
+		logins   []string       // the `PLAIN` responses received
+		vhosts   []string       // the virtual hosts opened
+		messages []tAMQPMessage // the messages received
+		closed   int            // number of `connection.close` received
+		nack     bool           // reject all messages
+		drop     int            // connections to drop after the first message
+	}
+)
+
+// `newFakeBroker()` starts a fake broker on a local port.
+func newFakeBroker(t *testing.T) *tFakeBroker {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if nil != err {
+		t.Fatal(err)
+	}
+	fb := &tFakeBroker{listener: listener}
+	t.Cleanup(func() { _ = listener.Close() })
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if nil != err {
+				return
+			}
+			go fb.serve(conn)
+		}
+	}()
+
+	return fb
+} // newFakeBroker()
+
+// `url()` returns the broker's URL with the given credentials.
+func (fb *tFakeBroker) url(aUserInfo, aVHost string) string {
+	return "amqp://" + aUserInfo + "@" + fb.listener.Addr().String() + aVHost
+} // url()
+
+// `method()` reads a method frame, returning its class, method, and
+// arguments.
+func (fb *tFakeBroker) method(aReader *bufio.Reader) (uint16, uint16, []byte) {
+	kind, _, payload, err := readAMQPFrame(aReader)
+	if (nil != err) || (alAMQPFrameMethod != kind) || (4 > len(payload)) {
+		return 0, 0, nil
+	}
+
+	return binary.BigEndian.Uint16(payload), binary.BigEndian.Uint16(payload[2:]), payload[4:]
+} // method()
+
+// `serve()` handles a single client connection.
+func (fb *tFakeBroker) serve(aConn net.Conn) {
+	defer aConn.Close()
+	reader, writer := bufio.NewReader(aConn), bufio.NewWriter(aConn)
+	send := func(aChannel uint16, aMethod tAMQPArgs) {
+		_ = writeAMQPFrame(writer, alAMQPFrameMethod, aChannel, aMethod)
+		_ = writer.Flush()
+	}
+
+	header := make([]byte, 8)
+	if _, err := io.ReadFull(reader, header); (nil != err) || ("AMQP\x00\x00\x09\x01" != string(header)) {
+		return
+	}
+	send(0, amqpMethod(10, 10).octet(0).octet(9).long(0).longstr("PLAIN").longstr("en_US"))
+	_, _, args := fb.method(reader) // start-ok
+	if 4 > len(args) {
+		return
+	}
+	args = args[4+binary.BigEndian.Uint32(args):] // skip the client properties
+	_, args = amqpShortString(args)               // mechanism
+	if 4 > len(args) {
+		return
+	}
+	login := string(args[4 : 4+binary.BigEndian.Uint32(args)])
+
+	send(0, amqpMethod(10, 30).short(16).long(4096).short(0)) // tune
+	fb.method(reader)                                         // tune-ok
+	_, _, args = fb.method(reader)                            // open
+	vhost, _ := amqpShortString(args)
+	send(0, amqpMethod(10, 41).shortstr(""))
+	fb.method(reader) // channel.open
+	send(alAMQPChannel, amqpMethod(20, 11).longstr(""))
+	fb.method(reader) // confirm.select
+	send(alAMQPChannel, amqpMethod(85, 11))
+
+	fb.mtx.Lock()
+	fb.logins, fb.vhosts = append(fb.logins, login), append(fb.vhosts, vhost)
+	fb.mtx.Unlock()
+
+	for tag := uint64(1); ; tag++ {
+		class, method, args := fb.method(reader)
+		if (10 == class) && (50 == method) {
+			fb.mtx.Lock()
+			fb.closed++
+			fb.mtx.Unlock()
+			send(0, amqpMethod(10, 51))
+			return
+		}
+		if (60 != class) || (40 != method) || (2 > len(args)) {
+			return
+		}
+		var msg tAMQPMessage
+		msg.exchange, args = amqpShortString(args[2:])
+		msg.key, _ = amqpShortString(args)
+		_, _, payload, err := readAMQPFrame(reader) // content header
+		if (nil != err) || (12 > len(payload)) {
+			return
+		}
+		for size := binary.BigEndian.Uint64(payload[4:]); uint64(len(msg.body)) < size; {
+			_, _, payload, err = readAMQPFrame(reader)
+			if nil != err {
+				return
+			}
+			msg.body = append(msg.body, payload...)
+		}
+
+		fb.mtx.Lock()
+		fb.messages = append(fb.messages, msg)
+		drop, nack := 0 < fb.drop, fb.nack
+		if drop {
+			fb.drop--
+		}
+		fb.mtx.Unlock()
+		if drop {
+			return // without confirming the message
+		}
+		if nack {
+			send(alAMQPChannel, amqpMethod(60, 120).longlong(tag).octet(0))
+		} else {
+			send(alAMQPChannel, amqpMethod(60, 80).longlong(tag).octet(0))
+		}
+	}
+} // serve()
+
+// `received()` returns the messages received as `exchange/key/body`.
+func (fb *tFakeBroker) received() []string {
+	fb.mtx.Lock()
+	defer fb.mtx.Unlock()
+
+	result := make([]string, 0, len(fb.messages))
+	for _, msg := range fb.messages {
+		result = append(result, msg.exchange+"/"+msg.key+"/"+string(msg.body))
+	}
+
+	return result
+} // received()
+
+func TestNewAMQPSink(t *testing.T) {
+	fb := newFakeBroker(t)
+	sink := NewAMQPSink(TAMQPOptions{
+		URL:        fb.url("logger:secret", "/logs"),
+		Exchange:   "web",
+		RoutingKey: "access.{log.level}",
+		Timeout:    time.Second,
+	})
+
+	large := strings.Repeat("x", 10000) // more than a frame
+	data := `{"log":{"level":"warn"},"message":"one"}` + "\n" +
+		"plain text\n" + large + "\n"
+	if err := sink.Write([]byte(data)); nil != err {
+		t.Fatalf("Write() error = %v", err)
+	}
+	want := []string{
+		`web/access.warn/{"log":{"level":"warn"},"message":"one"}`,
+		"web/access./plain text",
+		"web/access./" + large,
+	}
+	if got := fb.received(); strings.Join(got, "|") != strings.Join(want, "|") {
+		t.Errorf("messages = %.200q, want %.200q", got, want)
+	}
+	if err := sink.Close(); nil != err {
+		t.Errorf("Close() error = %v", err)
+	}
+
+	fb.mtx.Lock()
+	defer fb.mtx.Unlock()
+	if (1 != len(fb.logins)) || ("\x00logger\x00secret" != fb.logins[0]) {
+		t.Errorf("logins = %q", fb.logins)
+	}
+	if (1 != len(fb.vhosts)) || ("logs" != fb.vhosts[0]) {
+		t.Errorf("vhosts = %q, want [logs]", fb.vhosts)
+	}
+	if 1 != fb.closed {
+		t.Errorf("closed = %d, want 1", fb.closed)
+	}
+} // TestNewAMQPSink()
+
+func TestNewAMQPSink_reconnect(t *testing.T) {
+	fb := newFakeBroker(t)
+	fb.drop = 1
+	sink := NewAMQPSink(TAMQPOptions{
+		URL:        fb.url("guest:guest", ""),
+		RoutingKey: "logs",
+		Timeout:    time.Second,
+	})
+
+	if err := sink.Write([]byte("one\ntwo\n")); nil == err {
+		t.Fatal("Write() error = nil for a dropped connection")
+	}
+	if err := sink.Flush(); nil != err { // publishes both again
+		t.Fatalf("Flush() error = %v", err)
+	}
+	if got := fb.received(); "/logs/one /logs/one /logs/two" != strings.Join(got, " ") {
+		t.Errorf("messages = %q, want one, one, two", got)
+	}
+	if err := sink.Flush(); nil != err { // nothing pending
+		t.Errorf("Flush() error = %v", err)
+	}
+	if got := len(fb.received()); 3 != got {
+		t.Errorf("got %d messages, want 3", got)
+	}
+	fb.mtx.Lock()
+	if (2 != len(fb.vhosts)) || ("/" != fb.vhosts[1]) {
+		t.Errorf("vhosts = %q, want two of /", fb.vhosts)
+	}
+	fb.nack = true
+	fb.mtx.Unlock()
+	if err := sink.Write([]byte("three\n")); nil == err {
+		t.Error("Write() error = nil for rejected messages")
+	}
+	if pending := len(sink.(*tAMQPSink).pending); 1 != pending {
+		t.Errorf("pending = %d, want 1", pending)
+	}
+	fb.mtx.Lock()
+	fb.nack = false
+	fb.mtx.Unlock()
+	if err := sink.Close(); nil != err {
+		t.Errorf("Close() error = %v", err)
+	}
+	if got := fb.received(); "/logs/three" != got[len(got)-1] {
+		t.Errorf("last message = %q, want /logs/three", got[len(got)-1])
+	}
+} // TestNewAMQPSink_reconnect()
+
+func Test_tAMQPSink_validate(t *testing.T) {
+	fb := newFakeBroker(t)
+	sink := NewAMQPSink(TAMQPOptions{URL: fb.url("guest:guest", "/"), Timeout: time.Second})
+	if err := sink.(tValidator).validate(); nil != err {
+		t.Errorf("validate() error = %v", err)
+	}
+
+	listener, _ := net.Listen("tcp", "127.0.0.1:0")
+	address := listener.Addr().String()
+	_ = listener.Close()
+	sink = NewAMQPSink(TAMQPOptions{URL: "amqp://" + address, Timeout: time.Second})
+	if err := sink.(tValidator).validate(); nil == err {
+		t.Error("validate() error = nil for an unreachable broker")
+	}
+	sink = NewAMQPSink(TAMQPOptions{URL: "http://" + address})
+	if err := sink.(tValidator).validate(); nil == err {
+		t.Error("validate() error = nil for an invalid scheme")
+	}
+} // Test_tAMQPSink_validate()
+
+func Test_expandAMQPTemplate(t *testing.T) {
+	record := map[string]interface{}{
+		"status": float64(404),
+		"log":    map[string]interface{}{"level": "error"},
+		"ok":     true,
+	}
+	tests := []struct {
+		template string
+		want     string
+	}{
+		{"logs", "logs"},
+		{"web.{log.level}", "web.error"},
+		{"{status}.{ok}", "404.true"},
+		{"{missing}.{log}", "."},
+		{"open{brace", "open{brace"},
+	}
+	for _, tt := range tests {
+		if got := expandAMQPTemplate(tt.template, record); tt.want != got {
+			t.Errorf("expandAMQPTemplate(%q) = %q, want %q", tt.template, got, tt.want)
+		}
+	}
+	if got := expandAMQPTemplate("web.{log.level}", nil); "web." != got {
+		t.Errorf("expandAMQPTemplate(nil) = %q, want %q", got, "web.")
+	}
+} // Test_expandAMQPTemplate()
+
+/* _EoF_ */
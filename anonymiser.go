@@ -0,0 +1,340 @@
+/*
+Copyright © 2024  M.Watermann, 10247 Berlin, Germany
+
+	    All rights reserved
+	EMail : <support@mwat.de>
+*/
+package apachelogger
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net"
+	"regexp"
+	"strings"
+	"sync"
+)
+
+//lint:file-ignore ST1017 – I prefer Yoda conditions
+
+type (
+	// `TAnonymiser` turns a remote IP address into the string to
+	// write to the logfile.
+	TAnonymiser interface {
+		// `Anonymise()` returns the (anonymised) textual representation
+		// of `aIP` for a request finished with status `aStatus`.
+		Anonymise(aIP net.IP, aStatus int) string
+	}
+
+	// `tHashAnonymiser` replaces the address by a keyed hash.
+	tHashAnonymiser struct {
+		key []byte // the secret HMAC key
+	}
+
+	// `tNoopAnonymiser` returns the address unchanged.
+	tNoopAnonymiser struct{}
+
+	// `tTruncateAnonymiser` sets the host part of the address to zero.
+	tTruncateAnonymiser struct{}
+)
+
+var (
+	// The anonymiser used by `getRemote()`.
+	alAnonymiser TAnonymiser = tTruncateAnonymiser{}
+
+	// Guard for concurrent access to `alAnonymiser` and `alExemptNets`.
+	alAnonymiserMtx sync.RWMutex
+
+	// Networks whose addresses are not anonymised.
+	alExemptNets []*net.IPNet
+
+	// `PrivateNetworks` lists the private and local networks (RFC 1918,
+	// RFC 4193, loopback, and link-local) for use with
+	// `AddAnonymiseExemption()`.
+	PrivateNetworks = []string{
+		"10.0.0.0/8", "172.16.0.0/12", "192.168.0.0/16", "127.0.0.0/8",
+		"169.254.0.0/16", "fc00::/7", "::1/128", "fe80::/10",
+	}
+
+	// RegEx to match bracketed IPv6 addresses:
+	alBracketRE = regexp.MustCompile(`\[([0-9a-f:\.]+)\]`)
+)
+
+// `Anonymise()` returns the first 16 hex digits of the HMAC-SHA256
+// hash of `aIP`.
+//
+// Part of the `TAnonymiser` interface.
+//
+// Parameters:
+// - `aIP`: The remote IP address to anonymise.
+// - `aStatus`: The HTTP status code (ignored).
+//
+// Returns:
+// - `string`: The pseudonymised address.
+func (ha tHashAnonymiser) Anonymise(aIP net.IP, aStatus int) string {
+	if ip4 := aIP.To4(); nil != ip4 {
+		aIP = ip4
+	}
+	mac := hmac.New(sha256.New, ha.key)
+	_, _ = mac.Write(aIP)
+
+	return hex.EncodeToString(mac.Sum(nil)[:8])
+} // Anonymise()
+
+// `Anonymise()` returns `aIP` unchanged.
+//
+// Part of the `TAnonymiser` interface.
+//
+// Parameters:
+// - `aIP`: The remote IP address.
+// - `aStatus`: The HTTP status code (ignored).
+//
+// Returns:
+// - `string`: The textual representation of `aIP`.
+func (na tNoopAnonymiser) Anonymise(aIP net.IP, aStatus int) string {
+	return aIP.String()
+} // Anonymise()
+
+// `Anonymise()` sets the host part of `aIP` to zero.
+//
+// IPv4 addresses keep their first three octets, IPv6 addresses their
+// first four groups.
+//
+// Part of the `TAnonymiser` interface.
+//
+// Parameters:
+// - `aIP`: The remote IP address to anonymise.
+// - `aStatus`: The HTTP status code (ignored).
+//
+// Returns:
+// - `string`: The anonymised address.
+func (ta tTruncateAnonymiser) Anonymise(aIP net.IP, aStatus int) string {
+	if ip4 := aIP.To4(); nil != ip4 {
+		return fmt.Sprintf("%d.%d.%d.0", ip4[0], ip4[1], ip4[2])
+	}
+	if ip6 := aIP.To16(); nil != ip6 {
+		return fmt.Sprintf("%x:%x:%x:%x:0:0:0:0",
+			uint16(ip6[0])<<8|uint16(ip6[1]),
+			uint16(ip6[2])<<8|uint16(ip6[3]),
+			uint16(ip6[4])<<8|uint16(ip6[5]),
+			uint16(ip6[6])<<8|uint16(ip6[7]))
+	}
+
+	return aIP.String()
+} // Anonymise()
+
+/* * * * * * * * * * * * * * * * * * * * * * * * * * * * * * * * * * * * * */
+
+// `NewHashAnonymiser()` returns an anonymiser replacing the remote
+// addresses by a keyed hash (pseudonym).
+//
+// The same address always yields the same pseudonym as long as the
+// same key is used. If `aKey` is empty a random key is generated,
+// i.e. the pseudonyms change with every program start.
+//
+// Parameters:
+// - `aKey`: The secret key to use for hashing.
+//
+// Returns:
+// - `TAnonymiser`: The hashing anonymiser.
+func NewHashAnonymiser(aKey []byte) TAnonymiser {
+	if 0 == len(aKey) {
+		aKey = make([]byte, 32)
+		_, _ = rand.Read(aKey)
+	}
+
+	return tHashAnonymiser{key: append([]byte{}, aKey...)}
+} // NewHashAnonymiser()
+
+// `NewNoopAnonymiser()` returns an anonymiser that leaves the remote
+// addresses unchanged.
+//
+// Returns:
+// - `TAnonymiser`: The do-nothing anonymiser.
+func NewNoopAnonymiser() TAnonymiser {
+	return tNoopAnonymiser{}
+} // NewNoopAnonymiser()
+
+// `NewTruncateAnonymiser()` returns the default anonymiser which sets
+// the host part of the remote addresses to zero.
+//
+// Returns:
+// - `TAnonymiser`: The truncating anonymiser.
+func NewTruncateAnonymiser() TAnonymiser {
+	return tTruncateAnonymiser{}
+} // NewTruncateAnonymiser()
+
+// `SetAnonymiser()` sets the anonymiser to use for remote addresses.
+//
+// The global flags `AnonymiseURLs` and `AnonymiseErrors` decide whether
+// the anonymiser gets called at all. Passing `nil` restores the default
+// (truncating) anonymiser.
+//
+// Parameters:
+// - `aAnonymiser`: The anonymiser to use from now on.
+func SetAnonymiser(aAnonymiser TAnonymiser) {
+	if nil == aAnonymiser {
+		aAnonymiser = tTruncateAnonymiser{}
+	}
+	alAnonymiserMtx.Lock()
+	alAnonymiser = aAnonymiser
+	alAnonymiserMtx.Unlock()
+} // SetAnonymiser()
+
+// `anonymiseAddress()` removes the port (if any) from `aAddr` and
+// anonymises the remaining IP address.
+//
+// If the 'AnonymiseURLs' flag is `false` or if the 'AnonymiseErrors'
+// flag is `false` and `aStatus` denotes an error, only the port is
+// removed. Addresses that are not IP addresses or belong to an exempted
+// network (see `AddAnonymiseExemption()`) are returned unchanged.
+//
+// Parameters:
+// - `aAddr`: The remote address (with or without port).
+// - `aStatus`: The HTTP status code (`0` if there's no request).
+//
+// Returns:
+// - `string`: The anonymised remote address.
+func anonymiseAddress(aAddr string, aStatus int) string {
+	return anonymiseAddressWith(aAddr, aStatus, nil)
+} // anonymiseAddress()
+
+// `anonymiseAddressWith()` works like `anonymiseAddress()` using
+// `aAnonymiser` (e.g. a wrapper's one, see `WithAnonymiser()`).
+//
+// Parameters:
+// - `aAddr`: The remote address (with or without port).
+// - `aStatus`: The HTTP status code (`0` if there's no request).
+// - `aAnonymiser`: The anonymiser to use (`nil` for the one set by
+// `SetAnonymiser()`).
+//
+// Returns:
+// - `string`: The anonymised remote address.
+func anonymiseAddressWith(aAddr string, aStatus int, aAnonymiser TAnonymiser) (rAddress string) {
+	var err error
+
+	// We neither need nor want the remote port here:
+	if rAddress, _, err = net.SplitHostPort(aAddr); nil != err {
+		// err == "missing port in address"
+
+		if matches := alBracketRE.FindStringSubmatch(aAddr); 1 < len(matches) {
+			// Remove "[]" from address
+			rAddress = matches[1]
+		} else {
+			rAddress = aAddr
+		}
+	}
+
+	if !AnonymiseURLs { // Bad choice generally …
+		return
+	}
+
+	if (!AnonymiseErrors) && (400 <= aStatus) {
+		// store full address for requests causing errors
+		return
+	}
+
+	addr := rAddress
+	if idx := strings.IndexByte(addr, '%'); 0 < idx {
+		addr = addr[:idx] // remove IPv6 zone
+	}
+	if ip := net.ParseIP(addr); (nil != ip) && !isExempt(ip) {
+		rAddress = anonymise(ip, aStatus, aAnonymiser)
+	}
+
+	return
+} // anonymiseAddressWith()
+
+// `AddAnonymiseExemption()` adds networks whose addresses are written
+// to the logfiles unanonymised (e.g. internal networks for debugging).
+//
+// Example (exempting private networks and an office range):
+//
+//	err := apachelogger.AddAnonymiseExemption(
+//		append(apachelogger.PrivateNetworks, "203.0.113.0/24")...)
+//
+// Parameters:
+// - `aNetworks`: The networks in CIDR notation or single IP addresses.
+//
+// Returns:
+// - `error`: A possible error parsing `aNetworks` (none is added then).
+func AddAnonymiseExemption(aNetworks ...string) error {
+	nets := make([]*net.IPNet, 0, len(aNetworks))
+	for _, network := range aNetworks {
+		if !strings.Contains(network, "/") {
+			if ip := net.ParseIP(network); nil != ip {
+				if nil != ip.To4() {
+					network += "/32"
+				} else {
+					network += "/128"
+				}
+			}
+		}
+		_, ipNet, err := net.ParseCIDR(network)
+		if nil != err {
+			return err
+		}
+		nets = append(nets, ipNet)
+	}
+
+	alAnonymiserMtx.Lock()
+	alExemptNets = append(alExemptNets, nets...)
+	alAnonymiserMtx.Unlock()
+
+	return nil
+} // AddAnonymiseExemption()
+
+// `ClearAnonymiseExemptions()` removes all networks exempted from
+// anonymisation.
+func ClearAnonymiseExemptions() {
+	alAnonymiserMtx.Lock()
+	alExemptNets = nil
+	alAnonymiserMtx.Unlock()
+} // ClearAnonymiseExemptions()
+
+// `isExempt()` reports whether `aIP` is exempted from anonymisation.
+//
+// Parameters:
+// - `aIP`: The remote IP address to check.
+//
+// Returns:
+// - `bool`: `true` if `aIP` belongs to an exempted network.
+func isExempt(aIP net.IP) bool {
+	alAnonymiserMtx.RLock()
+	defer alAnonymiserMtx.RUnlock()
+
+	for _, ipNet := range alExemptNets {
+		if ipNet.Contains(aIP) {
+			return true
+		}
+	}
+
+	return false
+} // isExempt()
+
+// `anonymise()` applies `aAnonymiser` or else the current anonymiser
+// to `aIP`.
+//
+// Parameters:
+// - `aIP`: The remote IP address to anonymise.
+// - `aStatus`: The HTTP status code of the current request.
+// - `aAnonymiser`: The anonymiser to use (`nil` for the one set by
+// `SetAnonymiser()`).
+//
+// Returns:
+// - `string`: The anonymised address.
+func anonymise(aIP net.IP, aStatus int, aAnonymiser TAnonymiser) string {
+	if nil != aAnonymiser {
+		return aAnonymiser.Anonymise(aIP, aStatus)
+	}
+	alAnonymiserMtx.RLock()
+	anon := alAnonymiser
+	alAnonymiserMtx.RUnlock()
+
+	return anon.Anonymise(aIP, aStatus)
+} // anonymise()
+
+/* _EoF_ */
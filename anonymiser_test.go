@@ -0,0 +1,110 @@
+/*
+Copyright © 2024  M.Watermann, 10247 Berlin, Germany
+
+	    All rights reserved
+	EMail : <support@mwat.de>
+*/
+package apachelogger
+
+import (
+	"net"
+	"net/http/httptest"
+	"testing"
+)
+
+//lint:file-ignore ST1017 – I prefer Yoda conditions
+
+func Test_tTruncateAnonymiser_Anonymise(t *testing.T) {
+	anon := NewTruncateAnonymiser()
+
+	tests := []struct {
+		name string
+		ip   string
+		want string
+	}{
+		{" 1", "127.0.0.1", "127.0.0.0"},
+		{" 2", "192.168.1.234", "192.168.1.0"},
+		{" 3", "2001:9876:5432:abcd:1234:5678:90ab:cdef", "2001:9876:5432:abcd:0:0:0:0"},
+		{" 4", "2001:db8::1", "2001:db8:0:0:0:0:0:0"},
+		{" 5", "::ffff:10.1.2.3", "10.1.2.0"},
+		// TODO: Add test cases.
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := anon.Anonymise(net.ParseIP(tt.ip), 200); got != tt.want {
+				t.Errorf("%q: Anonymise() = %q, want %q",
+					tt.name, got, tt.want)
+			}
+		})
+	}
+} // Test_tTruncateAnonymiser_Anonymise()
+
+func Test_tHashAnonymiser_Anonymise(t *testing.T) {
+	anon1 := NewHashAnonymiser([]byte("secret"))
+	anon2 := NewHashAnonymiser([]byte("other secret"))
+	ip1 := net.ParseIP("192.168.1.234")
+	ip2 := net.ParseIP("192.168.1.235")
+
+	h1 := anon1.Anonymise(ip1, 200)
+	if 16 != len(h1) {
+		t.Errorf("Anonymise() = %q, want 16 hex digits", h1)
+	}
+	if h := anon1.Anonymise(ip1, 404); h != h1 {
+		t.Errorf("Anonymise() = %q, want %q", h, h1)
+	}
+	if h := anon1.Anonymise(ip2, 200); h == h1 {
+		t.Errorf("Anonymise() = %q for different address", h)
+	}
+	if h := anon2.Anonymise(ip1, 200); h == h1 {
+		t.Errorf("Anonymise() = %q for different key", h)
+	}
+} // Test_tHashAnonymiser_Anonymise()
+
+func TestSetAnonymiser(t *testing.T) {
+	defer SetAnonymiser(nil)
+	req := httptest.NewRequest("GET", "/", nil)
+	req.RemoteAddr = "192.168.1.234:1234"
+
+	SetAnonymiser(NewNoopAnonymiser())
+	if got, want := getRemote(req, 200, nil), "192.168.1.234"; got != want {
+		t.Errorf("getRemote() = %q, want %q", got, want)
+	}
+
+	SetAnonymiser(nil)
+	if got, want := getRemote(req, 200, nil), "192.168.1.0"; got != want {
+		t.Errorf("getRemote() = %q, want %q", got, want)
+	}
+} // TestSetAnonymiser()
+
+func TestAddAnonymiseExemption(t *testing.T) {
+	defer ClearAnonymiseExemptions()
+
+	if err := AddAnonymiseExemption("10.0.0.0/8", "nonsense"); nil == err {
+		t.Error("AddAnonymiseExemption() expected an error")
+	}
+	if got := anonymiseAddress("10.1.2.3:80", 200); "10.1.2.0" != got {
+		t.Errorf("anonymiseAddress() = %q, want %q (nothing added)", got, "10.1.2.0")
+	}
+	if err := AddAnonymiseExemption(append(PrivateNetworks, "203.0.113.7")...); nil != err {
+		t.Fatalf("AddAnonymiseExemption() error = %v", err)
+	}
+
+	tests := []struct {
+		addr string
+		want string
+	}{
+		{"10.1.2.3:80", "10.1.2.3"},
+		{"192.168.1.23", "192.168.1.23"},
+		{"[fd00::1234]:443", "fd00::1234"},
+		{"203.0.113.7:1234", "203.0.113.7"},
+		{"203.0.113.8:1234", "203.0.113.0"},
+		{"8.8.8.8:53", "8.8.8.0"},
+	}
+	for _, tt := range tests {
+		if got := anonymiseAddress(tt.addr, 200); got != tt.want {
+			t.Errorf("anonymiseAddress(%q) = %q, want %q", tt.addr, got, tt.want)
+		}
+	}
+} // TestAddAnonymiseExemption()
+
+/* _EoF_ */
@@ -8,6 +8,7 @@ package apachelogger
 
 import (
 	"fmt"
+	"io"
 	"log"
 	"net"
 	"net/http"
@@ -15,11 +16,11 @@ import (
 	"os"
 	"os/user"
 	"path/filepath"
-	"regexp"
-	"runtime"
 	"runtime/debug"
+	"strconv"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 )
 
@@ -36,15 +37,29 @@ var (
 	// `AnonymiseErrors` decides whether to anonymise remote IP addresses
 	// that cause errors with our server using this module.
 	AnonymiseErrors = false
+
+	// `MaxBatchBytes` is the max. number of bytes of queued messages
+	// gathered into a single sink write (default: 64 KiB).
+	//
+	// A value of zero (or less) writes every message on its own.
+	MaxBatchBytes = 64 << 10
 )
 
 type (
 	// `tLogWriter` embeds a `ResponseWriter` and provides log-to-file.
 	tLogWriter struct {
-		http.ResponseWriter           // used to construct the HTTP response
-		size                int       // the size/length of the data sent
-		status              int       // HTTP status code of current request
-		when                time.Time // access time
+		http.ResponseWriter               // used to construct the HTTP response
+		size                int           // the size/length of the data sent
+		status              int           // HTTP status code of current request
+		when                time.Time     // access time
+		duration            time.Duration // time taken to serve the request
+		request             *http.Request // the request served (see `Hijack()`)
+		hijacked            bool          // the connection was hijacked
+		progress            *tProgress    // tracker of long-running requests
+		queue               *tRing        // the queue of the access log entries
+		declared            string        // the response's `Content-Length` header
+		informational       string        // the `1xx` status codes sent
+		cache               string        // the response's cache status
 	}
 )
 
@@ -59,24 +74,82 @@ type (
 // - `int`: The number of bytes written.
 // - `error`: a possible error of processing.
 func (lw *tLogWriter) Write(aData []byte) (int, error) {
-	if 0 == lw.status {
-		lw.status = 200
+	lw.implicitHeader()
+	n, err := lw.ResponseWriter.Write(aData)
+
+	// Add length of _all_ chunks of data actually written.
+	lw.size += n // We need this value for the logfile.
+	if nil != lw.progress {
+		lw.progress.sent(n)
 	}
-	// Add length of _all_ chunks of data written.
-	lw.size += len(aData) // We need this value for the logfile.
 
-	return lw.ResponseWriter.Write(aData)
+	return n, err
 } // Write()
 
+// `ReadFrom()` copies the data from `aReader` to the connection as
+// part of an HTTP reply.
+//
+// Part of the `io.ReaderFrom` interface, so `http.ServeContent()` and
+// `http.ServeFile()` keep using the `sendfile` system call through the
+// logging wrapper.
+//
+// Parameters:
+// - `aReader`: The source of the data to write.
+//
+// Returns:
+// - `int64`: The number of bytes written.
+// - `error`: A possible error of processing.
+func (lw *tLogWriter) ReadFrom(aReader io.Reader) (int64, error) {
+	var (
+		n   int64
+		err error
+	)
+	lw.implicitHeader()
+	if rf, ok := lw.ResponseWriter.(io.ReaderFrom); ok {
+		n, err = rf.ReadFrom(aReader)
+	} else {
+		n, err = io.Copy(lw.ResponseWriter, aReader)
+	}
+	lw.size += int(n)
+	if nil != lw.progress {
+		lw.progress.sent(int(n))
+	}
+
+	return n, err
+} // ReadFrom()
+
+// `implicitHeader()` records the status (and declared length) of the
+// response header sent implicitly by the first write.
+func (lw *tLogWriter) implicitHeader() {
+	if 0 == lw.status {
+		lw.status = http.StatusOK
+		lw.declared = lw.ResponseWriter.Header().Get("Content-Length")
+		lw.captureCacheStatus()
+	}
+} // implicitHeader()
+
 // `WriteHeader()` sends an HTTP response header with the provided
 // status code.
 //
+// Informational status codes (`1xx`, e.g. `103 Early Hints`) are
+// passed on without replacing the request's final result code.
+//
 // Part of the `http.ResponseWriter` interface.
 //
 // Parameters:
 // - `aStatus`: The request's final result code.
 func (lw *tLogWriter) WriteHeader(aStatus int) {
+	if isInformational(aStatus) {
+		lw.sentInformational(aStatus)
+		lw.ResponseWriter.WriteHeader(aStatus)
+		return
+	}
 	lw.status = aStatus
+	lw.declared = lw.ResponseWriter.Header().Get("Content-Length")
+	lw.captureCacheStatus()
+	if nil != lw.progress {
+		lw.progress.setStatus(aStatus)
+	}
 	lw.ResponseWriter.WriteHeader(aStatus)
 } // WriteHeader()
 
@@ -84,9 +157,39 @@ func (lw *tLogWriter) WriteHeader(aStatus int) {
 
 type (
 	// Simple structure implementing the `io.Writer` interface.
-	tLogLog struct{}
+	tLogLog struct {
+		server *http.Server // the server whose errors are logged
+	}
+
+	// `tWrapper` is the handler returned by `Wrap()` and its siblings.
+	tWrapper struct {
+		http.Handler        // the logging handler
+		errorLog     *tRing // the queue of the wrapper's error log
+	}
 )
 
+// `serverErrorQueue()` returns the queue of the error log of the
+// wrapper serving `aServer`'s requests.
+//
+// The server's handler is looked up when a message is logged, so it
+// may be set after the error logger.
+//
+// Parameters:
+// - `aServer`: The server whose messages are to be logged.
+//
+// Returns:
+// - `*tRing`: The error log's queue (the global one if the server's
+// handler isn't a wrapper of this package).
+func serverErrorQueue(aServer *http.Server) *tRing {
+	if nil != aServer {
+		if wrapper, ok := aServer.Handler.(*tWrapper); ok {
+			return wrapper.errorLog
+		}
+	}
+
+	return errorQueue()
+} // serverErrorQueue()
+
 // `Write()` sends `aMessage` from the running server to the log file.
 // It returns the number of bytes written and `nil`.
 //
@@ -101,8 +204,15 @@ type (
 func (ll tLogLog) Write(aMessage []byte) (int, error) {
 	result := len(aMessage)
 	if 0 < result {
+		msg, remote := rewriteServerError(string(aMessage))
 		// Write to the error logfile in background:
-		go goCustomLog(`errorLogger`, string(aMessage), `ERR`, time.Now(), alErrorQueue)
+		spawnCustom(func(aNow time.Time) {
+			entry := customEntry(`errorLogger`, msg, `ERR`, aNow)
+			if "" != remote {
+				entry.Remote = remote // to find the client's requests
+			}
+			queueCustomEntry(entry, serverErrorQueue(ll.server))
+		})
 	}
 
 	return result, nil
@@ -110,14 +220,43 @@ func (ll tLogLog) Write(aMessage []byte) (int, error) {
 
 // `SetErrorLog()` sets the error logger of `aServer`.
 //
+// The messages are written to the error log of the wrapper set as the
+// server's `Handler` (see `WrapWith()`), so a public and an admin
+// server in the same program log their errors separately; if the
+// handler isn't a wrapper (e.g. a router wrapping it) the error log of
+// the first wrapper is used.
+//
 // Parameters:
 // - `aServer` The server instance whose errlogger is to be set.
 func SetErrorLog(aServer *http.Server) {
-	aServer.ErrorLog = log.New(tLogLog{}, "", log.Llongfile)
+	aServer.ErrorLog = log.New(tLogLog{server: aServer}, "", log.Llongfile)
 } // SetErrLog()
 
 /* * * * * * * * * * * * * * * * * * * * * * * * * * * * * * * * * * * * * */
 
+// `elapsed()` returns the time passed between `aFrom` and `aTo`.
+//
+// Both times should be taken by `time.Now()` in this process, so the
+// difference is computed from their monotonic clock readings: a step
+// of the wall clock (e.g. by NTP) during a long request doesn't affect
+// the result. Should one of the times lack that reading (e.g. after
+// `Round(0)`, `In()`, or `UTC()`) the wall clock is used instead, and
+// a negative difference is reported as zero.
+//
+// Parameters:
+// - `aFrom`: The start time (e.g. of the request).
+// - `aTo`: The end time.
+//
+// Returns:
+// - `time.Duration`: The non-negative time passed.
+func elapsed(aFrom, aTo time.Time) time.Duration {
+	if result := aTo.Sub(aFrom); 0 < result {
+		return result
+	}
+
+	return 0
+} // elapsed()
+
 // `getPath()` returns the requested path (and CGI query).
 //
 // Parameters:
@@ -177,19 +316,6 @@ func getProto(aRequest *http.Request) (rProto string) {
 	return
 } // getProto()
 
-var (
-	// RegEx to match IPv4 addresses:
-	alIpv4RE = regexp.MustCompile(`^([0-9]{1,3})\.([0-9]{1,3})\.([0-9]{1,3})\.[0-9]{1,3}`)
-
-	// RegEx to match IPv6 addresses:
-	alIpv6RE = regexp.MustCompile(`([0-9a-f]{1,4})\:([0-9a-f]{1,4})\:([0-9a-f]{1,4})\:([0-9a-f]{1,4})\:([0-9a-f]{1,4})\:([0-9a-f]{1,4})\:([0-9a-f]{1,4})\:([0-9a-f]{1,4})`)
-
-	alBracketRE = regexp.MustCompile(`\[([0-9a-f:\.]+)\]`)
-
-	// alLastLoggingDate stores the last day of logging
-	alLastLoggingDate time.Time = time.Now()
-)
-
 // `getRemote()` reads and anonymises the remote address.
 //
 // It takes an http.Request and the HTTP status code of the current request.
@@ -201,61 +327,43 @@ var (
 // If the 'AnonymiseURLs' flag is set to 'true', the function will anonymise
 // the remote IP addresses. If the 'AnonymiseErrors' flag is set to 'true',
 // the function will anonymise the remote IP addresses of requests causing
-// errors.
+// errors. The actual anonymisation is done by `aAnonymiser` or else
+// the anonymiser set with `SetAnonymiser()`.
 //
 // Parameters:
 // - `aRequest`: The HTTP request object.
 // - `aStatus`: The HTTP status code.
+// - `aAnonymiser`: The anonymiser to use (`nil` for the default).
 //
 // Returns:
 // - `string`: The anonymised remote address as a string.
-func getRemote(aRequest *http.Request, aStatus int) (rAddress string) {
-	var err error
-
-	addr := aRequest.RemoteAddr
-	// We neither need nor want the remote port here:
-	if rAddress, _, err = net.SplitHostPort(addr); nil != err {
-		// err == "missing port in address"
-
-		if matches := alBracketRE.FindStringSubmatch(addr); 1 < len(matches) {
-			// Remove "[]" from address
-			rAddress = matches[1]
-		} else {
-			rAddress = addr
-		}
-	}
+func getRemote(aRequest *http.Request, aStatus int, aAnonymiser TAnonymiser) string {
+	return anonymiseAddressWith(getRemoteAddr(aRequest), aStatus, aAnonymiser)
+} // getRemote()
 
+// `getRemoteAddr()` returns the request's remote address.
+//
+// If the request went through a proxy, the client address given by the
+// proxy is returned.
+//
+// Parameters:
+// - `aRequest`: The HTTP request object.
+//
+// Returns:
+// - `string`: The (not anonymised) remote address, possibly with port.
+func getRemoteAddr(aRequest *http.Request) string {
 	// Check whether the request went through a proxy.
 	// X-Forwarded-For: client, proxy1, proxy2
 	// Note: "proxy3" is the actual sender (i.e. aRequest.RemoteAddr).
 	if xff := strings.Trim(aRequest.Header.Get("X-Forwarded-For"), ","); 0 < len(xff) {
 		addrs := strings.Split(xff, ",")
 		if ip := net.ParseIP(addrs[0]); nil != ip {
-			rAddress = ip.String()
+			return ip.String()
 		}
 	}
 
-	if !AnonymiseURLs { // Bad choice generally …
-		return
-	}
-
-	if (!AnonymiseErrors) && (400 <= aStatus) {
-		// store full address for requests causing errors
-		return
-	}
-
-	if matches := alIpv4RE.FindStringSubmatch(rAddress); 3 < len(matches) {
-		// anonymise the remote IPv4 address:
-		rAddress = fmt.Sprintf("%s.%s.%s.0",
-			matches[1], matches[2], matches[3])
-	} else if matches := alIpv6RE.FindStringSubmatch(rAddress); 8 < len(matches) {
-		// anonymise the remote IPv6 address:
-		rAddress = fmt.Sprintf("%s:%s:%s:%s:0:0:0:0",
-			matches[1], matches[2], matches[3], matches[4])
-	}
-
-	return
-} // getRemote()
+	return aRequest.RemoteAddr
+} // getRemoteAddr()
 
 // `getUsername()` returns the request's username (if any).
 //
@@ -276,66 +384,90 @@ func getUsername(aURL *url.URL) string {
 
 /* * * * * * * * * * * * * * * * * * * * * * * * * * * * * * * * * * * * * */
 
-const (
-	/*
-		91.64.58.179 - username [25/Apr/2018:20:16:45 +0200] "GET /path/to/file?lang=en HTTP/1.1" 200 27155 "-" "Mozilla/5.0 (X11; Linux x86_64; rv:56.0) Gecko/20100101 Firefox/56.0"
-
-		2001:4dd6:b474:0:1234:5678:90ab:cdef - - [24/Apr/2018:23:58:42 +0200] "GET /path/to/file HTTP/1.1" 200 5361 "https://www.google.de/" "Mozilla/5.0 (iPhone; CPU iPhone OS 12_2 like Mac OS X) AppleWebKit/605.1.15 (KHTML, like Gecko) Version/12.1 Mobile/15E148 Safari/604.1"
-	*/
-
-	// `alApacheFormatPattern` is the format of Apache like logfile entries:
-	alApacheFormatPattern = `%s - %s [%s] "%s %s %s" %d %d "%s" "%s"` + "\n"
+type (
+	// `tLogQueues` holds the queues of the global access and error log
+	// used by e.g. `Log()` and `Err()`.
+	tLogQueues struct {
+		accessLog *tRing // queue of the access log messages
+		errorLog  *tRing // queue of the error log messages (may be `accessLog`)
+		started   bool   // whether the writers write to the first wrapper's sinks
+		discard   bool   // whether the writers discard the messages (see `stopQueues()`)
+	}
 )
 
 var (
-	// Channel to send access log messages to and read messages from.
-	alAccessQueue = make(chan string, 127)
-
 	// Name of current user (used by `goCustomLog()`).
 	alCurrentUser string = "-"
 
-	// Channel to send error log messages to and read messages from.
-	alErrorQueue = make(chan string, 127)
+	// The global log queues (a `*tLogQueues`, replaced as a whole
+	// while holding `alFileQueuesMtx`).
+	alLogQueues atomic.Value
 
-	// Make sure to initialise the wrapper only once.
-	alWrapOnce sync.Once
+	// Make sure to look up the current user only once.
+	alUserOnce sync.Once
 )
 
+func init() {
+	alLogQueues.Store(&tLogQueues{
+		accessLog: newRing(alRingSize),
+		errorLog:  newRing(alRingSize),
+	})
+} // init()
+
+// `accessQueue()` returns the queue of the global access log.
+//
+// Returns:
+// - `*tRing`: The queue to send access log messages to.
+func accessQueue() *tRing {
+	return alLogQueues.Load().(*tLogQueues).accessLog
+} // accessQueue()
+
+// `errorQueue()` returns the queue of the global error log.
+//
+// Returns:
+// - `*tRing`: The queue to send error log messages to.
+func errorQueue() *tRing {
+	return alLogQueues.Load().(*tLogQueues).errorLog
+} // errorQueue()
+
 // `compareDayStamps()` checks whether the current message's date differs
 // from the last logging date.
 //
+// Parameters:
+// - `aLastDate`: The last logging date (updated if the day changed).
+// - `aSettings`: The settings of the log (for its time zone).
+//
 // Returns:
 // - `bool`: `true` if the day changed from the day the
 // last protocol message was logged, or `false` otherwise.
-func compareDayStamps() bool {
+func compareDayStamps(aLastDate *time.Time, aSettings *tLogSettings) bool {
 	var (
 		currentLoggingDate  = time.Now()
-		nYear, nMonth, nDay = currentLoggingDate.Date()
-		oYear, oMonth, oDay = alLastLoggingDate.Date()
+		nYear, nMonth, nDay = aSettings.in(currentLoggingDate).Date()
+		oYear, oMonth, oDay = aSettings.in(*aLastDate).Date()
 	)
 
 	changed := (nDay != oDay) ||
 		(nMonth != oMonth) ||
 		(nYear != oYear)
 	if changed {
-		alLastLoggingDate = currentLoggingDate
+		*aLastDate = currentLoggingDate
 	}
 
 	return changed
 } // compareDayStamps()
 
-// `goCustomLog()` sends a custom log message on behalf of `Log()` and `Err()`.
+// `customEntry()` returns the log entry of a custom log message.
 //
 // Parameters:
 // - `aSender`: Identification of the message's sender.
 // - `aMessage`: The message to write to the logfile.
 // - `aMethod`: Either `LOG` or `ERR`.
 // - `aTime`: The time to log.
-// - `aLogChannel`: The channel to send the message to.
-func goCustomLog(aSender, aMessage, aMethod string, aTime time.Time, aLogChannel chan<- string) {
-	defer func() {
-		_ = recover() // panic: send on closed channel
-	}()
+//
+// Returns:
+// - `*TEntry`: The message's log entry.
+func customEntry(aSender, aMessage, aMethod string, aTime time.Time) *TEntry {
 	if "" == aSender {
 		aSender = filepath.Base(os.Args[0])
 	}
@@ -347,150 +479,450 @@ func goCustomLog(aSender, aMessage, aMethod string, aTime time.Time, aLogChannel
 		aMessage = strings.TrimSpace(strings.Replace(aMessage, "  ", " ", -1))
 	}
 
-	// build the log string and send it to the channel:
-	aLogChannel <- fmt.Sprintf(alApacheFormatPattern,
-		"127.0.0.1",
-		alCurrentUser,
-		aTime.Format("02/Jan/2006:15:04:05 -0700"),
-		aMethod,
-		aMessage,
-		"HTTP/1.0",
-		500,
-		len(aMessage),
-		aSender, // instead of Referer header
-		"mwat56/apachelogger",
-	)
+	return &TEntry{
+		Remote:   "127.0.0.1",
+		User:     alCurrentUser,
+		When:     aTime,
+		Method:   aMethod,
+		Path:     aMessage,
+		Proto:    "HTTP/1.0",
+		Status:   500,
+		Size:     len(aMessage),
+		Referrer: aSender, // instead of Referer header
+		Agent:    "mwat56/apachelogger",
+		origin:   &tErrorOrigin{}, // not a request's entry
+	}
+} // customEntry()
+
+// `openSinks()` returns the file sinks of `aAccessLog` and `aErrorLog`
+// after checking that both files can be opened.
+//
+// Parameters:
+// - `aAccessLog`: The name of the file to use for access log messages.
+// - `aErrorLog`: The name of the file to use for error log messages.
+//
+// Returns:
+// - `TSink`: The access log's sink (`nil` if `aAccessLog` is empty).
+// - `TSink`: The error log's sink (`nil` if `aErrorLog` is empty).
+// - `error`: A possible error opening one of the files.
+func openSinks(aAccessLog, aErrorLog string) (rAccess, rError TSink, rErr error) {
+	if 0 < len(aAccessLog) {
+		absFile, _ := filepath.Abs(aAccessLog)
+		aAccessLog = absFile
+		accessFile, err := os.OpenFile(aAccessLog, alOpenFlags, 0640) // #nosec G302
+		_ = accessFile.Close()
+		if nil != err {
+			return nil, nil, fmt.Errorf("apachelogger: can't open access logfile: %w", err)
+		}
+		rAccess = NewFileSink(aAccessLog)
+	}
+
+	if 0 < len(aErrorLog) {
+		absFile, _ := filepath.Abs(aErrorLog)
+		aErrorLog = absFile
+		if aErrorLog == aAccessLog {
+			rError = rAccess
+		} else {
+			errorFile, err := os.OpenFile(aErrorLog, alOpenFlags, 0640) // #nosec G302
+			_ = errorFile.Close()
+			if nil != err {
+				return nil, nil, fmt.Errorf("apachelogger: can't open error logfile: %w", err)
+			}
+			rError = NewFileSink(aErrorLog)
+		}
+	}
+
+	return
+} // openSinks()
+
+// `startWriters()` starts the background writers of the access and
+// error log.
+//
+// The first call starts the writers of the global queues used by e.g.
+// `Log()` and `Err()`. Later calls return the queues of the writers
+// already writing to the given sinks (file sinks are identified by
+// their filename), starting new writers for other sinks.
+//
+// Parameters:
+// - `aAccessSink`: The sink to use for access log messages.
+// - `aErrorSink`: The sink to use for error log messages.
+//
+// Returns:
+// - `*tRing`: The queue of the access log messages.
+// - `*tRing`: The queue of the error log messages.
+func startWriters(aAccessSink, aErrorSink TSink) (rAccess, rError *tRing) {
+	alUserOnce.Do(func() {
+		if usr, err := user.Current(); (nil == err) && (0 < len(usr.Username)) {
+			alCurrentUser = usr.Username
+		}
+	})
+
+	alFileQueuesMtx.Lock()
+	defer alFileQueuesMtx.Unlock()
+
+	if current := alLogQueues.Load().(*tLogQueues); !current.started {
+		queues := &tLogQueues{started: true}
+		if current.discard { // replace the queues of the discarding writers
+			current.accessLog.close()
+			current.errorLog.close()
+			queues.accessLog = newShardedRing(alRingSize, alQueueShards)
+			queues.errorLog = newRing(alRingSize)
+		} else { // keep the messages logged before
+			queues.accessLog = shardQueue(current.accessLog, alQueueShards)
+			queues.errorLog = current.errorLog
+		}
+		queues.accessLog.state.started(true) // for `Flush()` before the writer runs
+		go goDoLogWrite(aAccessSink, queues.accessLog)
+
+		if (nil != aErrorSink) && sameSink(aErrorSink, aAccessSink) {
+			mergeQueue(queues.errorLog, queues.accessLog)
+			queues.errorLog = queues.accessLog
+		} else {
+			queues.errorLog.state.started(true)
+			go goDoLogWrite(aErrorSink, queues.errorLog)
+		}
+		alLogQueues.Store(queues)
+
+		registerSinkQueue(aAccessSink, queues.accessLog)
+		registerSinkQueue(aErrorSink, queues.errorLog)
+
+		return queues.accessLog, queues.errorLog
+	}
+
+	rAccess = sinkQueue(aAccessSink, alQueueShards)
+	if (nil != aErrorSink) && sameSink(aErrorSink, aAccessSink) {
+		rError = rAccess
+	} else {
+		rError = sinkQueue(aErrorSink, 1)
+	}
+
+	return
+} // startWriters()
+
+// `goCustomLog()` sends a custom log message on behalf of `Log()` and `Err()`.
+//
+// Parameters:
+// - `aSender`: Identification of the message's sender.
+// - `aMessage`: The message to write to the logfile.
+// - `aMethod`: Either `LOG` or `ERR`.
+// - `aTime`: The time to log.
+// - `aLogQueue`: The queue to send the message to.
+func goCustomLog(aSender, aMessage, aMethod string, aTime time.Time, aLogQueue *tRing) {
+	queueCustomEntry(customEntry(aSender, aMessage, aMethod, aTime), aLogQueue)
 } // goCustomLog()
 
-// `goDoLogWrite()` performs the actual file write.
+// `queueCustomEntry()` prepares a custom log entry and sends it to
+// `aLogQueue`.
+//
+// Parameters:
+// - `aEntry`: The log entry to send.
+// - `aLogQueue`: The queue to send the entry to.
+//
+// Returns:
+// - `bool`: `false` if the entry was dropped by `aLogQueue`.
+func queueCustomEntry(aEntry *TEntry, aLogQueue *tRing) bool {
+	return queueEntry(aEntry, aLogQueue, false)
+} // queueCustomEntry()
+
+// `queueEntry()` prepares a custom log entry and sends it to
+// `aLogQueue`.
+//
+// Parameters:
+// - `aEntry`: The log entry to send.
+// - `aLogQueue`: The queue to send the entry to.
+// - `aWait`: Whether to wait for a free slot if the queue is full
+// (otherwise as set by `SetBlockWhenFull()`).
+//
+// Returns:
+// - `bool`: `false` if the entry was dropped by `aLogQueue`.
+func queueEntry(aEntry *TEntry, aLogQueue *tRing, aWait bool) bool {
+	if nil == aEntry.settings {
+		aEntry.settings = aLogQueue.logSettings()
+	}
+	prepareEntry(aEntry)
+	notifyWebhooks(aEntry)
+	if !routeEntry(aEntry, aLogQueue) {
+		return true // not meant for `aLogQueue`
+	}
+
+	// build the log string and send it to the queue:
+	stampSequence(aEntry, aLogQueue)
+	line := formatEntry(aEntry)
+	queued := false
+	if aWait {
+		queued = aLogQueue.pushWait(line)
+	} else {
+		queued = aLogQueue.push(line)
+	}
+	writeOutputs(aEntry, aLogQueue)
+
+	return queued
+} // queueEntry()
+
+// `goDoLogWrite()` performs the actual log write.
 //
-// This function runs indefinitely, handling all write requests.
+// This function runs until `aMsgSource` gets closed, handling all
+// write requests. While there are no messages it waits without using
+// the CPU.
 //
 // Parameters:
-// - `aLogFile`: The name of the logfile to write to.
+// - `aSink`: The sink to write the log messages to (`nil` discards them).
 // - `aMsgSource`: The source of log messages to write.
-func goDoLogWrite(aLogFile string, aMsgSource <-chan string) {
-	var (
-		cLen       int
-		closeTimer *time.Timer
-		err        error
-		logFile    *os.File
-	)
+func goDoLogWrite(aSink TSink, aMsgSource *tRing) {
+	if nil == aSink {
+		aSink = Discard
+	}
+	var closeTimer *time.Timer
+	aMsgSource.state.started(true)
 	defer func() {
+		aMsgSource.releaseBarriers(aSink)
 		// try to avoid resource leaks
-		if nil != logFile {
-			_ = logFile.Close()
+		if nil != aSink {
+			_ = aSink.Close()
 		}
 		if nil != closeTimer {
 			_ = closeTimer.Stop()
 		}
+		atomic.StoreInt32(&aMsgSource.state.busy, 0)
+		aMsgSource.state.started(false)
 	}()
 
-	time.Sleep(1234) // let the application initialise
 	closeTimer = time.NewTimer(alFileCloserDelay)
 
+	var (
+		batch    = make([]string, 0, alRingBatch)
+		buf      = make([]byte, 0, 4096)
+		lastDate = time.Now() // the day of the last batch
+	)
 	for { // Wait for strings to log/write
-		select {
-		case txt, more := <-aMsgSource:
-			if !more { // Channel closed
-				return
+		atomic.StoreInt32(&aMsgSource.state.busy, 1)
+		aMsgSource.releaseBarriers(aSink)
+		if target, ok := aMsgSource.takeTarget(); ok {
+			_ = aSink.Close()
+			aMsgSource.state.closed()
+			if aSink = target; nil == aSink {
+				aSink = Discard // until there's a new target
 			}
-			if compareDayStamps() { // it's a new day …
-				txt = "\n" + txt
+		}
+		if batch = aMsgSource.popBatch(batch[:0]); 0 < len(batch) {
+			settings := aMsgSource.logSettings()
+			if previous := lastDate; compareDayStamps(&lastDate, settings) { // it's a new day …
+				var err error
+				if buf, err = dayChange(aSink, buf, previous, lastDate, settings); nil != err {
+					aMsgSource.state.wrote(err)
+				}
 			} // if
 
-			if nil == logFile {
-				// Loop until we actually opened the logfile:
-				for {
-					if logFile, err = os.OpenFile(aLogFile,
-						alOpenFlags, 0640); /* #nosec G302 */ nil == err {
-						break
-					}
-					time.Sleep(1234)
-					closeTimer.Reset(alFileCloserDelay)
-				} // for
-			} // if
-			fmt.Fprint(logFile, txt)
-			if cLen = len(aMsgSource); 0 < cLen {
-				// Batch all waiting messages at once.
-				for txt = range aMsgSource {
-					fmt.Fprint(logFile, txt)
-					cLen--
-					if 0 < cLen {
-						continue
-					}
-					if cLen = len(aMsgSource); 0 == cLen {
-						break
+			// Batch all waiting messages into as few writes as possible.
+			count := 0
+			for 0 < len(batch) {
+				count += len(batch)
+				for _, txt := range batch {
+					if (0 < len(buf)) && (len(buf)+len(txt) > MaxBatchBytes) {
+						aMsgSource.state.wrote(aSink.Write(buf))
+						buf = buf[:0]
 					}
-				} // for
-			} // if
+					buf = append(buf, txt...)
+				}
+				batch = aMsgSource.popBatch(batch[:0])
+			} // for
+			aMsgSource.state.wrote(aSink.Write(buf))
+			if (4096 < cap(buf)) && (MaxBatchBytes < cap(buf)) {
+				buf = make([]byte, 0, 4096) // release an overlong line
+			} else {
+				buf = buf[:0]
+			}
+			if err := aSink.Flush(); nil != err {
+				aMsgSource.state.wrote(err)
+			}
+			atomic.AddUint64(&aMsgSource.written, uint64(count))
+			aMsgSource.adapt()
 			closeTimer.Reset(alFileCloserDelay)
+			continue
+		}
+		if aMsgSource.isClosed() {
+			return
+		}
+		atomic.StoreInt32(&aMsgSource.state.busy, 0)
+		if !aMsgSource.park() {
+			continue // new messages arrived meanwhile
+		}
+
+		select {
+		case <-aMsgSource.notify:
 
 		case <-closeTimer.C:
-			// Nothing logged in eight seconds => close the file.
-			if nil != logFile {
-				_ = logFile.Close()
-				logFile = nil
-			}
+			// Nothing logged in eight seconds => close the sink.
+			_ = aSink.Close()
+			aMsgSource.state.closed()
+			aMsgSource.adapt()
 			closeTimer.Reset(alFileCloserDelay)
 		} // select
 	} // for
 } // goDoLogWrite()
 
-// `goIgnoreLog()` is a background goroutine that reads from `aMsgSource`
-// ignoring the values.
+// `webLog()` prepares and queues the log entry of a request.
+//
+// This function is called once for each request.
 //
 // Parameters:
-// - `aMsgSource`: The channel to read the messages from.
-func goIgnoreLog(aMsgSource <-chan string) {
-	for {
-		select {
-		case txt := <-aMsgSource:
-			// just empty the channel
-			if "" != txt {
-				txt = ""
-			}
+// - `aLogger`: The handler of log messages.
+// - `aRequest:` An HTTP request received by the server.
+// - `aLogQueue`: The queue to write the message to.
+//
+// Returns:
+// - `*TEntry`: The request's log entry (`nil` if it was aggregated).
+func webLog(aLogger *tLogWriter, aRequest *http.Request, aLogQueue *tRing) *TEntry {
+	entry, suspect := webEntry(aLogger, aRequest)
+	checkMailAlerts(entry)
+	countTraffic(entry)
+	countSummary(entry)
+	if aggregateEntry(entry, aRequest) {
+		aLogger.status, aLogger.size = 0, 0
+		return nil
+	}
+	logAuthFailure(entry, aRequest.RemoteAddr) // never a client-supplied header
+	if suppressDuplicate(entry, aLogQueue) {
+		aLogger.status, aLogger.size = 0, 0
+		return nil
+	}
 
-		default:
-			runtime.Gosched()
-		}
+	// build the log string and send it to the queue:
+	stampSequence(entry, aLogQueue)
+	line := formatEntry(entry)
+	if !accessPaused() {
+		aLogQueue.pushKey(aRequest.RemoteAddr, line)
+		writeOutputs(entry, aLogQueue)
+		publishTail(entry, line)
+		alRecent.add(entry)
+	}
+	if suspect {
+		logSuspicious(line)
 	}
-} // goIgnoreLog()
 
-// `goWebLog()` prepares the actual background logging.
+	aLogger.status, aLogger.size = 0, 0
+
+	return entry
+} // webLog()
+
+// `checkContentLength()` adds the response's declared length to
+// `aEntry` and flags a difference to the bytes actually written.
 //
-// This function is called once for each request.
+// The declared length is written as the field `content_length`; if
+// the response's body was shorter (e.g. because the client went away
+// or the handler failed) or longer than declared, the field
+// `length_mismatch` is set to `short` or `long` respectively.
+// Responses without a body (to `HEAD` requests and with the status
+// `1xx`, `204`, or `304`) aren't flagged.
+//
+// Parameters:
+// - `aEntry`: The log entry to complete.
+// - `aDeclared`: The response's `Content-Length` header (if any).
+func checkContentLength(aEntry *TEntry, aDeclared string) {
+	if "" == aDeclared {
+		return
+	}
+	declared, err := strconv.ParseInt(aDeclared, 10, 64)
+	if (nil != err) || (0 > declared) {
+		aEntry.SetField("content_length", sanitiseString(aDeclared))
+		aEntry.SetField("length_mismatch", "invalid")
+		return
+	}
+	aEntry.SetField("content_length", strconv.FormatInt(declared, 10))
+
+	if (http.MethodHead == aEntry.Method) || (200 > aEntry.Status) ||
+		(http.StatusNoContent == aEntry.Status) ||
+		(http.StatusNotModified == aEntry.Status) {
+		return
+	}
+	switch size := int64(aEntry.Size); {
+	case size < declared:
+		aEntry.SetField("length_mismatch", "short")
+	case size > declared:
+		aEntry.SetField("length_mismatch", "long")
+	}
+} // checkContentLength()
+
+// `webEntry()` returns the prepared log entry of a request.
 //
 // Parameters:
 // - `aLogger`: The handler of log messages.
 // - `aRequest:` An HTTP request received by the server.
-// - `aLogChannel`: The channel to write the message to.
-func goWebLog(aLogger *tLogWriter, aRequest *http.Request,
-	aLogChannel chan<- string) {
-	defer func() {
-		_ = recover() // panic: send on closed channel
-	}()
+//
+// Returns:
+// - `*TEntry`: The request's log entry.
+// - `bool`: `true` if the request was flagged as suspicious.
+func webEntry(aLogger *tLogWriter, aRequest *http.Request) (*TEntry, bool) {
+	return requestEntry(aLogger, aRequest, false)
+} // webEntry()
+
+// `requestEntry()` returns the prepared log entry of a request.
+//
+// Parameters:
+// - `aLogger`: The handler of log messages.
+// - `aRequest:` An HTTP request received by the server.
+// - `aPreview`: Whether to skip the steps with side effects, i.e. the
+// hostname lookups and the transformers (see `Preview()`).
+//
+// Returns:
+// - `*TEntry`: The request's log entry.
+// - `bool`: `true` if the request was flagged as suspicious.
+func requestEntry(aLogger *tLogWriter, aRequest *http.Request, aPreview bool) (*TEntry, bool) {
 	agent := aRequest.UserAgent()
 	if "" == agent {
 		agent = "-"
 	}
+	settings := aLogger.queue.logSettings()
+
+	entry := &TEntry{
+		Remote:   getRemote(aRequest, aLogger.status, settings.remoteAnonymiser()),
+		User:     getUsername(aRequest.URL),
+		When:     aLogger.when,
+		Method:   aRequest.Method,
+		Path:     getPath(aRequest.URL),
+		Proto:    getProto(aRequest),
+		Status:   aLogger.status,
+		Size:     aLogger.size,
+		Referrer: getReferrer(&aRequest.Header),
+		Agent:    agent,
+		Duration: aLogger.duration,
+		headers:  captureHeaders(aRequest.Header, settings),
+		address:  getRemoteAddr(aRequest),
+		settings: settings,
+	}
+	if rs := requestState(aRequest.Context()); nil != rs {
+		entry.Fields = rs.copyFields()
+		entry.notes = rs.copyNotes()
+		addHeaderSize(entry, rs)
+		addKeepAlive(entry, rs)
+	}
+	anonymiseProxyHeaders(entry)
+	captureCorrelation(entry, aRequest.Header)
+	checkContentLength(entry, aLogger.declared)
+	addInformational(entry, aLogger.informational, aRequest.Header)
+	addCacheStatus(entry, aLogger.cache)
+	addCompressionRatio(entry, aLogger.responseHeader())
+	addHostPort(entry, aRequest)
+	addALPN(entry, aRequest)
+	addPeerCred(entry, aRequest.Context())
+	if (HostnameOff != HostnameLookups) && !aPreview {
+		addHostname(entry, getRemoteAddr(aRequest))
+	}
+	if ParseUserAgents {
+		addUserAgentFields(entry, agent)
+	}
+	suspect := FlagSuspicious && flagSuspicious(entry)
+	templatePath(entry)
+	normaliseHosts(entry)
+	if aPreview {
+		applyStaticFields(entry)
+		finishEntry(entry)
+	} else {
+		prepareEntry(entry)
+	}
 
-	// build the log string and send it to the channel:
-	aLogChannel <- fmt.Sprintf(alApacheFormatPattern,
-		getRemote(aRequest, aLogger.status),
-		getUsername(aRequest.URL),
-		aLogger.when.Format("02/Jan/2006:15:04:05 -0700"),
-		aRequest.Method,
-		getPath(aRequest.URL),
-		getProto(aRequest),
-		aLogger.status,
-		aLogger.size,
-		getReferrer(&aRequest.Header),
-		agent,
-	)
-
-	aLogger.status, aLogger.size = 0, 0
-} // goWebLog()
+	return entry, suspect
+} // requestEntry()
 
 const (
 	alFileCloserDelay = time.Second << 3 // eight seconds
@@ -507,7 +939,7 @@ const (
 // - `aSender`: The name/designation of the sending entity.
 // - `aMessage`: The text to write to the error logfile.
 func Err(aSender, aMessage string) {
-	go goCustomLog(aSender, aMessage, `ERR`, time.Now(), alErrorQueue)
+	spawnError(aSender, aMessage, `ERR`, callerName(2))
 } // Err()
 
 // 'Log()' writes `aMessage` on behalf of `aSender` to the access logfile.
@@ -516,7 +948,10 @@ func Err(aSender, aMessage string) {
 // - `aSender`: The name/designation of the sending entity.
 // - `aMessage`: The text to write to the access logfile.
 func Log(aSender, aMessage string) {
-	go goCustomLog(aSender, aMessage, `LOG`, time.Now(), alAccessQueue)
+	if accessPaused() {
+		return
+	}
+	spawnCustom(func(aNow time.Time) { goCustomLog(aSender, aMessage, `LOG`, aNow, accessQueue()) })
 } // Log()
 
 // `Wrap()` returns a handler function that includes logging, wrapping
@@ -535,63 +970,101 @@ func Log(aSender, aMessage string) {
 //
 // Returns:
 // - `http.Handler`:The (augmented) `aHandler`.
+//
+// Deprecated: Use `WrapWith()` with `WithAccessLog()` and
+// `WithErrorLog()` instead, which returns an error rather than
+// terminating the program and takes further options.
 func Wrap(aHandler http.Handler, aAccessLog, aErrorLog string) http.Handler {
-	alWrapOnce.Do(func() {
-		if usr, err := user.Current(); (nil == err) && (0 < len(usr.Username)) {
-			alCurrentUser = usr.Username
-		}
-		if 0 < len(aAccessLog) {
-			absFile, _ := filepath.Abs(aAccessLog)
-			aAccessLog = absFile
-		}
-		if 0 < len(aAccessLog) {
-			accessFile, err := os.OpenFile(aAccessLog, alOpenFlags, 0640) // #nosec G302
-			_ = accessFile.Close()
-			if nil != err {
-				log.Fatalf("%s can't open access logfile: %v", os.Args[0], err)
-			}
-			go goDoLogWrite(aAccessLog, alAccessQueue)
-		} else {
-			go goIgnoreLog(alAccessQueue)
-		}
+	accessSink, errorSink, err := openSinks(aAccessLog, aErrorLog)
+	if nil != err {
+		log.Fatalf("%s: %v", os.Args[0], err)
+	}
 
-		if 0 < len(aErrorLog) {
-			absFile, _ := filepath.Abs(aErrorLog)
-			aErrorLog = absFile
-		}
-		if 0 < len(aErrorLog) {
-			if aErrorLog == aAccessLog {
-				close(alErrorQueue)
-				alErrorQueue = alAccessQueue
-			} else {
-				errorFile, err := os.OpenFile(aErrorLog, alOpenFlags, 0640) // #nosec G302
-				_ = errorFile.Close()
-				if nil != err {
-					log.Fatalf("%s can't open error logfile: %v", os.Args[0], err)
-				}
-				go goDoLogWrite(aErrorLog, alErrorQueue)
-			}
-		} else {
-			go goIgnoreLog(alErrorQueue)
-		}
-	})
+	return WrapSinks(aHandler, accessSink, errorSink)
+} // Wrap()
+
+// `WrapSinks()` returns a handler function that includes logging,
+// wrapping the given `aHandler`, and calling it internally.
+//
+// It works like `Wrap()` but writes the log messages to the given sinks
+// instead of named files. A `nil` sink (or `Discard`) discards the
+// respective messages.
+// If both arguments refer to the same sink, access and error messages
+// are written to it in order.
+//
+// Every wrapper writes to its own sinks, so e.g. a public and an admin
+// server in the same program can use different logfiles; wrappers
+// given the same sink (or a file sink with the same filename) share
+// its writer. Messages not belonging to a request of a wrapper (e.g.
+// of `Log()` and `Err()`) as well as `Stats()` and `SetAccessTarget()`
+// refer to the sinks of the first wrapper; to log a server's errors to
+// its wrapper's error log see `SetErrorLog()` and `NewRejectListenerFor()`.
+//
+// Parameters:
+// - `aHandler`: Responds to the actual HTTP request.
+// - `aAccessSink`: The sink to use for access log messages.
+// - `aErrorSink`: The sink to use for error log messages.
+//
+// Returns:
+// - `http.Handler`:The (augmented) `aHandler`.
+func WrapSinks(aHandler http.Handler, aAccessSink, aErrorSink TSink) http.Handler {
+	accessRing, errorRing := startWriters(aAccessSink, aErrorSink)
+
+	return wrapQueues(aHandler, accessRing, errorRing)
+} // WrapSinks()
 
-	return http.HandlerFunc(
+// `wrapQueues()` returns a handler function that includes logging to
+// the given queues, wrapping `aHandler`.
+//
+// Parameters:
+// - `aHandler`: Responds to the actual HTTP request.
+// - `aAccessQueue`: The queue of the access log messages.
+// - `aErrorQueue`: The queue of the error log messages.
+//
+// Returns:
+// - `http.Handler`:The (augmented) `aHandler`.
+func wrapQueues(aHandler http.Handler, aAccessQueue, aErrorQueue *tRing) http.Handler {
+	handler := http.HandlerFunc(
 		func(aWriter http.ResponseWriter, aRequest *http.Request) {
 			defer func() {
 				// make sure a `panic` won't kill the program
 				if err := recover(); nil != err {
-					Err("ApacheLogger/catchPanic",
-						fmt.Sprintf("caught panic: %v - %s",
-							err, debug.Stack()))
+					stack, frames := debug.Stack(), panicFrames()
+					name := writeCrashReport(err, stack, aRequest)
+					spawnCustom(func(aNow time.Time) {
+						queueCustomEntry(panicEntry(err, stack, frames, name, aNow), aErrorQueue)
+					})
+					reportPanic(err, aRequest)
 				}
 			}()
-			lw := &tLogWriter{aWriter, 0, 0, time.Now()}
+			lw := &tLogWriter{ResponseWriter: aWriter, when: time.Now(), queue: aAccessQueue}
+			if nil != aRequest.TLS {
+				// the handshake succeeded, so we don't need the data
+				_, _ = forgetTLSHello(aRequest.RemoteAddr)
+			}
+			aRequest, rs := withRequestState(aRequest)
+			captureHeaderSize(rs, aRequest)
+			captureKeepAlive(rs, aRequest.Context())
+			sample := sampleCapture(aRequest)
+			lw.request = aRequest
+			lw.progress = newProgress(aRequest, lw.when, lw.queue)
 			aHandler.ServeHTTP(lw, aRequest)
+			lw.duration = elapsed(lw.when, time.Now())
+			if (nil != lw.progress) && lw.progress.finish() {
+				SetField(aRequest.Context(), "progress", "done")
+			}
+			if (nil != sample) && !aggregating() {
+				logCapture(lw, aRequest, sample)
+			}
+			if rs.isSuppressed() || lw.hijacked {
+				return // hijacked connections are logged by `Hijack()`
+			}
 
 			// run the log-entry formatter:
-			go goWebLog(lw, aRequest, alAccessQueue)
+			webLog(lw, aRequest, lw.queue)
 		})
-} // Wrap()
+
+	return &tWrapper{Handler: handler, errorLog: aErrorQueue}
+} // wrapQueues()
 
 /* _EoF_ */
@@ -7,10 +7,14 @@ Copyright © 2019, 2024  M.Watermann, 10247 Berlin, Germany
 package apachelogger
 
 import (
+	"context"
 	"fmt"
+	"io"
 	"net/http"
 	"net/http/httptest"
 	"net/url"
+	"os"
+	"path/filepath"
 	"runtime"
 	"strings"
 	"testing"
@@ -19,6 +23,87 @@ import (
 
 //lint:file-ignore ST1017 – I prefer Yoda conditions
 
+func Test_checkContentLength(t *testing.T) {
+	tests := []struct {
+		name     string
+		method   string
+		status   int
+		size     int
+		declared string
+		length   string
+		mismatch string
+	}{
+		{" 1", "GET", 200, 10, "", "", ""},
+		{" 2", "GET", 200, 10, "10", "10", ""},
+		{" 3", "GET", 200, 4, "10", "10", "short"},
+		{" 4", "GET", 200, 12, "10", "10", "long"},
+		{" 5", "HEAD", 200, 0, "10", "10", ""},
+		{" 6", "GET", 304, 0, "10", "10", ""},
+		{" 7", "GET", 200, 0, "-1", "-1", "invalid"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			e := &TEntry{Method: tt.method, Status: tt.status, Size: tt.size}
+			checkContentLength(e, tt.declared)
+			if tt.length != e.Fields["content_length"] {
+				t.Errorf("content_length = %q, want %q", e.Fields["content_length"], tt.length)
+			}
+			if tt.mismatch != e.Fields["length_mismatch"] {
+				t.Errorf("length_mismatch = %q, want %q", e.Fields["length_mismatch"], tt.mismatch)
+			}
+		})
+	}
+} // Test_checkContentLength()
+
+func Test_tLogWriter_Write(t *testing.T) {
+	rec := httptest.NewRecorder()
+	lw := &tLogWriter{ResponseWriter: rec}
+	rec.Header().Set("Content-Length", "11")
+	_, _ = lw.Write([]byte("Hello"))
+	if ("11" != lw.declared) || (5 != lw.size) || (200 != lw.status) {
+		t.Errorf("Write() declared = %q, size = %d, status = %d", lw.declared, lw.size, lw.status)
+	}
+} // Test_tLogWriter_Write()
+
+type (
+	// `tReaderFromRecorder` records whether `ReadFrom()` was used.
+	tReaderFromRecorder struct {
+		*httptest.ResponseRecorder
+		used bool
+	}
+)
+
+func (rr *tReaderFromRecorder) ReadFrom(aReader io.Reader) (int64, error) {
+	rr.used = true
+	return io.Copy(rr.ResponseRecorder, aReader)
+} // ReadFrom()
+
+func Test_tLogWriter_ReadFrom(t *testing.T) {
+	content := strings.Repeat("static file content\n", 100)
+	rec := &tReaderFromRecorder{ResponseRecorder: httptest.NewRecorder()}
+	lw := &tLogWriter{ResponseWriter: rec}
+	req := httptest.NewRequest(http.MethodGet, "/file.txt", nil)
+
+	http.ServeContent(lw, req, "file.txt", time.Now(), strings.NewReader(content))
+	if !rec.used {
+		t.Error("ServeContent() didn't use the ReadFrom() fast path")
+	}
+	if (len(content) != lw.size) || (200 != lw.status) {
+		t.Errorf("ReadFrom() size = %d, status = %d, want %d, 200", lw.size, lw.status, len(content))
+	}
+	if content != rec.Body.String() {
+		t.Errorf("ReadFrom() wrote %d bytes, want %d", rec.Body.Len(), len(content))
+	}
+
+	// without the fast path of the underlying writer:
+	plain := httptest.NewRecorder()
+	lw = &tLogWriter{ResponseWriter: plain}
+	if n, err := lw.ReadFrom(strings.NewReader(content)); (nil != err) ||
+		(int64(len(content)) != n) || (len(content) != lw.size) {
+		t.Errorf("ReadFrom() = %d, %v, size = %d", n, err, lw.size)
+	}
+} // Test_tLogWriter_ReadFrom()
+
 func Test_compareDayStamps(t *testing.T) {
 	ll1 := time.Now()
 	ll2 := ll1.Add(-1 * (24 * time.Hour))
@@ -35,9 +120,9 @@ func Test_compareDayStamps(t *testing.T) {
 		// TODO: Add test cases.
 	}
 	for _, tt := range tests {
-		alLastLoggingDate = tt.prevTime
+		lastDate := tt.prevTime
 		t.Run(tt.name, func(t *testing.T) {
-			if got := compareDayStamps(); got != tt.want {
+			if got := compareDayStamps(&lastDate, nil); got != tt.want {
 				t.Errorf("%q: Test_compareDayStamps() = %v, want %v",
 					tt.name, got, tt.want)
 			}
@@ -45,6 +130,20 @@ func Test_compareDayStamps(t *testing.T) {
 	}
 } // Test_compareDayStamps
 
+func Test_elapsed(t *testing.T) {
+	start := time.Now()
+	if got := elapsed(start, start.Add(1500*time.Millisecond)); 1500*time.Millisecond != got {
+		t.Errorf("elapsed() = %v, want 1.5s", got)
+	}
+	// a wall clock stepped back without a monotonic reading:
+	if got := elapsed(start, start.Round(0).Add(-time.Hour)); 0 != got {
+		t.Errorf("elapsed() = %v, want 0", got)
+	}
+	if got := elapsed(start, time.Now()); 0 > got {
+		t.Errorf("elapsed() = %v, want >= 0", got)
+	}
+} // Test_elapsed()
+
 func Test_getPath(t *testing.T) {
 	var u1, u2, u3, u4, u5 url.URL
 	f := "id"
@@ -164,7 +263,7 @@ func Test_getRemote(t *testing.T) {
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			if got := getRemote(tt.args.aRequest, tt.args.aStatus); got != tt.want {
+			if got := getRemote(tt.args.aRequest, tt.args.aStatus, nil); got != tt.want {
 				t.Errorf("getRemote() = %v,\nwant %v", got, tt.want)
 			}
 		})
@@ -196,9 +295,63 @@ func Test_getUsername(t *testing.T) {
 	}
 } // Test_getUsername()
 
+func TestWrap_independent(t *testing.T) {
+	dir := t.TempDir()
+	handler := http.HandlerFunc(func(http.ResponseWriter, *http.Request) {})
+	names := []string{"public", "admin", "public"}
+	for _, name := range names {
+		wrapped, err := WrapWith(handler,
+			WithAccessLog(filepath.Join(dir, name+".log")),
+			WithErrorLog(filepath.Join(dir, name+".err")))
+		if nil != err {
+			t.Fatalf("WrapWith(%q) error = %v", name, err)
+		}
+		wrapped.ServeHTTP(httptest.NewRecorder(),
+			httptest.NewRequest(http.MethodGet, "/"+name, nil))
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := Flush(ctx); nil != err {
+		t.Fatalf("Flush() error = %v", err)
+	}
+
+	for name, want := range map[string]int{"public": 2, "admin": 1} {
+		data, err := os.ReadFile(filepath.Join(dir, name+".log")) // #nosec G304
+		if nil != err {
+			t.Fatalf("os.ReadFile(%q) error = %v", name, err)
+		}
+		if got := strings.Count(string(data), " /"+name+" "); want != got {
+			t.Errorf("%s.log holds %d entries, want %d:\n%s", name, got, want, data)
+		}
+		if strings.Contains(string(data), "/admin") != ("admin" == name) {
+			t.Errorf("%s.log holds entries of another wrapper:\n%s", name, data)
+		}
+	}
+
+	health := Health()
+	for _, name := range []string{"admin.log", "admin.err"} {
+		if got, ok := health.Logs[filepath.Join(dir, name)]; !ok || !got.Running {
+			t.Errorf("Health().Logs[%q] = %+v, %v, want a running writer", name, got, ok)
+		}
+	}
+} // TestWrap_independent()
+
+func TestSetErrorLog(t *testing.T) {
+	errLog := newRing(8)
+	server := &http.Server{}
+	SetErrorLog(server) // before the handler is set
+	server.Handler = wrapQueues(http.NotFoundHandler(), newRing(8), errLog)
+
+	server.ErrorLog.Print("http: TLS handshake error from 192.0.2.1:1234: EOF")
+	got, ok := popWithin(errLog, 5*time.Second)
+	if !ok || !strings.Contains(got, "TLS handshake error") {
+		t.Errorf("SetErrorLog() wrote %q to the wrapper's error log, want the message", got)
+	}
+} // TestSetErrorLog()
+
 func Benchmark_goWrite(b *testing.B) {
 	runtime.GOMAXPROCS(1)
-	go goDoLogWrite("/dev/stdout", alAccessQueue)
+	go goDoLogWrite(NewFileSink("/dev/stdout"), accessQueue())
 	b.ResetTimer()
 
 	for n := 0; n < b.N; n++ {
@@ -210,12 +363,12 @@ func Benchmark_goWrite(b *testing.B) {
 
 func Benchmark_goCustomLog(b *testing.B) {
 	runtime.GOMAXPROCS(1)
-	go goDoLogWrite("/dev/stderr", alErrorQueue)
+	go goDoLogWrite(NewFileSink("/dev/stderr"), errorQueue())
 	b.ResetTimer()
 
 	for n := 0; n < b.N; n++ {
 		for i := 1; i < 9; i++ {
-			go goCustomLog("Benchmark_goCustomLog", fmt.Sprintf("%02d%02d", n, i), `TEST`, time.Now(), alErrorQueue)
+			go goCustomLog("Benchmark_goCustomLog", fmt.Sprintf("%02d%02d", n, i), `TEST`, time.Now(), errorQueue())
 		}
 	}
 } // Benchmark_goCustomLog()
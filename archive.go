@@ -0,0 +1,374 @@
+/*
+Copyright © 2024  M.Watermann, 10247 Berlin, Germany
+
+	    All rights reserved
+	EMail : <support@mwat.de>
+*/
+package apachelogger
+
+import (
+	"crypto/hmac"
+	"crypto/md5" // #nosec G501 – required by the S3 API
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+//lint:file-ignore ST1017 – I prefer Yoda conditions
+
+type (
+	// `TArchiveOptions` configures the archiving of rotated logfiles
+	// to an S3 compatible object storage (AWS S3, MinIO, Ceph, GCS
+	// with HMAC keys, …).
+	TArchiveOptions struct {
+		// The storage's endpoint, e.g. `https://s3.eu-central-1.amazonaws.com`
+		// or `http://minio:9000`.
+		Endpoint string
+
+		// The bucket to store the logfiles in.
+		Bucket string
+
+		// The prefix of the objects' keys (optional), e.g. `logs/web1/`;
+		// the key ends with the rotated logfile's name.
+		Prefix string
+
+		// The region used to sign the requests (default: `us-east-1`).
+		Region string
+
+		// The credentials used to sign the requests; if empty the
+		// requests aren't signed.
+		AccessKey string
+		SecretKey string
+
+		// Max. number of retries of a failed upload (default: 5; a
+		// negative value disables retries).
+		Retries int
+
+		// Time to wait before the first retry, doubled with every
+		// further retry (default: 30 seconds).
+		RetryDelay time.Duration
+
+		// Keep the logfile after it's uploaded and verified (default:
+		// `false`, i.e. it's removed).
+		Keep bool
+
+		// The HTTP client to use (default: a client with a 5 minute
+		// timeout).
+		Client *http.Client
+	}
+
+	// `tArchiver` uploads rotated logfiles to an object storage.
+	tArchiver struct {
+		opts TArchiveOptions // the archiver's configuration
+	}
+
+	// `tFileDigest` holds the checksums of a logfile to upload.
+	tFileDigest struct {
+		md5    []byte // the file's MD5 sum (`Content-MD5`)
+		sha256 string // the file's hex encoded SHA-256 sum
+		size   int64  // the file's size
+	}
+)
+
+var (
+	// The failed archivings (see `THealth`).
+	alArchiveState struct {
+		sync.Mutex
+		errors  uint64 // number of logfiles not archived
+		errText string // message of the last failure
+	}
+)
+
+// `archiveFailed()` records a logfile that couldn't be archived.
+//
+// Parameters:
+// - `aErr`: The archiving's error.
+func archiveFailed(aErr error) {
+	alArchiveState.Lock()
+	alArchiveState.errors++
+	alArchiveState.errText = aErr.Error()
+	alArchiveState.Unlock()
+} // archiveFailed()
+
+// `archiveHealth()` returns the number of logfiles not archived and
+// the message of the last failure.
+//
+// Returns:
+// - `uint64`: The number of failed archivings.
+// - `string`: The message of the last failure.
+func archiveHealth() (uint64, string) {
+	alArchiveState.Lock()
+	defer alArchiveState.Unlock()
+
+	return alArchiveState.errors, alArchiveState.errText
+} // archiveHealth()
+
+// `digestFile()` returns the checksums and size of `aFilename`.
+//
+// Parameters:
+// - `aFilename`: The file to read.
+//
+// Returns:
+// - `*tFileDigest`: The file's checksums.
+// - `error`: A possible error reading the file.
+func digestFile(aFilename string) (*tFileDigest, error) {
+	file, err := os.Open(aFilename) // #nosec G304
+	if nil != err {
+		return nil, err
+	}
+	defer file.Close()
+
+	var (
+		md5Sum = md5.New() // #nosec G401
+		shaSum = sha256.New()
+	)
+	size, err := io.Copy(io.MultiWriter(md5Sum, shaSum), file)
+	if nil != err {
+		return nil, err
+	}
+
+	return &tFileDigest{
+		md5:    md5Sum.Sum(nil),
+		sha256: hex.EncodeToString(shaSum.Sum(nil)),
+		size:   size,
+	}, nil
+} // digestFile()
+
+// `hmacSHA256()` returns the HMAC-SHA256 of `aData` using `aKey`.
+func hmacSHA256(aKey []byte, aData string) []byte {
+	mac := hmac.New(sha256.New, aKey)
+	_, _ = mac.Write([]byte(aData))
+
+	return mac.Sum(nil)
+} // hmacSHA256()
+
+// `s3Escape()` returns `aPath` URI encoded as required by AWS'
+// signature version 4, keeping the slashes.
+//
+// Parameters:
+// - `aPath`: The path to encode.
+//
+// Returns:
+// - `string`: The encoded path.
+func s3Escape(aPath string) string {
+	var sb strings.Builder
+	for _, b := range []byte(aPath) {
+		switch {
+		case (('A' <= b) && ('Z' >= b)) || (('a' <= b) && ('z' >= b)) ||
+			(('0' <= b) && ('9' >= b)) || (0 <= strings.IndexByte("-._~/", b)):
+			sb.WriteByte(b)
+		default:
+			fmt.Fprintf(&sb, "%%%02X", b)
+		}
+	}
+
+	return sb.String()
+} // s3Escape()
+
+// `archive()` uploads `aFilename`, verifies the stored object, and
+// removes the logfile afterwards, retrying failed attempts.
+//
+// Parameters:
+// - `aFilename`: The rotated logfile.
+//
+// Returns:
+// - `error`: The error of the last attempt.
+func (a *tArchiver) archive(aFilename string) error {
+	digest, err := digestFile(aFilename)
+	if nil != err {
+		return err
+	}
+	delay := a.opts.RetryDelay
+	for attempt := 0; ; attempt++ {
+		if err = a.upload(aFilename, digest); nil == err {
+			break
+		}
+		if a.opts.Retries <= attempt {
+			return fmt.Errorf("apachelogger: can't archive %s: %w", aFilename, err)
+		}
+		time.Sleep(delay)
+		delay *= 2
+	}
+	if a.opts.Keep {
+		return nil
+	}
+
+	return os.Remove(aFilename)
+} // archive()
+
+// `request()` returns a (signed) request for the object `aKey`.
+//
+// Parameters:
+// - `aMethod`: The HTTP method.
+// - `aKey`: The object's key.
+// - `aBody`: The request's body (may be `nil`).
+// - `aDigest`: The checksums of the body (may be `nil`).
+//
+// Returns:
+// - `*http.Request`: The request to send.
+// - `error`: A possible error creating the request.
+func (a *tArchiver) request(aMethod, aKey string, aBody io.Reader, aDigest *tFileDigest) (*http.Request, error) {
+	path := "/" + s3Escape(a.opts.Bucket+"/"+aKey)
+	req, err := http.NewRequest(aMethod, a.opts.Endpoint+path, aBody)
+	if nil != err {
+		return nil, err
+	}
+	payload := "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855" // SHA-256 of nothing
+	if nil != aDigest {
+		req.ContentLength = aDigest.size
+		req.Header.Set("Content-MD5", base64.StdEncoding.EncodeToString(aDigest.md5))
+		payload = aDigest.sha256
+	}
+	if "" == a.opts.AccessKey {
+		return req, nil
+	}
+
+	var (
+		now     = time.Now().UTC()
+		amzDate = now.Format("20060102T150405Z")
+		scope   = now.Format("20060102") + "/" + a.opts.Region + "/s3/aws4_request"
+		names   = []string{"host", "x-amz-content-sha256", "x-amz-date"}
+		values  = []string{req.URL.Host, payload, amzDate}
+	)
+	req.Header.Set("X-Amz-Content-Sha256", payload)
+	req.Header.Set("X-Amz-Date", amzDate)
+	if nil != aDigest {
+		names = append([]string{"content-md5"}, names...)
+		values = append([]string{req.Header.Get("Content-MD5")}, values...)
+	}
+	var canonical strings.Builder
+	canonical.WriteString(aMethod + "\n" + req.URL.EscapedPath() + "\n\n")
+	for idx, name := range names {
+		canonical.WriteString(name + ":" + values[idx] + "\n")
+	}
+	signed := strings.Join(names, ";")
+	canonical.WriteString("\n" + signed + "\n" + payload)
+
+	hash := sha256.Sum256([]byte(canonical.String()))
+	toSign := "AWS4-HMAC-SHA256\n" + amzDate + "\n" + scope + "\n" + hex.EncodeToString(hash[:])
+	key := hmacSHA256([]byte("AWS4"+a.opts.SecretKey), now.Format("20060102"))
+	key = hmacSHA256(key, a.opts.Region)
+	key = hmacSHA256(key, "s3")
+	key = hmacSHA256(key, "aws4_request")
+	req.Header.Set("Authorization", "AWS4-HMAC-SHA256 Credential="+
+		a.opts.AccessKey+"/"+scope+", SignedHeaders="+signed+
+		", Signature="+hex.EncodeToString(hmacSHA256(key, toSign)))
+
+	return req, nil
+} // request()
+
+// `upload()` stores `aFilename` in the bucket and verifies the object
+// by its size and entity tag.
+//
+// Parameters:
+// - `aFilename`: The rotated logfile.
+// - `aDigest`: The logfile's checksums.
+//
+// Returns:
+// - `error`: A possible error storing or verifying the object.
+func (a *tArchiver) upload(aFilename string, aDigest *tFileDigest) error {
+	file, err := os.Open(aFilename) // #nosec G304
+	if nil != err {
+		return err
+	}
+	defer file.Close()
+
+	key := a.opts.Prefix + filepath.Base(aFilename)
+	req, err := a.request(http.MethodPut, key, file, aDigest)
+	if nil != err {
+		return err
+	}
+	resp, err := a.opts.Client.Do(req)
+	if nil != err {
+		return err
+	}
+	_, _ = io.Copy(io.Discard, resp.Body)
+	resp.Body.Close()
+	if http.StatusOK != resp.StatusCode {
+		return fmt.Errorf("apachelogger: PUT %s: %s", key, resp.Status)
+	}
+	etag := resp.Header.Get("ETag")
+
+	if req, err = a.request(http.MethodHead, key, nil, nil); nil != err {
+		return err
+	}
+	if resp, err = a.opts.Client.Do(req); nil != err {
+		return err
+	}
+	resp.Body.Close()
+	if http.StatusOK != resp.StatusCode {
+		return fmt.Errorf("apachelogger: HEAD %s: %s", key, resp.Status)
+	}
+	if size := resp.Header.Get("Content-Length"); strconv.FormatInt(aDigest.size, 10) != size {
+		return fmt.Errorf("apachelogger: %s: stored size %s, want %d", key, size, aDigest.size)
+	}
+	if stored := resp.Header.Get("ETag"); ("" != etag) && (etag != stored) {
+		return fmt.Errorf("apachelogger: %s: stored ETag %s, want %s", key, stored, etag)
+	}
+
+	return nil
+} // upload()
+
+/* * * * * * * * * * * * * * * * * * * * * * * * * * * * * * * * * * * * * */
+
+// `NewArchiver()` returns a rotation hook (see `SetRotationHook()`)
+// uploading each rotated logfile to an S3 compatible object storage,
+// e.g.
+//
+//	apachelogger.SetRotationHook(apachelogger.NewArchiver(
+//		apachelogger.TArchiveOptions{
+//			Endpoint:  "https://s3.eu-central-1.amazonaws.com",
+//			Bucket:    "my-logs",
+//			Prefix:    "web1/",
+//			Region:    "eu-central-1",
+//			AccessKey: os.Getenv("AWS_ACCESS_KEY_ID"),
+//			SecretKey: os.Getenv("AWS_SECRET_ACCESS_KEY"),
+//		}))
+//
+// The logfile is sent with its MD5 sum (so the storage rejects a
+// damaged upload), the stored object is checked afterwards, and only
+// then the logfile is removed. A failed upload is retried `Retries`
+// times; if it still fails the logfile is kept, the failure is written
+// to the error log, and it's counted in `Health()`.
+//
+// Parameters:
+// - `aOptions`: The archiver's configuration.
+//
+// Returns:
+// - `func(string)`: The hook to pass to `SetRotationHook()`.
+func NewArchiver(aOptions TArchiveOptions) func(aRotatedFile string) {
+	aOptions.Endpoint = strings.TrimSuffix(aOptions.Endpoint, "/")
+	if "" == aOptions.Region {
+		aOptions.Region = "us-east-1"
+	}
+	if 0 > aOptions.Retries {
+		aOptions.Retries = 0
+	} else if 0 == aOptions.Retries {
+		aOptions.Retries = 5
+	}
+	if 0 >= aOptions.RetryDelay {
+		aOptions.RetryDelay = 30 * time.Second
+	}
+	if nil == aOptions.Client {
+		aOptions.Client = &http.Client{Timeout: 5 * time.Minute}
+	}
+	a := &tArchiver{opts: aOptions}
+
+	return func(aRotatedFile string) {
+		if err := a.archive(aRotatedFile); nil != err {
+			archiveFailed(err)
+			Err("apachelogger", err.Error())
+		}
+	}
+} // NewArchiver()
+
+/* _EoF_ */
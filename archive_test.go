@@ -0,0 +1,150 @@
+/*
+Copyright © 2024  M.Watermann, 10247 Berlin, Germany
+
+	    All rights reserved
+	EMail : <support@mwat.de>
+*/
+package apachelogger
+
+import (
+	"crypto/md5" // #nosec G501
+	"encoding/base64"
+	"encoding/hex"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+//lint:file-ignore ST1017 – I prefer Yoda conditions
+
+// `newS3Server()` returns a test server storing objects like S3,
+// failing the first `aFailures` uploads.
+func newS3Server(t *testing.T, aFailures int) (*httptest.Server, map[string][]byte) {
+	var (
+		mtx     sync.Mutex
+		objects = make(map[string][]byte)
+	)
+	server := httptest.NewServer(http.HandlerFunc(
+		func(aWriter http.ResponseWriter, aRequest *http.Request) {
+			mtx.Lock()
+			defer mtx.Unlock()
+			if !strings.HasPrefix(aRequest.Header.Get("Authorization"),
+				"AWS4-HMAC-SHA256 Credential=AKID/") {
+				aWriter.WriteHeader(http.StatusForbidden)
+				return
+			}
+			switch aRequest.Method {
+			case http.MethodPut:
+				if 0 < aFailures {
+					aFailures--
+					aWriter.WriteHeader(http.StatusServiceUnavailable)
+					return
+				}
+				body, _ := io.ReadAll(aRequest.Body)
+				sum := md5.Sum(body) // #nosec G401
+				if base64.StdEncoding.EncodeToString(sum[:]) != aRequest.Header.Get("Content-MD5") {
+					aWriter.WriteHeader(http.StatusBadRequest)
+					return
+				}
+				objects[aRequest.URL.Path] = body
+				aWriter.Header().Set("ETag", `"`+hex.EncodeToString(sum[:])+`"`)
+
+			case http.MethodHead:
+				body, ok := objects[aRequest.URL.Path]
+				if !ok {
+					aWriter.WriteHeader(http.StatusNotFound)
+					return
+				}
+				sum := md5.Sum(body) // #nosec G401
+				aWriter.Header().Set("Content-Length", strconv.Itoa(len(body)))
+				aWriter.Header().Set("ETag", `"`+hex.EncodeToString(sum[:])+`"`)
+			}
+		}))
+	t.Cleanup(server.Close)
+
+	return server, objects
+} // newS3Server()
+
+func TestNewArchiver(t *testing.T) {
+	server, objects := newS3Server(t, 2)
+	var (
+		fName = filepath.Join(t.TempDir(), "access.log.2024-01-02")
+		hook  = NewArchiver(TArchiveOptions{
+			Endpoint:   server.URL,
+			Bucket:     "logs",
+			Prefix:     "web 1/",
+			AccessKey:  "AKID",
+			SecretKey:  "secret",
+			RetryDelay: time.Millisecond,
+		})
+	)
+	if err := os.WriteFile(fName, []byte("line 1\n"), 0600); nil != err {
+		t.Fatal(err)
+	}
+	before, _ := archiveHealth()
+
+	hook(fName)
+	if got := string(objects["/logs/web 1/access.log.2024-01-02"]); "line 1\n" != got {
+		t.Errorf("stored object = %q, want %q (objects: %v)", got, "line 1\n", objects)
+	}
+	if _, err := os.Stat(fName); !os.IsNotExist(err) {
+		t.Errorf("archived logfile wasn't removed: %v", err)
+	}
+	if after, text := archiveHealth(); before != after {
+		t.Errorf("archiveHealth() = %d, %q after a successful upload", after, text)
+	}
+} // TestNewArchiver()
+
+func TestNewArchiver_failing(t *testing.T) {
+	server, _ := newS3Server(t, 1000)
+	var (
+		fName = filepath.Join(t.TempDir(), "access.log.2024-01-02")
+		hook  = NewArchiver(TArchiveOptions{
+			Endpoint:   server.URL,
+			Bucket:     "logs",
+			AccessKey:  "AKID",
+			SecretKey:  "secret",
+			Retries:    2,
+			RetryDelay: time.Millisecond,
+		})
+	)
+	if err := os.WriteFile(fName, []byte("line 1\n"), 0600); nil != err {
+		t.Fatal(err)
+	}
+	before, _ := archiveHealth()
+	errLog := newRing(8)
+	_, previous := setLogQueues(nil, errLog)
+	defer setLogQueues(nil, previous)
+
+	hook(fName)
+	lines := make([]string, 0, 8)
+	for deadline := time.Now().Add(time.Second); (0 == len(lines)) && time.Now().Before(deadline); {
+		time.Sleep(time.Millisecond)
+		lines = errLog.popBatch(lines[:0])
+	}
+	if (1 != len(lines)) || !strings.Contains(lines[0], "can't archive") {
+		t.Errorf("error log = %q, want the failure", lines)
+	}
+	if _, err := os.Stat(fName); nil != err {
+		t.Errorf("logfile removed although not archived: %v", err)
+	}
+	health := Health()
+	if (before+1 != health.ArchiveErrors) || !strings.Contains(health.ArchiveError, "503") {
+		t.Errorf("Health() = %d, %q, want one more failure", health.ArchiveErrors, health.ArchiveError)
+	}
+} // TestNewArchiver_failing()
+
+func Test_s3Escape(t *testing.T) {
+	if got, want := s3Escape("logs/web 1/a+b:c~.log"), "logs/web%201/a%2Bb%3Ac~.log"; want != got {
+		t.Errorf("s3Escape() = %q, want %q", got, want)
+	}
+} // Test_s3Escape()
+
+/* _EoF_ */
@@ -0,0 +1,279 @@
+/*
+Copyright © 2024  M.Watermann, 10247 Berlin, Germany
+
+	    All rights reserved
+	EMail : <support@mwat.de>
+*/
+package apachelogger
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+//lint:file-ignore ST1017 – I prefer Yoda conditions
+
+var (
+	// The audit log's state.
+	alAudit struct {
+		sync.Mutex
+		chain bool     // whether to hash-chain the entries
+		file  *os.File // the opened audit logfile
+		last  string   // the chain value of the last entry
+		name  string   // the audit logfile's name
+	}
+
+	// Error returned by `Audit()` if no audit log was set up.
+	errNoAuditLog = errors.New("apachelogger: no audit log set up")
+)
+
+const (
+	// Separator of an audit entry's chain value.
+	alAuditChainKey = " chain="
+)
+
+// `auditChain()` returns the chain value of an audit entry.
+//
+// Parameters:
+// - `aPrevious`: The chain value of the previous entry.
+// - `aLine`: The entry's line (without chain value and newline).
+//
+// Returns:
+// - `string`: The hex encoded chain value.
+func auditChain(aPrevious, aLine string) string {
+	sum := sha256.Sum256([]byte(aPrevious + aLine))
+
+	return hex.EncodeToString(sum[:])
+} // auditChain()
+
+// `auditKey()` returns `aKey` usable as the key of an audit field.
+//
+// Characters other than letters, digits, `-`, `.`, and `_` are replaced
+// by `_`, and the names of the standard keys are prefixed by `x_`.
+//
+// Parameters:
+// - `aKey`: The field's name.
+//
+// Returns:
+// - `string`: The sanitised name.
+func auditKey(aKey string) string {
+	key := strings.Map(func(aRune rune) rune {
+		if ('a' <= aRune && 'z' >= aRune) || ('A' <= aRune && 'Z' >= aRune) ||
+			('0' <= aRune && '9' >= aRune) || ('-' == aRune) ||
+			('.' == aRune) || ('_' == aRune) {
+			return aRune
+		}
+		return '_'
+	}, aKey)
+	switch key {
+	case "", "time", "sender", "event", "chain":
+		key = "x_" + key
+	}
+
+	return key
+} // auditKey()
+
+// `lastAuditChain()` returns the chain value of the last entry of the
+// audit logfile `aFilename`.
+//
+// Parameters:
+// - `aFilename`: The name of the audit logfile.
+//
+// Returns:
+// - `string`: The last chain value (empty if none).
+// - `error`: A possible error reading the file.
+func lastAuditChain(aFilename string) (string, error) {
+	file, err := os.Open(aFilename) // #nosec G304
+	if nil != err {
+		if os.IsNotExist(err) {
+			return "", nil
+		}
+		return "", err
+	}
+	defer file.Close()
+
+	last := ""
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	for scanner.Scan() {
+		if idx := strings.LastIndex(scanner.Text(), alAuditChainKey); 0 <= idx {
+			last = scanner.Text()[idx+len(alAuditChainKey):]
+		}
+	}
+
+	return last, scanner.Err()
+} // lastAuditChain()
+
+/* * * * * * * * * * * * * * * * * * * * * * * * * * * * * * * * * * * * * */
+
+// `Audit()` writes a security-relevant event (e.g. a login, a password
+// change, or a permission grant) to the audit log set up by
+// `SetAuditLog()`.
+//
+// Unlike `Log()` and `Err()` this function writes synchronously: the
+// entry is written and synced to disk before the function returns, so
+// no audit entry is ever dropped or lost by a crash. The entries are
+// never altered by transformers, redactions, or the anonymisation
+// settings, and `Suppress()` doesn't apply either; take care what you
+// pass in `aFields`.
+//
+// Each entry is a single line of `key=value` pairs:
+//
+//	time=2024-04-25T20:16:45.123456789+02:00 sender=auth event=login user=jane result=ok
+//
+// Parameters:
+// - `aSender`: The name of the sending function or component.
+// - `aEvent`: The event's name.
+// - `aFields`: Additional data of the event (may be `nil`).
+//
+// Returns:
+// - `error`: A possible error writing the entry; if only syncing it
+// failed, the entry was written (and chained) nonetheless.
+func Audit(aSender, aEvent string, aFields map[string]string) error {
+	var sb strings.Builder
+
+	sb.WriteString("time=")
+	sb.WriteString(time.Now().Format(time.RFC3339Nano))
+	appendKeyValue(&sb, "sender", aSender)
+	appendKeyValue(&sb, "event", aEvent)
+	keys := make([]string, 0, len(aFields))
+	for key := range aFields {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		appendKeyValue(&sb, auditKey(key), aFields[key])
+	}
+
+	alAudit.Lock()
+	defer alAudit.Unlock()
+	if nil == alAudit.file {
+		if "" == alAudit.name {
+			return errNoAuditLog
+		}
+		file, err := os.OpenFile(alAudit.name, alOpenFlags, 0600) // #nosec G302
+		if nil != err {
+			return err
+		}
+		alAudit.file = file
+	}
+
+	line := sb.String()
+	chain := ""
+	if alAudit.chain {
+		chain = auditChain(alAudit.last, line)
+		line += alAuditChainKey + chain
+	}
+	if _, err := alAudit.file.WriteString(line + "\n"); nil != err {
+		_ = alAudit.file.Close()
+		alAudit.file = nil // reopen on next call
+		return err
+	}
+	if alAudit.chain {
+		alAudit.last = chain // the entry is in the file now
+	}
+	if err := alAudit.file.Sync(); nil != err {
+		return fmt.Errorf("apachelogger: audit entry written but not synced: %w", err)
+	}
+
+	return nil
+} // Audit()
+
+// `SetAuditLog()` sets up the audit log written by `Audit()`.
+//
+// If `aHashChain` is `true` every entry ends with a `chain` value, the
+// SHA-256 hash of the previous entry's chain value and the entry
+// itself, so removing or modifying entries can be detected by
+// `VerifyAuditLog()`. An existing chain in the file is continued.
+//
+// Parameters:
+// - `aFilename`: The name of the audit logfile (empty to close it).
+// - `aHashChain`: Whether to hash-chain the entries.
+//
+// Returns:
+// - `error`: A possible error opening the file.
+func SetAuditLog(aFilename string, aHashChain bool) error {
+	alAudit.Lock()
+	defer alAudit.Unlock()
+
+	if nil != alAudit.file {
+		_ = alAudit.file.Close()
+		alAudit.file = nil
+	}
+	alAudit.name, alAudit.chain, alAudit.last = "", false, ""
+	if "" == aFilename {
+		return nil
+	}
+	if absFile, err := filepath.Abs(aFilename); nil == err {
+		aFilename = absFile
+	}
+
+	last := ""
+	if aHashChain {
+		var err error
+		if last, err = lastAuditChain(aFilename); nil != err {
+			return err
+		}
+	}
+	file, err := os.OpenFile(aFilename, alOpenFlags, 0600) // #nosec G302
+	if nil != err {
+		return err
+	}
+	alAudit.file, alAudit.name = file, aFilename
+	alAudit.chain, alAudit.last = aHashChain, last
+
+	return nil
+} // SetAuditLog()
+
+// `VerifyAuditLog()` checks the hash chain of an audit log.
+//
+// Lines without chain value are accepted only before the first chained
+// entry.
+//
+// Parameters:
+// - `aReader`: The audit log to check.
+//
+// Returns:
+// - `int`: The number of the first invalid line (`0` if none).
+// - `error`: A description of the problem found, or a reading error.
+func VerifyAuditLog(aReader io.Reader) (int, error) {
+	var (
+		lineNo int
+		last   string
+	)
+	scanner := bufio.NewScanner(aReader)
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	for scanner.Scan() {
+		lineNo++
+		line := scanner.Text()
+		if "" == line {
+			continue
+		}
+		idx := strings.LastIndex(line, alAuditChainKey)
+		if 0 > idx {
+			if "" != last {
+				return lineNo, fmt.Errorf("apachelogger: audit line %d: missing chain value", lineNo)
+			}
+			continue
+		}
+		chain := line[idx+len(alAuditChainKey):]
+		if auditChain(last, line[:idx]) != chain {
+			return lineNo, fmt.Errorf("apachelogger: audit line %d: broken chain", lineNo)
+		}
+		last = chain
+	}
+
+	return 0, scanner.Err()
+} // VerifyAuditLog()
+
+/* _EoF_ */
@@ -0,0 +1,96 @@
+/*
+Copyright © 2024  M.Watermann, 10247 Berlin, Germany
+
+	    All rights reserved
+	EMail : <support@mwat.de>
+*/
+package apachelogger
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+//lint:file-ignore ST1017 – I prefer Yoda conditions
+
+func TestAudit(t *testing.T) {
+	defer func() { _ = SetAuditLog("", false) }()
+	_ = SetAuditLog("", false)
+	if err := Audit("auth", "login", nil); errNoAuditLog != err {
+		t.Errorf("Audit() error = %v, want %v", err, errNoAuditLog)
+	}
+
+	name := filepath.Join(t.TempDir(), "audit.log")
+	if err := SetAuditLog(name, true); nil != err {
+		t.Fatalf("SetAuditLog() error = %v", err)
+	}
+	if err := Audit("auth", "login", map[string]string{
+		"user": "jane", "time": "forged", "the ip": "10.1.2.3",
+	}); nil != err {
+		t.Fatalf("Audit() error = %v", err)
+	}
+	// reopening continues the chain:
+	if err := SetAuditLog(name, true); nil != err {
+		t.Fatalf("SetAuditLog() error = %v", err)
+	}
+	if err := Audit("auth", "logout", map[string]string{"user": "jane doe"}); nil != err {
+		t.Fatalf("Audit() error = %v", err)
+	}
+
+	data, err := os.ReadFile(name)
+	if nil != err {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSpace(string(data)), "\n")
+	if 2 != len(lines) {
+		t.Fatalf("audit log = %q, want 2 lines", data)
+	}
+	for _, want := range []string{
+		" sender=auth event=login the_ip=10.1.2.3 x_time=forged user=jane chain=",
+	} {
+		if !strings.Contains(lines[0], want) {
+			t.Errorf("line = %q, missing %q", lines[0], want)
+		}
+	}
+	if !strings.Contains(lines[1], ` event=logout user="jane doe" chain=`) {
+		t.Errorf("line = %q", lines[1])
+	}
+	if n, err := VerifyAuditLog(strings.NewReader(string(data))); nil != err {
+		t.Errorf("VerifyAuditLog() = %d, %v", n, err)
+	}
+
+	tampered := strings.Replace(string(data), "user=jane ", "user=john ", 1)
+	if n, err := VerifyAuditLog(strings.NewReader(tampered)); (1 != n) || (nil == err) {
+		t.Errorf("VerifyAuditLog() = %d, %v, want 1 and an error", n, err)
+	}
+	if n, err := VerifyAuditLog(strings.NewReader(lines[1] + "\n")); (1 != n) || (nil == err) {
+		t.Errorf("VerifyAuditLog() = %d, %v, want 1 and an error", n, err)
+	}
+} // TestAudit()
+
+func TestAudit_syncError(t *testing.T) {
+	defer func() { _ = SetAuditLog("", false) }()
+	// writing to `/dev/null` succeeds while syncing it fails
+	if err := SetAuditLog(os.DevNull, true); nil != err {
+		t.Skipf("SetAuditLog() error = %v", err)
+	}
+	if err := Audit("auth", "login", nil); nil == err {
+		t.Skip("syncing the null device succeeded")
+	}
+	alAudit.Lock()
+	last := alAudit.last
+	alAudit.Unlock()
+	if "" == last {
+		t.Fatal("Audit() didn't advance the chain after writing the entry")
+	}
+	_ = Audit("auth", "logout", nil)
+	alAudit.Lock()
+	defer alAudit.Unlock()
+	if last == alAudit.last {
+		t.Error("Audit() didn't advance the chain again")
+	}
+} // TestAudit_syncError()
+
+/* _EoF_ */
@@ -0,0 +1,115 @@
+/*
+Copyright © 2024  M.Watermann, 10247 Berlin, Germany
+
+	    All rights reserved
+	EMail : <support@mwat.de>
+*/
+package apachelogger
+
+import (
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+//lint:file-ignore ST1017 – I prefer Yoda conditions
+
+var (
+	// The auth-failure log's settings.
+	alAuthLog struct {
+		sync.RWMutex
+		queue    *tRing       // the queue of the auth-failure logfile
+		statuses map[int]bool // the statuses to log
+	}
+)
+
+// `authFailureLine()` returns the auth-failure log line of `aEntry`.
+//
+// Parameters:
+// - `aEntry`: The (prepared) access log entry.
+// - `aClient`: The client's full address.
+//
+// Returns:
+// - `string`: The logfile line.
+func authFailureLine(aEntry *TEntry, aClient string) string {
+	if host, _, err := net.SplitHostPort(aClient); nil == err {
+		aClient = host
+	}
+	path := aEntry.Path
+	if idx := strings.IndexByte(path, '?'); 0 <= idx {
+		path = path[:idx]
+	}
+
+	return fmt.Sprintf("%s apachelogger auth-failure client=%s status=%d method=%s path=%s user=%s\n",
+		aEntry.inLocation(aEntry.When).Format(time.RFC3339), aClient, aEntry.Status,
+		strconv.Quote(aEntry.Method), strconv.Quote(path),
+		strconv.Quote(aEntry.User))
+} // authFailureLine()
+
+// `logAuthFailure()` writes `aEntry` to the auth-failure log if its
+// status is to be logged there.
+//
+// Parameters:
+// - `aEntry`: The (prepared) access log entry.
+// - `aClient`: The client's full address.
+func logAuthFailure(aEntry *TEntry, aClient string) {
+	alAuthLog.RLock()
+	queue, ok := alAuthLog.queue, alAuthLog.statuses[aEntry.Status]
+	alAuthLog.RUnlock()
+	if (nil == queue) || !ok {
+		return
+	}
+
+	queue.push(authFailureLine(aEntry, aClient))
+} // logAuthFailure()
+
+// `SetAuthFailureLog()` arranges for requests failing authentication
+// or authorisation to be written to a dedicated logfile suitable for
+// `fail2ban` jails.
+//
+// NOTE: The lines written to that file contain the client's **full**
+// IP address (regardless of `AnonymiseURLs`), since banning needs the
+// real address; calling this function is the explicit opt-in to store
+// that personal data for the failed requests only. The regular access
+// log isn't affected.
+//
+// The address is the one of the connection's peer, never taken from
+// headers like `X-Forwarded-For` which any client can forge to get
+// others banned; behind a proxy use `NewProxyListener()` to get the
+// real client addresses.
+//
+// The file's format is stable, one request per line:
+//
+//	2024-04-25T20:16:45+02:00 apachelogger auth-failure client=192.168.1.23 status=401 method="GET" path="/admin" user="-"
+//
+// A matching `fail2ban` filter (e.g. `filter.d/apachelogger.conf`) is:
+//
+//	[Definition]
+//	failregex = ^\S+ apachelogger auth-failure client=<HOST> status=\d+
+//	datepattern = {^LN-BEG}ISO8601
+//
+// Parameters:
+// - `aFilename`: The name of the logfile (empty to stop logging).
+// - `aStatuses`: The statuses to log (default: `401` and `403`).
+func SetAuthFailureLog(aFilename string, aStatuses ...int) {
+	alAuthLog.Lock()
+	defer alAuthLog.Unlock()
+
+	if "" == aFilename {
+		alAuthLog.queue, alAuthLog.statuses = nil, nil
+		return
+	}
+	if 0 == len(aStatuses) {
+		aStatuses = []int{401, 403}
+	}
+	alAuthLog.statuses = make(map[int]bool, len(aStatuses))
+	for _, status := range aStatuses {
+		alAuthLog.statuses[status] = true
+	}
+	alAuthLog.queue = fileQueue(aFilename)
+} // SetAuthFailureLog()
+
+/* _EoF_ */
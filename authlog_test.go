@@ -0,0 +1,92 @@
+/*
+Copyright © 2024  M.Watermann, 10247 Berlin, Germany
+
+	    All rights reserved
+	EMail : <support@mwat.de>
+*/
+package apachelogger
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+)
+
+//lint:file-ignore ST1017 – I prefer Yoda conditions
+
+func Test_authFailureLine(t *testing.T) {
+	e := prepEntry()
+	e.Status = 401
+	got := authFailureLine(e, "192.168.1.23:4567")
+	want := `2024-04-25T20:16:45+02:00 apachelogger auth-failure client=192.168.1.23 status=401 method="GET" path="/path/to/file" user="-"` + "\n"
+	if got != want {
+		t.Errorf("authFailureLine() = %q,\nwant %q", got, want)
+	}
+
+	// the documented fail2ban regex must match:
+	re := regexp.MustCompile(`^\S+ apachelogger auth-failure client=(\S+) status=\d+`)
+	if m := re.FindStringSubmatch(got); (nil == m) || ("192.168.1.23" != m[1]) {
+		t.Errorf("failregex match = %v", m)
+	}
+
+	got = authFailureLine(e, "[2001:db8::1]:443")
+	if !strings.Contains(got, " client=2001:db8::1 ") {
+		t.Errorf("authFailureLine() = %q", got)
+	}
+} // Test_authFailureLine()
+
+func TestSetAuthFailureLog(t *testing.T) {
+	defer SetAuthFailureLog("")
+	name := filepath.Join(t.TempDir(), "auth.log")
+	SetAuthFailureLog(name, 401, 429)
+
+	e := prepEntry()
+	logAuthFailure(e, "192.168.1.23:4567") // status 200: ignored
+	e.Status = 429
+	logAuthFailure(e, "192.168.1.24:4567")
+
+	var data []byte
+	for i := 0; 100 > i; i++ {
+		if data, _ = os.ReadFile(name); 0 < len(data) {
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if got := string(data); !strings.Contains(got, " client=192.168.1.24 status=429 ") ||
+		strings.Contains(got, "192.168.1.23") {
+		t.Errorf("auth log = %q", got)
+	}
+} // TestSetAuthFailureLog()
+
+func TestSetAuthFailureLog_forwarded(t *testing.T) {
+	defer SetAuthFailureLog("")
+	name := filepath.Join(t.TempDir(), "auth.log")
+	SetAuthFailureLog(name)
+
+	handler := WrapSinks(http.HandlerFunc(func(aWriter http.ResponseWriter, _ *http.Request) {
+		aWriter.WriteHeader(http.StatusUnauthorized)
+	}), Discard, Discard)
+	req := httptest.NewRequest(http.MethodGet, "/admin", nil)
+	req.RemoteAddr = "192.168.1.25:4567"
+	req.Header.Set("X-Forwarded-For", "10.9.9.9")
+	handler.ServeHTTP(httptest.NewRecorder(), req)
+
+	var data []byte
+	for i := 0; 100 > i; i++ {
+		if data, _ = os.ReadFile(name); 0 < len(data) {
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if got := string(data); !strings.Contains(got, " client=192.168.1.25 status=401 ") ||
+		strings.Contains(got, "10.9.9.9") {
+		t.Errorf("auth log = %q, want the peer's address", got)
+	}
+} // TestSetAuthFailureLog_forwarded()
+
+/* _EoF_ */
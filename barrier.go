@@ -0,0 +1,170 @@
+/*
+Copyright © 2024  M.Watermann, 10247 Berlin, Germany
+
+	    All rights reserved
+	EMail : <support@mwat.de>
+*/
+package apachelogger
+
+import (
+	"context"
+	"sync/atomic"
+	"time"
+)
+
+//lint:file-ignore ST1017 – I prefer Yoda conditions
+
+type (
+	// `tBarrier` is a caller of `Barrier()` waiting for its entry.
+	tBarrier struct {
+		target uint64     // the queue's `written` count to wait for
+		done   chan error // receives the result of syncing the sink
+	}
+
+	// `tSyncer` is implemented by sinks that can commit the data
+	// written so far to stable storage.
+	tSyncer interface {
+		// `sync()` writes buffered data and commits it to disk.
+		sync() error
+	}
+)
+
+// `sync()` commits the logfile's content to disk.
+//
+// Part of the `tSyncer` interface.
+//
+// Returns:
+// - `error`: A possible error syncing the logfile.
+func (fs *tFileSink) sync() error {
+	if nil == fs.file {
+		return nil // closed files were written with `O_SYNC`
+	}
+
+	return fs.file.Sync()
+} // sync()
+
+// `sync()` finishes a flush point and commits the logfile's content
+// to disk, regardless of the flush interval.
+//
+// Part of the `tSyncer` interface.
+//
+// Returns:
+// - `error`: A possible error writing or syncing the logfile.
+func (gs *tGzipFileSink) sync() error {
+	if nil == gs.writer {
+		return nil
+	}
+	if err := gs.writer.Flush(); nil != err {
+		return err
+	}
+	gs.lastFlush = time.Now()
+
+	return gs.file.Sync()
+} // sync()
+
+/* * * * * * * * * * * * * * * * * * * * * * * * * * * * * * * * * * * * * */
+
+// `addBarrier()` registers a barrier waiting for all messages queued
+// so far.
+//
+// Returns:
+// - `<-chan error`: Receives the result once the messages are synced.
+func (r *tRing) addBarrier() <-chan error {
+	barrier := tBarrier{
+		target: atomic.LoadUint64(&r.queued),
+		done:   make(chan error, 1),
+	}
+	r.mtx.Lock()
+	r.barriers = append(r.barriers, barrier)
+	atomic.AddInt32(&r.waiters, 1)
+	r.mtx.Unlock()
+	r.wake()
+
+	return barrier.done
+} // addBarrier()
+
+// `releaseBarriers()` syncs `aSink` and releases the barriers whose
+// messages are written.
+//
+// This method must be called by the consumer only.
+//
+// Parameters:
+// - `aSink`: The sink the messages were written to.
+func (r *tRing) releaseBarriers(aSink TSink) {
+	if 0 == atomic.LoadInt32(&r.waiters) {
+		return
+	}
+	written := atomic.LoadUint64(&r.written)
+	r.mtx.Lock()
+	var ready, waiting []tBarrier
+	for _, barrier := range r.barriers {
+		if barrier.target <= written {
+			ready = append(ready, barrier)
+		} else {
+			waiting = append(waiting, barrier)
+		}
+	}
+	r.barriers = waiting
+	atomic.StoreInt32(&r.waiters, int32(len(waiting)))
+	r.mtx.Unlock()
+	if 0 == len(ready) {
+		return
+	}
+
+	var err error
+	if syncer, ok := aSink.(tSyncer); ok {
+		if err = syncer.sync(); nil != err {
+			r.state.wrote(err)
+		}
+	}
+	for _, barrier := range ready {
+		barrier.done <- err
+	}
+} // releaseBarriers()
+
+/* * * * * * * * * * * * * * * * * * * * * * * * * * * * * * * * * * * * * */
+
+// `Barrier()` writes `aMessage` on behalf of `aSender` to the access
+// logfile and waits until it's committed to disk.
+//
+// Unlike `Log()`, which returns at once, the function returns only
+// after the entry (and all entries queued before) is written and the
+// logfile synced (`fsync`), even for buffering sinks like
+// `NewGzipFileSink()`. Use it for audit-sensitive operations which
+// must be durably recorded before responding to the client. Sinks
+// that can't be synced (e.g. remote ones) are flushed only.
+//
+// The entry is written even while the access log is paused; if the
+// queue is full the function waits for a free slot (regardless of
+// `SetBlockWhenFull()`).
+//
+// Parameters:
+// - `aContext`: The context limiting the time to wait.
+// - `aSender`: The name/designation of the sending entity.
+// - `aMessage`: The text to write to the access logfile.
+//
+// Returns:
+// - `error`: A possible error syncing the logfile, the context's
+// error if it's done before, or an error if no writer was started or
+// the entry couldn't be queued (e.g. the writer was stopped).
+func Barrier(aContext context.Context, aSender, aMessage string) error {
+	queue := accessQueue()
+	queue.state.Lock()
+	running := queue.state.running
+	queue.state.Unlock()
+	if !running {
+		return errNotStarted
+	}
+
+	if !queueEntry(customEntry(aSender, aMessage, `LOG`, time.Now()), queue, true) {
+		return errBarrierDropped
+	}
+	select {
+	case err := <-queue.addBarrier():
+		return err
+	case <-aContext.Done():
+		return aContext.Err()
+	}
+} // Barrier()
+
+/* _EoF_ */
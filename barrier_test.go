@@ -0,0 +1,126 @@
+/*
+Copyright © 2024  M.Watermann, 10247 Berlin, Germany
+
+	    All rights reserved
+	EMail : <support@mwat.de>
+*/
+package apachelogger
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+//lint:file-ignore ST1017 – I prefer Yoda conditions
+
+type (
+	// `tSyncMemSink` is a `tMemSink` counting its syncs (for testing).
+	tSyncMemSink struct {
+		tMemSink
+		syncs int32
+	}
+)
+
+func (ss *tSyncMemSink) sync() error {
+	atomic.AddInt32(&ss.syncs, 1)
+	return nil
+} // sync()
+
+func TestBarrier(t *testing.T) {
+	queue := newRing(8)
+	defer setLogQueues(setLogQueues(queue, nil))
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	if err := Barrier(ctx, "test", "not started"); errNotStarted != err {
+		t.Errorf("Barrier() error = %v, want %v", err, errNotStarted)
+	}
+
+	sink := &tSyncMemSink{}
+	queue.state.started(true)
+	done := make(chan struct{})
+	go func() {
+		goDoLogWrite(sink, queue)
+		close(done)
+	}()
+	queue.push("before\n")
+	if err := Barrier(ctx, "test", "audit record"); nil != err {
+		t.Fatalf("Barrier() error = %v", err)
+	}
+	if 1 > atomic.LoadInt32(&sink.syncs) {
+		t.Error("Barrier() returned without syncing the sink")
+	}
+	queue.close()
+	<-done
+
+	if got := string(sink.data); !strings.Contains(got, "before\n") ||
+		!strings.Contains(got, "audit record") {
+		t.Errorf("Barrier() wrote %q, want both entries", got)
+	}
+} // TestBarrier()
+
+func TestBarrier_queue(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	// a full queue makes the barrier wait instead of dropping it:
+	queue := newRing(2)
+	defer setLogQueues(setLogQueues(queue, nil))
+	queue.state.started(true)
+	queue.push("one\n")
+	queue.push("two\n")
+	result := make(chan error, 1)
+	go func() {
+		result <- Barrier(ctx, "test", "audit record")
+	}()
+	time.Sleep(20 * time.Millisecond)
+	sink := &tSyncMemSink{}
+	done := make(chan struct{})
+	go func() {
+		goDoLogWrite(sink, queue)
+		close(done)
+	}()
+	if err := <-result; nil != err {
+		t.Errorf("Barrier() error = %v for a full queue", err)
+	}
+	queue.close()
+	<-done
+	if got := string(sink.data); !strings.Contains(got, "audit record") {
+		t.Errorf("Barrier() wrote %q, want the audit record", got)
+	}
+
+	// a closed queue drops the entry:
+	queue = newRing(2)
+	setLogQueues(queue, nil)
+	queue.state.started(true)
+	queue.close()
+	if err := Barrier(ctx, "test", "lost"); errBarrierDropped != err {
+		t.Errorf("Barrier() error = %v, want %v", err, errBarrierDropped)
+	}
+} // TestBarrier_queue()
+
+func Test_tGzipFileSink_sync(t *testing.T) {
+	name := filepath.Join(t.TempDir(), "access.log.gz")
+	sink := NewGzipFileSink(name, time.Hour)
+	defer sink.Close()
+
+	if err := sink.(tSyncer).sync(); nil != err {
+		t.Errorf("sync() error = %v for an unopened sink", err)
+	}
+	_ = sink.Write([]byte("line 1\n"))
+	fi, _ := os.Stat(name)
+	size := fi.Size()
+	if err := sink.(tSyncer).sync(); nil != err {
+		t.Fatalf("sync() error = %v", err)
+	}
+	if fi, _ = os.Stat(name); fi.Size() <= size {
+		t.Errorf("sync() didn't write the compressed data (size %d)", fi.Size())
+	}
+} // Test_tGzipFileSink_sync()
+
+/* _EoF_ */
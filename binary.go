@@ -0,0 +1,298 @@
+/*
+Copyright © 2024  M.Watermann, 10247 Berlin, Germany
+
+	    All rights reserved
+	EMail : <support@mwat.de>
+*/
+package apachelogger
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"io"
+	"sort"
+	"time"
+)
+
+//lint:file-ignore ST1017 – I prefer Yoda conditions
+
+type (
+	// `TBinaryReader` reads binary log entries (see `BinaryLog`).
+	TBinaryReader struct {
+		reader *bufio.Reader // the source of binary records
+	}
+
+	// `tBinDecoder` decodes the fields of a binary record.
+	tBinDecoder struct {
+		data []byte // the remaining data
+		err  error  // the first decoding error
+	}
+)
+
+const (
+	// Version of the binary record format.
+	alBinaryVersion = 1
+
+	// Max. accepted size of a single binary record.
+	alBinaryMaxRecord = 1 << 20
+)
+
+var (
+	// `BinaryLog` decides whether to write the log entries in a compact
+	// binary format instead of text lines (default: `false`).
+	//
+	// Each record consists of its length (as unsigned varint) followed
+	// by a version byte and the entry's fields as varints and
+	// length-prefixed strings. Use `NewBinaryReader()` or the CLI's
+	// `dump` command to read such a logfile.
+	BinaryLog = false
+
+	// Error returned for malformed binary records.
+	errBinaryRecord = errors.New("apachelogger: malformed binary log record")
+)
+
+// `int()` decodes a signed varint.
+//
+// Returns:
+// - `int64`: The decoded value.
+func (bd *tBinDecoder) int() int64 {
+	if nil != bd.err {
+		return 0
+	}
+	value, n := binary.Varint(bd.data)
+	if 0 >= n {
+		bd.err = errBinaryRecord
+		return 0
+	}
+	bd.data = bd.data[n:]
+
+	return value
+} // int()
+
+// `string()` decodes a length-prefixed string.
+//
+// Returns:
+// - `string`: The decoded value.
+func (bd *tBinDecoder) string() string {
+	size := bd.uint()
+	if (nil != bd.err) || (uint64(len(bd.data)) < size) {
+		bd.err = errBinaryRecord
+		return ""
+	}
+	value := string(bd.data[:size])
+	bd.data = bd.data[size:]
+
+	return value
+} // string()
+
+// `uint()` decodes an unsigned varint.
+//
+// Returns:
+// - `uint64`: The decoded value.
+func (bd *tBinDecoder) uint() uint64 {
+	if nil != bd.err {
+		return 0
+	}
+	value, n := binary.Uvarint(bd.data)
+	if 0 >= n {
+		bd.err = errBinaryRecord
+		return 0
+	}
+	bd.data = bd.data[n:]
+
+	return value
+} // uint()
+
+/* * * * * * * * * * * * * * * * * * * * * * * * * * * * * * * * * * * * * */
+
+// `appendBinString()` appends the length-prefixed `aText` to `aBuffer`.
+//
+// Parameters:
+// - `aBuffer`: The buffer to append to.
+// - `aText`: The string to encode.
+//
+// Returns:
+// - `[]byte`: The extended buffer.
+func appendBinString(aBuffer []byte, aText string) []byte {
+	aBuffer = appendUvarint(aBuffer, uint64(len(aText)))
+
+	return append(aBuffer, aText...)
+} // appendBinString()
+
+// `appendUvarint()` appends the unsigned varint `aValue` to `aBuffer`.
+//
+// Parameters:
+// - `aBuffer`: The buffer to append to.
+// - `aValue`: The value to encode.
+//
+// Returns:
+// - `[]byte`: The extended buffer.
+func appendUvarint(aBuffer []byte, aValue uint64) []byte {
+	var tmp [binary.MaxVarintLen64]byte
+
+	return append(aBuffer, tmp[:binary.PutUvarint(tmp[:], aValue)]...)
+} // appendUvarint()
+
+// `appendVarint()` appends the signed varint `aValue` to `aBuffer`.
+//
+// Parameters:
+// - `aBuffer`: The buffer to append to.
+// - `aValue`: The value to encode.
+//
+// Returns:
+// - `[]byte`: The extended buffer.
+func appendVarint(aBuffer []byte, aValue int64) []byte {
+	var tmp [binary.MaxVarintLen64]byte
+
+	return append(aBuffer, tmp[:binary.PutVarint(tmp[:], aValue)]...)
+} // appendVarint()
+
+// `MarshalBinary()` returns the entry as a binary record (without the
+// leading record length).
+//
+// Part of the `encoding.BinaryMarshaler` interface.
+//
+// Returns:
+// - `[]byte`: The encoded entry.
+// - `error`: Always `nil`.
+func (e *TEntry) MarshalBinary() ([]byte, error) {
+	_, offset := e.When.Zone()
+	buf := make([]byte, 0, 128+len(e.Path)+len(e.Agent)+len(e.Referrer))
+
+	buf = append(buf, alBinaryVersion)
+	buf = appendVarint(buf, e.When.UnixNano())
+	buf = appendVarint(buf, int64(offset))
+	buf = appendBinString(buf, e.Remote)
+	buf = appendBinString(buf, e.User)
+	buf = appendBinString(buf, e.Method)
+	buf = appendBinString(buf, e.Path)
+	buf = appendBinString(buf, e.Proto)
+	buf = appendVarint(buf, int64(e.Status))
+	buf = appendVarint(buf, int64(e.Size))
+	buf = appendBinString(buf, e.Referrer)
+	buf = appendBinString(buf, e.Agent)
+	buf = appendVarint(buf, int64(e.Duration))
+
+	keys := make([]string, 0, len(e.Fields))
+	for key := range e.Fields {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	buf = appendUvarint(buf, uint64(len(keys)))
+	for _, key := range keys {
+		buf = appendBinString(buf, key)
+		buf = appendBinString(buf, e.Fields[key])
+	}
+
+	return buf, nil
+} // MarshalBinary()
+
+// `UnmarshalBinary()` decodes a binary record (without the leading
+// record length) into the entry.
+//
+// Part of the `encoding.BinaryUnmarshaler` interface.
+//
+// Parameters:
+// - `aData`: The encoded entry.
+//
+// Returns:
+// - `error`: A possible decoding error.
+func (e *TEntry) UnmarshalBinary(aData []byte) error {
+	if (0 == len(aData)) || (alBinaryVersion != aData[0]) {
+		return errBinaryRecord
+	}
+	bd := &tBinDecoder{data: aData[1:]}
+
+	nanos := bd.int()
+	offset := bd.int()
+	e.When = time.Unix(0, nanos).In(time.FixedZone("", int(offset)))
+	e.Remote = bd.string()
+	e.User = bd.string()
+	e.Method = bd.string()
+	e.Path = bd.string()
+	e.Proto = bd.string()
+	e.Status = int(bd.int())
+	e.Size = int(bd.int())
+	e.Referrer = bd.string()
+	e.Agent = bd.string()
+	e.Duration = time.Duration(bd.int())
+
+	e.Fields = nil
+	for count := bd.uint(); (0 < count) && (nil == bd.err); count-- {
+		key := bd.string()
+		e.SetField(key, bd.string())
+	}
+
+	return bd.err
+} // UnmarshalBinary()
+
+// `binaryRecord()` returns `aEntry` as a length-prefixed binary record.
+//
+// Parameters:
+// - `aEntry`: The log entry to encode.
+//
+// Returns:
+// - `string`: The binary record.
+func binaryRecord(aEntry *TEntry) string {
+	data, _ := aEntry.MarshalBinary()
+	record := appendUvarint(make([]byte, 0, len(data)+4), uint64(len(data)))
+
+	return string(append(record, data...))
+} // binaryRecord()
+
+/* * * * * * * * * * * * * * * * * * * * * * * * * * * * * * * * * * * * * */
+
+// `BinaryRecord()` returns `aEntry` as a length-prefixed binary record
+// (see `BinaryLog`), e.g. as the formatter of an access output feeding
+// `NewParquetSink()`.
+//
+// Parameters:
+// - `aEntry`: The log entry to encode.
+//
+// Returns:
+// - `string`: The binary record.
+func BinaryRecord(aEntry *TEntry) string {
+	return binaryRecord(aEntry)
+} // BinaryRecord()
+
+// `NewBinaryReader()` returns a reader of binary log records.
+//
+// Parameters:
+// - `aReader`: The source of binary records (e.g. a logfile).
+//
+// Returns:
+// - `*TBinaryReader`: The reader of log entries.
+func NewBinaryReader(aReader io.Reader) *TBinaryReader {
+	return &TBinaryReader{reader: bufio.NewReader(aReader)}
+} // NewBinaryReader()
+
+// `Next()` reads the next log entry.
+//
+// Returns:
+// - `*TEntry`: The log entry read.
+// - `error`: `io.EOF` at the end of input, or a reading/decoding error.
+func (br *TBinaryReader) Next() (*TEntry, error) {
+	size, err := binary.ReadUvarint(br.reader)
+	if nil != err {
+		if io.ErrUnexpectedEOF == err {
+			err = errBinaryRecord
+		}
+		return nil, err
+	}
+	if alBinaryMaxRecord < size {
+		return nil, errBinaryRecord
+	}
+	data := make([]byte, size)
+	if _, err = io.ReadFull(br.reader, data); nil != err {
+		return nil, errBinaryRecord
+	}
+	entry := &TEntry{}
+	if err = entry.UnmarshalBinary(data); nil != err {
+		return nil, err
+	}
+
+	return entry, nil
+} // Next()
+
+/* _EoF_ */
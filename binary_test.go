@@ -0,0 +1,78 @@
+/*
+Copyright © 2024  M.Watermann, 10247 Berlin, Germany
+
+	    All rights reserved
+	EMail : <support@mwat.de>
+*/
+package apachelogger
+
+import (
+	"bytes"
+	"io"
+	"strings"
+	"testing"
+	"time"
+)
+
+//lint:file-ignore ST1017 – I prefer Yoda conditions
+
+func TestTEntry_MarshalBinary(t *testing.T) {
+	e1 := prepEntry()
+	e1.Duration = 1234 * time.Microsecond
+	e1.SetField("trace_id", "Root=1-abc")
+	e1.SetField("ua_os", "Linux")
+
+	data, err := e1.MarshalBinary()
+	if nil != err {
+		t.Fatalf("MarshalBinary() error = %v", err)
+	}
+	e2 := &TEntry{}
+	if err = e2.UnmarshalBinary(data); nil != err {
+		t.Fatalf("UnmarshalBinary() error = %v", err)
+	}
+	if e1.String() != e2.String() {
+		t.Errorf("UnmarshalBinary() = %q,\nwant %q", e2.String(), e1.String())
+	}
+	if e1.Canonical() != e2.Canonical() {
+		t.Errorf("UnmarshalBinary() = %q,\nwant %q", e2.Canonical(), e1.Canonical())
+	}
+	if !e1.When.Equal(e2.When) {
+		t.Errorf("UnmarshalBinary() When = %v, want %v", e2.When, e1.When)
+	}
+
+	for _, bad := range [][]byte{nil, {9}, data[:len(data)-3]} {
+		if err = (&TEntry{}).UnmarshalBinary(bad); nil == err {
+			t.Errorf("UnmarshalBinary(%v) expected an error", bad)
+		}
+	}
+} // TestTEntry_MarshalBinary()
+
+func TestTBinaryReader_Next(t *testing.T) {
+	e1, e2 := prepEntry(), prepEntry()
+	e2.Path = "/second"
+	e2.Status = 404
+	input := binaryRecord(e1) + binaryRecord(e2)
+
+	br := NewBinaryReader(strings.NewReader(input))
+	for idx, want := range []*TEntry{e1, e2} {
+		got, err := br.Next()
+		if nil != err {
+			t.Fatalf("Next() %d error = %v", idx, err)
+		}
+		if got.String() != want.String() {
+			t.Errorf("Next() %d = %q, want %q", idx, got.String(), want.String())
+		}
+	}
+	if _, err := br.Next(); io.EOF != err {
+		t.Errorf("Next() error = %v, want %v", err, io.EOF)
+	}
+
+	truncated := []byte(input)[:len(input)-5]
+	br = NewBinaryReader(bytes.NewReader(truncated))
+	_, _ = br.Next()
+	if _, err := br.Next(); errBinaryRecord != err {
+		t.Errorf("Next() error = %v, want %v", err, errBinaryRecord)
+	}
+} // TestTBinaryReader_Next()
+
+/* _EoF_ */
@@ -0,0 +1,158 @@
+/*
+Copyright © 2024  M.Watermann, 10247 Berlin, Germany
+
+	    All rights reserved
+	EMail : <support@mwat.de>
+*/
+package apachelogger
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+//lint:file-ignore ST1017 – I prefer Yoda conditions
+
+var (
+	// `LogCacheStatus` decides whether to add the field `cache` to the
+	// access log entries telling whether the response was served by a
+	// cache (default: `false`).
+	//
+	// The value is derived from the response headers `Cache-Status`
+	// (RFC 9211), `X-Cache`, and `Age` (in this order of precedence) as
+	// set e.g. by a caching reverse proxy or middleware, and normalised
+	// to `hit`, `miss`, `expired` (a stale entry was revalidated or
+	// refreshed), or `bypass` (the cache wasn't used). Responses
+	// without any of these headers get no field.
+	LogCacheStatus = false
+)
+
+// `cacheStatus()` returns the normalised cache status given by the
+// response headers `aHeader`.
+//
+// Parameters:
+// - `aHeader`: The response's header.
+//
+// Returns:
+// - `string`: The cache status (empty if unknown).
+func cacheStatus(aHeader http.Header) string {
+	if value := lastListMember(aHeader.Values("Cache-Status")); "" != value {
+		if status := structuredCacheStatus(value); "" != status {
+			return status
+		}
+	}
+	if value := lastListMember(aHeader.Values("X-Cache")); "" != value {
+		if status := xCacheStatus(value); "" != status {
+			return status
+		}
+	}
+	if age, err := strconv.Atoi(strings.TrimSpace(aHeader.Get("Age"))); (nil == err) && (0 < age) {
+		return "hit" // served from a shared cache
+	}
+
+	return ""
+} // cacheStatus()
+
+// `lastListMember()` returns the last member of the comma separated
+// header values `aValues`, i.e. the one of the cache closest to the
+// client.
+//
+// Parameters:
+// - `aValues`: The header's values.
+//
+// Returns:
+// - `string`: The last member (empty if there's none).
+func lastListMember(aValues []string) string {
+	for idx := len(aValues) - 1; 0 <= idx; idx-- {
+		members := strings.Split(aValues[idx], ",")
+		for m := len(members) - 1; 0 <= m; m-- {
+			if member := strings.TrimSpace(members[m]); "" != member {
+				return member
+			}
+		}
+	}
+
+	return ""
+} // lastListMember()
+
+// `structuredCacheStatus()` returns the normalised status of an RFC 9211
+// `Cache-Status` member like `ExampleCache; fwd=stale; fwd-status=304`.
+//
+// Parameters:
+// - `aMember`: The list member to evaluate.
+//
+// Returns:
+// - `string`: The cache status (empty if unknown).
+func structuredCacheStatus(aMember string) string {
+	params := strings.Split(aMember, ";")
+	for _, param := range params[1:] {
+		name, value := strings.TrimSpace(param), ""
+		if idx := strings.IndexByte(name, '='); 0 <= idx {
+			name, value = strings.TrimSpace(name[:idx]), strings.Trim(strings.TrimSpace(name[idx+1:]), `"`)
+		}
+		switch strings.ToLower(name) {
+		case "hit":
+			if ("" == value) || ("?1" == value) {
+				return "hit"
+			}
+		case "fwd":
+			switch strings.ToLower(value) {
+			case "stale":
+				return "expired"
+			case "bypass", "method", "request":
+				return "bypass"
+			default: // uri-miss, vary-miss, miss, partial
+				return "miss"
+			}
+		}
+	}
+
+	return ""
+} // structuredCacheStatus()
+
+// `xCacheStatus()` returns the normalised status of an `X-Cache` value
+// like `HIT`, `TCP_REFRESH_HIT`, or `Miss from cloudfront`.
+//
+// Parameters:
+// - `aValue`: The value to evaluate.
+//
+// Returns:
+// - `string`: The cache status (empty if unknown).
+func xCacheStatus(aValue string) string {
+	value := strings.ToUpper(aValue)
+	switch {
+	case strings.Contains(value, "EXPIRED"), strings.Contains(value, "STALE"),
+		strings.Contains(value, "REFRESH"), strings.Contains(value, "REVALIDATED"):
+		return "expired"
+	case strings.Contains(value, "MISS"):
+		return "miss"
+	case strings.Contains(value, "HIT"):
+		return "hit"
+	case strings.Contains(value, "PASS"): // `PASS` or `BYPASS`
+		return "bypass"
+	}
+
+	return ""
+} // xCacheStatus()
+
+// `captureCacheStatus()` records the cache status of the response
+// header sent if `LogCacheStatus` is set.
+func (lw *tLogWriter) captureCacheStatus() {
+	if LogCacheStatus {
+		lw.cache = cacheStatus(lw.ResponseWriter.Header())
+	}
+} // captureCacheStatus()
+
+// `addCacheStatus()` adds the field `cache` to `aEntry`.
+//
+// Parameters:
+// - `aEntry`: The log entry to complete.
+// - `aStatus`: The cache status captured (may be empty).
+func addCacheStatus(aEntry *TEntry, aStatus string) {
+	if "" != aStatus {
+		aEntry.SetField("cache", aStatus)
+	}
+} // addCacheStatus()
+
+/* _EoF_ */
@@ -0,0 +1,67 @@
+/*
+Copyright © 2024  M.Watermann, 10247 Berlin, Germany
+
+	    All rights reserved
+	EMail : <support@mwat.de>
+*/
+package apachelogger
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+//lint:file-ignore ST1017 – I prefer Yoda conditions
+
+func Test_cacheStatus(t *testing.T) {
+	tests := []struct {
+		name   string
+		header http.Header
+		want   string
+	}{
+		{"none", http.Header{}, ""},
+		{"rfc hit", http.Header{"Cache-Status": {"OriginCache; fwd=uri-miss, CDN; hit; ttl=30"}}, "hit"},
+		{"rfc miss", http.Header{"Cache-Status": {"CDN; hit", "Edge; fwd=uri-miss; stored"}}, "miss"},
+		{"rfc stale", http.Header{"Cache-Status": {`CDN; fwd=stale; fwd-status=304`}}, "expired"},
+		{"rfc bypass", http.Header{"Cache-Status": {"CDN; fwd=bypass"}}, "bypass"},
+		{"rfc unknown", http.Header{"Cache-Status": {"CDN"}, "X-Cache": {"HIT"}}, "hit"},
+		{"x-cache fastly", http.Header{"X-Cache": {"MISS, HIT"}}, "hit"},
+		{"x-cache cloudfront", http.Header{"X-Cache": {"Miss from cloudfront"}}, "miss"},
+		{"x-cache squid", http.Header{"X-Cache": {"TCP_REFRESH_HIT"}}, "expired"},
+		{"x-cache nginx", http.Header{"X-Cache": {"BYPASS"}}, "bypass"},
+		{"age", http.Header{"Age": {"120"}}, "hit"},
+		{"age zero", http.Header{"Age": {"0"}}, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := cacheStatus(tt.header); tt.want != got {
+				t.Errorf("cacheStatus() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+} // Test_cacheStatus()
+
+func Test_tLogWriter_captureCacheStatus(t *testing.T) {
+	defer func() { LogCacheStatus = false }()
+
+	lw := &tLogWriter{ResponseWriter: httptest.NewRecorder()}
+	lw.Header().Set("X-Cache", "HIT")
+	_, _ = lw.Write([]byte("cached"))
+	if "" != lw.cache {
+		t.Errorf("cache = %q while disabled, want none", lw.cache)
+	}
+
+	LogCacheStatus = true
+	lw = &tLogWriter{ResponseWriter: httptest.NewRecorder()}
+	lw.Header().Set("X-Cache", "HIT")
+	lw.WriteHeader(http.StatusOK)
+	lw.Header().Set("X-Cache", "MISS") // after the header was sent
+	e := prepEntry()
+	addCacheStatus(e, lw.cache)
+	if "hit" != e.Fields["cache"] {
+		t.Errorf("addCacheStatus() fields = %v, want cache=hit", e.Fields)
+	}
+} // Test_tLogWriter_captureCacheStatus()
+
+/* _EoF_ */
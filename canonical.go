@@ -0,0 +1,168 @@
+/*
+Copyright © 2024  M.Watermann, 10247 Berlin, Germany
+
+	    All rights reserved
+	EMail : <support@mwat.de>
+*/
+package apachelogger
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+//lint:file-ignore ST1017 – I prefer Yoda conditions
+
+var (
+	// `AppendFields` decides whether to append the entries' additional
+	// fields (see `SetField()`) as `key=value` pairs to the Apache-like
+	// logfile lines (default: `false`).
+	AppendFields = false
+
+	// `CanonicalLogLine` decides whether to write "canonical log lines"
+	// (i.e. one wide line of `key=value` pairs per request including
+	// all custom fields set by `SetField()`) instead of Apache-like
+	// lines (default: `false`).
+	CanonicalLogLine = false
+)
+
+// `Canonical()` returns the entry formatted as a canonical log line.
+//
+// The line starts with the standard fields in fixed order (the duration
+// only if it was measured) followed by all additional fields sorted by
+// their names:
+//
+//	canonical-log-line time=2024-04-25T20:16:45+02:00 remote=192.168.1.0 user=- method=GET path=/ proto=HTTP/1.1 status=200 size=5361 referrer=- agent="Mozilla/5.0 (X11)" user_id=42
+//
+// Returns:
+// - `string`: The formatted logfile line.
+func (e *TEntry) Canonical() string {
+	var sb strings.Builder
+
+	sb.WriteString("canonical-log-line")
+	appendKeyValue(&sb, "time", e.timestamp().Format(time.RFC3339))
+	appendKeyValue(&sb, "remote", e.Remote)
+	appendKeyValue(&sb, "user", e.User)
+	appendKeyValue(&sb, "method", e.Method)
+	appendKeyValue(&sb, "path", e.Path)
+	appendKeyValue(&sb, "proto", e.Proto)
+	appendKeyValue(&sb, "status", strconv.Itoa(e.Status))
+	appendKeyValue(&sb, "size", strconv.Itoa(e.Size))
+	if 0 < e.Duration {
+		appendKeyValue(&sb, "duration_us",
+			strconv.FormatInt(e.Duration.Microseconds(), 10))
+	}
+	appendKeyValue(&sb, "referrer", e.Referrer)
+	appendKeyValue(&sb, "agent", e.Agent)
+
+	appendFields(&sb, e.Fields)
+	sb.WriteByte('\n')
+
+	return sb.String()
+} // Canonical()
+
+// `appendFields()` writes all `aFields` sorted by their names as
+// ` key=value` pairs to `aBuilder`.
+//
+// Parameters:
+// - `aBuilder`: The string builder to write to.
+// - `aFields`: The fields to write.
+func appendFields(aBuilder *strings.Builder, aFields map[string]string) {
+	if 0 == len(aFields) {
+		return
+	}
+	keys := make([]string, 0, len(aFields))
+	for key := range aFields {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		appendKeyValue(aBuilder, key, aFields[key])
+	}
+} // appendFields()
+
+// `appendKeyValue()` writes ` aKey=aValue` to `aBuilder` quoting the
+// value if necessary.
+//
+// Parameters:
+// - `aBuilder`: The string builder to write to.
+// - `aKey`: The name of the field.
+// - `aValue`: The field's value.
+func appendKeyValue(aBuilder *strings.Builder, aKey, aValue string) {
+	aBuilder.WriteByte(' ')
+	aBuilder.WriteString(aKey)
+	aBuilder.WriteByte('=')
+	if needsQuoting(aValue) {
+		aBuilder.WriteString(strconv.Quote(aValue))
+	} else {
+		aBuilder.WriteString(aValue)
+	}
+} // appendKeyValue()
+
+// `needsQuoting()` reports whether `aValue` must be quoted in a
+// `key=value` pair.
+//
+// Parameters:
+// - `aValue`: The value to check.
+//
+// Returns:
+// - `bool`: `true` if `aValue` is empty or contains special characters.
+func needsQuoting(aValue string) bool {
+	if "" == aValue {
+		return true
+	}
+	for _, r := range aValue {
+		if (' ' >= r) || ('"' == r) || ('\\' == r) || (0x7f <= r) {
+			return true
+		}
+	}
+
+	return false
+} // needsQuoting()
+
+// `formatEntry()` returns `aEntry` formatted according to the format
+// of its log (see `WithFormat()`) or else the current configuration.
+//
+// Parameters:
+// - `aEntry`: The log entry to format.
+//
+// Returns:
+// - `string`: The formatted logfile line.
+func formatEntry(aEntry *TEntry) string {
+	if lf := aEntry.settings.logFormat(); nil != lf {
+		return lf.render(aEntry)
+	}
+	if BinaryLog {
+		return binaryRecord(aEntry)
+	}
+	if JSONErrorLog && (LevelWarn <= aEntry.level()) {
+		return aEntry.errorJSON()
+	}
+	if line, ok := csvLine(aEntry); ok {
+		return line
+	}
+	if line, ok := w3cLine(aEntry); ok {
+		return line
+	}
+	if lf := logFormat(); nil != lf {
+		return lf.render(aEntry)
+	}
+	if CanonicalLogLine {
+		return aEntry.Canonical()
+	}
+	if (AppendFields || aEntry.summary) && (0 < len(aEntry.Fields)) {
+		var sb strings.Builder
+		line := aEntry.String()
+		sb.WriteString(line[:len(line)-1]) // without trailing newline
+		appendFields(&sb, aEntry.Fields)
+		sb.WriteByte('\n')
+
+		return sb.String()
+	}
+
+	return aEntry.String()
+} // formatEntry()
+
+/* _EoF_ */
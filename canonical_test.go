@@ -0,0 +1,69 @@
+/*
+Copyright © 2024  M.Watermann, 10247 Berlin, Germany
+
+	    All rights reserved
+	EMail : <support@mwat.de>
+*/
+package apachelogger
+
+import (
+	"net/http/httptest"
+	"testing"
+)
+
+//lint:file-ignore ST1017 – I prefer Yoda conditions
+
+func TestTEntry_Canonical(t *testing.T) {
+	e1 := prepEntry()
+	w1 := `canonical-log-line time=2024-04-25T20:16:45+02:00 remote=192.168.1.0 user=- method=GET path=/path/to/file?lang=en proto=HTTP/1.1 status=200 size=27155 referrer=- agent=Mozilla/5.0` + "\n"
+
+	e2 := prepEntry()
+	e2.Agent = `Mozilla/5.0 (X11; "Linux")`
+	e2.SetField("user_id", "42")
+	e2.SetField("db_time", "1.5ms")
+	e2.SetField("empty", "")
+	w2 := `canonical-log-line time=2024-04-25T20:16:45+02:00 remote=192.168.1.0 user=- method=GET path=/path/to/file?lang=en proto=HTTP/1.1 status=200 size=27155 referrer=- agent="Mozilla/5.0 (X11; \"Linux\")" db_time=1.5ms empty="" user_id=42` + "\n"
+
+	tests := []struct {
+		name  string
+		entry *TEntry
+		want  string
+	}{
+		{" 1", e1, w1},
+		{" 2", e2, w2},
+		// TODO: Add test cases.
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.entry.Canonical(); got != tt.want {
+				t.Errorf("%q: TEntry.Canonical() = %q,\nwant %q",
+					tt.name, got, tt.want)
+			}
+		})
+	}
+} // TestTEntry_Canonical()
+
+func TestSetField(t *testing.T) {
+	req, rs := withRequestState(httptest.NewRequest("GET", "/", nil))
+
+	if fields := rs.copyFields(); nil != fields {
+		t.Errorf("copyFields() = %v, want nil", fields)
+	}
+	SetField(req.Context(), "user_id", 42)
+	SetField(req.Context(), "cached", true)
+	SetField(req.Context(), "cached", false)
+	SetField(req.Context(), "", "ignored")
+
+	fields := rs.copyFields()
+	if 2 != len(fields) {
+		t.Fatalf("copyFields() = %v, want 2 fields", fields)
+	}
+	if w := "42"; w != fields["user_id"] {
+		t.Errorf("user_id = %q, want %q", fields["user_id"], w)
+	}
+	if w := "false"; w != fields["cached"] {
+		t.Errorf("cached = %q, want %q", fields["cached"], w)
+	}
+} // TestSetField()
+
+/* _EoF_ */
@@ -0,0 +1,206 @@
+/*
+Copyright © 2024  M.Watermann, 10247 Berlin, Germany
+
+	    All rights reserved
+	EMail : <support@mwat.de>
+*/
+package apachelogger
+
+import (
+	"fmt"
+	"math/rand"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+//lint:file-ignore ST1017 – I prefer Yoda conditions
+
+var (
+	// Flag telling whether a debug capture is running (`1`) or not (`0`).
+	alCaptureActive int32
+
+	// The debug capture's configuration.
+	alCapture struct {
+		sync.Mutex
+		queue *tRing      // the capture file's queue
+		rate  float64     // the share of requests to capture
+		until time.Time   // the end of the capture
+		timer *time.Timer // stops the capture at `until`
+	}
+)
+
+// `sampleCapture()` decides whether to capture the request `aRequest`.
+//
+// Parameters:
+// - `aRequest`: The request received.
+//
+// Returns:
+// - `[]string`: The request's (redacted) header lines, `nil` if the
+// request isn't captured.
+func sampleCapture(aRequest *http.Request) []string {
+	if 0 == atomic.LoadInt32(&alCaptureActive) {
+		return nil
+	}
+	alCapture.Lock()
+	sampled := (nil != alCapture.queue) && time.Now().Before(alCapture.until) &&
+		(rand.Float64() < alCapture.rate)
+	alCapture.Unlock()
+	if !sampled {
+		return nil
+	}
+
+	return append([]string{"Host: " + sanitiseString(aRequest.Host)},
+		crashHeaders(aRequest.Header)...)
+} // sampleCapture()
+
+// `captureRecord()` returns the capture record of a request.
+//
+// Parameters:
+// - `aLogger`: The request's response writer.
+// - `aRequest`: The request served.
+// - `aHeaders`: The request's header lines returned by `sampleCapture()`.
+//
+// Returns:
+// - `string`: The multi-line record.
+func captureRecord(aLogger *tLogWriter, aRequest *http.Request, aHeaders []string) string {
+	path := &TEntry{Path: getPath(aRequest.URL)}
+	applyRedactions(path)
+
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "=== %s %s \"%s %s %s\" %d %d %s\n",
+		inLocation(aLogger.when).Format(time.RFC3339Nano),
+		getRemote(aRequest, aLogger.status, aLogger.queue.logSettings().remoteAnonymiser()),
+		sanitiseString(aRequest.Method), sanitiseString(path.Path),
+		getProto(aRequest), aLogger.status, aLogger.size, aLogger.duration)
+	for _, line := range aHeaders {
+		sb.WriteString("> " + line + "\n")
+	}
+	if aLogger.hijacked {
+		sb.WriteString("< (connection hijacked)\n")
+	} else {
+		for _, line := range crashHeaders(aLogger.responseHeader()) {
+			sb.WriteString("< " + line + "\n")
+		}
+	}
+
+	return sb.String()
+} // captureRecord()
+
+// `logCapture()` writes the capture record of a request sampled by
+// `sampleCapture()`.
+//
+// Parameters:
+// - `aLogger`: The request's response writer.
+// - `aRequest`: The request served.
+// - `aHeaders`: The request's header lines.
+func logCapture(aLogger *tLogWriter, aRequest *http.Request, aHeaders []string) {
+	alCapture.Lock()
+	queue := alCapture.queue
+	alCapture.Unlock()
+
+	if nil != queue {
+		queue.push(captureRecord(aLogger, aRequest, aHeaders))
+	}
+} // logCapture()
+
+/* * * * * * * * * * * * * * * * * * * * * * * * * * * * * * * * * * * * * */
+
+// `Capturing()` reports whether a debug capture started by
+// `StartCapture()` is running.
+//
+// Returns:
+// - `bool`: `true` while requests are captured.
+func Capturing() bool {
+	return 1 == atomic.LoadInt32(&alCaptureActive)
+} // Capturing()
+
+// `StartCapture()` starts a debug capture writing the full request and
+// response headers of a random sample of the requests to the file
+// `aFilename` for the time `aDuration`.
+//
+// Like a `tcpdump` at the HTTP level this helps to debug problems
+// which can't be reproduced elsewhere. Each record consists of a line
+// starting with `===` (time, anonymised address, request line, status,
+// size, and duration) followed by the request headers (prefixed by
+// `> `) and the response headers (prefixed by `< `). The values of
+// sensitive headers (e.g. `Authorization`, `Cookie`, `Set-Cookie`, or
+// any `…-Token`) are redacted and the addresses of proxy chains are
+// anonymised.
+//
+// The capture can be started (again, replacing the current one) and
+// stopped by `StopCapture()` at runtime, e.g. by an admin endpoint; it
+// ends automatically after `aDuration`.
+//
+// Parameters:
+// - `aFilename`: The name of the capture file.
+// - `aRate`: The share of requests to capture (`0` < `aRate` <= `1`).
+// - `aDuration`: The time to capture requests.
+//
+// Returns:
+// - `error`: A possible error with the arguments.
+func StartCapture(aFilename string, aRate float64, aDuration time.Duration) error {
+	if "" == aFilename {
+		return fmt.Errorf("apachelogger: no capture file")
+	}
+	if (0 >= aRate) || (1 < aRate) {
+		return fmt.Errorf("apachelogger: invalid capture rate %v", aRate)
+	}
+	if 0 >= aDuration {
+		return fmt.Errorf("apachelogger: invalid capture duration %v", aDuration)
+	}
+	queue := fileQueue(aFilename)
+
+	alCapture.Lock()
+	defer alCapture.Unlock()
+
+	if nil != alCapture.timer {
+		alCapture.timer.Stop()
+	}
+	now := time.Now()
+	alCapture.queue, alCapture.rate = queue, aRate
+	alCapture.until = now.Add(aDuration)
+	until := alCapture.until
+	alCapture.timer = time.AfterFunc(aDuration, func() {
+		alCapture.Lock()
+		defer alCapture.Unlock()
+		if until.Equal(alCapture.until) { // not restarted meanwhile
+			stopCapture()
+		}
+	})
+	atomic.StoreInt32(&alCaptureActive, 1)
+	queue.push(fmt.Sprintf("# capture started %s rate=%s until=%s\n",
+		inLocation(now).Format(time.RFC3339),
+		strconv.FormatFloat(aRate, 'g', -1, 64),
+		inLocation(alCapture.until).Format(time.RFC3339)))
+
+	return nil
+} // StartCapture()
+
+// `StopCapture()` stops the debug capture started by `StartCapture()`.
+func StopCapture() {
+	alCapture.Lock()
+	defer alCapture.Unlock()
+
+	stopCapture()
+} // StopCapture()
+
+// `stopCapture()` stops the debug capture; the caller must hold the
+// lock of `alCapture`.
+func stopCapture() {
+	atomic.StoreInt32(&alCaptureActive, 0)
+	if nil != alCapture.timer {
+		alCapture.timer.Stop()
+		alCapture.timer = nil
+	}
+	if nil != alCapture.queue {
+		alCapture.queue.push(fmt.Sprintf("# capture stopped %s\n",
+			inLocation(time.Now()).Format(time.RFC3339)))
+		alCapture.queue = nil
+	}
+} // stopCapture()
+
+/* _EoF_ */
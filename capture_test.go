@@ -0,0 +1,81 @@
+/*
+Copyright © 2024  M.Watermann, 10247 Berlin, Germany
+
+	    All rights reserved
+	EMail : <support@mwat.de>
+*/
+package apachelogger
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+//lint:file-ignore ST1017 – I prefer Yoda conditions
+
+func TestStartCapture(t *testing.T) {
+	name := filepath.Join(t.TempDir(), "capture.log")
+	defer StopCapture()
+
+	if err := StartCapture(name, 1.5, time.Minute); nil == err {
+		t.Error("StartCapture() accepted a rate > 1")
+	}
+	if err := StartCapture(name, 1, 0); nil == err {
+		t.Error("StartCapture() accepted no duration")
+	}
+
+	handler := wrapQueues(http.HandlerFunc(func(aWriter http.ResponseWriter, aRequest *http.Request) {
+		aWriter.Header().Set("Set-Cookie", "session=secret")
+		aWriter.Header().Set("X-Served-By", "test")
+		aWriter.WriteHeader(http.StatusTeapot)
+	}), newRing(8), newRing(8))
+	serve := func(aPath string) {
+		req := httptest.NewRequest(http.MethodGet, "http://example.com"+aPath, nil)
+		req.Header.Set("Authorization", "Basic c2VjcmV0")
+		req.Header.Set("Accept", "text/html")
+		handler.ServeHTTP(httptest.NewRecorder(), req)
+	}
+
+	serve("/before")
+	if err := StartCapture(name, 1, time.Minute); nil != err {
+		t.Fatalf("StartCapture() error = %v", err)
+	}
+	if !Capturing() {
+		t.Error("Capturing() = false, want true")
+	}
+	serve("/during")
+	StopCapture()
+	serve("/after")
+	if Capturing() {
+		t.Error("Capturing() = true after StopCapture()")
+	}
+
+	var got string
+	for deadline := time.Now().Add(5 * time.Second); time.Now().Before(deadline); time.Sleep(10 * time.Millisecond) {
+		if data, _ := os.ReadFile(name); strings.Contains(string(data), "# capture stopped") {
+			got = string(data)
+			break
+		}
+	}
+	for _, want := range []string{
+		`"GET /during HTTP/1.1" 418 0`,
+		"> Host: example.com\n", "> Accept: text/html\n",
+		"> Authorization: [redacted]\n",
+		"< Set-Cookie: [redacted]\n", "< X-Served-By: test\n",
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("capture file lacks %q:\n%s", want, got)
+		}
+	}
+	if strings.Contains(got, "/before") || strings.Contains(got, "/after") ||
+		strings.Contains(got, "secret") {
+		t.Errorf("capture file holds too much:\n%s", got)
+	}
+} // TestStartCapture()
+
+/* _EoF_ */
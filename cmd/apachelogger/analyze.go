@@ -0,0 +1,222 @@
+/*
+Copyright © 2024  M.Watermann, 10247 Berlin, Germany
+
+	    All rights reserved
+	EMail : <support@mwat.de>
+*/
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/mwat56/apachelogger"
+)
+
+//lint:file-ignore ST1017 – I prefer Yoda conditions
+
+type (
+	// `tTally` holds the hits and bytes counted for a single key.
+	tTally struct {
+		key   string // the client, path, referrer, or status
+		hits  int    // number of requests
+		bytes int64  // size of the data sent
+	}
+
+	// `tAnalysis` holds the figures gathered from the access logs.
+	tAnalysis struct {
+		hits      int                // total number of requests
+		bytes     int64              // total size of the data sent
+		unparsed  int                // number of malformed lines
+		first     time.Time          // time of the earliest request
+		last      time.Time          // time of the latest request
+		classes   map[string]*tTally // requests per status class (`2xx` etc.)
+		statuses  map[string]*tTally // requests per status
+		clients   map[string]*tTally // requests per client
+		paths     map[string]*tTally // requests per path (without query)
+		referrers map[string]*tTally // requests per referrer
+	}
+)
+
+// `newAnalysis()` returns an empty analysis.
+//
+// Returns:
+// - `*tAnalysis`: The analysis to fill.
+func newAnalysis() *tAnalysis {
+	return &tAnalysis{
+		classes:   make(map[string]*tTally),
+		statuses:  make(map[string]*tTally),
+		clients:   make(map[string]*tTally),
+		paths:     make(map[string]*tTally),
+		referrers: make(map[string]*tTally),
+	}
+} // newAnalysis()
+
+// `add()` counts the access log entry `aEntry`.
+//
+// Parameters:
+// - `aEntry`: The entry to count.
+func (an *tAnalysis) add(aEntry *apachelogger.TEntry) {
+	count := func(aMap map[string]*tTally, aKey string) {
+		tally, ok := aMap[aKey]
+		if !ok {
+			tally = &tTally{key: aKey}
+			aMap[aKey] = tally
+		}
+		tally.hits++
+		tally.bytes += int64(aEntry.Size)
+	}
+
+	an.hits++
+	an.bytes += int64(aEntry.Size)
+	if an.first.IsZero() || aEntry.When.Before(an.first) {
+		an.first = aEntry.When
+	}
+	if aEntry.When.After(an.last) {
+		an.last = aEntry.When
+	}
+	path := aEntry.Path
+	if idx := strings.IndexByte(path, '?'); 0 <= idx {
+		path = path[:idx]
+	}
+	count(an.classes, fmt.Sprintf("%dxx", aEntry.Status/100))
+	count(an.statuses, strconv.Itoa(aEntry.Status))
+	count(an.clients, aEntry.Remote)
+	count(an.paths, path)
+	if ("" != aEntry.Referrer) && ("-" != aEntry.Referrer) {
+		count(an.referrers, aEntry.Referrer)
+	}
+} // add()
+
+// `write()` prints the analysis to `aWriter`.
+//
+// Parameters:
+// - `aWriter`: The destination of the summary.
+// - `aTop`: The max. number of entries per list.
+func (an *tAnalysis) write(aWriter io.Writer, aTop int) {
+	fmt.Fprintf(aWriter, "Requests: %d  Bytes: %s  Unparsed lines: %d\n",
+		an.hits, humanBytes(an.bytes), an.unparsed)
+	if 0 == an.hits {
+		return
+	}
+	span := an.last.Sub(an.first)
+	fmt.Fprintf(aWriter, "Period:   %s – %s (%v)\n",
+		an.first.Format(time.RFC3339), an.last.Format(time.RFC3339), span)
+	if 0 < span {
+		fmt.Fprintf(aWriter, "Rate:     %.2f requests/sec, %s/sec\n",
+			float64(an.hits)/span.Seconds(),
+			humanBytes(int64(float64(an.bytes)/span.Seconds())))
+	}
+
+	an.writeList(aWriter, "Status classes", an.classes, 0, true)
+	an.writeList(aWriter, "Statuses", an.statuses, aTop, false)
+	an.writeList(aWriter, "Top clients", an.clients, aTop, false)
+	an.writeList(aWriter, "Top paths", an.paths, aTop, false)
+	an.writeList(aWriter, "Top referrers", an.referrers, aTop, false)
+} // write()
+
+// `writeList()` prints the `aTop` largest tallies of `aTallies`.
+//
+// Parameters:
+// - `aWriter`: The destination of the list.
+// - `aTitle`: The list's heading.
+// - `aTallies`: The tallies to print.
+// - `aTop`: The max. number of entries (all if `0`).
+// - `aByKey`: Whether to sort by key instead of hits.
+func (an *tAnalysis) writeList(aWriter io.Writer, aTitle string,
+	aTallies map[string]*tTally, aTop int, aByKey bool) {
+	list := make([]*tTally, 0, len(aTallies))
+	for _, tally := range aTallies {
+		list = append(list, tally)
+	}
+	sort.Slice(list, func(i, j int) bool {
+		if !aByKey && (list[i].hits != list[j].hits) {
+			return list[i].hits > list[j].hits
+		}
+		return list[i].key < list[j].key
+	})
+	if (0 < aTop) && (aTop < len(list)) {
+		list = list[:aTop]
+	}
+
+	fmt.Fprintf(aWriter, "\n%s:\n", aTitle)
+	if 0 == len(list) {
+		fmt.Fprintln(aWriter, "  –")
+		return
+	}
+	for _, tally := range list {
+		fmt.Fprintf(aWriter, "  %8d %5.1f%% %9s  %s\n", tally.hits,
+			float64(tally.hits)*100/float64(an.hits), humanBytes(tally.bytes),
+			tally.key)
+	}
+} // writeList()
+
+// `humanBytes()` returns `aBytes` as a short human readable size.
+//
+// Parameters:
+// - `aBytes`: The size to format.
+//
+// Returns:
+// - `string`: The formatted size, e.g. `1.5M`.
+func humanBytes(aBytes int64) string {
+	const unit = 1024
+	if unit > aBytes {
+		return strconv.FormatInt(aBytes, 10)
+	}
+	size, idx := float64(aBytes)/unit, 0
+	for (unit <= size) && (idx < 4) {
+		size /= unit
+		idx++
+	}
+
+	return fmt.Sprintf("%.1f%c", size, "KMGTP"[idx])
+} // humanBytes()
+
+// `runAnalyze()` implements the `analyze` command.
+//
+// Parameters:
+// - `aArgs`: The command's arguments.
+//
+// Returns:
+// - `int`: The program's exit code.
+func runAnalyze(aArgs []string) int {
+	fs := flag.NewFlagSet("analyze", flag.ExitOnError)
+	binary := fs.Bool("binary", false, "read binary logfiles")
+	top := fs.Int("top", 10, "number of entries per list (0 for all)")
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: %s analyze [options] [files …]\n\n", alProgram)
+		fmt.Fprintln(fs.Output(), "Summarise access logs (or stdin) in Combined, canonical, or JSON format.")
+		fs.PrintDefaults()
+	}
+	_ = fs.Parse(aArgs)
+
+	files := fs.Args()
+	if 0 == len(files) {
+		files = []string{"-"}
+	}
+	result := 0
+	analysis := newAnalysis()
+	for _, name := range files {
+		malformed, err := readEntries(name, *binary, analysis.add)
+		analysis.unparsed += malformed
+		if nil != err {
+			fmt.Fprintf(os.Stderr, "%s: %v\n", name, err)
+			result = 1
+		}
+	}
+
+	out := bufio.NewWriter(os.Stdout)
+	defer out.Flush()
+	analysis.write(out, *top)
+
+	return result
+} // runAnalyze()
+
+/* _EoF_ */
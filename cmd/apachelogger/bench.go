@@ -0,0 +1,214 @@
+/*
+Copyright © 2024  M.Watermann, 10247 Berlin, Germany
+
+	    All rights reserved
+	EMail : <support@mwat.de>
+*/
+package main
+
+import (
+	"bytes"
+	"context"
+	"flag"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/mwat56/apachelogger"
+)
+
+//lint:file-ignore ST1017 – I prefer Yoda conditions
+
+type (
+	// `tCountingSink` counts the data written to a sink.
+	tCountingSink struct {
+		apachelogger.TSink
+		bytes   int64 // bytes written (accessed atomically)
+		lines   int64 // text lines written (accessed atomically)
+		batches int64 // number of writes (accessed atomically)
+	}
+)
+
+// `Write()` counts and writes `aData` to the sink.
+//
+// Part of the `TSink` interface.
+//
+// Parameters:
+// - `aData`: The log entries to write.
+//
+// Returns:
+// - `error`: A possible error while writing.
+func (cs *tCountingSink) Write(aData []byte) error {
+	atomic.AddInt64(&cs.bytes, int64(len(aData)))
+	atomic.AddInt64(&cs.lines, int64(bytes.Count(aData, []byte{'\n'})))
+	atomic.AddInt64(&cs.batches, 1)
+
+	return cs.TSink.Write(aData)
+} // Write()
+
+// `benchSink()` returns the sink named `aKind`.
+//
+// Parameters:
+// - `aKind`: The kind of sink (`discard`, `file`, `gzip`, or `uring`).
+// - `aFilename`: The logfile's name.
+//
+// Returns:
+// - `apachelogger.TSink`: The sink to write to.
+// - `error`: An error for an unknown kind.
+func benchSink(aKind, aFilename string) (apachelogger.TSink, error) {
+	switch aKind {
+	case "discard":
+		return apachelogger.Discard, nil
+	case "file":
+		return apachelogger.NewFileSink(aFilename), nil
+	case "gzip":
+		return apachelogger.NewGzipFileSink(aFilename, time.Second), nil
+	case "uring":
+		return apachelogger.NewURingFileSink(aFilename), nil
+	}
+
+	return nil, fmt.Errorf("unknown sink %q", aKind)
+} // benchSink()
+
+// `runBench()` implements the `bench` command.
+//
+// Parameters:
+// - `aArgs`: The command's arguments.
+//
+// Returns:
+// - `int`: The program's exit code.
+func runBench(aArgs []string) int {
+	fs := flag.NewFlagSet("bench", flag.ExitOnError)
+	binary := fs.Bool("binary", false, "write binary log records")
+	duration := fs.Duration("duration", 5*time.Second, "time to generate requests")
+	file := fs.String("file", "", "logfile to write (default: a temporary file)")
+	maxSlots := fs.Int("max", 0, "max. queue slots per shard (0: fixed size)")
+	minSlots := fs.Int("min", 0, "min. queue slots per shard")
+	server := fs.Bool("http", false, "send the requests through a local HTTP server")
+	shards := fs.Int("shards", 1, "number of access queue shards (0: one per CPU)")
+	sinkKind := fs.String("sink", "file", "sink to write to: discard, file, gzip, or uring")
+	workers := fs.Int("workers", runtime.GOMAXPROCS(0), "number of concurrent request generators")
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: %s bench [options]\n\n", alProgram)
+		fmt.Fprintln(fs.Output(), "Measure the logging pipeline's throughput with the given configuration.")
+		fs.PrintDefaults()
+	}
+	_ = fs.Parse(aArgs)
+
+	if "" == *file {
+		dir, err := os.MkdirTemp("", "apachelogger-bench")
+		if nil != err {
+			fmt.Fprintf(os.Stderr, "%s bench: %v\n", alProgram, err)
+			return 1
+		}
+		defer os.RemoveAll(dir)
+		*file = filepath.Join(dir, "access.log")
+	}
+	sink, err := benchSink(*sinkKind, *file)
+	if nil != err {
+		fmt.Fprintf(os.Stderr, "%s bench: %v\n", alProgram, err)
+		return 2
+	}
+	if 1 > *workers {
+		*workers = 1
+	}
+	counter := &tCountingSink{TSink: sink}
+	apachelogger.BinaryLog = *binary
+	apachelogger.SetQueueShards(*shards)
+	if 0 < *maxSlots {
+		apachelogger.SetQueueBounds(*minSlots, *maxSlots)
+	}
+	body := []byte("Hello, world!\n")
+	handler := apachelogger.WrapSinks(http.HandlerFunc(
+		func(aWriter http.ResponseWriter, aRequest *http.Request) {
+			_, _ = aWriter.Write(body)
+		}), counter, apachelogger.Discard)
+
+	// `request()` sends a single request through the wrapped handler:
+	request := func(aWorker int) {
+		req := httptest.NewRequest(http.MethodGet, "/bench/path?worker=1", nil)
+		req.RemoteAddr = fmt.Sprintf("192.0.2.%d:4711", aWorker%250+1)
+		req.Header.Set("User-Agent", "apachelogger-bench")
+		handler.ServeHTTP(httptest.NewRecorder(), req)
+	}
+	if *server {
+		ts := httptest.NewServer(handler)
+		defer ts.Close()
+		client := ts.Client()
+		client.Transport.(*http.Transport).MaxIdleConnsPerHost = *workers
+		request = func(int) {
+			resp, err := client.Get(ts.URL + "/bench/path?worker=1")
+			if nil == err {
+				_, _ = resp.Body.Read(make([]byte, len(body)))
+				resp.Body.Close()
+			}
+		}
+	}
+
+	var (
+		before, after runtime.MemStats
+		requests      int64
+		stop          int32
+		wg            sync.WaitGroup
+	)
+	runtime.GC()
+	runtime.ReadMemStats(&before)
+	start := time.Now()
+	for worker := 0; worker < *workers; worker++ {
+		wg.Add(1)
+		go func(aWorker int) {
+			defer wg.Done()
+			for 0 == atomic.LoadInt32(&stop) {
+				request(aWorker)
+				atomic.AddInt64(&requests, 1)
+			}
+		}(worker)
+	}
+	time.Sleep(*duration)
+	atomic.StoreInt32(&stop, 1)
+	wg.Wait()
+	generated := time.Since(start)
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+	if err = apachelogger.Flush(ctx); nil != err {
+		fmt.Fprintf(os.Stderr, "%s bench: %v\n", alProgram, err)
+	}
+	elapsed := time.Since(start)
+	runtime.ReadMemStats(&after)
+
+	stats := apachelogger.Stats()
+	health := apachelogger.Health()
+	written := atomic.LoadInt64(&counter.lines)
+	if *binary { // records aren't separated by newlines
+		written = requests - int64(stats.Access.Length)
+	}
+	lost := requests - written
+	if 0 > lost {
+		lost = 0
+	}
+	fmt.Printf("Configuration: sink=%s workers=%d shards=%d slots=%d-%d binary=%v http=%v\n",
+		*sinkKind, *workers, *shards, *minSlots, *maxSlots, *binary, *server)
+	fmt.Printf("Requests:      %d in %v (%.0f/sec)\n",
+		requests, generated.Round(time.Millisecond), float64(requests)/generated.Seconds())
+	fmt.Printf("Written:       %d entries, %s in %d batches (%.0f entries/sec incl. flush)\n",
+		written, humanBytes(atomic.LoadInt64(&counter.bytes)),
+		atomic.LoadInt64(&counter.batches), float64(written)/elapsed.Seconds())
+	fmt.Printf("Allocations:   %.1f per request, %s per request\n",
+		float64(after.Mallocs-before.Mallocs)/float64(requests),
+		humanBytes(int64(after.TotalAlloc-before.TotalAlloc)/requests))
+	fmt.Printf("Drops:         %d lost (%.3f%%), %d rejected by the closed queue, %d write errors\n",
+		lost, float64(lost)*100/float64(requests), health.Access.Dropped,
+		health.Access.WriteErrors)
+	fmt.Printf("Queue:         %s\n", stats.Access)
+
+	return 0
+} // runBench()
+
+/* _EoF_ */
@@ -0,0 +1,257 @@
+/*
+Copyright © 2024  M.Watermann, 10247 Berlin, Germany
+
+	    All rights reserved
+	EMail : <support@mwat.de>
+*/
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/mwat56/apachelogger"
+)
+
+//lint:file-ignore ST1017 – I prefer Yoda conditions
+
+type (
+	// `tCheck` holds the findings of checking a logfile.
+	tCheck struct {
+		name       string        // the logfile's name
+		maxReports int           // max. number of problems to print
+		strict     bool          // accept only lines as written by default
+		tolerance  time.Duration // allowed backward jump of the access time
+		reports    int           // number of problems printed
+		lines      int           // number of lines read
+		entries    int           // number of valid entries
+		blank      int           // number of empty lines
+		comments   int           // number of comment lines
+		markers    int           // number of day markers
+		malformed  int           // number of malformed lines
+		unordered  int           // number of out-of-order entries
+		truncated  bool          // the last line (or record) is incomplete
+		first      time.Time     // time of the earliest entry
+		last       time.Time     // time of the latest entry
+	}
+)
+
+// `report()` prints a problem found in line `aLine`.
+//
+// Parameters:
+// - `aWriter`: The destination of the report.
+// - `aLine`: The number of the line (`0` for binary logs).
+// - `aFormat`: The problem's description.
+// - `aArgs`: The description's arguments.
+func (ck *tCheck) report(aWriter io.Writer, aLine int, aFormat string, aArgs ...interface{}) {
+	ck.reports++
+	if (0 < ck.maxReports) && (ck.maxReports < ck.reports) {
+		return
+	}
+	if 0 < aLine {
+		fmt.Fprintf(aWriter, "%s:%d: %s\n", ck.name, aLine, fmt.Sprintf(aFormat, aArgs...))
+	} else {
+		fmt.Fprintf(aWriter, "%s: %s\n", ck.name, fmt.Sprintf(aFormat, aArgs...))
+	}
+} // report()
+
+// `entry()` checks the order of the valid entry `aEntry`.
+//
+// Parameters:
+// - `aWriter`: The destination of the reports.
+// - `aLine`: The number of the entry's line (`0` for binary logs).
+// - `aEntry`: The entry read.
+func (ck *tCheck) entry(aWriter io.Writer, aLine int, aEntry *apachelogger.TEntry) {
+	if isDayMarker(aEntry) {
+		ck.markers++
+		return
+	}
+	ck.entries++
+	if ck.first.IsZero() || aEntry.When.Before(ck.first) {
+		ck.first = aEntry.When
+	}
+	if !ck.last.IsZero() && (ck.tolerance < ck.last.Sub(aEntry.When)) {
+		ck.unordered++
+		ck.report(aWriter, aLine, "out of order: %s is %v before %s",
+			aEntry.When.Format(time.RFC3339), ck.last.Sub(aEntry.When),
+			ck.last.Format(time.RFC3339))
+	}
+	if aEntry.When.After(ck.last) {
+		ck.last = aEntry.When
+	}
+} // entry()
+
+// `readBinary()` checks the binary logfile `aReader`.
+//
+// Parameters:
+// - `aReader`: The logfile to check.
+// - `aWriter`: The destination of the reports.
+//
+// Returns:
+// - `error`: A possible error reading the logfile.
+func (ck *tCheck) readBinary(aReader io.Reader, aWriter io.Writer) error {
+	br := apachelogger.NewBinaryReader(aReader)
+	for {
+		entry, err := br.Next()
+		if nil != err {
+			if io.EOF == err {
+				return nil
+			}
+			// records have no separators, so reading can't continue:
+			ck.truncated = true
+			ck.report(aWriter, 0, "record %d: %v", ck.lines+1, err)
+			return nil
+		}
+		ck.lines++
+		ck.entry(aWriter, 0, entry)
+	}
+} // readBinary()
+
+// `readText()` checks the textual logfile `aReader`.
+//
+// Parameters:
+// - `aReader`: The logfile to check.
+// - `aWriter`: The destination of the reports.
+//
+// Returns:
+// - `error`: A possible error reading the logfile.
+func (ck *tCheck) readText(aReader io.Reader, aWriter io.Writer) error {
+	br := bufio.NewReader(aReader)
+	for {
+		line, err := br.ReadString('\n')
+		if "" == line {
+			if io.EOF == err {
+				return nil
+			}
+			return err
+		}
+		ck.lines++
+		if !strings.HasSuffix(line, "\n") {
+			ck.truncated = true
+			ck.report(aWriter, ck.lines, "truncated line (no trailing newline)")
+		}
+		line = strings.TrimRight(line, "\r\n")
+
+		switch trimmed := strings.TrimSpace(line); {
+		case "" == trimmed:
+			ck.blank++
+		case strings.HasPrefix(trimmed, "#"):
+			ck.comments++
+		default:
+			entry, perr := apachelogger.ParseLine(line)
+			if nil == perr && ck.strict &&
+				!strings.HasPrefix(line, strings.TrimSuffix(entry.String(), "\n")) {
+				perr = fmt.Errorf("not in Combined Log Format")
+			}
+			if nil != perr {
+				ck.malformed++
+				ck.report(aWriter, ck.lines, "%v", perr)
+				break
+			}
+			ck.entry(aWriter, ck.lines, entry)
+		}
+		if nil != err {
+			if io.EOF == err {
+				return nil
+			}
+			return err
+		}
+	}
+} // readText()
+
+// `problems()` returns the number of problems found.
+//
+// Returns:
+// - `int`: The number of malformed, out-of-order, and truncated lines.
+func (ck *tCheck) problems() int {
+	result := ck.malformed + ck.unordered
+	if ck.truncated {
+		result++
+	}
+
+	return result
+} // problems()
+
+// `write()` prints the statistics of the checked logfile.
+//
+// Parameters:
+// - `aWriter`: The destination of the statistics.
+func (ck *tCheck) write(aWriter io.Writer) {
+	if (0 < ck.maxReports) && (ck.maxReports < ck.reports) {
+		fmt.Fprintf(aWriter, "%s: … %d more problems\n", ck.name, ck.reports-ck.maxReports)
+	}
+	fmt.Fprintf(aWriter, "%s: lines=%d entries=%d blank=%d comments=%d day_markers=%d malformed=%d out_of_order=%d truncated=%v",
+		ck.name, ck.lines, ck.entries, ck.blank, ck.comments, ck.markers,
+		ck.malformed, ck.unordered, ck.truncated)
+	if 0 < ck.entries {
+		fmt.Fprintf(aWriter, " first=%s last=%s",
+			ck.first.Format(time.RFC3339), ck.last.Format(time.RFC3339))
+	}
+	fmt.Fprintln(aWriter)
+} // write()
+
+// `runCheck()` implements the `check` command.
+//
+// Parameters:
+// - `aArgs`: The command's arguments.
+//
+// Returns:
+// - `int`: The program's exit code (`1` if any problem was found).
+func runCheck(aArgs []string) int {
+	fs := flag.NewFlagSet("check", flag.ExitOnError)
+	binary := fs.Bool("binary", false, "check binary logfiles")
+	maxReports := fs.Int("max", 20, "max. number of problems printed per file (0 for all)")
+	strict := fs.Bool("strict", false, "accept only the Combined Log Format as written by default")
+	tolerance := fs.Duration("tolerance", time.Minute,
+		"allowed backward jump of the access time (entries are written when the request ends)")
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: %s check [options] [files …]\n\n", alProgram)
+		fmt.Fprintln(fs.Output(), "Scan access logs (or stdin) for malformed, truncated, or out-of-order lines.")
+		fs.PrintDefaults()
+	}
+	_ = fs.Parse(aArgs)
+
+	files := fs.Args()
+	if 0 == len(files) {
+		files = []string{"-"}
+	}
+	out := bufio.NewWriter(os.Stdout)
+	defer out.Flush()
+
+	result := 0
+	for _, name := range files {
+		ck := &tCheck{
+			name:       name,
+			maxReports: *maxReports,
+			strict:     *strict,
+			tolerance:  *tolerance,
+		}
+		file, err := openLog(name)
+		if nil == err {
+			if *binary {
+				err = ck.readBinary(file, out)
+			} else {
+				err = ck.readText(file, out)
+			}
+			file.Close()
+		}
+		if nil != err {
+			out.Flush()
+			fmt.Fprintf(os.Stderr, "%s: %v\n", name, err)
+			result = 1
+		}
+		ck.write(out)
+		if 0 < ck.problems() {
+			result = 1
+		}
+	}
+
+	return result
+} // runCheck()
+
+/* _EoF_ */
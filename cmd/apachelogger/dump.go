@@ -0,0 +1,122 @@
+/*
+Copyright © 2024  M.Watermann, 10247 Berlin, Germany
+
+	    All rights reserved
+	EMail : <support@mwat.de>
+*/
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/mwat56/apachelogger"
+)
+
+//lint:file-ignore ST1017 – I prefer Yoda conditions
+
+// `dumpFile()` writes the entries of the binary logfile `aReader` to
+// `aWriter`.
+//
+// Parameters:
+// - `aReader`: The binary logfile to read.
+// - `aWriter`: The destination of the text lines.
+// - `aCanonical`: Whether to write canonical log lines.
+//
+// Returns:
+// - `error`: A possible error reading the logfile.
+func dumpFile(aReader io.Reader, aWriter io.Writer, aCanonical bool) error {
+	br := apachelogger.NewBinaryReader(aReader)
+	for {
+		entry, err := br.Next()
+		if nil != err {
+			if io.EOF == err {
+				return nil
+			}
+			return err
+		}
+		writeText(aWriter, entry, aCanonical)
+	}
+} // dumpFile()
+
+// `runDump()` implements the `dump` command.
+//
+// Parameters:
+// - `aArgs`: The command's arguments.
+//
+// Returns:
+// - `int`: The program's exit code.
+func runDump(aArgs []string) int {
+	fs := flag.NewFlagSet("dump", flag.ExitOnError)
+	canonical := fs.Bool("canonical", false,
+		"write canonical log lines instead of combined format")
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: %s dump [options] [files …]\n\n", alProgram)
+		fmt.Fprintln(fs.Output(), "Print binary logfiles (or stdin) as text lines.")
+		fs.PrintDefaults()
+	}
+	_ = fs.Parse(aArgs)
+
+	out := bufio.NewWriter(os.Stdout)
+	defer out.Flush()
+
+	files := fs.Args()
+	if 0 == len(files) {
+		files = []string{"-"}
+	}
+	result := 0
+	for _, name := range files {
+		if err := dumpNamed(name, out, *canonical); nil != err {
+			fmt.Fprintf(os.Stderr, "%s: %v\n", name, err)
+			result = 1
+		}
+	}
+
+	return result
+} // runDump()
+
+// `dumpNamed()` dumps the binary logfile `aName` (`-` for stdin).
+//
+// Parameters:
+// - `aName`: The name of the logfile to read.
+// - `aWriter`: The destination of the text lines.
+// - `aCanonical`: Whether to write canonical log lines.
+//
+// Returns:
+// - `error`: A possible error reading the logfile.
+func dumpNamed(aName string, aWriter io.Writer, aCanonical bool) error {
+	if "-" == aName {
+		return dumpFile(os.Stdin, aWriter, aCanonical)
+	}
+	file, err := os.Open(aName) // #nosec G304
+	if nil != err {
+		return err
+	}
+	defer file.Close()
+
+	return dumpFile(file, aWriter, aCanonical)
+} // dumpNamed()
+
+// `writeText()` writes `aEntry` as a text line to `aWriter`.
+//
+// Parameters:
+// - `aWriter`: The destination of the text line.
+// - `aEntry`: The log entry to write.
+// - `aCanonical`: Whether to write a canonical log line.
+func writeText(aWriter io.Writer, aEntry *apachelogger.TEntry, aCanonical bool) {
+	if aCanonical {
+		fmt.Fprint(aWriter, aEntry.Canonical())
+		return
+	}
+	fmt.Fprint(aWriter, strings.TrimSuffix(aEntry.String(), "\n"))
+	for _, key := range sortedKeys(aEntry.Fields) {
+		fmt.Fprintf(aWriter, " %s=%q", key, aEntry.Fields[key])
+	}
+	fmt.Fprintln(aWriter)
+} // writeText()
+
+/* _EoF_ */
@@ -0,0 +1,80 @@
+/*
+Copyright © 2024  M.Watermann, 10247 Berlin, Germany
+
+	    All rights reserved
+	EMail : <support@mwat.de>
+*/
+
+// `apachelogger` is a companion tool working with the logfiles written
+// by the `apachelogger` package.
+//
+// Usage:
+//
+//	apachelogger <command> [options] [files …]
+//
+// Commands:
+//
+//	analyze summarise access logs (hits, bytes, statuses, top lists)
+//	bench   measure the logging pipeline's throughput
+//	check   scan logfiles for malformed, truncated, or out-of-order lines
+//	dump    print binary logfiles as text lines
+//	merge   merge logfiles into a single sorted stream
+//	replay  re-issue logged requests against a server
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+//lint:file-ignore ST1017 – I prefer Yoda conditions
+
+type (
+	// `tCommand` is a subcommand of the tool.
+	tCommand struct {
+		run   func(aArgs []string) int // the command's implementation
+		usage string                   // short description of the command
+	}
+)
+
+var (
+	// The program's name used in messages.
+	alProgram = filepath.Base(os.Args[0])
+
+	// The available subcommands, by name.
+	alCommands = map[string]tCommand{
+		"analyze": {runAnalyze, "summarise access logs (hits, bytes, statuses, top lists)"},
+		"bench":   {runBench, "measure the logging pipeline's throughput"},
+		"check":   {runCheck, "scan logfiles for malformed, truncated, or out-of-order lines"},
+		"dump":    {runDump, "print binary logfiles as text lines"},
+		"merge":   {runMerge, "merge logfiles into a single sorted stream"},
+		"replay":  {runReplay, "re-issue logged requests against a server"},
+	}
+)
+
+// `usage()` prints the tool's usage message to `os.Stderr`.
+func usage() {
+	fmt.Fprintf(os.Stderr, "Usage: %s <command> [options] [files …]\n\nCommands:\n", alProgram)
+	for _, name := range []string{"analyze", "bench", "check", "dump", "merge", "replay"} {
+		fmt.Fprintf(os.Stderr, "  %-8s%s\n", name, alCommands[name].usage)
+	}
+	fmt.Fprintf(os.Stderr, "\nUse \"%s <command> -h\" for the command's options.\n", alProgram)
+} // usage()
+
+func main() {
+	if 2 > len(os.Args) {
+		usage()
+		os.Exit(2)
+	}
+	cmd, ok := alCommands[os.Args[1]]
+	if !ok {
+		fmt.Fprintf(os.Stderr, "%s: unknown command %q\n\n", alProgram, os.Args[1])
+		usage()
+		os.Exit(2)
+	}
+
+	os.Exit(cmd.run(os.Args[2:]))
+} // main()
+
+/* _EoF_ */
@@ -0,0 +1,225 @@
+/*
+Copyright © 2024  M.Watermann, 10247 Berlin, Germany
+
+	    All rights reserved
+	EMail : <support@mwat.de>
+*/
+package main
+
+import (
+	"bufio"
+	"container/heap"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"github.com/mwat56/apachelogger"
+)
+
+//lint:file-ignore ST1017 – I prefer Yoda conditions
+
+type (
+	// `tMergeSource` is a logfile taking part in the merge.
+	tMergeSource struct {
+		name  string                               // the logfile's name
+		index int                                  // the logfile's position on the command line
+		file  io.ReadCloser                        // the opened logfile
+		next  func() (*apachelogger.TEntry, error) // reads the next entry
+		text  *apachelogger.TTextReader            // the text reader (`nil` for binary logs)
+		entry *apachelogger.TEntry                 // the current entry
+		line  string                               // the current entry's text line
+	}
+
+	// `tMergeHeap` orders the logfiles by the time of their current
+	// entry.
+	tMergeHeap []*tMergeSource
+)
+
+// `Len()` is part of the `heap.Interface`.
+func (mh tMergeHeap) Len() int { return len(mh) }
+
+// `Less()` is part of the `heap.Interface`.
+func (mh tMergeHeap) Less(i, j int) bool {
+	if !mh[i].entry.When.Equal(mh[j].entry.When) {
+		return mh[i].entry.When.Before(mh[j].entry.When)
+	}
+
+	return mh[i].index < mh[j].index
+} // Less()
+
+// `Swap()` is part of the `heap.Interface`.
+func (mh tMergeHeap) Swap(i, j int) { mh[i], mh[j] = mh[j], mh[i] }
+
+// `Push()` is part of the `heap.Interface`.
+func (mh *tMergeHeap) Push(aSource interface{}) {
+	*mh = append(*mh, aSource.(*tMergeSource))
+} // Push()
+
+// `Pop()` is part of the `heap.Interface`.
+func (mh *tMergeHeap) Pop() interface{} {
+	last := len(*mh) - 1
+	result := (*mh)[last]
+	*mh = (*mh)[:last]
+
+	return result
+} // Pop()
+
+// `isDayMarker()` reports whether `aEntry` is a day separator written
+// by the `apachelogger` package (see `DayChangeMarker`).
+//
+// Parameters:
+// - `aEntry`: The entry to check.
+//
+// Returns:
+// - `bool`: `true` if `aEntry` marks a new day.
+func isDayMarker(aEntry *apachelogger.TEntry) bool {
+	return ("DAY" == aEntry.Method) && ("mwat56/apachelogger" == aEntry.Agent)
+} // isDayMarker()
+
+// `openSource()` opens the logfile `aName` for merging.
+//
+// Parameters:
+// - `aName`: The name of the logfile (`-` for stdin).
+// - `aIndex`: The logfile's position on the command line.
+// - `aBinary`: Whether the logfile is in binary format.
+//
+// Returns:
+// - `*tMergeSource`: The opened logfile.
+// - `error`: A possible error opening the logfile.
+func openSource(aName string, aIndex int, aBinary bool) (*tMergeSource, error) {
+	file, err := openLog(aName)
+	if nil != err {
+		return nil, err
+	}
+	result := &tMergeSource{name: aName, index: aIndex, file: file}
+	if aBinary {
+		result.next = apachelogger.NewBinaryReader(file).Next
+	} else {
+		result.text = apachelogger.NewTextReader(file)
+		result.next = result.text.Next
+	}
+
+	return result, nil
+} // openSource()
+
+// `advance()` reads the next entry, skipping day markers.
+//
+// Returns:
+// - `error`: `io.EOF` at the end of the logfile, or a reading error.
+func (ms *tMergeSource) advance() error {
+	for {
+		entry, err := ms.next()
+		if nil != err {
+			ms.entry = nil
+			return err
+		}
+		if isDayMarker(entry) {
+			continue
+		}
+		ms.entry = entry
+		if nil != ms.text {
+			ms.line = ms.text.Line()
+		}
+		return nil
+	}
+} // advance()
+
+// `runMerge()` implements the `merge` command.
+//
+// Parameters:
+// - `aArgs`: The command's arguments.
+//
+// Returns:
+// - `int`: The program's exit code.
+func runMerge(aArgs []string) int {
+	fs := flag.NewFlagSet("merge", flag.ExitOnError)
+	binary := fs.Bool("binary", false, "read binary logfiles")
+	separate := fs.Bool("separate", false, "insert a blank line whenever the day changes")
+	zone := fs.String("tz", "", "rewrite the entries in this time zone (e.g. UTC or Local)")
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: %s merge [options] files …\n\n", alProgram)
+		fmt.Fprintln(fs.Output(), "Merge (possibly gzipped) access logs into a single chronologically sorted stream.")
+		fs.PrintDefaults()
+	}
+	_ = fs.Parse(aArgs)
+
+	var location *time.Location
+	if "" != *zone {
+		var err error
+		if location, err = time.LoadLocation(*zone); nil != err {
+			fmt.Fprintf(os.Stderr, "%s merge: %v\n", alProgram, err)
+			return 2
+		}
+	}
+	files := fs.Args()
+	if 0 == len(files) {
+		files = []string{"-"}
+	}
+
+	result := 0
+	sources := make(tMergeHeap, 0, len(files))
+	for idx, name := range files {
+		source, err := openSource(name, idx, *binary)
+		if nil == err {
+			defer source.file.Close()
+			if err = source.advance(); nil == err {
+				sources = append(sources, source)
+				continue
+			}
+		}
+		if io.EOF != err {
+			fmt.Fprintf(os.Stderr, "%s: %v\n", name, err)
+			result = 1
+		}
+	}
+	heap.Init(&sources)
+
+	out := bufio.NewWriter(os.Stdout)
+	defer out.Flush()
+	var (
+		lastDay   string
+		malformed int
+	)
+	for 0 < len(sources) {
+		source := sources[0]
+		entry := source.entry
+		if nil != location {
+			entry.When = entry.When.In(location)
+		}
+		if *separate {
+			if day := entry.When.Format("2006-01-02"); day != lastDay {
+				if "" != lastDay {
+					fmt.Fprintln(out)
+				}
+				lastDay = day
+			}
+		}
+		if (nil == source.text) || (nil != location) {
+			writeText(out, entry, false)
+		} else {
+			fmt.Fprintln(out, source.line)
+		}
+
+		if err := source.advance(); nil != err {
+			if io.EOF != err {
+				fmt.Fprintf(os.Stderr, "%s: %v\n", source.name, err)
+				result = 1
+			}
+			if nil != source.text {
+				malformed += source.text.Malformed()
+			}
+			heap.Pop(&sources)
+			continue
+		}
+		heap.Fix(&sources, 0)
+	}
+	if 0 < malformed {
+		fmt.Fprintf(os.Stderr, "%s merge: %d malformed lines skipped\n", alProgram, malformed)
+	}
+
+	return result
+} // runMerge()
+
+/* _EoF_ */
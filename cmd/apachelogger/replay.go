@@ -0,0 +1,194 @@
+/*
+Copyright © 2024  M.Watermann, 10247 Berlin, Germany
+
+	    All rights reserved
+	EMail : <support@mwat.de>
+*/
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/mwat56/apachelogger"
+)
+
+//lint:file-ignore ST1017 – I prefer Yoda conditions
+
+type (
+	// `tReplay` re-issues logged requests against a target server.
+	tReplay struct {
+		agent    bool            // send the logged user agents
+		client   *http.Client    // the client sending the requests
+		methods  map[string]bool // the methods to replay
+		speed    float64         // speed factor (`0` for no delays)
+		target   *url.URL        // the base URL of the target server
+		first    time.Time       // access time of the first request
+		start    time.Time       // time the replay started
+		slots    chan struct{}   // limits the concurrent requests
+		wg       sync.WaitGroup  // waits for the running requests
+		sent     int64           // number of requests sent (accessed atomically)
+		failed   int64           // number of failed requests (accessed atomically)
+		mismatch int64           // number of differing statuses (accessed atomically)
+		skipped  int64           // number of entries not replayed (accessed atomically)
+	}
+)
+
+// `replayEntry()` sends the request logged by `aEntry` after waiting
+// for its (scaled) offset from the first request.
+//
+// Parameters:
+// - `aEntry`: The logged request.
+func (rp *tReplay) replayEntry(aEntry *apachelogger.TEntry) {
+	if !rp.methods[strings.ToUpper(aEntry.Method)] ||
+		!strings.HasPrefix(aEntry.Path, "/") {
+		atomic.AddInt64(&rp.skipped, 1)
+		return
+	}
+	if rp.first.IsZero() {
+		rp.first, rp.start = aEntry.When, time.Now()
+	}
+	if 0 < rp.speed {
+		offset := time.Duration(float64(aEntry.When.Sub(rp.first)) / rp.speed)
+		if wait := time.Until(rp.start.Add(offset)); 0 < wait {
+			time.Sleep(wait)
+		}
+	}
+
+	rp.slots <- struct{}{}
+	rp.wg.Add(1)
+	go func() {
+		defer func() {
+			<-rp.slots
+			rp.wg.Done()
+		}()
+		rp.send(aEntry)
+	}()
+} // replayEntry()
+
+// `send()` sends the request logged by `aEntry` to the target.
+//
+// Parameters:
+// - `aEntry`: The logged request.
+func (rp *tReplay) send(aEntry *apachelogger.TEntry) {
+	atomic.AddInt64(&rp.sent, 1)
+	ref, err := url.Parse(aEntry.Path)
+	if nil != err {
+		atomic.AddInt64(&rp.failed, 1)
+		return
+	}
+	target := *rp.target
+	target.Path = strings.TrimSuffix(target.Path, "/") + ref.Path
+	target.RawPath = ""
+	target.RawQuery = ref.RawQuery
+
+	req, err := http.NewRequest(aEntry.Method, target.String(), nil)
+	if nil != err {
+		atomic.AddInt64(&rp.failed, 1)
+		return
+	}
+	if rp.agent && ("" != aEntry.Agent) && ("-" != aEntry.Agent) {
+		req.Header.Set("User-Agent", aEntry.Agent)
+	}
+	if ("" != aEntry.Referrer) && ("-" != aEntry.Referrer) {
+		req.Header.Set("Referer", aEntry.Referrer)
+	}
+	resp, err := rp.client.Do(req)
+	if nil != err {
+		atomic.AddInt64(&rp.failed, 1)
+		fmt.Fprintf(os.Stderr, "%s %s: %v\n", aEntry.Method, aEntry.Path, err)
+		return
+	}
+	_, _ = io.Copy(io.Discard, resp.Body)
+	resp.Body.Close()
+	if (0 < aEntry.Status) && (resp.StatusCode != aEntry.Status) {
+		atomic.AddInt64(&rp.mismatch, 1)
+		fmt.Fprintf(os.Stderr, "%s %s: status %d, logged %d\n",
+			aEntry.Method, aEntry.Path, resp.StatusCode, aEntry.Status)
+	}
+} // send()
+
+// `runReplay()` implements the `replay` command.
+//
+// Parameters:
+// - `aArgs`: The command's arguments.
+//
+// Returns:
+// - `int`: The program's exit code.
+func runReplay(aArgs []string) int {
+	fs := flag.NewFlagSet("replay", flag.ExitOnError)
+	agent := fs.Bool("agent", true, "send the logged user agents")
+	binary := fs.Bool("binary", false, "read binary logfiles")
+	concurrency := fs.Int("concurrency", 16, "max. number of concurrent requests")
+	methods := fs.String("methods", "GET,HEAD", "comma separated list of the methods to replay")
+	speed := fs.Float64("speed", 1, "speed factor (2 = twice as fast, 0 = no delays)")
+	target := fs.String("target", "", "base URL of the server to send the requests to (required)")
+	timeout := fs.Duration("timeout", 30*time.Second, "timeout of a single request")
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: %s replay -target URL [options] [files …]\n\n", alProgram)
+		fmt.Fprintln(fs.Output(), "Re-issue the requests of access logs (or stdin) against a server.")
+		fs.PrintDefaults()
+	}
+	_ = fs.Parse(aArgs)
+
+	base, err := url.Parse(*target)
+	if (nil != err) || ("" == base.Scheme) || ("" == base.Host) {
+		fmt.Fprintf(os.Stderr, "%s replay: invalid -target %q\n", alProgram, *target)
+		fs.Usage()
+		return 2
+	}
+	if 1 > *concurrency {
+		*concurrency = 1
+	}
+	rp := &tReplay{
+		agent: *agent,
+		client: &http.Client{
+			CheckRedirect: func(*http.Request, []*http.Request) error {
+				return http.ErrUseLastResponse // compare the logged status
+			},
+			Timeout: *timeout,
+		},
+		methods: make(map[string]bool),
+		speed:   *speed,
+		target:  base,
+		start:   time.Now(),
+		slots:   make(chan struct{}, *concurrency),
+	}
+	for _, method := range strings.Split(*methods, ",") {
+		if method = strings.TrimSpace(method); "" != method {
+			rp.methods[strings.ToUpper(method)] = true
+		}
+	}
+
+	files := fs.Args()
+	if 0 == len(files) {
+		files = []string{"-"}
+	}
+	result := 0
+	for _, name := range files {
+		if _, err = readEntries(name, *binary, rp.replayEntry); nil != err {
+			fmt.Fprintf(os.Stderr, "%s: %v\n", name, err)
+			result = 1
+		}
+	}
+	rp.wg.Wait()
+
+	fmt.Printf("Sent: %d  Failed: %d  Status mismatches: %d  Skipped: %d  Time: %v\n",
+		rp.sent, rp.failed, rp.mismatch, rp.skipped,
+		time.Since(rp.start).Round(time.Millisecond))
+	if (0 == result) && (0 < rp.failed) {
+		result = 1
+	}
+
+	return result
+} // runReplay()
+
+/* _EoF_ */
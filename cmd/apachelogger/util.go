@@ -0,0 +1,138 @@
+/*
+Copyright © 2024  M.Watermann, 10247 Berlin, Germany
+
+	    All rights reserved
+	EMail : <support@mwat.de>
+*/
+package main
+
+import (
+	"bufio"
+	"compress/gzip"
+	"io"
+	"os"
+	"sort"
+
+	"github.com/mwat56/apachelogger"
+)
+
+type (
+	// `tLogFile` is an opened (and possibly decompressed) logfile.
+	tLogFile struct {
+		io.Reader
+		closers []io.Closer // the resources to release
+	}
+)
+
+// `Close()` releases the logfile's resources.
+//
+// Returns:
+// - `error`: A possible error while closing.
+func (lf *tLogFile) Close() (rErr error) {
+	for idx := len(lf.closers) - 1; 0 <= idx; idx-- {
+		if err := lf.closers[idx].Close(); nil == rErr {
+			rErr = err
+		}
+	}
+
+	return
+} // Close()
+
+// `openLog()` opens the logfile `aName` (`-` for stdin), decompressing
+// it if it's gzipped (e.g. after a rotation).
+//
+// Parameters:
+// - `aName`: The name of the logfile to read.
+//
+// Returns:
+// - `io.ReadCloser`: The logfile's (uncompressed) contents.
+// - `error`: A possible error opening the logfile.
+func openLog(aName string) (io.ReadCloser, error) {
+	result := &tLogFile{Reader: os.Stdin}
+	if "-" != aName {
+		file, err := os.Open(aName) // #nosec G304
+		if nil != err {
+			return nil, err
+		}
+		result.Reader = file
+		result.closers = append(result.closers, file)
+	}
+
+	br := bufio.NewReader(result.Reader)
+	result.Reader = br
+	if magic, _ := br.Peek(2); (2 == len(magic)) && (0x1f == magic[0]) && (0x8b == magic[1]) {
+		zr, err := gzip.NewReader(br)
+		if nil != err {
+			_ = result.Close()
+			return nil, err
+		}
+		result.Reader = zr
+		result.closers = append(result.closers, zr)
+	}
+
+	return result, nil
+} // openLog()
+
+// `readEntries()` calls `aHandle` for every entry of the logfile
+// `aName` (`-` for stdin).
+//
+// Malformed text lines are skipped.
+//
+// Parameters:
+// - `aName`: The name of the logfile to read.
+// - `aBinary`: Whether the logfile is in binary format.
+// - `aHandle`: The function processing an entry.
+//
+// Returns:
+// - `int`: The number of malformed lines skipped.
+// - `error`: A possible error reading the logfile.
+func readEntries(aName string, aBinary bool, aHandle func(aEntry *apachelogger.TEntry)) (int, error) {
+	file, err := openLog(aName)
+	if nil != err {
+		return 0, err
+	}
+	defer file.Close()
+
+	var (
+		next func() (*apachelogger.TEntry, error)
+		tr   *apachelogger.TTextReader
+	)
+	if aBinary {
+		next = apachelogger.NewBinaryReader(file).Next
+	} else {
+		tr = apachelogger.NewTextReader(file)
+		next = tr.Next
+	}
+	for {
+		entry, err := next()
+		if nil != err {
+			if io.EOF == err {
+				err = nil
+			}
+			if nil != tr {
+				return tr.Malformed(), err
+			}
+			return 0, err
+		}
+		aHandle(entry)
+	}
+} // readEntries()
+
+// `sortedKeys()` returns the keys of `aMap` in ascending order.
+//
+// Parameters:
+// - `aMap`: The map whose keys to return.
+//
+// Returns:
+// - `[]string`: The sorted keys.
+func sortedKeys(aMap map[string]string) []string {
+	keys := make([]string, 0, len(aMap))
+	for key := range aMap {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	return keys
+} // sortedKeys()
+
+/* _EoF_ */
@@ -30,9 +30,15 @@ func main() {
 	pageHandler := http.NewServeMux()
 	pageHandler.HandleFunc("/", myHandler)
 
+	handler, err := apachelogger.WrapWith(pageHandler,
+		apachelogger.WithAccessLog(accessLog),
+		apachelogger.WithErrorLog(errorLog))
+	if nil != err {
+		log.Fatalf("%s: %v", os.Args[0], err)
+	}
 	server := http.Server{
 		Addr:    "127.0.0.1:8080",
-		Handler: apachelogger.Wrap(pageHandler, accessLog, errorLog),
+		Handler: handler,
 	}
 	apachelogger.SetErrorLog(&server)
 
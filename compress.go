@@ -0,0 +1,417 @@
+/*
+Copyright © 2024  M.Watermann, 10247 Berlin, Germany
+
+	    All rights reserved
+	EMail : <support@mwat.de>
+*/
+package apachelogger
+
+import (
+	"bufio"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"mime"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+//lint:file-ignore ST1017 – I prefer Yoda conditions
+
+type (
+	// `TCompressorFunc` returns a writer compressing the data written to
+	// it to `aWriter`; `Close()` has to write all pending data (without
+	// closing `aWriter`).
+	TCompressorFunc func(aWriter io.Writer) io.WriteCloser
+
+	// `tCompressor` is a content encoding registered by `AddCompressor()`.
+	tCompressor struct {
+		encoding string          // the `Content-Encoding` token
+		create   TCompressorFunc // creates the compressing writer
+	}
+
+	// `tCompressWriter` compresses the response of a request.
+	tCompressWriter struct {
+		http.ResponseWriter
+		request    *http.Request  // the request served
+		compressor tCompressor    // the negotiated encoding
+		minSize    int            // min. size to compress
+		status     int            // the deferred status code
+		buffer     []byte         // data held back until decided
+		decided    bool           // the response header was sent
+		writer     io.WriteCloser // the compressing writer (if any)
+		original   int64          // number of bytes before compression
+	}
+)
+
+const (
+	// `DefaultCompressMinSize` is the default minimum size (in bytes) of
+	// responses to compress (see `Compress()`).
+	DefaultCompressMinSize = 1024
+)
+
+var (
+	// List of registered content encodings (least preferred first).
+	alCompressors = []tCompressor{
+		{"gzip", func(aWriter io.Writer) io.WriteCloser {
+			return gzip.NewWriter(aWriter)
+		}},
+	}
+
+	// Guard for concurrent access to `alCompressors`.
+	alCompressorsMtx sync.RWMutex
+)
+
+// `Flush()` sends the data compressed so far to the client.
+//
+// Part of the `http.Flusher` interface.
+func (cw *tCompressWriter) Flush() {
+	if !cw.decided {
+		_ = cw.decide(true)
+	}
+	if flusher, ok := cw.writer.(interface{ Flush() error }); ok {
+		_ = flusher.Flush()
+	}
+	if flusher, ok := cw.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+} // Flush()
+
+// `Hijack()` lets the caller take over the connection as long as no
+// response was sent.
+//
+// Part of the `http.Hijacker` interface.
+//
+// Returns:
+// - `net.Conn`: The hijacked connection.
+// - `*bufio.ReadWriter`: The connection's buffered reader and writer.
+// - `error`: A possible error hijacking the connection.
+func (cw *tCompressWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	hijacker, ok := cw.ResponseWriter.(http.Hijacker)
+	if (!ok) || cw.decided || (0 < len(cw.buffer)) {
+		return nil, nil, errNoHijacker
+	}
+	cw.decided = true // nothing to compress anymore
+
+	return hijacker.Hijack()
+} // Hijack()
+
+// `Unwrap()` returns the wrapped response writer.
+//
+// Returns:
+// - `http.ResponseWriter`: The wrapped writer.
+func (cw *tCompressWriter) Unwrap() http.ResponseWriter {
+	return cw.ResponseWriter
+} // Unwrap()
+
+// `Write()` compresses `aData` and sends it to the client.
+//
+// Part of the `http.ResponseWriter` interface.
+//
+// Parameters:
+// - `aData`: The data to write.
+//
+// Returns:
+// - `int`: The number of bytes written.
+// - `error`: A possible error writing the data.
+func (cw *tCompressWriter) Write(aData []byte) (int, error) {
+	cw.original += int64(len(aData))
+	if !cw.decided {
+		cw.buffer = append(cw.buffer, aData...)
+		if len(cw.buffer) < cw.minSize {
+			return len(aData), nil
+		}
+		if err := cw.decide(true); nil != err {
+			return 0, err
+		}
+		return len(aData), nil
+	}
+	if nil != cw.writer {
+		return cw.writer.Write(aData)
+	}
+
+	return cw.ResponseWriter.Write(aData)
+} // Write()
+
+// `WriteHeader()` records the status code to send along with the
+// (possibly compressed) response.
+//
+// Part of the `http.ResponseWriter` interface.
+//
+// Parameters:
+// - `aStatus`: The response's status code.
+func (cw *tCompressWriter) WriteHeader(aStatus int) {
+	if cw.decided || isInformational(aStatus) {
+		cw.ResponseWriter.WriteHeader(aStatus)
+		return
+	}
+	if 0 == cw.status {
+		cw.status = aStatus
+	}
+	if !bodyAllowed(aStatus) {
+		_ = cw.decide(false)
+	}
+} // WriteHeader()
+
+// `compressible()` reports whether the response about to be sent
+// should be compressed.
+//
+// Returns:
+// - `bool`: `true` if the response is to be compressed.
+func (cw *tCompressWriter) compressible() bool {
+	header := cw.ResponseWriter.Header()
+	if ("" != header.Get("Content-Encoding")) ||
+		(http.MethodHead == cw.request.Method) ||
+		("" != header.Get("Content-Range")) {
+		return false
+	}
+	if length, err := strconv.Atoi(header.Get("Content-Length")); (nil == err) &&
+		(length < cw.minSize) {
+		return false
+	}
+	if "" == header.Get("Content-Type") {
+		if 0 == len(cw.buffer) {
+			return false // nothing to detect the type from
+		}
+		// do what `net/http` would do anyway:
+		header.Set("Content-Type", http.DetectContentType(cw.buffer))
+	}
+
+	return compressibleType(header.Get("Content-Type"))
+} // compressible()
+
+// `decide()` sends the response header and the buffered data, either
+// compressed or not.
+//
+// Parameters:
+// - `aCompress`: Whether to compress the response if it's eligible.
+//
+// Returns:
+// - `error`: A possible error writing the buffered data.
+func (cw *tCompressWriter) decide(aCompress bool) error {
+	cw.decided = true
+	header := cw.ResponseWriter.Header()
+	header.Add("Vary", "Accept-Encoding")
+	if aCompress && cw.compressible() {
+		header.Del("Content-Length")
+		header.Set("Content-Encoding", cw.compressor.encoding)
+		cw.writer = cw.compressor.create(cw.ResponseWriter)
+	}
+	if 0 != cw.status {
+		cw.ResponseWriter.WriteHeader(cw.status)
+	}
+
+	buffer := cw.buffer
+	cw.buffer = nil
+	if 0 == len(buffer) {
+		return nil
+	}
+	var err error
+	if nil != cw.writer {
+		_, err = cw.writer.Write(buffer)
+	} else {
+		_, err = cw.ResponseWriter.Write(buffer)
+	}
+
+	return err
+} // decide()
+
+// `finish()` sends the pending data and records the response's size
+// before compression.
+func (cw *tCompressWriter) finish() {
+	if !cw.decided {
+		// the response is smaller than `minSize`:
+		_ = cw.decide(false)
+	}
+	if nil == cw.writer {
+		return
+	}
+	_ = cw.writer.Close()
+
+	ctx := cw.request.Context()
+	SetField(ctx, "uncompressed_size", cw.original)
+	SetNote(ctx, alNoteInstream, strconv.FormatInt(cw.original, 10))
+} // finish()
+
+/* * * * * * * * * * * * * * * * * * * * * * * * * * * * * * * * * * * * * */
+
+// `bodyAllowed()` reports whether a response with status `aStatus` may
+// have a body.
+//
+// Parameters:
+// - `aStatus`: The response's status code.
+//
+// Returns:
+// - `bool`: `true` if a body is allowed.
+func bodyAllowed(aStatus int) bool {
+	return (200 <= aStatus) && (http.StatusNoContent != aStatus) &&
+		(http.StatusNotModified != aStatus)
+} // bodyAllowed()
+
+// `compressibleType()` reports whether content of type `aType` is
+// worth compressing.
+//
+// Parameters:
+// - `aType`: The response's `Content-Type`.
+//
+// Returns:
+// - `bool`: `true` for textual content.
+func compressibleType(aType string) bool {
+	media, _, err := mime.ParseMediaType(aType)
+	if nil != err {
+		return false
+	}
+	if strings.HasPrefix(media, "text/") ||
+		strings.HasSuffix(media, "+json") || strings.HasSuffix(media, "+xml") {
+		return true
+	}
+	switch media {
+	case "application/json", "application/javascript", "application/xml",
+		"application/wasm", "image/svg+xml", "image/x-icon",
+		"application/x-ndjson", "font/ttf", "font/otf":
+		return true
+	}
+
+	return false
+} // compressibleType()
+
+// `negotiateCompressor()` returns the registered content encoding the
+// client prefers according to its `Accept-Encoding` header.
+//
+// Parameters:
+// - `aAccept`: The request's `Accept-Encoding` header.
+//
+// Returns:
+// - `tCompressor`: The encoding to use.
+// - `bool`: `false` if there's no acceptable encoding.
+func negotiateCompressor(aAccept string) (tCompressor, bool) {
+	weights := make(map[string]float64)
+	for _, part := range strings.Split(aAccept, ",") {
+		params := strings.Split(part, ";")
+		name := strings.ToLower(strings.TrimSpace(params[0]))
+		weight := 1.0
+		for _, param := range params[1:] {
+			param = strings.TrimSpace(param)
+			if strings.HasPrefix(param, "q=") {
+				if q, err := strconv.ParseFloat(param[2:], 64); nil == err {
+					weight = q
+				}
+			}
+		}
+		if "" != name {
+			weights[name] = weight
+		}
+	}
+
+	alCompressorsMtx.RLock()
+	defer alCompressorsMtx.RUnlock()
+
+	var (
+		best   tCompressor
+		weight float64
+	)
+	for idx := len(alCompressors) - 1; 0 <= idx; idx-- {
+		c := alCompressors[idx]
+		q, ok := weights[c.encoding]
+		if !ok {
+			q = weights["*"]
+		}
+		if q > weight {
+			best, weight = c, q
+		}
+	}
+
+	return best, 0 < weight
+} // negotiateCompressor()
+
+// `AddCompressor()` registers the content encoding `aEncoding` for
+// `Compress()`.
+//
+// The encodings registered later are preferred if the client accepts
+// several ones with the same weight; `gzip` is built in. This way e.g.
+// Brotli can be added by
+//
+//	apachelogger.AddCompressor("br", func(aWriter io.Writer) io.WriteCloser {
+//		return brotli.NewWriter(aWriter)
+//	})
+//
+// Parameters:
+// - `aEncoding`: The `Content-Encoding` token of the compressor.
+// - `aCreate`: The function creating the compressing writers.
+//
+// Returns:
+// - `error`: A possible error with the arguments.
+func AddCompressor(aEncoding string, aCreate TCompressorFunc) error {
+	encoding := strings.ToLower(strings.TrimSpace(aEncoding))
+	if ("" == encoding) || ("identity" == encoding) || ("*" == encoding) {
+		return fmt.Errorf("apachelogger: invalid content encoding %q", aEncoding)
+	}
+	if nil == aCreate {
+		return fmt.Errorf("apachelogger: no compressor for %q", aEncoding)
+	}
+
+	alCompressorsMtx.Lock()
+	defer alCompressorsMtx.Unlock()
+	for idx, c := range alCompressors {
+		if c.encoding == encoding {
+			alCompressors = append(alCompressors[:idx], alCompressors[idx+1:]...)
+			break
+		}
+	}
+	alCompressors = append(alCompressors, tCompressor{encoding, aCreate})
+
+	return nil
+} // AddCompressor()
+
+// `Compress()` returns a handler compressing the responses of
+// `aHandler` for clients accepting it.
+//
+// Passing the returned handler to `Wrap()` (or using the
+// `WithCompression()` option) lets the access log record the bytes
+// actually sent as the entry's size and the size before compression as
+// the field `uncompressed_size` (and as the note `instream`, so
+// `LogCompressionRatio` works); compressing middlewares wrapped around
+// the logger instead make it log the uncompressed sizes.
+//
+// Only textual content (e.g. `text/*`, JSON, JavaScript, XML, or SVG)
+// of at least `aMinSize` bytes is compressed, using the best encoding
+// registered by `AddCompressor()` the client accepts; responses which
+// are encoded already, ranges, and `HEAD` requests are passed on
+// unchanged.
+//
+// Parameters:
+// - `aHandler`: The handler whose responses to compress.
+// - `aMinSize`: The min. size of responses to compress (`0` uses
+// `DefaultCompressMinSize`).
+//
+// Returns:
+// - `http.Handler`: The compressing handler.
+func Compress(aHandler http.Handler, aMinSize int) http.Handler {
+	if 0 >= aMinSize {
+		aMinSize = DefaultCompressMinSize
+	}
+
+	return http.HandlerFunc(
+		func(aWriter http.ResponseWriter, aRequest *http.Request) {
+			compressor, ok := negotiateCompressor(aRequest.Header.Get("Accept-Encoding"))
+			if !ok {
+				aWriter.Header().Add("Vary", "Accept-Encoding")
+				aHandler.ServeHTTP(aWriter, aRequest)
+				return
+			}
+
+			cw := &tCompressWriter{
+				ResponseWriter: aWriter,
+				request:        aRequest,
+				compressor:     compressor,
+				minSize:        aMinSize,
+			}
+			aHandler.ServeHTTP(cw, aRequest)
+			cw.finish() // not deferred: a panic's response isn't compressed
+		})
+} // Compress()
+
+/* _EoF_ */
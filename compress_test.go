@@ -0,0 +1,117 @@
+/*
+Copyright © 2024  M.Watermann, 10247 Berlin, Germany
+
+	    All rights reserved
+	EMail : <support@mwat.de>
+*/
+package apachelogger
+
+import (
+	"compress/gzip"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+//lint:file-ignore ST1017 – I prefer Yoda conditions
+
+func TestCompress(t *testing.T) {
+	page := strings.Repeat("<p>Hello, World!</p>\n", 100)
+	handler := Compress(http.HandlerFunc(func(aWriter http.ResponseWriter, aRequest *http.Request) {
+		if "/small" == aRequest.URL.Path {
+			_, _ = io.WriteString(aWriter, "<p>small</p>")
+			return
+		}
+		aWriter.Header().Set("Content-Length", strconv.Itoa(len(page)))
+		_, _ = io.WriteString(aWriter, page[:100])
+		_, _ = io.WriteString(aWriter, page[100:])
+	}), 0)
+	serve := func(aPath, aAccept string) (*httptest.ResponseRecorder, *TEntry) {
+		rec := httptest.NewRecorder()
+		req, _ := withRequestState(httptest.NewRequest(http.MethodGet, aPath, nil))
+		req.Header.Set("Accept-Encoding", aAccept)
+		lw := &tLogWriter{ResponseWriter: rec, when: time.Now()}
+		handler.ServeHTTP(lw, req)
+		entry, _ := webEntry(lw, req)
+		return rec, entry
+	}
+
+	rec, entry := serve("/", "br;q=1.0, gzip;q=0.8")
+	if "gzip" != rec.Header().Get("Content-Encoding") {
+		t.Fatalf("Content-Encoding = %q, want gzip", rec.Header().Get("Content-Encoding"))
+	}
+	if "" != rec.Header().Get("Content-Length") {
+		t.Errorf("Content-Length = %q, want none", rec.Header().Get("Content-Length"))
+	}
+	if (rec.Body.Len() != entry.Size) || (len(page) <= entry.Size) {
+		t.Errorf("entry size = %d, want %d (sent)", entry.Size, rec.Body.Len())
+	}
+	if want := strconv.Itoa(len(page)); want != entry.Fields["uncompressed_size"] {
+		t.Errorf("uncompressed_size = %q, want %q", entry.Fields["uncompressed_size"], want)
+	}
+	zr, err := gzip.NewReader(rec.Body)
+	if nil != err {
+		t.Fatalf("gzip.NewReader() error = %v", err)
+	}
+	if data, _ := io.ReadAll(zr); page != string(data) {
+		t.Errorf("decompressed body differs (%d bytes)", len(data))
+	}
+
+	for _, tt := range []struct{ path, accept string }{
+		{"/small", "gzip"},
+		{"/", "identity"},
+		{"/", "gzip;q=0"},
+	} {
+		rec, entry = serve(tt.path, tt.accept)
+		if "" != rec.Header().Get("Content-Encoding") {
+			t.Errorf("%s (%s): Content-Encoding = %q, want none", tt.path, tt.accept,
+				rec.Header().Get("Content-Encoding"))
+		}
+		if rec.Body.Len() != entry.Size {
+			t.Errorf("%s (%s): entry size = %d, want %d", tt.path, tt.accept, entry.Size, rec.Body.Len())
+		}
+		if "Accept-Encoding" != rec.Header().Get("Vary") {
+			t.Errorf("%s (%s): Vary = %q", tt.path, tt.accept, rec.Header().Get("Vary"))
+		}
+	}
+} // TestCompress()
+
+func TestAddCompressor(t *testing.T) {
+	defer func(aCompressors []tCompressor) { alCompressors = aCompressors }(alCompressors)
+
+	if err := AddCompressor("*", func(aWriter io.Writer) io.WriteCloser { return nil }); nil == err {
+		t.Error("AddCompressor() accepted `*`")
+	}
+	if err := AddCompressor("deflate", nil); nil == err {
+		t.Error("AddCompressor() accepted no compressor")
+	}
+	if err := AddCompressor("Deflate", func(aWriter io.Writer) io.WriteCloser {
+		return gzip.NewWriter(aWriter) // wrong, but good enough here
+	}); nil != err {
+		t.Fatalf("AddCompressor() error = %v", err)
+	}
+
+	tests := []struct {
+		accept string
+		want   string
+	}{
+		{"gzip, deflate", "deflate"},
+		{"gzip;q=1, deflate;q=0.5", "gzip"},
+		{"*", "deflate"},
+		{"*;q=0.1, gzip", "gzip"},
+		{"br", ""},
+		{"", ""},
+	}
+	for _, tt := range tests {
+		c, ok := negotiateCompressor(tt.accept)
+		if ok != ("" != tt.want) || (tt.want != c.encoding) {
+			t.Errorf("negotiateCompressor(%q) = %q, %v, want %q", tt.accept, c.encoding, ok, tt.want)
+		}
+	}
+} // TestAddCompressor()
+
+/* _EoF_ */
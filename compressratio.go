@@ -0,0 +1,112 @@
+/*
+Copyright © 2024  M.Watermann, 10247 Berlin, Germany
+
+	    All rights reserved
+	EMail : <support@mwat.de>
+*/
+package apachelogger
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+//lint:file-ignore ST1017 – I prefer Yoda conditions
+
+const (
+	// `UncompressedSizeHeader` is the response header (or the request's
+	// note, see `SetNote()`) a compressing middleware running inside the
+	// wrapper may set to the size of the response before compression.
+	UncompressedSizeHeader = "X-Uncompressed-Content-Length"
+
+	// Names of the notes used by Apache's `mod_deflate`.
+	alNoteInstream  = "instream"
+	alNoteOutstream = "outstream"
+	alNoteRatio     = "ratio"
+)
+
+var (
+	// `LogCompressionRatio` decides whether to add the compression ratio
+	// of compressed responses to the access log entries (default:
+	// `false`).
+	//
+	// The size before compression is taken from the request's note
+	// `instream` (as named by Apache's `mod_deflate`) or the note or
+	// response header `UncompressedSizeHeader`, the compressed size from
+	// the note `outstream` or the bytes actually sent. Like with
+	// `mod_deflate` the ratio is the compressed size in percent of the
+	// original one; it's added as the field `compression_ratio` and the
+	// notes `instream`, `outstream`, and `ratio`, so `%{ratio}n` (see
+	// `SetLogFormat()`) works as with Apache.
+	LogCompressionRatio = false
+)
+
+// `addCompressionRatio()` adds the compression ratio of the response
+// to `aEntry` if `LogCompressionRatio` is set.
+//
+// Parameters:
+// - `aEntry`: The log entry to complete.
+// - `aHeader`: The response's header.
+func addCompressionRatio(aEntry *TEntry, aHeader http.Header) {
+	if !LogCompressionRatio {
+		return
+	}
+	if encoding := aHeader.Get("Content-Encoding"); ("" == encoding) ||
+		strings.EqualFold("identity", encoding) {
+		return
+	}
+
+	in := sizeNote(aEntry.notes[alNoteInstream])
+	if 0 >= in {
+		if in = sizeNote(aEntry.notes[UncompressedSizeHeader]); 0 >= in {
+			in = sizeNote(aHeader.Get(UncompressedSizeHeader))
+		}
+	}
+	if 0 >= in {
+		return
+	}
+	out := sizeNote(aEntry.notes[alNoteOutstream])
+	if 0 > out {
+		out = int64(aEntry.Size)
+	}
+
+	ratio := strconv.FormatInt(out*100/in, 10)
+	if nil == aEntry.notes {
+		aEntry.notes = make(map[string]string)
+	}
+	aEntry.notes[alNoteInstream] = strconv.FormatInt(in, 10)
+	aEntry.notes[alNoteOutstream] = strconv.FormatInt(out, 10)
+	aEntry.notes[alNoteRatio] = ratio
+	aEntry.SetField("compression_ratio", ratio)
+} // addCompressionRatio()
+
+// `responseHeader()` returns the response's header.
+//
+// Returns:
+// - `http.Header`: The header (`nil` without a response writer).
+func (lw *tLogWriter) responseHeader() http.Header {
+	if nil == lw.ResponseWriter {
+		return nil
+	}
+
+	return lw.ResponseWriter.Header()
+} // responseHeader()
+
+// `sizeNote()` returns the size given by `aValue`.
+//
+// Parameters:
+// - `aValue`: The size as text.
+//
+// Returns:
+// - `int64`: The size (`-1` if `aValue` isn't a valid size).
+func sizeNote(aValue string) int64 {
+	size, err := strconv.ParseInt(strings.TrimSpace(aValue), 10, 64)
+	if (nil != err) || (0 > size) {
+		return -1
+	}
+
+	return size
+} // sizeNote()
+
+/* _EoF_ */
@@ -0,0 +1,50 @@
+/*
+Copyright © 2024  M.Watermann, 10247 Berlin, Germany
+
+	    All rights reserved
+	EMail : <support@mwat.de>
+*/
+package apachelogger
+
+import (
+	"net/http"
+	"testing"
+)
+
+//lint:file-ignore ST1017 – I prefer Yoda conditions
+
+func Test_addCompressionRatio(t *testing.T) {
+	defer func() { LogCompressionRatio = false }()
+	gzipped := http.Header{"Content-Encoding": {"gzip"}}
+
+	e := prepEntry()
+	e.notes = map[string]string{alNoteInstream: "100000"}
+	addCompressionRatio(e, gzipped) // disabled
+	if 0 != len(e.Fields) {
+		t.Errorf("addCompressionRatio() fields = %v, want none", e.Fields)
+	}
+
+	LogCompressionRatio = true
+	addCompressionRatio(e, http.Header{}) // not compressed
+	if 0 != len(e.Fields) {
+		t.Errorf("addCompressionRatio() fields = %v, want none", e.Fields)
+	}
+
+	addCompressionRatio(e, gzipped) // size 27155 sent
+	if ("27" != e.Fields["compression_ratio"]) || ("27" != e.notes[alNoteRatio]) {
+		t.Errorf("addCompressionRatio() fields = %v, notes = %v", e.Fields, e.notes)
+	}
+
+	e = prepEntry()
+	header := http.Header{"Content-Encoding": {"br"}, UncompressedSizeHeader: {"54310"}}
+	addCompressionRatio(e, header)
+	lf, err := parseFormat("%{instream}n %{outstream}n %{ratio}n%%")
+	if nil != err {
+		t.Fatalf("parseFormat() error = %v", err)
+	}
+	if got := lf.render(e); "54310 27155 50%\n" != got {
+		t.Errorf("render() = %q, want %q", got, "54310 27155 50%\n")
+	}
+} // Test_addCompressionRatio()
+
+/* _EoF_ */
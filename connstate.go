@@ -0,0 +1,110 @@
+/*
+Copyright © 2024  M.Watermann, 10247 Berlin, Germany
+
+	    All rights reserved
+	EMail : <support@mwat.de>
+*/
+package apachelogger
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+)
+
+//lint:file-ignore ST1017 – I prefer Yoda conditions
+
+type (
+	// `tConnInfo` holds the data of a single client connection.
+	tConnInfo struct {
+		id       uint64    // sequence number of the connection
+		opened   time.Time // time the connection was accepted
+		requests int       // number of requests served so far
+	}
+)
+
+var (
+	// Data of the currently open connections.
+	alConns = make(map[net.Conn]*tConnInfo)
+
+	// Guard for concurrent access to `alConns` and `alConnID`.
+	alConnsMtx sync.Mutex
+
+	// Sequence number of the last connection accepted.
+	alConnID uint64
+)
+
+// `connStateMessage()` updates the data of `aConn` according to
+// `aState` and returns the message to log.
+//
+// Parameters:
+// - `aConn`: The client connection changing its state.
+// - `aState`: The connection's new state.
+//
+// Returns:
+// - `string`: The message to log (empty if nothing should be logged).
+func connStateMessage(aConn net.Conn, aState http.ConnState) string {
+	alConnsMtx.Lock()
+	defer alConnsMtx.Unlock()
+
+	info, ok := alConns[aConn]
+	if !ok {
+		alConnID++
+		info = &tConnInfo{id: alConnID, opened: time.Now()}
+		alConns[aConn] = info
+	}
+
+	switch aState {
+	case http.StateNew:
+		// see below
+
+	case http.StateActive:
+		info.requests++
+		return "" // that's logged by the access log
+
+	case http.StateIdle:
+		// see below
+
+	case http.StateHijacked, http.StateClosed:
+		delete(alConns, aConn)
+
+	default:
+		return ""
+	}
+
+	return fmt.Sprintf("conn=%d remote=%s state=%s requests=%d duration=%s",
+		info.id,
+		anonymiseAddress(aConn.RemoteAddr().String(), 0),
+		aState.String(),
+		info.requests,
+		elapsed(info.opened, time.Now()).Round(time.Millisecond))
+} // connStateMessage()
+
+// `SetConnStateLog()` arranges for the connection state changes of
+// `aServer` to be written to the error log.
+//
+// The function installs a `ConnState` callback logging whenever a
+// client connection is opened, becomes idle, or gets closed (or
+// hijacked), along with the number of requests served over that
+// connection. This helps debugging keep-alive exhaustion and slowloris
+// behaviour. A `ConnState` callback set before is called as well.
+//
+// The function must be called before the server is started.
+//
+// Parameters:
+// - `aServer`: The server instance whose connections are to be logged.
+func SetConnStateLog(aServer *http.Server) {
+	connState := aServer.ConnState
+	aServer.ConnState = func(aConn net.Conn, aState http.ConnState) {
+		if msg := connStateMessage(aConn, aState); "" != msg {
+			Err("ApacheLogger/ConnState", msg)
+		}
+		if nil != connState {
+			connState(aConn, aState)
+		}
+	}
+} // SetConnStateLog()
+
+/* _EoF_ */
@@ -0,0 +1,60 @@
+/*
+Copyright © 2024  M.Watermann, 10247 Berlin, Germany
+
+	    All rights reserved
+	EMail : <support@mwat.de>
+*/
+package apachelogger
+
+import (
+	"net"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+//lint:file-ignore ST1017 – I prefer Yoda conditions
+
+func Test_connStateMessage(t *testing.T) {
+	c1, c2 := net.Pipe()
+	defer c1.Close()
+	defer c2.Close()
+
+	tests := []struct {
+		name  string
+		state http.ConnState
+		want  string
+	}{
+		{" 1", http.StateNew, "state=new requests=0"},
+		{" 2", http.StateActive, ""},
+		{" 3", http.StateIdle, "state=idle requests=1"},
+		{" 4", http.StateActive, ""},
+		{" 5", http.StateClosed, "state=closed requests=2"},
+		// TODO: Add test cases.
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := connStateMessage(c1, tt.state)
+			if "" == tt.want {
+				if "" != got {
+					t.Errorf("%q: connStateMessage() = %q, want empty",
+						tt.name, got)
+				}
+				return
+			}
+			if !strings.Contains(got, tt.want) {
+				t.Errorf("%q: connStateMessage() = %q,\nwant %q",
+					tt.name, got, tt.want)
+			}
+		})
+	}
+
+	alConnsMtx.Lock()
+	_, ok := alConns[c1]
+	alConnsMtx.Unlock()
+	if ok {
+		t.Error("connStateMessage() didn't forget the closed connection")
+	}
+} // Test_connStateMessage()
+
+/* _EoF_ */
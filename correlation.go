@@ -0,0 +1,109 @@
+/*
+Copyright © 2024  M.Watermann, 10247 Berlin, Germany
+
+	    All rights reserved
+	EMail : <support@mwat.de>
+*/
+package apachelogger
+
+import (
+	"net/http"
+	"sync"
+)
+
+//lint:file-ignore ST1017 – I prefer Yoda conditions
+
+type (
+	// `tCorrelation` maps a request header to an entry field.
+	tCorrelation struct {
+		header string // canonical name of the request header
+		field  string // name of the entry field
+	}
+)
+
+var (
+	// List of request headers to capture, in order of registration.
+	alCorrelations = []tCorrelation{
+		{"X-Amzn-Trace-Id", "trace_id"},
+	}
+
+	// Guard for concurrent access to `alCorrelations`.
+	alCorrelationsMtx sync.RWMutex
+)
+
+// `AddCorrelationHeader()` registers a request header whose value is
+// captured as field `aField` of the access log entries.
+//
+// Load balancers and tracing systems add correlation headers to the
+// requests (like AWS ALB's `X-Amzn-Trace-Id` which is captured as
+// `trace_id` by default) which allow joining the access log entries
+// with the load balancer's logs and traces. The fields show up in
+// structured output modes or with `AppendFields` set.
+//
+// Registering a header again changes its field name; an empty `aField`
+// removes the header from the list.
+//
+// Parameters:
+// - `aHeader`: The name of the request header to capture.
+// - `aField`: The name of the entry field to use.
+func AddCorrelationHeader(aHeader, aField string) {
+	aHeader = http.CanonicalHeaderKey(aHeader)
+	if "" == aHeader {
+		return
+	}
+	alCorrelationsMtx.Lock()
+	defer alCorrelationsMtx.Unlock()
+
+	for idx, corr := range alCorrelations {
+		if corr.header != aHeader {
+			continue
+		}
+		if "" == aField {
+			alCorrelations = append(alCorrelations[:idx], alCorrelations[idx+1:]...)
+		} else {
+			alCorrelations[idx].field = aField
+		}
+		return
+	}
+	if "" != aField {
+		alCorrelations = append(alCorrelations, tCorrelation{aHeader, aField})
+	}
+} // AddCorrelationHeader()
+
+// `captureCorrelation()` copies the values of all registered request
+// headers present in `aHeader` to the fields of `aEntry`.
+//
+// Parameters:
+// - `aEntry`: The log entry to augment.
+// - `aHeader`: The request's headers.
+func captureCorrelation(aEntry *TEntry, aHeader http.Header) {
+	for field, value := range correlationFields(aHeader) {
+		aEntry.SetField(field, value)
+	}
+} // captureCorrelation()
+
+// `correlationFields()` returns the values of all registered request
+// headers present in `aHeader` by their field names.
+//
+// Parameters:
+// - `aHeader`: The request's headers.
+//
+// Returns:
+// - `map[string]string`: The correlation fields (`nil` if none).
+func correlationFields(aHeader http.Header) (rFields map[string]string) {
+	alCorrelationsMtx.RLock()
+	defer alCorrelationsMtx.RUnlock()
+
+	for _, corr := range alCorrelations {
+		if value := aHeader.Get(corr.header); "" != value {
+			if nil == rFields {
+				rFields = make(map[string]string)
+			}
+			rFields[corr.field] = value
+		}
+	}
+
+	return
+} // correlationFields()
+
+/* _EoF_ */
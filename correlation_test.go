@@ -0,0 +1,59 @@
+/*
+Copyright © 2024  M.Watermann, 10247 Berlin, Germany
+
+	    All rights reserved
+	EMail : <support@mwat.de>
+*/
+package apachelogger
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+)
+
+//lint:file-ignore ST1017 – I prefer Yoda conditions
+
+func Test_captureCorrelation(t *testing.T) {
+	defer AddCorrelationHeader("X-Request-Id", "")
+
+	header := make(http.Header)
+	header.Set("X-Amzn-Trace-Id", "Root=1-67891233-abcdef012345678912345678")
+	header.Set("X-Request-Id", "abc-123")
+	header.Set("X-Other", "ignored")
+
+	e1 := prepEntry()
+	captureCorrelation(e1, header)
+	if 1 != len(e1.Fields) {
+		t.Errorf("captureCorrelation() Fields = %v, want 1 field", e1.Fields)
+	}
+
+	AddCorrelationHeader("x-request-id", "request_id")
+	e2 := prepEntry()
+	captureCorrelation(e2, header)
+	if w := "abc-123"; w != e2.Fields["request_id"] {
+		t.Errorf("request_id = %q, want %q", e2.Fields["request_id"], w)
+	}
+	if w := "Root=1-67891233-abcdef012345678912345678"; w != e2.Fields["trace_id"] {
+		t.Errorf("trace_id = %q, want %q", e2.Fields["trace_id"], w)
+	}
+} // Test_captureCorrelation()
+
+func Test_formatEntry_AppendFields(t *testing.T) {
+	defer func() {
+		AppendFields = false
+	}()
+	e1 := prepEntry()
+	e1.SetField("trace_id", "Root=1-abc")
+
+	if got := formatEntry(e1); got != e1.String() {
+		t.Errorf("formatEntry() = %q, want %q", got, e1.String())
+	}
+	AppendFields = true
+	want := ` "Mozilla/5.0" trace_id=Root=1-abc` + "\n"
+	if got := formatEntry(e1); !strings.HasSuffix(got, want) {
+		t.Errorf("formatEntry() = %q, want suffix %q", got, want)
+	}
+} // Test_formatEntry_AppendFields()
+
+/* _EoF_ */
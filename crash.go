@@ -0,0 +1,226 @@
+/*
+Copyright © 2024  M.Watermann, 10247 Berlin, Germany
+
+	    All rights reserved
+	EMail : <support@mwat.de>
+*/
+package apachelogger
+
+import (
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+//lint:file-ignore ST1017 – I prefer Yoda conditions
+
+const (
+	// Number of recent access log entries written to a crash report.
+	alCrashRecent = 20
+
+	// Prefix of the crash reports' filenames.
+	alCrashPrefix = "crash-"
+
+	// Text replacing the values of sensitive request headers.
+	alCrashRedacted = "[redacted]"
+)
+
+var (
+	// The crash reports' configuration.
+	alCrash struct {
+		sync.Mutex
+		dir string // directory of the crash reports (empty: none)
+		max int    // max. number of crash reports kept
+	}
+
+	// Parts of header names whose values are never written.
+	alCrashSecrets = []string{
+		"auth", "cookie", "key", "password", "secret", "session", "token",
+	}
+)
+
+// `crashHeaders()` returns the request headers sorted by name with the
+// values of sensitive headers (e.g. `Authorization`, `Cookie`, or any
+// `…-Token`) redacted and the addresses of proxy chains (e.g.
+// `X-Forwarded-For`) anonymised.
+//
+// Parameters:
+// - `aHeader`: The request headers.
+//
+// Returns:
+// - `[]string`: The `Name: value` lines.
+func crashHeaders(aHeader http.Header) []string {
+	result := make([]string, 0, len(aHeader))
+	for name, values := range aHeader {
+		lower := strings.ToLower(name)
+		secret := false
+		for _, part := range alCrashSecrets {
+			if strings.Contains(lower, part) {
+				secret = true
+				break
+			}
+		}
+		for _, value := range values {
+			if secret {
+				value = alCrashRedacted
+			} else {
+				value = anonymiseProxyHeader(name, value, http.StatusInternalServerError, nil)
+			}
+			result = append(result, name+": "+sanitiseString(value))
+		}
+	}
+	sort.Strings(result)
+
+	return result
+} // crashHeaders()
+
+// `crashReport()` returns the text of the crash report of a recovered
+// panic.
+//
+// Parameters:
+// - `aValue`: The value passed to `panic()`.
+// - `aStack`: The stack of the panicking goroutine.
+// - `aRequest`: The request whose handler panicked.
+// - `aNow`: The time the panic was recovered.
+//
+// Returns:
+// - `string`: The report's text.
+func crashReport(aValue interface{}, aStack []byte, aRequest *http.Request, aNow time.Time) string {
+	var sb strings.Builder
+	hostname, _ := os.Hostname()
+
+	fmt.Fprintf(&sb, "time:    %s\n", aNow.Format(time.RFC3339Nano))
+	fmt.Fprintf(&sb, "host:    %s\n", hostname)
+	fmt.Fprintf(&sb, "program: %s (pid %d, %s)\n", os.Args[0], os.Getpid(), runtime.Version())
+	fmt.Fprintf(&sb, "panic:   %T: %s\n", aValue, sanitiseString(fmt.Sprint(aValue)))
+
+	if nil != aRequest {
+		lw := &tLogWriter{status: http.StatusInternalServerError, when: aNow}
+		entry, _ := webEntry(lw, aRequest)
+		route := entry.Path
+		if idx := strings.IndexByte(route, '?'); 0 <= idx {
+			route = route[:idx]
+		}
+		fmt.Fprintf(&sb, "\n== request ==\n")
+		fmt.Fprintf(&sb, "route:   %s %s\n", entry.Method, route)
+		fmt.Fprintf(&sb, "host:    %s\n", sanitiseString(aRequest.Host))
+		fmt.Fprintf(&sb, "entry:   %s", tailLine(entry))
+		if 0 < len(entry.Fields) {
+			keys := make([]string, 0, len(entry.Fields))
+			for key := range entry.Fields {
+				keys = append(keys, key)
+			}
+			sort.Strings(keys)
+			sb.WriteString("fields: ")
+			for _, key := range keys {
+				appendKeyValue(&sb, key, entry.Fields[key])
+			}
+			sb.WriteByte('\n')
+		}
+		sb.WriteString("\n== headers ==\n")
+		for _, line := range crashHeaders(aRequest.Header) {
+			sb.WriteString(line)
+			sb.WriteByte('\n')
+		}
+	}
+
+	sb.WriteString("\n== recent access log entries ==\n")
+	recent := alRecent.query(TRecentFilter{Limit: alCrashRecent})
+	if 0 == len(recent) {
+		sb.WriteString("(none; see SetRecentSize())\n")
+	}
+	for _, entry := range recent {
+		sb.WriteString(tailLine(entry))
+	}
+
+	sb.WriteString("\n== stack ==\n")
+	sb.Write(aStack)
+
+	return sb.String()
+} // crashReport()
+
+// `pruneCrashReports()` removes the oldest crash reports in `aDir`
+// keeping at most `aMax - 1` files.
+//
+// Parameters:
+// - `aDir`: The directory of the crash reports.
+// - `aMax`: The max. number of reports to keep after the next one.
+func pruneCrashReports(aDir string, aMax int) {
+	names, err := filepath.Glob(filepath.Join(aDir, alCrashPrefix+"*.txt"))
+	if nil != err {
+		return
+	}
+	sort.Strings(names) // the names start with the report's time
+	for 0 < len(names) && len(names) >= aMax {
+		_ = os.Remove(names[0])
+		names = names[1:]
+	}
+} // pruneCrashReports()
+
+// `writeCrashReport()` writes the crash report of a recovered panic
+// if a directory was set by `SetCrashReports()`.
+//
+// Parameters:
+// - `aValue`: The value passed to `panic()`.
+// - `aStack`: The stack of the panicking goroutine.
+// - `aRequest`: The request whose handler panicked.
+//
+// Returns:
+// - `string`: The report's filename (empty if none was written).
+func writeCrashReport(aValue interface{}, aStack []byte, aRequest *http.Request) string {
+	alCrash.Lock()
+	defer alCrash.Unlock()
+	if "" == alCrash.dir {
+		return ""
+	}
+
+	now := time.Now()
+	report := crashReport(aValue, aStack, aRequest, now)
+	if err := os.MkdirAll(alCrash.dir, 0o750); nil != err {
+		Err("ApacheLogger/crashReport", err.Error())
+		return ""
+	}
+	pruneCrashReports(alCrash.dir, alCrash.max)
+
+	name := filepath.Join(alCrash.dir, fmt.Sprintf("%s%s-%09d-%d.txt", alCrashPrefix,
+		now.UTC().Format("20060102-150405"), now.Nanosecond(), os.Getpid()))
+	if err := os.WriteFile(name, []byte(report), 0o640); nil != err {
+		Err("ApacheLogger/crashReport", err.Error())
+		return ""
+	}
+
+	return name
+} // writeCrashReport()
+
+/* * * * * * * * * * * * * * * * * * * * * * * * * * * * * * * * * * * * * */
+
+// `SetCrashReports()` arranges for every panic recovered by the wrapper
+// to be written to a dedicated crash report in `aDirectory`, since a
+// single error log line is hardly enough to debug a production panic.
+//
+// Each report holds the panic's value and stack, the request's route,
+// its access log entry (with fields), its headers (with the values of
+// sensitive headers like `Authorization` or `Cookie` redacted), and the
+// latest access log entries leading up to it (see `SetRecentSize()`).
+// Only the newest `aMaxFiles` reports are kept; the error log line
+// of the panic names the report's file.
+//
+// Parameters:
+// - `aDirectory`: The directory to write the reports to (empty for none).
+// - `aMaxFiles`: The max. number of reports kept (at least `1`).
+func SetCrashReports(aDirectory string, aMaxFiles int) {
+	if 1 > aMaxFiles {
+		aMaxFiles = 1
+	}
+	alCrash.Lock()
+	alCrash.dir, alCrash.max = aDirectory, aMaxFiles
+	alCrash.Unlock()
+} // SetCrashReports()
+
+/* _EoF_ */
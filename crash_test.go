@@ -0,0 +1,86 @@
+/*
+Copyright © 2024  M.Watermann, 10247 Berlin, Germany
+
+	    All rights reserved
+	EMail : <support@mwat.de>
+*/
+package apachelogger
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+//lint:file-ignore ST1017 – I prefer Yoda conditions
+
+func Test_crashHeaders(t *testing.T) {
+	header := http.Header{}
+	header.Set("Authorization", "Bearer s3cr3t")
+	header.Set("Cookie", "session=s3cr3t")
+	header.Set("X-Api-Key", "s3cr3t")
+	header.Set("Accept", "text/html")
+	header.Add("X-Multi", "b")
+	header.Add("X-Multi", "a\nb")
+
+	got := strings.Join(crashHeaders(header), "|")
+	want := "Accept: text/html|Authorization: [redacted]|Cookie: [redacted]|X-Api-Key: [redacted]|X-Multi: a%0Ab|X-Multi: b"
+	if want != got {
+		t.Errorf("crashHeaders() = %q, want %q", got, want)
+	}
+} // Test_crashHeaders()
+
+func TestSetCrashReports(t *testing.T) {
+	dir := t.TempDir()
+	SetCrashReports(dir, 2)
+	SetRecentSize(5)
+	defer func() {
+		SetCrashReports("", 0)
+		SetRecentSize(0)
+	}()
+	before := prepEntry()
+	before.Path = "/before/panic"
+	alRecent.add(before)
+
+	handler := Wrap(http.HandlerFunc(func(http.ResponseWriter, *http.Request) {
+		panic("boom")
+	}), "", "")
+	for idx := 0; idx < 3; idx++ {
+		req := httptest.NewRequest(http.MethodGet, "/crash/path?id=1", nil)
+		req.Header.Set("Authorization", "Bearer s3cr3t")
+		req.Header.Set("User-Agent", "crash-test")
+		handler.ServeHTTP(httptest.NewRecorder(), req)
+		time.Sleep(time.Millisecond) // distinct filenames
+	}
+
+	names, _ := filepath.Glob(filepath.Join(dir, alCrashPrefix+"*.txt"))
+	if 2 != len(names) {
+		t.Fatalf("SetCrashReports() kept %d reports, want 2", len(names))
+	}
+	data, err := os.ReadFile(names[1])
+	if nil != err {
+		t.Fatal(err)
+	}
+	report := string(data)
+	for _, want := range []string{
+		"panic:   string: boom",
+		"route:   GET /crash/path",
+		"Authorization: [redacted]",
+		"User-Agent: crash-test",
+		"/before/panic",
+		"== stack ==",
+	} {
+		if !strings.Contains(report, want) {
+			t.Errorf("crash report lacks %q:\n%s", want, report)
+		}
+	}
+	if strings.Contains(report, "s3cr3t") {
+		t.Errorf("crash report contains a secret:\n%s", report)
+	}
+} // TestSetCrashReports()
+
+/* _EoF_ */
@@ -0,0 +1,116 @@
+/*
+Copyright © 2024  M.Watermann, 10247 Berlin, Germany
+
+	    All rights reserved
+	EMail : <support@mwat.de>
+*/
+package apachelogger
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"net"
+)
+
+//lint:file-ignore ST1017 – I prefer Yoda conditions
+
+type (
+	// `tCryptoPAnAnonymiser` replaces the address by a prefix-preserving
+	// keyed permutation (Crypto-PAn).
+	tCryptoPAnAnonymiser struct {
+		block cipher.Block // the AES cipher using the key's first half
+		pad   [16]byte     // the encrypted second half of the key
+	}
+)
+
+// `Anonymise()` returns the Crypto-PAn permutation of `aIP`.
+//
+// Part of the `TAnonymiser` interface.
+//
+// Parameters:
+// - `aIP`: The remote IP address to anonymise.
+// - `aStatus`: The HTTP status code (ignored).
+//
+// Returns:
+// - `string`: The anonymised address.
+func (ca *tCryptoPAnAnonymiser) Anonymise(aIP net.IP, aStatus int) string {
+	return ca.permute(aIP).String()
+} // Anonymise()
+
+// `permute()` returns the Crypto-PAn permutation of `aIP`.
+//
+// Bit `n` of the result is bit `n` of `aIP` XOR the first bit of the
+// AES encryption of the address' first `n` bits padded with the
+// key's pad. Hence addresses sharing a prefix of `n` bits yield results
+// sharing a prefix of `n` bits.
+//
+// Parameters:
+// - `aIP`: The address to permute.
+//
+// Returns:
+// - `net.IP`: The permuted address.
+func (ca *tCryptoPAnAnonymiser) permute(aIP net.IP) net.IP {
+	addr := aIP.To4()
+	if nil == addr {
+		if addr = aIP.To16(); nil == addr {
+			return aIP
+		}
+	}
+	var in, out [16]byte
+	result := make(net.IP, len(addr))
+	copy(in[:], ca.pad[:])
+
+	for pos := 0; pos < len(addr)*8; pos++ {
+		idx, mask := pos/8, byte(0x80)>>(pos%8)
+		ca.block.Encrypt(out[:], in[:])
+		result[idx] |= ((out[0] >> 7) << (7 - pos%8)) ^ (addr[idx] & mask)
+
+		// the next input uses one more bit of the address:
+		in[idx] = (in[idx] &^ mask) | (addr[idx] & mask)
+	}
+
+	return result
+} // permute()
+
+/* * * * * * * * * * * * * * * * * * * * * * * * * * * * * * * * * * * * * */
+
+// `NewCryptoPAnAnonymiser()` returns an anonymiser replacing the remote
+// addresses by their Crypto-PAn permutation.
+//
+// Crypto-PAn is prefix-preserving: if two addresses share the first
+// `n` bits, so do their permutations. The subnet structure is thus
+// kept for network analysis while the real addresses can't be
+// recovered without the key.
+//
+// A 32 byte key is used as is (compatible with other Crypto-PAn
+// implementations); other keys are hashed to 32 bytes first. If `aKey`
+// is empty a random key is generated, i.e. the results change with
+// every program start.
+//
+// Parameters:
+// - `aKey`: The secret key to use.
+//
+// Returns:
+// - `TAnonymiser`: The Crypto-PAn anonymiser.
+func NewCryptoPAnAnonymiser(aKey []byte) TAnonymiser {
+	key := make([]byte, 32)
+	switch len(aKey) {
+	case 0:
+		_, _ = rand.Read(key)
+	case 32:
+		copy(key, aKey)
+	default:
+		sum := sha256.Sum256(aKey)
+		copy(key, sum[:])
+	}
+
+	block, _ := aes.NewCipher(key[:16]) // can't fail with 16 bytes
+	result := &tCryptoPAnAnonymiser{block: block}
+	block.Encrypt(result.pad[:], key[16:])
+
+	return result
+} // NewCryptoPAnAnonymiser()
+
+/* _EoF_ */
@@ -0,0 +1,55 @@
+/*
+Copyright © 2024  M.Watermann, 10247 Berlin, Germany
+
+	    All rights reserved
+	EMail : <support@mwat.de>
+*/
+package apachelogger
+
+import (
+	"net"
+	"strings"
+	"testing"
+)
+
+//lint:file-ignore ST1017 – I prefer Yoda conditions
+
+func TestNewCryptoPAnAnonymiser(t *testing.T) {
+	// Key and samples of the Crypto-PAn reference implementation:
+	key := []byte{21, 34, 23, 141, 51, 164, 207, 128, 19, 10, 91, 22, 73, 144,
+		125, 16, 216, 152, 143, 131, 121, 121, 101, 39, 98, 87, 76, 45, 42,
+		132, 34, 2}
+	ca := NewCryptoPAnAnonymiser(key)
+	tests := []struct {
+		ip   string
+		want string
+	}{
+		{"128.11.68.132", "135.242.180.132"},
+		{"129.118.74.4", "134.136.186.123"},
+		{"130.132.252.244", "133.68.164.234"},
+		{"141.223.7.43", "141.167.8.160"},
+	}
+	for _, tt := range tests {
+		if got := ca.Anonymise(net.ParseIP(tt.ip), 200); got != tt.want {
+			t.Errorf("Anonymise(%q) = %q, want %q", tt.ip, got, tt.want)
+		}
+	}
+
+	// prefix preservation for IPv6:
+	a1 := ca.Anonymise(net.ParseIP("2001:db8:1:2::1"), 200)
+	a2 := ca.Anonymise(net.ParseIP("2001:db8:1:2::ffff"), 200)
+	p1, p2 := net.ParseIP(a1).To16(), net.ParseIP(a2).To16()
+	if (nil == p1) || (nil == p2) || (string(p1[:14]) != string(p2[:14])) {
+		t.Errorf("Anonymise() = %q, %q: prefix not preserved", a1, a2)
+	}
+	if strings.HasPrefix(a1, "2001:db8:") {
+		t.Errorf("Anonymise() = %q: not permuted", a1)
+	}
+
+	// other key lengths are usable as well:
+	if got := NewCryptoPAnAnonymiser([]byte("secret")).Anonymise(net.ParseIP("10.1.2.3"), 200); "" == got {
+		t.Error("Anonymise() returned an empty string")
+	}
+} // TestNewCryptoPAnAnonymiser()
+
+/* _EoF_ */
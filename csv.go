@@ -0,0 +1,203 @@
+/*
+Copyright © 2024  M.Watermann, 10247 Berlin, Germany
+
+	    All rights reserved
+	EMail : <support@mwat.de>
+*/
+package apachelogger
+
+import (
+	"encoding/csv"
+	"errors"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+)
+
+//lint:file-ignore ST1017 – I prefer Yoda conditions
+
+type (
+	// `tCSVFormat` holds the settings of the delimiter-separated output.
+	tCSVFormat struct {
+		columns   []string // names of the columns to write
+		delimiter rune     // the column separator
+		header    bool     // whether to start new files with a header
+	}
+)
+
+var (
+	// The columns written if no columns are given to `SetCSVFormat()`.
+	alCSVColumns = []string{
+		"remote", "user", "time", "method", "path", "proto",
+		"status", "size", "referrer", "agent",
+	}
+
+	// The current delimiter-separated output settings (`nil` if off).
+	alCSVFormat *tCSVFormat
+
+	// Guard for concurrent access to `alCSVFormat`.
+	alCSVFormatMtx sync.RWMutex
+
+	// Error returned for unusable column delimiters.
+	errCSVDelimiter = errors.New("apachelogger: invalid CSV delimiter")
+)
+
+// `line()` returns `aValues` as a single delimiter-separated line.
+//
+// Parameters:
+// - `aValues`: The column values to write.
+//
+// Returns:
+// - `string`: The line including the trailing newline.
+func (cf *tCSVFormat) line(aValues []string) string {
+	var sb strings.Builder
+	cw := csv.NewWriter(&sb)
+	cw.Comma = cf.delimiter
+	_ = cw.Write(aValues) // writing to memory can't fail
+	cw.Flush()
+
+	return sb.String()
+} // line()
+
+/* * * * * * * * * * * * * * * * * * * * * * * * * * * * * * * * * * * * * */
+
+// `column()` returns the value of the column `aName` of the entry.
+//
+// Besides the entry's standard fields (`remote`, `user`, `time`,
+// `method`, `path`, `proto`, `status`, `size`, `referrer`, `agent`,
+// and `duration_us`) all additional fields can be used as columns.
+//
+// Parameters:
+// - `aName`: The name of the column.
+//
+// Returns:
+// - `string`: The column's value (empty if unknown).
+func (e *TEntry) column(aName string) string {
+	switch aName {
+	case "time":
+		return e.timestamp().Format(time.RFC3339)
+	case "status":
+		return strconv.Itoa(e.Status)
+	case "size":
+		return strconv.Itoa(e.Size)
+	case "duration_us":
+		return strconv.FormatInt(e.Duration.Microseconds(), 10)
+	}
+	if field := e.stringField(aName); nil != field {
+		return *field
+	}
+
+	return e.Fields[aName]
+} // column()
+
+// `csvFormat()` returns the current delimiter-separated output settings.
+//
+// Returns:
+// - `*tCSVFormat`: The current settings (`nil` if that mode is off).
+func csvFormat() *tCSVFormat {
+	alCSVFormatMtx.RLock()
+	defer alCSVFormatMtx.RUnlock()
+
+	return alCSVFormat
+} // csvFormat()
+
+// `csvLine()` returns `aEntry` as a delimiter-separated line if that
+// output mode is active.
+//
+// Parameters:
+// - `aEntry`: The log entry to format.
+//
+// Returns:
+// - `string`: The formatted line.
+// - `bool`: `true` if the delimiter-separated output mode is active.
+func csvLine(aEntry *TEntry) (string, bool) {
+	cf := csvFormat()
+	if nil == cf {
+		return "", false
+	}
+	values := make([]string, len(cf.columns))
+	for idx, name := range cf.columns {
+		values[idx] = aEntry.column(name)
+	}
+
+	return cf.line(values), true
+} // csvLine()
+
+// `fileHeader()` returns the header to write at the start of a new
+// (empty) logfile.
+//
+// Returns:
+// - `string`: The header line(s) or an empty string.
+func fileHeader() string {
+	if cf := csvFormat(); (nil != cf) && cf.header {
+		return cf.line(cf.columns)
+	}
+	if wf := w3cFormat(); nil != wf {
+		return wf.header(time.Now())
+	}
+
+	return ""
+} // fileHeader()
+
+// `ClearCSVFormat()` switches the delimiter-separated output mode off.
+func ClearCSVFormat() {
+	alCSVFormatMtx.Lock()
+	alCSVFormat = nil
+	alCSVFormatMtx.Unlock()
+} // ClearCSVFormat()
+
+// `SetCSVFormat()` switches to writing the log entries as delimiter-
+// separated lines (e.g. CSV or TSV) for importing them into spreadsheets
+// or BI tools.
+//
+// Values containing the delimiter, quotes, or line breaks are quoted
+// as described in RFC 4180. Column names are `remote`, `user`, `time`
+// (RFC 3339), `method`, `path`, `proto`, `status`, `size`, `referrer`,
+// `agent`, `duration_us`, and the names of any additional fields (see
+// `SetField()`); without `aColumns` the fields of the combined log
+// format are written.
+//
+// If `aHeader` is `true` a row with the column names is written to
+// every new (empty) logfile.
+//
+// Example (TSV with header row):
+//
+//	err := apachelogger.SetCSVFormat('\t', true,
+//		"time", "remote", "method", "path", "status", "duration_us")
+//
+// Parameters:
+// - `aDelimiter`: The column separator (e.g. `,`, `;`, or `\t`).
+// - `aHeader`: Whether to start new logfiles with a header row.
+// - `aColumns`: The names of the columns to write.
+//
+// Returns:
+// - `error`: A possible error for an invalid delimiter or column name.
+func SetCSVFormat(aDelimiter rune, aHeader bool, aColumns ...string) error {
+	if (0 == aDelimiter) || ('"' == aDelimiter) || ('\r' == aDelimiter) ||
+		('\n' == aDelimiter) || !utf8.ValidRune(aDelimiter) ||
+		(utf8.RuneError == aDelimiter) {
+		return errCSVDelimiter
+	}
+	if 0 == len(aColumns) {
+		aColumns = alCSVColumns
+	}
+	for _, name := range aColumns {
+		if "" == strings.TrimSpace(name) {
+			return errors.New("apachelogger: empty CSV column name")
+		}
+	}
+
+	alCSVFormatMtx.Lock()
+	alCSVFormat = &tCSVFormat{
+		columns:   append([]string{}, aColumns...),
+		delimiter: aDelimiter,
+		header:    aHeader,
+	}
+	alCSVFormatMtx.Unlock()
+
+	return nil
+} // SetCSVFormat()
+
+/* _EoF_ */
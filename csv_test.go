@@ -0,0 +1,85 @@
+/*
+Copyright © 2024  M.Watermann, 10247 Berlin, Germany
+
+	    All rights reserved
+	EMail : <support@mwat.de>
+*/
+package apachelogger
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+//lint:file-ignore ST1017 – I prefer Yoda conditions
+
+func TestSetCSVFormat(t *testing.T) {
+	defer ClearCSVFormat()
+
+	for _, delim := range []rune{0, '"', '\n', '\r', -1} {
+		if err := SetCSVFormat(delim, false); nil == err {
+			t.Errorf("SetCSVFormat(%q) expected an error", delim)
+		}
+	}
+	if err := SetCSVFormat(',', false, "path", " "); nil == err {
+		t.Error("SetCSVFormat() expected an error for empty column")
+	}
+
+	e1 := prepEntry()
+	e1.Agent = `Mozilla/5.0 "quoted", too`
+	e1.SetField("trace_id", "abc")
+	if err := SetCSVFormat(',', true); nil != err {
+		t.Fatalf("SetCSVFormat() error = %v", err)
+	}
+	w1 := `192.168.1.0,-,2024-04-25T20:16:45+02:00,GET,/path/to/file?lang=en,HTTP/1.1,200,27155,-,"Mozilla/5.0 ""quoted"", too"` + "\n"
+	if got := formatEntry(e1); got != w1 {
+		t.Errorf("formatEntry() = %q,\nwant %q", got, w1)
+	}
+	w2 := "remote,user,time,method,path,proto,status,size,referrer,agent\n"
+	if got := fileHeader(); got != w2 {
+		t.Errorf("fileHeader() = %q, want %q", got, w2)
+	}
+
+	if err := SetCSVFormat('\t', false, "status", "path", "trace_id", "missing"); nil != err {
+		t.Fatalf("SetCSVFormat() error = %v", err)
+	}
+	w3 := "200\t/path/to/file?lang=en\tabc\t\n"
+	if got := formatEntry(e1); got != w3 {
+		t.Errorf("formatEntry() = %q, want %q", got, w3)
+	}
+	if got := fileHeader(); "" != got {
+		t.Errorf("fileHeader() = %q, want %q", got, "")
+	}
+
+	ClearCSVFormat()
+	if got := formatEntry(e1); got != e1.String() {
+		t.Errorf("formatEntry() = %q, want %q", got, e1.String())
+	}
+} // TestSetCSVFormat()
+
+func Test_writeHeader(t *testing.T) {
+	defer ClearCSVFormat()
+	if err := SetCSVFormat(';', true, "remote", "status"); nil != err {
+		t.Fatalf("SetCSVFormat() error = %v", err)
+	}
+	name := filepath.Join(t.TempDir(), "access.csv")
+
+	for i := 0; 2 > i; i++ {
+		sink := NewFileSink(name)
+		if err := sink.Write([]byte("1.2.3.0;200\n")); nil != err {
+			t.Fatalf("Write() error = %v", err)
+		}
+		_ = sink.Close()
+	}
+	data, err := os.ReadFile(name)
+	if nil != err {
+		t.Fatal(err)
+	}
+	want := "remote;status\n1.2.3.0;200\n1.2.3.0;200\n"
+	if string(data) != want {
+		t.Errorf("logfile = %q, want %q", data, want)
+	}
+} // Test_writeHeader()
+
+/* _EoF_ */
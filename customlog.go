@@ -0,0 +1,303 @@
+/*
+Copyright © 2024  M.Watermann, 10247 Berlin, Germany
+
+	    All rights reserved
+	EMail : <support@mwat.de>
+*/
+package apachelogger
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+//lint:file-ignore ST1017 – I prefer Yoda conditions
+
+// `fieldValue()` returns the textual representation of `aValue` as
+// stored in an entry's additional fields.
+//
+// Parameters:
+// - `aValue`: The value to convert.
+//
+// Returns:
+// - `string`: The field's value.
+func fieldValue(aValue interface{}) string {
+	switch value := aValue.(type) {
+	case nil:
+		return "-"
+	case string:
+		return value
+	case time.Time:
+		return value.Format(time.RFC3339Nano)
+	case error:
+		return value.Error()
+	}
+
+	return fmt.Sprint(aValue)
+} // fieldValue()
+
+// `errorChain()` returns the errors wrapped by `aErr` (depth-first,
+// excluding `aErr` itself) formatted as `type: message`.
+//
+// Both `errors.Unwrap()` and the `Unwrap() []error` method of joined
+// errors are followed.
+//
+// Parameters:
+// - `aErr`: The error to inspect.
+//
+// Returns:
+// - `[]string`: The wrapped errors.
+func errorChain(aErr error) (rChain []string) {
+	var wrapped []error
+	if multi, ok := aErr.(interface{ Unwrap() []error }); ok {
+		wrapped = multi.Unwrap()
+	} else if next := errors.Unwrap(aErr); nil != next {
+		wrapped = []error{next}
+	}
+	for _, err := range wrapped {
+		if nil == err {
+			continue
+		}
+		rChain = append(rChain, fmt.Sprintf("%T: %s", err, err.Error()))
+		rChain = append(rChain, errorChain(err)...)
+	}
+
+	return
+} // errorChain()
+
+// `errorFields()` returns the details of `aErr` as additional fields.
+//
+// The fields are `error_type`, `error_chain` (the wrapped errors
+// separated by ` <- `), and `error_stack` (the error's `%+v` output if
+// that differs from its message, as e.g. with `github.com/pkg/errors`).
+//
+// Parameters:
+// - `aErr`: The error to inspect.
+//
+// Returns:
+// - `map[string]string`: The error's details.
+func errorFields(aErr error) map[string]string {
+	result := map[string]string{
+		"error_type": fmt.Sprintf("%T", aErr),
+	}
+	if chain := errorChain(aErr); 0 < len(chain) {
+		result["error_chain"] = strings.Join(chain, " <- ")
+	}
+	if _, ok := aErr.(fmt.Formatter); ok {
+		if stack := fmt.Sprintf("%+v", aErr); stack != aErr.Error() {
+			result["error_stack"] = stack
+		}
+	}
+
+	return result
+} // errorFields()
+
+// `goContextLog()` sends a custom log message on behalf of `LogCtx()`
+// and `ErrCtx()`.
+//
+// Parameters:
+// - `aContext`: The context of the request the message belongs to.
+// - `aSender`: Identification of the message's sender.
+// - `aMessage`: The message to write to the logfile.
+// - `aMethod`: Either `LOG` or `ERR`.
+// - `aTime`: The time to log.
+// - `aLogQueue`: The queue to send the message to.
+func goContextLog(aContext context.Context, aSender, aMessage, aMethod string, aTime time.Time, aLogQueue *tRing) {
+	entry := customEntry(aSender, aMessage, aMethod, aTime)
+	if rs := requestState(aContext); nil != rs {
+		for field, value := range rs.correlation {
+			entry.SetField(field, value)
+		}
+	}
+	queueCustomEntry(entry, aLogQueue)
+} // goContextLog()
+
+// `goErrorLog()` sends an error value on behalf of `ErrE()`.
+//
+// Parameters:
+// - `aSender`: Identification of the message's sender.
+// - `aErr`: The error to write to the logfile.
+// - `aTime`: The time to log.
+// - `aLogQueue`: The queue to send the message to.
+func goErrorLog(aSender string, aErr error, aTime time.Time, aLogQueue *tRing) {
+	entry := customEntry(aSender, aErr.Error(), `ERR`, aTime)
+	entry.Fields = errorFields(aErr)
+	queueCustomEntry(entry, aLogQueue)
+} // goErrorLog()
+
+// `goFieldsLog()` sends a structured log message on behalf of
+// `LogFields()` and `ErrFields()`.
+//
+// The fields are stored as the entry's additional fields, while the
+// entry's path holds them as `key=value` pairs (with single quotes)
+// for the formats not writing additional fields.
+//
+// Parameters:
+// - `aSender`: Identification of the message's sender.
+// - `aFields`: The data to write to the logfile (see `stringFields()`).
+// - `aMethod`: Either `LOG` or `ERR`.
+// - `aTime`: The time to log.
+// - `aLogQueue`: The queue to send the message to.
+func goFieldsLog(aSender string, aFields map[string]string, aMethod string, aTime time.Time, aLogQueue *tRing) {
+	var sb strings.Builder
+	appendFields(&sb, aFields)
+	// keep the Apache-like line's quoting intact:
+	msg := strings.Replace(strings.TrimSpace(sb.String()), `"`, `'`, -1)
+
+	entry := customEntry(aSender, msg, aMethod, aTime)
+	if 0 < len(aFields) {
+		entry.Fields = aFields
+	}
+	queueCustomEntry(entry, aLogQueue)
+} // goFieldsLog()
+
+// `stringFields()` returns the textual representations of `aFields`.
+//
+// It's called by the caller's goroutine, so the caller may change or
+// reuse its map as soon as `LogFields()` or `ErrFields()` returned.
+//
+// Parameters:
+// - `aFields`: The data to write to the logfile.
+//
+// Returns:
+// - `map[string]string`: The fields' values (without empty keys).
+func stringFields(aFields map[string]interface{}) map[string]string {
+	result := make(map[string]string, len(aFields))
+	for key, value := range aFields {
+		if "" != key {
+			result[key] = fieldValue(value)
+		}
+	}
+
+	return result
+} // stringFields()
+
+/* * * * * * * * * * * * * * * * * * * * * * * * * * * * * * * * * * * * * */
+
+// `ErrCtx()` writes `aMessage` on behalf of `aSender` to the error
+// logfile.
+//
+// It works like `LogCtx()` but writes to the error logfile.
+//
+// Parameters:
+// - `aContext`: The context of the current request.
+// - `aSender`: The name/designation of the sending entity.
+// - `aMessage`: The text to write to the error logfile.
+func ErrCtx(aContext context.Context, aSender, aMessage string) {
+	spawnCustom(func(aNow time.Time) { goContextLog(aContext, aSender, aMessage, `ERR`, aNow, errorQueue()) })
+} // ErrCtx()
+
+// `ErrE()` writes `aErr` on behalf of `aSender` to the error logfile.
+//
+// The error's message is written like with `Err()`, while its type,
+// the chain of wrapped errors (following `errors.Unwrap()` as well as
+// joined errors), and – if available – its `%+v` output (e.g. a stack
+// trace) are added as the entry's fields `error_type`, `error_chain`,
+// and `error_stack` (see `LogFields()` for the output modes writing
+// them). A `nil` error is ignored.
+//
+// Parameters:
+// - `aSender`: The name/designation of the sending entity.
+// - `aErr`: The error to write to the error logfile.
+func ErrE(aSender string, aErr error) {
+	if nil == aErr {
+		return
+	}
+	spawnCustom(func(aNow time.Time) { goErrorLog(aSender, aErr, aNow, errorQueue()) })
+} // ErrE()
+
+// `Errf()` writes a message formatted like `fmt.Sprintf()` on behalf
+// of `aSender` to the error logfile.
+//
+// Parameters:
+// - `aSender`: The name/designation of the sending entity.
+// - `aFormat`: The format of the text to write to the error logfile.
+// - `aArgs`: The values to format.
+func Errf(aSender, aFormat string, aArgs ...interface{}) {
+	Err(aSender, fmt.Sprintf(aFormat, aArgs...))
+} // Errf()
+
+// `ErrFields()` writes `aFields` on behalf of `aSender` to the error
+// logfile.
+//
+// It works like `LogFields()` but writes to the error logfile.
+//
+// Parameters:
+// - `aSender`: The name/designation of the sending entity.
+// - `aFields`: The data to write to the error logfile.
+func ErrFields(aSender string, aFields map[string]interface{}) {
+	fields := stringFields(aFields)
+	spawnCustom(func(aNow time.Time) { goFieldsLog(aSender, fields, `ERR`, aNow, errorQueue()) })
+} // ErrFields()
+
+// `LogCtx()` writes `aMessage` on behalf of `aSender` to the access
+// logfile.
+//
+// A handler wrapped by `Wrap()` can call this function (passing the
+// request's `Context()`) to add the request's correlation fields (e.g.
+// `trace_id`, see `AddCorrelationHeader()`) to the message's entry, so
+// the message can be joined with the request's access log entry.
+// With a context not belonging to a wrapped request it works like
+// `Log()`.
+//
+// Parameters:
+// - `aContext`: The context of the current request.
+// - `aSender`: The name/designation of the sending entity.
+// - `aMessage`: The text to write to the access logfile.
+func LogCtx(aContext context.Context, aSender, aMessage string) {
+	if accessPaused() {
+		return
+	}
+	spawnCustom(func(aNow time.Time) { goContextLog(aContext, aSender, aMessage, `LOG`, aNow, accessQueue()) })
+} // LogCtx()
+
+// `LogFields()` writes `aFields` on behalf of `aSender` to the access
+// logfile.
+//
+// The fields become the entry's additional fields, so structured
+// output modes (e.g. `CanonicalLogLine`, `AppendFields`, `BinaryLog`,
+// `SetCSVFormat()`, or `%{name}e` of `SetLogFormat()`) write them as
+// separate values; other formats write them as `key=value` pairs
+// (quoted with single quotes) in place of the message. Strings are
+// written as they are, `time.Time` values in RFC 3339 format, errors
+// by their message, and all other values as formatted by `fmt.Sprint()`.
+//
+// Example:
+//
+//	apachelogger.LogFields("billing", map[string]interface{}{
+//		"invoice": 4711,
+//		"amount":  12.5,
+//	})
+//
+// Parameters:
+// - `aSender`: The name/designation of the sending entity.
+// - `aFields`: The data to write to the access logfile.
+func LogFields(aSender string, aFields map[string]interface{}) {
+	if accessPaused() {
+		return
+	}
+	fields := stringFields(aFields)
+	spawnCustom(func(aNow time.Time) { goFieldsLog(aSender, fields, `LOG`, aNow, accessQueue()) })
+} // LogFields()
+
+// `Logf()` writes a message formatted like `fmt.Sprintf()` on behalf
+// of `aSender` to the access logfile.
+//
+// The message isn't formatted at all while the access log is paused
+// (see `Pause()`), so calling `Logf()` costs next to nothing then.
+//
+// Parameters:
+// - `aSender`: The name/designation of the sending entity.
+// - `aFormat`: The format of the text to write to the access logfile.
+// - `aArgs`: The values to format.
+func Logf(aSender, aFormat string, aArgs ...interface{}) {
+	if accessPaused() {
+		return
+	}
+	Log(aSender, fmt.Sprintf(aFormat, aArgs...))
+} // Logf()
+
+/* _EoF_ */
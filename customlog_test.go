@@ -0,0 +1,202 @@
+/*
+Copyright © 2024  M.Watermann, 10247 Berlin, Germany
+
+	    All rights reserved
+	EMail : <support@mwat.de>
+*/
+package apachelogger
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+//lint:file-ignore ST1017 – I prefer Yoda conditions
+
+type (
+	// `tJoinedErr` wraps several errors (like `errors.Join()` does).
+	tJoinedErr []error
+
+	// `tStackErr` adds a fake stack trace to its `%+v` output.
+	tStackErr struct{ msg string }
+
+	// `tCountStringer` counts the calls of its `String()` method.
+	tCountStringer int
+)
+
+func (cs *tCountStringer) String() string {
+	*cs++
+	return "counted"
+} // String()
+
+func (je tJoinedErr) Error() string { return "joined" }
+
+func (je tJoinedErr) Unwrap() []error { return je }
+
+func (se tStackErr) Error() string { return se.msg }
+
+func (se tStackErr) Format(aState fmt.State, aVerb rune) {
+	fmt.Fprint(aState, se.msg)
+	if aState.Flag('+') {
+		fmt.Fprint(aState, "\nmain.go:42")
+	}
+} // Format()
+
+func Test_fieldValue(t *testing.T) {
+	when := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	tests := []struct {
+		name  string
+		value interface{}
+		want  string
+	}{
+		{"nil", nil, "-"},
+		{"string", "text", "text"},
+		{"int", 4711, "4711"},
+		{"float", 12.5, "12.5"},
+		{"bool", true, "true"},
+		{"time", when, "2024-01-02T03:04:05Z"},
+		{"error", errors.New("failed"), "failed"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := fieldValue(tt.value); got != tt.want {
+				t.Errorf("fieldValue() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+} // Test_fieldValue()
+
+func Test_goFieldsLog(t *testing.T) {
+	defer func() {
+		CanonicalLogLine = false
+	}()
+	queue := newRing(4)
+	fields := stringFields(map[string]interface{}{
+		"invoice": 4711,
+		"note":    "two words",
+		"":        "ignored",
+	})
+
+	goFieldsLog("billing", fields, "LOG", time.Now(), queue)
+	CanonicalLogLine = true
+	goFieldsLog("billing", fields, "LOG", time.Now(), queue)
+
+	lines := queue.popBatch(make([]string, 0, 4))
+	if 2 != len(lines) {
+		t.Fatalf("goFieldsLog() queued %d lines, want 2", len(lines))
+	}
+	if want := `"LOG invoice=4711 note='two words' HTTP/1.0"`; !strings.Contains(lines[0], want) {
+		t.Errorf("line 0 = %q, want %q", lines[0], want)
+	}
+	for _, want := range []string{` invoice=4711`, ` note="two words"`} {
+		if !strings.Contains(lines[1], want) {
+			t.Errorf("line 1 = %q, want %q", lines[1], want)
+		}
+	}
+} // Test_goFieldsLog()
+
+func TestLogFields_reuse(t *testing.T) {
+	queue, errQueue := newRing(64), newRing(64)
+	defer setLogQueues(setLogQueues(queue, errQueue))
+
+	fields := map[string]interface{}{"round": 0}
+	for round := 1; 10 >= round; round++ {
+		LogFields("test", fields)
+		fields["round"] = round // the caller reuses its map at once
+		fields[fmt.Sprint("key", round)] = round
+	}
+	for idx := 0; 10 > idx; idx++ {
+		ErrFields("test", fields)
+		delete(fields, fmt.Sprint("key", idx))
+	}
+
+	for end := time.Now().Add(5 * time.Second); time.Now().Before(end); {
+		if (10 == queue.length()) && (10 == errQueue.length()) {
+			return
+		}
+		time.Sleep(time.Millisecond)
+	}
+	t.Errorf("queued %d and %d lines, want 10 each", queue.length(), errQueue.length())
+} // TestLogFields_reuse()
+
+func Test_errorFields(t *testing.T) {
+	base := errors.New("base")
+	tests := []struct {
+		name string
+		err  error
+		want map[string]string
+	}{
+		{"plain", base, map[string]string{
+			"error_type": "*errors.errorString",
+		}},
+		{"wrapped", fmt.Errorf("outer: %w", base), map[string]string{
+			"error_type":  "*fmt.wrapError",
+			"error_chain": "*errors.errorString: base",
+		}},
+		{"joined", tJoinedErr{base, fmt.Errorf("two: %w", base)}, map[string]string{
+			"error_type":  "apachelogger.tJoinedErr",
+			"error_chain": "*errors.errorString: base <- *fmt.wrapError: two: base <- *errors.errorString: base",
+		}},
+		{"stack", tStackErr{"boom"}, map[string]string{
+			"error_type":  "apachelogger.tStackErr",
+			"error_stack": "boom\nmain.go:42",
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := errorFields(tt.err)
+			if len(got) != len(tt.want) {
+				t.Errorf("errorFields() = %v, want %v", got, tt.want)
+			}
+			for key, want := range tt.want {
+				if got[key] != want {
+					t.Errorf("errorFields()[%q] = %q, want %q", key, got[key], want)
+				}
+			}
+		})
+	}
+} // Test_errorFields()
+
+func Test_goContextLog(t *testing.T) {
+	defer func() {
+		CanonicalLogLine = false
+	}()
+	CanonicalLogLine = true
+	queue := newRing(4)
+
+	req := httptest.NewRequest("GET", "/", nil)
+	req.Header.Set("X-Amzn-Trace-Id", "Root=1-abc")
+	req, _ = withRequestState(req)
+
+	goContextLog(req.Context(), "test", "with trace", "LOG", time.Now(), queue)
+	goContextLog(context.Background(), "test", "without", "LOG", time.Now(), queue)
+
+	lines := queue.popBatch(make([]string, 0, 4))
+	if 2 != len(lines) {
+		t.Fatalf("goContextLog() queued %d lines, want 2", len(lines))
+	}
+	if want := " trace_id=Root=1-abc"; !strings.Contains(lines[0], want) {
+		t.Errorf("line 0 = %q, want %q", lines[0], want)
+	}
+	if strings.Contains(lines[1], "trace_id") {
+		t.Errorf("line 1 = %q, want no trace_id", lines[1])
+	}
+} // Test_goContextLog()
+
+func TestLogf(t *testing.T) {
+	defer Resume()
+	var cs tCountStringer
+
+	Pause()
+	Logf("test", "value: %s", &cs)
+	if 0 != cs {
+		t.Errorf("Logf() formatted %d times while paused, want 0", cs)
+	}
+} // TestLogf()
+
+/* _EoF_ */
@@ -0,0 +1,186 @@
+/*
+Copyright © 2024  M.Watermann, 10247 Berlin, Germany
+
+	    All rights reserved
+	EMail : <support@mwat.de>
+*/
+package apachelogger
+
+import (
+	"bytes"
+	"html/template"
+	"net/http"
+	"time"
+)
+
+//lint:file-ignore ST1017 – I prefer Yoda conditions
+
+type (
+	// `tDashCount` is a count with its share of the largest count.
+	tDashCount struct {
+		TCount
+		Width int // the bar's width in percent
+	}
+
+	// `tDashboard` holds the data shown by `DashboardHandler()`.
+	tDashboard struct {
+		Generated time.Time    // time the page was rendered
+		Tracking  bool         // traffic tracking is active
+		Since     time.Time    // start of the reported time span
+		Requests  int          // total number of requests
+		PerSecond float64      // average requests per second
+		Statuses  []tDashCount // the status distribution
+		Paths     []tDashCount // the most requested paths
+		Agents    []tDashCount // the most frequent user agents
+		Errors    []TEntry     // the latest failed requests
+		Health    THealth      // the state of the background writers
+	}
+)
+
+const (
+	// Number of entries per list shown by the dashboard.
+	alDashboardTop = 10
+)
+
+var (
+	// The page rendered by `DashboardHandler()`.
+	alDashboardPage = template.Must(template.New("dashboard").Parse(`<!DOCTYPE html>
+<html><head><meta charset="utf-8"><meta http-equiv="refresh" content="10"><title>access statistics</title>
+<style>body{font-family:sans-serif;font-size:small;margin:1em}table{border-collapse:collapse;margin-bottom:1em}td,th{padding:1px 6px;text-align:left;vertical-align:top}td.n{text-align:right}div.bar{background:#69c;height:1em}.bad{color:#c00}</style>
+</head><body>
+<h1>access statistics</h1>
+{{- if .Tracking}}
+<p>{{.Requests}} requests since {{.Since.Format "2006-01-02 15:04:05"}} ({{printf "%.2f" .PerSecond}}/sec)</p>
+{{- else}}
+<p class="bad">traffic tracking is not active (see <code>SetTrafficTracking()</code>)</p>
+{{- end}}
+{{- if .Health.Problem}}
+<p class="bad">{{.Health.Problem}}</p>
+{{- end}}
+{{- define "counts"}}<table>
+{{- range .}}
+<tr><td>{{.Key}}</td><td class="n">{{.Count}}</td><td style="width:20em"><div class="bar" style="width:{{.Width}}%"></div></td></tr>
+{{- else}}
+<tr><td>–</td></tr>
+{{- end}}
+</table>{{end}}
+<h2>status codes</h2>
+{{template "counts" .Statuses}}
+<h2>top paths</h2>
+{{template "counts" .Paths}}
+<h2>top user agents</h2>
+{{template "counts" .Agents}}
+<h2>recent errors</h2>
+<table>
+{{- range .Errors}}
+<tr><td>{{.When.Format "15:04:05"}}</td><td class="n bad">{{.Status}}</td><td>{{.Method}}</td><td>{{.Path}}</td><td>{{.Remote}}</td></tr>
+{{- else}}
+<tr><td>–</td></tr>
+{{- end}}
+</table>
+<p>access queue: {{.Health.Access.QueueLength}} waiting, {{.Health.Access.WriteErrors}} write errors –
+error queue: {{.Health.Error.QueueLength}} waiting, {{.Health.Error.WriteErrors}} write errors –
+generated {{.Generated.Format "2006-01-02 15:04:05"}}</p>
+</body></html>
+`))
+)
+
+// `dashCounts()` adds the bar widths to `aCounts`.
+//
+// Parameters:
+// - `aCounts`: The counts in descending order.
+//
+// Returns:
+// - `[]tDashCount`: The counts with their bar widths.
+func dashCounts(aCounts []TCount) []tDashCount {
+	result := make([]tDashCount, len(aCounts))
+	for idx, count := range aCounts {
+		result[idx].TCount = count
+		if 0 < aCounts[0].Count {
+			result[idx].Width = count.Count * 100 / aCounts[0].Count
+		}
+	}
+
+	return result
+} // dashCounts()
+
+// `dashboard()` collects the data shown by the dashboard.
+//
+// Returns:
+// - `*tDashboard`: The data to render.
+func dashboard() *tDashboard {
+	report := TrafficReport(alDashboardTop)
+	result := &tDashboard{
+		Generated: time.Now(),
+		Since:     report.Since,
+		Requests:  report.Requests,
+		Statuses:  dashCounts(TrafficReport(0).Statuses),
+		Paths:     dashCounts(report.Paths),
+		Agents:    dashCounts(report.Agents),
+		Health:    Health(),
+	}
+	alTraffic.Lock()
+	result.Tracking = 0 < alTraffic.window
+	alTraffic.Unlock()
+
+	if elapsed := result.Generated.Sub(result.Since).Seconds(); 1 <= elapsed {
+		result.PerSecond = float64(result.Requests) / elapsed
+	} else {
+		result.PerSecond = float64(result.Requests)
+	}
+	result.Errors = Recent(TRecentFilter{
+		MinStatus: http.StatusInternalServerError,
+		Limit:     alDashboardTop,
+	})
+	// show the latest error first:
+	for left, right := 0, len(result.Errors)-1; left < right; left, right = left+1, right-1 {
+		result.Errors[left], result.Errors[right] = result.Errors[right], result.Errors[left]
+	}
+
+	return result
+} // dashboard()
+
+/* * * * * * * * * * * * * * * * * * * * * * * * * * * * * * * * * * * * * */
+
+// `DashboardHandler()` returns a handler rendering a small HTML page
+// with the server's current traffic – a mini-GoAccess for deployments
+// consisting of a single binary.
+//
+// The page shows the requests per second, the status distribution,
+// the top paths and user agents, and the latest server errors (status
+// `5xx`). It's computed from the in-process counters, so traffic
+// tracking must be active (see `SetTrafficTracking()`) and the recent
+// errors require `SetRecentSize()`; the page refreshes itself every
+// ten seconds.
+//
+// Since the page may contain personal data `aAuthorise` should check
+// the request's credentials; it returns `true` to allow the request,
+// otherwise the response's status is `403`. A `nil` function allows
+// all requests, so use it only with a handler protected otherwise.
+//
+// Parameters:
+// - `aAuthorise`: The function deciding whether a request may see the page.
+//
+// Returns:
+// - `http.Handler`: The handler to register with your router.
+func DashboardHandler(aAuthorise func(aRequest *http.Request) bool) http.Handler {
+	return http.HandlerFunc(
+		func(aWriter http.ResponseWriter, aRequest *http.Request) {
+			if (nil != aAuthorise) && !aAuthorise(aRequest) {
+				http.Error(aWriter, http.StatusText(http.StatusForbidden),
+					http.StatusForbidden)
+				return
+			}
+			var page bytes.Buffer
+			if err := alDashboardPage.Execute(&page, dashboard()); nil != err {
+				http.Error(aWriter, err.Error(), http.StatusInternalServerError)
+				return
+			}
+
+			aWriter.Header().Set("Content-Type", "text/html; charset=utf-8")
+			aWriter.Header().Set("Cache-Control", "no-store")
+			_, _ = aWriter.Write(page.Bytes())
+		})
+} // DashboardHandler()
+
+/* _EoF_ */
@@ -0,0 +1,75 @@
+/*
+Copyright © 2024  M.Watermann, 10247 Berlin, Germany
+
+	    All rights reserved
+	EMail : <support@mwat.de>
+*/
+package apachelogger
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+//lint:file-ignore ST1017 – I prefer Yoda conditions
+
+func TestDashboardHandler(t *testing.T) {
+	defer SetTrafficTracking(0, 0)
+	defer SetRecentSize(0)
+
+	handler := DashboardHandler(func(aRequest *http.Request) bool {
+		return "secret" == aRequest.URL.Query().Get("token")
+	})
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest("GET", "/stats", nil))
+	if http.StatusForbidden != rec.Code {
+		t.Errorf("DashboardHandler() status = %d, want %d", rec.Code, http.StatusForbidden)
+	}
+
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest("GET", "/stats?token=secret", nil))
+	if body := rec.Body.String(); !strings.Contains(body, "traffic tracking is not active") {
+		t.Errorf("DashboardHandler() body misses the tracking hint:\n%s", body)
+	}
+
+	SetTrafficTracking(time.Minute, 0)
+	SetRecentSize(4)
+	e1 := prepEntry()
+	e1.Agent = "<script>"
+	countTraffic(e1)
+	e2 := prepEntry()
+	e2.Path, e2.Status = "/broken", 502
+	countTraffic(e2)
+	alRecent.add(e1)
+	alRecent.add(e2)
+
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest("GET", "/stats?token=secret", nil))
+	body := rec.Body.String()
+	if ct := rec.Header().Get("Content-Type"); !strings.HasPrefix(ct, "text/html") {
+		t.Errorf("DashboardHandler() Content-Type = %q, want text/html", ct)
+	}
+	for _, want := range []string{"2 requests since", ">502<", ">/broken<", "&lt;script&gt;"} {
+		if !strings.Contains(body, want) {
+			t.Errorf("DashboardHandler() body misses %q:\n%s", want, body)
+		}
+	}
+	if strings.Contains(body, "<script>") {
+		t.Errorf("DashboardHandler() body contains unescaped user agent")
+	}
+} // TestDashboardHandler()
+
+func Test_dashCounts(t *testing.T) {
+	got := dashCounts([]TCount{{"a", 4}, {"b", 1}})
+	if (100 != got[0].Width) || (25 != got[1].Width) {
+		t.Errorf("dashCounts() = %v, want widths 100 and 25", got)
+	}
+	if got := dashCounts(nil); 0 != len(got) {
+		t.Errorf("dashCounts(nil) = %v, want empty", got)
+	}
+} // Test_dashCounts()
+
+/* _EoF_ */
@@ -0,0 +1,244 @@
+/*
+Copyright © 2024  M.Watermann, 10247 Berlin, Germany
+
+	    All rights reserved
+	EMail : <support@mwat.de>
+*/
+package apachelogger
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+//lint:file-ignore ST1017 – I prefer Yoda conditions
+
+const (
+	// `DayChangeNone` writes nothing when the day changes.
+	DayChangeNone = iota
+
+	// `DayChangeBlankLine` writes an empty line when the day changes
+	// (the module's former behaviour).
+	DayChangeBlankLine
+
+	// `DayChangeMarker` writes a marker entry when the day changes
+	// (default).
+	DayChangeMarker
+
+	// `DayChangeRotate` renames the logfile when the day changes, so
+	// every day gets a file of its own.
+	DayChangeRotate
+)
+
+var (
+	// `DayChange` decides what to write when the first entry of a new
+	// day is logged.
+	//
+	// Possible values are `DayChangeNone`, `DayChangeBlankLine`,
+	// `DayChangeMarker` (default), and `DayChangeRotate`. The marker is
+	// a regular entry in the current output format sent by `apachelogger`
+	// with the method `DAY` and the new date as its path, so strict
+	// parsers can handle (or skip) it like any other entry.
+	// With `DayChangeRotate` the logfile is renamed to carry the date
+	// of the day just ended (e.g. `access.log.2024-01-02`); sinks not
+	// writing to a file get a marker entry instead.
+	DayChange = DayChangeMarker
+
+	// The function called with each rotated logfile (see
+	// `SetRotationHook()`).
+	alRotationHook struct {
+		sync.RWMutex
+		hook func(aRotatedFile string)
+	}
+)
+
+type (
+	// `tRotator` is implemented by sinks that can rotate their logfile.
+	tRotator interface {
+		// `rotate()` closes the logfile and renames it for `aDay`.
+		rotate(aDay time.Time) error
+	}
+)
+
+// `dayChange()` handles the change of the logging day according to
+// `DayChange`.
+//
+// Parameters:
+// - `aSink`: The sink the day's entries are written to.
+// - `aBuffer`: The buffer to append a separator to.
+// - `aPrevious`: The time of the previous batch of entries.
+// - `aNow`: The time of the current batch of entries.
+// - `aSettings`: The settings of the log (for its time zone).
+//
+// Returns:
+// - `[]byte`: The (possibly) extended buffer.
+// - `error`: A possible error while rotating the logfile.
+func dayChange(aSink TSink, aBuffer []byte, aPrevious, aNow time.Time, aSettings *tLogSettings) ([]byte, error) {
+	var err error
+	switch aSettings.dayChangeMode() {
+	case DayChangeBlankLine:
+		if !BinaryLog {
+			aBuffer = append(aBuffer, '\n')
+		}
+
+	case DayChangeMarker:
+		aBuffer = append(aBuffer, dayMarker(aNow, aSettings)...)
+
+	case DayChangeRotate:
+		if rs, ok := aSink.(tRotator); ok {
+			err = rs.rotate(aSettings.in(aPrevious))
+		} else {
+			aBuffer = append(aBuffer, dayMarker(aNow, aSettings)...)
+		}
+	}
+
+	return aBuffer, err
+} // dayChange()
+
+// `dayMarker()` returns the marker entry for the day of `aNow`.
+//
+// Parameters:
+// - `aNow`: The time of the first entry of the new day.
+// - `aSettings`: The settings of the log (for its time zone).
+//
+// Returns:
+// - `string`: The formatted marker entry.
+func dayMarker(aNow time.Time, aSettings *tLogSettings) string {
+	entry := &TEntry{
+		Remote:   "127.0.0.1",
+		User:     alCurrentUser,
+		When:     aNow,
+		Method:   "DAY",
+		Path:     aSettings.in(aNow).Format("2006-01-02"),
+		Proto:    "HTTP/1.0",
+		Status:   200,
+		Referrer: "apachelogger",
+		Agent:    "mwat56/apachelogger",
+		settings: aSettings,
+	}
+
+	return formatEntry(entry)
+} // dayMarker()
+
+// `rotatedName()` returns the name the logfile `aFilename` gets when
+// it's rotated for `aDay`.
+//
+// A `.gz` extension is kept at the end of the name.
+//
+// Parameters:
+// - `aFilename`: The current name of the logfile.
+// - `aDay`: The day of the entries in the logfile (in the log's time
+// zone).
+//
+// Returns:
+// - `string`: The logfile's new name.
+func rotatedName(aFilename string, aDay time.Time) string {
+	day := aDay.Format("2006-01-02")
+	if strings.HasSuffix(aFilename, ".gz") {
+		return strings.TrimSuffix(aFilename, ".gz") + "." + day + ".gz"
+	}
+
+	return aFilename + "." + day
+} // rotatedName()
+
+// `rotateFile()` renames the (closed) logfile `aFilename` for `aDay`
+// and passes the new name to the hook set by `SetRotationHook()`.
+//
+// If a file with the new name exists already the logfile is kept and
+// written to further on, and an error wrapping `os.ErrExist` is
+// returned.
+//
+// Parameters:
+// - `aFilename`: The name of the logfile to rotate.
+// - `aDay`: The day of the entries in the logfile.
+//
+// Returns:
+// - `error`: A possible error while renaming the logfile.
+func rotateFile(aFilename string, aDay time.Time) error {
+	newName := rotatedName(aFilename, aDay)
+	if _, err := os.Stat(newName); nil == err {
+		return fmt.Errorf("apachelogger: can't rotate %s: %s: %w",
+			aFilename, newName, os.ErrExist)
+	}
+
+	if err := os.Rename(aFilename, newName); nil != err {
+		return err
+	}
+
+	alRotationHook.RLock()
+	hook := alRotationHook.hook
+	alRotationHook.RUnlock()
+	if nil != hook {
+		go hook(newName)
+	}
+
+	return nil
+} // rotateFile()
+
+/* * * * * * * * * * * * * * * * * * * * * * * * * * * * * * * * * * * * * */
+
+// `rotate()` closes the logfile and renames it for `aDay`.
+//
+// Part of the `tRotator` interface.
+//
+// Parameters:
+// - `aDay`: The day of the entries in the logfile.
+//
+// Returns:
+// - `error`: A possible error while closing or renaming the logfile.
+func (fs *tFileSink) rotate(aDay time.Time) error {
+	if err := fs.Close(); nil != err {
+		return err
+	}
+
+	return rotateFile(fs.name, aDay)
+} // rotate()
+
+// `rotate()` finishes the current gzip member, closes the logfile,
+// and renames it for `aDay`.
+//
+// Part of the `tRotator` interface.
+//
+// Parameters:
+// - `aDay`: The day of the entries in the logfile.
+//
+// Returns:
+// - `error`: A possible error while closing or renaming the logfile.
+func (gs *tGzipFileSink) rotate(aDay time.Time) error {
+	if err := gs.Close(); nil != err {
+		return err
+	}
+
+	return rotateFile(gs.name, aDay)
+} // rotate()
+
+/* * * * * * * * * * * * * * * * * * * * * * * * * * * * * * * * * * * * * */
+
+// `SetRotationHook()` sets a function called with the name of each
+// logfile rotated by `DayChangeRotate`, e.g. to upload it to an
+// object storage (S3, GCS, MinIO) and remove it locally afterwards:
+//
+//	apachelogger.SetRotationHook(func(aRotatedFile string) {
+//		if nil == uploadToBucket(aRotatedFile) {
+//			_ = os.Remove(aRotatedFile)
+//		}
+//	})
+//
+// The hook runs in a goroutine of its own once the logfile is closed
+// and renamed, so a slow upload doesn't delay the logging; the file
+// isn't touched by the logger afterwards. `NewArchiver()` returns a
+// hook for S3 compatible storages which retries failed uploads and
+// reports the logfiles it couldn't archive.
+//
+// Parameters:
+// - `aHook`: The function to call (`nil` to remove the hook).
+func SetRotationHook(aHook func(aRotatedFile string)) {
+	alRotationHook.Lock()
+	alRotationHook.hook = aHook
+	alRotationHook.Unlock()
+} // SetRotationHook()
+
+/* _EoF_ */
@@ -0,0 +1,133 @@
+/*
+Copyright © 2024  M.Watermann, 10247 Berlin, Germany
+
+	    All rights reserved
+	EMail : <support@mwat.de>
+*/
+package apachelogger
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+//lint:file-ignore ST1017 – I prefer Yoda conditions
+
+func Test_dayChange(t *testing.T) {
+	defer func(aMode int) {
+		DayChange = aMode
+	}(DayChange)
+	var (
+		prev = time.Date(2024, 1, 2, 23, 59, 59, 0, time.Local)
+		now  = prev.Add(2 * time.Second)
+	)
+
+	tests := []struct {
+		name string
+		mode int
+		want string
+	}{
+		{"none", DayChangeNone, ""},
+		{"blank", DayChangeBlankLine, "\n"},
+		{"marker", DayChangeMarker, `"DAY 2024-01-03 HTTP/1.0" 200 `},
+		{"rotate", DayChangeRotate, `"DAY 2024-01-03 HTTP/1.0" 200 `},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			DayChange = tt.mode
+			buf, err := dayChange(&tMemSink{}, nil, prev, now, nil)
+			if nil != err {
+				t.Errorf("dayChange() error = %v", err)
+			}
+			got := string(buf)
+			if ("" == tt.want) && ("" != got) {
+				t.Errorf("dayChange() = %q, want %q", got, tt.want)
+			} else if !strings.Contains(got, tt.want) {
+				t.Errorf("dayChange() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+} // Test_dayChange()
+
+func Test_dayChange_rotate(t *testing.T) {
+	defer func(aMode int) {
+		DayChange = aMode
+	}(DayChange)
+	DayChange = DayChangeRotate
+	var (
+		dir   = t.TempDir()
+		fName = filepath.Join(dir, "access.log")
+		sink  = NewFileSink(fName)
+		prev  = time.Date(2024, 1, 2, 23, 59, 59, 0, time.Local)
+	)
+
+	_ = sink.Write([]byte("line 1\n"))
+	if buf, err := dayChange(sink, nil, prev, prev.Add(time.Minute), nil); (0 < len(buf)) || (nil != err) {
+		t.Errorf("dayChange() = %q, %v, want empty", buf, err)
+	}
+	_ = sink.Write([]byte("line 2\n"))
+	_ = sink.Close()
+
+	for name, want := range map[string]string{
+		fName:                 "line 2\n",
+		fName + ".2024-01-02": "line 1\n",
+	} {
+		got, err := os.ReadFile(name) // #nosec G304
+		if nil != err {
+			t.Fatalf("os.ReadFile() error = %v", err)
+		}
+		if want != string(got) {
+			t.Errorf("%s = %q, want %q", name, got, want)
+		}
+	}
+
+	if got, want := rotatedName("a.log.gz", prev), "a.log.2024-01-02.gz"; got != want {
+		t.Errorf("rotatedName() = %q, want %q", got, want)
+	}
+} // Test_dayChange_rotate()
+
+func TestSetRotationHook(t *testing.T) {
+	defer SetRotationHook(nil)
+	rotated := make(chan string, 1)
+	SetRotationHook(func(aRotatedFile string) {
+		rotated <- aRotatedFile
+	})
+	var (
+		fName = filepath.Join(t.TempDir(), "access.log.gz")
+		sink  = NewGzipFileSink(fName, time.Second)
+		day   = time.Date(2024, 1, 2, 12, 0, 0, 0, time.Local)
+	)
+
+	_ = sink.Write([]byte("line 1\n"))
+	if err := sink.(tRotator).rotate(day); nil != err {
+		t.Fatalf("rotate() error = %v", err)
+	}
+	select {
+	case got := <-rotated:
+		if want := filepath.Join(filepath.Dir(fName), "access.log.2024-01-02.gz"); want != got {
+			t.Errorf("hook got %q, want %q", got, want)
+		}
+		if _, err := os.Stat(got); nil != err {
+			t.Errorf("rotated file: %v", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("hook wasn't called")
+	}
+
+	// a failed rotation doesn't call the hook:
+	_ = sink.Write([]byte("line 2\n"))
+	if err := sink.(tRotator).rotate(day); !errors.Is(err, os.ErrExist) {
+		t.Errorf("rotate() error = %v, want os.ErrExist for an existing file", err)
+	}
+	select {
+	case got := <-rotated:
+		t.Errorf("hook called with %q for a failed rotation", got)
+	case <-time.After(50 * time.Millisecond):
+	}
+} // TestSetRotationHook()
+
+/* _EoF_ */
@@ -0,0 +1,281 @@
+/*
+Copyright © 2024  M.Watermann, 10247 Berlin, Germany
+
+	    All rights reserved
+	EMail : <support@mwat.de>
+*/
+package apachelogger
+
+import (
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+)
+
+//lint:file-ignore ST1017 – I prefer Yoda conditions
+
+type (
+	// `tDuplicateKey` identifies a burst of identical requests.
+	tDuplicateKey struct {
+		remote string // the (logged) client address
+		path   string // the (logged) request path
+		status int    // the response status
+	}
+
+	// `tDuplicateBurst` holds the suppressed requests of a burst.
+	tDuplicateBurst struct {
+		since time.Time // time the burst's first request was logged
+		first time.Time // time of the first suppressed request
+		last  time.Time // time of the latest suppressed request
+		count int       // number of suppressed requests
+		queue *tRing    // the queue of the burst's entries
+	}
+)
+
+const (
+	// Max. number of bursts tracked at the same time.
+	alDuplicateMaxBursts = 10000
+)
+
+var (
+	// The duplicate suppression's state.
+	alDuplicates struct {
+		sync.Mutex
+		bursts map[tDuplicateKey]*tDuplicateBurst // the current bursts
+		window time.Duration                      // length of a burst
+		stop   chan struct{}                      // stops the periodic output
+	}
+)
+
+// `duplicateEntry()` returns the log entry summarising the suppressed
+// requests of a burst.
+//
+// Parameters:
+// - `aKey`: The burst's identification.
+// - `aBurst`: The burst's suppressed requests.
+//
+// Returns:
+// - `*TEntry`: The summary entry.
+func duplicateEntry(aKey tDuplicateKey, aBurst *tDuplicateBurst) *TEntry {
+	settings := aBurst.queue.logSettings()
+
+	return &TEntry{
+		Remote:   aKey.remote,
+		User:     "-",
+		When:     aBurst.last,
+		Method:   "REPEAT",
+		Path:     aKey.path,
+		Proto:    "HTTP/1.0",
+		Status:   aKey.status,
+		Referrer: "apachelogger",
+		Agent:    "mwat56/apachelogger",
+		Fields: map[string]string{
+			"repeat_start": settings.in(aBurst.first).Format(time.RFC3339),
+			"repeat_end":   settings.in(aBurst.last).Format(time.RFC3339),
+			"repeat_count": strconv.Itoa(aBurst.count),
+		},
+		settings: settings,
+		summary:  true,
+	}
+} // duplicateEntry()
+
+// `duplicateLine()` returns the log line summarising the suppressed
+// requests of a burst in the current output format.
+//
+// Parameters:
+// - `aKey`: The burst's identification.
+// - `aBurst`: The burst's suppressed requests.
+//
+// Returns:
+// - `string`: The log line.
+func duplicateLine(aKey tDuplicateKey, aBurst *tDuplicateBurst) string {
+	return formatEntry(duplicateEntry(aKey, aBurst))
+} // duplicateLine()
+
+// `expireDuplicates()` ends all bursts started before `aBefore`.
+//
+// The caller must hold the lock of `alDuplicates`.
+//
+// Parameters:
+// - `aBefore`: The time before which bursts end (zero for all).
+//
+// Returns:
+// - `map[*tRing][]string`: The summaries of the bursts with suppressed
+// requests, by the queue to send them to.
+func expireDuplicates(aBefore time.Time) map[*tRing][]string {
+	result := make(map[*tRing][]string)
+	for key, burst := range alDuplicates.bursts {
+		if !aBefore.IsZero() && !burst.since.Before(aBefore) {
+			continue
+		}
+		delete(alDuplicates.bursts, key)
+		if 0 < burst.count {
+			result[burst.queue] = append(result[burst.queue], duplicateLine(key, burst))
+		}
+	}
+	for _, lines := range result {
+		sort.Strings(lines)
+	}
+
+	return result
+} // expireDuplicates()
+
+// `goSendDuplicates()` sends the summaries of ended bursts to their
+// queues.
+//
+// Parameters:
+// - `aLines`: The summaries by the queue to send them to.
+func goSendDuplicates(aLines map[*tRing][]string) {
+	for queue, lines := range aLines {
+		goSendLines(lines, queue)
+	}
+} // goSendDuplicates()
+
+// `goExpireDuplicates()` periodically ends the bursts older than
+// `aWindow`.
+//
+// Parameters:
+// - `aWindow`: The length of a burst.
+// - `aStop`: Closed to stop the expiration.
+func goExpireDuplicates(aWindow time.Duration, aStop <-chan struct{}) {
+	interval := aWindow >> 1
+	if 0 >= interval {
+		interval = aWindow
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-aStop:
+			return
+		case now := <-ticker.C:
+			alDuplicates.Lock()
+			lines := expireDuplicates(now.Add(-aWindow))
+			alDuplicates.Unlock()
+			goSendDuplicates(lines)
+		}
+	}
+} // goExpireDuplicates()
+
+// `isDuplicate()` checks whether `aEntry` repeats a request logged
+// within the current window, without counting it.
+//
+// Parameters:
+// - `aEntry`: The (prepared) access log entry.
+//
+// Returns:
+// - `bool`: `true` if the entry belongs to a burst of duplicates.
+func isDuplicate(aEntry *TEntry) bool {
+	alDuplicates.Lock()
+	defer alDuplicates.Unlock()
+
+	if nil == alDuplicates.bursts {
+		return false
+	}
+	key := tDuplicateKey{remote: aEntry.Remote, path: aEntry.Path, status: aEntry.Status}
+	burst, ok := alDuplicates.bursts[key]
+
+	return ok && (alDuplicates.window > aEntry.When.Sub(burst.since))
+} // isDuplicate()
+
+// `suppressDuplicate()` checks whether `aEntry` repeats a request
+// logged within the current window.
+//
+// Parameters:
+// - `aEntry`: The (prepared) access log entry.
+// - `aLogQueue`: The queue the entry would be sent to.
+//
+// Returns:
+// - `bool`: `true` if the entry was counted (and mustn't be logged).
+func suppressDuplicate(aEntry *TEntry, aLogQueue *tRing) bool {
+	var (
+		line  string
+		queue *tRing
+	)
+
+	alDuplicates.Lock()
+	if nil == alDuplicates.bursts {
+		alDuplicates.Unlock()
+		return false
+	}
+
+	key := tDuplicateKey{remote: aEntry.Remote, path: aEntry.Path, status: aEntry.Status}
+	burst, ok := alDuplicates.bursts[key]
+	if ok && (alDuplicates.window > aEntry.When.Sub(burst.since)) {
+		if 0 == burst.count {
+			burst.first = aEntry.When
+		}
+		burst.count++
+		if aEntry.When.After(burst.last) {
+			burst.last = aEntry.When
+		}
+		alDuplicates.Unlock()
+		return true
+	}
+	if ok { // the burst's window is over
+		delete(alDuplicates.bursts, key)
+		if 0 < burst.count {
+			line, queue = duplicateLine(key, burst), burst.queue
+		}
+	}
+	if alDuplicateMaxBursts > len(alDuplicates.bursts) {
+		alDuplicates.bursts[key] = &tDuplicateBurst{since: aEntry.When, queue: aLogQueue}
+	}
+	alDuplicates.Unlock()
+
+	if "" != line { // keep the summary before the new burst's entry
+		queue.push(line)
+	}
+
+	return false
+} // suppressDuplicate()
+
+/* * * * * * * * * * * * * * * * * * * * * * * * * * * * * * * * * * * * * */
+
+// `SetDuplicateWindow()` switches the suppression of duplicate access
+// log entries on or off.
+//
+// Vulnerability scanners and misbehaving clients tend to send the same
+// request over and over, bloating the access log. With the suppression
+// active the first request of a client for a path with a given status
+// is logged as usual, while identical requests (same client address,
+// path, and status) within the following `aWindow` are only counted.
+// When the window ends the suppressed requests are written as a single
+// entry in the current output format with the method `REPEAT`, the
+// burst's client, path, and status, and the fields `repeat_start`,
+// `repeat_end`, and `repeat_count`, e.g.
+//
+//	192.0.2.1 - - [25/Apr/2024:20:00:09 +0200] "REPEAT /wp-login.php HTTP/1.0" 404 0 "apachelogger" "mwat56/apachelogger" repeat_count=87 repeat_end=2024-04-25T20:00:09+02:00 repeat_start=2024-04-25T20:00:01+02:00
+//
+// The Apache-like lines always carry these fields (regardless of
+// `AppendFields`).
+//
+// The next identical request after the window is logged again, starting
+// a new window. Traffic counters and alerts still see every request.
+//
+// Switching the suppression off writes the counts of all open windows.
+//
+// Parameters:
+// - `aWindow`: The length of a burst (`0` switches the suppression off).
+func SetDuplicateWindow(aWindow time.Duration) {
+	alDuplicates.Lock()
+	defer alDuplicates.Unlock()
+
+	if nil != alDuplicates.stop {
+		close(alDuplicates.stop)
+		alDuplicates.stop = nil
+		go goSendDuplicates(expireDuplicates(time.Time{}))
+	}
+	if 0 >= aWindow {
+		alDuplicates.bursts = nil
+		return
+	}
+	alDuplicates.bursts = make(map[tDuplicateKey]*tDuplicateBurst)
+	alDuplicates.window = aWindow
+	alDuplicates.stop = make(chan struct{})
+	go goExpireDuplicates(aWindow, alDuplicates.stop)
+} // SetDuplicateWindow()
+
+/* _EoF_ */
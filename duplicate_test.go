@@ -0,0 +1,93 @@
+/*
+Copyright © 2024  M.Watermann, 10247 Berlin, Germany
+
+	    All rights reserved
+	EMail : <support@mwat.de>
+*/
+package apachelogger
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+//lint:file-ignore ST1017 – I prefer Yoda conditions
+
+func Test_suppressDuplicate(t *testing.T) {
+	defer func() {
+		alDuplicates.Lock()
+		if nil != alDuplicates.stop {
+			close(alDuplicates.stop)
+			alDuplicates.stop = nil
+		}
+		alDuplicates.bursts = nil
+		alDuplicates.Unlock()
+	}()
+	e := prepEntry()
+	if suppressDuplicate(e, accessQueue()) {
+		t.Error("suppressDuplicate() = true, want false (mode off)")
+	}
+
+	SetDuplicateWindow(time.Hour)
+	start := e.When
+	if suppressDuplicate(e, accessQueue()) {
+		t.Fatal("suppressDuplicate() = true for the first entry")
+	}
+	for i := 1; 4 > i; i++ {
+		d := prepEntry()
+		d.When = start.Add(time.Duration(i) * time.Minute)
+		if !suppressDuplicate(d, accessQueue()) {
+			t.Fatalf("suppressDuplicate() = false for duplicate %d", i)
+		}
+	}
+	other := prepEntry()
+	other.Status = 404
+	if suppressDuplicate(other, accessQueue()) {
+		t.Error("suppressDuplicate() = true for another status")
+	}
+	other = prepEntry()
+	other.Remote = "192.0.2.99"
+	if suppressDuplicate(other, accessQueue()) {
+		t.Error("suppressDuplicate() = true for another client")
+	}
+
+	alDuplicates.Lock()
+	lines := expireDuplicates(start.Add(time.Nanosecond))[accessQueue()]
+	remaining := len(alDuplicates.bursts)
+	alDuplicates.Unlock()
+	if (1 != len(lines)) || (0 != remaining) {
+		t.Fatalf("expireDuplicates() = %q, %d bursts remaining", lines, remaining)
+	}
+	want := `"REPEAT ` + e.Path + ` HTTP/1.0" 200 0 "apachelogger" "mwat56/apachelogger"` +
+		" repeat_count=3 repeat_end=" + start.Add(3*time.Minute).Format(time.RFC3339) +
+		" repeat_start=" + start.Add(time.Minute).Format(time.RFC3339) + "\n"
+	if !strings.HasPrefix(lines[0], e.Remote+" - - [") ||
+		!strings.HasSuffix(lines[0], want) {
+		t.Errorf("line = %q, want suffix %q", lines[0], want)
+	}
+
+	if suppressDuplicate(e, accessQueue()) {
+		t.Error("suppressDuplicate() = true after the window ended")
+	}
+} // Test_suppressDuplicate()
+
+func Test_duplicateLine(t *testing.T) {
+	defer func(aBinary bool) { BinaryLog = aBinary }(BinaryLog)
+	start := time.Date(2024, 4, 25, 20, 0, 1, 0, time.UTC)
+	key := tDuplicateKey{remote: "192.0.2.1", path: "/wp-login.php", status: 404}
+	burst := &tDuplicateBurst{first: start, last: start.Add(8 * time.Second), count: 87}
+
+	BinaryLog = true
+	entry, err := NewBinaryReader(strings.NewReader(duplicateLine(key, burst))).Next()
+	if nil != err {
+		t.Fatalf("Next() error = %v", err)
+	}
+	if ("REPEAT" != entry.Method) || (key.remote != entry.Remote) ||
+		(key.path != entry.Path) || (key.status != entry.Status) ||
+		("87" != entry.Fields["repeat_count"]) {
+		t.Errorf("Next() = %+v, want the REPEAT summary", entry)
+	}
+} // Test_duplicateLine()
+
+/* _EoF_ */
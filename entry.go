@@ -0,0 +1,324 @@
+/*
+Copyright © 2024  M.Watermann, 10247 Berlin, Germany
+
+	    All rights reserved
+	EMail : <support@mwat.de>
+*/
+package apachelogger
+
+import (
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+//lint:file-ignore ST1017 – I prefer Yoda conditions
+
+type (
+	// `TEntry` holds the data of a single logfile entry before it
+	// gets formatted.
+	TEntry struct {
+		Remote   string    // remote address (possibly anonymised)
+		User     string    // name of the remote user
+		When     time.Time // time the request was received
+		Method   string    // request method
+		Path     string    // requested path (and CGI query)
+		Proto    string    // request protocol
+		Status   int       // HTTP status code
+		Size     int       // size of the data sent
+		Referrer string    // remote referrer
+		Agent    string    // remote user agent
+
+		// Time taken to serve the request (if measured).
+		Duration time.Duration
+
+		// Additional fields attached to the entry.
+		Fields map[string]string
+
+		// Request headers needed by the log format (see `SetLogFormat()`).
+		headers map[string]string
+
+		// Notes set by other middlewares (see `SetNote()`).
+		notes map[string]string
+
+		// Origin of a custom log entry (see `JSONErrorLog`); `nil` for
+		// the entries of requests.
+		origin *tErrorOrigin
+
+		// Full remote address (see `SetFieldEncryption()`).
+		address string
+
+		// The settings of the entry's log (see `WrapWith()`).
+		settings *tLogSettings
+
+		// Whether the entry summarises other requests, so the
+		// Apache-like lines always carry its fields (regardless of
+		// `AppendFields`).
+		summary bool
+	}
+
+	// `TTransformer` is a function that may modify an entry before
+	// it is formatted and written to the logfile.
+	TTransformer func(aEntry *TEntry)
+)
+
+const (
+	// Buffers larger than this are not returned to `alLinePool`.
+	alMaxPooledLine = 64 << 10
+)
+
+var (
+	// Pool of buffers used to format the logfile lines.
+	alLinePool = sync.Pool{
+		New: func() interface{} {
+			buf := make([]byte, 0, 512)
+			return &buf
+		},
+	}
+
+	// Static fields added to every log entry.
+	alStaticFields map[string]string
+
+	// Guard for concurrent access to `alStaticFields`.
+	alStaticFieldsMtx sync.RWMutex
+
+	// List of registered entry transformers.
+	alTransformers []TTransformer
+
+	// Guard for concurrent access to `alTransformers`.
+	alTransformersMtx sync.RWMutex
+)
+
+// `SetField()` sets the additional field `aKey` to `aValue`.
+//
+// Parameters:
+// - `aKey`: The name of the field.
+// - `aValue`: The field's value.
+func (e *TEntry) SetField(aKey, aValue string) {
+	if nil == e.Fields {
+		e.Fields = make(map[string]string)
+	}
+	e.Fields[aKey] = aValue
+} // SetField()
+
+// `stringField()` returns a pointer to the entry's text field named
+// `aName`.
+//
+// Known field names are `remote`, `user`, `method`, `path`, `proto`,
+// `referrer`, and `agent`.
+//
+// Parameters:
+// - `aName`: The name of the field to look up.
+//
+// Returns:
+// - `*string`: The field's address or `nil` if `aName` is unknown.
+func (e *TEntry) stringField(aName string) *string {
+	switch strings.ToLower(aName) {
+	case "remote":
+		return &e.Remote
+	case "user":
+		return &e.User
+	case "method":
+		return &e.Method
+	case "path":
+		return &e.Path
+	case "proto":
+		return &e.Proto
+	case "referrer", "referer":
+		return &e.Referrer
+	case "agent":
+		return &e.Agent
+	}
+
+	return nil
+} // stringField()
+
+// `AppendText()` appends the entry formatted as an Apache-like logfile
+// line (including the trailing newline) to `aBuffer`, e.g.
+//
+//	91.64.58.0 - username [25/Apr/2018:20:16:45 +0200] "GET /path/to/file?lang=en HTTP/1.1" 200 27155 "-" "Mozilla/5.0 (X11; Linux x86_64; rv:56.0) Gecko/20100101 Firefox/56.0"
+//
+// It doesn't allocate if `aBuffer` has enough capacity.
+//
+// Parameters:
+// - `aBuffer`: The buffer to append to.
+//
+// Returns:
+// - `[]byte`: The extended buffer.
+func (e *TEntry) AppendText(aBuffer []byte) []byte {
+	aBuffer = append(aBuffer, e.Remote...)
+	aBuffer = append(aBuffer, " - "...)
+	aBuffer = append(aBuffer, e.User...)
+	aBuffer = append(aBuffer, " ["...)
+	aBuffer = e.timestamp().AppendFormat(aBuffer, "02/Jan/2006:15:04:05 -0700")
+	aBuffer = append(aBuffer, `] "`...)
+	aBuffer = append(aBuffer, e.Method...)
+	aBuffer = append(aBuffer, ' ')
+	aBuffer = append(aBuffer, e.Path...)
+	aBuffer = append(aBuffer, ' ')
+	aBuffer = append(aBuffer, e.Proto...)
+	aBuffer = append(aBuffer, `" `...)
+	aBuffer = strconv.AppendInt(aBuffer, int64(e.Status), 10)
+	aBuffer = append(aBuffer, ' ')
+	aBuffer = strconv.AppendInt(aBuffer, int64(e.Size), 10)
+	aBuffer = append(aBuffer, ` "`...)
+	aBuffer = append(aBuffer, e.Referrer...)
+	aBuffer = append(aBuffer, `" "`...)
+	aBuffer = append(aBuffer, e.Agent...)
+	aBuffer = append(aBuffer, "\"\n"...)
+
+	return aBuffer
+} // AppendText()
+
+// `String()` returns the entry formatted as an Apache-like logfile line.
+//
+// Returns:
+// - `string`: The formatted logfile line.
+func (e *TEntry) String() string {
+	buf := getLineBuffer()
+	*buf = e.AppendText((*buf)[:0])
+	result := string(*buf)
+	putLineBuffer(buf)
+
+	return result
+} // String()
+
+// `getLineBuffer()` returns an empty buffer from the pool.
+//
+// Returns:
+// - `*[]byte`: The buffer to format a logfile line into.
+func getLineBuffer() *[]byte {
+	return alLinePool.Get().(*[]byte)
+} // getLineBuffer()
+
+// `putLineBuffer()` returns `aBuffer` to the pool.
+//
+// Unusually large buffers are dropped to keep the pool's memory low.
+//
+// Parameters:
+// - `aBuffer`: The buffer to return.
+func putLineBuffer(aBuffer *[]byte) {
+	if alMaxPooledLine < cap(*aBuffer) {
+		return
+	}
+	*aBuffer = (*aBuffer)[:0]
+	alLinePool.Put(aBuffer)
+} // putLineBuffer()
+
+// `AddTransformer()` appends `aTransformer` to the list of functions
+// applied (in order of registration) to every log entry before it is
+// formatted.
+//
+// Transformers can be used e.g. to redact or enrich the entries'
+// fields without changing the formatter.
+//
+// Parameters:
+// - `aTransformer`: The function to apply to every log entry.
+func AddTransformer(aTransformer TTransformer) {
+	if nil == aTransformer {
+		return
+	}
+	alTransformersMtx.Lock()
+	alTransformers = append(alTransformers, aTransformer)
+	alTransformersMtx.Unlock()
+} // AddTransformer()
+
+// `ClearTransformers()` removes all registered entry transformers.
+func ClearTransformers() {
+	alTransformersMtx.Lock()
+	alTransformers = nil
+	alTransformersMtx.Unlock()
+} // ClearTransformers()
+
+// `SetStaticField()` sets a static field that is added to every log
+// entry, e.g. the service name, version, environment, or the schema
+// version of the entries, so that downstream consumers can evolve
+// their parsers safely across deployments.
+//
+// Static fields show up in structured output modes (e.g. with
+// `CanonicalLogLine` set). Fields of the same name set by a request's
+// handler (see `SetField()`) take precedence. An empty `aValue`
+// removes the static field `aKey`.
+//
+// Parameters:
+// - `aKey`: The name of the field.
+// - `aValue`: The field's value.
+func SetStaticField(aKey, aValue string) {
+	if "" == aKey {
+		return
+	}
+	alStaticFieldsMtx.Lock()
+	defer alStaticFieldsMtx.Unlock()
+
+	if "" == aValue {
+		delete(alStaticFields, aKey)
+		return
+	}
+	if nil == alStaticFields {
+		alStaticFields = make(map[string]string)
+	}
+	alStaticFields[aKey] = aValue
+} // SetStaticField()
+
+// `ClearStaticFields()` removes all static fields.
+func ClearStaticFields() {
+	alStaticFieldsMtx.Lock()
+	alStaticFields = nil
+	alStaticFieldsMtx.Unlock()
+} // ClearStaticFields()
+
+// `applyStaticFields()` adds all static fields not set already to
+// `aEntry`.
+//
+// Parameters:
+// - `aEntry`: The log entry to augment.
+func applyStaticFields(aEntry *TEntry) {
+	alStaticFieldsMtx.RLock()
+	defer alStaticFieldsMtx.RUnlock()
+
+	for key, value := range alStaticFields {
+		if _, ok := aEntry.Fields[key]; !ok {
+			aEntry.SetField(key, value)
+		}
+	}
+} // applyStaticFields()
+
+// `prepareEntry()` adds the static fields to `aEntry` and runs all
+// registered transformers and redaction rules on it before it gets
+// formatted; finally its strings are sanitised (see `SanitiseUTF8`).
+//
+// Parameters:
+// - `aEntry`: The log entry to prepare.
+func prepareEntry(aEntry *TEntry) {
+	applyStaticFields(aEntry)
+	applyTransformers(aEntry)
+	finishEntry(aEntry)
+} // prepareEntry()
+
+// `finishEntry()` runs all registered redaction rules on `aEntry`,
+// encrypts its fields (see `SetFieldEncryption()`), and sanitises its
+// strings.
+//
+// Parameters:
+// - `aEntry`: The log entry to finish.
+func finishEntry(aEntry *TEntry) {
+	applyRedactions(aEntry)
+	encryptFields(aEntry)
+	sanitiseEntry(aEntry)
+} // finishEntry()
+
+// `applyTransformers()` runs all registered transformers on `aEntry`.
+//
+// Parameters:
+// - `aEntry`: The log entry to modify.
+func applyTransformers(aEntry *TEntry) {
+	alTransformersMtx.RLock()
+	defer alTransformersMtx.RUnlock()
+
+	for _, transform := range alTransformers {
+		transform(aEntry)
+	}
+} // applyTransformers()
+
+/* _EoF_ */
@@ -0,0 +1,145 @@
+/*
+Copyright © 2024  M.Watermann, 10247 Berlin, Germany
+
+	    All rights reserved
+	EMail : <support@mwat.de>
+*/
+package apachelogger
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+//lint:file-ignore ST1017 – I prefer Yoda conditions
+
+func prepEntry() *TEntry {
+	return &TEntry{
+		Remote:   "192.168.1.0",
+		User:     "-",
+		When:     time.Date(2024, 4, 25, 20, 16, 45, 0, time.FixedZone("", 7200)),
+		Method:   "GET",
+		Path:     "/path/to/file?lang=en",
+		Proto:    "HTTP/1.1",
+		Status:   200,
+		Size:     27155,
+		Referrer: "-",
+		Agent:    "Mozilla/5.0",
+	}
+} // prepEntry()
+
+func TestTEntry_String(t *testing.T) {
+	e1 := prepEntry()
+	w1 := `192.168.1.0 - - [25/Apr/2024:20:16:45 +0200] "GET /path/to/file?lang=en HTTP/1.1" 200 27155 "-" "Mozilla/5.0"` + "\n"
+
+	tests := []struct {
+		name  string
+		entry *TEntry
+		want  string
+	}{
+		{" 1", e1, w1},
+		// TODO: Add test cases.
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.entry.String(); got != tt.want {
+				t.Errorf("%q: TEntry.String() = %q,\nwant %q",
+					tt.name, got, tt.want)
+			}
+		})
+	}
+} // TestTEntry_String()
+
+func Test_applyTransformers(t *testing.T) {
+	defer ClearTransformers()
+
+	AddTransformer(nil) // should be ignored
+	AddTransformer(func(aEntry *TEntry) {
+		aEntry.User = "user"
+	})
+	AddTransformer(func(aEntry *TEntry) {
+		aEntry.Path = strings.ToUpper(aEntry.Path)
+	})
+
+	e1 := prepEntry()
+	applyTransformers(e1)
+	if "user" != e1.User {
+		t.Errorf("applyTransformers() User = %q, want %q", e1.User, "user")
+	}
+	if w := "/PATH/TO/FILE?LANG=EN"; w != e1.Path {
+		t.Errorf("applyTransformers() Path = %q, want %q", e1.Path, w)
+	}
+
+	ClearTransformers()
+	e2 := prepEntry()
+	applyTransformers(e2)
+	if "-" != e2.User {
+		t.Errorf("applyTransformers() User = %q, want %q", e2.User, "-")
+	}
+} // Test_applyTransformers()
+
+func Test_applyStaticFields(t *testing.T) {
+	defer ClearStaticFields()
+
+	SetStaticField("service", "shop")
+	SetStaticField("schema_version", "2")
+	SetStaticField("env", "test")
+	SetStaticField("env", "") // remove field again
+	SetStaticField("", "ignored")
+
+	e1 := prepEntry()
+	e1.SetField("service", "admin")
+	applyStaticFields(e1)
+
+	if 2 != len(e1.Fields) {
+		t.Fatalf("applyStaticFields() Fields = %v, want 2 fields", e1.Fields)
+	}
+	if w := "admin"; w != e1.Fields["service"] {
+		t.Errorf("service = %q, want %q", e1.Fields["service"], w)
+	}
+	if w := "2"; w != e1.Fields["schema_version"] {
+		t.Errorf("schema_version = %q, want %q", e1.Fields["schema_version"], w)
+	}
+} // Test_applyStaticFields()
+
+func TestTEntry_AppendText(t *testing.T) {
+	e1 := prepEntry()
+	buf := make([]byte, 0, 512)
+	if got := string(e1.AppendText(buf)); got != e1.String() {
+		t.Errorf("AppendText() = %q, want %q", got, e1.String())
+	}
+	if got := string(e1.AppendText([]byte("> "))); got != "> "+e1.String() {
+		t.Errorf("AppendText() = %q, want %q", got, "> "+e1.String())
+	}
+
+	allocs := testing.AllocsPerRun(100, func() {
+		buf = e1.AppendText(buf[:0])
+	})
+	if 0 != allocs {
+		t.Errorf("AppendText() allocs = %v, want 0", allocs)
+	}
+} // TestTEntry_AppendText()
+
+func BenchmarkTEntry_AppendText(b *testing.B) {
+	e1 := prepEntry()
+	buf := make([]byte, 0, 512)
+	b.ReportAllocs()
+	b.ResetTimer()
+
+	for n := 0; n < b.N; n++ {
+		buf = e1.AppendText(buf[:0])
+	}
+} // BenchmarkTEntry_AppendText()
+
+func BenchmarkTEntry_String(b *testing.B) {
+	e1 := prepEntry()
+	b.ReportAllocs()
+	b.ResetTimer()
+
+	for n := 0; n < b.N; n++ {
+		_ = e1.String()
+	}
+} // BenchmarkTEntry_String()
+
+/* _EoF_ */
@@ -0,0 +1,219 @@
+/*
+Copyright © 2024  M.Watermann, 10247 Berlin, Germany
+
+	    All rights reserved
+	EMail : <support@mwat.de>
+*/
+package apachelogger
+
+import (
+	"fmt"
+	"sync"
+	"time"
+)
+
+//lint:file-ignore ST1017 – I prefer Yoda conditions
+
+const (
+	// `LevelInfo` is the severity of the messages written by `Log()`.
+	LevelInfo = iota
+
+	// `LevelWarn` is the severity of the messages written by `Warn()`.
+	LevelWarn
+
+	// `LevelError` is the severity of the messages written by `Err()`
+	// and of the panics caught by `Wrap()`.
+	LevelError
+)
+
+type (
+	// `tErrorRoute` sends the error log entries of a min. severity to
+	// an additional queue.
+	tErrorRoute struct {
+		level int    // the min. severity to send
+		queue *tRing // the queue of the route's sink
+	}
+)
+
+var (
+	// `ErrorLogLevel` is the min. severity of the entries written to
+	// the error log itself (default: `LevelWarn`, i.e. all of them);
+	// the routes added by `AddErrorRoute()` get their entries anyway.
+	ErrorLogLevel = LevelWarn
+
+	// The additional routes of the error queues.
+	alErrorRoutes struct {
+		sync.RWMutex
+		routes map[*tRing][]tErrorRoute
+	}
+)
+
+// `entryLevel()` returns the severity of a custom log entry.
+//
+// Parameters:
+// - `aMethod`: The entry's method (`LOG`, `WARN`, or `ERR`).
+//
+// Returns:
+// - `int`: The entry's severity (`-1` for other entries).
+func entryLevel(aMethod string) int {
+	switch aMethod {
+	case `LOG`:
+		return LevelInfo
+	case `WARN`:
+		return LevelWarn
+	case `ERR`:
+		return LevelError
+	}
+
+	return -1
+} // entryLevel()
+
+// `level()` returns the severity of the entry.
+//
+// Only the entries written by `Log()`, `Err()` etc. have a severity;
+// a request's method (like `ERR`) is chosen by the client and doesn't
+// count.
+//
+// Returns:
+// - `int`: The entry's severity (`-1` for the entries of requests).
+func (e *TEntry) level() int {
+	if nil == e.origin {
+		return -1
+	}
+
+	return entryLevel(e.Method)
+} // level()
+
+// `addErrorRoute()` sends the entries of `aQueue` with at least
+// `aLevel` to `aSink` as well.
+//
+// Parameters:
+// - `aQueue`: The error queue whose entries to route.
+// - `aLevel`: The min. severity to send.
+// - `aSink`: The additional sink.
+func addErrorRoute(aQueue *tRing, aLevel int, aSink TSink) {
+	alFileQueuesMtx.Lock()
+	queue := sinkQueue(aSink, 1)
+	alFileQueuesMtx.Unlock()
+	if queue == aQueue {
+		return // the error log's own sink
+	}
+
+	alErrorRoutes.Lock()
+	if nil == alErrorRoutes.routes {
+		alErrorRoutes.routes = make(map[*tRing][]tErrorRoute)
+	}
+	alErrorRoutes.routes[aQueue] = append(alErrorRoutes.routes[aQueue],
+		tErrorRoute{level: aLevel, queue: queue})
+	alErrorRoutes.Unlock()
+} // addErrorRoute()
+
+// `checkLevel()` checks whether `aLevel` is a known severity.
+//
+// Parameters:
+// - `aLevel`: The severity to check.
+//
+// Returns:
+// - `error`: A possible error for an unknown severity.
+func checkLevel(aLevel int) error {
+	if (LevelInfo > aLevel) || (LevelError < aLevel) {
+		return fmt.Errorf("apachelogger: unknown severity %d", aLevel)
+	}
+
+	return nil
+} // checkLevel()
+
+// `routeEntry()` sends `aEntry` to the routes of `aQueue` accepting
+// its severity.
+//
+// Parameters:
+// - `aEntry`: The log entry to route.
+// - `aQueue`: The queue the entry is logged to.
+//
+// Returns:
+// - `bool`: `false` if the entry is below `ErrorLogLevel`, i.e. it
+// should not be written to `aQueue`.
+func routeEntry(aEntry *TEntry, aQueue *tRing) bool {
+	level := aEntry.level()
+	if LevelWarn > level {
+		return true // only error log entries are routed
+	}
+
+	alErrorRoutes.RLock()
+	routes := alErrorRoutes.routes[aQueue]
+	alErrorRoutes.RUnlock()
+	for _, route := range routes {
+		if level >= route.level {
+			stampSequence(aEntry, route.queue)
+			route.queue.push(formatEntry(aEntry))
+		}
+	}
+
+	return level >= ErrorLogLevel
+} // routeEntry()
+
+/* * * * * * * * * * * * * * * * * * * * * * * * * * * * * * * * * * * * * */
+
+// `AddErrorRoute()` additionally sends the error log entries with at
+// least the severity `aLevel` to `aSink`, e.g.
+//
+//	_ = apachelogger.AddErrorRoute(apachelogger.LevelError, syslogSink)
+//
+// writes the messages of `Err()` and the caught panics to the syslog
+// while those of `Warn()` go to the error logfile only. Together with
+// `ErrorLogLevel` the error log's entries can be split up by severity.
+// The routes apply to the global error log; see `WithErrorRoute()` for
+// a single wrapper.
+//
+// Parameters:
+// - `aLevel`: The min. severity (`LevelWarn` or `LevelError`).
+// - `aSink`: The sink to send the entries to.
+//
+// Returns:
+// - `error`: A possible error for an unknown severity.
+func AddErrorRoute(aLevel int, aSink TSink) error {
+	if err := checkLevel(aLevel); nil != err {
+		return err
+	}
+	addErrorRoute(errorQueue(), aLevel, aSink)
+
+	return nil
+} // AddErrorRoute()
+
+// `ClearErrorRoutes()` removes all routes added by `AddErrorRoute()`
+// and `WithErrorRoute()`.
+func ClearErrorRoutes() {
+	alErrorRoutes.Lock()
+	alErrorRoutes.routes = nil
+	alErrorRoutes.Unlock()
+} // ClearErrorRoutes()
+
+// `Warn()` writes `aMessage` on behalf of `aSender` with the severity
+// `LevelWarn` to the error logfile.
+//
+// Parameters:
+// - `aSender`: The name/designation of the sending entity.
+// - `aMessage`: The text to write to the error logfile.
+func Warn(aSender, aMessage string) {
+	spawnError(aSender, aMessage, `WARN`, callerName(2))
+} // Warn()
+
+// `spawnError()` sends an error log message in background on behalf
+// of `Err()` and `Warn()`.
+//
+// Parameters:
+// - `aSender`: The name/designation of the sending entity.
+// - `aMessage`: The text to write to the error logfile.
+// - `aMethod`: Either `WARN` or `ERR`.
+// - `aCaller`: The source position of the caller (see `callerName()`).
+func spawnError(aSender, aMessage, aMethod, aCaller string) {
+	spawnCustom(func(aNow time.Time) {
+		entry := customEntry(aSender, aMessage, aMethod, aNow)
+		if "" != aCaller {
+			entry.origin = &tErrorOrigin{caller: aCaller}
+		}
+		queueCustomEntry(entry, errorQueue())
+	})
+} // spawnError()
+
+/* _EoF_ */
@@ -0,0 +1,54 @@
+/*
+Copyright © 2024  M.Watermann, 10247 Berlin, Germany
+
+	    All rights reserved
+	EMail : <support@mwat.de>
+*/
+package apachelogger
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+)
+
+//lint:file-ignore ST1017 – I prefer Yoda conditions
+
+func TestAddErrorRoute(t *testing.T) {
+	if err := AddErrorRoute(42, Discard); nil == err {
+		t.Error("AddErrorRoute() expected an error for an unknown severity")
+	}
+	if err := WithErrorRoute(-1, Discard)(&tOptions{}); nil == err {
+		t.Error("WithErrorRoute() expected an error for an unknown severity")
+	}
+
+	sink, queue := &tMemSink{}, newRing(8)
+	addErrorRoute(queue, LevelError, sink)
+	defer func() {
+		ClearErrorRoutes()
+		ErrorLogLevel = LevelWarn
+	}()
+	ErrorLogLevel = LevelError
+	now := time.Now()
+	for method, msg := range map[string]string{`WARN`: "warning", `ERR`: "failure", `LOG`: "info"} {
+		queueCustomEntry(customEntry("test", msg, method, now), queue)
+	}
+
+	lines := strings.Join(queue.popBatch(make([]string, 0, 8)), "")
+	if !strings.Contains(lines, "failure") || !strings.Contains(lines, "info") ||
+		strings.Contains(lines, "warning") {
+		t.Errorf("error log = %q, want the entries from LevelError and of Log()", lines)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := Drain(ctx); nil != err {
+		t.Fatalf("Drain() error = %v", err)
+	}
+	if got := string(sink.data); !strings.Contains(got, "failure") ||
+		strings.Contains(got, "warning") || strings.Contains(got, "info") {
+		t.Errorf("route = %q, want the entry from LevelError only", got)
+	}
+} // TestAddErrorRoute()
+
+/* _EoF_ */
@@ -0,0 +1,224 @@
+/*
+Copyright © 2024  M.Watermann, 10247 Berlin, Germany
+
+	    All rights reserved
+	EMail : <support@mwat.de>
+*/
+package apachelogger
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"fmt"
+	"net"
+	"strings"
+	"sync"
+)
+
+//lint:file-ignore ST1017 – I prefer Yoda conditions
+
+const (
+	// Prefix marking an encrypted field value.
+	alEncryptedPrefix = "enc:"
+
+	// Label binding the RSA ciphertexts to this use.
+	alEncryptionLabel = "apachelogger field"
+)
+
+var (
+	// The public key to encrypt the fields with (`nil` = disabled).
+	alFieldKey *rsa.PublicKey
+
+	// Names of the fields to encrypt.
+	alFieldNames []string
+
+	// Guard for concurrent access to `alFieldKey` and `alFieldNames`.
+	alFieldKeyMtx sync.RWMutex
+)
+
+// `SetFieldEncryption()` arranges for the log entries' fields named
+// `aFields` to be encrypted with the public key `aKey` while the rest
+// of the line stays readable (and grep-able).
+//
+// Valid field names are `remote`, `user`, `method`, `path`, `proto`,
+// `referrer`, and `agent` as well as the names of additional fields
+// (e.g. `remote_host` or `peer_uid`). For the `remote` field the full
+// (not anonymised) client address is encrypted, so it can be recovered
+// when legally required, while e.g. `AnonymiseURLs` protects all the
+// entries whose remote address isn't encrypted.
+//
+// Each value is encrypted with a fresh AES-256 key which is itself
+// encrypted by RSA-OAEP (SHA-256); the result is written as `enc:`
+// followed by its URL-safe base64 encoding. Empty values and `-` are
+// left alone. Since the same value encrypts differently each time,
+// encrypted fields can't be grouped (e.g. by `SetTrafficStats()` or
+// `SetDuplicateWindow()`) anymore. Authorised staff can decrypt single
+// values by `DecryptField()` using the matching private key.
+//
+// The fields are encrypted after all redactions (see `AddRedaction()`)
+// were applied. Calling the function with a `nil` key or without field
+// names disables the encryption.
+//
+// Parameters:
+// - `aKey`: The public key to encrypt with.
+// - `aFields`: The names of the fields to encrypt.
+//
+// Returns:
+// - `error`: A possible error with a field name or the key's size.
+func SetFieldEncryption(aKey *rsa.PublicKey, aFields ...string) error {
+	if (nil == aKey) || (0 == len(aFields)) {
+		aKey, aFields = nil, nil
+	} else if (2*sha256.Size + 2 + 32) > aKey.Size() {
+		return fmt.Errorf("apachelogger: RSA key too small (%d bits)", aKey.N.BitLen())
+	}
+	names := make([]string, 0, len(aFields))
+	for _, name := range aFields {
+		if name = strings.TrimSpace(name); "" == name {
+			return fmt.Errorf("apachelogger: empty field name")
+		}
+		if nil != (&TEntry{}).stringField(name) {
+			name = strings.ToLower(name)
+		}
+		names = append(names, name)
+	}
+
+	alFieldKeyMtx.Lock()
+	alFieldKey, alFieldNames = aKey, names
+	alFieldKeyMtx.Unlock()
+
+	return nil
+} // SetFieldEncryption()
+
+// `encryptFields()` encrypts the fields selected by
+// `SetFieldEncryption()` in `aEntry`.
+//
+// Parameters:
+// - `aEntry`: The log entry to encrypt.
+func encryptFields(aEntry *TEntry) {
+	alFieldKeyMtx.RLock()
+	defer alFieldKeyMtx.RUnlock()
+	if nil == alFieldKey {
+		return
+	}
+
+	for _, name := range alFieldNames {
+		if text := aEntry.stringField(name); nil != text {
+			if ("remote" == name) && ("" != aEntry.address) {
+				*text = plainAddress(aEntry.address)
+			}
+			*text = encryptValue(alFieldKey, *text)
+			continue
+		}
+		if value, ok := aEntry.Fields[name]; ok {
+			aEntry.Fields[name] = encryptValue(alFieldKey, value)
+		}
+	}
+} // encryptFields()
+
+// `plainAddress()` returns `aAddr` without a port.
+//
+// Parameters:
+// - `aAddr`: The remote address, possibly with port.
+//
+// Returns:
+// - `string`: The bare address.
+func plainAddress(aAddr string) string {
+	if host, _, err := net.SplitHostPort(aAddr); nil == err {
+		return host
+	}
+
+	return strings.Trim(aAddr, "[]")
+} // plainAddress()
+
+// `encryptValue()` returns `aValue` encrypted with `aKey`.
+//
+// Parameters:
+// - `aKey`: The public key to encrypt with.
+// - `aValue`: The text to encrypt.
+//
+// Returns:
+// - `string`: The encrypted text (or `-` if the encryption failed).
+func encryptValue(aKey *rsa.PublicKey, aValue string) string {
+	if ("" == aValue) || ("-" == aValue) {
+		return aValue
+	}
+
+	key := make([]byte, 32)
+	if _, err := rand.Read(key); nil != err {
+		return "-"
+	}
+	sealed, err := rsa.EncryptOAEP(sha256.New(), rand.Reader, aKey, key, []byte(alEncryptionLabel))
+	if nil != err {
+		return "-"
+	}
+	aead, err := fieldCipher(key)
+	if nil != err {
+		return "-"
+	}
+	// The key is used only once, so a constant nonce is safe:
+	nonce := make([]byte, aead.NonceSize())
+	sealed = aead.Seal(sealed, nonce, []byte(aValue), nil)
+
+	return alEncryptedPrefix + base64.RawURLEncoding.EncodeToString(sealed)
+} // encryptValue()
+
+// `fieldCipher()` returns the AES-GCM cipher using `aKey`.
+//
+// Parameters:
+// - `aKey`: The AES key to use.
+//
+// Returns:
+// - `cipher.AEAD`: The authenticated cipher.
+// - `error`: A possible error creating the cipher.
+func fieldCipher(aKey []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(aKey)
+	if nil != err {
+		return nil, err
+	}
+
+	return cipher.NewGCM(block)
+} // fieldCipher()
+
+// `DecryptField()` returns the plain text of a field value encrypted
+// by the key set with `SetFieldEncryption()`.
+//
+// Parameters:
+// - `aKey`: The private key matching the encryption key.
+// - `aValue`: The encrypted value as written to the logfile.
+//
+// Returns:
+// - `string`: The decrypted value.
+// - `error`: A possible error decoding or decrypting `aValue`.
+func DecryptField(aKey *rsa.PrivateKey, aValue string) (string, error) {
+	if !strings.HasPrefix(aValue, alEncryptedPrefix) {
+		return "", fmt.Errorf("apachelogger: value not encrypted")
+	}
+	data, err := base64.RawURLEncoding.DecodeString(aValue[len(alEncryptedPrefix):])
+	if nil != err {
+		return "", fmt.Errorf("apachelogger: invalid encrypted value: %w", err)
+	}
+	size := aKey.Size()
+	if size > len(data) {
+		return "", fmt.Errorf("apachelogger: encrypted value too short")
+	}
+	key, err := rsa.DecryptOAEP(sha256.New(), nil, aKey, data[:size], []byte(alEncryptionLabel))
+	if nil != err {
+		return "", fmt.Errorf("apachelogger: decrypting the value's key: %w", err)
+	}
+	aead, err := fieldCipher(key)
+	if nil != err {
+		return "", err
+	}
+	plain, err := aead.Open(nil, make([]byte, aead.NonceSize()), data[size:], nil)
+	if nil != err {
+		return "", fmt.Errorf("apachelogger: decrypting the value: %w", err)
+	}
+
+	return string(plain), nil
+} // DecryptField()
+
+/* _EoF_ */
@@ -0,0 +1,71 @@
+/*
+Copyright © 2024  M.Watermann, 10247 Berlin, Germany
+
+	    All rights reserved
+	EMail : <support@mwat.de>
+*/
+package apachelogger
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"strings"
+	"testing"
+)
+
+//lint:file-ignore ST1017 – I prefer Yoda conditions
+
+func TestSetFieldEncryption(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if nil != err {
+		t.Fatalf("GenerateKey() error = %v", err)
+	}
+	defer func() { _ = SetFieldEncryption(nil) }()
+
+	if err := SetFieldEncryption(&key.PublicKey, "user", " "); nil == err {
+		t.Error("SetFieldEncryption() accepted an empty field name")
+	}
+	if err := SetFieldEncryption(&key.PublicKey, "Remote", "user", "peer_uid"); nil != err {
+		t.Fatalf("SetFieldEncryption() error = %v", err)
+	}
+
+	e := prepEntry()
+	e.address = "192.168.1.2:54321"
+	e.Remote = "192.168.1.0"
+	e.User = "-"
+	e.SetField("peer_uid", "1000")
+	prepareEntry(e)
+
+	if "-" != e.User {
+		t.Errorf("User = %q, want %q", e.User, "-")
+	}
+	if "GET" != e.Method {
+		t.Errorf("Method = %q, want it unencrypted", e.Method)
+	}
+	for value, want := range map[string]string{
+		e.Remote:             "192.168.1.2",
+		e.Fields["peer_uid"]: "1000",
+	} {
+		if !strings.HasPrefix(value, alEncryptedPrefix) {
+			t.Errorf("value %q not encrypted", value)
+			continue
+		}
+		got, err := DecryptField(key, value)
+		if nil != err {
+			t.Errorf("DecryptField() error = %v", err)
+		} else if want != got {
+			t.Errorf("DecryptField() = %q, want %q", got, want)
+		}
+	}
+
+	if other, _ := rsa.GenerateKey(rand.Reader, 1024); nil != other {
+		if _, err := DecryptField(other, e.Remote); nil == err {
+			t.Error("DecryptField() succeeded with the wrong key")
+		}
+	}
+	if _, err := DecryptField(key, "192.168.1.0"); nil == err {
+		t.Error("DecryptField() accepted a plain value")
+	}
+} // TestSetFieldEncryption()
+
+/* _EoF_ */
@@ -0,0 +1,533 @@
+/*
+Copyright © 2024  M.Watermann, 10247 Berlin, Germany
+
+	    All rights reserved
+	EMail : <support@mwat.de>
+*/
+package apachelogger
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+//lint:file-ignore ST1017 – I prefer Yoda conditions
+
+const (
+	// `FormatCommon` is Apache's Common Log Format.
+	FormatCommon = `%h %l %u %t "%r" %>s %b`
+
+	// `FormatCombined` is Apache's Combined Log Format (the default).
+	FormatCombined = FormatCommon + ` "%{Referer}i" "%{User-agent}i"`
+)
+
+type (
+	// `tFormatPart` is a single literal text or directive of a log format.
+	tFormatPart struct {
+		directive byte   // the directive's letter (`0` for literal text)
+		param     string // the directive's `{…}` parameter or the literal text
+	}
+
+	// `tLogFormat` is a parsed log format.
+	tLogFormat struct {
+		headers        []string      // names of the request headers used
+		parts          []tFormatPart // the format's literals and directives
+		strict         bool          // percent-encode quotes etc. (see `SetProfile()`)
+		serverHostPort bool          // the server's host or port is used
+		clientPort     bool          // the client's port is used
+	}
+)
+
+var (
+	// The current log format (`nil` for the built-in combined format).
+	alLogFormat *tLogFormat
+
+	// Guard for concurrent access to `alLogFormat`.
+	alLogFormatMtx sync.RWMutex
+)
+
+// `parseFormat()` parses an Apache-like log format.
+//
+// Parameters:
+// - `aFormat`: The format to parse.
+//
+// Returns:
+// - `*tLogFormat`: The parsed format.
+// - `error`: A possible error for an invalid or unsupported directive.
+func parseFormat(aFormat string) (*tLogFormat, error) {
+	result := &tLogFormat{}
+	var literal strings.Builder
+
+	for idx := 0; idx < len(aFormat); idx++ {
+		if '%' != aFormat[idx] {
+			literal.WriteByte(aFormat[idx])
+			continue
+		}
+		start := idx
+		if idx++; idx >= len(aFormat) {
+			return nil, fmt.Errorf("apachelogger: format ends with incomplete directive at offset %d", start)
+		}
+		if '%' == aFormat[idx] {
+			literal.WriteByte('%')
+			continue
+		}
+
+		// Apache's `<` and `>` modifiers make no difference here:
+		for (idx < len(aFormat)) && (('<' == aFormat[idx]) || ('>' == aFormat[idx])) {
+			idx++
+		}
+		param := ""
+		if (idx < len(aFormat)) && ('{' == aFormat[idx]) {
+			end := strings.IndexByte(aFormat[idx:], '}')
+			if 0 > end {
+				return nil, fmt.Errorf("apachelogger: unterminated '{' in directive at offset %d", start)
+			}
+			param = aFormat[idx+1 : idx+end]
+			idx += end + 1
+		}
+		if idx >= len(aFormat) {
+			return nil, fmt.Errorf("apachelogger: format ends with incomplete directive at offset %d", start)
+		}
+
+		directive := aFormat[idx]
+		if err := checkDirective(directive, param); nil != err {
+			return nil, fmt.Errorf("apachelogger: directive %q at offset %d: %v",
+				aFormat[start:idx+1], start, err)
+		}
+		if 0 < literal.Len() {
+			result.parts = append(result.parts, tFormatPart{0, literal.String()})
+			literal.Reset()
+		}
+		result.parts = append(result.parts, tFormatPart{directive, param})
+		switch directive {
+		case 'i':
+			result.headers = append(result.headers, http.CanonicalHeaderKey(param))
+		case 'p':
+			if "remote" == param {
+				result.clientPort = true
+			} else {
+				result.serverHostPort = true
+			}
+		case 'v':
+			result.serverHostPort = true
+		}
+	}
+	if 0 < literal.Len() {
+		result.parts = append(result.parts, tFormatPart{0, literal.String()})
+	}
+
+	return result, nil
+} // parseFormat()
+
+// `checkDirective()` checks whether a directive is supported.
+//
+// Parameters:
+// - `aDirective`: The directive's letter.
+// - `aParam`: The directive's `{…}` parameter (if any).
+//
+// Returns:
+// - `error`: A possible error for an unsupported directive.
+func checkDirective(aDirective byte, aParam string) error {
+	switch aDirective {
+	case 'a', 'b', 'B', 'D', 'h', 'H', 'k', 'l', 'm', 'q', 'r', 's', 'u', 'U', 'v':
+		if "" != aParam {
+			return fmt.Errorf("unsupported parameter %q", aParam)
+		}
+
+	case 't':
+		format := aParam
+		if strings.HasPrefix(format, "begin:") {
+			format = format[6:]
+		} else if strings.HasPrefix(format, "end:") {
+			format = format[4:]
+		}
+		switch format {
+		case "sec", "msec", "usec", "msec_frac", "usec_frac":
+		default:
+			return checkStrftime(format)
+		}
+
+	case 'p':
+		switch aParam {
+		case "", "canonical", "local", "remote":
+		default:
+			return fmt.Errorf("unsupported port format %q", aParam)
+		}
+
+	case 'T':
+		switch aParam {
+		case "", "s", "ms", "us":
+		default:
+			return fmt.Errorf("unsupported time unit %q", aParam)
+		}
+
+	case 'e', 'i', 'n':
+		if "" == aParam {
+			return fmt.Errorf("missing name parameter")
+		}
+
+	default:
+		return fmt.Errorf("unsupported directive")
+	}
+
+	return nil
+} // checkDirective()
+
+// `appendEscaped()` appends `aText` to `aBuffer`, escaping quotes,
+// backslashes, and control characters like Apache does.
+//
+// Parameters:
+// - `aBuffer`: The buffer to append to.
+// - `aText`: The text to append.
+//
+// Returns:
+// - `[]byte`: The extended buffer.
+func appendEscaped(aBuffer []byte, aText string) []byte {
+	const hex = "0123456789abcdef"
+
+	for idx := 0; idx < len(aText); idx++ {
+		switch c := aText[idx]; {
+		case ('"' == c) || ('\\' == c):
+			aBuffer = append(aBuffer, '\\', c)
+		case (' ' > c) || (0x7f == c):
+			aBuffer = append(aBuffer, '\\', 'x', hex[c>>4], hex[c&0x0f])
+		default:
+			aBuffer = append(aBuffer, c)
+		}
+	}
+
+	return aBuffer
+} // appendEscaped()
+
+// `appendTo()` appends `aEntry` formatted according to the log format
+// to `aBuffer`.
+//
+// Parameters:
+// - `aBuffer`: The buffer to append to.
+// - `aEntry`: The log entry to format.
+//
+// Returns:
+// - `[]byte`: The extended buffer.
+func (lf *tLogFormat) appendTo(aBuffer []byte, aEntry *TEntry) []byte {
+	quoted := false // the directive is enclosed by quotes
+	for _, part := range lf.parts {
+		switch part.directive {
+		case 0:
+			aBuffer = append(aBuffer, part.param...)
+			quoted = strings.HasSuffix(part.param, `"`)
+			continue
+		case 'a', 'h':
+			aBuffer = lf.value(aBuffer, aEntry.Remote, quoted)
+		case 'b':
+			if 0 == aEntry.Size {
+				aBuffer = append(aBuffer, '-')
+			} else {
+				aBuffer = strconv.AppendInt(aBuffer, int64(aEntry.Size), 10)
+			}
+		case 'B':
+			aBuffer = strconv.AppendInt(aBuffer, int64(aEntry.Size), 10)
+		case 'D':
+			aBuffer = strconv.AppendInt(aBuffer, aEntry.Duration.Microseconds(), 10)
+		case 'e':
+			aBuffer = lf.value(aBuffer, aEntry.Fields[part.param], quoted)
+		case 'H':
+			aBuffer = lf.value(aBuffer, aEntry.Proto, quoted)
+		case 'i':
+			aBuffer = lf.value(aBuffer, aEntry.header(part.param), quoted)
+		case 'l':
+			aBuffer = append(aBuffer, '-')
+		case 'm':
+			aBuffer = lf.value(aBuffer, aEntry.Method, quoted)
+		case 'k':
+			aBuffer = lf.value(aBuffer, aEntry.Fields["keepalive"], quoted)
+		case 'n':
+			aBuffer = lf.value(aBuffer, aEntry.notes[part.param], quoted)
+		case 'p':
+			if "remote" == part.param {
+				aBuffer = lf.value(aBuffer, aEntry.Fields["client_port"], quoted)
+			} else {
+				aBuffer = lf.value(aBuffer, aEntry.Fields["server_port"], quoted)
+			}
+		case 'q':
+			if idx := strings.IndexByte(aEntry.Path, '?'); 0 <= idx {
+				aBuffer = lf.escape(aBuffer, aEntry.Path[idx:], quoted)
+			}
+		case 'r':
+			if lf.strict { // the parsers expect three non-empty words
+				aBuffer = lf.value(aBuffer, aEntry.Method, false)
+				aBuffer = append(aBuffer, ' ')
+				aBuffer = lf.value(aBuffer, aEntry.Path, false)
+				aBuffer = append(aBuffer, ' ')
+				aBuffer = lf.value(aBuffer, aEntry.Proto, false)
+				break
+			}
+			aBuffer = appendEscaped(aBuffer, aEntry.Method)
+			aBuffer = append(aBuffer, ' ')
+			aBuffer = appendEscaped(aBuffer, aEntry.Path)
+			aBuffer = append(aBuffer, ' ')
+			aBuffer = appendEscaped(aBuffer, aEntry.Proto)
+		case 's':
+			aBuffer = strconv.AppendInt(aBuffer, int64(aEntry.Status), 10)
+		case 't':
+			aBuffer = appendTimeFormat(aBuffer, aEntry, part.param)
+		case 'T':
+			switch part.param {
+			case "ms":
+				aBuffer = strconv.AppendInt(aBuffer, aEntry.Duration.Milliseconds(), 10)
+			case "us":
+				aBuffer = strconv.AppendInt(aBuffer, aEntry.Duration.Microseconds(), 10)
+			default:
+				aBuffer = strconv.AppendInt(aBuffer, int64(aEntry.Duration/time.Second), 10)
+			}
+		case 'u':
+			aBuffer = lf.value(aBuffer, aEntry.User, quoted)
+		case 'U':
+			path := aEntry.Path
+			if idx := strings.IndexByte(path, '?'); 0 <= idx {
+				path = path[:idx]
+			}
+			aBuffer = lf.value(aBuffer, path, quoted)
+		case 'v':
+			aBuffer = lf.value(aBuffer, aEntry.Fields["server_host"], quoted)
+		}
+		quoted = false
+	}
+
+	return append(aBuffer, '\n')
+} // appendTo()
+
+// `escape()` appends `aText` to `aBuffer`, escaped according to the
+// format's strictness.
+//
+// Parameters:
+// - `aBuffer`: The buffer to append to.
+// - `aText`: The text to append.
+// - `aQuoted`: Whether the text is enclosed by quotes.
+//
+// Returns:
+// - `[]byte`: The extended buffer.
+func (lf *tLogFormat) escape(aBuffer []byte, aText string, aQuoted bool) []byte {
+	if lf.strict {
+		return appendStrict(aBuffer, aText, !aQuoted)
+	}
+
+	return appendEscaped(aBuffer, aText)
+} // escape()
+
+// `value()` appends `aText` (or `-` if empty) to `aBuffer`, escaped
+// according to the format's strictness.
+//
+// Parameters:
+// - `aBuffer`: The buffer to append to.
+// - `aText`: The text to append.
+// - `aQuoted`: Whether the text is enclosed by quotes.
+//
+// Returns:
+// - `[]byte`: The extended buffer.
+func (lf *tLogFormat) value(aBuffer []byte, aText string, aQuoted bool) []byte {
+	if "" == aText {
+		return append(aBuffer, '-')
+	}
+
+	return lf.escape(aBuffer, aText, aQuoted)
+} // value()
+
+// `render()` returns `aEntry` formatted according to the log format.
+//
+// Parameters:
+// - `aEntry`: The log entry to format.
+//
+// Returns:
+// - `string`: The formatted logfile line.
+func (lf *tLogFormat) render(aEntry *TEntry) string {
+	buf := getLineBuffer()
+	*buf = lf.appendTo((*buf)[:0], aEntry)
+	result := string(*buf)
+	putLineBuffer(buf)
+
+	return result
+} // render()
+
+/* * * * * * * * * * * * * * * * * * * * * * * * * * * * * * * * * * * * * */
+
+// `captureHeaders()` returns the request headers needed by the log's
+// own format or else the current log format and W3C fields.
+//
+// Parameters:
+// - `aHeader`: The request's headers.
+// - `aSettings`: The settings of the log.
+//
+// Returns:
+// - `map[string]string`: The needed headers (`nil` if none).
+func captureHeaders(aHeader http.Header, aSettings *tLogSettings) map[string]string {
+	var names []string
+	if lf := aSettings.logFormat(); nil != lf {
+		names = lf.headers
+	} else {
+		if lf = logFormat(); nil != lf {
+			names = lf.headers
+		}
+		if wf := w3cFormat(); nil != wf {
+			names = append(names[:len(names):len(names)], wf.headers...)
+		}
+	}
+	if 0 == len(names) {
+		return nil
+	}
+	result := make(map[string]string, len(names))
+	for _, name := range names {
+		if value := aHeader.Get(name); "" != value {
+			result[name] = value
+		}
+	}
+
+	return result
+} // captureHeaders()
+
+// `header()` returns the value of the request header `aName`.
+//
+// The `Referer` and `User-Agent` headers are taken from the entry's
+// respective fields (i.e. after transformers and redactions).
+//
+// Parameters:
+// - `aName`: The header's name.
+//
+// Returns:
+// - `string`: The header's value (empty if not available).
+func (e *TEntry) header(aName string) string {
+	switch aName = http.CanonicalHeaderKey(aName); aName {
+	case "Referer", "Referrer":
+		if "-" == e.Referrer {
+			return ""
+		}
+		return e.Referrer
+	case "User-Agent":
+		if "-" == e.Agent {
+			return ""
+		}
+		return e.Agent
+	}
+
+	return e.headers[aName]
+} // header()
+
+// `logFormat()` returns the current log format.
+//
+// Returns:
+// - `*tLogFormat`: The current format (`nil` for the built-in one).
+func logFormat() *tLogFormat {
+	alLogFormatMtx.RLock()
+	defer alLogFormatMtx.RUnlock()
+
+	return alLogFormat
+} // logFormat()
+
+// `Preview()` returns the access log line the current configuration
+// would write for `aRequest` (answered with status 200 and 1234 bytes
+// within 1.5 milliseconds), so a misconfigured format can be spotted
+// at startup.
+//
+// The preview has no side effects: no hostnames are looked up (see
+// `HostnameLookups`) and the transformers (see `AddTransformer()`)
+// aren't run.
+//
+// Parameters:
+// - `aRequest`: The sample request (`nil` for a built-in sample).
+//
+// Returns:
+// - `string`: The formatted logfile line.
+func Preview(aRequest *http.Request) string {
+	if nil == aRequest {
+		aRequest, _ = http.NewRequest(http.MethodGet,
+			"http://www.example.com/index.html?lang=en", nil)
+		aRequest.RemoteAddr = "192.0.2.34:54321"
+		aRequest.Header.Set("Referer", "https://www.example.org/")
+		aRequest.Header.Set("User-Agent", "Mozilla/5.0 (X11; Linux x86_64)")
+	}
+	lw := &tLogWriter{size: 1234, status: http.StatusOK, when: time.Now(), duration: 1500 * time.Microsecond}
+	entry, _ := requestEntry(lw, aRequest, true)
+
+	return formatEntry(entry)
+} // Preview()
+
+// `SetLogFormat()` sets the format of the access and error log lines
+// using Apache's `LogFormat` directives.
+//
+// The supported directives are:
+//
+//	%%           a literal percent sign
+//	%a, %h       the remote address (anonymised; see `AnonymiseURLs`)
+//	%b, %B       the size of the response body (`%b`: `-` for zero)
+//	%D           the time taken to serve the request, in microseconds
+//	%{name}e     the entry's field `name` (see `SetField()`)
+//	%H           the request protocol
+//	%{name}i     the request header `name`
+//	%k           the number of keep-alive requests served on the
+//	             connection before (see `SetKeepAliveLog()`)
+//	%l           the remote logname (always `-`)
+//	%m           the request method
+//	%{name}n     the request's note `name` (see `SetNote()`)
+//	%p           the port of the listener which accepted the request
+//	             (same as `%{canonical}p` and `%{local}p`)
+//	%{remote}p   the client's port (before anonymising the address)
+//	%q           the query string (prefixed by `?`) or an empty string
+//	%r           the first line of the request
+//	%s, %>s      the response status
+//	%t           the time the request was received (see `Timestamp`)
+//	%{FORMAT}t   the time in `strftime()` format, optionally prefixed by
+//	             `begin:` or `end:` (the time the request was received
+//	             or finished), or one of `sec`, `msec`, `usec`,
+//	             `msec_frac`, `usec_frac`
+//	%T, %{UNIT}T the time taken to serve the request (UNIT: `s`, `ms`, `us`)
+//	%u           the remote user
+//	%U           the requested path without query
+//	%v           the requested host name
+//
+// An empty format restores the built-in combined format. See also
+// `ValidateFormat()` and `Preview()`.
+//
+// Example:
+//
+//	err := apachelogger.SetLogFormat(apachelogger.FormatCombined + " %D")
+//
+// Parameters:
+// - `aFormat`: The log format to use.
+//
+// Returns:
+// - `error`: A possible error for an invalid format (which isn't used then).
+func SetLogFormat(aFormat string) error {
+	var lf *tLogFormat
+
+	if "" != aFormat {
+		var err error
+		if lf, err = parseFormat(aFormat); nil != err {
+			return err
+		}
+	}
+
+	alLogFormatMtx.Lock()
+	alLogFormat = lf
+	alLogFormatMtx.Unlock()
+
+	return nil
+} // SetLogFormat()
+
+// `ValidateFormat()` checks whether `aFormat` is a valid log format for
+// `SetLogFormat()`.
+//
+// Parameters:
+// - `aFormat`: The log format to check.
+//
+// Returns:
+// - `error`: A description of the first problem found (`nil` if none).
+func ValidateFormat(aFormat string) error {
+	_, err := parseFormat(aFormat)
+
+	return err
+} // ValidateFormat()
+
+/* _EoF_ */
@@ -0,0 +1,132 @@
+/*
+Copyright © 2024  M.Watermann, 10247 Berlin, Germany
+
+	    All rights reserved
+	EMail : <support@mwat.de>
+*/
+package apachelogger
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+//lint:file-ignore ST1017 – I prefer Yoda conditions
+
+func TestValidateFormat(t *testing.T) {
+	tests := []struct {
+		name    string
+		format  string
+		wantErr bool
+	}{
+		{"combined", FormatCombined, false},
+		{"common", FormatCommon, false},
+		{"literal", "plain text 100%%", false},
+		{"times", "%D %T %{ms}T %{us}T %{s}T", false},
+		{"fields", "%{trace_id}e %{X-Request-Id}i", false},
+		{"trailing", "%h %", true},
+		{"unknown", "%h %Z", true},
+		{"unterminated", "%{Referer i", true},
+		{"no name", "%{}i", true},
+		{"bad unit", "%{min}T", true},
+		{"bad param", "%{x}h", true},
+		{"strftime", "[%{%d/%b/%Y:%H:%M:%S}t.%{msec_frac}t %{%z}t]", false},
+		{"end time", "%{end:%T}t %{end:usec_frac}t", false},
+		{"bad strftime", "%{%Q}t", true},
+		{"host and ports", "%v:%p %{canonical}p %{local}p %{remote}p", false},
+		{"bad port", "%{peer}p", true},
+		{"note", "%{cache}n", false},
+		{"no note name", "%n", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := ValidateFormat(tt.format); (nil != err) != tt.wantErr {
+				t.Errorf("ValidateFormat(%q) error = %v, wantErr %v", tt.format, err, tt.wantErr)
+			}
+		})
+	}
+} // TestValidateFormat()
+
+func TestSetLogFormat(t *testing.T) {
+	defer SetLogFormat("")
+
+	if err := SetLogFormat("%h %Z"); nil == err {
+		t.Error("SetLogFormat() expected an error")
+	}
+	e1 := prepEntry()
+	if got := formatEntry(e1); got != e1.String() {
+		t.Errorf("formatEntry() = %q, want %q", got, e1.String())
+	}
+
+	if err := SetLogFormat(FormatCombined); nil != err {
+		t.Fatalf("SetLogFormat() error = %v", err)
+	}
+	if got := formatEntry(e1); got != e1.String() {
+		t.Errorf("formatEntry() = %q, want %q", got, e1.String())
+	}
+
+	e1.Agent = `say "hi"` + "\t"
+	e1.Duration = 2500 * time.Microsecond
+	e1.SetField("trace_id", "abc")
+	e1.headers = map[string]string{"X-Request-Id": "r1"}
+	if err := SetLogFormat(`%m %U%q %>s %B %D %{ms}T %T "%{User-Agent}i" %{trace_id}e %{x-request-id}i %{missing}e 100%%`); nil != err {
+		t.Fatalf("SetLogFormat() error = %v", err)
+	}
+	w2 := `GET /path/to/file?lang=en 200 27155 2500 2 0 "say \"hi\"\x09" abc r1 - 100%` + "\n"
+	if got := formatEntry(e1); got != w2 {
+		t.Errorf("formatEntry() = %q,\nwant %q", got, w2)
+	}
+} // TestSetLogFormat()
+
+func TestPreview(t *testing.T) {
+	defer SetLogFormat("")
+
+	if err := SetLogFormat(`%h "%r" %>s %b %D "%{X-Request-Id}i"`); nil != err {
+		t.Fatalf("SetLogFormat() error = %v", err)
+	}
+	w1 := `192.0.2.0 "GET /index.html?lang=en HTTP/1.1" 200 1234 1500 "-"` + "\n"
+	if got := Preview(nil); got != w1 {
+		t.Errorf("Preview(nil) = %q,\nwant %q", got, w1)
+	}
+
+	req, _ := http.NewRequest(http.MethodPost, "http://example.com/form", nil)
+	req.RemoteAddr = "198.51.100.7:4711"
+	req.Header.Set("X-Request-Id", "req-42")
+	got := Preview(req)
+	if !strings.HasPrefix(got, `198.51.100.0 "POST /form HTTP/1.1"`) ||
+		!strings.HasSuffix(got, `"req-42"`+"\n") {
+		t.Errorf("Preview() = %q", got)
+	}
+
+	// a captured header is sanitised and the preview has no side effects:
+	defer ClearTransformers()
+	transformed := false
+	AddTransformer(func(*TEntry) { transformed = true })
+	req.Header.Set("X-Request-Id", "req\x1b[31m")
+	if got = Preview(req); !strings.HasSuffix(got, `"req%1B[31m"`+"\n") {
+		t.Errorf("Preview() = %q, want the header sanitised", got)
+	}
+	if transformed {
+		t.Error("Preview() ran the transformers")
+	}
+} // TestPreview()
+
+func BenchmarkLogFormat_appendTo(b *testing.B) {
+	lf, err := parseFormat(FormatCombined + " %D %{X-Request-Id}i")
+	if nil != err {
+		b.Fatalf("parseFormat() error = %v", err)
+	}
+	e1 := prepEntry()
+	e1.headers = map[string]string{"X-Request-Id": "r1"}
+	buf := make([]byte, 0, 512)
+	b.ReportAllocs()
+	b.ResetTimer()
+
+	for n := 0; n < b.N; n++ {
+		buf = lf.appendTo(buf[:0], e1)
+	}
+} // BenchmarkLogFormat_appendTo()
+
+/* _EoF_ */
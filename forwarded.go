@@ -0,0 +1,132 @@
+/*
+Copyright © 2024  M.Watermann, 10247 Berlin, Germany
+
+	    All rights reserved
+	EMail : <support@mwat.de>
+*/
+package apachelogger
+
+import (
+	"strings"
+)
+
+//lint:file-ignore ST1017 – I prefer Yoda conditions
+
+// `anonymiseHop()` anonymises a single address of a proxy chain like
+// `anonymiseAddress()` does for the remote address.
+//
+// Values that aren't IP addresses (e.g. `unknown` or obfuscated
+// identifiers) are returned unchanged.
+//
+// Parameters:
+// - `aHop`: The address (with or without port).
+// - `aStatus`: The HTTP status code of the current request.
+// - `aAnonymiser`: The anonymiser to use (`nil` for the one set by
+// `SetAnonymiser()`).
+//
+// Returns:
+// - `string`: The anonymised address.
+func anonymiseHop(aHop string, aStatus int, aAnonymiser TAnonymiser) string {
+	if !AnonymiseURLs || ((!AnonymiseErrors) && (400 <= aStatus)) {
+		return aHop
+	}
+
+	return anonymiseAddressWith(aHop, aStatus, aAnonymiser)
+} // anonymiseHop()
+
+// `anonymiseChain()` anonymises all addresses of an `X-Forwarded-For`
+// (or `X-Real-IP`) header.
+//
+// Parameters:
+// - `aValue`: The header's value (`client, proxy1, proxy2`).
+// - `aStatus`: The HTTP status code of the current request.
+// - `aAnonymiser`: The anonymiser to use (`nil` for the one set by
+// `SetAnonymiser()`).
+//
+// Returns:
+// - `string`: The header's value with anonymised addresses.
+func anonymiseChain(aValue string, aStatus int, aAnonymiser TAnonymiser) string {
+	hops := strings.Split(aValue, ",")
+	for idx, hop := range hops {
+		hops[idx] = anonymiseHop(strings.TrimSpace(hop), aStatus, aAnonymiser)
+	}
+
+	return strings.Join(hops, ", ")
+} // anonymiseChain()
+
+// `anonymiseForwarded()` anonymises the `for` and `by` addresses of a
+// `Forwarded` header (RFC 7239).
+//
+// Parameters:
+// - `aValue`: The header's value (e.g. `for=192.0.2.60;proto=https`).
+// - `aStatus`: The HTTP status code of the current request.
+// - `aAnonymiser`: The anonymiser to use (`nil` for the one set by
+// `SetAnonymiser()`).
+//
+// Returns:
+// - `string`: The header's value with anonymised addresses.
+func anonymiseForwarded(aValue string, aStatus int, aAnonymiser TAnonymiser) string {
+	elements := strings.Split(aValue, ",")
+	for idx, element := range elements {
+		pairs := strings.Split(strings.TrimSpace(element), ";")
+		for pIdx, pair := range pairs {
+			pos := strings.IndexByte(pair, '=')
+			if 0 > pos {
+				continue
+			}
+			key := strings.TrimSpace(pair[:pos])
+			if !strings.EqualFold("for", key) && !strings.EqualFold("by", key) {
+				continue
+			}
+			node := anonymiseHop(strings.Trim(strings.TrimSpace(pair[pos+1:]), `"`), aStatus, aAnonymiser)
+			if (1 < strings.Count(node, ":")) && !strings.HasPrefix(node, "[") {
+				node = "[" + node + "]" // IPv6 address
+			}
+			if strings.ContainsAny(node, ":[") {
+				node = `"` + node + `"`
+			}
+			pairs[pIdx] = key + "=" + node
+		}
+		elements[idx] = strings.Join(pairs, ";")
+	}
+
+	return strings.Join(elements, ", ")
+} // anonymiseForwarded()
+
+// `anonymiseProxyHeaders()` anonymises the addresses of the proxy
+// chain in the headers captured for `aEntry` (see `SetLogFormat()` and
+// `SetW3CFormat()`), so proxies are treated like the clients.
+//
+// Parameters:
+// - `aEntry`: The log entry whose headers to anonymise.
+func anonymiseProxyHeaders(aEntry *TEntry) {
+	anonymiser := aEntry.settings.remoteAnonymiser()
+	for name, value := range aEntry.headers {
+		aEntry.headers[name] = anonymiseProxyHeader(name, value, aEntry.Status, anonymiser)
+	}
+} // anonymiseProxyHeaders()
+
+// `anonymiseProxyHeader()` anonymises the addresses of the proxy chain
+// if `aName` is `X-Forwarded-For`, `X-Real-IP`, or `Forwarded`.
+//
+// Parameters:
+// - `aName`: The header's (canonical) name.
+// - `aValue`: The header's value.
+// - `aStatus`: The HTTP status code of the current request.
+// - `aAnonymiser`: The anonymiser to use (`nil` for the one set by
+// `SetAnonymiser()`).
+//
+// Returns:
+// - `string`: The (possibly) anonymised value.
+func anonymiseProxyHeader(aName, aValue string, aStatus int, aAnonymiser TAnonymiser) string {
+	switch aName {
+	case "X-Forwarded-For", "X-Real-Ip":
+		return anonymiseChain(aValue, aStatus, aAnonymiser)
+	case "Forwarded":
+		return anonymiseForwarded(aValue, aStatus, aAnonymiser)
+	}
+
+	return aValue
+} // anonymiseProxyHeader()
+
+/* _EoF_ */
@@ -0,0 +1,44 @@
+/*
+Copyright © 2024  M.Watermann, 10247 Berlin, Germany
+
+	    All rights reserved
+	EMail : <support@mwat.de>
+*/
+package apachelogger
+
+import (
+	"testing"
+)
+
+//lint:file-ignore ST1017 – I prefer Yoda conditions
+
+func Test_anonymiseProxyHeader(t *testing.T) {
+	tests := []struct {
+		name   string
+		value  string
+		status int
+		want   string
+	}{
+		{"X-Forwarded-For", "203.0.113.77, 198.51.100.23:8080,unknown", 200,
+			"203.0.113.0, 198.51.100.0, unknown"},
+		{"X-Forwarded-For", "203.0.113.77, 198.51.100.23", 404,
+			"203.0.113.77, 198.51.100.23"},
+		{"X-Real-Ip", "203.0.113.77", 200, "203.0.113.0"},
+		{"Forwarded", `for=203.0.113.77;proto=https;by=198.51.100.23, for="[2001:db8:cafe::17]:4711", for=_hidden`, 200,
+			`for=203.0.113.0;proto=https;by=198.51.100.0, for="[2001:db8:cafe:0:0:0:0:0]", for=_hidden`},
+		{"X-Request-Id", "203.0.113.77", 200, "203.0.113.77"},
+	}
+	for _, tt := range tests {
+		if got := anonymiseProxyHeader(tt.name, tt.value, tt.status, nil); tt.want != got {
+			t.Errorf("anonymiseProxyHeader(%q, %q) = %q,\nwant %q", tt.name, tt.value, got, tt.want)
+		}
+	}
+
+	AnonymiseURLs = false
+	defer func() { AnonymiseURLs = true }()
+	if got := anonymiseChain("203.0.113.77:80", 200, nil); "203.0.113.77:80" != got {
+		t.Errorf("anonymiseChain() = %q, want it unchanged", got)
+	}
+} // Test_anonymiseProxyHeader()
+
+/* _EoF_ */
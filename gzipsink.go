@@ -0,0 +1,128 @@
+/*
+Copyright © 2024  M.Watermann, 10247 Berlin, Germany
+
+	    All rights reserved
+	EMail : <support@mwat.de>
+*/
+package apachelogger
+
+import (
+	"compress/gzip"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+//lint:file-ignore ST1017 – I prefer Yoda conditions
+
+type (
+	// `tGzipFileSink` writes gzip-compressed log entries to a file.
+	tGzipFileSink struct {
+		file      *os.File      // the currently opened logfile
+		interval  time.Duration // time between flush points
+		lastFlush time.Time     // time of the last flush point
+		name      string        // the logfile's name
+		writer    *gzip.Writer  // the compressor writing to `file`
+	}
+)
+
+// `NewGzipFileSink()` returns a sink appending gzip-compressed log
+// entries to the file `aFilename`.
+//
+// This is meant for long-retention archive logs which are never tailed
+// live. Every time the file is (re-)opened a new gzip member is started;
+// since concatenated gzip members form a valid gzip stream, tools like
+// `zcat` or `zgrep` can read the file as a whole. The compressed data is
+// flushed to the file at most every `aFlushInterval` (at least once
+// per second), so a crash loses at most that much of the log.
+//
+// Parameters:
+// - `aFilename`: The name of the logfile to write to (e.g. `access.log.gz`).
+// - `aFlushInterval`: The time between flush points.
+//
+// Returns:
+// - `TSink`: The sink to use with `WrapSinks()`.
+func NewGzipFileSink(aFilename string, aFlushInterval time.Duration) TSink {
+	if 0 < len(aFilename) {
+		if absFile, err := filepath.Abs(aFilename); nil == err {
+			aFilename = absFile
+		}
+	}
+	if time.Second > aFlushInterval {
+		aFlushInterval = time.Second
+	}
+
+	return &tGzipFileSink{
+		interval: aFlushInterval,
+		name:     aFilename,
+	}
+} // NewGzipFileSink()
+
+// `Close()` finishes the current gzip member and closes the logfile.
+//
+// Part of the `TSink` interface.
+//
+// Returns:
+// - `error`: A possible error while closing the logfile.
+func (gs *tGzipFileSink) Close() (rErr error) {
+	if nil != gs.writer {
+		rErr = gs.writer.Close()
+		gs.writer = nil
+	}
+	if nil != gs.file {
+		if err := gs.file.Close(); nil == rErr {
+			rErr = err
+		}
+		gs.file = nil
+	}
+
+	return
+} // Close()
+
+// `Flush()` writes the compressed data to the logfile if the flush
+// interval has passed.
+//
+// Part of the `TSink` interface.
+//
+// Returns:
+// - `error`: A possible error while writing the data.
+func (gs *tGzipFileSink) Flush() error {
+	if (nil == gs.writer) || (gs.interval > time.Since(gs.lastFlush)) {
+		return nil
+	}
+	gs.lastFlush = time.Now()
+
+	return gs.writer.Flush()
+} // Flush()
+
+// `Write()` compresses `aData` and writes it to the logfile, opening
+// it if necessary.
+//
+// Part of the `TSink` interface.
+//
+// Parameters:
+// - `aData`: The data to write to the logfile.
+//
+// Returns:
+// - `error`: A possible error while writing the data.
+func (gs *tGzipFileSink) Write(aData []byte) (rErr error) {
+	if nil == gs.file {
+		// no O_SYNC here: the flush points control durability
+		if gs.file, rErr = os.OpenFile(gs.name,
+			os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0640); /* #nosec G302 */ nil != rErr {
+			return
+		}
+		gs.writer = gzip.NewWriter(gs.file)
+		if header := fileHeader(); "" != header {
+			if fi, err := gs.file.Stat(); (nil == err) && (0 == fi.Size()) {
+				_, _ = gs.writer.Write([]byte(header))
+			}
+		}
+		gs.lastFlush = time.Now()
+	}
+	_, rErr = gs.writer.Write(aData)
+
+	return
+} // Write()
+
+/* _EoF_ */
@@ -0,0 +1,50 @@
+/*
+Copyright © 2024  M.Watermann, 10247 Berlin, Germany
+
+	    All rights reserved
+	EMail : <support@mwat.de>
+*/
+package apachelogger
+
+import (
+	"compress/gzip"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+//lint:file-ignore ST1017 – I prefer Yoda conditions
+
+func Test_tGzipFileSink(t *testing.T) {
+	fName := filepath.Join(t.TempDir(), "access.log.gz")
+	sink := NewGzipFileSink(fName, 0)
+
+	_ = sink.Write([]byte("line 1\n"))
+	_ = sink.Flush()
+	_ = sink.Close()
+	// reopening starts a second gzip member:
+	_ = sink.Write([]byte("line 2\n"))
+	if err := sink.Close(); nil != err {
+		t.Fatalf("tGzipFileSink.Close() error = %v", err)
+	}
+
+	file, err := os.Open(fName) // #nosec G304
+	if nil != err {
+		t.Fatalf("os.Open() error = %v", err)
+	}
+	defer file.Close()
+	reader, err := gzip.NewReader(file)
+	if nil != err {
+		t.Fatalf("gzip.NewReader() error = %v", err)
+	}
+	got, err := io.ReadAll(reader)
+	if nil != err {
+		t.Fatalf("io.ReadAll() error = %v", err)
+	}
+	if want := "line 1\nline 2\n"; want != string(got) {
+		t.Errorf("tGzipFileSink content = %q, want %q", got, want)
+	}
+} // Test_tGzipFileSink()
+
+/* _EoF_ */
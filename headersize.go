@@ -0,0 +1,89 @@
+/*
+Copyright © 2024  M.Watermann, 10247 Berlin, Germany
+
+	    All rights reserved
+	EMail : <support@mwat.de>
+*/
+package apachelogger
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+//lint:file-ignore ST1017 – I prefer Yoda conditions
+
+var (
+	// `LogHeaderSize` decides whether to add the size of the request's
+	// headers (`header_bytes`) and their number (`header_count`) as
+	// fields to the access log entries (default: `false`), e.g. to spot
+	// abusive clients or to tune the server's `MaxHeaderBytes`.
+	//
+	// The headers are measured before the handler is called, as they'd
+	// be sent by HTTP/1 (`Name: value` plus CRLF per header line,
+	// including `Host`); for HTTP/2 requests that's the size of the
+	// decoded headers.
+	LogHeaderSize = false
+)
+
+// `headerSize()` returns the size and number of the request's header
+// lines.
+//
+// Parameters:
+// - `aRequest`: The request to measure.
+//
+// Returns:
+// - `int`: The headers' size in bytes.
+// - `int`: The number of header lines.
+func headerSize(aRequest *http.Request) (rBytes, rCount int) {
+	line := func(aName, aValue string) {
+		rBytes += len(aName) + len(aValue) + 4 // ": " and CRLF
+		rCount++
+	}
+
+	if "" != aRequest.Host {
+		line("Host", aRequest.Host) // removed from `Header` by the server
+	}
+	if 0 < len(aRequest.TransferEncoding) {
+		// removed from `Header` as well
+		line("Transfer-Encoding", strings.Join(aRequest.TransferEncoding, ", "))
+	}
+	for name, values := range aRequest.Header {
+		for _, value := range values {
+			line(name, value)
+		}
+	}
+
+	return
+} // headerSize()
+
+// `captureHeaderSize()` stores the size and number of the request's
+// headers in `aState` if `LogHeaderSize` is set.
+//
+// It must be called before the handler may modify the headers.
+//
+// Parameters:
+// - `aState`: The request's logging state.
+// - `aRequest`: The request to measure.
+func captureHeaderSize(aState *tRequestState, aRequest *http.Request) {
+	if LogHeaderSize {
+		aState.headerBytes, aState.headerCount = headerSize(aRequest)
+		aState.headerSized = true
+	}
+} // captureHeaderSize()
+
+// `addHeaderSize()` adds the fields `header_bytes` and `header_count`
+// captured by `captureHeaderSize()` to `aEntry`.
+//
+// Parameters:
+// - `aEntry`: The log entry to extend.
+// - `aState`: The request's logging state.
+func addHeaderSize(aEntry *TEntry, aState *tRequestState) {
+	if aState.headerSized {
+		aEntry.SetField("header_bytes", strconv.Itoa(aState.headerBytes))
+		aEntry.SetField("header_count", strconv.Itoa(aState.headerCount))
+	}
+} // addHeaderSize()
+
+/* _EoF_ */
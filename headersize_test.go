@@ -0,0 +1,55 @@
+/*
+Copyright © 2024  M.Watermann, 10247 Berlin, Germany
+
+	    All rights reserved
+	EMail : <support@mwat.de>
+*/
+package apachelogger
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+//lint:file-ignore ST1017 – I prefer Yoda conditions
+
+func Test_headerSize(t *testing.T) {
+	req := httptest.NewRequest(http.MethodGet, "http://example.com/", nil)
+	req.Header.Set("Accept", "*/*")
+	// "Host: example.com\r\n" + "Accept: */*\r\n":
+	if bytes, count := headerSize(req); (32 != bytes) || (2 != count) {
+		t.Errorf("headerSize() = %d, %d, want 32, 2", bytes, count)
+	}
+
+	req.Header.Add("Accept", "text/html")
+	req.TransferEncoding = []string{"chunked"}
+	// + "Accept: text/html\r\n" + "Transfer-Encoding: chunked\r\n":
+	if bytes, count := headerSize(req); (32+19+28 != bytes) || (4 != count) {
+		t.Errorf("headerSize() = %d, %d, want %d, 4", bytes, count, 32+19+28)
+	}
+} // Test_headerSize()
+
+func Test_addHeaderSize(t *testing.T) {
+	defer func() { LogHeaderSize = false }()
+	req := httptest.NewRequest(http.MethodGet, "http://example.com/", nil)
+
+	rs := &tRequestState{}
+	captureHeaderSize(rs, req) // not enabled
+	e := prepEntry()
+	addHeaderSize(e, rs)
+	if 0 != len(e.Fields) {
+		t.Errorf("addHeaderSize() fields = %v, want none", e.Fields)
+	}
+
+	LogHeaderSize = true
+	captureHeaderSize(rs, req)
+	req.Header.Set("X-Added", strings.Repeat("x", 100)) // by the handler
+	addHeaderSize(e, rs)
+	if ("19" != e.Fields["header_bytes"]) || ("1" != e.Fields["header_count"]) {
+		t.Errorf("addHeaderSize() fields = %v, want the headers as received", e.Fields)
+	}
+} // Test_addHeaderSize()
+
+/* _EoF_ */
@@ -0,0 +1,232 @@
+/*
+Copyright © 2024  M.Watermann, 10247 Berlin, Germany
+
+	    All rights reserved
+	EMail : <support@mwat.de>
+*/
+package apachelogger
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+//lint:file-ignore ST1017 – I prefer Yoda conditions
+
+type (
+	// `tWriterState` holds the state of a queue's background writer.
+	tWriterState struct {
+		sync.Mutex
+		busy      int32     // `1` while the writer handles messages (accessed atomically)
+		running   bool      // the writer is running
+		open      bool      // data was written since the last `Close()`
+		since     time.Time // time the writer started
+		lastWrite time.Time // time of the last successful write
+		lastError time.Time // time of the last failed write
+		errText   string    // message of the last failed write
+		errors    uint64    // number of failed writes
+	}
+
+	// `TWriterHealth` holds the state of a log's background writer.
+	TWriterHealth struct {
+		Running     bool      `json:"running"`              // the writer is running
+		Open        bool      `json:"open"`                 // the sink is open
+		LastWrite   time.Time `json:"last_write"`           // time of the last successful write
+		LastError   string    `json:"last_error,omitempty"` // message of the last failed write or flush
+		WriteErrors uint64    `json:"write_errors"`         // number of failed writes and flushes
+		Dropped     uint64    `json:"dropped"`              // messages rejected by the closed or full queue
+		QueueLength int       `json:"queue_length"`         // number of messages waiting
+	}
+
+	// `THealth` holds the state of the logger's background writers.
+	THealth struct {
+		Access  TWriterHealth `json:"access"`            // the access log's writer
+		Error   TWriterHealth `json:"error"`             // the error log's writer
+		Paused  bool          `json:"paused"`            // access logging is paused
+		Problem string        `json:"problem,omitempty"` // the reason of being unhealthy
+
+		// The writers of further wrappers' sinks and additional
+		// logfiles, by filename or sink.
+		Logs map[string]TWriterHealth `json:"logs,omitempty"`
+
+		// Rotated logfiles `NewArchiver()` failed to archive, and the
+		// message of the last failure.
+		ArchiveErrors uint64 `json:"archive_errors"`
+		ArchiveError  string `json:"archive_error,omitempty"`
+	}
+)
+
+var (
+	// `HealthStallTimeout` is the time messages may wait in a queue
+	// without a successful write before the writer is considered stuck
+	// (default: 30 seconds).
+	HealthStallTimeout = 30 * time.Second
+)
+
+// `started()` marks the writer as running (or not).
+//
+// Parameters:
+// - `aRunning`: Whether the writer is running.
+func (ws *tWriterState) started(aRunning bool) {
+	ws.Lock()
+	ws.running, ws.open = aRunning, false
+	ws.since = time.Now()
+	ws.Unlock()
+} // started()
+
+// `closed()` records that the writer closed its sink.
+func (ws *tWriterState) closed() {
+	ws.Lock()
+	ws.open = false
+	ws.Unlock()
+} // closed()
+
+// `wrote()` records the result of a write to the sink.
+//
+// Parameters:
+// - `aErr`: The write's error (`nil` on success).
+func (ws *tWriterState) wrote(aErr error) {
+	now := time.Now()
+	ws.Lock()
+	ws.open = true
+	if nil == aErr {
+		ws.lastWrite = now
+	} else {
+		ws.lastError, ws.errText = now, aErr.Error()
+		ws.errors++
+	}
+	ws.Unlock()
+} // wrote()
+
+// `health()` returns the state of the queue's writer.
+//
+// Returns:
+// - `TWriterHealth`: The writer's state.
+// - `string`: The reason of the writer being unhealthy (empty if healthy).
+func (r *tRing) health() (TWriterHealth, string) {
+	ws := &r.state
+	ws.Lock()
+	result := TWriterHealth{
+		Running:     ws.running,
+		Open:        ws.open,
+		LastWrite:   ws.lastWrite,
+		LastError:   ws.errText,
+		WriteErrors: ws.errors,
+		Dropped:     atomic.LoadUint64(&r.dropped),
+		QueueLength: r.length(),
+	}
+	var (
+		failing  = ws.lastError.After(ws.lastWrite)
+		progress = ws.lastWrite
+	)
+	if progress.Before(ws.since) {
+		progress = ws.since
+	}
+	ws.Unlock()
+
+	switch {
+	case !result.Running:
+		return result, "writer not running"
+	case failing:
+		return result, "last write failed: " + result.LastError
+	case (0 < result.QueueLength) && (HealthStallTimeout < time.Since(progress)):
+		return result, fmt.Sprintf("no write for %v with %d messages waiting",
+			time.Since(progress).Round(time.Second), result.QueueLength)
+	}
+
+	return result, ""
+} // health()
+
+/* * * * * * * * * * * * * * * * * * * * * * * * * * * * * * * * * * * * * */
+
+// `Health()` returns the current state of the logger's background
+// writers.
+//
+// Besides the writers of the global access and error log those of the
+// further wrappers' sinks and of additional logfiles are reported (as
+// `Logs`); the first problem found is reported as `Problem`.
+//
+// Returns:
+// - `THealth`: The current state.
+func Health() THealth {
+	var (
+		result         = THealth{Paused: accessPaused()}
+		access, errLog string
+	)
+	result.Access, access = accessQueue().health()
+	result.Error, errLog = errorQueue().health()
+	result.ArchiveErrors, result.ArchiveError = archiveHealth()
+	switch {
+	case "" != access:
+		result.Problem = "access log: " + access
+	case "" != errLog:
+		result.Problem = "error log: " + errLog
+	}
+
+	others := otherQueues()
+	names := make([]string, 0, len(others))
+	for name := range others {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		health, problem := others[name].health()
+		if nil == result.Logs {
+			result.Logs = make(map[string]TWriterHealth, len(others))
+		}
+		result.Logs[name] = health
+		if ("" == result.Problem) && ("" != problem) {
+			result.Problem = name + ": " + problem
+		}
+	}
+
+	return result
+} // Health()
+
+// `HealthHandler()` returns a handler reporting the state of the
+// logger's background writers as JSON (see `THealth`), e.g. for a
+// readiness probe.
+//
+// The response's status is `200` if the logger is healthy, or `503`
+// otherwise.
+//
+// Returns:
+// - `http.Handler`: The handler to register with your router.
+func HealthHandler() http.Handler {
+	return http.HandlerFunc(
+		func(aWriter http.ResponseWriter, aRequest *http.Request) {
+			health := Health()
+			body, _ := json.Marshal(health)
+
+			aWriter.Header().Set("Content-Type", "application/json")
+			aWriter.Header().Set("Cache-Control", "no-store")
+			if "" != health.Problem {
+				aWriter.WriteHeader(http.StatusServiceUnavailable)
+			}
+			_, _ = aWriter.Write(body)
+		})
+} // HealthHandler()
+
+// `Healthy()` reports whether the logger works as expected.
+//
+// The logger is unhealthy if a background writer isn't running (e.g.
+// before `Wrap()` was called), if the last write to a sink failed, or
+// if messages waited longer than `HealthStallTimeout` without any
+// successful write.
+//
+// Returns:
+// - `error`: The reason of being unhealthy, or `nil` if healthy.
+func Healthy() error {
+	if problem := Health().Problem; "" != problem {
+		return fmt.Errorf("apachelogger: %s", problem)
+	}
+
+	return nil
+} // Healthy()
+
+/* _EoF_ */
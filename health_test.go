@@ -0,0 +1,101 @@
+/*
+Copyright © 2024  M.Watermann, 10247 Berlin, Germany
+
+	    All rights reserved
+	EMail : <support@mwat.de>
+*/
+package apachelogger
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+//lint:file-ignore ST1017 – I prefer Yoda conditions
+
+func Test_tRing_health(t *testing.T) {
+	defer func(aTimeout time.Duration) {
+		HealthStallTimeout = aTimeout
+	}(HealthStallTimeout)
+	queue := newRing(4)
+
+	if _, problem := queue.health(); "writer not running" != problem {
+		t.Errorf("health() = %q, want %q", problem, "writer not running")
+	}
+
+	queue.state.started(true)
+	queue.state.wrote(nil)
+	got, problem := queue.health()
+	if "" != problem {
+		t.Errorf("health() = %q, want healthy", problem)
+	}
+	if !got.Running || !got.Open || got.LastWrite.IsZero() {
+		t.Errorf("health() = %+v, want running and open", got)
+	}
+
+	queue.state.wrote(errors.New("disk full"))
+	if _, problem = queue.health(); !strings.HasSuffix(problem, "disk full") {
+		t.Errorf("health() = %q, want failed write", problem)
+	}
+	queue.state.wrote(nil)
+
+	HealthStallTimeout = time.Millisecond
+	queue.push("waiting\n")
+	time.Sleep(5 * time.Millisecond)
+	if _, problem = queue.health(); !strings.HasPrefix(problem, "no write for") {
+		t.Errorf("health() = %q, want stalled writer", problem)
+	}
+
+	queue.close()
+	queue.push("dropped\n")
+	if got, _ = queue.health(); 1 != got.Dropped {
+		t.Errorf("health().Dropped = %d, want 1", got.Dropped)
+	}
+} // Test_tRing_health()
+
+type (
+	// `tFlushFailSink` accepts all writes but fails to flush them.
+	tFlushFailSink struct {
+		tMemSink
+	}
+)
+
+func (fs *tFlushFailSink) Flush() error {
+	return errors.New("flush failed")
+} // Flush()
+
+func Test_tRing_health_flush(t *testing.T) {
+	queue := newRing(4)
+	queue.push("entry\n")
+	queue.close()
+	goDoLogWrite(&tFlushFailSink{}, queue)
+
+	got, _ := queue.health()
+	if (1 != got.WriteErrors) || ("flush failed" != got.LastError) {
+		t.Errorf("health() = %+v, want the failed flush", got)
+	}
+} // Test_tRing_health_flush()
+
+func TestHealthHandler(t *testing.T) {
+	rec := httptest.NewRecorder()
+	HealthHandler().ServeHTTP(rec, httptest.NewRequest("GET", "/health", nil))
+
+	var got THealth
+	if err := json.Unmarshal(rec.Body.Bytes(), &got); nil != err {
+		t.Fatalf("HealthHandler() body %q: %v", rec.Body.String(), err)
+	}
+	want := http.StatusOK
+	if nil != Healthy() {
+		want = http.StatusServiceUnavailable
+	}
+	if rec.Code != want {
+		t.Errorf("HealthHandler() status = %d, want %d", rec.Code, want)
+	}
+} // TestHealthHandler()
+
+/* _EoF_ */
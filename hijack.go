@@ -0,0 +1,181 @@
+/*
+Copyright © 2024  M.Watermann, 10247 Berlin, Germany
+
+	    All rights reserved
+	EMail : <support@mwat.de>
+*/
+package apachelogger
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+//lint:file-ignore ST1017 – I prefer Yoda conditions
+
+type (
+	// `tHijackedConn` counts the data sent over a hijacked connection
+	// and logs the session when it's closed.
+	tHijackedConn struct {
+		net.Conn
+		bytesIn  int64     // bytes read (accessed atomically)
+		bytesOut int64     // bytes written (accessed atomically)
+		entry    *TEntry   // the entry logged at the upgrade
+		key      string    // the key of the queue's shard
+		queue    *tRing    // the queue of the access log entries
+		opened   time.Time // time the connection was hijacked
+		once     sync.Once // make sure to log the session only once
+	}
+)
+
+var (
+	// The error returned if the `ResponseWriter` can't be hijacked.
+	errNoHijacker = errors.New("apachelogger: ResponseWriter doesn't implement http.Hijacker")
+)
+
+// `Close()` closes the connection and logs the session.
+//
+// Part of the `net.Conn` interface.
+//
+// Returns:
+// - `error`: A possible error while closing the connection.
+func (hc *tHijackedConn) Close() error {
+	err := hc.Conn.Close()
+	hc.once.Do(hc.logSession)
+
+	return err
+} // Close()
+
+// `logSession()` queues the closing entry of the session.
+//
+// The entry repeats the upgrade entry with the session's duration,
+// the bytes sent as its size, and the fields `session=closed`,
+// `bytes_in`, and `bytes_out`.
+func (hc *tHijackedConn) logSession() {
+	if (nil == hc.entry) || accessPaused() {
+		return
+	}
+	entry := *hc.entry
+	entry.Fields = make(map[string]string, len(hc.entry.Fields)+3)
+	for key, value := range hc.entry.Fields {
+		entry.Fields[key] = value
+	}
+	var (
+		bytesIn  = atomic.LoadInt64(&hc.bytesIn)
+		bytesOut = atomic.LoadInt64(&hc.bytesOut)
+	)
+	entry.Duration = elapsed(hc.opened, time.Now())
+	entry.Size = int(bytesOut)
+	entry.SetField("session", "closed")
+	entry.SetField("bytes_in", strconv.FormatInt(bytesIn, 10))
+	entry.SetField("bytes_out", strconv.FormatInt(bytesOut, 10))
+
+	stampSequence(&entry, hc.queue)
+	hc.queue.pushKey(hc.key, formatEntry(&entry))
+} // logSession()
+
+// `Read()` reads data from the connection, counting the bytes read.
+//
+// Part of the `net.Conn` interface.
+//
+// Parameters:
+// - `aData`: The buffer to read into.
+//
+// Returns:
+// - `int`: The number of bytes read.
+// - `error`: A possible error while reading.
+func (hc *tHijackedConn) Read(aData []byte) (int, error) {
+	n, err := hc.Conn.Read(aData)
+	atomic.AddInt64(&hc.bytesIn, int64(n))
+
+	return n, err
+} // Read()
+
+// `Write()` writes data to the connection, counting the bytes written.
+//
+// Part of the `net.Conn` interface.
+//
+// Parameters:
+// - `aData`: The data to write.
+//
+// Returns:
+// - `int`: The number of bytes written.
+// - `error`: A possible error while writing.
+func (hc *tHijackedConn) Write(aData []byte) (int, error) {
+	n, err := hc.Conn.Write(aData)
+	atomic.AddInt64(&hc.bytesOut, int64(n))
+
+	return n, err
+} // Write()
+
+/* * * * * * * * * * * * * * * * * * * * * * * * * * * * * * * * * * * * * */
+
+// `Hijack()` lets the handler take over the connection, e.g. for a
+// WebSocket session.
+//
+// Part of the `http.Hijacker` interface.
+//
+// The request is logged at once (with status `101` unless the handler
+// set another one), and a second entry is logged when the returned
+// connection is closed, holding the session's duration and the bytes
+// received and sent.
+//
+// Returns:
+// - `net.Conn`: The hijacked connection.
+// - `*bufio.ReadWriter`: The connection's buffered reader and writer.
+// - `error`: A possible error while hijacking the connection.
+func (lw *tLogWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	hijacker, ok := lw.ResponseWriter.(http.Hijacker)
+	if !ok {
+		return nil, nil, errNoHijacker
+	}
+	conn, brw, err := hijacker.Hijack()
+	if nil != err {
+		return conn, brw, err
+	}
+	lw.hijacked = true
+
+	hc := &tHijackedConn{Conn: conn, opened: time.Now(), queue: lw.queue}
+	if nil != lw.request {
+		if 0 == lw.status {
+			lw.status = http.StatusSwitchingProtocols
+		}
+		lw.duration = elapsed(lw.when, time.Now())
+		if rs := requestState(lw.request.Context()); (nil == rs) || !rs.isSuppressed() {
+			hc.entry = webLog(lw, lw.request, lw.queue)
+		}
+		hc.key = lw.request.RemoteAddr
+	}
+
+	// Route the buffered data through the counting connection:
+	if nil != brw {
+		if buffered := brw.Reader.Buffered(); 0 < buffered {
+			// keep the data read ahead by the server (already counted):
+			data, _ := brw.Reader.Peek(buffered)
+			hc.bytesIn = int64(buffered)
+			brw.Reader.Reset(io.MultiReader(bytes.NewReader(append([]byte(nil), data...)), hc))
+		} else {
+			brw.Reader.Reset(hc)
+		}
+		if buffered := brw.Writer.Buffered(); 0 < buffered {
+			// send the pending data before switching the writer:
+			if err = brw.Writer.Flush(); nil != err {
+				return hc, brw, err
+			}
+			hc.bytesOut = int64(buffered)
+		}
+		brw.Writer.Reset(hc)
+	}
+
+	return hc, brw, nil
+} // Hijack()
+
+/* _EoF_ */
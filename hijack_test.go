@@ -0,0 +1,164 @@
+/*
+Copyright © 2024  M.Watermann, 10247 Berlin, Germany
+
+	    All rights reserved
+	EMail : <support@mwat.de>
+*/
+package apachelogger
+
+import (
+	"bufio"
+	"io"
+	"net"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+//lint:file-ignore ST1017 – I prefer Yoda conditions
+
+type (
+	// `tHijackRecorder` is a `ResponseRecorder` that can be hijacked.
+	tHijackRecorder struct {
+		*httptest.ResponseRecorder
+		conn net.Conn
+		brw  *bufio.ReadWriter // buffers to return (if any)
+	}
+)
+
+func (hr *tHijackRecorder) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	if nil != hr.brw {
+		return hr.conn, hr.brw, nil
+	}
+	return hr.conn, bufio.NewReadWriter(bufio.NewReader(hr.conn),
+		bufio.NewWriter(hr.conn)), nil
+} // Hijack()
+
+func Test_tLogWriter_Hijack(t *testing.T) {
+	defer func(aCanonical bool) {
+		CanonicalLogLine = aCanonical
+	}(CanonicalLogLine)
+	defer setLogQueues(setLogQueues(newRing(8), nil))
+	CanonicalLogLine = true
+
+	lw := &tLogWriter{ResponseWriter: httptest.NewRecorder(), when: time.Now()}
+	if _, _, err := lw.Hijack(); errNoHijacker != err {
+		t.Errorf("Hijack() error = %v, want %v", err, errNoHijacker)
+	}
+
+	server, client := net.Pipe()
+	go func() {
+		buf := make([]byte, 16)
+		_, _ = client.Write([]byte("ping"))
+		_, _ = client.Read(buf)
+		_ = client.Close()
+	}()
+	req, _ := withRequestState(httptest.NewRequest("GET", "/ws", nil))
+	lw = &tLogWriter{
+		ResponseWriter: &tHijackRecorder{ResponseRecorder: httptest.NewRecorder(), conn: server},
+		when:           time.Now(),
+		request:        req,
+		queue:          accessQueue(),
+	}
+	conn, brw, err := lw.Hijack()
+	if nil != err {
+		t.Fatalf("Hijack() error = %v", err)
+	}
+	buf := make([]byte, 4)
+	_, _ = brw.Read(buf)
+	_, _ = brw.WriteString("pong!")
+	_ = brw.Flush()
+	_ = conn.Close()
+	_ = conn.Close() // logs only once
+
+	lines := accessQueue().popBatch(make([]string, 0, 8))
+	if 2 != len(lines) {
+		t.Fatalf("Hijack() queued %d lines, want 2: %q", len(lines), lines)
+	}
+	if !strings.Contains(lines[0], " status=101 ") {
+		t.Errorf("upgrade line = %q, want status 101", lines[0])
+	}
+	for _, want := range []string{" bytes_in=4", " bytes_out=5", " session=closed", " size=5 "} {
+		if !strings.Contains(lines[1], want) {
+			t.Errorf("closing line = %q, want %q", lines[1], want)
+		}
+	}
+} // Test_tLogWriter_Hijack()
+
+func Test_tLogWriter_Hijack_buffered(t *testing.T) {
+	defer func(aCanonical bool) {
+		CanonicalLogLine = aCanonical
+	}(CanonicalLogLine)
+	defer setLogQueues(setLogQueues(newRing(8), nil))
+	CanonicalLogLine = true
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if nil != err {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+	var (
+		more     = make(chan struct{})
+		received = make(chan string, 1)
+	)
+	go func() {
+		client, err := net.Dial("tcp", listener.Addr().String())
+		if nil != err {
+			received <- err.Error()
+			return
+		}
+		defer client.Close()
+		_, _ = client.Write([]byte("ping"))
+		<-more
+		_, _ = client.Write([]byte("more"))
+		data, _ := io.ReadAll(client)
+		received <- string(data)
+	}()
+	server, err := listener.Accept()
+	if nil != err {
+		t.Fatal(err)
+	}
+
+	// data read ahead and written, but not flushed, by the server:
+	brw := bufio.NewReadWriter(bufio.NewReader(server), bufio.NewWriter(server))
+	if _, err = brw.Peek(4); nil != err {
+		t.Fatal(err)
+	}
+	_, _ = brw.WriteString("hello")
+
+	req, _ := withRequestState(httptest.NewRequest("GET", "/ws", nil))
+	lw := &tLogWriter{
+		ResponseWriter: &tHijackRecorder{ResponseRecorder: httptest.NewRecorder(), conn: server, brw: brw},
+		when:           time.Now(),
+		request:        req,
+		queue:          accessQueue(),
+	}
+	conn, brw, err := lw.Hijack()
+	if nil != err {
+		t.Fatalf("Hijack() error = %v", err)
+	}
+	close(more)
+	buf := make([]byte, 8)
+	if _, err = io.ReadFull(brw, buf); (nil != err) || ("pingmore" != string(buf)) {
+		t.Errorf("read %q (%v), want %q", buf, err, "pingmore")
+	}
+	_, _ = brw.WriteString("pong!")
+	_ = brw.Flush()
+	_ = conn.Close()
+	if got := <-received; "hellopong!" != got {
+		t.Errorf("client received %q, want %q", got, "hellopong!")
+	}
+
+	lines := accessQueue().popBatch(make([]string, 0, 8))
+	if 2 != len(lines) {
+		t.Fatalf("Hijack() queued %d lines, want 2: %q", len(lines), lines)
+	}
+	for _, want := range []string{" bytes_in=8", " bytes_out=10"} {
+		if !strings.Contains(lines[1], want) {
+			t.Errorf("closing line = %q, want %q", lines[1], want)
+		}
+	}
+} // Test_tLogWriter_Hijack_buffered()
+
+/* _EoF_ */
@@ -0,0 +1,199 @@
+/*
+Copyright © 2024  M.Watermann, 10247 Berlin, Germany
+
+	    All rights reserved
+	EMail : <support@mwat.de>
+*/
+package apachelogger
+
+import (
+	"context"
+	"net"
+	"strings"
+	"sync"
+	"time"
+)
+
+//lint:file-ignore ST1017 – I prefer Yoda conditions
+
+type (
+	// `tHostname` is a cached result of a reverse DNS lookup.
+	tHostname struct {
+		expires time.Time // time the result becomes invalid
+		name    string    // the hostname (empty if unknown)
+		pending bool      // `true` while the lookup is running
+	}
+)
+
+const (
+	// `HostnameOff` disables reverse DNS lookups (default).
+	HostnameOff = iota
+
+	// `HostnameAlongside` adds the client's hostname as the entry
+	// field `remote_host`.
+	HostnameAlongside
+
+	// `HostnameInstead` logs the client's hostname instead of the
+	// (anonymised) remote address.
+	HostnameInstead
+)
+
+const (
+	// Max. number of cached lookup results.
+	alHostnameCacheMax = 4096
+
+	// Max. number of concurrent lookups.
+	alHostnameLookupsMax = 8
+
+	// Max. time to wait for a lookup's answer.
+	alHostnameTimeout = 3 * time.Second
+
+	// Time to keep failed lookups in the cache.
+	alHostnameNegativeTTL = 5 * time.Minute
+)
+
+var (
+	// `HostnameLookups` decides whether to resolve the client addresses
+	// to hostnames (like Apache's `HostnameLookups` directive).
+	//
+	// Possible values are `HostnameOff` (default), `HostnameAlongside`,
+	// and `HostnameInstead`. Lookups are done in background and never
+	// delay a request: an address not yet in the cache is logged
+	// without hostname while its lookup is started. Note that hostnames
+	// may identify a client as well as its full address does.
+	HostnameLookups = HostnameOff
+
+	// `HostnameTTL` is the time a resolved hostname is cached
+	// (default: one hour).
+	HostnameTTL = time.Hour
+
+	// Cache of reverse DNS lookup results, by IP address.
+	alHostnames = make(map[string]*tHostname)
+
+	// Guard for concurrent access to `alHostnames`.
+	alHostnamesMtx sync.Mutex
+
+	// Semaphore limiting the number of concurrent lookups.
+	alHostnameSem = make(chan struct{}, alHostnameLookupsMax)
+
+	// The function doing the actual lookups (replaceable for testing).
+	alLookupAddr = net.DefaultResolver.LookupAddr
+)
+
+// `addHostname()` adds the hostname of `aAddr` (if known) to `aEntry`
+// according to `HostnameLookups`.
+//
+// Parameters:
+// - `aEntry`: The log entry to augment.
+// - `aAddr`: The client's (not anonymised) address.
+func addHostname(aEntry *TEntry, aAddr string) {
+	if host, _, err := net.SplitHostPort(aAddr); nil == err {
+		aAddr = host
+	}
+	name, ok := lookupHostname(aAddr)
+	if !ok {
+		return
+	}
+
+	switch HostnameLookups {
+	case HostnameAlongside:
+		aEntry.SetField("remote_host", name)
+	case HostnameInstead:
+		aEntry.Remote = name
+	}
+} // addHostname()
+
+// `goLookupHostname()` resolves `aIP` and stores the result in the
+// cache.
+//
+// Parameters:
+// - `aIP`: The IP address to resolve.
+func goLookupHostname(aIP string) {
+	alHostnameSem <- struct{}{}
+	defer func() {
+		<-alHostnameSem
+	}()
+
+	ctx, cancel := context.WithTimeout(context.Background(), alHostnameTimeout)
+	names, err := alLookupAddr(ctx, aIP)
+	cancel()
+
+	result := &tHostname{expires: time.Now().Add(alHostnameNegativeTTL)}
+	if (nil == err) && (0 < len(names)) {
+		result.name = strings.TrimSuffix(names[0], ".")
+		result.expires = time.Now().Add(HostnameTTL)
+	}
+
+	alHostnamesMtx.Lock()
+	alHostnames[aIP] = result
+	alHostnamesMtx.Unlock()
+} // goLookupHostname()
+
+// `lookupHostname()` returns the cached hostname of `aIP`.
+//
+// If there's no valid cache entry for `aIP` a lookup is started in
+// background; the function itself never blocks.
+//
+// Parameters:
+// - `aIP`: The IP address to look up.
+//
+// Returns:
+// - `string`: The hostname of `aIP`.
+// - `bool`: `true` if a hostname is known.
+func lookupHostname(aIP string) (string, bool) {
+	if nil == net.ParseIP(aIP) {
+		return "", false
+	}
+	now := time.Now()
+
+	alHostnamesMtx.Lock()
+	defer alHostnamesMtx.Unlock()
+
+	if hn, ok := alHostnames[aIP]; ok {
+		if hn.pending || now.Before(hn.expires) {
+			return hn.name, ("" != hn.name)
+		}
+	}
+
+	if alHostnameCacheMax <= len(alHostnames) {
+		pruneHostnames(now)
+		if alHostnameCacheMax <= len(alHostnames) {
+			return "", false // cache full of valid entries
+		}
+	}
+	alHostnames[aIP] = &tHostname{pending: true}
+	go goLookupHostname(aIP)
+
+	return "", false
+} // lookupHostname()
+
+// `pruneHostnames()` removes expired entries from the cache; if there
+// are none, the entries expiring soonest are removed.
+//
+// The caller must hold the lock of `alHostnamesMtx`.
+//
+// Parameters:
+// - `aNow`: The current time.
+func pruneHostnames(aNow time.Time) {
+	var (
+		oldestIP   string
+		oldestTime time.Time
+	)
+	for ip, hn := range alHostnames {
+		if hn.pending {
+			continue
+		}
+		if aNow.After(hn.expires) {
+			delete(alHostnames, ip)
+			continue
+		}
+		if ("" == oldestIP) || hn.expires.Before(oldestTime) {
+			oldestIP, oldestTime = ip, hn.expires
+		}
+	}
+	if (alHostnameCacheMax <= len(alHostnames)) && ("" != oldestIP) {
+		delete(alHostnames, oldestIP)
+	}
+} // pruneHostnames()
+
+/* _EoF_ */
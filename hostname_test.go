@@ -0,0 +1,89 @@
+/*
+Copyright © 2024  M.Watermann, 10247 Berlin, Germany
+
+	    All rights reserved
+	EMail : <support@mwat.de>
+*/
+package apachelogger
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+//lint:file-ignore ST1017 – I prefer Yoda conditions
+
+func Test_lookupHostname(t *testing.T) {
+	lookup := alLookupAddr
+	t.Cleanup(func() {
+		// wait for the lookups started and forget their results
+		for i := 0; i < 100; i++ {
+			alHostnamesMtx.Lock()
+			pending := false
+			for _, hn := range alHostnames {
+				pending = pending || hn.pending
+			}
+			if !pending {
+				alHostnames = make(map[string]*tHostname)
+			}
+			alHostnamesMtx.Unlock()
+			if !pending {
+				break
+			}
+			time.Sleep(time.Millisecond)
+		}
+		alLookupAddr = lookup
+		HostnameLookups = HostnameOff
+	})
+	alLookupAddr = func(aCtx context.Context, aIP string) ([]string, error) {
+		if "192.168.1.234" == aIP {
+			return []string{"host.example.com."}, nil
+		}
+		return nil, errors.New("not found")
+	}
+
+	if _, ok := lookupHostname("not an address"); ok {
+		t.Error("lookupHostname() returned a name for a non-address")
+	}
+	// The first call starts the lookup in background:
+	if _, ok := lookupHostname("192.168.1.234"); ok {
+		t.Error("lookupHostname() returned a name before the lookup")
+	}
+	_, _ = lookupHostname("192.168.1.235")
+
+	var (
+		name string
+		ok   bool
+	)
+	for i := 0; i < 100; i++ {
+		if name, ok = lookupHostname("192.168.1.234"); ok {
+			break
+		}
+		time.Sleep(time.Millisecond)
+	}
+	if w := "host.example.com"; w != name {
+		t.Errorf("lookupHostname() = %q, want %q", name, w)
+	}
+
+	HostnameLookups = HostnameAlongside
+	e1 := prepEntry()
+	addHostname(e1, "192.168.1.234:1234")
+	if w := "host.example.com"; w != e1.Fields["remote_host"] {
+		t.Errorf("addHostname() remote_host = %q, want %q", e1.Fields["remote_host"], w)
+	}
+	HostnameLookups = HostnameInstead
+	e2 := prepEntry()
+	addHostname(e2, "192.168.1.234")
+	if w := "host.example.com"; w != e2.Remote {
+		t.Errorf("addHostname() Remote = %q, want %q", e2.Remote, w)
+	}
+	e3 := prepEntry()
+	addHostname(e3, "192.168.1.235")
+	if w := "192.168.1.0"; w != e3.Remote {
+		t.Errorf("addHostname() Remote = %q, want %q", e3.Remote, w)
+	}
+} // Test_lookupHostname()
+
+/* _EoF_ */
@@ -0,0 +1,125 @@
+/*
+Copyright © 2024  M.Watermann, 10247 Berlin, Germany
+
+	    All rights reserved
+	EMail : <support@mwat.de>
+*/
+package apachelogger
+
+import (
+	"net"
+	"net/http"
+	"strings"
+)
+
+//lint:file-ignore ST1017 – I prefer Yoda conditions
+
+var (
+	// `LogServerHostPort` decides whether to add the requested host
+	// (`server_host`, like Apache's `%v`) and the port of the listener
+	// which accepted the connection (`server_port`, like `%p`) as
+	// fields to the access log entries (default: `false`).
+	LogServerHostPort = false
+
+	// `LogClientPort` decides whether to add the client's port (taken
+	// before the remote address gets anonymised) as the field
+	// `client_port` (like Apache's `%{remote}p`) to the access log
+	// entries (default: `false`).
+	//
+	// Requests coming through a proxy (see `X-Forwarded-For`) don't
+	// get the field since the proxy's port is of no use.
+	LogClientPort = false
+)
+
+// `splitPort()` returns the port of `aAddress`.
+//
+// Parameters:
+// - `aAddress`: The address as `host:port`.
+//
+// Returns:
+// - `string`: The address' port (empty if none).
+func splitPort(aAddress string) string {
+	_, port, err := net.SplitHostPort(aAddress)
+	if nil != err {
+		return ""
+	}
+
+	return port
+} // splitPort()
+
+// `serverPort()` returns the port of the listener which accepted
+// `aRequest`.
+//
+// If the local address isn't available the port of the requested
+// host (or the scheme's default port) is returned.
+//
+// Parameters:
+// - `aRequest`: The HTTP request received by the server.
+//
+// Returns:
+// - `string`: The server's port.
+func serverPort(aRequest *http.Request) string {
+	if addr, ok := aRequest.Context().Value(http.LocalAddrContextKey).(net.Addr); ok {
+		if port := splitPort(addr.String()); "" != port {
+			return port
+		}
+	}
+	if port := splitPort(aRequest.Host); "" != port {
+		return port
+	}
+	if nil != aRequest.TLS {
+		return "443"
+	}
+
+	return "80"
+} // serverPort()
+
+// `serverHost()` returns the host name requested by `aRequest`.
+//
+// Parameters:
+// - `aRequest`: The HTTP request received by the server.
+//
+// Returns:
+// - `string`: The requested host (without port).
+func serverHost(aRequest *http.Request) string {
+	host := aRequest.Host
+	if h, _, err := net.SplitHostPort(host); nil == err {
+		host = h
+	}
+
+	return sanitiseString(strings.ToLower(strings.Trim(host, "[]")))
+} // serverHost()
+
+// `addHostPort()` adds the server's host and port as well as the
+// client's port as fields to `aEntry`.
+//
+// The fields are added if enabled by `LogServerHostPort` and
+// `LogClientPort` or needed by the entry's log format.
+//
+// Parameters:
+// - `aEntry`: The log entry to complete.
+// - `aRequest`: The HTTP request received by the server.
+func addHostPort(aEntry *TEntry, aRequest *http.Request) {
+	server, client := LogServerHostPort, LogClientPort
+	lf := aEntry.settings.logFormat()
+	if nil == lf {
+		lf = logFormat()
+	}
+	if nil != lf {
+		server = server || lf.serverHostPort
+		client = client || lf.clientPort
+	}
+	if server {
+		if host := serverHost(aRequest); "" != host {
+			aEntry.SetField("server_host", host)
+		}
+		aEntry.SetField("server_port", serverPort(aRequest))
+	}
+	if client {
+		if port := splitPort(getRemoteAddr(aRequest)); "" != port {
+			aEntry.SetField("client_port", port)
+		}
+	}
+} // addHostPort()
+
+/* _EoF_ */
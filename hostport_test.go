@@ -0,0 +1,81 @@
+/*
+Copyright © 2024  M.Watermann, 10247 Berlin, Germany
+
+	    All rights reserved
+	EMail : <support@mwat.de>
+*/
+package apachelogger
+
+import (
+	"context"
+	"crypto/tls"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+//lint:file-ignore ST1017 – I prefer Yoda conditions
+
+func Test_serverPort(t *testing.T) {
+	req := httptest.NewRequest(http.MethodGet, "http://example.com/", nil)
+	if got := serverPort(req); "80" != got {
+		t.Errorf("serverPort() = %q, want %q", got, "80")
+	}
+	req.TLS = &tls.ConnectionState{}
+	if got := serverPort(req); "443" != got {
+		t.Errorf("serverPort(TLS) = %q, want %q", got, "443")
+	}
+	req.Host = "example.com:8443"
+	if got := serverPort(req); "8443" != got {
+		t.Errorf("serverPort(Host) = %q, want %q", got, "8443")
+	}
+	local := &net.TCPAddr{IP: net.IPv4(127, 0, 0, 1), Port: 9090}
+	req = req.WithContext(context.WithValue(req.Context(), http.LocalAddrContextKey, local))
+	if got := serverPort(req); "9090" != got {
+		t.Errorf("serverPort(local) = %q, want %q", got, "9090")
+	}
+} // Test_serverPort()
+
+func Test_addHostPort(t *testing.T) {
+	defer func() {
+		LogServerHostPort, LogClientPort = false, false
+		_ = SetLogFormat("")
+	}()
+	req := httptest.NewRequest(http.MethodGet, "http://WWW.Example.com:8080/", nil)
+	req.RemoteAddr = "192.0.2.7:51234"
+
+	e := prepEntry()
+	addHostPort(e, req)
+	if 0 != len(e.Fields) {
+		t.Errorf("addHostPort() fields = %v, want none", e.Fields)
+	}
+
+	LogServerHostPort, LogClientPort = true, true
+	addHostPort(e, req)
+	if ("www.example.com" != e.Fields["server_host"]) ||
+		("8080" != e.Fields["server_port"]) || ("51234" != e.Fields["client_port"]) {
+		t.Errorf("addHostPort() fields = %v", e.Fields)
+	}
+
+	// the proxy's port isn't the client's:
+	req.Header.Set("X-Forwarded-For", "198.51.100.1")
+	e = prepEntry()
+	addHostPort(e, req)
+	if _, ok := e.Fields["client_port"]; ok {
+		t.Errorf("addHostPort() fields = %v, want no client_port", e.Fields)
+	}
+
+	LogServerHostPort, LogClientPort = false, false
+	if err := SetLogFormat("%v %p %{remote}p"); nil != err {
+		t.Fatal(err)
+	}
+	req.Header.Del("X-Forwarded-For")
+	e = prepEntry()
+	addHostPort(e, req)
+	if got, want := formatEntry(e), "www.example.com 8080 51234\n"; want != got {
+		t.Errorf("formatEntry() = %q, want %q", got, want)
+	}
+} // Test_addHostPort()
+
+/* _EoF_ */
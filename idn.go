@@ -0,0 +1,332 @@
+/*
+Copyright © 2024  M.Watermann, 10247 Berlin, Germany
+
+	    All rights reserved
+	EMail : <support@mwat.de>
+*/
+package apachelogger
+
+import (
+	"errors"
+	"strings"
+	"unicode/utf8"
+)
+
+//lint:file-ignore ST1017 – I prefer Yoda conditions
+
+const (
+	// `IDNKeep` writes host names unchanged.
+	IDNKeep = iota
+
+	// `IDNASCII` writes international host names in their ASCII
+	// (punycode) form, e.g. `xn--mnchen-3ya.de`.
+	IDNASCII
+
+	// `IDNUnicode` writes international host names in their Unicode
+	// form, e.g. `münchen.de`.
+	IDNUnicode
+)
+
+// Parameters of the punycode algorithm (RFC 3492).
+const (
+	alPunyBase        = 36
+	alPunyTMin        = 1
+	alPunyTMax        = 26
+	alPunySkew        = 38
+	alPunyDamp        = 700
+	alPunyInitialBias = 72
+	alPunyInitialN    = 128
+	alPunyPrefix      = "xn--"
+)
+
+var (
+	// `IDNMode` decides how to write the host names in the `Referer`
+	// field and the `host` and `remote_host` fields (default: `IDNKeep`),
+	// so analytics can group international domains consistently.
+	IDNMode = IDNKeep
+
+	// Error returned for malformed punycode.
+	errPunycode = errors.New("apachelogger: invalid punycode")
+)
+
+// `punyAdapt()` returns the new bias (RFC 3492, section 6.1).
+//
+// Parameters:
+// - `aDelta`: The current delta.
+// - `aNumPoints`: The number of code points handled so far.
+// - `aFirst`: Whether this is the first adaption.
+//
+// Returns:
+// - `int`: The new bias.
+func punyAdapt(aDelta, aNumPoints int, aFirst bool) int {
+	if aFirst {
+		aDelta /= alPunyDamp
+	} else {
+		aDelta /= 2
+	}
+	aDelta += aDelta / aNumPoints
+	k := 0
+	for ((alPunyBase - alPunyTMin) * alPunyTMax / 2) < aDelta {
+		aDelta /= alPunyBase - alPunyTMin
+		k += alPunyBase
+	}
+
+	return k + (alPunyBase-alPunyTMin+1)*aDelta/(aDelta+alPunySkew)
+} // punyAdapt()
+
+// `punyThreshold()` returns the threshold `t` for position `aK`.
+//
+// Parameters:
+// - `aK`: The current position (a multiple of the base).
+// - `aBias`: The current bias.
+//
+// Returns:
+// - `int`: The threshold.
+func punyThreshold(aK, aBias int) int {
+	switch {
+	case aK <= aBias:
+		return alPunyTMin
+	case aK >= aBias+alPunyTMax:
+		return alPunyTMax
+	}
+
+	return aK - aBias
+} // punyThreshold()
+
+// `punyDigit()` returns the basic code point of the digit `aDigit`.
+//
+// Parameters:
+// - `aDigit`: The digit (`0` to `35`).
+//
+// Returns:
+// - `byte`: The digit's character.
+func punyDigit(aDigit int) byte {
+	if 26 > aDigit {
+		return byte('a' + aDigit)
+	}
+
+	return byte('0' + aDigit - 26)
+} // punyDigit()
+
+// `punycodeDecode()` decodes a punycode label (without `xn--` prefix).
+//
+// Parameters:
+// - `aLabel`: The punycode to decode.
+//
+// Returns:
+// - `string`: The decoded Unicode label.
+// - `error`: A possible error for malformed punycode.
+func punycodeDecode(aLabel string) (string, error) {
+	var output []rune
+
+	pos := 0
+	if idx := strings.LastIndexByte(aLabel, '-'); 0 <= idx {
+		for _, r := range aLabel[:idx] {
+			if 0x80 <= r {
+				return "", errPunycode
+			}
+			output = append(output, r)
+		}
+		pos = idx + 1
+	}
+
+	n, i, bias := alPunyInitialN, 0, alPunyInitialBias
+	for pos < len(aLabel) {
+		oldI, w := i, 1
+		for k := alPunyBase; ; k += alPunyBase {
+			if pos >= len(aLabel) {
+				return "", errPunycode
+			}
+			var digit int
+			switch c := aLabel[pos]; {
+			case ('0' <= c) && ('9' >= c):
+				digit = int(c-'0') + 26
+			case ('a' <= c) && ('z' >= c):
+				digit = int(c - 'a')
+			case ('A' <= c) && ('Z' >= c):
+				digit = int(c - 'A')
+			default:
+				return "", errPunycode
+			}
+			pos++
+			if digit > (utf8.MaxRune-i)/w {
+				return "", errPunycode // overflow
+			}
+			i += digit * w
+			t := punyThreshold(k, bias)
+			if digit < t {
+				break
+			}
+			w *= alPunyBase - t
+		}
+		bias = punyAdapt(i-oldI, len(output)+1, 0 == oldI)
+		n += i / (len(output) + 1)
+		i %= len(output) + 1
+		if (utf8.MaxRune < n) || !utf8.ValidRune(rune(n)) {
+			return "", errPunycode
+		}
+		output = append(output, 0)
+		copy(output[i+1:], output[i:])
+		output[i] = rune(n)
+		i++
+	}
+
+	return string(output), nil
+} // punycodeDecode()
+
+// `punycodeEncode()` encodes a Unicode label as punycode (without
+// `xn--` prefix).
+//
+// Parameters:
+// - `aLabel`: The label to encode.
+//
+// Returns:
+// - `string`: The punycode.
+func punycodeEncode(aLabel string) string {
+	input := []rune(aLabel)
+	output := make([]byte, 0, len(aLabel)+8)
+	for _, r := range input {
+		if 0x80 > r {
+			output = append(output, byte(r))
+		}
+	}
+	b := len(output)
+	h := b
+	if 0 < b {
+		output = append(output, '-')
+	}
+
+	n, delta, bias := alPunyInitialN, 0, alPunyInitialBias
+	for h < len(input) {
+		m := int(utf8.MaxRune) + 1
+		for _, r := range input {
+			if (int(r) >= n) && (int(r) < m) {
+				m = int(r)
+			}
+		}
+		delta += (m - n) * (h + 1)
+		n = m
+		for _, r := range input {
+			if int(r) < n {
+				delta++
+				continue
+			}
+			if int(r) > n {
+				continue
+			}
+			q := delta
+			for k := alPunyBase; ; k += alPunyBase {
+				t := punyThreshold(k, bias)
+				if q < t {
+					break
+				}
+				output = append(output, punyDigit(t+(q-t)%(alPunyBase-t)))
+				q = (q - t) / (alPunyBase - t)
+			}
+			output = append(output, punyDigit(q))
+			bias = punyAdapt(delta, h+1, h == b)
+			delta = 0
+			h++
+		}
+		delta++
+		n++
+	}
+
+	return string(output)
+} // punycodeEncode()
+
+/* * * * * * * * * * * * * * * * * * * * * * * * * * * * * * * * * * * * * */
+
+// `normaliseHost()` returns the host name `aHost` in the form selected
+// by `IDNMode`.
+//
+// Labels are converted individually; labels that can't be converted
+// are kept unchanged. No further normalisation (like Unicode NFKC) is
+// done apart from lower-casing.
+//
+// Parameters:
+// - `aHost`: The host name to convert.
+//
+// Returns:
+// - `string`: The converted host name.
+func normaliseHost(aHost string) string {
+	if (IDNKeep == IDNMode) || ("" == aHost) {
+		return aHost
+	}
+	labels := strings.Split(aHost, ".")
+	for idx, label := range labels {
+		switch IDNMode {
+		case IDNASCII:
+			for _, r := range label {
+				if 0x80 <= r {
+					labels[idx] = alPunyPrefix + punycodeEncode(strings.ToLower(label))
+					break
+				}
+			}
+
+		case IDNUnicode:
+			if (len(alPunyPrefix) < len(label)) &&
+				strings.EqualFold(label[:len(alPunyPrefix)], alPunyPrefix) {
+				if decoded, err := punycodeDecode(label[len(alPunyPrefix):]); nil == err {
+					labels[idx] = strings.ToLower(decoded)
+				}
+			}
+		}
+	}
+
+	return strings.Join(labels, ".")
+} // normaliseHost()
+
+// `normaliseURLHost()` returns the URL `aURL` with its host name in the
+// form selected by `IDNMode`; the rest of the URL is kept unchanged.
+//
+// Parameters:
+// - `aURL`: The URL to convert.
+//
+// Returns:
+// - `string`: The converted URL.
+func normaliseURLHost(aURL string) string {
+	if IDNKeep == IDNMode {
+		return aURL
+	}
+	idx := strings.Index(aURL, "://")
+	if 0 > idx {
+		return aURL
+	}
+	start := idx + 3
+	end := len(aURL)
+	if idx = strings.IndexAny(aURL[start:], "/?#"); 0 <= idx {
+		end = start + idx
+	}
+	if idx = strings.LastIndexByte(aURL[start:end], '@'); 0 <= idx {
+		start += idx + 1 // skip the user info
+	}
+	if strings.HasPrefix(aURL[start:end], "[") {
+		return aURL // IPv6 address
+	}
+	hostEnd := end
+	if idx = strings.LastIndexByte(aURL[start:end], ':'); 0 <= idx {
+		hostEnd = start + idx // skip the port
+	}
+
+	return aURL[:start] + normaliseHost(aURL[start:hostEnd]) + aURL[hostEnd:]
+} // normaliseURLHost()
+
+// `normaliseHosts()` converts the host names of `aEntry` according to
+// `IDNMode`.
+//
+// Parameters:
+// - `aEntry`: The log entry to modify.
+func normaliseHosts(aEntry *TEntry) {
+	if IDNKeep == IDNMode {
+		return
+	}
+	aEntry.Referrer = normaliseURLHost(aEntry.Referrer)
+	for _, key := range []string{"host", "remote_host", "server_host"} {
+		if host, ok := aEntry.Fields[key]; ok {
+			aEntry.Fields[key] = normaliseHost(host)
+		}
+	}
+} // normaliseHosts()
+
+/* _EoF_ */
@@ -0,0 +1,88 @@
+/*
+Copyright © 2024  M.Watermann, 10247 Berlin, Germany
+
+	    All rights reserved
+	EMail : <support@mwat.de>
+*/
+package apachelogger
+
+import (
+	"testing"
+)
+
+//lint:file-ignore ST1017 – I prefer Yoda conditions
+
+func Test_punycode(t *testing.T) {
+	tests := []struct {
+		name    string
+		unicode string
+		puny    string
+	}{
+		{"1", "bücher", "bcher-kva"},
+		{"2", "münchen", "mnchen-3ya"},
+		{"3", "中文", "fiq228c"},
+		// RFC 3492, section 7.1, sample (A):
+		{"4", "ليهمابتكلموشعربي؟",
+			"egbpdaj6bu4bxfgehfvwxn"},
+		// RFC 3492, section 7.1, sample (L):
+		{"5", "3年B組金八先生", "3B-ww4c5e180e575a65lsy2b"},
+		{"6", "abc", "abc-"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := punycodeEncode(tt.unicode); got != tt.puny {
+				t.Errorf("punycodeEncode() = %q, want %q", got, tt.puny)
+			}
+			got, err := punycodeDecode(tt.puny)
+			if nil != err {
+				t.Fatalf("punycodeDecode() error = %v", err)
+			}
+			if got != tt.unicode {
+				t.Errorf("punycodeDecode() = %q, want %q", got, tt.unicode)
+			}
+		})
+	}
+	for _, bad := range []string{"a-!", "zzzzzzzzzzzzzzzzzz", "ü-abc", "a-9"} {
+		if _, err := punycodeDecode(bad); nil == err {
+			t.Errorf("punycodeDecode(%q) expected an error", bad)
+		}
+	}
+} // Test_punycode()
+
+func Test_normaliseURLHost(t *testing.T) {
+	defer func() { IDNMode = IDNKeep }()
+
+	tests := []struct {
+		name string
+		mode int
+		url  string
+		want string
+	}{
+		{"1", IDNKeep, "https://münchen.de/", "https://münchen.de/"},
+		{"2", IDNASCII, "https://München.de/straße?q=ü", "https://xn--mnchen-3ya.de/straße?q=ü"},
+		{"3", IDNASCII, "http://user@bücher.example:8080", "http://user@xn--bcher-kva.example:8080"},
+		{"4", IDNUnicode, "https://XN--MNCHEN-3YA.de/x", "https://münchen.de/x"},
+		{"5", IDNUnicode, "https://xn--invalid-!.de/", "https://xn--invalid-!.de/"},
+		{"6", IDNUnicode, "-", "-"},
+		{"7", IDNASCII, "http://[::1]:80/", "http://[::1]:80/"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			IDNMode = tt.mode
+			if got := normaliseURLHost(tt.url); got != tt.want {
+				t.Errorf("normaliseURLHost() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+
+	IDNMode = IDNUnicode
+	e := prepEntry()
+	e.Referrer = "https://xn--bcher-kva.example/"
+	e.SetField("remote_host", "xn--mnchen-3ya.de")
+	normaliseHosts(e)
+	if ("https://bücher.example/" != e.Referrer) || ("münchen.de" != e.Fields["remote_host"]) {
+		t.Errorf("normaliseHosts() = %q, %q", e.Referrer, e.Fields["remote_host"])
+	}
+} // Test_normaliseURLHost()
+
+/* _EoF_ */
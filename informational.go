@@ -0,0 +1,71 @@
+/*
+Copyright © 2024  M.Watermann, 10247 Berlin, Germany
+
+	    All rights reserved
+	EMail : <support@mwat.de>
+*/
+package apachelogger
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+//lint:file-ignore ST1017 – I prefer Yoda conditions
+
+var (
+	// `LogExpectContinue` decides whether to add the field
+	// `expect=100-continue` to the access log entries of requests
+	// asking for a `100 Continue` response before sending their body
+	// (default: `false`).
+	LogExpectContinue = false
+)
+
+// `isInformational()` reports whether `aStatus` is an informational
+// (`1xx`) status code sent ahead of the final response.
+//
+// `101 Switching Protocols` is the final response of an upgraded
+// connection, hence it's not considered informational.
+//
+// Parameters:
+// - `aStatus`: The HTTP status code to check.
+//
+// Returns:
+// - `bool`: `true` if `aStatus` doesn't end the response header.
+func isInformational(aStatus int) bool {
+	return (100 <= aStatus) && (200 > aStatus) &&
+		(http.StatusSwitchingProtocols != aStatus)
+} // isInformational()
+
+// `addInformational()` adds the informational responses sent (e.g.
+// `103 Early Hints`) and the use of `Expect: 100-continue` as fields
+// to `aEntry`.
+//
+// Parameters:
+// - `aEntry`: The log entry to complete.
+// - `aSent`: The informational status codes sent (space separated).
+// - `aHeader`: The request's header.
+func addInformational(aEntry *TEntry, aSent string, aHeader http.Header) {
+	if "" != aSent {
+		aEntry.SetField("informational", aSent)
+	}
+	if LogExpectContinue &&
+		strings.EqualFold("100-continue", aHeader.Get("Expect")) {
+		aEntry.SetField("expect", "100-continue")
+	}
+} // addInformational()
+
+// `sentInformational()` records the informational status `aStatus`
+// sent by the handler.
+//
+// Parameters:
+// - `aStatus`: The informational status code sent.
+func (lw *tLogWriter) sentInformational(aStatus int) {
+	if "" != lw.informational {
+		lw.informational += " "
+	}
+	lw.informational += strconv.Itoa(aStatus)
+} // sentInformational()
+
+/* _EoF_ */
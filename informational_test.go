@@ -0,0 +1,54 @@
+/*
+Copyright © 2024  M.Watermann, 10247 Berlin, Germany
+
+	    All rights reserved
+	EMail : <support@mwat.de>
+*/
+package apachelogger
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+//lint:file-ignore ST1017 – I prefer Yoda conditions
+
+func Test_tLogWriter_WriteHeader(t *testing.T) {
+	lw := &tLogWriter{ResponseWriter: httptest.NewRecorder()}
+	lw.WriteHeader(http.StatusEarlyHints)
+	lw.WriteHeader(http.StatusEarlyHints)
+	if 0 != lw.status {
+		t.Errorf("WriteHeader(103) status = %d, want 0", lw.status)
+	}
+	lw.WriteHeader(http.StatusNotFound)
+	if (http.StatusNotFound != lw.status) || ("103 103" != lw.informational) {
+		t.Errorf("WriteHeader() status = %d, informational = %q",
+			lw.status, lw.informational)
+	}
+
+	lw = &tLogWriter{ResponseWriter: httptest.NewRecorder()}
+	lw.WriteHeader(http.StatusSwitchingProtocols)
+	if http.StatusSwitchingProtocols != lw.status {
+		t.Errorf("WriteHeader(101) status = %d, want 101", lw.status)
+	}
+} // Test_tLogWriter_WriteHeader()
+
+func Test_addInformational(t *testing.T) {
+	defer func() { LogExpectContinue = false }()
+	header := http.Header{"Expect": {"100-Continue"}}
+
+	e := prepEntry()
+	addInformational(e, "", header)
+	if 0 != len(e.Fields) {
+		t.Errorf("addInformational() fields = %v, want none", e.Fields)
+	}
+
+	LogExpectContinue = true
+	addInformational(e, "103", header)
+	if ("103" != e.Fields["informational"]) || ("100-continue" != e.Fields["expect"]) {
+		t.Errorf("addInformational() fields = %v", e.Fields)
+	}
+} // Test_addInformational()
+
+/* _EoF_ */
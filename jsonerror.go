@@ -0,0 +1,184 @@
+/*
+Copyright © 2024  M.Watermann, 10247 Berlin, Germany
+
+	    All rights reserved
+	EMail : <support@mwat.de>
+*/
+package apachelogger
+
+import (
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+	"time"
+)
+
+//lint:file-ignore ST1017 – I prefer Yoda conditions
+
+type (
+	// `tFrame` is a single stack frame of a panic record.
+	tFrame struct {
+		Function string `json:"function"` // the function's qualified name
+		File     string `json:"file"`     // the source file's path
+		Line     int    `json:"line"`     // the line in the source file
+	}
+
+	// `tErrorOrigin` is the origin of an error log entry.
+	tErrorOrigin struct {
+		caller string   // `file.go:line` of the caller of `Err()`
+		panic  string   // the value passed to `panic()` (if any)
+		frames []tFrame // the panicking goroutine's stack (if any)
+	}
+
+	// `tErrorRecord` is an error log entry as written by `JSONErrorLog`.
+	tErrorRecord struct {
+		Level     string            `json:"level"`
+		Timestamp string            `json:"timestamp"`
+		Sender    string            `json:"sender"`
+		Message   string            `json:"message"`
+		Caller    string            `json:"caller,omitempty"`
+		Panic     string            `json:"panic,omitempty"`
+		Frames    []tFrame          `json:"frames,omitempty"`
+		Fields    map[string]string `json:"fields,omitempty"`
+	}
+)
+
+var (
+	// `JSONErrorLog` decides whether to write the error log entries
+	// (see `Err()`, `Warn()`, and the panics caught by `Wrap()`) as
+	// JSON lines instead of Apache-like lines (default: `false`), e.g.
+	//
+	//	{"level":"error","timestamp":"…","sender":"…","message":"…","caller":"main.go:42"}
+	//
+	// Panics additionally get the `panic` value and the stack as
+	// `frames` array (of `function`, `file`, and `line`), so the
+	// error log can be ingested by the same pipeline as the access log.
+	JSONErrorLog = false
+)
+
+// `callerName()` returns the source position of a caller.
+//
+// Parameters:
+// - `aSkip`: The number of stack frames to skip (`1` for the caller
+// of `callerName()`).
+//
+// Returns:
+// - `string`: The caller's `file.go:line` (empty if unknown or
+// `JSONErrorLog` is disabled).
+func callerName(aSkip int) string {
+	if !JSONErrorLog {
+		return ""
+	}
+	_, file, line, ok := runtime.Caller(aSkip)
+	if !ok {
+		return ""
+	}
+
+	return filepath.Base(file) + ":" + strconv.Itoa(line)
+} // callerName()
+
+// `panicFrames()` returns the stack of the panicking goroutine,
+// starting at the function that panicked.
+//
+// It must be called by the deferred function that recovered the panic.
+//
+// Returns:
+// - `[]tFrame`: The stack frames (`nil` if `JSONErrorLog` is disabled).
+func panicFrames() []tFrame {
+	if !JSONErrorLog {
+		return nil
+	}
+	pcs := make([]uintptr, 64)
+	pcs = pcs[:runtime.Callers(2, pcs)]
+
+	var (
+		result   []tFrame
+		panicked = false
+		frames   = runtime.CallersFrames(pcs)
+	)
+	for {
+		frame, more := frames.Next()
+		if panicked {
+			result = append(result, tFrame{frame.Function, frame.File, frame.Line})
+		} else {
+			panicked = "runtime.gopanic" == frame.Function
+		}
+		if !more {
+			break
+		}
+	}
+	if !panicked { // not called while panicking
+		return nil
+	}
+
+	return result
+} // panicFrames()
+
+// `panicEntry()` returns the error log entry of a caught panic.
+//
+// Parameters:
+// - `aValue`: The value passed to `panic()`.
+// - `aStack`: The text of the panicking goroutine's stack.
+// - `aFrames`: The panicking goroutine's stack frames.
+// - `aReport`: The name of the crash report (if any).
+// - `aTime`: The time to log.
+//
+// Returns:
+// - `*TEntry`: The log entry.
+func panicEntry(aValue interface{}, aStack []byte, aFrames []tFrame, aReport string, aTime time.Time) *TEntry {
+	msg := fmt.Sprintf("caught panic: %v", aValue)
+	if !JSONErrorLog { // the frames replace the stack's text
+		msg += " - " + string(aStack)
+	}
+	if "" != aReport {
+		msg += " - crash report: " + aReport
+	}
+	entry := customEntry("ApacheLogger/catchPanic", msg, `ERR`, aTime)
+	if JSONErrorLog {
+		entry.origin = &tErrorOrigin{
+			panic:  fmt.Sprint(aValue),
+			frames: aFrames,
+		}
+		if 0 < len(aFrames) {
+			entry.origin.caller = filepath.Base(aFrames[0].File) + ":" +
+				strconv.Itoa(aFrames[0].Line)
+		}
+	}
+
+	return entry
+} // panicEntry()
+
+// `errorJSON()` returns the error log entry as a JSON line.
+//
+// Returns:
+// - `string`: The JSON record (including the trailing newline).
+func (e *TEntry) errorJSON() string {
+	level := "error"
+	if `WARN` == e.Method {
+		level = "warn"
+	}
+	record := tErrorRecord{
+		Level:     level,
+		Timestamp: e.inLocation(e.When).Format(time.RFC3339Nano),
+		Sender:    e.Referrer,
+		Message:   e.Path,
+		Fields:    e.Fields,
+	}
+	if nil != e.origin {
+		record.Caller, record.Panic, record.Frames = e.origin.caller,
+			e.origin.panic, e.origin.frames
+	}
+	var sb strings.Builder
+	enc := json.NewEncoder(&sb)
+	enc.SetEscapeHTML(false)
+	if err := enc.Encode(record); nil != err {
+		return e.String()
+	}
+
+	return sb.String() // `Encode()` appended the newline
+} // errorJSON()
+
+/* _EoF_ */
@@ -0,0 +1,99 @@
+/*
+Copyright © 2024  M.Watermann, 10247 Berlin, Germany
+
+	    All rights reserved
+	EMail : <support@mwat.de>
+*/
+package apachelogger
+
+import (
+	"encoding/json"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+//lint:file-ignore ST1017 – I prefer Yoda conditions
+
+func panicking() {
+	panic("boom")
+} // panicking()
+
+func Test_panicFrames(t *testing.T) {
+	if nil != panicFrames() {
+		t.Error("panicFrames() != nil with JSONErrorLog disabled")
+	}
+	JSONErrorLog = true
+	defer func() { JSONErrorLog = false }()
+	if nil != panicFrames() {
+		t.Error("panicFrames() != nil without a panic")
+	}
+
+	var frames []tFrame
+	func() {
+		defer func() {
+			_ = recover()
+			frames = panicFrames()
+		}()
+		panicking()
+	}()
+	if (0 == len(frames)) || !strings.HasSuffix(frames[0].Function, ".panicking") ||
+		!strings.HasSuffix(frames[0].File, "jsonerror_test.go") {
+		t.Errorf("panicFrames() = %+v, want to start at panicking()", frames)
+	}
+} // Test_panicFrames()
+
+func TestJSONErrorLog(t *testing.T) {
+	JSONErrorLog = true
+	defer func() { JSONErrorLog = false }()
+	now := time.Date(2024, 4, 25, 20, 16, 45, 0, time.UTC)
+
+	frames := []tFrame{{"main.handler", "/src/app/main.go", 42}}
+	entry := panicEntry("boom", []byte("goroutine 1"), frames, "crash.txt", now)
+	var record map[string]interface{}
+	if err := json.Unmarshal([]byte(formatEntry(entry)), &record); nil != err {
+		t.Fatalf("formatEntry() isn't JSON: %v", err)
+	}
+	want := map[string]interface{}{
+		"level":     "error",
+		"timestamp": "2024-04-25T20:16:45Z",
+		"sender":    "ApacheLogger/catchPanic",
+		"message":   "caught panic: boom - crash report: crash.txt",
+		"caller":    "main.go:42",
+		"panic":     "boom",
+	}
+	for key, value := range want {
+		if value != record[key] {
+			t.Errorf("record[%q] = %v, want %v", key, record[key], value)
+		}
+	}
+	if got, ok := record["frames"].([]interface{}); !ok || (1 != len(got)) {
+		t.Errorf("record[frames] = %v, want one frame", record["frames"])
+	}
+
+	entry = customEntry("test", "info", `LOG`, now)
+	if got := formatEntry(entry); strings.HasPrefix(got, "{") {
+		t.Errorf("formatEntry(LOG) = %q, want an Apache-like line", got)
+	}
+	if got := callerName(1); !strings.HasPrefix(got, "jsonerror_test.go:") {
+		t.Errorf("callerName() = %q", got)
+	}
+} // TestJSONErrorLog()
+
+func TestJSONErrorLog_forgedMethod(t *testing.T) {
+	JSONErrorLog = true
+	defer func() { JSONErrorLog = false }()
+
+	req := httptest.NewRequest(`ERR`, "/forged", nil)
+	lw := &tLogWriter{status: 200, when: time.Now()}
+	entry, _ := webEntry(lw, req)
+	if got := formatEntry(entry); strings.HasPrefix(got, "{") {
+		t.Errorf("formatEntry(request ERR) = %q, want an access log line", got)
+	}
+	if !routeEntry(entry, errorQueue()) {
+		t.Error("routeEntry(request ERR) = false, want true")
+	}
+} // TestJSONErrorLog_forgedMethod()
+
+/* _EoF_ */
@@ -0,0 +1,71 @@
+/*
+Copyright © 2024  M.Watermann, 10247 Berlin, Germany
+
+	    All rights reserved
+	EMail : <support@mwat.de>
+*/
+package apachelogger
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"strconv"
+	"sync/atomic"
+)
+
+//lint:file-ignore ST1017 – I prefer Yoda conditions
+
+// `captureKeepAlive()` counts the request on its connection if the
+// server was set up by `SetKeepAliveLog()`.
+//
+// Parameters:
+// - `aState`: The request's logging state.
+// - `aContext`: The context of the current request.
+func captureKeepAlive(aState *tRequestState, aContext context.Context) {
+	if counter, ok := aContext.Value(alKeepAliveKey).(*uint32); ok {
+		aState.keepAlive = atomic.AddUint32(counter, 1) - 1
+		aState.keepAliveOK = true
+	}
+} // captureKeepAlive()
+
+// `addKeepAlive()` adds the field `keepalive` counted by
+// `captureKeepAlive()` to `aEntry`.
+//
+// Parameters:
+// - `aEntry`: The log entry to extend.
+// - `aState`: The request's logging state.
+func addKeepAlive(aEntry *TEntry, aState *tRequestState) {
+	if aState.keepAliveOK {
+		aEntry.SetField("keepalive", strconv.FormatUint(uint64(aState.keepAlive), 10))
+	}
+} // addKeepAlive()
+
+// `SetKeepAliveLog()` arranges for the requests received by `aServer`
+// to be logged with the number of requests served on the same client
+// connection before, like Apache's `%k`.
+//
+// The function installs a `ConnContext` callback counting the requests
+// of each connection; the number is added as the field `keepalive`
+// and written by the `%k` directive of `SetLogFormat()`: `0` for the
+// first request of a connection, `1` for the first keep-alive request
+// after it, and so on. This way the keep-alive effectiveness can be
+// measured from the access log. A `ConnContext` callback set before
+// is called as well.
+//
+// The function must be called before the server is started.
+//
+// Parameters:
+// - `aServer`: The server instance whose connections are to be counted.
+func SetKeepAliveLog(aServer *http.Server) {
+	connContext := aServer.ConnContext
+	aServer.ConnContext = func(aContext context.Context, aConn net.Conn) context.Context {
+		if nil != connContext {
+			aContext = connContext(aContext, aConn)
+		}
+
+		return context.WithValue(aContext, alKeepAliveKey, new(uint32))
+	}
+} // SetKeepAliveLog()
+
+/* _EoF_ */
@@ -0,0 +1,70 @@
+/*
+Copyright © 2024  M.Watermann, 10247 Berlin, Germany
+
+	    All rights reserved
+	EMail : <support@mwat.de>
+*/
+package apachelogger
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+)
+
+//lint:file-ignore ST1017 – I prefer Yoda conditions
+
+func TestSetKeepAliveLog(t *testing.T) {
+	var got []string
+	handler := wrapQueues(http.HandlerFunc(func(aWriter http.ResponseWriter, aRequest *http.Request) {
+		e := prepEntry()
+		addKeepAlive(e, requestState(aRequest.Context()))
+		got = append(got, e.Fields["keepalive"])
+	}), newRing(8), newRing(8))
+	server := httptest.NewUnstartedServer(handler)
+	SetKeepAliveLog(server.Config)
+	server.Start()
+	defer server.Close()
+
+	client := server.Client()
+	for i := 0; 3 > i; i++ {
+		resp, err := client.Get(server.URL)
+		if nil != err {
+			t.Fatalf("Get() error = %v", err)
+		}
+		_, _ = io.Copy(io.Discard, resp.Body)
+		_ = resp.Body.Close()
+	}
+	client.CloseIdleConnections()
+	if resp, err := client.Get(server.URL); nil == err { // a new connection
+		_ = resp.Body.Close()
+	}
+
+	if want := []string{"0", "1", "2", "0"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("keepalive = %v, want %v", got, want)
+	}
+} // TestSetKeepAliveLog()
+
+func Test_addKeepAlive(t *testing.T) {
+	e := prepEntry()
+	addKeepAlive(e, &tRequestState{}) // not counted
+	if _, ok := e.Fields["keepalive"]; ok {
+		t.Errorf("addKeepAlive() fields = %v, want none", e.Fields)
+	}
+
+	lf, err := parseFormat("%k %s")
+	if nil != err {
+		t.Fatalf("parseFormat() error = %v", err)
+	}
+	if got := lf.render(e); "- 200\n" != got {
+		t.Errorf("render() = %q, want %q", got, "- 200\n")
+	}
+	addKeepAlive(e, &tRequestState{keepAlive: 3, keepAliveOK: true})
+	if got := lf.render(e); "3 200\n" != got {
+		t.Errorf("render() = %q, want %q", got, "3 200\n")
+	}
+} // Test_addKeepAlive()
+
+/* _EoF_ */
@@ -0,0 +1,399 @@
+/*
+Copyright © 2024  M.Watermann, 10247 Berlin, Germany
+
+	    All rights reserved
+	EMail : <support@mwat.de>
+*/
+package apachelogger
+
+import (
+	"bytes"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+//lint:file-ignore ST1017 – I prefer Yoda conditions
+
+type (
+	// `TKeyProvider` supplies the keys used by `SetHashKeyRotation()`
+	// and `SetFieldKeyRotation()`, so keys never have to be part of the
+	// program's configuration.
+	TKeyProvider interface {
+		// `Key()` returns the key to use from `aNow` on.
+		Key(aNow time.Time) ([]byte, error)
+	}
+
+	// `TKeyProviderFunc` is a function acting as a `TKeyProvider`,
+	// e.g. to call the SDK of a key management service.
+	TKeyProviderFunc func(aNow time.Time) ([]byte, error)
+
+	// `tFileKeyProvider` reads the keys from files.
+	tFileKeyProvider struct {
+		pattern string // the file name, possibly with `{date}`
+	}
+
+	// `tHTTPKeyProvider` fetches the keys from a web service.
+	tHTTPKeyProvider struct {
+		url    string      // the service's URL, possibly with `{date}`
+		header http.Header // additional request headers
+		client *http.Client
+	}
+)
+
+const (
+	// Placeholder for the current day in key file names and URLs.
+	alKeyDatePlaceholder = "{date}"
+
+	// Maximum size of a key read by the providers.
+	alMaxKeySize = 1 << 16
+
+	// Delay before retrying a failed key rotation.
+	alKeyRetryDelay = time.Minute
+)
+
+var (
+	// Stop channels of the running key rotations.
+	alKeyRotation struct {
+		sync.Mutex
+		hash  chan struct{} // stops the hash key rotation
+		field chan struct{} // stops the field key rotation
+	}
+)
+
+// `Key()` calls the function.
+//
+// Part of the `TKeyProvider` interface.
+//
+// Parameters:
+// - `aNow`: The time from which on the key is used.
+//
+// Returns:
+// - `[]byte`: The key.
+// - `error`: A possible error retrieving the key.
+func (kf TKeyProviderFunc) Key(aNow time.Time) ([]byte, error) {
+	return kf(aNow)
+} // Key()
+
+// `Key()` reads the key from the provider's file.
+//
+// Part of the `TKeyProvider` interface.
+//
+// Parameters:
+// - `aNow`: The time from which on the key is used.
+//
+// Returns:
+// - `[]byte`: The key.
+// - `error`: A possible error reading the key file.
+func (fp tFileKeyProvider) Key(aNow time.Time) ([]byte, error) {
+	file, err := os.Open(keyDate(fp.pattern, aNow))
+	if nil != err {
+		return nil, err
+	}
+	defer file.Close()
+
+	return readKey(file)
+} // Key()
+
+// `Key()` fetches the key from the provider's web service.
+//
+// Part of the `TKeyProvider` interface.
+//
+// Parameters:
+// - `aNow`: The time from which on the key is used.
+//
+// Returns:
+// - `[]byte`: The key.
+// - `error`: A possible error fetching the key.
+func (hp tHTTPKeyProvider) Key(aNow time.Time) ([]byte, error) {
+	url := keyDate(hp.url, aNow)
+	req, err := http.NewRequest(http.MethodGet, url, nil)
+	if nil != err {
+		return nil, err
+	}
+	for name, values := range hp.header {
+		req.Header[name] = values
+	}
+	resp, err := hp.client.Do(req)
+	if nil != err {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if http.StatusOK != resp.StatusCode {
+		return nil, fmt.Errorf("apachelogger: key service %q: %s", url, resp.Status)
+	}
+
+	return readKey(resp.Body)
+} // Key()
+
+/* * * * * * * * * * * * * * * * * * * * * * * * * * * * * * * * * * * * * */
+
+// `NewFileKeyProvider()` returns a key provider reading the keys from
+// the file `aPattern`.
+//
+// The file is read again on each rotation, so the key can be replaced
+// by external tools. A `{date}` in `aPattern` is replaced by the
+// rotation's date (`YYYY-MM-DD`, see `SetTimeLocation()`) to use a
+// separate file per day. The file's content (without leading and
+// trailing whitespace) is the key, e.g. a random text for
+// `SetHashKeyRotation()` or a PEM encoded public key for
+// `SetFieldKeyRotation()`.
+//
+// Parameters:
+// - `aPattern`: The name of the key file.
+//
+// Returns:
+// - `TKeyProvider`: The file based key provider.
+func NewFileKeyProvider(aPattern string) TKeyProvider {
+	return tFileKeyProvider{pattern: aPattern}
+} // NewFileKeyProvider()
+
+// `NewHTTPKeyProvider()` returns a key provider fetching the keys by
+// a GET request from `aURL`, e.g. from a key management service's
+// REST interface.
+//
+// A `{date}` in `aURL` is replaced as described for
+// `NewFileKeyProvider()`; `aHeader` may hold additional headers like
+// the service's access token. The response's body (without leading and
+// trailing whitespace) is the key.
+//
+// Parameters:
+// - `aURL`: The URL to fetch the keys from.
+// - `aHeader`: Additional request headers (may be `nil`).
+//
+// Returns:
+// - `TKeyProvider`: The web service based key provider.
+func NewHTTPKeyProvider(aURL string, aHeader http.Header) TKeyProvider {
+	return tHTTPKeyProvider{
+		url:    aURL,
+		header: aHeader.Clone(),
+		client: &http.Client{Timeout: 10 * time.Second},
+	}
+} // NewHTTPKeyProvider()
+
+// `keyDate()` replaces the `{date}` placeholder in `aPattern`.
+//
+// Parameters:
+// - `aPattern`: The file name or URL.
+// - `aNow`: The time whose date to insert.
+//
+// Returns:
+// - `string`: The file name or URL to use.
+func keyDate(aPattern string, aNow time.Time) string {
+	return strings.ReplaceAll(aPattern, alKeyDatePlaceholder,
+		inLocation(aNow).Format("2006-01-02"))
+} // keyDate()
+
+// `readKey()` reads a key from `aReader`.
+//
+// Parameters:
+// - `aReader`: The source of the key.
+//
+// Returns:
+// - `[]byte`: The key without surrounding whitespace.
+// - `error`: A possible error reading or an empty key.
+func readKey(aReader io.Reader) ([]byte, error) {
+	data, err := io.ReadAll(io.LimitReader(aReader, alMaxKeySize))
+	if nil != err {
+		return nil, err
+	}
+	if data = bytes.TrimSpace(data); 0 == len(data) {
+		return nil, fmt.Errorf("apachelogger: empty key")
+	}
+
+	return data, nil
+} // readKey()
+
+// `parsePublicKey()` returns the RSA public key encoded by `aData`.
+//
+// Parameters:
+// - `aData`: The key as PEM or DER (PKIX or PKCS #1).
+//
+// Returns:
+// - `*rsa.PublicKey`: The public key.
+// - `error`: A possible error parsing `aData`.
+func parsePublicKey(aData []byte) (*rsa.PublicKey, error) {
+	if block, _ := pem.Decode(aData); nil != block {
+		aData = block.Bytes
+	}
+	if key, err := x509.ParsePKCS1PublicKey(aData); nil == err {
+		return key, nil
+	}
+	parsed, err := x509.ParsePKIXPublicKey(aData)
+	if nil != err {
+		return nil, fmt.Errorf("apachelogger: invalid public key: %w", err)
+	}
+	key, ok := parsed.(*rsa.PublicKey)
+	if !ok {
+		return nil, fmt.Errorf("apachelogger: not an RSA public key (%T)", parsed)
+	}
+
+	return key, nil
+} // parsePublicKey()
+
+// `nextRotation()` returns the time of the key rotation following
+// `aNow`.
+//
+// Intervals of whole days rotate at midnight (see `SetTimeLocation()`),
+// other intervals at the multiples of `aInterval`.
+//
+// Parameters:
+// - `aNow`: The time of the current rotation.
+// - `aInterval`: The time between two rotations.
+//
+// Returns:
+// - `time.Time`: The time of the next rotation.
+func nextRotation(aNow time.Time, aInterval time.Duration) time.Time {
+	const day = 24 * time.Hour
+
+	if 0 == aInterval%day {
+		y, m, d := inLocation(aNow).Date()
+		midnight := time.Date(y, m, d, 0, 0, 0, 0, inLocation(aNow).Location())
+
+		return midnight.AddDate(0, 0, int(aInterval/day))
+	}
+
+	return aNow.Truncate(aInterval).Add(aInterval)
+} // nextRotation()
+
+// `SetHashKeyRotation()` installs a hashing anonymiser (see
+// `NewHashAnonymiser()`) whose key is retrieved from `aProvider` and
+// replaced every `aInterval`.
+//
+// The first key is retrieved immediately; if that fails the anonymiser
+// isn't changed and the error is returned. Later failures are written
+// to the error log and retried every minute while the previous key
+// stays in use. An interval of whole days rotates the keys at midnight,
+// so all pseudonyms of one day are comparable while they can't be
+// linked across days. A `nil` provider or an `aInterval` of zero stops
+// the rotation (keeping the current anonymiser).
+//
+// Parameters:
+// - `aProvider`: The source of the hashing keys.
+// - `aInterval`: The time between two key rotations (e.g. `24 * time.Hour`).
+//
+// Returns:
+// - `error`: A possible error retrieving the first key.
+func SetHashKeyRotation(aProvider TKeyProvider, aInterval time.Duration) error {
+	return startKeyRotation(&alKeyRotation.hash, aProvider, aInterval,
+		func(aKey []byte) error {
+			SetAnonymiser(NewHashAnonymiser(aKey))
+			return nil
+		})
+} // SetHashKeyRotation()
+
+// `SetFieldKeyRotation()` encrypts the fields named `aFields` (see
+// `SetFieldEncryption()`) with the RSA public key retrieved from
+// `aProvider` and replaced every `aInterval`.
+//
+// The keys must be PEM or DER encoded (PKIX or PKCS #1). Errors are
+// handled as described for `SetHashKeyRotation()`; a `nil` provider or
+// an `aInterval` of zero stops the rotation (keeping the current key).
+//
+// Parameters:
+// - `aProvider`: The source of the public keys.
+// - `aInterval`: The time between two key rotations (e.g. `24 * time.Hour`).
+// - `aFields`: The names of the fields to encrypt.
+//
+// Returns:
+// - `error`: A possible error retrieving the first key.
+func SetFieldKeyRotation(aProvider TKeyProvider, aInterval time.Duration, aFields ...string) error {
+	fields := append([]string{}, aFields...)
+
+	return startKeyRotation(&alKeyRotation.field, aProvider, aInterval,
+		func(aKey []byte) error {
+			key, err := parsePublicKey(aKey)
+			if nil != err {
+				return err
+			}
+
+			return SetFieldEncryption(key, fields...)
+		})
+} // SetFieldKeyRotation()
+
+// `startKeyRotation()` stops the rotation controlled by `aStop` and
+// starts a new one.
+//
+// Parameters:
+// - `aStop`: The rotation's stop channel.
+// - `aProvider`: The source of the keys.
+// - `aInterval`: The time between two key rotations.
+// - `aApply`: The function putting a new key into use.
+//
+// Returns:
+// - `error`: A possible error retrieving or applying the first key.
+func startKeyRotation(aStop *chan struct{}, aProvider TKeyProvider, aInterval time.Duration, aApply func(aKey []byte) error) error {
+	alKeyRotation.Lock()
+	defer alKeyRotation.Unlock()
+
+	if nil != *aStop {
+		close(*aStop)
+		*aStop = nil
+	}
+	if (nil == aProvider) || (0 >= aInterval) {
+		return nil
+	}
+
+	now := time.Now()
+	if err := applyKey(aProvider, now, aApply); nil != err {
+		return err
+	}
+	stop := make(chan struct{})
+	*aStop = stop
+	go goKeyRotation(aProvider, aInterval, aApply, nextRotation(now, aInterval), stop)
+
+	return nil
+} // startKeyRotation()
+
+// `applyKey()` retrieves the key for `aNow` and puts it into use.
+//
+// Parameters:
+// - `aProvider`: The source of the keys.
+// - `aNow`: The time from which on the key is used.
+// - `aApply`: The function putting the key into use.
+//
+// Returns:
+// - `error`: A possible error retrieving or applying the key.
+func applyKey(aProvider TKeyProvider, aNow time.Time, aApply func(aKey []byte) error) error {
+	key, err := aProvider.Key(aNow)
+	if nil != err {
+		return fmt.Errorf("apachelogger: retrieving the key: %w", err)
+	}
+
+	return aApply(key)
+} // applyKey()
+
+// `goKeyRotation()` periodically replaces the key.
+//
+// Parameters:
+// - `aProvider`: The source of the keys.
+// - `aInterval`: The time between two key rotations.
+// - `aApply`: The function putting a new key into use.
+// - `aNext`: The time of the first rotation.
+// - `aStop`: Closed to stop the rotation.
+func goKeyRotation(aProvider TKeyProvider, aInterval time.Duration, aApply func(aKey []byte) error, aNext time.Time, aStop <-chan struct{}) {
+	for {
+		timer := time.NewTimer(time.Until(aNext))
+		select {
+		case <-aStop:
+			timer.Stop()
+			return
+		case now := <-timer.C:
+			if err := applyKey(aProvider, now, aApply); nil != err {
+				Err("ApacheLogger/KeyRotation", err.Error())
+				aNext = now.Add(alKeyRetryDelay)
+				continue
+			}
+			aNext = nextRotation(now, aInterval)
+		}
+	}
+} // goKeyRotation()
+
+/* _EoF_ */
@@ -0,0 +1,124 @@
+/*
+Copyright © 2024  M.Watermann, 10247 Berlin, Germany
+
+	    All rights reserved
+	EMail : <support@mwat.de>
+*/
+package apachelogger
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+//lint:file-ignore ST1017 – I prefer Yoda conditions
+
+func Test_nextRotation(t *testing.T) {
+	defer SetTimeLocation(nil)
+	SetTimeLocation(time.FixedZone("CEST", 7200))
+	now := time.Date(2024, 5, 1, 23, 30, 0, 0, time.UTC) // 01:30 CEST
+
+	tests := []struct {
+		name     string
+		interval time.Duration
+		want     time.Time
+	}{
+		{"day", 24 * time.Hour, time.Date(2024, 5, 2, 22, 0, 0, 0, time.UTC)},
+		{"week", 7 * 24 * time.Hour, time.Date(2024, 5, 8, 22, 0, 0, 0, time.UTC)},
+		{"hour", time.Hour, time.Date(2024, 5, 2, 0, 0, 0, 0, time.UTC)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := nextRotation(now, tt.interval); !got.Equal(tt.want) {
+				t.Errorf("nextRotation() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+} // Test_nextRotation()
+
+func TestNewFileKeyProvider(t *testing.T) {
+	dir := t.TempDir()
+	now := time.Now()
+	name := filepath.Join(dir, "hash-"+inLocation(now).Format("2006-01-02")+".key")
+	if err := os.WriteFile(name, []byte("secret\n"), 0600); nil != err {
+		t.Fatal(err)
+	}
+
+	kp := NewFileKeyProvider(filepath.Join(dir, "hash-{date}.key"))
+	if key, err := kp.Key(now); (nil != err) || ("secret" != string(key)) {
+		t.Errorf("Key() = %q, %v, want %q", key, err, "secret")
+	}
+	if _, err := kp.Key(now.AddDate(0, 0, 1)); nil == err {
+		t.Error("Key() found a key for tomorrow")
+	}
+} // TestNewFileKeyProvider()
+
+func TestSetHashKeyRotation(t *testing.T) {
+	defer SetAnonymiser(nil)
+	defer func() { _ = SetHashKeyRotation(nil, 0) }()
+	failing := TKeyProviderFunc(func(time.Time) ([]byte, error) {
+		return nil, errors.New("unavailable")
+	})
+	if err := SetHashKeyRotation(failing, time.Hour); nil == err {
+		t.Error("SetHashKeyRotation() accepted a failing provider")
+	}
+
+	var calls int32
+	counting := TKeyProviderFunc(func(time.Time) ([]byte, error) {
+		return []byte{byte(atomic.AddInt32(&calls, 1))}, nil
+	})
+	if err := SetHashKeyRotation(counting, 20*time.Millisecond); nil != err {
+		t.Fatalf("SetHashKeyRotation() error = %v", err)
+	}
+	ip := net.ParseIP("192.168.1.2")
+	first := NewHashAnonymiser([]byte{1}).Anonymise(ip, 200)
+	deadline := time.Now().Add(5 * time.Second)
+	for (3 > atomic.LoadInt32(&calls)) && time.Now().Before(deadline) {
+		time.Sleep(5 * time.Millisecond)
+	}
+	if 3 > atomic.LoadInt32(&calls) {
+		t.Fatal("SetHashKeyRotation() didn't rotate the key")
+	}
+	if got := anonymise(ip, 200, nil); first == got {
+		t.Errorf("anonymise() = %q after the rotation, want a new pseudonym", got)
+	}
+} // TestSetHashKeyRotation()
+
+func TestSetFieldKeyRotation(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 1024)
+	if nil != err {
+		t.Fatalf("GenerateKey() error = %v", err)
+	}
+	der, _ := x509.MarshalPKIXPublicKey(&key.PublicKey)
+	name := filepath.Join(t.TempDir(), "field.pem")
+	if err := os.WriteFile(name, pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der}), 0600); nil != err {
+		t.Fatal(err)
+	}
+	defer func() { _ = SetFieldEncryption(nil) }()
+	defer func() { _ = SetFieldKeyRotation(nil, 0) }()
+
+	if err := SetFieldKeyRotation(NewFileKeyProvider(name), 24*time.Hour, "user"); nil != err {
+		t.Fatalf("SetFieldKeyRotation() error = %v", err)
+	}
+	e := prepEntry()
+	e.User = "alice"
+	prepareEntry(e)
+	if !strings.HasPrefix(e.User, alEncryptedPrefix) {
+		t.Fatalf("User = %q, want it encrypted", e.User)
+	}
+	if got, err := DecryptField(key, e.User); "alice" != got {
+		t.Errorf("DecryptField() = %q, %v, want %q", got, err, "alice")
+	}
+} // TestSetFieldKeyRotation()
+
+/* _EoF_ */
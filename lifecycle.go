@@ -0,0 +1,123 @@
+/*
+Copyright © 2024  M.Watermann, 10247 Berlin, Germany
+
+	    All rights reserved
+	EMail : <support@mwat.de>
+*/
+package apachelogger
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"time"
+)
+
+//lint:file-ignore ST1017 – I prefer Yoda conditions
+
+const (
+	// Max. time to wait for the final messages to be written.
+	alLifecycleFlush = 5 * time.Second
+)
+
+var (
+	// `alLifecycleLog` writes a server lifecycle message.
+	alLifecycleLog = func(aMessage string) {
+		Err("ApacheLogger/Lifecycle", aMessage)
+	}
+)
+
+// `listenerMessage()` returns the message logged when the server
+// starts serving `aListener`.
+//
+// Parameters:
+// - `aServer`: The server starting to serve.
+// - `aListener`: The listener accepting the server's connections.
+//
+// Returns:
+// - `string`: The message to log.
+func listenerMessage(aServer *http.Server, aListener net.Listener) string {
+	addr := aListener.Addr()
+	tls := "no"
+	if nil != aServer.TLSConfig {
+		tls = "configured"
+	}
+
+	return fmt.Sprintf("event=start network=%s listener=%s addr=%s tls=%s pid=%d",
+		addr.Network(), addr.String(), aServer.Addr, tls, os.Getpid())
+} // listenerMessage()
+
+/* * * * * * * * * * * * * * * * * * * * * * * * * * * * * * * * * * * * * */
+
+// `SetLifecycleLog()` arranges for the lifecycle events of `aServer`
+// to be written to the error log, so the access log doesn't just stop
+// without any explanation.
+//
+// The function installs a `BaseContext` callback logging the address
+// of every listener the server starts serving (`event=start`), and
+// registers a shutdown function logging the begin of a graceful
+// shutdown (`event=shutdown_begin`). A `BaseContext` callback set
+// before is called as well.
+//
+// To log the end of the shutdown and a possibly forced close as well
+// use `ShutdownServer()` instead of the server's `Shutdown()` method.
+//
+// The function must be called before the server is started.
+//
+// Parameters:
+// - `aServer`: The server instance whose lifecycle is to be logged.
+func SetLifecycleLog(aServer *http.Server) {
+	baseContext := aServer.BaseContext
+	aServer.BaseContext = func(aListener net.Listener) context.Context {
+		alLifecycleLog(listenerMessage(aServer, aListener))
+		if nil != baseContext {
+			return baseContext(aListener)
+		}
+		return context.Background()
+	}
+	aServer.RegisterOnShutdown(func() {
+		alLifecycleLog("event=shutdown_begin addr=" + aServer.Addr)
+	})
+} // SetLifecycleLog()
+
+// `ShutdownServer()` gracefully shuts down `aServer`, logging the
+// shutdown's end to the error log.
+//
+// The server's `Shutdown()` method is called with `aContext`; if the
+// context ends before all connections became idle, the remaining
+// connections are closed forcibly by the server's `Close()` method,
+// which is logged as `event=forced_close`. Finally `event=shutdown_end`
+// (with the shutdown's duration) is logged and the pending log messages
+// are written (waiting at most five seconds).
+//
+// Parameters:
+// - `aContext`: The context limiting the graceful shutdown.
+// - `aServer`: The server to shut down.
+//
+// Returns:
+// - `error`: The error of `Shutdown()` (e.g. `context.DeadlineExceeded`
+// if the close was forced).
+func ShutdownServer(aContext context.Context, aServer *http.Server) error {
+	start := time.Now()
+	err := aServer.Shutdown(aContext)
+	if nil != err {
+		alLifecycleLog(fmt.Sprintf("event=forced_close addr=%s reason=%q",
+			aServer.Addr, err.Error()))
+		if cerr := aServer.Close(); nil != cerr {
+			alLifecycleLog(fmt.Sprintf("event=close_error addr=%s error=%q",
+				aServer.Addr, cerr.Error()))
+		}
+	}
+	alLifecycleLog(fmt.Sprintf("event=shutdown_end addr=%s duration=%s",
+		aServer.Addr, elapsed(start, time.Now()).Round(time.Millisecond)))
+
+	ctx, cancel := context.WithTimeout(context.Background(), alLifecycleFlush)
+	defer cancel()
+	_ = Flush(ctx)
+
+	return err
+} // ShutdownServer()
+
+/* _EoF_ */
@@ -0,0 +1,85 @@
+/*
+Copyright © 2024  M.Watermann, 10247 Berlin, Germany
+
+	    All rights reserved
+	EMail : <support@mwat.de>
+*/
+package apachelogger
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+//lint:file-ignore ST1017 – I prefer Yoda conditions
+
+func TestSetLifecycleLog(t *testing.T) {
+	var (
+		mtx      sync.Mutex
+		messages []string
+	)
+	oldLog := alLifecycleLog
+	defer func() { alLifecycleLog = oldLog }()
+	alLifecycleLog = func(aMessage string) {
+		mtx.Lock()
+		messages = append(messages, aMessage)
+		mtx.Unlock()
+	}
+	events := func() string {
+		mtx.Lock()
+		defer mtx.Unlock()
+		return strings.Join(messages, "\n")
+	}
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if nil != err {
+		t.Fatal(err)
+	}
+	started, release := make(chan struct{}), make(chan struct{})
+	server := &http.Server{
+		Handler: http.HandlerFunc(func(http.ResponseWriter, *http.Request) {
+			close(started)
+			<-release
+		}),
+	}
+	defer close(release)
+	SetLifecycleLog(server)
+	go func() { _ = server.Serve(listener) }()
+
+	go func() {
+		resp, err := http.Get("http://" + listener.Addr().String() + "/")
+		if nil == err {
+			resp.Body.Close()
+		}
+	}()
+	select {
+	case <-started:
+	case <-time.After(5 * time.Second):
+		t.Fatal("request not served")
+	}
+	if got := events(); !strings.Contains(got, "event=start network=tcp listener="+listener.Addr().String()) {
+		t.Errorf("SetLifecycleLog() logged %q, want the listener", got)
+	}
+
+	// the running request forces the close:
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if err = ShutdownServer(ctx, server); context.DeadlineExceeded != err {
+		t.Errorf("ShutdownServer() = %v, want %v", err, context.DeadlineExceeded)
+	}
+	time.Sleep(10 * time.Millisecond) // the shutdown hooks run in background
+	got := events()
+	for _, want := range []string{"event=shutdown_begin", "event=forced_close",
+		`reason="context deadline exceeded"`, "event=shutdown_end"} {
+		if !strings.Contains(got, want) {
+			t.Errorf("ShutdownServer() logged %q, want %q", got, want)
+		}
+	}
+} // TestSetLifecycleLog()
+
+/* _EoF_ */
@@ -0,0 +1,237 @@
+/*
+Copyright © 2024  M.Watermann, 10247 Berlin, Germany
+
+	    All rights reserved
+	EMail : <support@mwat.de>
+*/
+package apachelogger
+
+import (
+	"fmt"
+	"net/smtp"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+//lint:file-ignore ST1017 – I prefer Yoda conditions
+
+type (
+	// `TMailAlert` configures an email alert on bursts of failed requests.
+	TMailAlert struct {
+		// The SMTP server's address (`host:port`).
+		Server string
+
+		// The authentication to use with the SMTP server (optional).
+		Auth smtp.Auth
+
+		// The sender's email address.
+		From string
+
+		// The recipients' email addresses.
+		To []string
+
+		// The subject of the alert mails (default: `[apachelogger]
+		// error burst on <hostname>`).
+		Subject string
+
+		// The range of response status codes to count (default:
+		// 500 to 599).
+		MinStatus, MaxStatus int
+
+		// Number of matching requests within `Window` to exceed for an
+		// alert (default: 50).
+		Threshold int
+
+		// The length of the sliding time window (default: 5 minutes).
+		Window time.Duration
+
+		// Min. time between two alert mails (default: `Window`).
+		Cooldown time.Duration
+
+		// Number of sample entries to include in the mail (default: 10).
+		Samples int
+	}
+
+	// `tMailAlert` is a registered email alert with its current state.
+	tMailAlert struct {
+		TMailAlert
+		hits     []time.Time // ring of the latest `Threshold`+1 matching requests' times
+		next     int         // index of the ring's oldest entry
+		lastSent time.Time   // time the last alert was sent
+		mtx      sync.Mutex  // guard for the fields above
+		samples  []string    // the latest matching entries
+	}
+)
+
+var (
+	// List of registered email alerts.
+	alMailAlerts []*tMailAlert
+
+	// Guard for concurrent access to `alMailAlerts`.
+	alMailAlertsMtx sync.RWMutex
+
+	// The function sending the alert mails (replaceable for testing).
+	alSendMail = smtp.SendMail
+)
+
+// `observe()` counts `aEntry` if its status matches, returning the
+// mail to send if the threshold was exceeded.
+//
+// Parameters:
+// - `aEntry`: The (prepared) access log entry.
+//
+// Returns:
+// - `[]byte`: The alert mail to send (`nil` if none).
+func (ma *tMailAlert) observe(aEntry *TEntry) []byte {
+	if (ma.MinStatus > aEntry.Status) || (ma.MaxStatus < aEntry.Status) {
+		return nil
+	}
+	now := time.Now()
+
+	ma.mtx.Lock()
+	defer ma.mtx.Unlock()
+
+	// Only the latest `Threshold`+1 hits matter: the threshold is
+	// exceeded if the oldest of them is within the window.
+	if ma.Threshold >= len(ma.hits) {
+		ma.hits = append(ma.hits, now)
+	} else {
+		ma.hits[ma.next] = now
+		ma.next = (ma.next + 1) % len(ma.hits)
+	}
+	ma.samples = append(ma.samples, aEntry.String())
+	if ma.Samples < len(ma.samples) {
+		ma.samples = ma.samples[len(ma.samples)-ma.Samples:]
+	}
+
+	if (ma.Threshold >= len(ma.hits)) || (ma.Window < now.Sub(ma.hits[ma.next])) ||
+		(!ma.lastSent.IsZero() && (ma.Cooldown > now.Sub(ma.lastSent))) {
+		return nil
+	}
+	ma.lastSent = now
+
+	return ma.message(now)
+} // observe()
+
+// `message()` returns the alert mail.
+//
+// Parameters:
+// - `aNow`: The time of the alert.
+//
+// Returns:
+// - `[]byte`: The complete mail (headers and body).
+func (ma *tMailAlert) message(aNow time.Time) []byte {
+	var sb strings.Builder
+
+	fmt.Fprintf(&sb, "From: %s\r\n", ma.From)
+	fmt.Fprintf(&sb, "To: %s\r\n", strings.Join(ma.To, ", "))
+	fmt.Fprintf(&sb, "Subject: %s\r\n", ma.Subject)
+	fmt.Fprintf(&sb, "Date: %s\r\n", aNow.Format(time.RFC1123Z))
+	sb.WriteString("MIME-Version: 1.0\r\n")
+	sb.WriteString("Content-Type: text/plain; charset=utf-8\r\n\r\n")
+
+	fmt.Fprintf(&sb, "More than %d requests with status %d-%d within %s.\r\n\r\n",
+		ma.Threshold, ma.MinStatus, ma.MaxStatus, ma.Window)
+	sb.WriteString("Latest entries:\r\n\r\n")
+	for _, line := range ma.samples {
+		sb.WriteString(strings.TrimSuffix(line, "\n"))
+		sb.WriteString("\r\n")
+	}
+
+	return []byte(sb.String())
+} // message()
+
+/* * * * * * * * * * * * * * * * * * * * * * * * * * * * * * * * * * * * * */
+
+// `checkMailAlerts()` passes the access log entry `aEntry` to all
+// registered email alerts, sending the alert mails due.
+//
+// Parameters:
+// - `aEntry`: The (prepared) access log entry.
+func checkMailAlerts(aEntry *TEntry) {
+	alMailAlertsMtx.RLock()
+	defer alMailAlertsMtx.RUnlock()
+
+	for _, alert := range alMailAlerts {
+		if msg := alert.observe(aEntry); nil != msg {
+			go func(aAlert *tMailAlert, aMessage []byte) {
+				if err := alSendMail(aAlert.Server, aAlert.Auth,
+					aAlert.From, aAlert.To, aMessage); nil != err {
+					Err("ApacheLogger/MailAlert", err.Error())
+				}
+			}(alert, msg)
+		}
+	}
+} // checkMailAlerts()
+
+// `AddMailAlert()` registers an email alert sending a digest whenever
+// the number of failed requests exceeds a threshold within a sliding
+// time window (e.g. more than 50 responses with status 5xx within five
+// minutes).
+//
+// The mail contains the exceeded threshold and the latest
+// matching entries (anonymised like the access log). After an alert
+// no further mail is sent for `Cooldown`.
+//
+// Example:
+//
+//	err := apachelogger.AddMailAlert(apachelogger.TMailAlert{
+//		Server: "mail.example.com:587",
+//		Auth:   smtp.PlainAuth("", "web", "secret", "mail.example.com"),
+//		From:   "web@example.com",
+//		To:     []string{"admin@example.com"},
+//	})
+//
+// Parameters:
+// - `aAlert`: The alert's configuration.
+//
+// Returns:
+// - `error`: A possible error for an incomplete configuration.
+func AddMailAlert(aAlert TMailAlert) error {
+	if ("" == aAlert.Server) || ("" == aAlert.From) || (0 == len(aAlert.To)) {
+		return fmt.Errorf("apachelogger: mail alert needs server, sender and recipients")
+	}
+	if 0 >= aAlert.MinStatus {
+		aAlert.MinStatus = 500
+	}
+	if aAlert.MinStatus > aAlert.MaxStatus {
+		aAlert.MaxStatus = 599
+		if aAlert.MinStatus > aAlert.MaxStatus {
+			aAlert.MaxStatus = aAlert.MinStatus
+		}
+	}
+	if 0 >= aAlert.Threshold {
+		aAlert.Threshold = 50
+	}
+	if 0 >= aAlert.Window {
+		aAlert.Window = 5 * time.Minute
+	}
+	if 0 >= aAlert.Cooldown {
+		aAlert.Cooldown = aAlert.Window
+	}
+	if 0 >= aAlert.Samples {
+		aAlert.Samples = 10
+	}
+	if "" == aAlert.Subject {
+		host, _ := os.Hostname()
+		aAlert.Subject = "[apachelogger] error burst on " + host
+	}
+	aAlert.Subject = strings.NewReplacer("\r", " ", "\n", " ").Replace(aAlert.Subject)
+
+	alMailAlertsMtx.Lock()
+	alMailAlerts = append(alMailAlerts, &tMailAlert{TMailAlert: aAlert})
+	alMailAlertsMtx.Unlock()
+
+	return nil
+} // AddMailAlert()
+
+// `ClearMailAlerts()` removes all registered email alerts.
+func ClearMailAlerts() {
+	alMailAlertsMtx.Lock()
+	alMailAlerts = nil
+	alMailAlertsMtx.Unlock()
+} // ClearMailAlerts()
+
+/* _EoF_ */
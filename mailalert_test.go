@@ -0,0 +1,102 @@
+/*
+Copyright © 2024  M.Watermann, 10247 Berlin, Germany
+
+	    All rights reserved
+	EMail : <support@mwat.de>
+*/
+package apachelogger
+
+import (
+	"net/smtp"
+	"strings"
+	"testing"
+	"time"
+)
+
+//lint:file-ignore ST1017 – I prefer Yoda conditions
+
+func TestAddMailAlert(t *testing.T) {
+	defer ClearMailAlerts()
+	sent := make(chan string, 4)
+	defer func(aSend func(string, smtp.Auth, string, []string, []byte) error) {
+		alSendMail = aSend
+	}(alSendMail)
+	alSendMail = func(aServer string, _ smtp.Auth, aFrom string, aTo []string, aMsg []byte) error {
+		sent <- aServer + "|" + aFrom + "|" + strings.Join(aTo, ",") + "|" + string(aMsg)
+		return nil
+	}
+
+	if err := AddMailAlert(TMailAlert{Server: "localhost:25"}); nil == err {
+		t.Error("AddMailAlert() expected an error")
+	}
+	if err := AddMailAlert(TMailAlert{
+		Server:    "localhost:25",
+		From:      "web@example.com",
+		To:        []string{"admin@example.com"},
+		Subject:   "burst\r\nBcc: evil@example.com",
+		Threshold: 3,
+		Samples:   2,
+	}); nil != err {
+		t.Fatalf("AddMailAlert() error = %v", err)
+	}
+
+	e := prepEntry()
+	checkMailAlerts(e) // status 200: not counted
+	e.Status = 503
+	for i := 0; 5 > i; i++ {
+		checkMailAlerts(e)
+	}
+
+	select {
+	case got := <-sent:
+		for _, want := range []string{
+			"localhost:25|web@example.com|admin@example.com|",
+			"Subject: burst  Bcc: evil@example.com\r\n",
+			"More than 3 requests with status 500-599 within 5m0s.",
+			`"GET /path/to/file?lang=en HTTP/1.1" 503`,
+		} {
+			if !strings.Contains(got, want) {
+				t.Errorf("mail = %q,\nmissing %q", got, want)
+			}
+		}
+		if 2 != strings.Count(got, "503 27155") {
+			t.Errorf("mail = %q, want 2 samples", got)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("no alert mail sent")
+	}
+	select {
+	case got := <-sent:
+		t.Errorf("unexpected second mail (cooldown): %q", got)
+	case <-time.After(50 * time.Millisecond):
+	}
+} // TestAddMailAlert()
+
+func Test_tMailAlert_observe(t *testing.T) {
+	ma := &tMailAlert{TMailAlert: TMailAlert{
+		MinStatus: 500, MaxStatus: 599, Threshold: 3,
+		Window: time.Hour, Cooldown: time.Hour, Samples: 1,
+	}}
+	e := prepEntry()
+	e.Status = 500
+	for i := 0; 3 > i; i++ {
+		if msg := ma.observe(e); nil != msg {
+			t.Fatalf("observe() sent an alert after %d hits", i+1)
+		}
+	}
+	ma.hits[ma.next] = time.Now().Add(-2 * time.Hour) // outside the window
+	if msg := ma.observe(e); nil != msg {
+		t.Error("observe() counted a hit outside the window")
+	}
+	for i := 0; 1000 > i; i++ {
+		ma.observe(e)
+	}
+	if 4 != len(ma.hits) {
+		t.Errorf("observe() kept %d hits, want 4", len(ma.hits))
+	}
+	if ma.lastSent.IsZero() {
+		t.Error("observe() didn't send an alert")
+	}
+} // Test_tMailAlert_observe()
+
+/* _EoF_ */
@@ -0,0 +1,83 @@
+/*
+Copyright © 2024  M.Watermann, 10247 Berlin, Germany
+
+	    All rights reserved
+	EMail : <support@mwat.de>
+*/
+package apachelogger
+
+import (
+	"context"
+)
+
+//lint:file-ignore ST1017 – I prefer Yoda conditions
+
+// `copyNotes()` returns a copy of the notes set for the request.
+//
+// Returns:
+// - `map[string]string`: The request's notes (may be `nil`).
+func (rs *tRequestState) copyNotes() map[string]string {
+	rs.mtx.Lock()
+	defer rs.mtx.Unlock()
+
+	return copyStrings(rs.notes)
+} // copyNotes()
+
+/* * * * * * * * * * * * * * * * * * * * * * * * * * * * * * * * * * * * * */
+
+// `Note()` returns the note `aName` of the request belonging to
+// `aContext`.
+//
+// Parameters:
+// - `aContext`: The context of the current request.
+// - `aName`: The name of the note.
+//
+// Returns:
+// - `string`: The note's value (empty if not set).
+func Note(aContext context.Context, aName string) string {
+	rs := requestState(aContext)
+	if nil == rs {
+		return ""
+	}
+
+	rs.mtx.Lock()
+	defer rs.mtx.Unlock()
+
+	return rs.notes[aName]
+} // Note()
+
+// `SetNote()` sets the note `aName` of the request belonging to
+// `aContext` to `aValue`.
+//
+// Like Apache's request notes the notes are a generic way for the
+// middlewares running inside the wrapper (e.g. authentication, cache,
+// or WAF) to pass data to each other and to the log format's
+// `%{name}n` directive (see `SetLogFormat()`). Unlike the fields set
+// by `SetField()` they don't show up in the canonical, CSV, or JSON
+// entries. An empty `aValue` removes the note. Calling it with a
+// context not belonging to a wrapped request does nothing.
+//
+// Parameters:
+// - `aContext`: The context of the current request.
+// - `aName`: The name of the note.
+// - `aValue`: The note's value.
+func SetNote(aContext context.Context, aName, aValue string) {
+	rs := requestState(aContext)
+	if (nil == rs) || ("" == aName) {
+		return
+	}
+
+	rs.mtx.Lock()
+	defer rs.mtx.Unlock()
+
+	if "" == aValue {
+		delete(rs.notes, aName)
+		return
+	}
+	if nil == rs.notes {
+		rs.notes = make(map[string]string)
+	}
+	rs.notes[aName] = aValue
+} // SetNote()
+
+/* _EoF_ */
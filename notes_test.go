@@ -0,0 +1,47 @@
+/*
+Copyright © 2024  M.Watermann, 10247 Berlin, Germany
+
+	    All rights reserved
+	EMail : <support@mwat.de>
+*/
+package apachelogger
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+//lint:file-ignore ST1017 – I prefer Yoda conditions
+
+func TestSetNote(t *testing.T) {
+	SetNote(context.Background(), "cache", "hit") // must not panic
+	if got := Note(context.Background(), "cache"); "" != got {
+		t.Errorf("Note() = %q, want an empty string", got)
+	}
+
+	req, rs := withRequestState(httptest.NewRequest(http.MethodGet, "/", nil))
+	SetNote(req.Context(), "cache", "hit")
+	SetNote(req.Context(), "waf", "pass")
+	SetNote(req.Context(), "waf", "")
+	if got := Note(req.Context(), "cache"); "hit" != got {
+		t.Errorf("Note() = %q, want %q", got, "hit")
+	}
+	notes := rs.copyNotes()
+	if (1 != len(notes)) || (nil != rs.copyFields()) {
+		t.Errorf("copyNotes() = %v, want only the cache note", notes)
+	}
+
+	defer SetLogFormat("")
+	if err := SetLogFormat(`%>s %{cache}n "%{waf}n"`); nil != err {
+		t.Fatal(err)
+	}
+	e1 := prepEntry()
+	e1.notes = notes
+	if got, want := formatEntry(e1), `200 hit "-"`+"\n"; want != got {
+		t.Errorf("formatEntry() = %q, want %q", got, want)
+	}
+} // TestSetNote()
+
+/* _EoF_ */
@@ -0,0 +1,632 @@
+/*
+Copyright © 2024  M.Watermann, 10247 Berlin, Germany
+
+	    All rights reserved
+	EMail : <support@mwat.de>
+*/
+package apachelogger
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+)
+
+//lint:file-ignore ST1017 – I prefer Yoda conditions
+
+type (
+	// `TOption` configures the logging set up by `WrapWith()`.
+	//
+	// Options are created by the `With…()` functions and applied in
+	// the order given. Options like `WithFormat()` or `WithLocation()`
+	// apply to the wrapper's logs only (which are shared with other wrappers and
+	// functions like `Log()` writing to the same logfiles or sinks);
+	// options like `WithSpoolDir()` change process-wide settings. They
+	// take effect only if all options are valid and the logfiles could
+	// be opened.
+	TOption func(aOptions *tOptions) error
+
+	// `tLogSettings` holds the settings of a log given by the options
+	// of `WrapWith()` (see `tRing.logSettings()`).
+	tLogSettings struct {
+		anonymiser   TAnonymiser    // the remote addresses' anonymiser (`nil`: see `SetAnonymiser()`)
+		dayChange    int            // the `DayChange` mode (if `hasDayChange`)
+		format       *tLogFormat    // the entries' format (`nil`: the process-wide one)
+		hasDayChange bool           // whether `dayChange` is set
+		location     *time.Location // the time zone (`nil`: see `SetTimeLocation()`)
+	}
+
+	// `tOptionOutput` is an access output given by `WithAccessOutput()`.
+	tOptionOutput struct {
+		format TFormatter // renders the entries
+		sinks  []TSink    // the sinks to write to
+	}
+
+	// `tOptionRoute` is an error route given by `WithErrorRoute()`.
+	tOptionRoute struct {
+		level int   // the min. severity to send
+		sink  TSink // the sink to send the entries to
+	}
+
+	// `tOptions` holds the per-wrapper settings of `WrapWith()`.
+	tOptions struct {
+		accessLog  string                            // name of the access logfile
+		errorLog   string                            // name of the error logfile
+		accessSink TSink                             // sink for access log messages
+		compress   int                               // min. size of responses to compress
+		context    context.Context                   // stops the writers when done
+		errorSink  TSink                             // sink for error log messages
+		filter     func(aRequest *http.Request) bool // decides which requests to log
+		globals    []func()                          // process-wide settings to commit
+		locals     []func(aSettings *tLogSettings)   // the logs' settings to commit
+		outputs    []tOptionOutput                   // additional access log outputs
+		routes     []tOptionRoute                    // additional error log sinks
+	}
+)
+
+// `filterHandler()` returns a handler suppressing the access log entry
+// of all requests rejected by `aFilter`.
+//
+// Parameters:
+// - `aHandler`: The handler to call.
+// - `aFilter`: Decides which requests to log.
+//
+// Returns:
+// - `http.Handler`: The filtering handler.
+func filterHandler(aHandler http.Handler, aFilter func(aRequest *http.Request) bool) http.Handler {
+	return http.HandlerFunc(
+		func(aWriter http.ResponseWriter, aRequest *http.Request) {
+			if !aFilter(aRequest) {
+				Suppress(aRequest.Context())
+			}
+			aHandler.ServeHTTP(aWriter, aRequest)
+		})
+} // filterHandler()
+
+var (
+	// Guard for changing the settings of the logs.
+	alLogSettingsMtx sync.Mutex
+)
+
+// `in()` returns `aTime` in the log's time zone or else the
+// process-wide one (see `SetTimeLocation()`).
+//
+// Parameters:
+// - `aTime`: The time to convert.
+//
+// Returns:
+// - `time.Time`: The (possibly) converted time.
+func (ls *tLogSettings) in(aTime time.Time) time.Time {
+	if (nil != ls) && (nil != ls.location) {
+		return aTime.In(ls.location)
+	}
+
+	return inLocation(aTime)
+} // in()
+
+// `dayChangeMode()` returns the log's `DayChange` mode or else the
+// process-wide one.
+//
+// Returns:
+// - `int`: The mode to use when the day changes.
+func (ls *tLogSettings) dayChangeMode() int {
+	if (nil != ls) && ls.hasDayChange {
+		return ls.dayChange
+	}
+
+	return DayChange
+} // dayChangeMode()
+
+// `logFormat()` returns the log's format.
+//
+// Returns:
+// - `*tLogFormat`: The format (`nil` for the process-wide output
+// settings).
+func (ls *tLogSettings) logFormat() *tLogFormat {
+	if nil == ls {
+		return nil
+	}
+
+	return ls.format
+} // logFormat()
+
+// `remoteAnonymiser()` returns the log's anonymiser of the remote
+// addresses.
+//
+// Returns:
+// - `TAnonymiser`: The anonymiser (`nil` for the one set by
+// `SetAnonymiser()`).
+func (ls *tLogSettings) remoteAnonymiser() TAnonymiser {
+	if nil == ls {
+		return nil
+	}
+
+	return ls.anonymiser
+} // remoteAnonymiser()
+
+// `logSettings()` returns the settings of the queue's log.
+//
+// Returns:
+// - `*tLogSettings`: The settings (`nil` if there are none).
+func (r *tRing) logSettings() *tLogSettings {
+	if nil == r {
+		return nil
+	}
+	result, _ := r.settings.Load().(*tLogSettings)
+
+	return result
+} // logSettings()
+
+// `changeSettings()` replaces the settings of the queue's log by a
+// copy changed by `aChanges`.
+//
+// Parameters:
+// - `aChanges`: The functions changing the settings.
+func (r *tRing) changeSettings(aChanges []func(aSettings *tLogSettings)) {
+	alLogSettingsMtx.Lock()
+	defer alLogSettingsMtx.Unlock()
+
+	settings := &tLogSettings{}
+	if current := r.logSettings(); nil != current {
+		*settings = *current
+	}
+	for _, change := range aChanges {
+		change(settings)
+	}
+	r.settings.Store(settings)
+} // changeSettings()
+
+// `setGlobal()` records a process-wide setting to commit once all
+// options are valid.
+//
+// Parameters:
+// - `aSetter`: The function changing the setting.
+func (o *tOptions) setGlobal(aSetter func()) {
+	o.globals = append(o.globals, aSetter)
+} // setGlobal()
+
+// `setLocal()` records a setting of the wrapper's logs to commit once
+// all options are valid.
+//
+// Parameters:
+// - `aSetter`: The function changing the logs' settings.
+func (o *tOptions) setLocal(aSetter func(aSettings *tLogSettings)) {
+	o.locals = append(o.locals, aSetter)
+} // setLocal()
+
+// `checkOutputs()` checks that each access output has a sink other
+// than the access log's sink `aAccessSink`.
+//
+// Parameters:
+// - `aAccessSink`: The sink of the access log.
+//
+// Returns:
+// - `error`: A possible error with the outputs.
+func (o *tOptions) checkOutputs(aAccessSink TSink) error {
+	for _, output := range o.outputs {
+		own := true
+		for _, sink := range output.sinks {
+			own = own && sameTarget(sink, aAccessSink)
+		}
+		if own {
+			return fmt.Errorf("apachelogger: sink used by the access log already")
+		}
+	}
+
+	return nil
+} // checkOutputs()
+
+/* * * * * * * * * * * * * * * * * * * * * * * * * * * * * * * * * * * * * */
+
+// `WithAccessLog()` writes the access log to the file `aFilename`
+// (see `Wrap()`).
+//
+// Parameters:
+// - `aFilename`: The name of the file to use for access log messages.
+//
+// Returns:
+// - `TOption`: The option for `WrapWith()`.
+func WithAccessLog(aFilename string) TOption {
+	return func(aOptions *tOptions) error {
+		aOptions.accessLog, aOptions.accessSink = aFilename, nil
+		return nil
+	}
+} // WithAccessLog()
+
+// `WithAccessOutput()` additionally writes the wrapper's access log
+// entries rendered by `aFormatter` to `aSinks` (see
+// `AddAccessOutput()`).
+//
+// Parameters:
+// - `aFormatter`: The formatter to render the entries.
+// - `aSinks`: The sinks to write the rendered entries to.
+//
+// Returns:
+// - `TOption`: The option for `WrapWith()`.
+func WithAccessOutput(aFormatter TFormatter, aSinks ...TSink) TOption {
+	return func(aOptions *tOptions) error {
+		if nil == aFormatter {
+			return fmt.Errorf("apachelogger: no formatter")
+		}
+		if 0 == len(aSinks) {
+			return fmt.Errorf("apachelogger: no sink")
+		}
+		aOptions.outputs = append(aOptions.outputs,
+			tOptionOutput{format: aFormatter, sinks: aSinks})
+		return nil
+	}
+} // WithAccessOutput()
+
+// `WithAccessSink()` writes the access log to `aSink` (see
+// `WrapSinks()`).
+//
+// Parameters:
+// - `aSink`: The sink to use for access log messages.
+//
+// Returns:
+// - `TOption`: The option for `WrapWith()`.
+func WithAccessSink(aSink TSink) TOption {
+	return func(aOptions *tOptions) error {
+		aOptions.accessLog, aOptions.accessSink = "", aSink
+		return nil
+	}
+} // WithAccessSink()
+
+// `WithAnonymiser()` sets the anonymiser of the remote addresses
+// logged by the wrapper instead of the process-wide one (see
+// `SetAnonymiser()`).
+//
+// Parameters:
+// - `aAnonymiser`: The anonymiser to use (`nil` for the process-wide
+// one).
+//
+// Returns:
+// - `TOption`: The option for `WrapWith()`.
+func WithAnonymiser(aAnonymiser TAnonymiser) TOption {
+	return func(aOptions *tOptions) error {
+		aOptions.setLocal(func(aSettings *tLogSettings) {
+			aSettings.anonymiser = aAnonymiser
+		})
+		return nil
+	}
+} // WithAnonymiser()
+
+// `WithCompression()` compresses the responses of at least `aMinSize`
+// bytes (see `Compress()`).
+//
+// Parameters:
+// - `aMinSize`: The min. size of responses to compress (`0` uses
+// `DefaultCompressMinSize`).
+//
+// Returns:
+// - `TOption`: The option for `WrapWith()`.
+func WithCompression(aMinSize int) TOption {
+	return func(aOptions *tOptions) error {
+		if 0 >= aMinSize {
+			aMinSize = DefaultCompressMinSize
+		}
+		aOptions.compress = aMinSize
+		return nil
+	}
+} // WithCompression()
+
+// `WithContext()` stops the wrapper's background writers once
+// `aContext` is done, e.g. when a plugin gets unloaded or a test ends.
+//
+// The writers write the messages queued so far, close their sinks,
+// and return; later messages for the wrapper are discarded. Writers
+// are shared by all wrappers (and functions like `Log()`) using the
+// same sinks, so those are stopped as well. Using the sinks again
+// (e.g. by `WrapWith()`) starts new writers.
+//
+// Parameters:
+// - `aContext`: The context whose end stops the writers.
+//
+// Returns:
+// - `TOption`: The option for `WrapWith()`.
+func WithContext(aContext context.Context) TOption {
+	return func(aOptions *tOptions) error {
+		aOptions.context = aContext
+		return nil
+	}
+} // WithContext()
+
+// `WithDayChange()` decides what to write when the day changes in the
+// wrapper's logs instead of the process-wide `DayChange`.
+//
+// Parameters:
+// - `aMode`: One of `DayChangeNone`, `DayChangeBlankLine`,
+// `DayChangeMarker`, or `DayChangeRotate`.
+//
+// Returns:
+// - `TOption`: The option for `WrapWith()`.
+func WithDayChange(aMode int) TOption {
+	return func(aOptions *tOptions) error {
+		if (DayChangeNone > aMode) || (DayChangeRotate < aMode) {
+			return fmt.Errorf("apachelogger: unknown day change mode %d", aMode)
+		}
+		aOptions.setLocal(func(aSettings *tLogSettings) {
+			aSettings.dayChange, aSettings.hasDayChange = aMode, true
+		})
+		return nil
+	}
+} // WithDayChange()
+
+// `WithDuplicateWindow()` collapses bursts of identical requests (see
+// `SetDuplicateWindow()`).
+//
+// Parameters:
+// - `aWindow`: The length of a burst.
+//
+// Returns:
+// - `TOption`: The option for `WrapWith()`.
+func WithDuplicateWindow(aWindow time.Duration) TOption {
+	return func(aOptions *tOptions) error {
+		aOptions.setGlobal(func() { SetDuplicateWindow(aWindow) })
+		return nil
+	}
+} // WithDuplicateWindow()
+
+// `WithErrorLog()` writes the error log to the file `aFilename`
+// (see `Wrap()`).
+//
+// Parameters:
+// - `aFilename`: The name of the file to use for error log messages.
+//
+// Returns:
+// - `TOption`: The option for `WrapWith()`.
+func WithErrorLog(aFilename string) TOption {
+	return func(aOptions *tOptions) error {
+		aOptions.errorLog, aOptions.errorSink = aFilename, nil
+		return nil
+	}
+} // WithErrorLog()
+
+// `WithErrorRoute()` additionally sends the wrapper's error log
+// entries with at least the severity `aLevel` to `aSink` (see
+// `AddErrorRoute()`).
+//
+// Parameters:
+// - `aLevel`: The min. severity (`LevelWarn` or `LevelError`).
+// - `aSink`: The sink to send the entries to.
+//
+// Returns:
+// - `TOption`: The option for `WrapWith()`.
+func WithErrorRoute(aLevel int, aSink TSink) TOption {
+	return func(aOptions *tOptions) error {
+		if err := checkLevel(aLevel); nil != err {
+			return err
+		}
+		aOptions.routes = append(aOptions.routes, tOptionRoute{aLevel, aSink})
+		return nil
+	}
+} // WithErrorRoute()
+
+// `WithErrorSink()` writes the error log to `aSink` (see
+// `WrapSinks()`).
+//
+// Parameters:
+// - `aSink`: The sink to use for error log messages.
+//
+// Returns:
+// - `TOption`: The option for `WrapWith()`.
+func WithErrorSink(aSink TSink) TOption {
+	return func(aOptions *tOptions) error {
+		aOptions.errorLog, aOptions.errorSink = "", aSink
+		return nil
+	}
+} // WithErrorSink()
+
+// `WithFilter()` logs only the requests accepted by `aFilter`, e.g.
+// to skip health checks.
+//
+// The filter is called before the wrapped handler; the requests it
+// returns `false` for are handled as if `Suppress()` was called.
+//
+// Parameters:
+// - `aFilter`: Decides which requests to log (`nil` for all).
+//
+// Returns:
+// - `TOption`: The option for `WrapWith()`.
+func WithFilter(aFilter func(aRequest *http.Request) bool) TOption {
+	return func(aOptions *tOptions) error {
+		aOptions.filter = aFilter
+		return nil
+	}
+} // WithFilter()
+
+// `WithFormat()` sets the format of the wrapper's log entries (see
+// `SetLogFormat()`), taking precedence over the process-wide output
+// settings like `BinaryLog` or `SetCSVFormat()`.
+//
+// Parameters:
+// - `aFormat`: The Apache `LogFormat` string (empty for the
+// process-wide output settings).
+//
+// Returns:
+// - `TOption`: The option for `WrapWith()`.
+func WithFormat(aFormat string) TOption {
+	return func(aOptions *tOptions) error {
+		var lf *tLogFormat
+		if "" != aFormat {
+			var err error
+			if lf, err = parseFormat(aFormat); nil != err {
+				return err
+			}
+		}
+		aOptions.setLocal(func(aSettings *tLogSettings) {
+			aSettings.format = lf
+		})
+		return nil
+	}
+} // WithFormat()
+
+// `WithLocation()` logs the timestamps and changes the logging day
+// of the wrapper's logs in the time zone `aLocation` instead of the
+// process-wide one (see `SetTimeLocation()`).
+//
+// Parameters:
+// - `aLocation`: The time zone to use (`nil` for `time.Local`).
+//
+// Returns:
+// - `TOption`: The option for `WrapWith()`.
+func WithLocation(aLocation *time.Location) TOption {
+	return func(aOptions *tOptions) error {
+		if nil == aLocation {
+			aLocation = time.Local
+		}
+		aOptions.setLocal(func(aSettings *tLogSettings) {
+			aSettings.location = aLocation
+		})
+		return nil
+	}
+} // WithLocation()
+
+// `WithProfile()` configures the wrapper's logs for a log analyser
+// (see `SetProfile()`) like `WithFormat()` does, writing no day
+// separators unless the logfiles are rotated (`DayChangeRotate`).
+//
+// Parameters:
+// - `aProfile`: One of `ProfileNone` (the process-wide output
+// settings), `ProfileGoAccess`, or `ProfileAWStats`.
+//
+// Returns:
+// - `TOption`: The option for `WrapWith()`.
+func WithProfile(aProfile int) TOption {
+	return func(aOptions *tOptions) error {
+		lf, err := profileFormat(aProfile)
+		if nil != err {
+			return err
+		}
+		aOptions.setLocal(func(aSettings *tLogSettings) {
+			aSettings.format = lf
+			if (nil != lf) && (DayChangeRotate != aSettings.dayChangeMode()) {
+				aSettings.dayChange, aSettings.hasDayChange = DayChangeNone, true
+			}
+		})
+		return nil
+	}
+} // WithProfile()
+
+// `WithQueueShards()` splits the access log queue (see
+// `SetQueueShards()`).
+//
+// Parameters:
+// - `aShards`: The number of shards to use (`0` for one per CPU).
+//
+// Returns:
+// - `TOption`: The option for `WrapWith()`.
+func WithQueueShards(aShards int) TOption {
+	return func(aOptions *tOptions) error {
+		aOptions.setGlobal(func() { SetQueueShards(aShards) })
+		return nil
+	}
+} // WithQueueShards()
+
+// `WithRecentSize()` keeps the latest access log entries in memory
+// (see `SetRecentSize()`).
+//
+// Parameters:
+// - `aSize`: The number of entries to keep.
+//
+// Returns:
+// - `TOption`: The option for `WrapWith()`.
+func WithRecentSize(aSize int) TOption {
+	return func(aOptions *tOptions) error {
+		aOptions.setGlobal(func() { SetRecentSize(aSize) })
+		return nil
+	}
+} // WithRecentSize()
+
+// `WithSpoolDir()` spills the queues' overflow to temporary files (see
+// `SetSpoolDir()`).
+//
+// Parameters:
+// - `aDirectory`: The directory for the spool files.
+//
+// Returns:
+// - `TOption`: The option for `WrapWith()`.
+func WithSpoolDir(aDirectory string) TOption {
+	return func(aOptions *tOptions) error {
+		aOptions.setGlobal(func() { SetSpoolDir(aDirectory) })
+		return nil
+	}
+} // WithSpoolDir()
+
+// `WrapWith()` returns a handler function that includes logging,
+// wrapping the given `aHandler`, and calling it internally.
+//
+// It's the forward-compatible replacement of `Wrap()` and `WrapSinks()`:
+// the logfiles (or sinks) as well as the commonly tuned settings are
+// given as options, e.g.
+//
+//	handler, err := apachelogger.WrapWith(pageHandler,
+//		apachelogger.WithAccessLog("access.log"),
+//		apachelogger.WithErrorLog("error.log"),
+//		apachelogger.WithFormat(apachelogger.FormatCombined),
+//		apachelogger.WithFilter(func(aRequest *http.Request) bool {
+//			return "/healthz" != aRequest.URL.Path
+//		}))
+//
+// Without an access (or error) log option the respective messages are
+// discarded. Unlike `Wrap()` an error opening a logfile (or of another
+// option) is returned instead of terminating the program; in that case
+// no setting is changed and no writer is started.
+//
+// Parameters:
+// - `aHandler`: Responds to the actual HTTP request.
+// - `aOptions`: The options to apply in the given order.
+//
+// Returns:
+// - `http.Handler`: The (augmented) `aHandler`.
+// - `error`: The error of the first failing option or of opening a logfile.
+func WrapWith(aHandler http.Handler, aOptions ...TOption) (http.Handler, error) {
+	options := &tOptions{}
+	for _, option := range aOptions {
+		if err := option(options); nil != err {
+			return nil, err
+		}
+	}
+
+	accessSink, errorSink, err := openSinks(options.accessLog, options.errorLog)
+	if nil != err {
+		return nil, err
+	}
+	if nil != options.accessSink {
+		accessSink = options.accessSink
+	}
+	if nil != options.errorSink {
+		errorSink = options.errorSink
+	}
+	if err = options.checkOutputs(accessSink); nil != err {
+		return nil, err
+	}
+	for _, setter := range options.globals {
+		setter()
+	}
+	if 0 < options.compress {
+		aHandler = Compress(aHandler, options.compress)
+	}
+	if nil != options.filter {
+		aHandler = filterHandler(aHandler, options.filter)
+	}
+	accessRing, errorRing := startWriters(accessSink, errorSink)
+	if 0 < len(options.locals) {
+		accessRing.changeSettings(options.locals)
+		errorRing.changeSettings(options.locals)
+	}
+	watchContext(options.context, accessRing, errorRing)
+	for _, route := range options.routes {
+		addErrorRoute(errorRing, route.level, route.sink)
+	}
+	for _, output := range options.outputs {
+		// the arguments were checked above
+		_ = addAccessOutput(accessRing, output.format, output.sinks)
+	}
+
+	return wrapQueues(aHandler, accessRing, errorRing), nil
+} // WrapWith()
+
+/* _EoF_ */
@@ -0,0 +1,256 @@
+/*
+Copyright © 2024  M.Watermann, 10247 Berlin, Germany
+
+	    All rights reserved
+	EMail : <support@mwat.de>
+*/
+package apachelogger
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+//lint:file-ignore ST1017 – I prefer Yoda conditions
+
+func TestWrapWith(t *testing.T) {
+	oldDayChange := DayChange
+	defer func() { DayChange = oldDayChange }()
+	handler := http.HandlerFunc(func(http.ResponseWriter, *http.Request) {})
+
+	if _, err := WrapWith(handler, WithDayChange(42)); nil == err {
+		t.Error("WrapWith() expected an error for an invalid day change mode")
+	}
+	if _, err := WrapWith(handler, WithFormat("%{")); nil == err {
+		t.Error("WrapWith() expected an error for an invalid format")
+	}
+	if _, err := WrapWith(handler,
+		WithAccessLog(filepath.Join(t.TempDir(), "missing", "access.log"))); nil == err {
+		t.Error("WrapWith() expected an error for an unopenable logfile")
+	}
+
+	opts := &tOptions{}
+	for _, option := range []TOption{
+		WithAccessLog("access.log"), WithAccessSink(Discard),
+		WithErrorSink(Discard), WithErrorLog("error.log"),
+	} {
+		_ = option(opts)
+	}
+	if ("" != opts.accessLog) || (Discard != opts.accessSink) ||
+		("error.log" != opts.errorLog) || (nil != opts.errorSink) {
+		t.Errorf("options = %+v, want the last one to win", opts)
+	}
+
+	DayChange = DayChangeMarker
+	sink := &tMemSink{}
+	if _, err := WrapWith(handler, WithAccessSink(sink), WithErrorSink(Discard),
+		WithDayChange(DayChangeNone), WithProfile(ProfileGoAccess),
+		WithAnonymiser(NewNoopAnonymiser())); nil != err {
+		t.Fatalf("WrapWith() error = %v", err)
+	}
+	alFileQueuesMtx.Lock()
+	settings := sinkQueue(sink, 1).logSettings()
+	alFileQueuesMtx.Unlock()
+	if (DayChangeNone != settings.dayChangeMode()) || (nil == settings.logFormat()) ||
+		!settings.logFormat().strict || (nil == settings.remoteAnonymiser()) {
+		t.Errorf("settings = %+v, want the wrapper's options", settings)
+	}
+	if (DayChangeMarker != DayChange) || (nil != logFormat()) {
+		t.Errorf("DayChange = %d, format = %v, want the process-wide settings unchanged",
+			DayChange, logFormat())
+	}
+
+	// a failing option mustn't change the settings of the valid ones:
+	if _, err := WrapWith(handler, WithAccessSink(sink), WithErrorSink(Discard),
+		WithDayChange(DayChangeMarker), WithFormat("%{")); nil == err {
+		t.Error("WrapWith() expected an error for an invalid format")
+	}
+	alFileQueuesMtx.Lock()
+	settings = sinkQueue(sink, 1).logSettings()
+	alFileQueuesMtx.Unlock()
+	if DayChangeNone != settings.dayChangeMode() {
+		t.Errorf("dayChangeMode() = %d, want it unchanged", settings.dayChangeMode())
+	}
+
+	// an output without a sink of its own mustn't start the writers:
+	own := &tMemSink{}
+	if _, err := WrapWith(handler, WithAccessSink(own),
+		WithAccessOutput(ECSJSON, own)); nil == err {
+		t.Error("WrapWith() expected an error for the access log's sink")
+	}
+	alFileQueuesMtx.Lock()
+	for _, sq := range alSinkQueues {
+		if sameSink(sq.sink, own) {
+			t.Error("WrapWith() started a writer despite the error")
+		}
+	}
+	alFileQueuesMtx.Unlock()
+} // TestWrapWith()
+
+func TestWithContext(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	accessSink, errorSink := &tMemSink{}, &tMemSink{}
+	handler, err := WrapWith(http.HandlerFunc(func(http.ResponseWriter, *http.Request) {}),
+		WithContext(ctx), WithAccessSink(accessSink), WithErrorSink(errorSink))
+	if nil != err {
+		t.Fatalf("WrapWith() error = %v", err)
+	}
+	handler.ServeHTTP(httptest.NewRecorder(),
+		httptest.NewRequest(http.MethodGet, "/context", nil))
+
+	alFileQueuesMtx.Lock()
+	queue := sinkQueue(accessSink, 1)
+	alFileQueuesMtx.Unlock()
+	cancel()
+
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		queue.state.Lock()
+		running := queue.state.running
+		queue.state.Unlock()
+		if !running {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("the writer didn't stop after the context was cancelled")
+		}
+		time.Sleep(time.Millisecond)
+	}
+	if !strings.Contains(string(accessSink.data), " /context ") || (0 == accessSink.closed) {
+		t.Errorf("access sink = %q (closed %d), want the entry written and the sink closed",
+			accessSink.data, accessSink.closed)
+	}
+	alFileQueuesMtx.Lock()
+	for _, sq := range alSinkQueues {
+		if sq.queue == queue {
+			t.Error("the stopped queue is still registered")
+		}
+	}
+	alFileQueuesMtx.Unlock()
+} // TestWithContext()
+
+func TestWithLocation(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	handler := http.HandlerFunc(func(http.ResponseWriter, *http.Request) {})
+	zoned, plain := &tMemSink{}, &tMemSink{}
+	h1, err := WrapWith(handler, WithContext(ctx), WithAccessSink(zoned),
+		WithErrorSink(Discard), WithLocation(time.FixedZone("UTC+5:30", 5*3600+1800)))
+	if nil != err {
+		t.Fatalf("WrapWith() error = %v", err)
+	}
+	h2, err := WrapWith(handler, WithContext(ctx), WithAccessSink(plain),
+		WithErrorSink(Discard))
+	if nil != err {
+		t.Fatalf("WrapWith() error = %v", err)
+	}
+	h1.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/zoned", nil))
+	h2.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/plain", nil))
+
+	alFileQueuesMtx.Lock()
+	queues := []*tRing{sinkQueue(zoned, 1), sinkQueue(plain, 1)}
+	alFileQueuesMtx.Unlock()
+	cancel()
+	deadline := time.Now().Add(5 * time.Second)
+	for _, queue := range queues {
+		for {
+			queue.state.Lock()
+			running := queue.state.running
+			queue.state.Unlock()
+			if !running {
+				break
+			}
+			if time.Now().After(deadline) {
+				t.Fatal("the writer didn't stop after the context was cancelled")
+			}
+			time.Sleep(time.Millisecond)
+		}
+	}
+	if !strings.Contains(string(zoned.data), " +0530] ") {
+		t.Errorf("zoned log = %q, want the time in UTC+5:30", zoned.data)
+	}
+	if !strings.Contains(string(plain.data), " /plain ") ||
+		strings.Contains(string(plain.data), " +0530] ") {
+		t.Errorf("plain log = %q, want the process' time zone", plain.data)
+	}
+} // TestWithLocation()
+
+func TestWithFormat(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	oldAnonymise := AnonymiseURLs
+	defer func() { AnonymiseURLs = oldAnonymise }()
+	AnonymiseURLs = true
+	handler := http.HandlerFunc(func(http.ResponseWriter, *http.Request) {})
+	formatted, plain := &tMemSink{}, &tMemSink{}
+	h1, err := WrapWith(handler, WithContext(ctx), WithAccessSink(formatted),
+		WithErrorSink(Discard), WithFormat("%h %m %U %{X-Test}i"),
+		WithAnonymiser(NewNoopAnonymiser()))
+	if nil != err {
+		t.Fatalf("WrapWith() error = %v", err)
+	}
+	h2, err := WrapWith(handler, WithContext(ctx), WithAccessSink(plain),
+		WithErrorSink(Discard))
+	if nil != err {
+		t.Fatalf("WrapWith() error = %v", err)
+	}
+	for _, h := range []http.Handler{h1, h2} {
+		req := httptest.NewRequest(http.MethodGet, "/formatted", nil)
+		req.RemoteAddr = "192.0.2.123:4567"
+		req.Header.Set("X-Test", "yes")
+		h.ServeHTTP(httptest.NewRecorder(), req)
+	}
+
+	alFileQueuesMtx.Lock()
+	queues := []*tRing{sinkQueue(formatted, 1), sinkQueue(plain, 1)}
+	alFileQueuesMtx.Unlock()
+	cancel()
+	deadline := time.Now().Add(5 * time.Second)
+	for _, queue := range queues {
+		for {
+			queue.state.Lock()
+			running := queue.state.running
+			queue.state.Unlock()
+			if !running {
+				break
+			}
+			if time.Now().After(deadline) {
+				t.Fatal("the writer didn't stop after the context was cancelled")
+			}
+			time.Sleep(time.Millisecond)
+		}
+	}
+	if want := "192.0.2.123 GET /formatted yes\n"; want != string(formatted.data) {
+		t.Errorf("formatted log = %q, want %q", formatted.data, want)
+	}
+	if got := string(plain.data); !strings.HasPrefix(got, "192.0.2.0 - - [") ||
+		!strings.Contains(got, `"GET /formatted HTTP/1.1"`) {
+		t.Errorf("plain log = %q, want the process-wide format and anonymiser", got)
+	}
+} // TestWithFormat()
+
+func Test_filterHandler(t *testing.T) {
+	var suppressed bool
+	inner := http.HandlerFunc(func(aWriter http.ResponseWriter, aRequest *http.Request) {
+		suppressed = requestState(aRequest.Context()).isSuppressed()
+	})
+	handler := filterHandler(inner, func(aRequest *http.Request) bool {
+		return "/healthz" != aRequest.URL.Path
+	})
+
+	for path, want := range map[string]bool{"/healthz": true, "/index.html": false} {
+		req, _ := withRequestState(httptest.NewRequest(http.MethodGet, path, nil))
+		handler.ServeHTTP(httptest.NewRecorder(), req)
+		if want != suppressed {
+			t.Errorf("filterHandler(%q) suppressed = %v, want %v", path, suppressed, want)
+		}
+	}
+} // Test_filterHandler()
+
+/* _EoF_ */
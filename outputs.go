@@ -0,0 +1,324 @@
+/*
+Copyright © 2024  M.Watermann, 10247 Berlin, Germany
+
+	    All rights reserved
+	EMail : <support@mwat.de>
+*/
+package apachelogger
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+)
+
+//lint:file-ignore ST1017 – I prefer Yoda conditions
+
+type (
+	// `TFormatter` is a function rendering a log entry as a line
+	// (including the trailing newline) for `AddAccessOutput()`.
+	//
+	// Besides `NewLogFormatter()`, `ECSJSON()`, and `BinaryRecord()`
+	// the method expressions `(*TEntry).String` (Combined Log Format)
+	// and `(*TEntry).Canonical` can be used as formatters.
+	TFormatter func(aEntry *TEntry) string
+
+	// `tAccessOutput` writes the access log entries formatted by its
+	// own formatter to additional queues.
+	tAccessOutput struct {
+		format TFormatter // renders the entries
+		queues []*tRing   // the queues of the output's sinks
+	}
+
+	// `tECSRecord` is an access log entry in the Elastic Common Schema.
+	tECSRecord struct {
+		Timestamp string            `json:"@timestamp"`
+		ECS       tECSVersion       `json:"ecs"`
+		Message   string            `json:"message,omitempty"`
+		Log       *tECSLog          `json:"log,omitempty"`
+		Source    *tECSSource       `json:"source,omitempty"`
+		User      *tECSUser         `json:"user,omitempty"`
+		HTTP      *tECSHTTP         `json:"http,omitempty"`
+		URL       *tECSURL          `json:"url,omitempty"`
+		UserAgent *tECSUserAgent    `json:"user_agent,omitempty"`
+		Event     *tECSEvent        `json:"event,omitempty"`
+		Labels    map[string]string `json:"labels,omitempty"`
+	}
+
+	// `tECSVersion` is the ECS record's `ecs` object.
+	tECSVersion struct {
+		Version string `json:"version"`
+	}
+
+	// `tECSLog` is the ECS record's `log` object.
+	tECSLog struct {
+		Level  string `json:"level"`
+		Logger string `json:"logger,omitempty"`
+	}
+
+	// `tECSSource` is the ECS record's `source` object.
+	tECSSource struct {
+		Address string `json:"address"`
+	}
+
+	// `tECSUser` is the ECS record's `user` object.
+	tECSUser struct {
+		Name string `json:"name"`
+	}
+
+	// `tECSHTTP` is the ECS record's `http` object.
+	tECSHTTP struct {
+		Version  string          `json:"version,omitempty"`
+		Request  tECSHTTPRequest `json:"request"`
+		Response tECSHTTPResult  `json:"response"`
+	}
+
+	// `tECSHTTPRequest` is the ECS record's `http.request` object.
+	tECSHTTPRequest struct {
+		Method   string `json:"method"`
+		Referrer string `json:"referrer,omitempty"`
+	}
+
+	// `tECSHTTPResult` is the ECS record's `http.response` object.
+	tECSHTTPResult struct {
+		StatusCode int          `json:"status_code"`
+		Body       tECSHTTPBody `json:"body"`
+	}
+
+	// `tECSHTTPBody` is the ECS record's `http.response.body` object.
+	tECSHTTPBody struct {
+		Bytes int `json:"bytes"`
+	}
+
+	// `tECSURL` is the ECS record's `url` object.
+	tECSURL struct {
+		Original string `json:"original"`
+	}
+
+	// `tECSUserAgent` is the ECS record's `user_agent` object.
+	tECSUserAgent struct {
+		Original string `json:"original"`
+	}
+
+	// `tECSEvent` is the ECS record's `event` object.
+	tECSEvent struct {
+		Duration int64 `json:"duration"` // nanoseconds
+	}
+)
+
+const (
+	// The version of the Elastic Common Schema used by `ECSJSON()`.
+	alECSVersion = "8.11.0"
+)
+
+var (
+	// The additional outputs of the access queues.
+	alAccessOutputs struct {
+		sync.RWMutex
+		outputs map[*tRing][]tAccessOutput
+	}
+)
+
+// `ecsValue()` returns `aText` unless it denotes a missing value.
+//
+// Parameters:
+// - `aText`: The entry's field.
+//
+// Returns:
+// - `string`: The field's value (empty for `-`).
+func ecsValue(aText string) string {
+	if "-" == aText {
+		return ""
+	}
+
+	return aText
+} // ecsValue()
+
+// `ECSJSON()` returns `aEntry` as a JSON line following the Elastic
+// Common Schema (ECS), e.g. to be shipped to Elasticsearch.
+//
+// The entry's additional fields are written as `labels`; the messages
+// of `Log()`, `Err()` etc. get the keys `message`, `log.level`, and
+// `log.logger`.
+//
+// Parameters:
+// - `aEntry`: The log entry to format.
+//
+// Returns:
+// - `string`: The JSON record (including the trailing newline).
+func ECSJSON(aEntry *TEntry) string {
+	record := tECSRecord{
+		Timestamp: aEntry.timestamp().Format(time.RFC3339Nano),
+		ECS:       tECSVersion{Version: alECSVersion},
+		Labels:    aEntry.Fields,
+	}
+	if remote := ecsValue(aEntry.Remote); "" != remote {
+		record.Source = &tECSSource{Address: remote}
+	}
+	if user := ecsValue(aEntry.User); "" != user {
+		record.User = &tECSUser{Name: user}
+	}
+
+	switch level := aEntry.level(); level {
+	case LevelInfo, LevelWarn, LevelError:
+		record.Message = aEntry.Path
+		record.Log = &tECSLog{
+			Level:  [...]string{"info", "warn", "error"}[level],
+			Logger: ecsValue(aEntry.Referrer),
+		}
+
+	default:
+		record.HTTP = &tECSHTTP{
+			Version: strings.TrimPrefix(aEntry.Proto, "HTTP/"),
+			Request: tECSHTTPRequest{
+				Method:   aEntry.Method,
+				Referrer: ecsValue(aEntry.Referrer),
+			},
+			Response: tECSHTTPResult{
+				StatusCode: aEntry.Status,
+				Body:       tECSHTTPBody{Bytes: aEntry.Size},
+			},
+		}
+		record.URL = &tECSURL{Original: aEntry.Path}
+		if agent := ecsValue(aEntry.Agent); "" != agent {
+			record.UserAgent = &tECSUserAgent{Original: agent}
+		}
+		if 0 < aEntry.Duration {
+			record.Event = &tECSEvent{Duration: int64(aEntry.Duration)}
+		}
+	}
+
+	var sb strings.Builder
+	enc := json.NewEncoder(&sb)
+	enc.SetEscapeHTML(false)
+	if err := enc.Encode(record); nil != err {
+		return aEntry.String()
+	}
+
+	return sb.String() // `Encode()` appended the newline
+} // ECSJSON()
+
+// `NewLogFormatter()` returns a formatter rendering the entries by the
+// Apache-like log format `aFormat` (see `SetLogFormat()`), e.g.
+// `FormatCombined` for GoAccess.
+//
+// Parameters:
+// - `aFormat`: The log format to use.
+//
+// Returns:
+// - `TFormatter`: The formatter.
+// - `error`: A possible error parsing `aFormat`.
+func NewLogFormatter(aFormat string) (TFormatter, error) {
+	lf, err := parseFormat(aFormat)
+	if nil != err {
+		return nil, err
+	}
+
+	return lf.render, nil
+} // NewLogFormatter()
+
+// `addAccessOutput()` writes the entries of `aQueue` formatted by
+// `aFormatter` to `aSinks` as well.
+//
+// Parameters:
+// - `aQueue`: The access queue whose entries to write (`nil` for the
+// global access log, whose queue may change when it's started).
+// - `aFormatter`: The formatter to render the entries.
+// - `aSinks`: The additional sinks.
+//
+// Returns:
+// - `error`: A possible error with the arguments.
+func addAccessOutput(aQueue *tRing, aFormatter TFormatter, aSinks []TSink) error {
+	if nil == aFormatter {
+		return fmt.Errorf("apachelogger: no formatter")
+	}
+	if 0 == len(aSinks) {
+		return fmt.Errorf("apachelogger: no sink")
+	}
+
+	own := aQueue
+	if nil == own {
+		own = accessQueue()
+	}
+	output := tAccessOutput{format: aFormatter}
+	alFileQueuesMtx.Lock()
+	for _, sink := range aSinks {
+		if queue := sinkQueue(sink, 1); queue != own {
+			output.queues = append(output.queues, queue)
+		}
+	}
+	alFileQueuesMtx.Unlock()
+	if 0 == len(output.queues) {
+		return fmt.Errorf("apachelogger: sink used by the access log already")
+	}
+
+	alAccessOutputs.Lock()
+	if nil == alAccessOutputs.outputs {
+		alAccessOutputs.outputs = make(map[*tRing][]tAccessOutput)
+	}
+	alAccessOutputs.outputs[aQueue] = append(alAccessOutputs.outputs[aQueue], output)
+	alAccessOutputs.Unlock()
+
+	return nil
+} // addAccessOutput()
+
+// `writeOutputs()` sends `aEntry` to the additional outputs of
+// `aQueue`, formatting it once per formatter.
+//
+// Parameters:
+// - `aEntry`: The (prepared) log entry to write.
+// - `aQueue`: The queue the entry is logged to.
+func writeOutputs(aEntry *TEntry, aQueue *tRing) {
+	alAccessOutputs.RLock()
+	outputs := alAccessOutputs.outputs[aQueue]
+	if aQueue == accessQueue() {
+		outputs = append(outputs[:len(outputs):len(outputs)],
+			alAccessOutputs.outputs[nil]...)
+	}
+	alAccessOutputs.RUnlock()
+
+	for _, output := range outputs {
+		line := output.format(aEntry)
+		for _, queue := range output.queues {
+			queue.push(line)
+		}
+	}
+} // writeOutputs()
+
+/* * * * * * * * * * * * * * * * * * * * * * * * * * * * * * * * * * * * * */
+
+// `AddAccessOutput()` additionally writes all access log entries
+// rendered by `aFormatter` to `aSinks`, e.g.
+//
+//	goaccess, _ := apachelogger.NewLogFormatter(apachelogger.FormatCombined)
+//	_ = apachelogger.AddAccessOutput(goaccess, apachelogger.NewFileSink("goaccess.log"))
+//	_ = apachelogger.AddAccessOutput(apachelogger.ECSJSON, elasticSink)
+//
+// Each entry is prepared once (see e.g. `AddTransformer()` and
+// `AddRedaction()`) and rendered once per formatter, however many sinks
+// it's written to; the access log itself keeps its own format. This
+// avoids running several logging middlewares for several formats. The
+// outputs apply to the global access log; see `WithAccessOutput()` for
+// a single wrapper.
+//
+// Parameters:
+// - `aFormatter`: The formatter to render the entries.
+// - `aSinks`: The sinks to write the rendered entries to.
+//
+// Returns:
+// - `error`: A possible error with the arguments.
+func AddAccessOutput(aFormatter TFormatter, aSinks ...TSink) error {
+	return addAccessOutput(nil, aFormatter, aSinks)
+} // AddAccessOutput()
+
+// `ClearAccessOutputs()` removes all outputs added by
+// `AddAccessOutput()` and `WithAccessOutput()`.
+func ClearAccessOutputs() {
+	alAccessOutputs.Lock()
+	alAccessOutputs.outputs = nil
+	alAccessOutputs.Unlock()
+} // ClearAccessOutputs()
+
+/* _EoF_ */
@@ -0,0 +1,123 @@
+/*
+Copyright © 2024  M.Watermann, 10247 Berlin, Germany
+
+	    All rights reserved
+	EMail : <support@mwat.de>
+*/
+package apachelogger
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+)
+
+//lint:file-ignore ST1017 – I prefer Yoda conditions
+
+func TestECSJSON(t *testing.T) {
+	e := prepEntry()
+	e.Duration = 1500 * time.Microsecond
+	e.SetField("alpn", "h2")
+
+	var record map[string]interface{}
+	if err := json.Unmarshal([]byte(ECSJSON(e)), &record); nil != err {
+		t.Fatalf("ECSJSON() isn't valid JSON: %v", err)
+	}
+	path := func(aKeys ...string) interface{} {
+		var value interface{} = record
+		for _, key := range aKeys {
+			obj, ok := value.(map[string]interface{})
+			if !ok {
+				return nil
+			}
+			value = obj[key]
+		}
+		return value
+	}
+	tests := []struct {
+		keys []string
+		want interface{}
+	}{
+		{[]string{"source", "address"}, "192.168.1.0"},
+		{[]string{"user"}, nil},
+		{[]string{"http", "version"}, "1.1"},
+		{[]string{"http", "request", "method"}, "GET"},
+		{[]string{"http", "response", "status_code"}, 200.0},
+		{[]string{"http", "response", "body", "bytes"}, 27155.0},
+		{[]string{"url", "original"}, "/path/to/file?lang=en"},
+		{[]string{"user_agent", "original"}, "Mozilla/5.0"},
+		{[]string{"event", "duration"}, 1.5e6},
+		{[]string{"labels", "alpn"}, "h2"},
+	}
+	for _, tt := range tests {
+		if got := path(tt.keys...); tt.want != got {
+			t.Errorf("ECSJSON() %s = %v, want %v", strings.Join(tt.keys, "."), got, tt.want)
+		}
+	}
+
+	msg := customEntry("test", "hello", `WARN`, time.Now())
+	if got := ECSJSON(msg); !strings.Contains(got, `"message":"hello","log":{"level":"warn","logger":"test"}`) ||
+		strings.Contains(got, `"http"`) {
+		t.Errorf("ECSJSON() = %s", got)
+	}
+
+	forged := prepEntry()
+	forged.Method = `ERR`
+	if got := ECSJSON(forged); !strings.Contains(got, `"method":"ERR"`) ||
+		strings.Contains(got, `"log"`) {
+		t.Errorf("ECSJSON(request ERR) = %s, want an HTTP record", got)
+	}
+} // TestECSJSON()
+
+func Test_writeOutputs(t *testing.T) {
+	defer ClearAccessOutputs()
+	queue, out1, out2 := newRing(8), newRing(8), newRing(8)
+	defer stopQueues([]*tRing{queue}) // unregisters the sink below
+
+	if err := addAccessOutput(queue, nil, []TSink{Discard}); nil == err {
+		t.Error("addAccessOutput() accepted no formatter")
+	}
+	if err := addAccessOutput(queue, ECSJSON, nil); nil == err {
+		t.Error("addAccessOutput() accepted no sink")
+	}
+	own := &tMemSink{}
+	alFileQueuesMtx.Lock()
+	registerSinkQueue(own, queue)
+	alFileQueuesMtx.Unlock()
+	if err := addAccessOutput(queue, ECSJSON, []TSink{own}); nil == err {
+		t.Error("addAccessOutput() accepted the access log's own sink")
+	}
+
+	calls := 0
+	combined, err := NewLogFormatter(FormatCombined)
+	if nil != err {
+		t.Fatalf("NewLogFormatter() error = %v", err)
+	}
+	alAccessOutputs.Lock()
+	alAccessOutputs.outputs = map[*tRing][]tAccessOutput{
+		queue: {{
+			format: func(aEntry *TEntry) string {
+				calls++
+				return combined(aEntry)
+			},
+			queues: []*tRing{out1, out2},
+		}},
+	}
+	alAccessOutputs.Unlock()
+
+	e := prepEntry()
+	writeOutputs(e, queue)
+	writeOutputs(e, newRing(8)) // no outputs
+	if 1 != calls {
+		t.Errorf("writeOutputs() formatted %d times, want once", calls)
+	}
+	want := e.String()
+	for _, out := range []*tRing{out1, out2} {
+		if got := strings.Join(out.popBatch(make([]string, 0, 4)), ""); want != got {
+			t.Errorf("writeOutputs() wrote %q, want %q", got, want)
+		}
+	}
+} // Test_writeOutputs()
+
+/* _EoF_ */
@@ -0,0 +1,670 @@
+/*
+Copyright © 2024  M.Watermann, 10247 Berlin, Germany
+
+	    All rights reserved
+	EMail : <support@mwat.de>
+*/
+package apachelogger
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"time"
+)
+
+//lint:file-ignore ST1017 – I prefer Yoda conditions
+
+type (
+	// `TParquetOptions` configures a Parquet sink.
+	TParquetOptions struct {
+		// The directory to write the Parquet files to.
+		Directory string
+
+		// The prefix of the files' names (default: `access`); the files
+		// are named like `access-2024-04-25T20.parquet`.
+		Prefix string
+
+		// Max. number of entries per row group (default: 10000).
+		RowGroupSize int
+
+		// Max. time to keep entries before writing them as a row group
+		// (default: 1 minute).
+		FlushDelay time.Duration
+
+		// Whether to compress the column data with gzip.
+		Gzip bool
+	}
+
+	// `tParquetColumn` describes a column of the Parquet files.
+	tParquetColumn struct {
+		name      string                                      // the column's name
+		kind      int32                                       // the physical type
+		converted int32                                       // the converted type (`-1`: none)
+		value     func(aBuffer []byte, aEntry *TEntry) []byte // appends the PLAIN encoded value
+	}
+
+	// `tParquetChunk` describes a column chunk written to a file.
+	tParquetChunk struct {
+		offset  int64 // the offset of the chunk's data page
+		size    int64 // the (compressed) size including the page header
+		rawSize int64 // the uncompressed size including the page header
+	}
+
+	// `tParquetRowGroup` describes a row group written to a file.
+	tParquetRowGroup struct {
+		chunks []tParquetChunk // the group's column chunks
+		rows   int64           // the group's number of rows
+	}
+
+	// `tParquetSink` writes log entries to hourly Parquet files.
+	tParquetSink struct {
+		opts    TParquetOptions    // the sink's configuration
+		file    *os.File           // the current file (`nil` if closed)
+		name    string             // the current file's name
+		hour    string             // the hour of the current file
+		end     int64              // the end of the row groups written
+		groups  []tParquetRowGroup // the row groups of the current file
+		pending []*TEntry          // entries not yet written
+		since   time.Time          // time the oldest pending entry was added
+		rest    []byte             // an incomplete record of the last write
+	}
+
+	// `tThriftWriter` encodes structures in Thrift's compact protocol
+	// as used by the Parquet metadata.
+	tThriftWriter struct {
+		buf  []byte  // the encoded data
+		last []int16 // the last field ID of each open structure
+	}
+)
+
+const (
+	// The magic bytes at the start and end of a Parquet file.
+	alParquetMagic = "PAR1"
+
+	// Parquet's physical types.
+	alParquetInt32     = 1
+	alParquetInt64     = 2
+	alParquetByteArray = 6
+
+	// Parquet's converted types.
+	alParquetUTF8            = 0
+	alParquetTimestampMicros = 10
+	alParquetJSON            = 19
+
+	// Parquet's encodings and compression codecs.
+	alParquetPlain = 0
+	alParquetRLE   = 3
+	alParquetGzip  = 2
+
+	// Thrift's compact protocol types.
+	alThriftI32    = 5
+	alThriftI64    = 6
+	alThriftBinary = 8
+	alThriftList   = 9
+	alThriftStruct = 12
+)
+
+var (
+	// The columns of the Parquet files.
+	alParquetColumns = []tParquetColumn{
+		{"time", alParquetInt64, alParquetTimestampMicros, func(aBuffer []byte, aEntry *TEntry) []byte {
+			return appendParquetInt64(aBuffer, aEntry.When.UnixNano()/int64(time.Microsecond))
+		}},
+		{"remote", alParquetByteArray, alParquetUTF8, func(aBuffer []byte, aEntry *TEntry) []byte {
+			return appendParquetString(aBuffer, aEntry.Remote)
+		}},
+		{"user", alParquetByteArray, alParquetUTF8, func(aBuffer []byte, aEntry *TEntry) []byte {
+			return appendParquetString(aBuffer, aEntry.User)
+		}},
+		{"method", alParquetByteArray, alParquetUTF8, func(aBuffer []byte, aEntry *TEntry) []byte {
+			return appendParquetString(aBuffer, aEntry.Method)
+		}},
+		{"path", alParquetByteArray, alParquetUTF8, func(aBuffer []byte, aEntry *TEntry) []byte {
+			return appendParquetString(aBuffer, aEntry.Path)
+		}},
+		{"proto", alParquetByteArray, alParquetUTF8, func(aBuffer []byte, aEntry *TEntry) []byte {
+			return appendParquetString(aBuffer, aEntry.Proto)
+		}},
+		{"status", alParquetInt32, -1, func(aBuffer []byte, aEntry *TEntry) []byte {
+			return appendParquetInt32(aBuffer, int32(aEntry.Status))
+		}},
+		{"size", alParquetInt64, -1, func(aBuffer []byte, aEntry *TEntry) []byte {
+			return appendParquetInt64(aBuffer, int64(aEntry.Size))
+		}},
+		{"referrer", alParquetByteArray, alParquetUTF8, func(aBuffer []byte, aEntry *TEntry) []byte {
+			return appendParquetString(aBuffer, aEntry.Referrer)
+		}},
+		{"agent", alParquetByteArray, alParquetUTF8, func(aBuffer []byte, aEntry *TEntry) []byte {
+			return appendParquetString(aBuffer, aEntry.Agent)
+		}},
+		{"duration_us", alParquetInt64, -1, func(aBuffer []byte, aEntry *TEntry) []byte {
+			return appendParquetInt64(aBuffer, aEntry.Duration.Microseconds())
+		}},
+		{"fields", alParquetByteArray, alParquetJSON, func(aBuffer []byte, aEntry *TEntry) []byte {
+			fields := []byte("{}")
+			if 0 < len(aEntry.Fields) {
+				fields, _ = json.Marshal(aEntry.Fields)
+			}
+			return appendParquetString(aBuffer, string(fields))
+		}},
+	}
+)
+
+// `appendParquetInt32()` appends the PLAIN encoded `aValue` to
+// `aBuffer`.
+//
+// Parameters:
+// - `aBuffer`: The buffer to append to.
+// - `aValue`: The value to encode.
+//
+// Returns:
+// - `[]byte`: The extended buffer.
+func appendParquetInt32(aBuffer []byte, aValue int32) []byte {
+	var tmp [4]byte
+	binary.LittleEndian.PutUint32(tmp[:], uint32(aValue))
+
+	return append(aBuffer, tmp[:]...)
+} // appendParquetInt32()
+
+// `appendParquetInt64()` appends the PLAIN encoded `aValue` to
+// `aBuffer`.
+//
+// Parameters:
+// - `aBuffer`: The buffer to append to.
+// - `aValue`: The value to encode.
+//
+// Returns:
+// - `[]byte`: The extended buffer.
+func appendParquetInt64(aBuffer []byte, aValue int64) []byte {
+	var tmp [8]byte
+	binary.LittleEndian.PutUint64(tmp[:], uint64(aValue))
+
+	return append(aBuffer, tmp[:]...)
+} // appendParquetInt64()
+
+// `appendParquetString()` appends the PLAIN encoded `aText` to
+// `aBuffer`.
+//
+// Parameters:
+// - `aBuffer`: The buffer to append to.
+// - `aText`: The value to encode.
+//
+// Returns:
+// - `[]byte`: The extended buffer.
+func appendParquetString(aBuffer []byte, aText string) []byte {
+	aBuffer = appendParquetInt32(aBuffer, int32(len(aText)))
+
+	return append(aBuffer, aText...)
+} // appendParquetString()
+
+// `newThriftWriter()` returns a writer of a Thrift structure.
+//
+// Returns:
+// - `*tThriftWriter`: The writer.
+func newThriftWriter() *tThriftWriter {
+	return &tThriftWriter{last: []int16{0}}
+} // newThriftWriter()
+
+// `begin()` starts a structure, either the value of the field `aID`
+// or (with `0`) an element of a list.
+//
+// Parameters:
+// - `aID`: The field's ID (`0` for a list element).
+func (tw *tThriftWriter) begin(aID int16) {
+	if 0 < aID {
+		tw.field(aID, alThriftStruct)
+	}
+	tw.last = append(tw.last, 0)
+} // begin()
+
+// `binary()` writes the string field `aID`.
+//
+// Parameters:
+// - `aID`: The field's ID.
+// - `aValue`: The field's value.
+func (tw *tThriftWriter) binary(aID int16, aValue string) {
+	tw.field(aID, alThriftBinary)
+	tw.buf = appendUvarint(tw.buf, uint64(len(aValue)))
+	tw.buf = append(tw.buf, aValue...)
+} // binary()
+
+// `bytes()` returns the encoded structure (after ending it).
+//
+// Returns:
+// - `[]byte`: The encoded data.
+func (tw *tThriftWriter) bytes() []byte {
+	return append(tw.buf, 0)
+} // bytes()
+
+// `end()` ends the structure started by `begin()`.
+func (tw *tThriftWriter) end() {
+	tw.buf = append(tw.buf, 0)
+	tw.last = tw.last[:len(tw.last)-1]
+} // end()
+
+// `field()` writes the header of the field `aID`.
+//
+// Parameters:
+// - `aID`: The field's ID.
+// - `aType`: The field's compact protocol type.
+func (tw *tThriftWriter) field(aID int16, aType byte) {
+	last := &tw.last[len(tw.last)-1]
+	if delta := aID - *last; (0 < delta) && (15 >= delta) {
+		tw.buf = append(tw.buf, byte(delta)<<4|aType)
+	} else {
+		tw.buf = append(tw.buf, aType)
+		tw.buf = appendVarint(tw.buf, int64(aID))
+	}
+	*last = aID
+} // field()
+
+// `i32()` writes the integer field `aID`.
+//
+// Parameters:
+// - `aID`: The field's ID.
+// - `aValue`: The field's value.
+func (tw *tThriftWriter) i32(aID int16, aValue int32) {
+	tw.field(aID, alThriftI32)
+	tw.buf = appendVarint(tw.buf, int64(aValue))
+} // i32()
+
+// `i64()` writes the integer field `aID`.
+//
+// Parameters:
+// - `aID`: The field's ID.
+// - `aValue`: The field's value.
+func (tw *tThriftWriter) i64(aID int16, aValue int64) {
+	tw.field(aID, alThriftI64)
+	tw.buf = appendVarint(tw.buf, aValue)
+} // i64()
+
+// `list()` writes the header of the list field `aID`; the elements
+// have to follow.
+//
+// Parameters:
+// - `aID`: The field's ID.
+// - `aType`: The elements' compact protocol type.
+// - `aSize`: The number of elements.
+func (tw *tThriftWriter) list(aID int16, aType byte, aSize int) {
+	tw.field(aID, alThriftList)
+	if 15 > aSize {
+		tw.buf = append(tw.buf, byte(aSize)<<4|aType)
+		return
+	}
+	tw.buf = append(tw.buf, 0xf0|aType)
+	tw.buf = appendUvarint(tw.buf, uint64(aSize))
+} // list()
+
+/* * * * * * * * * * * * * * * * * * * * * * * * * * * * * * * * * * * * * */
+
+// `parquetPageHeader()` returns the header of a data page.
+//
+// Parameters:
+// - `aRawSize`: The page's uncompressed size.
+// - `aSize`: The page's (compressed) size.
+// - `aValues`: The page's number of values.
+//
+// Returns:
+// - `[]byte`: The encoded page header.
+func parquetPageHeader(aRawSize, aSize, aValues int) []byte {
+	tw := newThriftWriter()
+	tw.i32(1, 0) // DATA_PAGE
+	tw.i32(2, int32(aRawSize))
+	tw.i32(3, int32(aSize))
+	tw.begin(5)
+	tw.i32(1, int32(aValues))
+	tw.i32(2, alParquetPlain)
+	tw.i32(3, alParquetRLE) // no levels for required columns
+	tw.i32(4, alParquetRLE)
+	tw.end()
+
+	return tw.bytes()
+} // parquetPageHeader()
+
+// `footer()` returns the metadata of the current file.
+//
+// Returns:
+// - `[]byte`: The encoded metadata.
+func (ps *tParquetSink) footer() []byte {
+	codec := int32(0) // UNCOMPRESSED
+	if ps.opts.Gzip {
+		codec = alParquetGzip
+	}
+	rows := int64(0)
+	for _, group := range ps.groups {
+		rows += group.rows
+	}
+
+	tw := newThriftWriter()
+	tw.i32(1, 1) // version
+	tw.list(2, alThriftStruct, 1+len(alParquetColumns))
+	tw.begin(0)
+	tw.binary(4, "schema")
+	tw.i32(5, int32(len(alParquetColumns)))
+	tw.end()
+	for _, column := range alParquetColumns {
+		tw.begin(0)
+		tw.i32(1, column.kind)
+		tw.i32(3, 0) // REQUIRED
+		tw.binary(4, column.name)
+		if 0 <= column.converted {
+			tw.i32(6, column.converted)
+		}
+		tw.end()
+	}
+	tw.i64(3, rows)
+	tw.list(4, alThriftStruct, len(ps.groups))
+	for _, group := range ps.groups {
+		tw.begin(0)
+		tw.list(1, alThriftStruct, len(group.chunks))
+		total := int64(0)
+		for idx, chunk := range group.chunks {
+			column := alParquetColumns[idx]
+			total += chunk.rawSize
+			tw.begin(0)
+			tw.i64(2, chunk.offset)
+			tw.begin(3)
+			tw.i32(1, column.kind)
+			tw.list(2, alThriftI32, 1)
+			tw.buf = appendVarint(tw.buf, alParquetPlain)
+			tw.list(3, alThriftBinary, 1)
+			tw.buf = appendUvarint(tw.buf, uint64(len(column.name)))
+			tw.buf = append(tw.buf, column.name...)
+			tw.i32(4, codec)
+			tw.i64(5, group.rows)
+			tw.i64(6, chunk.rawSize)
+			tw.i64(7, chunk.size)
+			tw.i64(9, chunk.offset)
+			tw.end()
+			tw.end()
+		}
+		tw.i64(2, total)
+		tw.i64(3, group.rows)
+		tw.end()
+	}
+	tw.binary(6, "mwat56/apachelogger")
+
+	return tw.bytes()
+} // footer()
+
+// `finish()` writes the metadata of the current file and closes it.
+//
+// The row groups are kept, so the file can be continued by further
+// row groups (overwriting the metadata) during the same hour.
+//
+// Returns:
+// - `error`: A possible error writing the file.
+func (ps *tParquetSink) finish() error {
+	if nil == ps.file {
+		return nil
+	}
+	footer := ps.footer()
+	footer = appendParquetInt32(footer, int32(len(footer)))
+	footer = append(footer, alParquetMagic...)
+	_, err := ps.file.WriteAt(footer, ps.end)
+	if nil == err {
+		err = ps.file.Truncate(ps.end + int64(len(footer)))
+	}
+	if cErr := ps.file.Close(); nil == err {
+		err = cErr
+	}
+	ps.file = nil
+
+	return err
+} // finish()
+
+// `open()` opens the file of the current hour, creating it if needed.
+//
+// Returns:
+// - `error`: A possible error opening the file.
+func (ps *tParquetSink) open() error {
+	if nil != ps.file {
+		return nil
+	}
+	if "" != ps.name { // continue the file of this hour
+		file, err := os.OpenFile(ps.name, os.O_WRONLY, 0640) // #nosec G302
+		if nil == err {
+			ps.file = file
+			return nil
+		}
+		ps.name, ps.groups = "", nil
+	}
+
+	base := filepath.Join(ps.opts.Directory, ps.opts.Prefix+"-"+ps.hour)
+	name := base + ".parquet"
+	for idx := 1; ; idx++ {
+		file, err := os.OpenFile(name, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0640) // #nosec G302
+		if nil == err {
+			if _, err = file.WriteAt([]byte(alParquetMagic), 0); nil != err {
+				_ = file.Close()
+				return err
+			}
+			ps.file, ps.name, ps.end, ps.groups = file, name, int64(len(alParquetMagic)), nil
+			return nil
+		}
+		if !os.IsExist(err) {
+			return err
+		}
+		// a file of an earlier run: don't touch it
+		name = base + "." + strconv.Itoa(idx) + ".parquet"
+	}
+} // open()
+
+// `rotate()` finishes the current file if the hour changed.
+//
+// Parameters:
+// - `aNow`: The current time.
+//
+// Returns:
+// - `error`: A possible error finishing the file.
+func (ps *tParquetSink) rotate(aNow time.Time) error {
+	hour := inLocation(aNow).Format("2006-01-02T15")
+	if hour == ps.hour {
+		return nil
+	}
+	err := ps.writeRowGroup()
+	if fErr := ps.finish(); nil == err {
+		err = fErr
+	}
+	ps.hour, ps.name, ps.end, ps.groups = hour, "", 0, nil
+
+	return err
+} // rotate()
+
+// `writeRowGroup()` writes the pending entries as a row group.
+//
+// Returns:
+// - `error`: A possible error writing the file.
+func (ps *tParquetSink) writeRowGroup() error {
+	if 0 == len(ps.pending) {
+		return nil
+	}
+	if err := ps.open(); nil != err {
+		return ps.keepPending(err)
+	}
+
+	var (
+		buf   []byte
+		data  []byte
+		group = tParquetRowGroup{rows: int64(len(ps.pending))}
+	)
+	for _, column := range alParquetColumns {
+		data = data[:0]
+		for _, entry := range ps.pending {
+			data = column.value(data, entry)
+		}
+		page, rawSize := data, len(data)
+		if ps.opts.Gzip {
+			var zb bytes.Buffer
+			zw := gzip.NewWriter(&zb)
+			_, _ = zw.Write(data)
+			_ = zw.Close()
+			page = zb.Bytes()
+		}
+		header := parquetPageHeader(rawSize, len(page), len(ps.pending))
+		group.chunks = append(group.chunks, tParquetChunk{
+			offset:  ps.end + int64(len(buf)),
+			size:    int64(len(header) + len(page)),
+			rawSize: int64(len(header) + rawSize),
+		})
+		buf = append(append(buf, header...), page...)
+	}
+	if _, err := ps.file.WriteAt(buf, ps.end); nil != err {
+		return ps.keepPending(err)
+	}
+	ps.end += int64(len(buf))
+	ps.groups = append(ps.groups, group)
+	ps.pending = ps.pending[:0]
+
+	return nil
+} // writeRowGroup()
+
+// `keepPending()` limits the entries kept after a failed write.
+//
+// Parameters:
+// - `aErr`: The write error.
+//
+// Returns:
+// - `error`: The write error.
+func (ps *tParquetSink) keepPending(aErr error) error {
+	if limit := 4 * ps.opts.RowGroupSize; limit < len(ps.pending) {
+		ps.pending = append(ps.pending[:0], ps.pending[len(ps.pending)-limit:]...)
+	}
+
+	return fmt.Errorf("apachelogger: Parquet file: %w", aErr)
+} // keepPending()
+
+/* * * * * * * * * * * * * * * * * * * * * * * * * * * * * * * * * * * * * */
+
+// `Close()` writes the pending entries and completes the current file.
+//
+// Part of the `TSink` interface.
+//
+// Returns:
+// - `error`: A possible error writing the file.
+func (ps *tParquetSink) Close() error {
+	err := ps.writeRowGroup()
+	if fErr := ps.finish(); nil == err {
+		err = fErr
+	}
+
+	return err
+} // Close()
+
+// `Flush()` writes the pending entries as a row group once the flush
+// delay has elapsed.
+//
+// Part of the `TSink` interface.
+//
+// Returns:
+// - `error`: A possible error writing the file.
+func (ps *tParquetSink) Flush() error {
+	if (0 == len(ps.pending)) || (ps.opts.FlushDelay > time.Since(ps.since)) {
+		return nil
+	}
+
+	return ps.writeRowGroup()
+} // Flush()
+
+// `Write()` decodes the binary records in `aData` (see `BinaryRecord()`)
+// and collects their entries for the current file.
+//
+// Part of the `TSink` interface.
+//
+// Parameters:
+// - `aData`: The binary records to write.
+//
+// Returns:
+// - `error`: A possible error decoding the records or writing the file.
+func (ps *tParquetSink) Write(aData []byte) error {
+	err := ps.rotate(time.Now())
+	ps.rest = append(ps.rest, aData...)
+	data := ps.rest
+	for 0 < len(data) {
+		size, n := binary.Uvarint(data)
+		if 0 == n {
+			break // incomplete length
+		}
+		if (0 > n) || (alBinaryMaxRecord < size) {
+			data, err = nil, errBinaryRecord
+			break
+		}
+		if uint64(len(data)-n) < size {
+			break // incomplete record
+		}
+		entry := &TEntry{}
+		if dErr := entry.UnmarshalBinary(data[n : n+int(size)]); nil != dErr {
+			err = dErr
+		} else {
+			if 0 == len(ps.pending) {
+				ps.since = time.Now()
+			}
+			ps.pending = append(ps.pending, entry)
+		}
+		data = data[n+int(size):]
+	}
+	ps.rest = append(ps.rest[:0], data...)
+
+	if ps.opts.RowGroupSize <= len(ps.pending) {
+		if wErr := ps.writeRowGroup(); nil == err {
+			err = wErr
+		}
+	}
+
+	return err
+} // Write()
+
+// `NewParquetSink()` returns a sink writing log entries to hourly
+// Parquet files, so the access logs can be queried by e.g. DuckDB,
+// Athena, or Spark without a separate ETL step.
+//
+// The sink takes binary records (see `BinaryLog`), so it's typically
+// used as an access output with the `BinaryRecord()` formatter. The
+// entries are collected and written as a row group of `RowGroupSize`
+// entries or whenever the oldest entry waits longer than `FlushDelay`;
+// a file is readable once it's completed, which happens at the end of
+// each hour and whenever there was nothing to log for some seconds
+// (further entries of the same hour are appended then). Files of an
+// earlier run are never overwritten; a numbered file is written
+// instead (e.g. `access-2024-04-25T20.1.parquet`).
+//
+// The columns are `time` (a UTC timestamp in microseconds), `remote`,
+// `user`, `method`, `path`, `proto`, `status`, `size`, `referrer`,
+// `agent`, `duration_us`, and `fields` (the entry's additional fields
+// as a JSON object), e.g.
+//
+//	SELECT path, count(*) FROM 'logs/access-*.parquet' GROUP BY path;
+//
+// Example:
+//
+//	sink := apachelogger.NewParquetSink(apachelogger.TParquetOptions{
+//		Directory: "/var/log/parquet",
+//	})
+//	_ = apachelogger.AddAccessOutput(apachelogger.BinaryRecord, sink)
+//
+// Parameters:
+// - `aOptions`: The sink's configuration.
+//
+// Returns:
+// - `TSink`: The sink to use with `AddAccessOutput()`.
+func NewParquetSink(aOptions TParquetOptions) TSink {
+	if "" == aOptions.Prefix {
+		aOptions.Prefix = "access"
+	}
+	if 0 >= aOptions.RowGroupSize {
+		aOptions.RowGroupSize = 10000
+	}
+	if 0 >= aOptions.FlushDelay {
+		aOptions.FlushDelay = time.Minute
+	}
+
+	return &tParquetSink{opts: aOptions}
+} // NewParquetSink()
+
+/* _EoF_ */
@@ -0,0 +1,253 @@
+/*
+Copyright © 2024  M.Watermann, 10247 Berlin, Germany
+
+	    All rights reserved
+	EMail : <support@mwat.de>
+*/
+package apachelogger
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/binary"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"testing"
+	"time"
+)
+
+//lint:file-ignore ST1017 – I prefer Yoda conditions
+
+type (
+	// `tThriftReader` decodes Thrift's compact protocol.
+	tThriftReader struct {
+		data []byte
+	}
+
+	// `tThriftStruct` is a decoded structure (field ID => value).
+	tThriftStruct map[int16]interface{}
+)
+
+func (tr *tThriftReader) uvarint() uint64 {
+	value, n := binary.Uvarint(tr.data)
+	if 0 >= n {
+		panic("malformed varint")
+	}
+	tr.data = tr.data[n:]
+	return value
+} // uvarint()
+
+func (tr *tThriftReader) value(aType byte) interface{} {
+	switch aType {
+	case 1:
+		return true
+	case 2:
+		return false
+	case 3, 4, 5, 6: // byte, i16, i32, i64
+		value, n := binary.Varint(tr.data)
+		tr.data = tr.data[n:]
+		return value
+	case 8:
+		size := tr.uvarint()
+		value := string(tr.data[:size])
+		tr.data = tr.data[size:]
+		return value
+	case 9:
+		header := tr.data[0]
+		tr.data = tr.data[1:]
+		size := uint64(header >> 4)
+		if 15 == size {
+			size = tr.uvarint()
+		}
+		list := make([]interface{}, size)
+		for idx := range list {
+			list[idx] = tr.value(header & 0x0f)
+		}
+		return list
+	case 12:
+		return tr.structure()
+	}
+	panic("unsupported type")
+} // value()
+
+func (tr *tThriftReader) structure() tThriftStruct {
+	result := tThriftStruct{}
+	last := int16(0)
+	for {
+		header := tr.data[0]
+		tr.data = tr.data[1:]
+		if 0 == header {
+			return result
+		}
+		if delta := int16(header >> 4); 0 < delta {
+			last += delta
+		} else {
+			value, n := binary.Varint(tr.data)
+			tr.data = tr.data[n:]
+			last = int16(value)
+		}
+		result[last] = tr.value(header & 0x0f)
+	}
+} // structure()
+
+// `readParquet()` returns the metadata and the columns' values of
+// the Parquet file `aFilename`.
+func readParquet(t *testing.T, aFilename string) (tThriftStruct, map[string][]interface{}) {
+	t.Helper()
+	data, err := os.ReadFile(aFilename) // #nosec G304
+	if nil != err {
+		t.Fatal(err)
+	}
+	if (12 > len(data)) || ("PAR1" != string(data[:4])) || ("PAR1" != string(data[len(data)-4:])) {
+		t.Fatalf("%s: missing magic bytes", aFilename)
+	}
+	size := int(binary.LittleEndian.Uint32(data[len(data)-8:]))
+	meta := (&tThriftReader{data: data[len(data)-8-size : len(data)-8]}).structure()
+
+	values := make(map[string][]interface{})
+	for _, group := range meta[4].([]interface{}) {
+		for _, chunk := range group.(tThriftStruct)[1].([]interface{}) {
+			cmd := chunk.(tThriftStruct)[3].(tThriftStruct)
+			name := cmd[3].([]interface{})[0].(string)
+			tr := &tThriftReader{data: data[cmd[9].(int64):]}
+			header := tr.structure()
+			page := tr.data[:header[3].(int64)]
+			if 2 == cmd[4].(int64) { // gzip
+				zr, err := gzip.NewReader(bytes.NewReader(page))
+				if nil != err {
+					t.Fatal(err)
+				}
+				if page, err = io.ReadAll(zr); nil != err {
+					t.Fatal(err)
+				}
+			}
+			if int64(len(page)) != header[2].(int64) {
+				t.Errorf("%s: page size %d, want %d", name, len(page), header[2])
+			}
+			count := header[5].(tThriftStruct)[1].(int64)
+			for ; 0 < count; count-- {
+				switch cmd[1].(int64) {
+				case alParquetInt32:
+					values[name] = append(values[name], int32(binary.LittleEndian.Uint32(page)))
+					page = page[4:]
+				case alParquetInt64:
+					values[name] = append(values[name], int64(binary.LittleEndian.Uint64(page)))
+					page = page[8:]
+				default:
+					size := binary.LittleEndian.Uint32(page)
+					values[name] = append(values[name], string(page[4:4+size]))
+					page = page[4+size:]
+				}
+			}
+		}
+	}
+
+	return meta, values
+} // readParquet()
+
+func TestNewParquetSink(t *testing.T) {
+	for _, compress := range []bool{false, true} {
+		dir := t.TempDir()
+		sink := NewParquetSink(TParquetOptions{Directory: dir, RowGroupSize: 2, Gzip: compress})
+		e1, e2, e3 := prepEntry(), prepEntry(), prepEntry()
+		e2.Path, e2.Status = "/two", 404
+		e3.Path, e3.Fields = "/three", map[string]string{"trace": "abc"}
+		record := BinaryRecord(e2)
+
+		// a record split across writes is put together again:
+		if err := sink.Write([]byte(BinaryRecord(e1) + record[:5])); nil != err {
+			t.Fatalf("Write() error = %v", err)
+		}
+		if err := sink.Write([]byte(record[5:] + BinaryRecord(e3))); nil != err {
+			t.Fatalf("Write() error = %v", err)
+		}
+		if err := sink.Close(); nil != err {
+			t.Fatalf("Close() error = %v", err)
+		}
+		// further entries of the same hour continue the file:
+		if err := sink.Write([]byte(BinaryRecord(e1))); nil != err {
+			t.Fatalf("Write() error = %v", err)
+		}
+		if err := sink.Close(); nil != err {
+			t.Fatalf("Close() error = %v", err)
+		}
+
+		files, _ := filepath.Glob(filepath.Join(dir, "access-*.parquet"))
+		if 1 != len(files) {
+			t.Fatalf("files = %q, want one", files)
+		}
+		meta, values := readParquet(t, files[0])
+		if rows := meta[3].(int64); 4 != rows {
+			t.Errorf("num_rows = %d, want 4", rows)
+		}
+		if groups := len(meta[4].([]interface{})); 2 != groups {
+			t.Errorf("row groups = %d, want 2", groups)
+		}
+		var names []string
+		for _, element := range meta[2].([]interface{})[1:] {
+			names = append(names, element.(tThriftStruct)[4].(string))
+		}
+		if len(alParquetColumns) != len(names) {
+			t.Errorf("schema = %q", names)
+		}
+		if got := values["path"]; (4 != len(got)) || (e1.Path != got[0]) ||
+			("/two" != got[1]) || ("/three" != got[2]) || (e1.Path != got[3]) {
+			t.Errorf("path = %q", got)
+		}
+		if got := values["status"]; (4 != len(got)) || (int32(404) != got[1]) {
+			t.Errorf("status = %v", got)
+		}
+		if got := values["time"]; (4 != len(got)) || (e1.When.UnixNano()/1000 != got[0]) {
+			t.Errorf("time = %v", got)
+		}
+		if got := values["fields"]; (4 != len(got)) || ("{}" != got[0]) ||
+			(`{"trace":"abc"}` != got[2]) {
+			t.Errorf("fields = %q", got)
+		}
+	}
+} // TestNewParquetSink()
+
+func TestNewParquetSink_existing(t *testing.T) {
+	dir := t.TempDir()
+	for run := 0; 2 > run; run++ {
+		sink := NewParquetSink(TParquetOptions{Directory: dir, Prefix: "web"})
+		if err := sink.Write([]byte(BinaryRecord(prepEntry()))); nil != err {
+			t.Fatalf("Write() error = %v", err)
+		}
+		if err := sink.Flush(); nil != err { // the delay hasn't elapsed
+			t.Fatalf("Flush() error = %v", err)
+		}
+		if err := sink.Close(); nil != err {
+			t.Fatalf("Close() error = %v", err)
+		}
+	}
+
+	files, _ := filepath.Glob(filepath.Join(dir, "web-*.parquet"))
+	sort.Strings(files)
+	if 2 != len(files) {
+		t.Fatalf("files = %q, want two", files)
+	}
+	hour := inLocation(time.Now()).Format("2006-01-02T15")
+	if want := filepath.Join(dir, "web-"+hour+".1.parquet"); want != files[0] {
+		t.Errorf("second file = %q, want %q", files[0], want)
+	}
+	for _, name := range files {
+		if meta, _ := readParquet(t, name); 1 != meta[3].(int64) {
+			t.Errorf("%s: num_rows = %d, want 1", name, meta[3])
+		}
+	}
+
+	if err := NewParquetSink(TParquetOptions{Directory: dir}).Write([]byte{0x05, 0x02}); nil != err {
+		t.Errorf("Write() error = %v for an incomplete record", err)
+	}
+	if err := NewParquetSink(TParquetOptions{Directory: dir}).Write([]byte("\x01x")); nil == err {
+		t.Error("Write() error = nil for a malformed record")
+	}
+	if err := NewParquetSink(TParquetOptions{Directory: filepath.Join(dir, "missing")}).(tValidator).validate(); nil == err {
+		t.Error("validate() error = nil for a missing directory")
+	}
+} // TestNewParquetSink_existing()
+
+/* _EoF_ */
@@ -0,0 +1,439 @@
+/*
+Copyright © 2024  M.Watermann, 10247 Berlin, Germany
+
+	    All rights reserved
+	EMail : <support@mwat.de>
+*/
+package apachelogger
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+)
+
+//lint:file-ignore ST1017 – I prefer Yoda conditions
+
+type (
+	// `TTextReader` reads the entries of a textual access log.
+	TTextReader struct {
+		scanner   *bufio.Scanner // the source of lines
+		line      string         // the line of the last entry read
+		malformed int            // number of lines skipped
+	}
+)
+
+const (
+	// Max. length of a single access log line read.
+	alMaxParsedLine = 1 << 20
+
+	// The time layout of Apache's logfiles.
+	alApacheTime = "02/Jan/2006:15:04:05 -0700"
+)
+
+var (
+	// The error returned for lines not holding an access log entry.
+	errLogLine = errors.New("apachelogger: malformed access log line")
+)
+
+// `parseCanonical()` parses a canonical log line (see `Canonical()`).
+//
+// Parameters:
+// - `aLine`: The line without the `canonical-log-line` prefix.
+//
+// Returns:
+// - `*TEntry`: The parsed entry.
+// - `error`: A possible parsing error.
+func parseCanonical(aLine string) (*TEntry, error) {
+	values, err := parseKeyValues(aLine)
+	if nil != err {
+		return nil, err
+	}
+	result := &TEntry{}
+	for key, value := range values {
+		if err = result.setParsed(key, value); nil != err {
+			return nil, err
+		}
+	}
+	if result.When.IsZero() || ("" == result.Method) {
+		return nil, errLogLine
+	}
+
+	return result, nil
+} // parseCanonical()
+
+// `parseCombined()` parses a line in Common or Combined Log Format,
+// possibly followed by additional ` key=value` fields.
+//
+// Parameters:
+// - `aLine`: The line to parse.
+//
+// Returns:
+// - `*TEntry`: The parsed entry.
+// - `error`: A possible parsing error.
+func parseCombined(aLine string) (*TEntry, error) {
+	var (
+		err    error
+		result = &TEntry{}
+		rest   = aLine
+		word   = func() string { // the next space separated word
+			idx := strings.IndexByte(rest, ' ')
+			if 0 > idx {
+				idx = len(rest)
+			}
+			text := rest[:idx]
+			rest = strings.TrimLeft(rest[idx:], " ")
+			return text
+		}
+	)
+
+	result.Remote = word()
+	_ = word() // the client's identity, always `-`
+	result.User = word()
+
+	// `[02/Jan/2006:15:04:05 -0700]`
+	if !strings.HasPrefix(rest, "[") {
+		return nil, errLogLine
+	}
+	end := strings.IndexByte(rest, ']')
+	if 0 > end {
+		return nil, errLogLine
+	}
+	if result.When, err = time.Parse(alApacheTime, rest[1:end]); nil != err {
+		return nil, errLogLine
+	}
+	rest = strings.TrimLeft(rest[end+1:], " ")
+
+	// `"GET /path HTTP/1.1"`
+	request, tail, ok := unquoteLogField(rest)
+	if !ok {
+		return nil, errLogLine
+	}
+	result.Method, result.Path, result.Proto = splitRequest(request)
+	rest = strings.TrimLeft(tail, " ")
+
+	if result.Status, err = strconv.Atoi(word()); nil != err {
+		return nil, errLogLine
+	}
+	if size := word(); "-" != size {
+		if result.Size, err = strconv.Atoi(size); nil != err {
+			return nil, errLogLine
+		}
+	}
+
+	// optional `"referrer" "agent"`:
+	if strings.HasPrefix(rest, `"`) {
+		if result.Referrer, rest, ok = unquoteLogField(rest); !ok {
+			return nil, errLogLine
+		}
+		if result.Agent, rest, ok = unquoteLogField(strings.TrimLeft(rest, " ")); !ok {
+			return nil, errLogLine
+		}
+	}
+	if rest = strings.TrimSpace(rest); "" != rest {
+		if result.Fields, err = parseKeyValues(rest); nil != err {
+			return nil, err
+		}
+	}
+
+	return result, nil
+} // parseCombined()
+
+// `parseJSON()` parses an access log entry encoded as a JSON object.
+//
+// Besides the names used by `Canonical()` the usual names of other
+// servers (e.g. `remote_addr`, `request`, `body_bytes_sent`, or
+// `http_user_agent`) are accepted; unknown members become additional
+// fields.
+//
+// Parameters:
+// - `aLine`: The JSON object.
+//
+// Returns:
+// - `*TEntry`: The parsed entry.
+// - `error`: A possible parsing error.
+func parseJSON(aLine string) (*TEntry, error) {
+	var values map[string]interface{}
+	decoder := json.NewDecoder(strings.NewReader(aLine))
+	decoder.UseNumber()
+	if err := decoder.Decode(&values); nil != err {
+		return nil, fmt.Errorf("%w: %v", errLogLine, err)
+	}
+	result := &TEntry{}
+	for key, value := range values {
+		if err := result.setParsed(key, fieldValue(value)); nil != err {
+			return nil, err
+		}
+	}
+	if result.When.IsZero() {
+		return nil, errLogLine
+	}
+
+	return result, nil
+} // parseJSON()
+
+// `parseKeyValues()` parses a list of ` key=value` pairs as written by
+// `appendFields()`.
+//
+// Parameters:
+// - `aText`: The pairs to parse.
+//
+// Returns:
+// - `map[string]string`: The parsed pairs.
+// - `error`: A possible parsing error.
+func parseKeyValues(aText string) (map[string]string, error) {
+	result := make(map[string]string)
+	for rest := strings.TrimSpace(aText); "" != rest; rest = strings.TrimLeft(rest, " ") {
+		idx := strings.IndexByte(rest, '=')
+		if 0 >= idx || strings.ContainsRune(rest[:idx], ' ') {
+			return nil, errLogLine
+		}
+		key := rest[:idx]
+		rest = rest[idx+1:]
+		if strings.HasPrefix(rest, `"`) {
+			quoted, err := strconv.QuotedPrefix(rest)
+			if nil != err {
+				return nil, errLogLine
+			}
+			result[key], _ = strconv.Unquote(quoted)
+			rest = rest[len(quoted):]
+			continue
+		}
+		if idx = strings.IndexByte(rest, ' '); 0 > idx {
+			idx = len(rest)
+		}
+		result[key] = rest[:idx]
+		rest = rest[idx:]
+	}
+
+	return result, nil
+} // parseKeyValues()
+
+// `parseTime()` parses a timestamp in RFC 3339 or Apache format, or as
+// seconds since the epoch.
+//
+// Parameters:
+// - `aText`: The timestamp to parse.
+//
+// Returns:
+// - `time.Time`: The parsed time.
+// - `error`: A possible parsing error.
+func parseTime(aText string) (time.Time, error) {
+	if result, err := time.Parse(time.RFC3339Nano, aText); nil == err {
+		return result, nil
+	}
+	if result, err := time.Parse(alApacheTime, strings.Trim(aText, "[]")); nil == err {
+		return result, nil
+	}
+	if seconds, err := strconv.ParseFloat(aText, 64); nil == err {
+		return time.Unix(0, int64(seconds*float64(time.Second))), nil
+	}
+
+	return time.Time{}, errLogLine
+} // parseTime()
+
+// `setParsed()` sets the entry's member named `aKey` to `aValue`.
+//
+// Unknown names are stored as additional fields.
+//
+// Parameters:
+// - `aKey`: The name of the member.
+// - `aValue`: The member's textual value.
+//
+// Returns:
+// - `error`: A possible error parsing `aValue`.
+func (e *TEntry) setParsed(aKey, aValue string) (rErr error) {
+	switch strings.ToLower(aKey) {
+	case "remote", "remote_addr", "client", "ip":
+		e.Remote = aValue
+	case "user", "remote_user":
+		e.User = aValue
+	case "method", "request_method":
+		e.Method = aValue
+	case "path", "uri", "request_uri", "url":
+		e.Path = aValue
+	case "proto", "protocol", "server_protocol":
+		e.Proto = aValue
+	case "request":
+		e.Method, e.Path, e.Proto = splitRequest(aValue)
+	case "referrer", "referer", "http_referer":
+		e.Referrer = aValue
+	case "agent", "user_agent", "http_user_agent":
+		e.Agent = aValue
+	case "time", "timestamp", "@timestamp", "time_local", "time_iso8601":
+		e.When, rErr = parseTime(aValue)
+	case "status":
+		e.Status, rErr = strconv.Atoi(aValue)
+	case "size", "bytes", "body_bytes_sent", "bytes_sent":
+		if "-" != aValue {
+			e.Size, rErr = strconv.Atoi(aValue)
+		}
+	case "duration_us":
+		var us int64
+		us, rErr = strconv.ParseInt(aValue, 10, 64)
+		e.Duration = time.Duration(us) * time.Microsecond
+	case "request_time": // seconds
+		var seconds float64
+		seconds, rErr = strconv.ParseFloat(aValue, 64)
+		e.Duration = time.Duration(seconds * float64(time.Second))
+	default:
+		e.SetField(aKey, aValue)
+	}
+	if nil != rErr {
+		rErr = fmt.Errorf("%w: invalid %s %q", errLogLine, aKey, aValue)
+	}
+
+	return
+} // setParsed()
+
+// `splitRequest()` splits a request line like `GET /path HTTP/1.1`.
+//
+// Parameters:
+// - `aRequest`: The request line.
+//
+// Returns:
+// - `string`: The request's method.
+// - `string`: The requested path.
+// - `string`: The request's protocol.
+func splitRequest(aRequest string) (rMethod, rPath, rProto string) {
+	parts := strings.Fields(aRequest)
+	switch len(parts) {
+	case 0:
+		return
+	case 1:
+		return parts[0], "", ""
+	case 2:
+		return parts[0], parts[1], ""
+	}
+	last := len(parts) - 1
+
+	return parts[0], strings.Join(parts[1:last], " "), parts[last]
+} // splitRequest()
+
+// `unquoteLogField()` returns the leading `"…"` field of `aText` with
+// `\"` and `\\` unescaped.
+//
+// Parameters:
+// - `aText`: The text starting with a quoted field.
+//
+// Returns:
+// - `string`: The field's value.
+// - `string`: The text following the field.
+// - `bool`: `false` if `aText` doesn't start with a complete field.
+func unquoteLogField(aText string) (string, string, bool) {
+	if !strings.HasPrefix(aText, `"`) {
+		return "", aText, false
+	}
+	var sb strings.Builder
+	for idx := 1; idx < len(aText); idx++ {
+		switch ch := aText[idx]; ch {
+		case '"':
+			return sb.String(), aText[idx+1:], true
+		case '\\':
+			if idx+1 < len(aText) {
+				idx++
+				ch = aText[idx]
+			}
+			sb.WriteByte(ch)
+		default:
+			sb.WriteByte(ch)
+		}
+	}
+
+	return "", aText, false
+} // unquoteLogField()
+
+/* * * * * * * * * * * * * * * * * * * * * * * * * * * * * * * * * * * * * */
+
+// `ParseLine()` parses a single access log line.
+//
+// Supported are the Common and Combined Log Format (as written by
+// default, possibly with additional ` key=value` fields), canonical log
+// lines (see `CanonicalLogLine`), and JSON objects (one per line).
+//
+// Parameters:
+// - `aLine`: The line to parse.
+//
+// Returns:
+// - `*TEntry`: The parsed entry.
+// - `error`: A possible parsing error.
+func ParseLine(aLine string) (*TEntry, error) {
+	aLine = strings.TrimSpace(aLine)
+	switch {
+	case "" == aLine:
+		return nil, errLogLine
+	case strings.HasPrefix(aLine, "{"):
+		return parseJSON(aLine)
+	case strings.HasPrefix(aLine, "canonical-log-line "):
+		return parseCanonical(aLine[len("canonical-log-line "):])
+	}
+
+	return parseCombined(aLine)
+} // ParseLine()
+
+// `NewTextReader()` returns a reader of textual access logs.
+//
+// Parameters:
+// - `aReader`: The source of log lines (e.g. a logfile).
+//
+// Returns:
+// - `*TTextReader`: The reader of log entries.
+func NewTextReader(aReader io.Reader) *TTextReader {
+	scanner := bufio.NewScanner(aReader)
+	scanner.Buffer(make([]byte, 0, 4096), alMaxParsedLine)
+
+	return &TTextReader{scanner: scanner}
+} // NewTextReader()
+
+// `Line()` returns the text of the line holding the last entry
+// returned by `Next()`.
+//
+// Returns:
+// - `string`: The entry's line (without surrounding whitespace).
+func (tr *TTextReader) Line() string {
+	return tr.line
+} // Line()
+
+// `Malformed()` returns the number of lines skipped by `Next()`
+// because they couldn't be parsed.
+//
+// Returns:
+// - `int`: The number of malformed lines.
+func (tr *TTextReader) Malformed() int {
+	return tr.malformed
+} // Malformed()
+
+// `Next()` reads the next log entry.
+//
+// Empty lines (e.g. the day separators) and comments (e.g. W3C
+// directives) are skipped, as are lines which can't be parsed (see
+// `Malformed()`).
+//
+// Returns:
+// - `*TEntry`: The log entry read.
+// - `error`: `io.EOF` at the end of input, or a reading error.
+func (tr *TTextReader) Next() (*TEntry, error) {
+	for tr.scanner.Scan() {
+		line := strings.TrimSpace(tr.scanner.Text())
+		if ("" == line) || strings.HasPrefix(line, "#") {
+			continue
+		}
+		if entry, err := ParseLine(line); nil == err {
+			tr.line = line
+			return entry, nil
+		}
+		tr.malformed++
+	}
+	if err := tr.scanner.Err(); nil != err {
+		return nil, err
+	}
+
+	return nil, io.EOF
+} // Next()
+
+/* _EoF_ */
@@ -0,0 +1,112 @@
+/*
+Copyright © 2024  M.Watermann, 10247 Berlin, Germany
+
+	    All rights reserved
+	EMail : <support@mwat.de>
+*/
+package apachelogger
+
+import (
+	"errors"
+	"io"
+	"strings"
+	"testing"
+	"time"
+)
+
+//lint:file-ignore ST1017 – I prefer Yoda conditions
+
+func TestParseLine(t *testing.T) {
+	e1 := prepEntry()
+	e2 := prepEntry()
+	e2.SetField("user_id", "42")
+	e2.SetField("note", "two words")
+	e2.Duration = 1500 * time.Microsecond
+
+	tests := []struct {
+		name    string
+		line    string
+		want    string
+		wantErr bool
+	}{
+		{"combined", e1.String(), e1.String(), false},
+		{"fields", strings.TrimSuffix(e2.String(), "\n") + ` note="two words" user_id=42`, e1.String(), false},
+		{"canonical", e2.Canonical(), e1.String(), false},
+		{"common", `192.168.1.0 - - [25/Apr/2024:20:16:45 +0200] "GET /path/to/file?lang=en HTTP/1.1" 200 -`,
+			`192.168.1.0 - - [25/Apr/2024:20:16:45 +0200] "GET /path/to/file?lang=en HTTP/1.1" 200 0 "" ""` + "\n", false},
+		{"json", `{"remote_addr":"192.168.1.0","remote_user":"-","time":"2024-04-25T20:16:45+02:00","request":"GET /path/to/file?lang=en HTTP/1.1","status":200,"body_bytes_sent":27155,"http_referer":"-","http_user_agent":"Mozilla/5.0"}`,
+			e1.String(), false},
+		{"empty", "", "", true},
+		{"garbage", "not a log line", "", true},
+		{"bad status", `1.2.3.4 - - [25/Apr/2024:20:16:45 +0200] "GET / HTTP/1.1" ok 0`, "", true},
+		{"bad json", `{"status":`, "", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseLine(tt.line)
+			if (nil != err) != tt.wantErr {
+				t.Fatalf("ParseLine() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if nil != err {
+				if !errors.Is(err, errLogLine) {
+					t.Errorf("ParseLine() error = %v, want %v", err, errLogLine)
+				}
+				return
+			}
+			if s := got.String(); tt.want != s {
+				t.Errorf("ParseLine() = %q\nwant %q", s, tt.want)
+			}
+		})
+	}
+
+	got, _ := ParseLine(e2.Canonical())
+	if ("42" != got.Fields["user_id"]) || ("two words" != got.Fields["note"]) ||
+		(e2.Duration != got.Duration) {
+		t.Errorf("ParseLine() fields = %v, duration = %v", got.Fields, got.Duration)
+	}
+} // TestParseLine()
+
+func TestTTextReader_Next(t *testing.T) {
+	e := prepEntry()
+	input := "#Version: 1.0\n" + e.String() + "\n" + "garbage\n" + e.String()
+	tr := NewTextReader(strings.NewReader(input))
+
+	for idx := 0; 2 > idx; idx++ {
+		entry, err := tr.Next()
+		if nil != err {
+			t.Fatalf("Next() #%d error = %v", idx, err)
+		}
+		if e.Path != entry.Path {
+			t.Errorf("Next() #%d path = %q, want %q", idx, entry.Path, e.Path)
+		}
+		if line := tr.Line(); strings.TrimSpace(e.String()) != line {
+			t.Errorf("Line() #%d = %q, want %q", idx, line, e.String())
+		}
+	}
+	if _, err := tr.Next(); io.EOF != err {
+		t.Errorf("Next() error = %v, want io.EOF", err)
+	}
+	if got := tr.Malformed(); 1 != got {
+		t.Errorf("Malformed() = %d, want 1", got)
+	}
+} // TestTTextReader_Next()
+
+func Test_splitRequest(t *testing.T) {
+	tests := []struct {
+		request, method, path, proto string
+	}{
+		{"GET / HTTP/1.1", "GET", "/", "HTTP/1.1"},
+		{"GET /a b HTTP/1.0", "GET", "/a b", "HTTP/1.0"},
+		{"GET /", "GET", "/", ""},
+		{"-", "-", "", ""},
+		{"", "", "", ""},
+	}
+	for _, tt := range tests {
+		m, p, pr := splitRequest(tt.request)
+		if (tt.method != m) || (tt.path != p) || (tt.proto != pr) {
+			t.Errorf("splitRequest(%q) = %q, %q, %q", tt.request, m, p, pr)
+		}
+	}
+} // Test_splitRequest()
+
+/* _EoF_ */
@@ -0,0 +1,147 @@
+/*
+Copyright © 2024  M.Watermann, 10247 Berlin, Germany
+
+	    All rights reserved
+	EMail : <support@mwat.de>
+*/
+package apachelogger
+
+import (
+	"regexp"
+	"strings"
+	"sync"
+)
+
+//lint:file-ignore ST1017 – I prefer Yoda conditions
+
+type (
+	// `tPathTemplate` is a single path templating rule.
+	tPathTemplate struct {
+		pattern     *regexp.Regexp // the path part to replace
+		replacement string         // the placeholder text
+	}
+)
+
+var (
+	// `CollapsePathIDs` decides whether to replace path segments looking
+	// like IDs by placeholders: numbers by `:id`, UUIDs by `:uuid`, and
+	// long hexadecimal strings (e.g. hashes) by `:hash` (default: `false`).
+	CollapsePathIDs = false
+
+	// `KeepRawPath` decides whether to keep the original path in the
+	// field `raw_path` if path templating changed it (default: `false`).
+	KeepRawPath = false
+
+	// List of registered path templating rules.
+	alPathTemplates []tPathTemplate
+
+	// Guard for concurrent access to `alPathTemplates`.
+	alPathTemplatesMtx sync.RWMutex
+
+	// RegEx to match UUIDs:
+	alUUIDRE = regexp.MustCompile(`^[0-9a-fA-F]{8}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{12}$`)
+)
+
+// `AddPathTemplate()` registers a rule replacing all matches of
+// `aPattern` in the logged request paths by `aReplacement`.
+//
+// The rules are applied to the path only (not the query) before any
+// transformers run; the `aReplacement` text may refer to submatches as
+// described for `regexp.Regexp.ReplaceAllString()`.
+//
+// Example:
+//
+//	err := apachelogger.AddPathTemplate(`^/users/[^/]+`, "/users/:name")
+//
+// Parameters:
+// - `aPattern`: The regular expression to look for.
+// - `aReplacement`: The text to replace the matches with.
+//
+// Returns:
+// - `error`: A possible error parsing `aPattern`.
+func AddPathTemplate(aPattern, aReplacement string) error {
+	re, err := regexp.Compile(aPattern)
+	if nil != err {
+		return err
+	}
+
+	alPathTemplatesMtx.Lock()
+	alPathTemplates = append(alPathTemplates, tPathTemplate{re, aReplacement})
+	alPathTemplatesMtx.Unlock()
+
+	return nil
+} // AddPathTemplate()
+
+// `ClearPathTemplates()` removes all registered path templating rules.
+func ClearPathTemplates() {
+	alPathTemplatesMtx.Lock()
+	alPathTemplates = nil
+	alPathTemplatesMtx.Unlock()
+} // ClearPathTemplates()
+
+// `collapseSegment()` returns the placeholder for a path segment
+// looking like an ID.
+//
+// Parameters:
+// - `aSegment`: The path segment to check.
+//
+// Returns:
+// - `string`: The placeholder or `aSegment` unchanged.
+func collapseSegment(aSegment string) string {
+	if "" == aSegment {
+		return aSegment
+	}
+	digits, hex := true, true
+	for _, r := range aSegment {
+		isDigit := ('0' <= r) && ('9' >= r)
+		digits = digits && isDigit
+		hex = hex && (isDigit || (('a' <= r) && ('f' >= r)) || (('A' <= r) && ('F' >= r)))
+	}
+	switch {
+	case digits:
+		return ":id"
+	case hex && (16 <= len(aSegment)):
+		return ":hash"
+	case (36 == len(aSegment)) && alUUIDRE.MatchString(aSegment):
+		return ":uuid"
+	}
+
+	return aSegment
+} // collapseSegment()
+
+// `templatePath()` applies the path templating rules to `aEntry`.
+//
+// Parameters:
+// - `aEntry`: The access log entry to modify.
+func templatePath(aEntry *TEntry) {
+	alPathTemplatesMtx.RLock()
+	rules := alPathTemplates
+	alPathTemplatesMtx.RUnlock()
+	if (0 == len(rules)) && !CollapsePathIDs {
+		return
+	}
+
+	path, query := aEntry.Path, ""
+	if idx := strings.IndexByte(path, '?'); 0 <= idx {
+		path, query = path[:idx], path[idx:]
+	}
+	for _, rule := range rules {
+		path = rule.pattern.ReplaceAllString(path, rule.replacement)
+	}
+	if CollapsePathIDs {
+		segments := strings.Split(path, "/")
+		for idx, segment := range segments {
+			segments[idx] = collapseSegment(segment)
+		}
+		path = strings.Join(segments, "/")
+	}
+
+	if path+query != aEntry.Path {
+		if KeepRawPath {
+			aEntry.SetField("raw_path", aEntry.Path)
+		}
+		aEntry.Path = path + query
+	}
+} // templatePath()
+
+/* _EoF_ */
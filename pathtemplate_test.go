@@ -0,0 +1,67 @@
+/*
+Copyright © 2024  M.Watermann, 10247 Berlin, Germany
+
+	    All rights reserved
+	EMail : <support@mwat.de>
+*/
+package apachelogger
+
+import (
+	"testing"
+)
+
+//lint:file-ignore ST1017 – I prefer Yoda conditions
+
+func Test_templatePath(t *testing.T) {
+	defer func() {
+		ClearPathTemplates()
+		CollapsePathIDs, KeepRawPath = false, false
+	}()
+	if err := AddPathTemplate("(", ""); nil == err {
+		t.Error("AddPathTemplate() expected an error")
+	}
+	if err := AddPathTemplate(`^/users/[^/]+`, "/users/:name"); nil != err {
+		t.Fatalf("AddPathTemplate() error = %v", err)
+	}
+
+	tests := []struct {
+		name     string
+		collapse bool
+		path     string
+		want     string
+	}{
+		{"1", false, "/users/jane/profile?tab=1", "/users/:name/profile?tab=1"},
+		{"2", false, "/orders/12345", "/orders/12345"},
+		{"3", true, "/orders/12345/items/7", "/orders/:id/items/:id"},
+		{"4", true, "/doc/123e4567-e89b-12d3-a456-426614174000", "/doc/:uuid"},
+		{"5", true, "/blob/9f86d081884c7d659a2feaa0c55ad015", "/blob/:hash"},
+		{"6", true, "/blob/cafe/v2", "/blob/cafe/v2"},
+		{"7", true, "/path/to/file?id=42", "/path/to/file?id=42"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			CollapsePathIDs = tt.collapse
+			e := prepEntry()
+			e.Path = tt.path
+			templatePath(e)
+			if e.Path != tt.want {
+				t.Errorf("templatePath() = %q, want %q", e.Path, tt.want)
+			}
+		})
+	}
+
+	KeepRawPath = true
+	e := prepEntry()
+	e.Path = "/users/jane"
+	templatePath(e)
+	if "/users/jane" != e.Fields["raw_path"] {
+		t.Errorf("raw_path = %q, want %q", e.Fields["raw_path"], "/users/jane")
+	}
+	e = prepEntry()
+	templatePath(e)
+	if _, ok := e.Fields["raw_path"]; ok {
+		t.Error("raw_path set for unchanged path")
+	}
+} // Test_templatePath()
+
+/* _EoF_ */
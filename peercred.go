@@ -0,0 +1,108 @@
+/*
+Copyright © 2024  M.Watermann, 10247 Berlin, Germany
+
+	    All rights reserved
+	EMail : <support@mwat.de>
+*/
+package apachelogger
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"strconv"
+)
+
+//lint:file-ignore ST1017 – I prefer Yoda conditions
+
+type (
+	// `tPeerCred` holds the credentials of a unix socket's peer process.
+	tPeerCred struct {
+		pid int32  // the peer's process ID
+		uid uint32 // the peer's user ID
+		gid uint32 // the peer's group ID
+	}
+)
+
+// `String()` returns the credentials as the remote address to log.
+//
+// Returns:
+// - `string`: The credentials like `uid=1000,gid=1000,pid=4711`.
+func (pc tPeerCred) String() string {
+	return "uid=" + strconv.FormatUint(uint64(pc.uid), 10) +
+		",gid=" + strconv.FormatUint(uint64(pc.gid), 10) +
+		",pid=" + strconv.FormatInt(int64(pc.pid), 10)
+} // String()
+
+// `addPeerCred()` replaces the remote address of `aEntry` by the peer
+// credentials stored in `aContext` (if any).
+//
+// Parameters:
+// - `aEntry`: The log entry to augment.
+// - `aContext`: The context of the current request.
+func addPeerCred(aEntry *TEntry, aContext context.Context) {
+	pc, ok := aContext.Value(alPeerCredKey).(tPeerCred)
+	if !ok {
+		return
+	}
+	aEntry.Remote = pc.String()
+	aEntry.SetField("peer_uid", strconv.FormatUint(uint64(pc.uid), 10))
+	aEntry.SetField("peer_gid", strconv.FormatUint(uint64(pc.gid), 10))
+	aEntry.SetField("peer_pid", strconv.FormatInt(int64(pc.pid), 10))
+} // addPeerCred()
+
+// `connPeerCred()` returns the peer credentials of `aConn` if it's a
+// unix socket connection (possibly wrapped, e.g. by TLS).
+//
+// Parameters:
+// - `aConn`: The client connection.
+//
+// Returns:
+// - `tPeerCred`: The peer's credentials.
+// - `bool`: `false` if the credentials are not available.
+func connPeerCred(aConn net.Conn) (tPeerCred, bool) {
+	for nil != aConn {
+		if uc, ok := aConn.(*net.UnixConn); ok {
+			return unixPeerCred(uc)
+		}
+		wrapper, ok := aConn.(interface{ NetConn() net.Conn })
+		if !ok {
+			break
+		}
+		aConn = wrapper.NetConn()
+	}
+
+	return tPeerCred{}, false
+} // connPeerCred()
+
+// `SetPeerCredLog()` arranges for requests received by `aServer` over
+// a unix socket to be logged with the peer process' credentials.
+//
+// The function installs a `ConnContext` callback reading the user ID,
+// group ID, and process ID of the connecting process (using Linux's
+// `SO_PEERCRED` socket option) which replace the remote address of
+// the access log entries (like `uid=1000,gid=1000,pid=4711`) and are
+// added as fields `peer_uid`, `peer_gid`, and `peer_pid` as well.
+// The credentials are not anonymised. Connections over other networks
+// and platforms without `SO_PEERCRED` are logged as before. A
+// `ConnContext` callback set before is called as well.
+//
+// The function must be called before the server is started.
+//
+// Parameters:
+// - `aServer`: The server instance whose peers are to be logged.
+func SetPeerCredLog(aServer *http.Server) {
+	connContext := aServer.ConnContext
+	aServer.ConnContext = func(aContext context.Context, aConn net.Conn) context.Context {
+		if nil != connContext {
+			aContext = connContext(aContext, aConn)
+		}
+		if pc, ok := connPeerCred(aConn); ok {
+			aContext = context.WithValue(aContext, alPeerCredKey, pc)
+		}
+
+		return aContext
+	}
+} // SetPeerCredLog()
+
+/* _EoF_ */
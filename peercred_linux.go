@@ -0,0 +1,48 @@
+//go:build linux
+// +build linux
+
+/*
+Copyright © 2024  M.Watermann, 10247 Berlin, Germany
+
+	    All rights reserved
+	EMail : <support@mwat.de>
+*/
+package apachelogger
+
+import (
+	"net"
+	"syscall"
+)
+
+//lint:file-ignore ST1017 – I prefer Yoda conditions
+
+// `unixPeerCred()` returns the credentials of the peer process of
+// `aConn` using the `SO_PEERCRED` socket option.
+//
+// Parameters:
+// - `aConn`: The unix socket connection.
+//
+// Returns:
+// - `tPeerCred`: The peer's credentials.
+// - `bool`: `false` if the credentials couldn't be read.
+func unixPeerCred(aConn *net.UnixConn) (tPeerCred, bool) {
+	raw, err := aConn.SyscallConn()
+	if nil != err {
+		return tPeerCred{}, false
+	}
+
+	var (
+		cred   *syscall.Ucred
+		detail error
+	)
+	if err = raw.Control(func(aFd uintptr) {
+		cred, detail = syscall.GetsockoptUcred(int(aFd),
+			syscall.SOL_SOCKET, syscall.SO_PEERCRED)
+	}); (nil != err) || (nil != detail) {
+		return tPeerCred{}, false
+	}
+
+	return tPeerCred{pid: cred.Pid, uid: cred.Uid, gid: cred.Gid}, true
+} // unixPeerCred()
+
+/* _EoF_ */
@@ -0,0 +1,53 @@
+//go:build linux
+// +build linux
+
+/*
+Copyright © 2024  M.Watermann, 10247 Berlin, Germany
+
+	    All rights reserved
+	EMail : <support@mwat.de>
+*/
+package apachelogger
+
+import (
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+//lint:file-ignore ST1017 – I prefer Yoda conditions
+
+func Test_connPeerCred(t *testing.T) {
+	listener, err := net.Listen("unix", filepath.Join(t.TempDir(), "test.sock"))
+	if nil != err {
+		t.Skipf("can't listen on unix socket: %v", err)
+	}
+	defer listener.Close()
+
+	client, err := net.Dial("unix", listener.Addr().String())
+	if nil != err {
+		t.Fatalf("net.Dial() error = %v", err)
+	}
+	defer client.Close()
+	server, err := listener.Accept()
+	if nil != err {
+		t.Fatalf("Accept() error = %v", err)
+	}
+	defer server.Close()
+
+	pc, ok := connPeerCred(server)
+	if !ok {
+		t.Fatal("connPeerCred() returned no credentials")
+	}
+	want := tPeerCred{
+		pid: int32(os.Getpid()),
+		uid: uint32(os.Getuid()),
+		gid: uint32(os.Getgid()),
+	}
+	if want != pc {
+		t.Errorf("connPeerCred() = %v, want %v", pc, want)
+	}
+} // Test_connPeerCred()
+
+/* _EoF_ */
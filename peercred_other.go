@@ -0,0 +1,34 @@
+//go:build !linux
+// +build !linux
+
+/*
+Copyright © 2024  M.Watermann, 10247 Berlin, Germany
+
+	    All rights reserved
+	EMail : <support@mwat.de>
+*/
+package apachelogger
+
+import (
+	"net"
+)
+
+//lint:file-ignore ST1017 – I prefer Yoda conditions
+
+// `unixPeerCred()` returns the credentials of the peer process of
+// `aConn`.
+//
+// `SO_PEERCRED` isn't available on this platform, so no credentials
+// are returned.
+//
+// Parameters:
+// - `aConn`: The unix socket connection.
+//
+// Returns:
+// - `tPeerCred`: The peer's credentials.
+// - `bool`: Always `false`.
+func unixPeerCred(aConn *net.UnixConn) (tPeerCred, bool) {
+	return tPeerCred{}, false
+} // unixPeerCred()
+
+/* _EoF_ */
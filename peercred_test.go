@@ -0,0 +1,35 @@
+/*
+Copyright © 2024  M.Watermann, 10247 Berlin, Germany
+
+	    All rights reserved
+	EMail : <support@mwat.de>
+*/
+package apachelogger
+
+import (
+	"context"
+	"testing"
+)
+
+//lint:file-ignore ST1017 – I prefer Yoda conditions
+
+func Test_addPeerCred(t *testing.T) {
+	e1 := prepEntry()
+	remote := e1.Remote
+	addPeerCred(e1, context.Background())
+	if remote != e1.Remote {
+		t.Errorf("addPeerCred() Remote = %q, want %q", e1.Remote, remote)
+	}
+
+	ctx := context.WithValue(context.Background(), alPeerCredKey,
+		tPeerCred{pid: 4711, uid: 1000, gid: 100})
+	addPeerCred(e1, ctx)
+	if want := "uid=1000,gid=100,pid=4711"; want != e1.Remote {
+		t.Errorf("addPeerCred() Remote = %q, want %q", e1.Remote, want)
+	}
+	if want := "4711"; want != e1.Fields["peer_pid"] {
+		t.Errorf("addPeerCred() peer_pid = %q, want %q", e1.Fields["peer_pid"], want)
+	}
+} // Test_addPeerCred()
+
+/* _EoF_ */
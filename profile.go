@@ -0,0 +1,130 @@
+/*
+Copyright © 2024  M.Watermann, 10247 Berlin, Germany
+
+	    All rights reserved
+	EMail : <support@mwat.de>
+*/
+package apachelogger
+
+import (
+	"fmt"
+)
+
+//lint:file-ignore ST1017 – I prefer Yoda conditions
+
+const (
+	// `ProfileNone` restores the built-in combined format.
+	ProfileNone = iota
+
+	// `ProfileGoAccess` writes lines for GoAccess' predefined
+	// `COMBINED` log format.
+	ProfileGoAccess
+
+	// `ProfileAWStats` writes lines for AWStats' `LogFormat=1`
+	// (combined).
+	ProfileAWStats
+)
+
+// `appendStrict()` appends `aText` to `aBuffer`, percent-encoding all
+// characters log analysers may choke on.
+//
+// Quotes and backslashes (which the analysers don't unescape), control
+// characters, and – for unquoted fields – spaces are encoded like in
+// URLs (e.g. `%22`, `%20`).
+//
+// Parameters:
+// - `aBuffer`: The buffer to append to.
+// - `aText`: The text to append.
+// - `aSpaces`: Whether to encode spaces as well.
+//
+// Returns:
+// - `[]byte`: The extended buffer.
+func appendStrict(aBuffer []byte, aText string, aSpaces bool) []byte {
+	const hex = "0123456789ABCDEF"
+
+	for idx := 0; idx < len(aText); idx++ {
+		switch c := aText[idx]; {
+		case ('"' == c) || ('\\' == c) || (' ' > c) || (0x7f == c),
+			aSpaces && (' ' == c):
+			aBuffer = append(aBuffer, '%', hex[c>>4], hex[c&0x0f])
+		default:
+			aBuffer = append(aBuffer, c)
+		}
+	}
+
+	return aBuffer
+} // appendStrict()
+
+// `profileFormat()` returns the log format of `aProfile`.
+//
+// Parameters:
+// - `aProfile`: The analyser to write the access log for.
+//
+// Returns:
+// - `*tLogFormat`: The format (`nil` for `ProfileNone`).
+// - `error`: A possible error for an unknown profile.
+func profileFormat(aProfile int) (*tLogFormat, error) {
+	switch aProfile {
+	case ProfileNone:
+		return nil, nil
+
+	case ProfileGoAccess, ProfileAWStats:
+		lf, err := parseFormat(FormatCombined)
+		if nil != err {
+			return nil, err
+		}
+		lf.strict = true
+
+		return lf, nil
+	}
+
+	return nil, fmt.Errorf("apachelogger: unknown profile %d", aProfile)
+} // profileFormat()
+
+/* * * * * * * * * * * * * * * * * * * * * * * * * * * * * * * * * * * * * */
+
+// `SetProfile()` configures the access log to be read by a particular
+// log analyser without any further configuration.
+//
+// `ProfileGoAccess` (`goaccess --log-format=COMBINED`) and
+// `ProfileAWStats` (`LogFormat=1`) both write Apache's Combined Log
+// Format as text lines with
+//
+//   - every empty field written as `-` (including a zero size),
+//   - quotes, backslashes, and control characters percent-encoded (the
+//     analysers don't unescape `\"`), as well as spaces outside of the
+//     quoted fields and within the requested path,
+//   - no additional fields, and
+//   - no day separators, i.e. `DayChange` is set to `DayChangeNone`
+//     unless the logfiles are rotated (`DayChangeRotate`).
+//
+// To that end `BinaryLog` is switched off and any CSV or W3C format is
+// cleared. `ProfileNone` restores the built-in combined format leaving
+// the other settings alone.
+//
+// Parameters:
+// - `aProfile`: The analyser to write the access log for.
+//
+// Returns:
+// - `error`: A possible error for an unknown profile.
+func SetProfile(aProfile int) error {
+	lf, err := profileFormat(aProfile)
+	if nil != err {
+		return err
+	}
+	if nil != lf {
+		BinaryLog = false
+		ClearCSVFormat()
+		ClearW3CFormat()
+		if DayChangeRotate != DayChange {
+			DayChange = DayChangeNone
+		}
+	}
+	alLogFormatMtx.Lock()
+	alLogFormat = lf
+	alLogFormatMtx.Unlock()
+
+	return nil
+} // SetProfile()
+
+/* _EoF_ */
@@ -0,0 +1,190 @@
+/*
+Copyright © 2024  M.Watermann, 10247 Berlin, Germany
+
+	    All rights reserved
+	EMail : <support@mwat.de>
+*/
+package apachelogger
+
+import (
+	"net"
+	"regexp"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+//lint:file-ignore ST1017 – I prefer Yoda conditions
+
+var (
+	// AWStats' parsing expression for `LogFormat=1` (from `awstats.pl`).
+	awstatsCombined = regexp.MustCompile(`^([^ ]+) [^ ]+ ([^ ]+) \[([^ ]+) [^ ]+\] "([^ ]+) (.+) [^"]+" ([\d|-]+) ([\d|-]+) "(.*?)" "([^"]*)"$`)
+
+	// The request methods accepted by GoAccess.
+	goaccessMethods = map[string]bool{"OPTIONS": true, "GET": true,
+		"HEAD": true, "POST": true, "PUT": true, "DELETE": true,
+		"TRACE": true, "CONNECT": true, "PATCH": true, "PROPFIND": true,
+		"PROPPATCH": true, "MKCOL": true, "COPY": true, "MOVE": true,
+		"LOCK": true, "UNLOCK": true, "VERSION-CONTROL": true,
+		"REPORT": true, "CHECKOUT": true, "CHECKIN": true,
+		"UNCHECKOUT": true, "MKWORKSPACE": true, "UPDATE": true,
+		"LABEL": true, "MERGE": true, "BASELINE-CONTROL": true,
+		"MKACTIVITY": true, "ORDERPATCH": true, "ACL": true,
+		"SEARCH": true}
+)
+
+// `goaccessParse()` parses `aLine` like GoAccess does with its
+// predefined `COMBINED` format (`%h %^[%d:%t %^] "%r" %s %b "%R" "%u"`):
+// every specifier extends up to the format's next character, and the
+// host, date, request, status, and size are validated.
+func goaccessParse(aLine string) (map[byte]string, string) {
+	const format = `%h %^[%d:%t %^] "%r" %s %b "%R" "%u"`
+	result := make(map[byte]string)
+	rest := aLine
+	for idx := 0; idx < len(format); idx++ {
+		if '%' != format[idx] {
+			if !strings.HasPrefix(rest, format[idx:idx+1]) {
+				return nil, "expected " + strconv.Quote(format[idx:idx+1])
+			}
+			rest = rest[1:]
+			continue
+		}
+		idx++
+		spec := format[idx]
+		end := len(rest)
+		if idx+1 < len(format) {
+			end = strings.IndexByte(rest, format[idx+1])
+		}
+		if 0 > end {
+			return nil, "unterminated %" + string(spec)
+		}
+		result[spec] = rest[:end]
+		rest = rest[end:]
+	}
+	if "" != rest {
+		return nil, "trailing data " + strconv.Quote(rest)
+	}
+
+	if nil == net.ParseIP(result['h']) {
+		return nil, "invalid host " + strconv.Quote(result['h'])
+	}
+	if _, err := time.Parse("02/Jan/2006 15:04:05", result['d']+" "+result['t']); nil != err {
+		return nil, "invalid date/time"
+	}
+	req := strings.Split(result['r'], " ")
+	if (3 != len(req)) || !goaccessMethods[req[0]] ||
+		!strings.HasPrefix(req[2], "HTTP/") {
+		return nil, "invalid request " + strconv.Quote(result['r'])
+	}
+	if status, err := strconv.Atoi(result['s']); (nil != err) || (100 > status) || (599 < status) {
+		return nil, "invalid status " + strconv.Quote(result['s'])
+	}
+	if _, err := strconv.Atoi(result['b']); (nil != err) && ("-" != result['b']) {
+		return nil, "invalid size " + strconv.Quote(result['b'])
+	}
+
+	return result, ""
+} // goaccessParse()
+
+func profileSamples() []*TEntry {
+	e1 := prepEntry()
+	e2 := prepEntry()
+	e2.User, e2.Referrer, e2.Agent, e2.Size = "", "", "", 0
+	e3 := prepEntry()
+	e3.User = "John Doe"
+	e3.Path = `/search?q="quoted" \ and spaces`
+	e3.Referrer = `https://example.com/?a="b"`
+	e3.Agent = `Mozilla/5.0 "evil" \x00` + "\t\x7f"
+	e4 := prepEntry()
+	e4.Remote = "2001:db8::"
+	e4.Method, e4.Path, e4.Proto = "PROPFIND", "", "HTTP/2.0"
+	e5 := prepEntry()
+	e5.Path = "/ünïcödé/pfad"
+	e5.Agent = "Agent ]with [brackets]"
+	e5.SetField("user_id", "42")
+
+	return []*TEntry{e1, e2, e3, e4, e5}
+} // profileSamples()
+
+func TestSetProfile(t *testing.T) {
+	oldDayChange := DayChange
+	defer func() {
+		DayChange = oldDayChange
+		_ = SetProfile(ProfileNone)
+	}()
+
+	if err := SetProfile(42); nil == err {
+		t.Error("SetProfile(42) expected an error")
+	}
+	for _, profile := range []int{ProfileGoAccess, ProfileAWStats} {
+		DayChange = DayChangeMarker
+		BinaryLog = true
+		if err := SetProfile(profile); nil != err {
+			t.Fatalf("SetProfile(%d) error = %v", profile, err)
+		}
+		if BinaryLog || (DayChangeNone != DayChange) {
+			t.Errorf("SetProfile(%d) BinaryLog = %v, DayChange = %d", profile, BinaryLog, DayChange)
+		}
+		if buf, _ := dayChange(nil, nil, time.Now().AddDate(0, 0, -1), time.Now(), nil); 0 != len(buf) {
+			t.Errorf("SetProfile(%d) writes a day separator %q", profile, buf)
+		}
+
+		for idx, entry := range profileSamples() {
+			line := formatEntry(entry)
+			if !strings.HasSuffix(line, "\n") || (1 != strings.Count(line, "\n")) {
+				t.Fatalf("profile %d, sample %d: not a single line %q", profile, idx, line)
+			}
+			line = strings.TrimSuffix(line, "\n")
+
+			m := awstatsCombined.FindStringSubmatch(line)
+			if nil == m {
+				t.Errorf("profile %d, sample %d: AWStats can't parse %q", profile, idx, line)
+				continue
+			}
+			if strconv.Itoa(entry.Status) != m[6] {
+				t.Errorf("profile %d, sample %d: AWStats status = %q", profile, idx, m[6])
+			}
+			if strings.ContainsAny(m[2], " ") || ("" == m[2]) {
+				t.Errorf("profile %d, sample %d: AWStats user = %q", profile, idx, m[2])
+			}
+
+			fields, problem := goaccessParse(line)
+			if "" != problem {
+				t.Errorf("profile %d, sample %d: GoAccess: %s in %q", profile, idx, problem, line)
+				continue
+			}
+			if entry.Remote != fields['h'] {
+				t.Errorf("profile %d, sample %d: GoAccess host = %q", profile, idx, fields['h'])
+			}
+		}
+	}
+
+	if err := SetProfile(ProfileNone); nil != err {
+		t.Fatalf("SetProfile(ProfileNone) error = %v", err)
+	}
+	e := prepEntry()
+	if got := formatEntry(e); e.String() != got {
+		t.Errorf("formatEntry() = %q, want %q", got, e.String())
+	}
+} // TestSetProfile()
+
+func Test_appendStrict(t *testing.T) {
+	tests := []struct {
+		text   string
+		spaces bool
+		want   string
+	}{
+		{`a "b" c`, false, `a %22b%22 c`},
+		{`a "b" c`, true, `a%20%22b%22%20c`},
+		{"x\\y\n\x7f", false, `x%5Cy%0A%7F`},
+		{"ünï", true, "ünï"},
+	}
+	for _, tt := range tests {
+		if got := string(appendStrict(nil, tt.text, tt.spaces)); tt.want != got {
+			t.Errorf("appendStrict(%q, %v) = %q, want %q", tt.text, tt.spaces, got, tt.want)
+		}
+	}
+} // Test_appendStrict()
+
+/* _EoF_ */
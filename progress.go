@@ -0,0 +1,176 @@
+/*
+Copyright © 2024  M.Watermann, 10247 Berlin, Germany
+
+	    All rights reserved
+	EMail : <support@mwat.de>
+*/
+package apachelogger
+
+import (
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+//lint:file-ignore ST1017 – I prefer Yoda conditions
+
+type (
+	// `tProgress` writes the progress entries of a long-running request.
+	tProgress struct {
+		sync.Mutex
+		done    bool          // the request is finished
+		logged  int           // number of progress entries written
+		request *http.Request // the request served
+		size    int           // the size of the data sent so far
+		status  int           // the HTTP status code sent
+		timer   *time.Timer   // the timer triggering the next entry
+		when    time.Time     // access time
+		queue   *tRing        // the queue of the access log entries
+	}
+)
+
+var (
+	// `ProgressInterval` is the time after which (and then repeatedly)
+	// a still running request gets an in-progress entry in the access
+	// log (default: `0`, i.e. no such entries).
+	//
+	// This is meant for long-lived responses like Server-Sent Events or
+	// long polling which otherwise show up only when they're finished,
+	// possibly hours later. The in-progress entries carry the bytes sent
+	// and the time elapsed so far as well as the fields `progress=running`
+	// and `progress_seq`; the request's final entry gets `progress=done`
+	// if in-progress entries were written for it.
+	// Like the final entry they're left out for suppressed requests (see
+	// `Suppress()`), suppressed duplicates, and while aggregating.
+	ProgressInterval time.Duration
+)
+
+// `newProgress()` returns the progress tracker of `aRequest` if
+// `ProgressInterval` is set.
+//
+// Parameters:
+// - `aRequest`: The request to track.
+// - `aWhen`: The request's access time.
+// - `aLogQueue`: The queue to send the progress entries to.
+//
+// Returns:
+// - `*tProgress`: The tracker (`nil` if `ProgressInterval` isn't set).
+func newProgress(aRequest *http.Request, aWhen time.Time, aLogQueue *tRing) *tProgress {
+	interval := ProgressInterval
+	if 0 >= interval {
+		return nil
+	}
+	result := &tProgress{request: aRequest, when: aWhen, queue: aLogQueue}
+	result.Lock() // `tick()` must wait for `timer` to be set
+	result.timer = time.AfterFunc(interval, func() {
+		result.tick(interval)
+	})
+	result.Unlock()
+
+	return result
+} // newProgress()
+
+// `finish()` stops the tracker.
+//
+// Returns:
+// - `bool`: `true` if progress entries were written.
+func (p *tProgress) finish() bool {
+	p.Lock()
+	defer p.Unlock()
+
+	p.done = true
+	p.timer.Stop()
+
+	return 0 < p.logged
+} // finish()
+
+// `sent()` adds `aSize` to the number of bytes sent.
+//
+// Parameters:
+// - `aSize`: The number of bytes just sent.
+func (p *tProgress) sent(aSize int) {
+	p.Lock()
+	p.size += aSize
+	p.Unlock()
+} // sent()
+
+// `setStatus()` records the HTTP status code sent.
+//
+// Parameters:
+// - `aStatus`: The request's result code.
+func (p *tProgress) setStatus(aStatus int) {
+	p.Lock()
+	p.status = aStatus
+	p.Unlock()
+} // setStatus()
+
+// `tick()` writes an in-progress entry and schedules the next one.
+//
+// No entry is written while the access log is paused or aggregated,
+// if `Suppress()` was called for the request, or if it repeats a
+// request whose duplicates are currently suppressed.
+//
+// Parameters:
+// - `aInterval`: The time between two entries.
+func (p *tProgress) tick(aInterval time.Duration) {
+	p.Lock()
+	if p.done {
+		p.Unlock()
+		return
+	}
+	lw := &tLogWriter{
+		size:     p.size,
+		status:   p.status,
+		when:     p.when,
+		duration: elapsed(p.when, time.Now()),
+		queue:    p.queue,
+	}
+	p.timer.Reset(aInterval)
+	p.Unlock()
+
+	if 0 == lw.status {
+		lw.status = http.StatusOK
+	}
+	if accessPaused() || aggregating() {
+		return
+	}
+	if rs := requestState(p.request.Context()); (nil != rs) && rs.isSuppressed() {
+		return
+	}
+	entry, _ := webEntry(lw, p.request)
+	if isDuplicate(entry) {
+		return
+	}
+
+	p.Lock()
+	if p.done {
+		p.Unlock()
+		return
+	}
+	p.logged++
+	seq := p.logged
+	p.Unlock()
+
+	entry.SetField("progress", "running")
+	entry.SetField("progress_seq", strconv.Itoa(seq))
+	stampSequence(entry, p.queue)
+	p.queue.pushKey(p.request.RemoteAddr, formatEntry(entry))
+} // tick()
+
+/* * * * * * * * * * * * * * * * * * * * * * * * * * * * * * * * * * * * * */
+
+// `Flush()` sends any buffered data to the client.
+//
+// Part of the `http.Flusher` interface, so streaming handlers (e.g.
+// Server-Sent Events) work through the logging wrapper.
+func (lw *tLogWriter) Flush() {
+	if 0 == lw.status {
+		lw.WriteHeader(http.StatusOK)
+	}
+	if flusher, ok := lw.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+} // Flush()
+
+/* _EoF_ */
@@ -0,0 +1,92 @@
+/*
+Copyright © 2024  M.Watermann, 10247 Berlin, Germany
+
+	    All rights reserved
+	EMail : <support@mwat.de>
+*/
+package apachelogger
+
+import (
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+//lint:file-ignore ST1017 – I prefer Yoda conditions
+
+func Test_tProgress(t *testing.T) {
+	defer func(aCanonical bool, aInterval time.Duration) {
+		CanonicalLogLine, ProgressInterval = aCanonical, aInterval
+	}(CanonicalLogLine, ProgressInterval)
+	defer setLogQueues(setLogQueues(newRing(8), nil))
+	CanonicalLogLine = true
+	req := httptest.NewRequest("GET", "/events", nil)
+
+	ProgressInterval = 0
+	if p := newProgress(req, time.Now(), accessQueue()); nil != p {
+		t.Error("newProgress() returned a tracker while disabled")
+	}
+
+	ProgressInterval = 5 * time.Millisecond
+	p := newProgress(req, time.Now(), accessQueue())
+	p.setStatus(200)
+	p.sent(42)
+	deadline := time.Now().Add(time.Second)
+	for accessQueue().isEmpty() {
+		if time.Now().After(deadline) {
+			t.Fatal("tick() didn't queue a progress entry")
+		}
+		time.Sleep(time.Millisecond)
+	}
+	if !p.finish() {
+		t.Fatal("finish() = false, want true")
+	}
+
+	lines := accessQueue().popBatch(make([]string, 0, 8))
+	for _, want := range []string{" progress=running", " progress_seq=1", " size=42 "} {
+		if !strings.Contains(lines[0], want) {
+			t.Errorf("progress line = %q, want %q", lines[0], want)
+		}
+	}
+} // Test_tProgress()
+
+func Test_tProgress_suppressed(t *testing.T) {
+	defer func(aInterval time.Duration) {
+		ProgressInterval = aInterval
+	}(ProgressInterval)
+	defer setLogQueues(setLogQueues(newRing(8), nil))
+	defer SetDuplicateWindow(0)
+	ProgressInterval = 2 * time.Millisecond
+
+	// a suppressed request:
+	req, _ := withRequestState(httptest.NewRequest("GET", "/quiet", nil))
+	Suppress(req.Context())
+	p := newProgress(req, time.Now(), accessQueue())
+	time.Sleep(20 * time.Millisecond)
+	if p.finish() || !accessQueue().isEmpty() {
+		t.Error("tick() logged a suppressed request")
+	}
+
+	// a repeated request while duplicates are suppressed:
+	SetDuplicateWindow(time.Minute)
+	req, _ = withRequestState(httptest.NewRequest("GET", "/again", nil))
+	first, _ := webEntry(&tLogWriter{status: 200, when: time.Now()}, req)
+	suppressDuplicate(first, accessQueue())
+	p = newProgress(req, time.Now(), accessQueue())
+	time.Sleep(20 * time.Millisecond)
+	if p.finish() || !accessQueue().isEmpty() {
+		t.Error("tick() logged a suppressed duplicate")
+	}
+} // Test_tProgress_suppressed()
+
+func Test_tLogWriter_Flush(t *testing.T) {
+	rec := httptest.NewRecorder()
+	lw := &tLogWriter{ResponseWriter: rec}
+	lw.Flush()
+	if !rec.Flushed || (200 != lw.status) {
+		t.Errorf("Flush() flushed = %v, status = %d", rec.Flushed, lw.status)
+	}
+} // Test_tLogWriter_Flush()
+
+/* _EoF_ */
@@ -0,0 +1,345 @@
+/*
+Copyright © 2024  M.Watermann, 10247 Berlin, Germany
+
+	    All rights reserved
+	EMail : <support@mwat.de>
+*/
+package apachelogger
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+//lint:file-ignore ST1017 – I prefer Yoda conditions
+
+type (
+	// `tProxyConn` is a connection whose remote address is taken from
+	// a PROXY protocol header (if any).
+	tProxyConn struct {
+		net.Conn
+		err     error         // error reading the PROXY header
+		local   net.Addr      // local address announced by the proxy
+		reader  *bufio.Reader // buffered reader of the connection
+		remote  net.Addr      // remote address announced by the proxy
+		timeout time.Duration // max. time to wait for the header
+	}
+
+	// `tProxyListener` is a listener accepting connections carrying a
+	// PROXY protocol header.
+	tProxyListener struct {
+		net.Listener
+		timeout time.Duration // max. time to wait for the header
+		once    sync.Once     // starts `goAccept()` once
+		conns   chan net.Conn // connections whose header was read
+		errs    chan error    // errors of the wrapped listener
+		done    chan struct{} // closed when the wrapped listener failed
+		err     error         // the wrapped listener's final error
+	}
+)
+
+const (
+	// The default max. time to wait for a PROXY header.
+	alProxyTimeout = 5 * time.Second
+)
+
+var (
+	// Signature of PROXY protocol v2 headers.
+	alProxyV2Sig = []byte("\r\n\r\n\x00\r\nQUIT\n")
+
+	// Error returned for malformed PROXY protocol headers.
+	errProxyHeader = errors.New("apachelogger: malformed PROXY protocol header")
+)
+
+// `Accept()` waits for and returns the next connection whose PROXY
+// header (if any) was read.
+//
+// Part of the `net.Listener` interface.
+//
+// Returns:
+// - `net.Conn`: The accepted connection.
+// - `error`: A possible error accepting the connection.
+func (pl *tProxyListener) Accept() (net.Conn, error) {
+	pl.once.Do(func() {
+		go pl.goAccept()
+	})
+
+	select {
+	case conn := <-pl.conns:
+		return conn, nil
+	case err := <-pl.errs:
+		return nil, err
+	case <-pl.done:
+		return nil, pl.err
+	}
+} // Accept()
+
+// `goAccept()` accepts the connections of the wrapped listener and
+// reads their PROXY headers in goroutines of their own, so a client
+// sending nothing doesn't delay the other connections.
+func (pl *tProxyListener) goAccept() {
+	for {
+		conn, err := pl.Listener.Accept()
+		if nil != err {
+			if te, ok := err.(interface{ Temporary() bool }); ok && te.Temporary() {
+				select {
+				case pl.errs <- err:
+				case <-pl.done:
+				}
+				continue
+			}
+			pl.err = err
+			close(pl.done)
+			return
+		}
+
+		go func(aConn net.Conn) {
+			pc := &tProxyConn{
+				Conn:    aConn,
+				reader:  bufio.NewReader(aConn),
+				timeout: pl.timeout,
+			}
+			pc.readHeader()
+			select {
+			case pl.conns <- pc:
+			case <-pl.done:
+				_ = aConn.Close()
+			}
+		}(conn)
+	}
+} // goAccept()
+
+/* * * * * * * * * * * * * * * * * * * * * * * * * * * * * * * * * * * * * */
+
+// `LocalAddr()` returns the local network address announced by the
+// proxy or the connection's local address.
+//
+// Part of the `net.Conn` interface.
+//
+// Returns:
+// - `net.Addr`: The local address.
+func (pc *tProxyConn) LocalAddr() net.Addr {
+	if nil != pc.local {
+		return pc.local
+	}
+
+	return pc.Conn.LocalAddr()
+} // LocalAddr()
+
+// `Read()` reads data from the connection (after the PROXY header).
+//
+// Part of the `net.Conn` interface.
+//
+// Parameters:
+// - `aBuffer`: The buffer to read into.
+//
+// Returns:
+// - `int`: The number of bytes read.
+// - `error`: A possible error reading the data.
+func (pc *tProxyConn) Read(aBuffer []byte) (int, error) {
+	if nil != pc.err {
+		return 0, pc.err
+	}
+
+	return pc.reader.Read(aBuffer)
+} // Read()
+
+// `readHeader()` reads the PROXY protocol header (if any); it's called
+// before the connection is returned by `Accept()`.
+func (pc *tProxyConn) readHeader() {
+	_ = pc.Conn.SetReadDeadline(time.Now().Add(pc.timeout))
+	defer func() {
+		_ = pc.Conn.SetReadDeadline(time.Time{})
+	}()
+
+	first, err := pc.reader.Peek(1)
+	if nil != err {
+		if io.EOF != err {
+			pc.err = err
+		}
+		return
+	}
+	switch first[0] {
+	case 'P':
+		if sig, _ := pc.reader.Peek(6); bytes.Equal(sig, []byte("PROXY ")) {
+			pc.remote, pc.local, pc.err = readProxyV1(pc.reader)
+		}
+	case '\r':
+		if sig, _ := pc.reader.Peek(12); bytes.Equal(sig, alProxyV2Sig) {
+			pc.remote, pc.local, pc.err = readProxyV2(pc.reader)
+		}
+	}
+} // readHeader()
+
+// `RemoteAddr()` returns the remote network address announced by the
+// proxy or the connection's remote address.
+//
+// Part of the `net.Conn` interface.
+//
+// Returns:
+// - `net.Addr`: The remote address.
+func (pc *tProxyConn) RemoteAddr() net.Addr {
+	if nil != pc.remote {
+		return pc.remote
+	}
+
+	return pc.Conn.RemoteAddr()
+} // RemoteAddr()
+
+/* * * * * * * * * * * * * * * * * * * * * * * * * * * * * * * * * * * * * */
+
+// `readProxyV1()` reads a (textual) PROXY protocol v1 header.
+//
+//	PROXY TCP4 192.168.0.1 192.168.0.11 56324 443\r\n
+//
+// Parameters:
+// - `aReader`: The reader to read the header from.
+//
+// Returns:
+// - `net.Addr`: The announced remote address (`nil` for `UNKNOWN`).
+// - `net.Addr`: The announced local address (`nil` for `UNKNOWN`).
+// - `error`: A possible error reading or parsing the header.
+func readProxyV1(aReader *bufio.Reader) (net.Addr, net.Addr, error) {
+	var line []byte
+
+	// The header is at most 107 bytes long (including CRLF).
+	for 107 >= len(line) {
+		b, err := aReader.ReadByte()
+		if nil != err {
+			return nil, nil, err
+		}
+		line = append(line, b)
+		if '\n' == b {
+			break
+		}
+	}
+	if !bytes.HasSuffix(line, []byte("\r\n")) {
+		return nil, nil, errProxyHeader
+	}
+
+	fields := strings.Fields(string(line[:len(line)-2]))
+	if (2 <= len(fields)) && ("UNKNOWN" == fields[1]) {
+		return nil, nil, nil
+	}
+	if (6 != len(fields)) || (("TCP4" != fields[1]) && ("TCP6" != fields[1])) {
+		return nil, nil, errProxyHeader
+	}
+	srcIP, dstIP := net.ParseIP(fields[2]), net.ParseIP(fields[3])
+	srcPort, err1 := strconv.ParseUint(fields[4], 10, 16)
+	dstPort, err2 := strconv.ParseUint(fields[5], 10, 16)
+	if (nil == srcIP) || (nil == dstIP) || (nil != err1) || (nil != err2) {
+		return nil, nil, errProxyHeader
+	}
+
+	return &net.TCPAddr{IP: srcIP, Port: int(srcPort)},
+		&net.TCPAddr{IP: dstIP, Port: int(dstPort)}, nil
+} // readProxyV1()
+
+// `readProxyV2()` reads a (binary) PROXY protocol v2 header.
+//
+// Parameters:
+// - `aReader`: The reader to read the header from.
+//
+// Returns:
+// - `net.Addr`: The announced remote address (`nil` for `LOCAL`).
+// - `net.Addr`: The announced local address (`nil` for `LOCAL`).
+// - `error`: A possible error reading or parsing the header.
+func readProxyV2(aReader *bufio.Reader) (net.Addr, net.Addr, error) {
+	header := make([]byte, 16)
+	if _, err := io.ReadFull(aReader, header); nil != err {
+		return nil, nil, err
+	}
+	if 0x20 != header[12]&0xF0 {
+		return nil, nil, errProxyHeader // unsupported version
+	}
+	payload := make([]byte, binary.BigEndian.Uint16(header[14:16]))
+	if _, err := io.ReadFull(aReader, payload); nil != err {
+		return nil, nil, err
+	}
+	if 0x01 != header[12]&0x0F {
+		return nil, nil, nil // LOCAL command: use the real addresses
+	}
+
+	switch header[13] {
+	case 0x11: // TCP over IPv4
+		if 12 > len(payload) {
+			return nil, nil, errProxyHeader
+		}
+		return &net.TCPAddr{
+				IP:   net.IP(payload[0:4]),
+				Port: int(binary.BigEndian.Uint16(payload[8:10]))},
+			&net.TCPAddr{
+				IP:   net.IP(payload[4:8]),
+				Port: int(binary.BigEndian.Uint16(payload[10:12]))},
+			nil
+
+	case 0x21: // TCP over IPv6
+		if 36 > len(payload) {
+			return nil, nil, errProxyHeader
+		}
+		return &net.TCPAddr{
+				IP:   net.IP(payload[0:16]),
+				Port: int(binary.BigEndian.Uint16(payload[32:34]))},
+			&net.TCPAddr{
+				IP:   net.IP(payload[16:32]),
+				Port: int(binary.BigEndian.Uint16(payload[34:36]))},
+			nil
+	}
+
+	// unsupported address family/protocol: use the real addresses
+	return nil, nil, nil
+} // readProxyV2()
+
+/* * * * * * * * * * * * * * * * * * * * * * * * * * * * * * * * * * * * * */
+
+// `NewProxyListener()` returns a listener whose connections take their
+// remote address from a HAProxy PROXY protocol (v1 or v2) header.
+//
+// Behind a load balancer in TCP mode all connections come from the load
+// balancer's address; with this listener the real client address is
+// reported by the connections' `RemoteAddr()` method, i.e. it is used
+// by the access log (and anonymised like any other address).
+// Connections without a PROXY header keep their own addresses.
+//
+// Each connection's header is read in a goroutine of its own, waiting
+// at most `aTimeout` (default: 5 seconds), before `Accept()` returns
+// the connection; so a client sending nothing delays neither the other
+// connections nor the server's accept loop. To use the listener with
+// TLS wrap it by `tls.NewListener()`:
+//
+//	ln, _ := net.Listen("tcp", ":443")
+//	ln = apachelogger.NewProxyListener(ln, 5*time.Second)
+//	err := server.Serve(tls.NewListener(ln, server.TLSConfig))
+//
+// NOTE: Only put this listener behind trusted proxies since clients
+// could send forged headers otherwise.
+//
+// Parameters:
+// - `aListener`: The listener accepting the proxy's connections.
+// - `aTimeout`: Max. time to wait for the PROXY header.
+//
+// Returns:
+// - `net.Listener`: The (augmented) `aListener`.
+func NewProxyListener(aListener net.Listener, aTimeout time.Duration) net.Listener {
+	if 0 >= aTimeout {
+		aTimeout = alProxyTimeout
+	}
+
+	return &tProxyListener{
+		Listener: aListener,
+		timeout:  aTimeout,
+		conns:    make(chan net.Conn),
+		errs:     make(chan error),
+		done:     make(chan struct{}),
+	}
+} // NewProxyListener()
+
+/* _EoF_ */
@@ -0,0 +1,134 @@
+/*
+Copyright © 2024  M.Watermann, 10247 Berlin, Germany
+
+	    All rights reserved
+	EMail : <support@mwat.de>
+*/
+package apachelogger
+
+import (
+	"bufio"
+	"io"
+	"net"
+	"testing"
+	"time"
+)
+
+//lint:file-ignore ST1017 – I prefer Yoda conditions
+
+func Test_tProxyConn(t *testing.T) {
+	v2 := append([]byte{}, alProxyV2Sig...)
+	v2 = append(v2, 0x21, 0x11, 0, 12, // PROXY, TCP4, length
+		192, 168, 0, 1, 10, 0, 0, 1, 0xDC, 0x04, 0x01, 0xBB)
+
+	tests := []struct {
+		name       string
+		data       []byte
+		wantRemote string
+		wantLocal  string
+	}{
+		{" 1", []byte("PROXY TCP4 192.168.0.1 10.0.0.1 56324 443\r\nGET"),
+			"192.168.0.1:56324", "10.0.0.1:443"},
+		{" 2", []byte("PROXY TCP6 2001:db8::1 2001:db8::2 56324 443\r\nGET"),
+			"[2001:db8::1]:56324", "[2001:db8::2]:443"},
+		{" 3", []byte("PROXY UNKNOWN\r\nGET"), "pipe", "pipe"},
+		{" 4", append(v2, "GET"...), "192.168.0.1:56324", "10.0.0.1:443"},
+		{" 5", []byte("GET"), "pipe", "pipe"},
+		// TODO: Add test cases.
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c1, c2 := net.Pipe()
+			defer c1.Close()
+			go func() {
+				_, _ = c2.Write(tt.data)
+				_ = c2.Close()
+			}()
+			pc := &tProxyConn{
+				Conn:    c1,
+				reader:  bufio.NewReader(c1),
+				timeout: time.Second,
+			}
+			pc.readHeader()
+
+			if got := pc.RemoteAddr().String(); got != tt.wantRemote {
+				t.Errorf("%q: RemoteAddr() = %q, want %q",
+					tt.name, got, tt.wantRemote)
+			}
+			if got := pc.LocalAddr().String(); got != tt.wantLocal {
+				t.Errorf("%q: LocalAddr() = %q, want %q",
+					tt.name, got, tt.wantLocal)
+			}
+			rest, err := io.ReadAll(pc)
+			if nil != err {
+				t.Fatalf("%q: Read() error = %v", tt.name, err)
+			}
+			if "GET" != string(rest) {
+				t.Errorf("%q: Read() = %q, want %q", tt.name, rest, "GET")
+			}
+		})
+	}
+} // Test_tProxyConn()
+
+func Test_readProxyV1_malformed(t *testing.T) {
+	c1, c2 := net.Pipe()
+	defer c1.Close()
+	go func() {
+		_, _ = c2.Write([]byte("PROXY TCP4 not-an-address\r\n"))
+		_ = c2.Close()
+	}()
+	pc := &tProxyConn{Conn: c1, reader: bufio.NewReader(c1), timeout: time.Second}
+	pc.readHeader()
+
+	if _, err := pc.Read(make([]byte, 8)); errProxyHeader != err {
+		t.Errorf("Read() error = %v, want %v", err, errProxyHeader)
+	}
+} // Test_readProxyV1_malformed()
+
+func TestNewProxyListener(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if nil != err {
+		t.Fatal(err)
+	}
+	pl := NewProxyListener(ln, 0)
+	defer pl.Close()
+	if got := pl.(*tProxyListener).timeout; alProxyTimeout != got {
+		t.Errorf("timeout = %v, want %v", got, alProxyTimeout)
+	}
+
+	// a client sending nothing mustn't delay the next one:
+	silent, err := net.Dial("tcp", ln.Addr().String())
+	if nil != err {
+		t.Fatal(err)
+	}
+	defer silent.Close()
+	client, err := net.Dial("tcp", ln.Addr().String())
+	if nil != err {
+		t.Fatal(err)
+	}
+	defer client.Close()
+	_, _ = client.Write([]byte("PROXY TCP4 192.168.0.1 10.0.0.1 56324 443\r\n"))
+
+	accepted := make(chan net.Conn, 1)
+	go func() {
+		if conn, err := pl.Accept(); nil == err {
+			accepted <- conn
+		}
+	}()
+	select {
+	case conn := <-accepted:
+		defer conn.Close()
+		if got := conn.RemoteAddr().String(); "192.168.0.1:56324" != got {
+			t.Errorf("RemoteAddr() = %q, want %q", got, "192.168.0.1:56324")
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Accept() blocked by a silent client")
+	}
+
+	_ = pl.Close()
+	if _, err := pl.Accept(); nil == err {
+		t.Error("Accept() error = nil for a closed listener")
+	}
+} // TestNewProxyListener()
+
+/* _EoF_ */
@@ -0,0 +1,373 @@
+/*
+Copyright © 2024  M.Watermann, 10247 Berlin, Germany
+
+	    All rights reserved
+	EMail : <support@mwat.de>
+*/
+package apachelogger
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+//lint:file-ignore ST1017 – I prefer Yoda conditions
+
+type (
+	// `TPubSubOptions` configures a Google Cloud Pub/Sub sink.
+	TPubSubOptions struct {
+		// The Google Cloud project owning the topic.
+		Project string
+
+		// The topic to publish to.
+		Topic string
+
+		// The ordering key of all messages (optional); message ordering
+		// must be enabled for the subscriptions, and a regional
+		// `Endpoint` should be used.
+		OrderingKey string
+
+		// Max. number of messages per publish request (default: 100,
+		// max: 1000).
+		BatchSize int
+
+		// Max. time to keep messages before publishing them (default:
+		// 1 second).
+		BatchDelay time.Duration
+
+		// The Pub/Sub API endpoint (default:
+		// `https://pubsub.googleapis.com`).
+		Endpoint string
+
+		// `Token` returns an OAuth2 access token for the API; if `nil`
+		// the token of the instance's default service account is
+		// fetched from the GCE metadata server.
+		Token func() (string, error)
+
+		// The HTTP client to use (default: a client with a 10 second
+		// timeout).
+		Client *http.Client
+	}
+
+	// `tPubSubMessage` is a single message of a publish request.
+	tPubSubMessage struct {
+		Data        string `json:"data"`
+		OrderingKey string `json:"orderingKey,omitempty"`
+	}
+
+	// `tPubSubSink` publishes log entries to a Pub/Sub topic.
+	tPubSubSink struct {
+		mtx       sync.Mutex       // guard for the fields below
+		opts      TPubSubOptions   // the sink's configuration
+		pending   []tPubSubMessage // messages not yet published
+		since     time.Time        // time the oldest pending message was added
+		timer     *time.Timer      // publishes the pending messages in time
+		failed    error            // error of the timer's last publishing
+		token     string           // the cached access token
+		tokenEnds time.Time        // time the cached token expires
+	}
+)
+
+const (
+	// URL of the GCE metadata server's access tokens.
+	alMetadataTokenURL = "http://metadata.google.internal/computeMetadata/v1/instance/service-accounts/default/token"
+)
+
+// `accessToken()` returns an OAuth2 access token for the API.
+//
+// Returns:
+// - `string`: The access token.
+// - `error`: A possible error fetching the token.
+func (ps *tPubSubSink) accessToken() (string, error) {
+	if nil != ps.opts.Token {
+		return ps.opts.Token()
+	}
+	if ("" != ps.token) && time.Now().Before(ps.tokenEnds) {
+		return ps.token, nil
+	}
+
+	req, err := http.NewRequest(http.MethodGet, alMetadataTokenURL, nil)
+	if nil != err {
+		return "", err
+	}
+	req.Header.Set("Metadata-Flavor", "Google")
+	resp, err := ps.opts.Client.Do(req)
+	if nil != err {
+		return "", err
+	}
+	defer resp.Body.Close()
+	if http.StatusOK != resp.StatusCode {
+		return "", fmt.Errorf("apachelogger: metadata server: %s", resp.Status)
+	}
+
+	var token struct {
+		AccessToken string `json:"access_token"`
+		ExpiresIn   int    `json:"expires_in"`
+	}
+	if err = json.NewDecoder(resp.Body).Decode(&token); nil != err {
+		return "", err
+	}
+	ps.token = token.AccessToken
+	// renew the token a minute before it expires
+	ps.tokenEnds = time.Now().Add(time.Duration(token.ExpiresIn-60) * time.Second)
+
+	return ps.token, nil
+} // accessToken()
+
+// `Close()` publishes all pending messages.
+//
+// Part of the `TSink` interface.
+//
+// Returns:
+// - `error`: A possible error publishing the messages.
+func (ps *tPubSubSink) Close() error {
+	ps.mtx.Lock()
+	defer ps.mtx.Unlock()
+
+	if nil != ps.timer {
+		ps.timer.Stop()
+		ps.timer = nil
+	}
+
+	return ps.reported(ps.publish())
+} // Close()
+
+// `Flush()` publishes the pending messages once the batch delay has
+// elapsed.
+//
+// Part of the `TSink` interface.
+//
+// Returns:
+// - `error`: A possible error publishing the messages.
+func (ps *tPubSubSink) Flush() error {
+	ps.mtx.Lock()
+	defer ps.mtx.Unlock()
+
+	if (0 == len(ps.pending)) || (ps.opts.BatchDelay > time.Since(ps.since)) {
+		return ps.reported(nil)
+	}
+
+	return ps.reported(ps.publish())
+} // Flush()
+
+// `publishDue()` publishes the pending messages when the batch delay
+// has elapsed without a further write.
+//
+// A failure is reported by the next call of `Write()`, `Flush()`, or
+// `Close()`; the messages are tried again after another delay.
+func (ps *tPubSubSink) publishDue() {
+	ps.mtx.Lock()
+	defer ps.mtx.Unlock()
+
+	ps.timer = nil
+	if (0 == len(ps.pending)) || (ps.opts.BatchDelay > time.Since(ps.since)) {
+		ps.schedule()
+		return
+	}
+	if err := ps.publish(); nil != err {
+		ps.failed = err
+		ps.since = time.Now()
+	}
+	ps.schedule()
+} // publishDue()
+
+// `publish()` sends all pending messages to the topic.
+//
+// If publishing fails the messages are kept for the next attempt (up
+// to ten batches; older messages are dropped).
+//
+// Returns:
+// - `error`: A possible error publishing the messages.
+func (ps *tPubSubSink) publish() error {
+	for 0 < len(ps.pending) {
+		count := len(ps.pending)
+		if ps.opts.BatchSize < count {
+			count = ps.opts.BatchSize
+		}
+		if err := ps.send(ps.pending[:count]); nil != err {
+			if limit := 10 * ps.opts.BatchSize; limit < len(ps.pending) {
+				ps.pending = ps.pending[len(ps.pending)-limit:]
+			}
+			return err
+		}
+		ps.pending = ps.pending[count:]
+	}
+	ps.pending = nil
+
+	return nil
+} // publish()
+
+// `reported()` returns `aErr` or – if `nil` – the error of the timer's
+// last publishing (see `publishDue()`), which is reported only once.
+//
+// The caller must hold `ps.mtx`.
+//
+// Parameters:
+// - `aErr`: The error of the current operation.
+//
+// Returns:
+// - `error`: The error to report.
+func (ps *tPubSubSink) reported(aErr error) error {
+	if nil == aErr {
+		aErr = ps.failed
+	}
+	ps.failed = nil
+
+	return aErr
+} // reported()
+
+// `schedule()` starts the timer publishing the pending messages once
+// the batch delay has elapsed (if it's not running already).
+//
+// The caller must hold `ps.mtx`.
+func (ps *tPubSubSink) schedule() {
+	if (nil != ps.timer) || (0 == len(ps.pending)) {
+		return
+	}
+	ps.timer = time.AfterFunc(ps.opts.BatchDelay-time.Since(ps.since), ps.publishDue)
+} // schedule()
+
+// `send()` publishes `aMessages` with a single API request.
+//
+// Parameters:
+// - `aMessages`: The messages to publish.
+//
+// Returns:
+// - `error`: A possible error publishing the messages.
+func (ps *tPubSubSink) send(aMessages []tPubSubMessage) error {
+	token, err := ps.accessToken()
+	if nil != err {
+		return err
+	}
+	body, err := json.Marshal(struct {
+		Messages []tPubSubMessage `json:"messages"`
+	}{aMessages})
+	if nil != err {
+		return err
+	}
+
+	target := fmt.Sprintf("%s/v1/projects/%s/topics/%s:publish",
+		ps.opts.Endpoint, url.PathEscape(ps.opts.Project),
+		url.PathEscape(ps.opts.Topic))
+	req, err := http.NewRequest(http.MethodPost, target, bytes.NewReader(body))
+	if nil != err {
+		return err
+	}
+	req.Header.Set("Authorization", "Bearer "+token)
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := ps.opts.Client.Do(req)
+	if nil != err {
+		return err
+	}
+	defer resp.Body.Close()
+	if http.StatusOK != resp.StatusCode {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("apachelogger: Pub/Sub publish: %s: %s",
+			resp.Status, strings.TrimSpace(string(msg)))
+	}
+	_, _ = io.Copy(io.Discard, resp.Body)
+
+	return nil
+} // send()
+
+// `Write()` adds each line of `aData` as a message to the current
+// batch, publishing the batch once it's full.
+//
+// Part of the `TSink` interface.
+//
+// Parameters:
+// - `aData`: The log entries to publish.
+//
+// Returns:
+// - `error`: A possible error publishing the messages.
+func (ps *tPubSubSink) Write(aData []byte) error {
+	ps.mtx.Lock()
+	defer ps.mtx.Unlock()
+
+	if 0 == len(ps.pending) {
+		ps.since = time.Now()
+	}
+	for 0 < len(aData) {
+		line := aData
+		if idx := bytes.IndexByte(aData, '\n'); 0 <= idx {
+			line, aData = aData[:idx], aData[idx+1:]
+		} else {
+			aData = nil
+		}
+		if 0 == len(line) {
+			continue
+		}
+		ps.pending = append(ps.pending, tPubSubMessage{
+			Data:        base64.StdEncoding.EncodeToString(line),
+			OrderingKey: ps.opts.OrderingKey,
+		})
+	}
+	if ps.opts.BatchSize > len(ps.pending) {
+		ps.schedule()
+		return ps.reported(nil)
+	}
+
+	return ps.reported(ps.publish())
+} // Write()
+
+/* * * * * * * * * * * * * * * * * * * * * * * * * * * * * * * * * * * * * */
+
+// `NewPubSubSink()` returns a sink publishing log entries to a Google
+// Cloud Pub/Sub topic (e.g. for streaming them into BigQuery via a
+// BigQuery subscription).
+//
+// Each log entry (i.e. each line of the batches written by the
+// background writer, see `MaxBatchBytes`) is published as a single
+// message without the trailing newline. The messages are collected
+// and published in batches of `BatchSize` messages or whenever the
+// oldest message waits longer than `BatchDelay` (even if nothing else
+// is logged meanwhile); a failure of such a delayed publishing is
+// reported by the sink's next write.
+//
+// The sink uses the Pub/Sub REST API; authentication is done with the
+// access tokens provided by `aOptions.Token` or – on Google Cloud –
+// the instance's default service account.
+//
+// Example:
+//
+//	sink := apachelogger.NewPubSubSink(apachelogger.TPubSubOptions{
+//		Project: "my-project",
+//		Topic:   "access-log",
+//	})
+//	handler := apachelogger.WrapSinks(pageHandler, sink, nil)
+//
+// Parameters:
+// - `aOptions`: The sink's configuration.
+//
+// Returns:
+// - `TSink`: The sink to use with `WrapSinks()`.
+func NewPubSubSink(aOptions TPubSubOptions) TSink {
+	if 0 >= aOptions.BatchSize {
+		aOptions.BatchSize = 100
+	} else if 1000 < aOptions.BatchSize {
+		aOptions.BatchSize = 1000
+	}
+	if 0 >= aOptions.BatchDelay {
+		aOptions.BatchDelay = time.Second
+	}
+	if "" == aOptions.Endpoint {
+		aOptions.Endpoint = "https://pubsub.googleapis.com"
+	}
+	aOptions.Endpoint = strings.TrimSuffix(aOptions.Endpoint, "/")
+	if nil == aOptions.Client {
+		aOptions.Client = &http.Client{Timeout: 10 * time.Second}
+	}
+
+	return &tPubSubSink{opts: aOptions}
+} // NewPubSubSink()
+
+/* _EoF_ */
@@ -0,0 +1,191 @@
+/*
+Copyright © 2024  M.Watermann, 10247 Berlin, Germany
+
+	    All rights reserved
+	EMail : <support@mwat.de>
+*/
+package apachelogger
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+)
+
+//lint:file-ignore ST1017 – I prefer Yoda conditions
+
+func TestNewPubSubSink(t *testing.T) {
+	var (
+		mtx     sync.Mutex
+		batches [][]tPubSubMessage
+		fail    bool
+	)
+	srv := httptest.NewServer(http.HandlerFunc(func(aWriter http.ResponseWriter, aRequest *http.Request) {
+		if "/v1/projects/proj/topics/logs:publish" != aRequest.URL.Path {
+			t.Errorf("path = %q", aRequest.URL.Path)
+		}
+		if "Bearer secret" != aRequest.Header.Get("Authorization") {
+			t.Errorf("Authorization = %q", aRequest.Header.Get("Authorization"))
+		}
+		var body struct {
+			Messages []tPubSubMessage `json:"messages"`
+		}
+		if err := json.NewDecoder(aRequest.Body).Decode(&body); nil != err {
+			t.Error(err)
+		}
+		mtx.Lock()
+		defer mtx.Unlock()
+		if fail {
+			http.Error(aWriter, "unavailable", http.StatusServiceUnavailable)
+			return
+		}
+		batches = append(batches, body.Messages)
+		_, _ = aWriter.Write([]byte(`{"messageIds":["1"]}`))
+	}))
+	defer srv.Close()
+
+	sink := NewPubSubSink(TPubSubOptions{
+		Project:     "proj",
+		Topic:       "logs",
+		OrderingKey: "web1",
+		BatchSize:   2,
+		BatchDelay:  time.Hour,
+		Endpoint:    srv.URL + "/",
+		Token:       func() (string, error) { return "secret", nil },
+	})
+
+	for _, line := range []string{"one\n", "two\n", "three\n"} {
+		if err := sink.Write([]byte(line)); nil != err {
+			t.Fatalf("Write() error = %v", err)
+		}
+	}
+	if err := sink.Flush(); nil != err { // batch delay not yet elapsed
+		t.Fatalf("Flush() error = %v", err)
+	}
+	if 1 != len(batches) || 2 != len(batches[0]) {
+		t.Fatalf("batches = %v, want one batch of 2", batches)
+	}
+	data, _ := base64.StdEncoding.DecodeString(batches[0][1].Data)
+	if "two" != string(data) || "web1" != batches[0][1].OrderingKey {
+		t.Errorf("message = %q/%q, want %q/%q",
+			data, batches[0][1].OrderingKey, "two", "web1")
+	}
+
+	mtx.Lock()
+	fail = true
+	mtx.Unlock()
+	if err := sink.Close(); nil == err {
+		t.Error("Close() expected an error")
+	}
+	mtx.Lock()
+	fail = false
+	mtx.Unlock()
+	if err := sink.Close(); nil != err {
+		t.Fatalf("Close() error = %v", err)
+	}
+	if 2 != len(batches) || 1 != len(batches[1]) {
+		t.Errorf("batches = %v, want a second batch of 1", batches)
+	}
+} // TestNewPubSubSink()
+
+func TestNewPubSubSink_batched(t *testing.T) {
+	var (
+		mtx      sync.Mutex
+		messages []string
+	)
+	srv := httptest.NewServer(http.HandlerFunc(func(aWriter http.ResponseWriter, aRequest *http.Request) {
+		var body struct {
+			Messages []tPubSubMessage `json:"messages"`
+		}
+		if err := json.NewDecoder(aRequest.Body).Decode(&body); nil != err {
+			t.Error(err)
+		}
+		mtx.Lock()
+		defer mtx.Unlock()
+		for _, msg := range body.Messages {
+			data, _ := base64.StdEncoding.DecodeString(msg.Data)
+			messages = append(messages, string(data))
+		}
+		_, _ = aWriter.Write([]byte(`{"messageIds":["1"]}`))
+	}))
+	defer srv.Close()
+
+	sink := NewPubSubSink(TPubSubOptions{
+		Project:    "proj",
+		Topic:      "logs",
+		BatchSize:  2,
+		BatchDelay: time.Hour,
+		Endpoint:   srv.URL,
+		Token:      func() (string, error) { return "secret", nil },
+	})
+	queue := newRing(8)
+	for _, line := range []string{"one\n", "two\n", "three\n", "four\n", "five\n"} {
+		queue.push(line)
+	}
+	queue.close()
+	goDoLogWrite(sink, queue) // writes all entries with a single `Write()`
+
+	mtx.Lock()
+	defer mtx.Unlock()
+	if 5 != len(messages) {
+		t.Fatalf("published %d messages %q, want 5", len(messages), messages)
+	}
+	if "one" != messages[0] || "five" != messages[4] {
+		t.Errorf("messages = %q", messages)
+	}
+} // TestNewPubSubSink_batched()
+
+func TestNewPubSubSink_delayed(t *testing.T) {
+	published := make(chan int, 4)
+	fail := make(chan bool, 1)
+	fail <- true
+	srv := httptest.NewServer(http.HandlerFunc(func(aWriter http.ResponseWriter, aRequest *http.Request) {
+		var body struct {
+			Messages []tPubSubMessage `json:"messages"`
+		}
+		_ = json.NewDecoder(aRequest.Body).Decode(&body)
+		select {
+		case <-fail:
+			http.Error(aWriter, "unavailable", http.StatusServiceUnavailable)
+			return
+		default:
+		}
+		published <- len(body.Messages)
+		_, _ = aWriter.Write([]byte(`{"messageIds":["1"]}`))
+	}))
+	defer srv.Close()
+
+	sink := NewPubSubSink(TPubSubOptions{
+		Project:    "proj",
+		Topic:      "logs",
+		BatchDelay: 10 * time.Millisecond,
+		Endpoint:   srv.URL,
+		Token:      func() (string, error) { return "secret", nil },
+	})
+	defer sink.Close()
+
+	// nothing else is written or flushed after these messages:
+	if err := sink.Write([]byte("one\ntwo\n")); nil != err {
+		t.Fatalf("Write() error = %v", err)
+	}
+	select {
+	case got := <-published: // after the failed first attempt
+		if 2 != got {
+			t.Errorf("published %d messages, want 2", got)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("the pending messages weren't published after the delay")
+	}
+	if err := sink.Write([]byte("three\n")); nil == err {
+		t.Error("Write() error = nil, want the delayed publishing's failure")
+	}
+	if err := sink.Flush(); nil != err {
+		t.Errorf("Flush() error = %v, want the failure reported once", err)
+	}
+} // TestNewPubSubSink_delayed()
+
+/* _EoF_ */
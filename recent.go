@@ -0,0 +1,186 @@
+/*
+Copyright © 2024  M.Watermann, 10247 Berlin, Germany
+
+	    All rights reserved
+	EMail : <support@mwat.de>
+*/
+package apachelogger
+
+import (
+	"strings"
+	"sync"
+	"time"
+)
+
+//lint:file-ignore ST1017 – I prefer Yoda conditions
+
+type (
+	// `TRecentFilter` selects entries returned by `Recent()`.
+	//
+	// Zero values match all entries.
+	TRecentFilter struct {
+		MinStatus  int       // lowest HTTP status code to return
+		MaxStatus  int       // highest HTTP status code to return
+		Method     string    // request method to return
+		PathPrefix string    // start of the requested paths to return
+		Remote     string    // (anonymised) remote address to return
+		Since      time.Time // earliest access time to return
+		Limit      int       // max. number of (the latest) entries to return
+	}
+
+	// `tRecent` is a ring buffer of the latest access log entries.
+	tRecent struct {
+		sync.RWMutex
+		entries []*TEntry // the stored entries
+		next    int       // index of the slot to write next
+		full    bool      // all slots are used
+	}
+)
+
+var (
+	// The latest access log entries (see `SetRecentSize()`).
+	alRecent tRecent
+)
+
+// `Match()` reports whether `aEntry` is selected by the filter.
+//
+// Parameters:
+// - `aEntry`: The log entry to check.
+//
+// Returns:
+// - `bool`: `true` if the entry matches all criteria.
+func (rf *TRecentFilter) Match(aEntry *TEntry) bool {
+	switch {
+	case (0 < rf.MinStatus) && (aEntry.Status < rf.MinStatus),
+		(0 < rf.MaxStatus) && (aEntry.Status > rf.MaxStatus),
+		("" != rf.Method) && !strings.EqualFold(rf.Method, aEntry.Method),
+		("" != rf.PathPrefix) && !strings.HasPrefix(aEntry.Path, rf.PathPrefix),
+		("" != rf.Remote) && (rf.Remote != aEntry.Remote),
+		!rf.Since.IsZero() && aEntry.When.Before(rf.Since):
+		return false
+	}
+
+	return true
+} // Match()
+
+// `add()` stores `aEntry` replacing the oldest entry if all slots are
+// used.
+//
+// Parameters:
+// - `aEntry`: The log entry to store (not to be modified afterwards).
+func (r *tRecent) add(aEntry *TEntry) {
+	r.Lock()
+	defer r.Unlock()
+
+	if 0 == len(r.entries) {
+		return
+	}
+	r.entries[r.next] = aEntry
+	if r.next++; len(r.entries) == r.next {
+		r.next, r.full = 0, true
+	}
+} // add()
+
+// `query()` returns the stored entries matching `aFilter`, oldest first.
+//
+// Parameters:
+// - `aFilter`: The criteria to select the entries.
+//
+// Returns:
+// - `[]*TEntry`: The selected entries (not to be modified).
+func (r *tRecent) query(aFilter TRecentFilter) []*TEntry {
+	r.RLock()
+	defer r.RUnlock()
+
+	var result []*TEntry
+	count := r.next
+	if r.full {
+		count = len(r.entries)
+	}
+	// walk backwards from the latest entry to respect `Limit`:
+	for idx := 0; idx < count; idx++ {
+		entry := r.entries[(r.next-1-idx+len(r.entries))%len(r.entries)]
+		if !aFilter.Match(entry) {
+			continue
+		}
+		result = append(result, entry)
+		if (0 < aFilter.Limit) && (aFilter.Limit == len(result)) {
+			break
+		}
+	}
+	for left, right := 0, len(result)-1; left < right; left, right = left+1, right-1 {
+		result[left], result[right] = result[right], result[left]
+	}
+
+	return result
+} // query()
+
+// `resize()` changes the number of slots keeping the latest entries.
+//
+// Parameters:
+// - `aSize`: The new number of slots.
+func (r *tRecent) resize(aSize int) {
+	if 0 > aSize {
+		aSize = 0
+	}
+	latest := r.query(TRecentFilter{Limit: aSize})
+
+	r.Lock()
+	defer r.Unlock()
+
+	r.entries = make([]*TEntry, aSize)
+	r.next = copy(r.entries, latest)
+	if r.full = (0 < aSize) && (aSize == r.next); r.full {
+		r.next = 0
+	}
+} // resize()
+
+/* * * * * * * * * * * * * * * * * * * * * * * * * * * * * * * * * * * * * */
+
+// `Recent()` returns copies of the latest access log entries matching
+// `aFilter`, oldest first.
+//
+// The entries are kept in memory after the privacy settings were
+// applied (e.g. with anonymised addresses), so debugging endpoints can
+// show recent traffic without re-reading the logfiles.
+//
+// Example:
+//
+//	errors := apachelogger.Recent(apachelogger.TRecentFilter{
+//		MinStatus: 500,
+//		Limit:     20,
+//	})
+//
+// Parameters:
+// - `aFilter`: The criteria to select the entries.
+//
+// Returns:
+// - `[]TEntry`: The selected entries (empty if `SetRecentSize()` wasn't called).
+func Recent(aFilter TRecentFilter) []TEntry {
+	entries := alRecent.query(aFilter)
+	result := make([]TEntry, len(entries))
+	for idx, entry := range entries {
+		result[idx] = *entry
+		if nil != entry.Fields {
+			result[idx].Fields = make(map[string]string, len(entry.Fields))
+			for key, value := range entry.Fields {
+				result[idx].Fields[key] = value
+			}
+		}
+		result[idx].headers = nil
+	}
+
+	return result
+} // Recent()
+
+// `SetRecentSize()` sets the number of the latest access log entries
+// kept in memory for `Recent()` and `TailHandler()` (default: `0`,
+// i.e. none).
+//
+// Parameters:
+// - `aSize`: The number of entries to keep.
+func SetRecentSize(aSize int) {
+	alRecent.resize(aSize)
+} // SetRecentSize()
+
+/* _EoF_ */
@@ -0,0 +1,65 @@
+/*
+Copyright © 2024  M.Watermann, 10247 Berlin, Germany
+
+	    All rights reserved
+	EMail : <support@mwat.de>
+*/
+package apachelogger
+
+import (
+	"testing"
+)
+
+//lint:file-ignore ST1017 – I prefer Yoda conditions
+
+func TestRecent(t *testing.T) {
+	defer SetRecentSize(0)
+
+	alRecent.add(prepEntry()) // ignored without slots
+	if got := Recent(TRecentFilter{}); 0 != len(got) {
+		t.Errorf("Recent() = %d entries, want 0", len(got))
+	}
+
+	SetRecentSize(3)
+	for idx, status := range []int{200, 404, 500, 200} {
+		entry := prepEntry()
+		entry.Status = status
+		entry.Size = idx
+		alRecent.add(entry)
+	}
+
+	tests := []struct {
+		name   string
+		filter TRecentFilter
+		want   []int // sizes of the expected entries
+	}{
+		{"all", TRecentFilter{}, []int{1, 2, 3}},
+		{"errors", TRecentFilter{MinStatus: 400}, []int{1, 2}},
+		{"client errors", TRecentFilter{MinStatus: 400, MaxStatus: 499}, []int{1}},
+		{"limit", TRecentFilter{Limit: 2}, []int{2, 3}},
+		{"path", TRecentFilter{PathPrefix: "/path/"}, []int{1, 2, 3}},
+		{"other path", TRecentFilter{PathPrefix: "/other"}, nil},
+		{"remote", TRecentFilter{Remote: "10.0.0.0"}, nil},
+		{"method", TRecentFilter{Method: "get"}, []int{1, 2, 3}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := Recent(tt.filter)
+			if len(got) != len(tt.want) {
+				t.Fatalf("Recent() = %d entries, want %d", len(got), len(tt.want))
+			}
+			for idx, size := range tt.want {
+				if got[idx].Size != size {
+					t.Errorf("Recent()[%d].Size = %d, want %d", idx, got[idx].Size, size)
+				}
+			}
+		})
+	}
+
+	SetRecentSize(2) // keeps the latest entries
+	if got := Recent(TRecentFilter{}); (2 != len(got)) || (3 != got[1].Size) {
+		t.Errorf("Recent() after resize = %v", got)
+	}
+} // TestRecent()
+
+/* _EoF_ */
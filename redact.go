@@ -0,0 +1,126 @@
+/*
+Copyright © 2024  M.Watermann, 10247 Berlin, Germany
+
+	    All rights reserved
+	EMail : <support@mwat.de>
+*/
+package apachelogger
+
+import (
+	"fmt"
+	"regexp"
+	"sync"
+)
+
+//lint:file-ignore ST1017 – I prefer Yoda conditions
+
+type (
+	// `tRedaction` is a single redaction rule.
+	tRedaction struct {
+		field       string         // name of the field to redact or `*`
+		pattern     *regexp.Regexp // the text to replace
+		replacement string         // the replacement text
+	}
+)
+
+var (
+	// Names of all entry fields subject to a `*` redaction rule.
+	alRedactFields = []string{
+		"remote", "user", "method", "path", "proto", "referrer", "agent",
+	}
+
+	// List of registered redaction rules.
+	alRedactions []tRedaction
+
+	// Guard for concurrent access to `alRedactions`.
+	alRedactionsMtx sync.RWMutex
+)
+
+// `AddRedaction()` registers a rule replacing all matches of `aPattern`
+// in the log entries' field `aField` by `aReplacement`.
+//
+// Valid field names are `remote`, `user`, `method`, `path`, `proto`,
+// `referrer`, and `agent`; the name `*` applies the rule to all of
+// these fields as well as to the additional fields (see `SetField()`)
+// and the request headers logged by `%{name}i` (see `SetLogFormat()`).
+// The `aReplacement` text may refer to submatches as
+// described for `regexp.Regexp.ReplaceAllString()`.
+//
+// Rules are applied in order of registration after all transformers
+// (see `AddTransformer()`) have run.
+//
+// Example (masking email addresses anywhere in the entries):
+//
+//	err := apachelogger.AddRedaction("*",
+//		`[^@/?&=\s]+@[^@/?&=\s]+\.[a-z]{2,}`, "<email>")
+//
+// Parameters:
+// - `aField`: The name of the field to redact.
+// - `aPattern`: The regular expression to look for.
+// - `aReplacement`: The text to replace the matches with.
+//
+// Returns:
+// - `error`: A possible error parsing `aPattern` or an unknown field.
+func AddRedaction(aField, aPattern, aReplacement string) error {
+	if "*" != aField {
+		if nil == (&TEntry{}).stringField(aField) {
+			return fmt.Errorf("apachelogger: unknown field name %q", aField)
+		}
+	}
+	re, err := regexp.Compile(aPattern)
+	if nil != err {
+		return err
+	}
+
+	alRedactionsMtx.Lock()
+	alRedactions = append(alRedactions, tRedaction{aField, re, aReplacement})
+	alRedactionsMtx.Unlock()
+
+	return nil
+} // AddRedaction()
+
+// `ClearRedactions()` removes all registered redaction rules.
+func ClearRedactions() {
+	alRedactionsMtx.Lock()
+	alRedactions = nil
+	alRedactionsMtx.Unlock()
+} // ClearRedactions()
+
+// `applyRedactions()` applies all registered redaction rules to `aEntry`.
+//
+// Parameters:
+// - `aEntry`: The log entry to redact.
+func applyRedactions(aEntry *TEntry) {
+	alRedactionsMtx.RLock()
+	defer alRedactionsMtx.RUnlock()
+
+	for _, rule := range alRedactions {
+		if "*" != rule.field {
+			rule.redact(aEntry.stringField(rule.field))
+			continue
+		}
+		for _, name := range alRedactFields {
+			rule.redact(aEntry.stringField(name))
+		}
+		for name, value := range aEntry.headers {
+			rule.redact(&value)
+			aEntry.headers[name] = value
+		}
+		for key, value := range aEntry.Fields {
+			rule.redact(&value)
+			aEntry.Fields[key] = value
+		}
+	}
+} // applyRedactions()
+
+// `redact()` replaces all matches of the rule's pattern in `aText`.
+//
+// Parameters:
+// - `aText`: The field to redact.
+func (r tRedaction) redact(aText *string) {
+	if (nil != aText) && (0 < len(*aText)) {
+		*aText = r.pattern.ReplaceAllString(*aText, r.replacement)
+	}
+} // redact()
+
+/* _EoF_ */
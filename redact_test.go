@@ -0,0 +1,73 @@
+/*
+Copyright © 2024  M.Watermann, 10247 Berlin, Germany
+
+	    All rights reserved
+	EMail : <support@mwat.de>
+*/
+package apachelogger
+
+import (
+	"testing"
+)
+
+//lint:file-ignore ST1017 – I prefer Yoda conditions
+
+func TestAddRedaction(t *testing.T) {
+	defer ClearRedactions()
+
+	tests := []struct {
+		name    string
+		field   string
+		pattern string
+		wantErr bool
+	}{
+		{" 1", "path", `\d+`, false},
+		{" 2", "*", `x`, false},
+		{" 3", "unknown", `x`, true},
+		{" 4", "path", `(`, true},
+		// TODO: Add test cases.
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := AddRedaction(tt.field, tt.pattern, ""); (nil != err) != tt.wantErr {
+				t.Errorf("%q: AddRedaction() error = %v, wantErr %v",
+					tt.name, err, tt.wantErr)
+			}
+		})
+	}
+} // TestAddRedaction()
+
+func Test_applyRedactions(t *testing.T) {
+	defer ClearRedactions()
+	email := `[^@/?&=\s]+@[^@/?&=\s]+\.[a-z]{2,}`
+
+	_ = AddRedaction("*", email, "<email>")
+	_ = AddRedaction("path", `token=[^&]*`, "token=xxx")
+
+	e1 := prepEntry()
+	e1.Path = "/users/john@example.com?token=abc123&x=1"
+	e1.Referrer = "https://example.com/?mail=jane@example.org"
+	e1.Agent = "Mozilla/5.0"
+	applyRedactions(e1)
+
+	if w := "/users/<email>?token=xxx&x=1"; w != e1.Path {
+		t.Errorf("applyRedactions() Path = %q, want %q", e1.Path, w)
+	}
+	if w := "https://example.com/?mail=<email>"; w != e1.Referrer {
+		t.Errorf("applyRedactions() Referrer = %q, want %q", e1.Referrer, w)
+	}
+	if w := "Mozilla/5.0"; w != e1.Agent {
+		t.Errorf("applyRedactions() Agent = %q, want %q", e1.Agent, w)
+	}
+
+	e2 := prepEntry()
+	e2.headers = map[string]string{"X-User": "john@example.com"}
+	e2.SetField("login", "jane@example.org")
+	applyRedactions(e2)
+	if w := "<email>"; (w != e2.headers["X-User"]) || (w != e2.Fields["login"]) {
+		t.Errorf("applyRedactions() headers = %q, fields = %q, want %q",
+			e2.headers, e2.Fields, w)
+	}
+} // Test_applyRedactions()
+
+/* _EoF_ */
@@ -0,0 +1,268 @@
+/*
+Copyright © 2024  M.Watermann, 10247 Berlin, Germany
+
+	    All rights reserved
+	EMail : <support@mwat.de>
+*/
+package apachelogger
+
+import (
+	"bytes"
+	"fmt"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+//lint:file-ignore ST1017 – I prefer Yoda conditions
+
+type (
+	// `tRejectConn` is a connection watching for the requests rejected
+	// by `http.Server` before they reach the handler.
+	tRejectConn struct {
+		net.Conn
+		mtx    sync.Mutex   // guard for the other fields
+		fresh  bool         // the next read starts a new request
+		line   string       // the current request's (sanitised) line
+		server *http.Server // the server whose error log is used (may be `nil`)
+	}
+
+	// `tRejectListener` is a listener returning `tRejectConn` instances.
+	tRejectListener struct {
+		net.Listener
+		server *http.Server // the server whose error log is used (may be `nil`)
+	}
+)
+
+var (
+	// The headers `http.Server` sends with the responses rejecting
+	// malformed requests; a handler's response would (among others) have
+	// a `Date` header and its headers sorted.
+	alRejectHeaders = []byte("\r\nContent-Type: text/plain; charset=utf-8\r\nConnection: close\r\n\r\n")
+)
+
+// `rejectedResponse()` checks whether `aData` is a response written by
+// `http.Server` itself to reject a malformed request.
+//
+// Parameters:
+// - `aData`: The data written to the client connection.
+//
+// Returns:
+// - `int`: The response's status code.
+// - `string`: The reason given by the server (e.g. `invalid method "X"`).
+// - `bool`: `true` if `aData` is such a response.
+func rejectedResponse(aData []byte) (int, string, bool) {
+	if !bytes.HasPrefix(aData, []byte("HTTP/1.1 ")) {
+		return 0, "", false
+	}
+	idx := bytes.Index(aData, alRejectHeaders)
+	if 0 > idx {
+		return 0, "", false
+	}
+	statusLine := string(aData[len("HTTP/1.1 "):idx])
+	if strings.ContainsAny(statusLine, "\r\n") {
+		return 0, "", false // other headers in between
+	}
+	if 3 > len(statusLine) {
+		return 0, "", false
+	}
+	status, err := strconv.Atoi(statusLine[:3])
+	if (nil != err) || (400 > status) {
+		return 0, "", false
+	}
+
+	// The body repeats the status line, optionally followed by the
+	// reason, e.g. `400 Bad Request: malformed Host header`:
+	reason := string(aData[idx+len(alRejectHeaders):])
+	reason = strings.TrimPrefix(reason, statusLine)
+	reason = strings.TrimSpace(strings.TrimPrefix(reason, ":"))
+	if "" == reason {
+		reason = statusLine[3:]
+	}
+
+	return status, strings.TrimSpace(reason), true
+} // rejectedResponse()
+
+// `rejectedEntry()` returns the error log entry of a rejected request.
+//
+// Parameters:
+// - `aRemote`: The client's address.
+// - `aLine`: The (sanitised) request line.
+// - `aStatus`: The response's status code.
+// - `aReason`: The reason given by the server.
+// - `aTime`: The time to log.
+//
+// Returns:
+// - `*TEntry`: The log entry.
+func rejectedEntry(aRemote, aLine string, aStatus int, aReason string, aTime time.Time) *TEntry {
+	entry := customEntry("ApacheLogger/Rejected",
+		fmt.Sprintf("rejected request: %d %s", aStatus, aReason), `WARN`, aTime)
+	entry.Remote = anonymiseAddress(aRemote, 0)
+	entry.Status = aStatus
+	entry.SetField("status", strconv.Itoa(aStatus))
+	entry.SetField("reason", aReason)
+	if "" != aLine {
+		entry.SetField("request", aLine)
+	}
+
+	return entry
+} // rejectedEntry()
+
+// `requestLine()` returns the request line at the start of `aData`.
+//
+// The query is removed (it might carry personal data), the line is
+// cut to 128 bytes and any control or non-ASCII byte replaced by `?`.
+//
+// Parameters:
+// - `aData`: The data read from the client connection.
+//
+// Returns:
+// - `string`: The sanitised request line.
+func requestLine(aData []byte) string {
+	if idx := bytes.IndexAny(aData, "\r\n"); 0 <= idx {
+		aData = aData[:idx]
+	}
+	line := string(aData)
+	if idx := strings.IndexByte(line, '?'); 0 <= idx {
+		// drop the query but keep the protocol
+		if end := strings.LastIndexByte(line, ' '); end > idx {
+			line = line[:idx] + line[end:]
+		} else {
+			line = line[:idx]
+		}
+	}
+	if 128 < len(line) {
+		line = line[:128]
+	}
+	result := []byte(line)
+	for i, b := range result {
+		if (' ' > b) || ('~' < b) {
+			result[i] = '?'
+		}
+	}
+
+	return string(result)
+} // requestLine()
+
+/* * * * * * * * * * * * * * * * * * * * * * * * * * * * * * * * * * * * * */
+
+// `Accept()` waits for and returns the next connection.
+//
+// Part of the `net.Listener` interface.
+//
+// Returns:
+// - `net.Conn`: The accepted connection.
+// - `error`: A possible error accepting the connection.
+func (rl *tRejectListener) Accept() (net.Conn, error) {
+	conn, err := rl.Listener.Accept()
+	if nil != err {
+		return conn, err
+	}
+
+	return &tRejectConn{Conn: conn, fresh: true, server: rl.server}, nil
+} // Accept()
+
+/* * * * * * * * * * * * * * * * * * * * * * * * * * * * * * * * * * * * * */
+
+// `Read()` reads data from the connection, remembering the line of a
+// new request.
+//
+// Part of the `net.Conn` interface.
+//
+// Parameters:
+// - `aBuffer`: The buffer to read into.
+//
+// Returns:
+// - `int`: The number of bytes read.
+// - `error`: A possible error reading the data.
+func (rc *tRejectConn) Read(aBuffer []byte) (int, error) {
+	n, err := rc.Conn.Read(aBuffer)
+	if 0 < n {
+		rc.mtx.Lock()
+		if rc.fresh {
+			rc.fresh = false
+			rc.line = requestLine(aBuffer[:n])
+		}
+		rc.mtx.Unlock()
+	}
+
+	return n, err
+} // Read()
+
+// `Write()` writes data to the connection, logging the responses that
+// reject a request.
+//
+// Part of the `net.Conn` interface.
+//
+// Parameters:
+// - `aData`: The data to write.
+//
+// Returns:
+// - `int`: The number of bytes written.
+// - `error`: A possible error writing the data.
+func (rc *tRejectConn) Write(aData []byte) (int, error) {
+	rc.mtx.Lock()
+	line := rc.line
+	rc.fresh, rc.line = true, ""
+	rc.mtx.Unlock()
+
+	if status, reason, ok := rejectedResponse(aData); ok {
+		remote := rc.RemoteAddr().String()
+		spawnCustom(func(aNow time.Time) {
+			queueCustomEntry(rejectedEntry(remote, line, status, reason, aNow),
+				serverErrorQueue(rc.server))
+		})
+	}
+
+	return rc.Conn.Write(aData)
+} // Write()
+
+/* * * * * * * * * * * * * * * * * * * * * * * * * * * * * * * * * * * * * */
+
+// `NewRejectListener()` returns a listener whose connections log the
+// requests rejected by `http.Server` before they reach any handler,
+// e.g. because of an invalid method, a malformed `Host` header, too
+// large headers (`431`), or an unsupported `Transfer-Encoding` (`501`).
+//
+// Such requests never show up in the access log, since the server
+// answers them itself. With this listener each of them is written as
+// a `WARN` entry to the error log, carrying the anonymised client
+// address (see `AnonymiseURLs`) and the fields `status`, `reason`, and
+// `request` (the request line without its query), e.g.
+//
+//	srv.Serve(apachelogger.NewRejectListener(listener))
+//
+// Since the responses are recognised on the wire, only plain HTTP/1
+// connections are watched: `http.Server` needs the `*tls.Conn` itself
+// for TLS, so a TLS listener must not be wrapped. Wrapping the
+// `NewProxyListener()` listener logs the proxy's client addresses.
+//
+// Parameters:
+// - `aListener`: The listener accepting the clients' connections.
+//
+// Returns:
+// - `net.Listener`: The (augmented) `aListener`.
+func NewRejectListener(aListener net.Listener) net.Listener {
+	return &tRejectListener{Listener: aListener}
+} // NewRejectListener()
+
+// `NewRejectListenerFor()` works like `NewRejectListener()` but writes
+// the rejected requests to the error log of the wrapper set as
+// `aServer`'s `Handler` (see `SetErrorLog()`), e.g.
+//
+//	admin.Serve(apachelogger.NewRejectListenerFor(listener, admin))
+//
+// Parameters:
+// - `aListener`: The listener accepting the clients' connections.
+// - `aServer`: The server serving the connections.
+//
+// Returns:
+// - `net.Listener`: The (augmented) `aListener`.
+func NewRejectListenerFor(aListener net.Listener, aServer *http.Server) net.Listener {
+	return &tRejectListener{Listener: aListener, server: aServer}
+} // NewRejectListenerFor()
+
+/* _EoF_ */
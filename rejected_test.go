@@ -0,0 +1,160 @@
+/*
+Copyright © 2024  M.Watermann, 10247 Berlin, Germany
+
+	    All rights reserved
+	EMail : <support@mwat.de>
+*/
+package apachelogger
+
+import (
+	"io"
+	"net"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+//lint:file-ignore ST1017 – I prefer Yoda conditions
+
+func Test_rejectedResponse(t *testing.T) {
+	tests := []struct {
+		name       string
+		data       string
+		wantStatus int
+		wantReason string
+		wantOK     bool
+	}{
+		{" 1", "HTTP/1.1 400 Bad Request" + string(alRejectHeaders) +
+			`400 Bad Request: invalid method "X"`, 400, `invalid method "X"`, true},
+		{" 2", "HTTP/1.1 431 Request Header Fields Too Large" + string(alRejectHeaders) +
+			"431 Request Header Fields Too Large", 431, "Request Header Fields Too Large", true},
+		{" 3", "HTTP/1.1 400 Bad Request\r\nDate: Mon, 01 Jan 2024 00:00:00 GMT" +
+			string(alRejectHeaders) + "400 Bad Request", 0, "", false},
+		{" 4", "HTTP/1.1 200 OK\r\nContent-Length: 0\r\n\r\n", 0, "", false},
+		{" 5", "GET / HTTP/1.1\r\n\r\n", 0, "", false},
+		// TODO: Add test cases.
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			status, reason, ok := rejectedResponse([]byte(tt.data))
+			if (status != tt.wantStatus) || (reason != tt.wantReason) || (ok != tt.wantOK) {
+				t.Errorf("rejectedResponse() = %d, %q, %v, want %d, %q, %v",
+					status, reason, ok, tt.wantStatus, tt.wantReason, tt.wantOK)
+			}
+		})
+	}
+} // Test_rejectedResponse()
+
+func Test_requestLine(t *testing.T) {
+	tests := []struct {
+		name string
+		data string
+		want string
+	}{
+		{" 1", "GET /index.html HTTP/1.1\r\nHost: x\r\n\r\n", "GET /index.html HTTP/1.1"},
+		{" 2", "GET /search?name=secret HTTP/1.1\r\n", "GET /search HTTP/1.1"},
+		{" 3", "B\x01D / HTTP/1.1\r\n", "B?D / HTTP/1.1"},
+		{" 4", "GET /?q", "GET /"},
+		{" 5", strings.Repeat("A", 200), strings.Repeat("A", 128)},
+		// TODO: Add test cases.
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := requestLine([]byte(tt.data)); got != tt.want {
+				t.Errorf("requestLine() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+} // Test_requestLine()
+
+func Test_rejectedEntry(t *testing.T) {
+	entry := rejectedEntry("192.168.1.234:4711", "B?D / HTTP/1.1", 400,
+		`invalid method "B\x01D"`, time.Now())
+	if `WARN` != entry.Method {
+		t.Errorf("Method = %q, want %q", entry.Method, `WARN`)
+	}
+	if strings.Contains(entry.Remote, "234") || strings.Contains(entry.Remote, "4711") {
+		t.Errorf("Remote = %q, want an anonymised address", entry.Remote)
+	}
+	if "400" != entry.Fields["status"] || "B?D / HTTP/1.1" != entry.Fields["request"] {
+		t.Errorf("Fields = %v, want status and request", entry.Fields)
+	}
+} // Test_rejectedEntry()
+
+func TestNewRejectListener(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if nil != err {
+		t.Skipf("net.Listen() error = %v", err)
+	}
+	var handled bool
+	server := &http.Server{
+		Handler: http.HandlerFunc(func(aWriter http.ResponseWriter, aRequest *http.Request) {
+			handled = true
+		}),
+	}
+	go func() { _ = server.Serve(NewRejectListener(listener)) }()
+	defer server.Close()
+	queue := newRing(8)
+	defer setLogQueues(setLogQueues(nil, queue))
+
+	conn, err := net.Dial("tcp", listener.Addr().String())
+	if nil != err {
+		t.Fatalf("net.Dial() error = %v", err)
+	}
+	defer conn.Close()
+	_ = conn.SetDeadline(time.Now().Add(5 * time.Second))
+	if _, err = io.WriteString(conn, "GET / HTTP/1.1\r\nHost: a b\r\n\r\n"); nil != err {
+		t.Fatalf("Write() error = %v", err)
+	}
+	response, _ := io.ReadAll(conn)
+
+	// the server's own response has to be recognised:
+	status, reason, ok := rejectedResponse(response)
+	if !ok || (400 != status) || !strings.Contains(reason, "Host") {
+		t.Errorf("rejectedResponse(%q) = %d, %q, %v, want 400 and a reason",
+			response, status, reason, ok)
+	}
+	if handled {
+		t.Error("the rejected request reached the handler")
+	}
+
+	deadline := time.Now().Add(5 * time.Second)
+	for queue.isEmpty() && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+	lines := strings.Join(queue.popBatch(make([]string, 0, 8)), "")
+	if !strings.Contains(lines, "rejected request: 400") {
+		t.Errorf("error log = %q, want the rejected request", lines)
+	}
+} // TestNewRejectListener()
+
+func TestNewRejectListenerFor(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if nil != err {
+		t.Skipf("net.Listen() error = %v", err)
+	}
+	errLog := newRing(8)
+	server := &http.Server{
+		Handler: wrapQueues(http.NotFoundHandler(), newRing(8), errLog),
+	}
+	go func() { _ = server.Serve(NewRejectListenerFor(listener, server)) }()
+	defer server.Close()
+
+	conn, err := net.Dial("tcp", listener.Addr().String())
+	if nil != err {
+		t.Fatalf("net.Dial() error = %v", err)
+	}
+	defer conn.Close()
+	_ = conn.SetDeadline(time.Now().Add(5 * time.Second))
+	if _, err = io.WriteString(conn, "GET / HTTP/1.1\r\nHost: a b\r\n\r\n"); nil != err {
+		t.Fatalf("Write() error = %v", err)
+	}
+	_, _ = io.ReadAll(conn)
+
+	if got, ok := popWithin(errLog, 5*time.Second); !ok || !strings.Contains(got, "rejected request: 400") {
+		t.Errorf("wrapper's error log = %q, want the rejected request", got)
+	}
+} // TestNewRejectListenerFor()
+
+/* _EoF_ */
@@ -0,0 +1,169 @@
+/*
+Copyright © 2024  M.Watermann, 10247 Berlin, Germany
+
+	    All rights reserved
+	EMail : <support@mwat.de>
+*/
+package apachelogger
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sync"
+	"sync/atomic"
+)
+
+//lint:file-ignore ST1017 – I prefer Yoda conditions
+
+type (
+	// `tCtxKey` is the type of the context keys used by this package.
+	tCtxKey int
+
+	// `tRequestState` holds the logging state of a single request.
+	tRequestState struct {
+		correlation map[string]string // the request's correlation fields
+		fields      map[string]string // custom fields set by the handler
+		notes       map[string]string // notes set by other middlewares
+		mtx         sync.Mutex        // guard for `fields` and `notes`
+		suppressed  int32             // `1` if the request should not be logged
+		headerBytes int               // size of the request's headers
+		headerCount int               // number of the request's header lines
+		headerSized bool              // the headers were measured
+		keepAlive   uint32            // index of the request on its connection
+		keepAliveOK bool              // the connection's requests are counted
+	}
+)
+
+const (
+	// Context key of the `tRequestState` of the current request.
+	alStateKey tCtxKey = iota
+
+	// Context key of the `tPeerCred` of the current connection.
+	alPeerCredKey
+
+	// Context key of the request counter of the current connection.
+	alKeepAliveKey
+)
+
+// `requestState()` returns the logging state stored in `aContext`.
+//
+// Parameters:
+// - `aContext`: The context of the current request.
+//
+// Returns:
+// - `*tRequestState`: The request's logging state or `nil` if the
+// request was not passed through `Wrap()`.
+func requestState(aContext context.Context) *tRequestState {
+	if nil == aContext {
+		return nil
+	}
+	if rs, ok := aContext.Value(alStateKey).(*tRequestState); ok {
+		return rs
+	}
+
+	return nil
+} // requestState()
+
+// `withRequestState()` returns a shallow copy of `aRequest` whose
+// context carries a new logging state holding the request's
+// correlation fields (see `AddCorrelationHeader()`).
+//
+// Parameters:
+// - `aRequest`: The request to augment.
+//
+// Returns:
+// - `*http.Request`: The augmented request.
+// - `*tRequestState`: The request's logging state.
+func withRequestState(aRequest *http.Request) (*http.Request, *tRequestState) {
+	rs := &tRequestState{correlation: correlationFields(aRequest.Header)}
+	ctx := context.WithValue(aRequest.Context(), alStateKey, rs)
+
+	return aRequest.WithContext(ctx), rs
+} // withRequestState()
+
+// `copyFields()` returns a copy of the custom fields set for the
+// request.
+//
+// Returns:
+// - `map[string]string`: The request's custom fields (may be `nil`).
+func (rs *tRequestState) copyFields() map[string]string {
+	rs.mtx.Lock()
+	defer rs.mtx.Unlock()
+
+	return copyStrings(rs.fields)
+} // copyFields()
+
+// `copyStrings()` returns a copy of `aMap`.
+//
+// Parameters:
+// - `aMap`: The map to copy.
+//
+// Returns:
+// - `map[string]string`: The copy (`nil` if `aMap` is empty).
+func copyStrings(aMap map[string]string) map[string]string {
+	if 0 == len(aMap) {
+		return nil
+	}
+	result := make(map[string]string, len(aMap))
+	for key, value := range aMap {
+		result[key] = value
+	}
+
+	return result
+} // copyStrings()
+
+// `isSuppressed()` reports whether the request should not be logged.
+//
+// Returns:
+// - `bool`: `true` if `Suppress()` was called for the request.
+func (rs *tRequestState) isSuppressed() bool {
+	return 1 == atomic.LoadInt32(&rs.suppressed)
+} // isSuppressed()
+
+/* * * * * * * * * * * * * * * * * * * * * * * * * * * * * * * * * * * * * */
+
+// `SetField()` attaches the custom field `aKey` with `aValue` to the
+// request belonging to `aContext`.
+//
+// A handler wrapped by `Wrap()` can call this function (passing the
+// request's `Context()`) to add data to the request's log entry; the
+// fields are buffered until the request is finished. Setting the same
+// key again replaces the former value. Calling it with a context not
+// belonging to a wrapped request does nothing.
+//
+// Parameters:
+// - `aContext`: The context of the current request.
+// - `aKey`: The name of the field.
+// - `aValue`: The field's value.
+func SetField(aContext context.Context, aKey string, aValue interface{}) {
+	rs := requestState(aContext)
+	if (nil == rs) || ("" == aKey) {
+		return
+	}
+
+	rs.mtx.Lock()
+	if nil == rs.fields {
+		rs.fields = make(map[string]string)
+	}
+	rs.fields[aKey] = fmt.Sprint(aValue)
+	rs.mtx.Unlock()
+} // SetField()
+
+// `Suppress()` marks the request belonging to `aContext` as "do not log".
+//
+// A handler wrapped by `Wrap()` can call this function (passing the
+// request's `Context()`) e.g. for internal admin endpoints or synthetic
+// monitoring requests; no access log entry gets written for that
+// request then. Calling it with a context not belonging to a wrapped
+// request does nothing.
+//
+// Parameters:
+// - `aContext`: The context of the current request.
+func Suppress(aContext context.Context) {
+	if rs := requestState(aContext); nil != rs {
+		atomic.StoreInt32(&rs.suppressed, 1)
+	}
+} // Suppress()
+
+/* _EoF_ */
@@ -0,0 +1,37 @@
+/*
+Copyright © 2024  M.Watermann, 10247 Berlin, Germany
+
+	    All rights reserved
+	EMail : <support@mwat.de>
+*/
+package apachelogger
+
+import (
+	"context"
+	"net/http/httptest"
+	"testing"
+)
+
+//lint:file-ignore ST1017 – I prefer Yoda conditions
+
+func TestSuppress(t *testing.T) {
+	req1, rs1 := withRequestState(httptest.NewRequest("GET", "/", nil))
+	req2, rs2 := withRequestState(httptest.NewRequest("GET", "/", nil))
+
+	Suppress(req1.Context())
+	Suppress(context.Background()) // must not panic
+	//lint:ignore SA1012 testing a nil context
+	Suppress(nil) // must not panic
+
+	if !rs1.isSuppressed() {
+		t.Error("Suppress() didn't mark request 1")
+	}
+	if rs2.isSuppressed() {
+		t.Error("Suppress() marked request 2")
+	}
+	if rs := requestState(req2.Context()); rs != rs2 {
+		t.Errorf("requestState() = %p, want %p", rs, rs2)
+	}
+} // TestSuppress()
+
+/* _EoF_ */
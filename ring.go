@@ -0,0 +1,773 @@
+/*
+Copyright © 2024  M.Watermann, 10247 Berlin, Germany
+
+	    All rights reserved
+	EMail : <support@mwat.de>
+*/
+package apachelogger
+
+import (
+	"runtime"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+//lint:file-ignore ST1017 – I prefer Yoda conditions
+
+const (
+	// Max. number of messages dequeued at once.
+	alRingBatch = 64
+
+	// Default number of slots of a message queue.
+	alRingSize = 128
+
+	// Period of low usage after which a queue is shrunk.
+	alQueueShrinkWindow = time.Minute
+)
+
+type (
+	// `tRingSlot` is a single cell of a `tRingBuffer`.
+	tRingSlot struct {
+		seq  uint64 // the slot's sequence number (accessed atomically)
+		text string // the queued log message
+	}
+
+	// `tRingBuffer` is a bounded lock-free multi-producer single-consumer
+	// queue of log messages.
+	//
+	// Each slot carries a sequence number telling whether it's free to
+	// write (`seq == pos`) or ready to read (`seq == pos+1`) for the
+	// producers' and consumer's current positions.
+	tRingBuffer struct {
+		tail     uint64      // next position to write (accessed atomically)
+		_        [56]byte    // keep `tail` and `head` in different cache lines
+		head     uint64      // next position to read (accessed atomically)
+		inflight int32       // number of producers writing (accessed atomically)
+		retired  int32       // `1` after the buffer got replaced (accessed atomically)
+		mask     uint64      // `len(slots) - 1`
+		slots    []tRingSlot // the buffer's cells
+		_        [24]byte    // keep the buffers in different cache lines
+	}
+
+	// `tRingShard` is a queue of log messages whose buffer can be
+	// replaced by a smaller or larger one while it's in use.
+	tRingShard struct {
+		buffer  atomic.Value // the current `*tRingBuffer` (used by producers)
+		full    uint64       // times a producer found the buffer full (accessed atomically)
+		hwm     uint64       // max. number of queued messages seen (accessed atomically)
+		resizes uint64       // times the buffer was replaced (accessed atomically)
+		read    *tRingBuffer // the buffer to read from (consumer only)
+		peak    int          // max. number of queued messages in the current window
+		seen    uint64       // value of `full` at the last `adapt()`
+		since   time.Time    // start of the current window
+	}
+
+	// `tRing` is a queue of log messages consisting of one or more
+	// shards read by a single consumer.
+	//
+	// Messages with the same key always go to the same shard, so their
+	// order is preserved.
+	tRing struct {
+		closed   int32         // `1` after `close()` (accessed atomically)
+		waiting  int32         // `1` while the consumer waits (accessed atomically)
+		next     int           // shard to read first (consumer only)
+		shards   []*tRingShard // the queue's shards
+		notify   chan struct{} // wakes up the waiting consumer
+		seq      uint64        // last sequence number issued (accessed atomically)
+		pending  int32         // `1` if there's a new target (accessed atomically)
+		target   TSink         // the sink the consumer should switch to
+		mtx      sync.Mutex    // guard for `target` and `barriers`
+		dropped  uint64        // messages rejected when closed or full (accessed atomically)
+		state    tWriterState  // the state of the consumer
+		spool    tSpool        // the overflow on disk (see `SetSpoolDir()`)
+		queued   uint64        // messages accepted (accessed atomically)
+		written  uint64        // messages written to the sink (accessed atomically)
+		waiters  int32         // number of `barriers` (accessed atomically)
+		barriers []tBarrier    // the waiting barriers (guarded by `mtx`)
+		settings atomic.Value  // the log's `*tLogSettings` (see `WrapWith()`)
+	}
+)
+
+var (
+	// Number of shards of the access log queue (see `SetQueueShards()`).
+	alQueueShards = 1
+
+	// Flag telling whether callers wait for a free slot of a full queue
+	// (`1`) or the message is dropped (`0`, see `SetBlockWhenFull()`).
+	alBlockWhenFull int32
+
+	// The bounds of the queues' sizes (see `SetQueueBounds()`);
+	// `max == 0` means a fixed size of `alRingSize` slots.
+	alQueueBounds struct {
+		sync.RWMutex
+		min, max int
+	}
+)
+
+// `newRingBuffer()` returns a new queue buffer.
+//
+// Parameters:
+// - `aSize`: The number of slots (rounded up to a power of two).
+//
+// Returns:
+// - `*tRingBuffer`: The new buffer.
+func newRingBuffer(aSize int) *tRingBuffer {
+	size := 2
+	for size < aSize {
+		size <<= 1
+	}
+	result := &tRingBuffer{
+		mask:  uint64(size - 1),
+		slots: make([]tRingSlot, size),
+	}
+	for idx := range result.slots {
+		result.slots[idx].seq = uint64(idx)
+	}
+
+	return result
+} // newRingBuffer()
+
+// `isEmpty()` reports whether there's no message ready to read.
+//
+// Returns:
+// - `bool`: `true` if the consumer would get no message.
+func (rb *tRingBuffer) isEmpty() bool {
+	head := atomic.LoadUint64(&rb.head)
+
+	return atomic.LoadUint64(&rb.slots[head&rb.mask].seq) != head+1
+} // isEmpty()
+
+// `length()` returns the number of messages currently queued.
+//
+// Returns:
+// - `int`: The number of queued messages.
+func (rb *tRingBuffer) length() int {
+	head := atomic.LoadUint64(&rb.head)
+	tail := atomic.LoadUint64(&rb.tail)
+	if tail <= head {
+		return 0
+	}
+
+	return int(tail - head)
+} // length()
+
+// `popBatch()` appends the messages ready to read to `aBatch` until it
+// reaches its capacity.
+//
+// Parameters:
+// - `aBatch`: The slice to append the messages to.
+//
+// Returns:
+// - `[]string`: The extended slice.
+func (rb *tRingBuffer) popBatch(aBatch []string) []string {
+	head := atomic.LoadUint64(&rb.head)
+	for len(aBatch) < cap(aBatch) {
+		slot := &rb.slots[head&rb.mask]
+		if atomic.LoadUint64(&slot.seq) != head+1 {
+			break // empty or not yet published
+		}
+		aBatch = append(aBatch, slot.text)
+		slot.text = ""
+		atomic.StoreUint64(&slot.seq, head+rb.mask+1)
+		head++
+		atomic.StoreUint64(&rb.head, head)
+	}
+
+	return aBatch
+} // popBatch()
+
+// `tryPush()` appends `aText` to the buffer if there's room.
+//
+// Parameters:
+// - `aText`: The message to queue.
+//
+// Returns:
+// - `bool`: `false` if the buffer is full.
+func (rb *tRingBuffer) tryPush(aText string) bool {
+	for {
+		pos := atomic.LoadUint64(&rb.tail)
+		slot := &rb.slots[pos&rb.mask]
+		seq := atomic.LoadUint64(&slot.seq)
+
+		if seq == pos {
+			if atomic.CompareAndSwapUint64(&rb.tail, pos, pos+1) {
+				slot.text = aText
+				atomic.StoreUint64(&slot.seq, pos+1)
+
+				return true
+			}
+			continue // another producer took the slot
+		}
+		if seq < pos {
+			return false
+		}
+		// `tail` moved on meanwhile
+	}
+} // tryPush()
+
+/* * * * * * * * * * * * * * * * * * * * * * * * * * * * * * * * * * * * * */
+
+// `newRingShard()` returns a new queue shard.
+//
+// Parameters:
+// - `aSize`: The number of slots (rounded up to a power of two).
+//
+// Returns:
+// - `*tRingShard`: The new shard.
+func newRingShard(aSize int) *tRingShard {
+	result := &tRingShard{
+		read:  newRingBuffer(aSize),
+		since: time.Now(),
+	}
+	result.buffer.Store(result.read)
+
+	return result
+} // newRingShard()
+
+// `adapt()` replaces the shard's buffer by a larger one if producers
+// found it full or it was at least three quarters full,
+// or by a smaller one if it was less than a quarter full during the
+// last `aWindow`.
+//
+// This method must be called by the consumer only.
+//
+// Parameters:
+// - `aMin`: The min. number of slots.
+// - `aMax`: The max. number of slots.
+// - `aWindow`: The period to watch before shrinking the buffer.
+func (rs *tRingShard) adapt(aMin, aMax int, aWindow time.Duration) {
+	current := rs.current()
+	if rs.read != current {
+		return // the previous replacement isn't finished yet
+	}
+	size, full := len(current.slots), atomic.LoadUint64(&rs.full)
+
+	newSize := size
+	switch {
+	case size < aMin:
+		newSize = aMin
+	case size > aMax:
+		newSize = aMax
+	case (full != rs.seen) || (size*3 <= rs.peak*4):
+		if newSize = size << 1; newSize > aMax {
+			newSize = aMax
+		}
+	case aWindow <= time.Since(rs.since):
+		if rs.peak*4 < size {
+			if newSize = size >> 1; newSize < aMin {
+				newSize = aMin
+			}
+		}
+		rs.peak, rs.since = 0, time.Now()
+	}
+	rs.seen = full
+	if newSize = len(newRingBuffer(newSize).slots); newSize == size {
+		return
+	}
+
+	rs.buffer.Store(newRingBuffer(newSize))
+	atomic.StoreInt32(&current.retired, 1)
+	atomic.AddUint64(&rs.resizes, 1)
+} // adapt()
+
+// `current()` returns the buffer producers currently write to.
+//
+// Returns:
+// - `*tRingBuffer`: The shard's current buffer.
+func (rs *tRingShard) current() *tRingBuffer {
+	return rs.buffer.Load().(*tRingBuffer)
+} // current()
+
+// `isEmpty()` reports whether there's no message ready to read.
+//
+// This method must be called by the consumer only.
+//
+// Returns:
+// - `bool`: `true` if the consumer would get no message.
+func (rs *tRingShard) isEmpty() bool {
+	current := rs.current()
+
+	return rs.read.isEmpty() && ((rs.read == current) || current.isEmpty())
+} // isEmpty()
+
+// `length()` returns the number of messages currently queued.
+//
+// Returns:
+// - `int`: The number of queued messages.
+func (rs *tRingShard) length() int {
+	return rs.current().length()
+} // length()
+
+// `popBatch()` appends the messages ready to read to `aBatch` until it
+// reaches its capacity.
+//
+// The messages of a replaced buffer are read completely before those
+// of the current one, so the order is preserved.
+//
+// Parameters:
+// - `aBatch`: The slice to append the messages to.
+//
+// Returns:
+// - `[]string`: The extended slice.
+func (rs *tRingShard) popBatch(aBatch []string) []string {
+	if queued := rs.read.length(); queued > rs.peak {
+		rs.peak = queued
+		if uint64(queued) > atomic.LoadUint64(&rs.hwm) {
+			atomic.StoreUint64(&rs.hwm, uint64(queued))
+		}
+	}
+	aBatch = rs.read.popBatch(aBatch)
+
+	if current := rs.current(); rs.read != current {
+		// Switch to the new buffer once no producer writes to the old one:
+		if (0 == atomic.LoadInt32(&rs.read.inflight)) && rs.read.isEmpty() {
+			rs.read = current
+			aBatch = rs.read.popBatch(aBatch)
+		}
+	}
+
+	return aBatch
+} // popBatch()
+
+// `tryPush()` appends `aText` to the shard's current buffer if there's
+// room.
+//
+// Parameters:
+// - `aText`: The message to queue.
+//
+// Returns:
+// - `bool`: `false` if the buffer is full.
+func (rs *tRingShard) tryPush(aText string) bool {
+	for {
+		buffer := rs.current()
+		atomic.AddInt32(&buffer.inflight, 1)
+		if 1 == atomic.LoadInt32(&buffer.retired) {
+			atomic.AddInt32(&buffer.inflight, -1)
+			continue // the buffer got replaced meanwhile
+		}
+		ok := buffer.tryPush(aText)
+		atomic.AddInt32(&buffer.inflight, -1)
+
+		return ok
+	}
+} // tryPush()
+
+/* * * * * * * * * * * * * * * * * * * * * * * * * * * * * * * * * * * * * */
+
+// `newRing()` returns a new message queue with a single shard.
+//
+// Parameters:
+// - `aSize`: The number of slots (rounded up to a power of two).
+//
+// Returns:
+// - `*tRing`: The new queue.
+func newRing(aSize int) *tRing {
+	return newShardedRing(aSize, 1)
+} // newRing()
+
+// `newShardedRing()` returns a new message queue.
+//
+// Parameters:
+// - `aSize`: The number of slots per shard (rounded up to a power of two).
+// - `aShards`: The number of shards.
+//
+// Returns:
+// - `*tRing`: The new queue.
+func newShardedRing(aSize, aShards int) *tRing {
+	if 1 > aShards {
+		aShards = 1
+	}
+	result := &tRing{
+		shards: make([]*tRingShard, aShards),
+		notify: make(chan struct{}, 1),
+	}
+	for idx := range result.shards {
+		result.shards[idx] = newRingShard(aSize)
+	}
+
+	return result
+} // newShardedRing()
+
+// `accepted()` counts a message added to the queue and wakes up the
+// consumer.
+func (r *tRing) accepted() {
+	atomic.AddUint64(&r.queued, 1)
+	r.wake()
+} // accepted()
+
+// `adapt()` adjusts the size of the queue's shards to their recent
+// usage (see `SetQueueBounds()`).
+//
+// This method must be called by the consumer only.
+func (r *tRing) adapt() {
+	alQueueBounds.RLock()
+	minSize, maxSize := alQueueBounds.min, alQueueBounds.max
+	alQueueBounds.RUnlock()
+	if 0 == maxSize { // fixed size
+		minSize, maxSize = alRingSize, alRingSize
+	}
+
+	for _, shard := range r.shards {
+		shard.adapt(minSize, maxSize, alQueueShrinkWindow)
+	}
+} // adapt()
+
+// `close()` marks the queue as closed.
+//
+// Further messages are rejected while the consumer still gets all
+// messages queued before.
+func (r *tRing) close() {
+	atomic.StoreInt32(&r.closed, 1)
+	r.wake()
+} // close()
+
+// `isClosed()` reports whether the queue got closed.
+//
+// Returns:
+// - `bool`: `true` after `close()` was called.
+func (r *tRing) isClosed() bool {
+	return 1 == atomic.LoadInt32(&r.closed)
+} // isClosed()
+
+// `isEmpty()` reports whether there's no message ready to read.
+//
+// Returns:
+// - `bool`: `true` if the consumer would get no message.
+func (r *tRing) isEmpty() bool {
+	if !r.spool.isEmpty() {
+		return false
+	}
+	for _, shard := range r.shards {
+		if !shard.isEmpty() {
+			return false
+		}
+	}
+
+	return true
+} // isEmpty()
+
+// `length()` returns the number of messages currently queued.
+//
+// Returns:
+// - `int`: The number of queued messages.
+func (r *tRing) length() (rLen int) {
+	for _, shard := range r.shards {
+		rLen += shard.length()
+	}
+	rLen += r.spool.length()
+
+	return
+} // length()
+
+// `nextSeq()` returns the next sequence number of the queue.
+//
+// Returns:
+// - `uint64`: The sequence number (starting at `1`).
+func (r *tRing) nextSeq() uint64 {
+	return atomic.AddUint64(&r.seq, 1)
+} // nextSeq()
+
+// `park()` prepares the consumer to wait for `notify`.
+//
+// Returns:
+// - `bool`: `false` if messages arrived (or the queue got closed or
+// a new target was set) meanwhile, so the consumer mustn't wait.
+func (r *tRing) park() bool {
+	atomic.StoreInt32(&r.waiting, 1)
+	if r.isEmpty() && !r.isClosed() && (0 == atomic.LoadInt32(&r.pending)) &&
+		(0 == atomic.LoadInt32(&r.waiters)) {
+		return true
+	}
+	atomic.StoreInt32(&r.waiting, 0)
+
+	return false
+} // park()
+
+// `popBatch()` appends the messages ready to read to `aBatch` until it
+// reaches its capacity, merging the messages of all shards.
+//
+// The spooled messages (see `SetSpoolDir()`) are read once the shards
+// are drained.
+//
+// This method must be called by a single consumer only.
+//
+// Parameters:
+// - `aBatch`: The slice to append the messages to.
+//
+// Returns:
+// - `[]string`: The extended slice.
+func (r *tRing) popBatch(aBatch []string) []string {
+	if 1 == len(r.shards) {
+		aBatch = r.shards[0].popBatch(aBatch)
+	} else {
+		// Take turns so that no shard can starve the others:
+		for idx := 0; (idx < len(r.shards)) && (len(aBatch) < cap(aBatch)); idx++ {
+			aBatch = r.shards[(r.next+idx)%len(r.shards)].popBatch(aBatch)
+		}
+		r.next = (r.next + 1) % len(r.shards)
+	}
+	if (0 == len(aBatch)) && !r.spool.isEmpty() {
+		aBatch = r.spool.popBatch(aBatch)
+	}
+
+	return aBatch
+} // popBatch()
+
+// `push()` appends `aText` to the queue's first shard.
+//
+// If the shard is full the message is dropped unless
+// `SetBlockWhenFull()` was enabled (see `pushTo()`).
+//
+// Parameters:
+// - `aText`: The message to queue.
+//
+// Returns:
+// - `bool`: `false` if the queue is closed or the message was dropped.
+func (r *tRing) push(aText string) bool {
+	return r.pushTo(r.shards[0], aText, 0 != atomic.LoadInt32(&alBlockWhenFull))
+} // push()
+
+// `pushWait()` appends `aText` to the queue's first shard, waiting
+// for a free slot if the shard is full (regardless of
+// `SetBlockWhenFull()`).
+//
+// Parameters:
+// - `aText`: The message to queue.
+//
+// Returns:
+// - `bool`: `false` if the queue is closed.
+func (r *tRing) pushWait(aText string) bool {
+	return r.pushTo(r.shards[0], aText, true)
+} // pushWait()
+
+// `pushKey()` appends `aText` to the shard selected by `aKey`, so
+// messages with the same key (e.g. of the same connection) keep
+// their order.
+//
+// Parameters:
+// - `aKey`: The key selecting the shard.
+// - `aText`: The message to queue.
+//
+// Returns:
+// - `bool`: `false` if the queue is closed or the message was dropped.
+func (r *tRing) pushKey(aKey, aText string) bool {
+	wait := 0 != atomic.LoadInt32(&alBlockWhenFull)
+	if 1 == len(r.shards) {
+		return r.pushTo(r.shards[0], aText, wait)
+	}
+
+	// FNV-1a hash of the key:
+	hash := uint32(2166136261)
+	for idx := 0; idx < len(aKey); idx++ {
+		hash ^= uint32(aKey[idx])
+		hash *= 16777619
+	}
+
+	return r.pushTo(r.shards[hash%uint32(len(r.shards))], aText, wait)
+} // pushKey()
+
+// `pushTo()` appends `aText` to `aShard`. If the shard is full and the
+// message can't be spooled (see `SetSpoolDir()`) the message is dropped
+// unless `aWait` is set; then the caller waits (with increasing
+// back-off) for a free slot.
+//
+// Parameters:
+// - `aShard`: The shard to append to.
+// - `aText`: The message to queue.
+// - `aWait`: Whether to wait for a free slot of a full shard.
+//
+// Returns:
+// - `bool`: `false` if the queue is closed or the message was dropped.
+func (r *tRing) pushTo(aShard *tRingShard, aText string, aWait bool) bool {
+	var pause time.Duration
+
+	for spins := 1; ; spins++ {
+		if r.isClosed() {
+			atomic.AddUint64(&r.dropped, 1)
+			return false
+		}
+		if !r.spool.isEmpty() {
+			if r.spill(aText) {
+				r.accepted() // keep the order behind the spooled messages
+				return true
+			}
+			if !aWait { // don't overtake the spooled messages
+				atomic.AddUint64(&r.dropped, 1)
+				return false
+			}
+		} else if aShard.tryPush(aText) {
+			r.accepted()
+			return true
+		} else if 1 == spins { // the shard is full
+			atomic.AddUint64(&aShard.full, 1)
+			if r.spill(aText) {
+				r.accepted()
+				return true
+			}
+			if !aWait {
+				atomic.AddUint64(&r.dropped, 1)
+				return false
+			}
+		}
+		if 32 > spins {
+			runtime.Gosched()
+			continue
+		}
+		if pause < 10*time.Millisecond {
+			pause += 100 * time.Microsecond
+		}
+		time.Sleep(pause)
+	}
+} // pushTo()
+
+// `retarget()` asks the queue's consumer to write all messages not yet
+// written to `aSink` instead of its current sink.
+//
+// Parameters:
+// - `aSink`: The new sink (`nil` to discard the messages).
+func (r *tRing) retarget(aSink TSink) {
+	r.mtx.Lock()
+	r.target = aSink
+	atomic.StoreInt32(&r.pending, 1)
+	r.mtx.Unlock()
+	r.wake()
+} // retarget()
+
+// `takeTarget()` returns the sink set by `retarget()` (if any).
+//
+// This method must be called by the consumer only.
+//
+// Returns:
+// - `TSink`: The new sink to use.
+// - `bool`: `false` if there's no new sink.
+func (r *tRing) takeTarget() (TSink, bool) {
+	if 0 == atomic.LoadInt32(&r.pending) {
+		return nil, false
+	}
+	r.mtx.Lock()
+	defer r.mtx.Unlock()
+
+	result := r.target
+	r.target = nil
+	atomic.StoreInt32(&r.pending, 0)
+
+	return result, true
+} // takeTarget()
+
+// `wake()` wakes up the consumer if it's waiting.
+func (r *tRing) wake() {
+	if atomic.CompareAndSwapInt32(&r.waiting, 1, 0) {
+		select {
+		case r.notify <- struct{}{}:
+		default:
+		}
+	}
+} // wake()
+
+/* * * * * * * * * * * * * * * * * * * * * * * * * * * * * * * * * * * * * */
+
+// `SetQueueShards()` splits the access log queue into `aShards`
+// independent queues which are merged by the background writer.
+//
+// This reduces the contention between concurrent requests on machines
+// with many cores. Entries of the same connection always use the same
+// shard, so their order is preserved. A value of zero (or less) uses
+// one shard per CPU (`runtime.GOMAXPROCS(0)`).
+//
+// This function must be called before `Wrap()` or `WrapSinks()`;
+// later calls have no effect.
+//
+// Parameters:
+// - `aShards`: The number of shards to use.
+func SetQueueShards(aShards int) {
+	if 1 > aShards {
+		aShards = runtime.GOMAXPROCS(0)
+	}
+	alQueueShards = aShards
+} // SetQueueShards()
+
+// `SetBlockWhenFull()` decides what happens to a message if its queue
+// is full and the message can't be spooled (see `SetSpoolDir()`).
+//
+// By default the message is dropped (and counted as `dropped` by
+// `Health()`), so logging never delays the requests served. With
+// `aBlock` set the callers – including the request handlers – wait
+// for a free slot instead, so no message is lost but a slow sink may
+// slow down the server.
+//
+// Parameters:
+// - `aBlock`: Whether to wait for a free slot.
+func SetBlockWhenFull(aBlock bool) {
+	var block int32
+	if aBlock {
+		block = 1
+	}
+	atomic.StoreInt32(&alBlockWhenFull, block)
+} // SetBlockWhenFull()
+
+// `SetQueueBounds()` lets the queues' sizes adapt to the load between
+// `aMin` and `aMax` slots (per shard, rounded to powers of two).
+//
+// A queue is doubled whenever it was at least three quarters full or
+// a caller found it full, and halved after it was less
+// than a quarter full for a minute. Calling this function with zero
+// arguments restores the fixed size of 128 slots.
+//
+// Parameters:
+// - `aMin`: The min. number of slots per queue (shard).
+// - `aMax`: The max. number of slots per queue (shard).
+func SetQueueBounds(aMin, aMax int) {
+	if 0 >= aMax {
+		aMin, aMax = 0, 0
+	} else {
+		if 2 > aMin {
+			aMin = 2
+		}
+		if aMax < aMin {
+			aMax = aMin
+		}
+	}
+
+	alQueueBounds.Lock()
+	alQueueBounds.min, alQueueBounds.max = aMin, aMax
+	alQueueBounds.Unlock()
+} // SetQueueBounds()
+
+// `shardQueue()` returns a queue with `aShards` shards containing the
+// messages of `aQueue` (which gets closed).
+//
+// Parameters:
+// - `aQueue`: The queue to replace.
+// - `aShards`: The number of shards to use.
+//
+// Returns:
+// - `*tRing`: The new queue.
+func shardQueue(aQueue *tRing, aShards int) *tRing {
+	if len(aQueue.shards) == aShards {
+		return aQueue
+	}
+	result := newShardedRing(alRingSize, aShards)
+	mergeQueue(aQueue, result)
+
+	return result
+} // shardQueue()
+
+// `mergeQueue()` closes `aFrom` and moves its messages to `aTo`.
+//
+// Parameters:
+// - `aFrom`: The queue to close.
+// - `aTo`: The queue to receive the messages.
+func mergeQueue(aFrom, aTo *tRing) {
+	aFrom.close()
+
+	batch := make([]string, 0, alRingBatch)
+	for batch = aFrom.popBatch(batch[:0]); 0 < len(batch); batch = aFrom.popBatch(batch[:0]) {
+		for _, txt := range batch {
+			aTo.push(txt)
+		}
+		atomic.AddUint64(&aFrom.written, uint64(len(batch)))
+	}
+} // mergeQueue()
+
+/* _EoF_ */
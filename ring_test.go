@@ -0,0 +1,456 @@
+/*
+Copyright © 2024  M.Watermann, 10247 Berlin, Germany
+
+	    All rights reserved
+	EMail : <support@mwat.de>
+*/
+package apachelogger
+
+import (
+	"fmt"
+	"runtime"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+//lint:file-ignore ST1017 – I prefer Yoda conditions
+
+// `popWithin()` returns the next message of `aRing` waiting at most
+// `aTimeout` for it.
+func popWithin(aRing *tRing, aTimeout time.Duration) (string, bool) {
+	deadline := time.Now().Add(aTimeout)
+	batch := make([]string, 0, 1)
+	for {
+		if batch = aRing.popBatch(batch[:0]); 0 < len(batch) {
+			return batch[0], true
+		}
+		if time.Now().After(deadline) {
+			return "", false
+		}
+		time.Sleep(time.Millisecond)
+	}
+} // popWithin()
+
+func Test_newRing(t *testing.T) {
+	tests := []struct {
+		size int
+		want int
+	}{
+		{0, 2}, {1, 2}, {2, 2}, {3, 4}, {127, 128}, {128, 128}, {129, 256},
+	}
+	for _, tt := range tests {
+		if got := len(newRing(tt.size).shards[0].current().slots); got != tt.want {
+			t.Errorf("newRing(%d) slots = %d, want %d", tt.size, got, tt.want)
+		}
+	}
+} // Test_newRing()
+
+func Test_tRing(t *testing.T) {
+	r := newRing(4)
+	if !r.isEmpty() || (0 != r.length()) {
+		t.Fatal("new ring isn't empty")
+	}
+
+	batch := make([]string, 0, 3)
+	for round := 0; round < 3; round++ { // wrap around several times
+		for i := 0; i < 4; i++ {
+			if !r.push(fmt.Sprintf("%d-%d", round, i)) {
+				t.Fatalf("push() = false")
+			}
+		}
+		if got := r.length(); 4 != got {
+			t.Errorf("length() = %d, want 4", got)
+		}
+		batch = r.popBatch(batch[:0])
+		if want := fmt.Sprint([]string{
+			fmt.Sprintf("%d-0", round), fmt.Sprintf("%d-1", round), fmt.Sprintf("%d-2", round),
+		}); fmt.Sprint(batch) != want {
+			t.Errorf("popBatch() = %v, want %v", batch, want)
+		}
+		batch = r.popBatch(batch[:0])
+		if want := fmt.Sprintf("[%d-3]", round); fmt.Sprint(batch) != want {
+			t.Errorf("popBatch() = %v, want %v", batch, want)
+		}
+	}
+	if !r.isEmpty() {
+		t.Error("isEmpty() = false, want true")
+	}
+	if !r.park() {
+		t.Error("park() = false for an empty ring")
+	}
+	r.push("wake")
+	select {
+	case <-r.notify:
+	case <-time.After(time.Second):
+		t.Error("push() didn't wake the consumer")
+	}
+	if r.park() {
+		t.Error("park() = true for a non-empty ring")
+	}
+
+	r.close()
+	if r.push("late") {
+		t.Error("push() = true for a closed ring")
+	}
+	if batch = r.popBatch(batch[:0]); "[wake]" != fmt.Sprint(batch) {
+		t.Errorf("popBatch() = %v, want [wake]", batch)
+	}
+} // Test_tRing()
+
+func Test_tRing_full(t *testing.T) {
+	r := newRing(2)
+	for i := 0; i < 2; i++ {
+		if !r.push(fmt.Sprint(i)) {
+			t.Fatalf("push(%d) = false", i)
+		}
+	}
+
+	done := make(chan bool)
+	go func() { done <- r.push("full") }()
+	select {
+	case ok := <-done:
+		if ok {
+			t.Error("push() = true for a full ring")
+		}
+	case <-time.After(time.Second):
+		t.Fatal("push() blocked on a full ring")
+	}
+	if got := atomic.LoadUint64(&r.dropped); 1 != got {
+		t.Errorf("dropped = %d, want 1", got)
+	}
+
+	defer SetBlockWhenFull(false)
+	SetBlockWhenFull(true)
+	go func() { done <- r.push("waiting") }()
+	select {
+	case <-done:
+		t.Fatal("push() didn't wait for a free slot")
+	case <-time.After(50 * time.Millisecond):
+	}
+	batch := r.popBatch(make([]string, 0, alRingBatch))
+	if ok := <-done; !ok {
+		t.Error("push() = false after a slot was freed")
+	}
+	if batch = r.popBatch(batch[:0]); "[waiting]" != fmt.Sprint(batch) {
+		t.Errorf("popBatch() = %v, want [waiting]", batch)
+	}
+} // Test_tRing_full()
+
+func Test_tRing_concurrent(t *testing.T) {
+	defer SetBlockWhenFull(false)
+	SetBlockWhenFull(true)
+
+	const producers, messages = 8, 1000
+	r := newRing(16)
+
+	var wg sync.WaitGroup
+	for p := 0; p < producers; p++ {
+		wg.Add(1)
+		go func(aProducer int) {
+			defer wg.Done()
+			for i := 0; i < messages; i++ {
+				r.push(fmt.Sprintf("%d %d", aProducer, i))
+			}
+		}(p)
+	}
+	go func() {
+		wg.Wait()
+		r.close()
+	}()
+
+	next := make([]int, producers)
+	batch := make([]string, 0, alRingBatch)
+	for count := 0; ; {
+		if batch = r.popBatch(batch[:0]); 0 == len(batch) {
+			if r.isClosed() && r.isEmpty() {
+				if want := producers * messages; count != want {
+					t.Errorf("got %d messages, want %d", count, want)
+				}
+				break
+			}
+			if r.park() {
+				<-r.notify
+			}
+			continue
+		}
+		for _, txt := range batch {
+			var p, i int
+			if _, err := fmt.Sscanf(txt, "%d %d", &p, &i); nil != err {
+				t.Fatalf("unexpected message %q", txt)
+			}
+			if next[p] != i { // per-producer order must be kept
+				t.Fatalf("producer %d: got message %d, want %d", p, i, next[p])
+			}
+			next[p]++
+			count++
+		}
+	}
+} // Test_tRing_concurrent()
+
+func Test_tRing_sharded(t *testing.T) {
+	defer SetBlockWhenFull(false)
+	SetBlockWhenFull(true)
+
+	const producers, messages = 8, 500
+	r := newShardedRing(8, 4)
+
+	var wg sync.WaitGroup
+	for p := 0; p < producers; p++ {
+		wg.Add(1)
+		go func(aProducer int) {
+			defer wg.Done()
+			key := fmt.Sprintf("192.0.2.%d:%d", aProducer, 40000+aProducer)
+			for i := 0; i < messages; i++ {
+				r.pushKey(key, fmt.Sprintf("%d %d", aProducer, i))
+			}
+		}(p)
+	}
+	go func() {
+		wg.Wait()
+		r.close()
+	}()
+
+	next := make([]int, producers)
+	batch := make([]string, 0, alRingBatch)
+	count := 0
+	for {
+		if batch = r.popBatch(batch[:0]); 0 == len(batch) {
+			if r.isClosed() && r.isEmpty() {
+				break
+			}
+			if r.park() {
+				<-r.notify
+			}
+			continue
+		}
+		for _, txt := range batch {
+			var p, i int
+			if _, err := fmt.Sscanf(txt, "%d %d", &p, &i); nil != err {
+				t.Fatalf("unexpected message %q", txt)
+			}
+			if next[p] != i { // per-key order must be kept
+				t.Fatalf("key %d: got message %d, want %d", p, i, next[p])
+			}
+			next[p]++
+			count++
+		}
+	}
+	if want := producers * messages; count != want {
+		t.Errorf("got %d messages, want %d", count, want)
+	}
+} // Test_tRing_sharded()
+
+func Test_tRingShard_adapt(t *testing.T) {
+	rs := newRingShard(4)
+	batch := make([]string, 0, 8)
+	for i := 0; i < 3; i++ {
+		rs.tryPush("msg")
+	}
+	_ = rs.popBatch(batch[:0])
+	if got := atomic.LoadUint64(&rs.hwm); 3 != got {
+		t.Errorf("hwm = %d, want 3", got)
+	}
+
+	rs.adapt(2, 16, time.Minute) // 3 of 4 slots used => grow
+	if got := len(rs.current().slots); 8 != got {
+		t.Fatalf("adapt() size = %d, want 8", got)
+	}
+	rs.tryPush("new")
+	if got := fmt.Sprint(rs.popBatch(batch[:0])); "[new]" != got {
+		t.Errorf("popBatch() = %s, want [new]", got)
+	}
+
+	atomic.AddUint64(&rs.full, 1) // a producer had to wait => grow
+	rs.adapt(2, 16, time.Minute)
+	if got := len(rs.current().slots); 16 != got {
+		t.Fatalf("adapt() size = %d, want 16", got)
+	}
+	rs.adapt(32, 64, time.Minute) // the old buffer isn't read yet
+	if got := len(rs.current().slots); 16 != got {
+		t.Fatalf("adapt() size = %d, want 16", got)
+	}
+	_ = rs.popBatch(batch[:0])
+	rs.adapt(2, 16, time.Minute) // no change
+	if got := len(rs.current().slots); 16 != got {
+		t.Fatalf("adapt() size = %d, want 16", got)
+	}
+
+	rs.peak = 1 // low usage for a whole window => shrink
+	rs.adapt(2, 16, 0)
+	if got := len(rs.current().slots); 8 != got {
+		t.Errorf("adapt() size = %d, want 8", got)
+	}
+	_ = rs.popBatch(batch[:0])
+	rs.adapt(32, 64, time.Minute) // below the min. size
+	if got := len(rs.current().slots); 32 != got {
+		t.Errorf("adapt() size = %d, want 32", got)
+	}
+	if got := atomic.LoadUint64(&rs.resizes); 4 != got {
+		t.Errorf("resizes = %d, want 4", got)
+	}
+} // Test_tRingShard_adapt()
+
+func Test_tRing_adaptConcurrent(t *testing.T) {
+	defer SetQueueBounds(0, 0)
+	defer SetBlockWhenFull(false)
+	SetQueueBounds(2, 64)
+	SetBlockWhenFull(true)
+
+	const producers, messages = 8, 1000
+	r := newShardedRing(2, 2)
+
+	var wg sync.WaitGroup
+	for p := 0; p < producers; p++ {
+		wg.Add(1)
+		go func(aProducer int) {
+			defer wg.Done()
+			key := fmt.Sprintf("192.0.2.%d:4711", aProducer)
+			for i := 0; i < messages; i++ {
+				r.pushKey(key, fmt.Sprintf("%d %d", aProducer, i))
+			}
+		}(p)
+	}
+	go func() {
+		wg.Wait()
+		r.close()
+	}()
+
+	next := make([]int, producers)
+	batch := make([]string, 0, 4)
+	count := 0
+	for {
+		if batch = r.popBatch(batch[:0]); 0 == len(batch) {
+			if r.isClosed() && r.isEmpty() {
+				break
+			}
+			if r.park() {
+				<-r.notify
+			}
+			continue
+		}
+		for _, txt := range batch {
+			var p, i int
+			if _, err := fmt.Sscanf(txt, "%d %d", &p, &i); nil != err {
+				t.Fatalf("unexpected message %q", txt)
+			}
+			if next[p] != i { // per-key order must be kept
+				t.Fatalf("key %d: got message %d, want %d", p, i, next[p])
+			}
+			next[p]++
+			count++
+		}
+		r.adapt()
+	}
+	if want := producers * messages; count != want {
+		t.Errorf("got %d messages, want %d", count, want)
+	}
+	if got := r.stats(); 0 == got.Resizes {
+		t.Errorf("stats() = %v, want resizes", got)
+	}
+} // Test_tRing_adaptConcurrent()
+
+func Test_shardQueue(t *testing.T) {
+	q1 := newRing(4)
+	q1.push("one")
+	q1.push("two")
+
+	if got := shardQueue(q1, 1); got != q1 {
+		t.Error("shardQueue() replaced a queue with the same shards")
+	}
+	q2 := shardQueue(q1, 4)
+	if got := len(q2.shards); 4 != got {
+		t.Errorf("shardQueue() shards = %d, want 4", got)
+	}
+	if !q1.isClosed() {
+		t.Error("shardQueue() didn't close the old queue")
+	}
+	if got := fmt.Sprint(q2.popBatch(make([]string, 0, 8))); "[one two]" != got {
+		t.Errorf("shardQueue() messages = %s, want [one two]", got)
+	}
+} // Test_shardQueue()
+
+func Benchmark_tRing(b *testing.B) {
+	r := newRing(alRingSize)
+	done := make(chan struct{})
+	go func() {
+		batch := make([]string, 0, alRingBatch)
+		for {
+			if batch = r.popBatch(batch[:0]); 0 < len(batch) {
+				continue
+			}
+			if r.isClosed() {
+				close(done)
+				return
+			}
+			if r.park() {
+				<-r.notify
+			}
+		}
+	}()
+	b.ReportAllocs()
+	b.ResetTimer()
+
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			r.push("message\n")
+		}
+	})
+	r.close()
+	<-done
+} // Benchmark_tRing()
+
+func Benchmark_tRing_sharded(b *testing.B) {
+	r := newShardedRing(alRingSize, runtime.GOMAXPROCS(0))
+	done := make(chan struct{})
+	go func() {
+		batch := make([]string, 0, alRingBatch)
+		for {
+			if batch = r.popBatch(batch[:0]); 0 < len(batch) {
+				continue
+			}
+			if r.isClosed() {
+				close(done)
+				return
+			}
+			if r.park() {
+				<-r.notify
+			}
+		}
+	}()
+	var id uint32
+	b.ReportAllocs()
+	b.ResetTimer()
+
+	b.RunParallel(func(pb *testing.PB) {
+		key := fmt.Sprintf("192.0.2.1:%d", atomic.AddUint32(&id, 1))
+		for pb.Next() {
+			r.pushKey(key, "message\n")
+		}
+	})
+	r.close()
+	<-done
+} // Benchmark_tRing_sharded()
+
+func Benchmark_channel(b *testing.B) {
+	ch := make(chan string, alRingSize)
+	done := make(chan struct{})
+	go func() {
+		for range ch {
+		}
+		close(done)
+	}()
+	b.ReportAllocs()
+	b.ResetTimer()
+
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			ch <- "message\n"
+		}
+	})
+	close(ch)
+	<-done
+} // Benchmark_channel()
+
+/* _EoF_ */
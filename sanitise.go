@@ -0,0 +1,124 @@
+/*
+Copyright © 2024  M.Watermann, 10247 Berlin, Germany
+
+	    All rights reserved
+	EMail : <support@mwat.de>
+*/
+package apachelogger
+
+import (
+	"fmt"
+	"strings"
+	"unicode/utf8"
+)
+
+//lint:file-ignore ST1017 – I prefer Yoda conditions
+
+const (
+	// `UTF8Keep` writes invalid UTF-8 and control characters unchanged.
+	UTF8Keep = iota
+
+	// `UTF8Escape` percent-encodes invalid UTF-8 bytes and control
+	// characters (e.g. `%FF`, `%0A`).
+	UTF8Escape
+
+	// `UTF8Replace` replaces invalid UTF-8 bytes and control characters
+	// by the Unicode replacement character (`U+FFFD`).
+	UTF8Replace
+)
+
+var (
+	// `SanitiseUTF8` decides how to handle invalid UTF-8 and control
+	// characters (e.g. binary junk sent by scanners) in the logged
+	// strings, so the logfiles remain valid UTF-8 and can't be broken
+	// up by injected line breaks (default: `UTF8Escape`).
+	SanitiseUTF8 = UTF8Escape
+)
+
+// `needsSanitising()` reports whether `aText` contains invalid UTF-8
+// or control characters.
+//
+// Parameters:
+// - `aText`: The text to check.
+//
+// Returns:
+// - `bool`: `true` if `aText` must be sanitised.
+func needsSanitising(aText string) bool {
+	for idx := 0; idx < len(aText); idx++ {
+		if c := aText[idx]; (' ' > c) || (0x7f <= c) {
+			// slow path for non-ASCII and control characters:
+			for _, r := range aText[idx:] {
+				if (' ' > r) || (0x7f == r) || ((0x80 <= r) && (0x9f >= r)) ||
+					(utf8.RuneError == r) {
+					return true
+				}
+			}
+			return false
+		}
+	}
+
+	return false
+} // needsSanitising()
+
+// `sanitiseEntry()` sanitises all strings of `aEntry` according to
+// `SanitiseUTF8`.
+//
+// Parameters:
+// - `aEntry`: The log entry to sanitise.
+func sanitiseEntry(aEntry *TEntry) {
+	if UTF8Keep == SanitiseUTF8 {
+		return
+	}
+	for _, name := range alRedactFields {
+		if field := aEntry.stringField(name); nil != field {
+			*field = sanitiseString(*field)
+		}
+	}
+	for key, value := range aEntry.Fields {
+		if needsSanitising(value) {
+			aEntry.Fields[key] = sanitiseString(value)
+		}
+	}
+	for name, value := range aEntry.headers {
+		if needsSanitising(value) {
+			aEntry.headers[name] = sanitiseString(value)
+		}
+	}
+} // sanitiseEntry()
+
+// `sanitiseString()` returns `aText` with invalid UTF-8 and control
+// characters escaped or replaced according to `SanitiseUTF8`.
+//
+// Parameters:
+// - `aText`: The text to sanitise.
+//
+// Returns:
+// - `string`: The sanitised text.
+func sanitiseString(aText string) string {
+	if (UTF8Keep == SanitiseUTF8) || !needsSanitising(aText) {
+		return aText
+	}
+
+	var sb strings.Builder
+	sb.Grow(len(aText) + 8)
+	for idx := 0; idx < len(aText); {
+		r, size := utf8.DecodeRuneInString(aText[idx:])
+		invalid := (utf8.RuneError == r) && (1 == size)
+		if invalid || (' ' > r) || (0x7f == r) || ((0x80 <= r) && (0x9f >= r)) {
+			if UTF8Replace == SanitiseUTF8 {
+				sb.WriteRune(utf8.RuneError)
+			} else {
+				for _, b := range []byte(aText[idx : idx+size]) {
+					fmt.Fprintf(&sb, "%%%02X", b)
+				}
+			}
+		} else {
+			sb.WriteString(aText[idx : idx+size])
+		}
+		idx += size
+	}
+
+	return sb.String()
+} // sanitiseString()
+
+/* _EoF_ */
@@ -0,0 +1,66 @@
+/*
+Copyright © 2024  M.Watermann, 10247 Berlin, Germany
+
+	    All rights reserved
+	EMail : <support@mwat.de>
+*/
+package apachelogger
+
+import (
+	"testing"
+	"unicode/utf8"
+)
+
+//lint:file-ignore ST1017 – I prefer Yoda conditions
+
+func Test_sanitiseString(t *testing.T) {
+	defer func() { SanitiseUTF8 = UTF8Escape }()
+
+	tests := []struct {
+		name string
+		mode int
+		text string
+		want string
+	}{
+		{"1", UTF8Escape, "/path/to/file", "/path/to/file"},
+		{"2", UTF8Escape, "/Müller/straße", "/Müller/straße"},
+		{"3", UTF8Escape, "/bin\xff\xfe", "/bin%FF%FE"},
+		{"4", UTF8Escape, "/a\nb\r\x00", "/a%0Ab%0D%00"},
+		{"5", UTF8Escape, "x\u0085y\x7f", "x%C2%85y%7F"},
+		{"6", UTF8Replace, "/bin\xffx\n", "/bin�x�"},
+		{"7", UTF8Keep, "/bin\xff\n", "/bin\xff\n"},
+		{"8", UTF8Escape, "\xe2\x82", "%E2%82"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			SanitiseUTF8 = tt.mode
+			got := sanitiseString(tt.text)
+			if got != tt.want {
+				t.Errorf("sanitiseString() = %q, want %q", got, tt.want)
+			}
+			if (UTF8Keep != tt.mode) && !utf8.ValidString(got) {
+				t.Errorf("sanitiseString() = %q: invalid UTF-8", got)
+			}
+		})
+	}
+} // Test_sanitiseString()
+
+func Test_sanitiseEntry(t *testing.T) {
+	e := prepEntry()
+	e.Path = "/\xc0\xafetc/passwd"
+	e.Referrer = "http://x/\n127.0.0.1 - - forged"
+	e.SetField("note", "a\tb")
+	sanitiseEntry(e)
+
+	if "/%C0%AFetc/passwd" != e.Path {
+		t.Errorf("Path = %q", e.Path)
+	}
+	if "http://x/%0A127.0.0.1 - - forged" != e.Referrer {
+		t.Errorf("Referrer = %q", e.Referrer)
+	}
+	if "a%09b" != e.Fields["note"] {
+		t.Errorf("note = %q", e.Fields["note"])
+	}
+} // Test_sanitiseEntry()
+
+/* _EoF_ */
@@ -0,0 +1,204 @@
+/*
+Copyright © 2024  M.Watermann, 10247 Berlin, Germany
+
+	    All rights reserved
+	EMail : <support@mwat.de>
+*/
+package apachelogger
+
+import (
+	"fmt"
+	"net/url"
+	"regexp"
+	"strings"
+	"sync"
+)
+
+//lint:file-ignore ST1017 – I prefer Yoda conditions
+
+type (
+	// `tSecurityRule` is a single rule detecting suspicious requests.
+	tSecurityRule struct {
+		name  string             // the name to tag matching entries with
+		match func(*TEntry) bool // the detection function
+	}
+)
+
+var (
+	// `FlagSuspicious` decides whether to check requests against the
+	// security rules, tagging matching entries with a `suspect` field
+	// (default: `false`).
+	FlagSuspicious = false
+
+	// List of registered security rules.
+	alSecurityRules []tSecurityRule
+
+	// Queue of the security logfile (`nil` if none).
+	alSecurityQueue *tRing
+
+	// Guard for concurrent access to `alSecurityRules` and `alSecurityQueue`.
+	alSecurityMtx sync.RWMutex
+)
+
+func init() {
+	initSecurityRules()
+} // init()
+
+// `initSecurityRules()` registers the default security rules.
+func initSecurityRules() {
+	for _, rule := range [][3]string{
+		{"traversal", "path", `\.\./|\.\.\\|/etc/passwd|/proc/self/`},
+		{"sqli", "path", `(?i)union(\s|/\*.*\*/)+(all\s+)?select|'\s*or\s+'?\d+'?\s*=\s*'?\d|\bor\s+1\s*=\s*1\b|sleep\(\s*\d+\s*\)|benchmark\(|information_schema|;\s*drop\s+table`},
+		{"xss", "path", `(?i)<\s*script|javascript:|\bon(error|load|mouseover)\s*=|<\s*(iframe|svg|img)[^>]*\bon\w+\s*=`},
+		{"probe", "path", `(?i)/(\.env|\.git/|\.aws/|\.ssh/|wp-login\.php|xmlrpc\.php|phpmyadmin|cgi-bin/|actuator/|server-status)`},
+		{"scanner", "agent", `(?i)sqlmap|nikto|nmap|masscan|zgrab|nuclei|dirbuster|gobuster|wpscan|acunetix|nessus|openvas|w3af|fuzz`},
+	} {
+		_ = AddSecurityPattern(rule[0], rule[1], rule[2])
+	}
+} // initSecurityRules()
+
+// `decodeRepeatedly()` URL-decodes `aText` (at most three times to cope
+// with multiple encodings).
+//
+// Parameters:
+// - `aText`: The text to decode.
+//
+// Returns:
+// - `string`: The decoded text.
+func decodeRepeatedly(aText string) string {
+	for i := 0; 3 > i; i++ {
+		if !strings.ContainsRune(aText, '%') && !strings.ContainsRune(aText, '+') {
+			break
+		}
+		decoded, err := url.QueryUnescape(aText)
+		if (nil != err) || (decoded == aText) {
+			break
+		}
+		aText = decoded
+	}
+
+	return aText
+} // decodeRepeatedly()
+
+// `flagSuspicious()` checks `aEntry` against all security rules,
+// tagging it with the names of the matching rules.
+//
+// Parameters:
+// - `aEntry`: The log entry to check.
+//
+// Returns:
+// - `bool`: `true` if any rule matched.
+func flagSuspicious(aEntry *TEntry) bool {
+	alSecurityMtx.RLock()
+	defer alSecurityMtx.RUnlock()
+
+	var names []string
+	for _, rule := range alSecurityRules {
+		if rule.match(aEntry) {
+			names = append(names, rule.name)
+		}
+	}
+	if 0 == len(names) {
+		return false
+	}
+	aEntry.SetField("suspect", strings.Join(names, ","))
+
+	return true
+} // flagSuspicious()
+
+// `logSuspicious()` writes the log line of a suspicious request to the
+// security log (if any).
+//
+// Parameters:
+// - `aLine`: The formatted log entry.
+func logSuspicious(aLine string) {
+	alSecurityMtx.RLock()
+	queue := alSecurityQueue
+	alSecurityMtx.RUnlock()
+
+	if nil != queue {
+		queue.push(aLine)
+	}
+} // logSuspicious()
+
+/* * * * * * * * * * * * * * * * * * * * * * * * * * * * * * * * * * * * * */
+
+// `AddSecurityPattern()` registers a security rule matching the
+// URL-decoded field `aField` of the log entries against `aPattern`.
+//
+// Valid field names are those accepted by `AddRedaction()`.
+//
+// Parameters:
+// - `aName`: The name to tag matching entries with.
+// - `aField`: The name of the field to check.
+// - `aPattern`: The regular expression to look for.
+//
+// Returns:
+// - `error`: A possible error parsing `aPattern` or an unknown field.
+func AddSecurityPattern(aName, aField, aPattern string) error {
+	if nil == (&TEntry{}).stringField(aField) {
+		return fmt.Errorf("apachelogger: unknown field name %q", aField)
+	}
+	re, err := regexp.Compile(aPattern)
+	if nil != err {
+		return err
+	}
+
+	AddSecurityRule(aName, func(aEntry *TEntry) bool {
+		field := aEntry.stringField(aField)
+		return (nil != field) && re.MatchString(decodeRepeatedly(*field))
+	})
+
+	return nil
+} // AddSecurityPattern()
+
+// `AddSecurityRule()` registers a function detecting suspicious
+// requests.
+//
+// The function gets the request's log entry before any transformers or
+// redactions are applied and must not modify it.
+//
+// Parameters:
+// - `aName`: The name to tag matching entries with.
+// - `aMatch`: The function reporting whether an entry is suspicious.
+func AddSecurityRule(aName string, aMatch func(aEntry *TEntry) bool) {
+	if nil == aMatch {
+		return
+	}
+	alSecurityMtx.Lock()
+	alSecurityRules = append(alSecurityRules, tSecurityRule{aName, aMatch})
+	alSecurityMtx.Unlock()
+} // AddSecurityRule()
+
+// `ClearSecurityRules()` removes all security rules including the
+// default ones.
+func ClearSecurityRules() {
+	alSecurityMtx.Lock()
+	alSecurityRules = nil
+	alSecurityMtx.Unlock()
+} // ClearSecurityRules()
+
+// `SetSecurityLog()` arranges for suspicious requests (see
+// `FlagSuspicious`) to be written to a dedicated security log in
+// addition to the access log.
+//
+// By default the requests are checked for path traversal, SQL injection
+// and XSS probes, requests of well-known sensitive files (`probe`), and
+// the user agents of common vulnerability scanners; further rules can
+// be added by `AddSecurityRule()` and `AddSecurityPattern()`. Matching
+// entries get a `suspect` field listing the rules' names.
+//
+// Parameters:
+// - `aFilename`: The name of the security logfile (empty for none).
+func SetSecurityLog(aFilename string) {
+	var queue *tRing
+	if "" != aFilename {
+		queue = fileQueue(aFilename)
+	}
+
+	alSecurityMtx.Lock()
+	alSecurityQueue = queue
+	alSecurityMtx.Unlock()
+} // SetSecurityLog()
+
+/* _EoF_ */
@@ -0,0 +1,77 @@
+/*
+Copyright © 2024  M.Watermann, 10247 Berlin, Germany
+
+	    All rights reserved
+	EMail : <support@mwat.de>
+*/
+package apachelogger
+
+import (
+	"testing"
+)
+
+//lint:file-ignore ST1017 – I prefer Yoda conditions
+
+func Test_flagSuspicious(t *testing.T) {
+	tests := []struct {
+		name  string
+		path  string
+		agent string
+		want  string
+	}{
+		{"1", "/path/to/file?lang=en", "Mozilla/5.0", ""},
+		{"2", "/static/../../etc/passwd", "Mozilla/5.0", "traversal"},
+		{"3", "/download?f=%252e%252e%252fsecret", "Mozilla/5.0", "traversal"},
+		{"4", "/item?id=1%20UNION%20SELECT%20password%20FROM%20users", "-", "sqli"},
+		{"5", "/item?id=1'%20or%20'1'='1", "-", "sqli"},
+		{"6", "/search?q=%3Cscript%3Ealert(1)%3C/script%3E", "-", "xss"},
+		{"7", "/.env", "-", "probe"},
+		{"8", "/", "sqlmap/1.7.2#stable", "scanner"},
+		{"9", "/wp-login.php?x=<script>", "Nikto/2.5", "xss,probe,scanner"},
+		{"10", "/union-station/selection", "-", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			e := prepEntry()
+			e.Path, e.Agent = tt.path, tt.agent
+			got := flagSuspicious(e)
+			if got != ("" != tt.want) {
+				t.Errorf("flagSuspicious() = %v, want %v", got, "" != tt.want)
+			}
+			if e.Fields["suspect"] != tt.want {
+				t.Errorf("suspect = %q, want %q", e.Fields["suspect"], tt.want)
+			}
+		})
+	}
+} // Test_flagSuspicious()
+
+func TestAddSecurityRule(t *testing.T) {
+	defer func() {
+		ClearSecurityRules()
+		initSecurityRules()
+	}()
+	ClearSecurityRules()
+
+	if err := AddSecurityPattern("x", "nonsense", "."); nil == err {
+		t.Error("AddSecurityPattern() expected an error for unknown field")
+	}
+	if err := AddSecurityPattern("x", "path", "("); nil == err {
+		t.Error("AddSecurityPattern() expected an error for invalid pattern")
+	}
+	AddSecurityRule("teapot", func(aEntry *TEntry) bool {
+		return "BREW" == aEntry.Method
+	})
+	AddSecurityRule("nil", nil)
+
+	e := prepEntry()
+	e.Path = "/../../etc/passwd" // default rules are cleared
+	if flagSuspicious(e) {
+		t.Errorf("flagSuspicious() = true, want false")
+	}
+	e.Method = "BREW"
+	if !flagSuspicious(e) || ("teapot" != e.Fields["suspect"]) {
+		t.Errorf("suspect = %q, want %q", e.Fields["suspect"], "teapot")
+	}
+} // TestAddSecurityRule()
+
+/* _EoF_ */
@@ -0,0 +1,282 @@
+/*
+Copyright © 2024  M.Watermann, 10247 Berlin, Germany
+
+	    All rights reserved
+	EMail : <support@mwat.de>
+*/
+package apachelogger
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"runtime"
+	"strings"
+	"sync"
+	"time"
+)
+
+//lint:file-ignore ST1017 – I prefer Yoda conditions
+
+type (
+	// `tSentryDSN` holds the data of a parsed Sentry DSN.
+	tSentryDSN struct {
+		endpoint  string // URL of the project's store API
+		publicKey string // the project's public key
+		secretKey string // the project's secret key (legacy DSNs only)
+	}
+
+	// `tSentryFrame` is a single stack frame of a Sentry event.
+	tSentryFrame struct {
+		Function string `json:"function"`
+		Filename string `json:"abs_path"`
+		Lineno   int    `json:"lineno"`
+		InApp    bool   `json:"in_app"`
+	}
+)
+
+var (
+	// The Sentry project to report panics to (`nil` if none).
+	alSentry *tSentryDSN
+
+	// Guard for concurrent access to `alSentry`.
+	alSentryMtx sync.RWMutex
+
+	// The HTTP client used to send Sentry events.
+	alSentryClient = &http.Client{Timeout: 10 * time.Second}
+)
+
+// `auth()` returns the value of the `X-Sentry-Auth` header.
+//
+// Returns:
+// - `string`: The authentication header.
+func (sd *tSentryDSN) auth() string {
+	result := "Sentry sentry_version=7, sentry_client=mwat56/apachelogger, sentry_key=" +
+		sd.publicKey
+	if "" != sd.secretKey {
+		result += ", sentry_secret=" + sd.secretKey
+	}
+
+	return result
+} // auth()
+
+// `send()` posts `aEvent` to the project's store API.
+//
+// Parameters:
+// - `aEvent`: The JSON encoded event.
+func (sd *tSentryDSN) send(aEvent []byte) {
+	req, err := http.NewRequest(http.MethodPost, sd.endpoint, bytes.NewReader(aEvent))
+	if nil != err {
+		return
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Sentry-Auth", sd.auth())
+
+	resp, err := alSentryClient.Do(req)
+	if nil != err {
+		Err("ApacheLogger/Sentry", err.Error())
+		return
+	}
+	_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, 4096))
+	_ = resp.Body.Close()
+	if http.StatusOK != resp.StatusCode {
+		Err("ApacheLogger/Sentry", "sending event failed: "+resp.Status)
+	}
+} // send()
+
+/* * * * * * * * * * * * * * * * * * * * * * * * * * * * * * * * * * * * * */
+
+// `isStdlibFunc()` reports whether `aFunction` belongs to a package of
+// the standard library.
+//
+// Parameters:
+// - `aFunction`: The fully qualified function name.
+//
+// Returns:
+// - `bool`: `true` if the function's package is a standard package.
+func isStdlibFunc(aFunction string) bool {
+	pkg := aFunction
+	if idx := strings.IndexByte(pkg, '/'); 0 <= idx {
+		// the first path element of non-standard packages is a domain
+		return !strings.Contains(pkg[:idx], ".")
+	}
+	if idx := strings.IndexByte(pkg, '.'); 0 <= idx {
+		pkg = pkg[:idx]
+	}
+
+	return "main" != pkg
+} // isStdlibFunc()
+
+// `parseSentryDSN()` parses a Sentry DSN like
+// `https://<key>@<host>/<project>`.
+//
+// Parameters:
+// - `aDSN`: The DSN to parse.
+//
+// Returns:
+// - `*tSentryDSN`: The parsed DSN.
+// - `error`: A possible error parsing `aDSN`.
+func parseSentryDSN(aDSN string) (*tSentryDSN, error) {
+	dsn, err := url.Parse(aDSN)
+	if nil != err {
+		return nil, err
+	}
+	if (("http" != dsn.Scheme) && ("https" != dsn.Scheme)) ||
+		("" == dsn.Host) || (nil == dsn.User) || ("" == dsn.User.Username()) {
+		return nil, fmt.Errorf("apachelogger: invalid Sentry DSN %q", aDSN)
+	}
+	path := strings.TrimSuffix(dsn.Path, "/")
+	idx := strings.LastIndexByte(path, '/')
+	project := path[idx+1:]
+	if "" == project {
+		return nil, fmt.Errorf("apachelogger: missing Sentry project in DSN %q", aDSN)
+	}
+	secret, _ := dsn.User.Password()
+
+	return &tSentryDSN{
+		endpoint: fmt.Sprintf("%s://%s%s/api/%s/store/",
+			dsn.Scheme, dsn.Host, path[:idx], project),
+		publicKey: dsn.User.Username(),
+		secretKey: secret,
+	}, nil
+} // parseSentryDSN()
+
+// `reportPanic()` sends a recovered panic to the Sentry project set by
+// `SetSentryDSN()` (if any).
+//
+// The function must be called by the deferred function recovering the
+// panic to get the panicking goroutine's stack.
+//
+// Parameters:
+// - `aValue`: The value passed to `panic()`.
+// - `aRequest`: The request whose handler panicked.
+func reportPanic(aValue interface{}, aRequest *http.Request) {
+	alSentryMtx.RLock()
+	dsn := alSentry
+	alSentryMtx.RUnlock()
+	if nil == dsn {
+		return
+	}
+
+	pcs := make([]uintptr, 64)
+	pcs = pcs[:runtime.Callers(3, pcs)] // skip the recovering function
+	event, err := sentryEvent(aValue, pcs, aRequest)
+	if nil != err {
+		return
+	}
+	go dsn.send(event)
+} // reportPanic()
+
+// `sentryEvent()` returns the Sentry event of a recovered panic.
+//
+// Parameters:
+// - `aValue`: The value passed to `panic()`.
+// - `aStack`: The program counters of the panicking goroutine.
+// - `aRequest`: The request whose handler panicked.
+//
+// Returns:
+// - `[]byte`: The JSON encoded event.
+// - `error`: A possible encoding error.
+func sentryEvent(aValue interface{}, aStack []uintptr, aRequest *http.Request) ([]byte, error) {
+	var id [16]byte
+	_, _ = rand.Read(id[:])
+	hostname, _ := os.Hostname()
+
+	// Sentry expects the frames from oldest to newest:
+	var frames []tSentryFrame
+	callers := runtime.CallersFrames(aStack)
+	for {
+		frame, more := callers.Next()
+		if ("" != frame.Function) && !strings.HasPrefix(frame.Function, "runtime.") {
+			frames = append([]tSentryFrame{{
+				Function: frame.Function,
+				Filename: frame.File,
+				Lineno:   frame.Line,
+				InApp:    !isStdlibFunc(frame.Function),
+			}}, frames...)
+		}
+		if !more {
+			break
+		}
+	}
+
+	event := map[string]interface{}{
+		"event_id":    hex.EncodeToString(id[:]),
+		"timestamp":   time.Now().UTC().Format("2006-01-02T15:04:05.000Z"),
+		"level":       "fatal",
+		"logger":      "apachelogger",
+		"platform":    "go",
+		"server_name": hostname,
+		"exception": map[string]interface{}{
+			"values": []interface{}{map[string]interface{}{
+				"type":       fmt.Sprintf("%T", aValue),
+				"value":      fmt.Sprint(aValue),
+				"mechanism":  map[string]interface{}{"type": "recover", "handled": true},
+				"stacktrace": map[string]interface{}{"frames": frames},
+			}},
+		},
+	}
+	if nil != aRequest {
+		scheme := "http"
+		if nil != aRequest.TLS {
+			scheme = "https"
+		}
+		headers := make(map[string]string)
+		for _, name := range []string{"Accept", "Content-Type", "User-Agent"} {
+			if value := aRequest.Header.Get(name); "" != value {
+				headers[name] = value
+			}
+		}
+		event["request"] = map[string]interface{}{
+			// without query (which might contain credentials):
+			"url":     scheme + "://" + aRequest.Host + aRequest.URL.EscapedPath(),
+			"method":  aRequest.Method,
+			"headers": headers,
+		}
+		event["user"] = map[string]string{
+			"ip_address": anonymiseAddress(getRemoteAddr(aRequest), 0),
+		}
+	}
+
+	return json.Marshal(event)
+} // sentryEvent()
+
+// `SetSentryDSN()` arranges for panics recovered by the wrapper to be
+// reported to a Sentry (compatible) project in addition to the error
+// log.
+//
+// The reports contain the panic's value and stack, the request's
+// method, URL (without query) and some headers, and the client's
+// anonymised address (see `AnonymiseURLs`). They're sent in the
+// background using Sentry's store API.
+//
+// Parameters:
+// - `aDSN`: The project's DSN (e.g. `https://<key>@o0.ingest.sentry.io/42`);
+// an empty string switches the reporting off.
+//
+// Returns:
+// - `error`: A possible error parsing `aDSN`.
+func SetSentryDSN(aDSN string) error {
+	var dsn *tSentryDSN
+
+	if "" != aDSN {
+		var err error
+		if dsn, err = parseSentryDSN(aDSN); nil != err {
+			return err
+		}
+	}
+
+	alSentryMtx.Lock()
+	alSentry = dsn
+	alSentryMtx.Unlock()
+
+	return nil
+} // SetSentryDSN()
+
+/* _EoF_ */
@@ -0,0 +1,47 @@
+/*
+Copyright © 2024  M.Watermann, 10247 Berlin, Germany
+
+	    All rights reserved
+	EMail : <support@mwat.de>
+*/
+package apachelogger
+
+import (
+	"strconv"
+)
+
+//lint:file-ignore ST1017 – I prefer Yoda conditions
+
+const (
+	// Name of the field holding an entry's sequence number.
+	alSeqField = "seq"
+)
+
+var (
+	// `SequenceNumbers` decides whether to stamp every entry with a
+	// `seq` field holding a number increasing by one for every entry
+	// of the same logfile (default: `false`), so consumers can detect
+	// gaps and reorder merged streams.
+	//
+	// The field is written by the canonical and binary formats, as a
+	// `seq` column of `SetCSVFormat()`, as `%{seq}e` of `SetLogFormat()`,
+	// or if `AppendFields` is set.
+	// Entries of the same connection are always queued in the order of
+	// their completion.
+	SequenceNumbers = false
+)
+
+// `stampSequence()` sets the `seq` field of `aEntry` to the next
+// sequence number of `aQueue` if `SequenceNumbers` is set.
+//
+// Parameters:
+// - `aEntry`: The log entry to stamp.
+// - `aQueue`: The queue the entry is sent to.
+func stampSequence(aEntry *TEntry, aQueue *tRing) {
+	if !SequenceNumbers {
+		return
+	}
+	aEntry.SetField(alSeqField, strconv.FormatUint(aQueue.nextSeq(), 10))
+} // stampSequence()
+
+/* _EoF_ */
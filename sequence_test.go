@@ -0,0 +1,64 @@
+/*
+Copyright © 2024  M.Watermann, 10247 Berlin, Germany
+
+	    All rights reserved
+	EMail : <support@mwat.de>
+*/
+package apachelogger
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+//lint:file-ignore ST1017 – I prefer Yoda conditions
+
+func Test_stampSequence(t *testing.T) {
+	defer func() {
+		SequenceNumbers = false
+	}()
+	queue1, queue2 := newRing(4), newRing(4)
+
+	e1 := prepEntry()
+	stampSequence(e1, queue1)
+	if _, ok := e1.Fields[alSeqField]; ok {
+		t.Errorf("stampSequence() set %q while disabled", alSeqField)
+	}
+
+	SequenceNumbers = true
+	for _, want := range []string{"1", "2", "3"} {
+		e1 = prepEntry()
+		stampSequence(e1, queue1)
+		if got := e1.Fields[alSeqField]; got != want {
+			t.Errorf("stampSequence() = %q, want %q", got, want)
+		}
+	}
+	stampSequence(e1, queue2) // every queue counts on its own
+	if got := e1.Fields[alSeqField]; "1" != got {
+		t.Errorf("stampSequence() = %q, want %q", got, "1")
+	}
+} // Test_stampSequence()
+
+func Test_goCustomLog_sequence(t *testing.T) {
+	defer func() {
+		SequenceNumbers, CanonicalLogLine = false, false
+	}()
+	SequenceNumbers, CanonicalLogLine = true, true
+	queue := newRing(4)
+
+	goCustomLog("test", "first", "LOG", time.Now(), queue)
+	goCustomLog("test", "second", "LOG", time.Now(), queue)
+
+	lines := queue.popBatch(make([]string, 0, 4))
+	if 2 != len(lines) {
+		t.Fatalf("goCustomLog() queued %d lines, want 2", len(lines))
+	}
+	for idx, want := range []string{" seq=1", " seq=2"} {
+		if !strings.Contains(lines[idx], want) {
+			t.Errorf("line %d = %q, want %q", idx, lines[idx], want)
+		}
+	}
+} // Test_goCustomLog_sequence()
+
+/* _EoF_ */
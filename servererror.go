@@ -0,0 +1,51 @@
+/*
+Copyright © 2024  M.Watermann, 10247 Berlin, Germany
+
+	    All rights reserved
+	EMail : <support@mwat.de>
+*/
+package apachelogger
+
+import (
+	"regexp"
+	"strings"
+)
+
+//lint:file-ignore ST1017 – I prefer Yoda conditions
+
+var (
+	// RegEx matching the client address in the error messages of
+	// `http.Server` (and its HTTP/2 implementation).
+	alServerErrorRE = regexp.MustCompile(`(?:http: TLS handshake error from|http: panic serving|` +
+		`http2: server: error reading preface from client|` +
+		`http2: server connection error from) (\[[0-9A-Fa-f:.%]+\]:\d+|[0-9.]+:\d+)`)
+)
+
+// `rewriteServerError()` anonymises the client address embedded in an
+// error message of `http.Server` (e.g. of a failed TLS handshake, a
+// panicking handler, or an invalid HTTP/2 preface).
+//
+// All occurrences of the client's address in the message are replaced
+// by its anonymised form (see `AnonymiseURLs`), so the standard
+// server's messages don't bypass the privacy settings.
+//
+// Parameters:
+// - `aMessage`: The error message written by the server.
+//
+// Returns:
+// - `string`: The (possibly) rewritten error message.
+// - `string`: The anonymised client address (empty if none was found).
+func rewriteServerError(aMessage string) (string, string) {
+	// the TLS messages get the ClientHello's data as well:
+	message := rewriteTLSError(aMessage)
+	match := alServerErrorRE.FindStringSubmatch(aMessage)
+	if nil == match {
+		return message, ""
+	}
+	addr := match[1]
+	remote := anonymiseAddress(addr, 0)
+
+	return strings.Replace(message, addr, remote, -1), remote
+} // rewriteServerError()
+
+/* _EoF_ */
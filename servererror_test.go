@@ -0,0 +1,41 @@
+/*
+Copyright © 2024  M.Watermann, 10247 Berlin, Germany
+
+	    All rights reserved
+	EMail : <support@mwat.de>
+*/
+package apachelogger
+
+import (
+	"testing"
+)
+
+//lint:file-ignore ST1017 – I prefer Yoda conditions
+
+func Test_rewriteServerError(t *testing.T) {
+	tests := []struct {
+		msg    string
+		want   string
+		remote string
+	}{
+		{"http: TLS handshake error from 192.168.1.234:54321: EOF",
+			"http: TLS handshake error from 192.168.1.0: EOF", "192.168.1.0"},
+		{"http: panic serving 203.0.113.77:4711: boom\ngoroutine 7 [running]:",
+			"http: panic serving 203.0.113.0: boom\ngoroutine 7 [running]:", "203.0.113.0"},
+		{"http2: server: error reading preface from client 203.0.113.77:4711: read tcp 10.0.0.1:443->203.0.113.77:4711: read: connection reset by peer",
+			"http2: server: error reading preface from client 203.0.113.0: read tcp 10.0.0.1:443->203.0.113.0: read: connection reset by peer", "203.0.113.0"},
+		{"http2: server connection error from [2001:db8::17]:4711: connection error: PROTOCOL_ERROR",
+			"http2: server connection error from 2001:db8:0:0:0:0:0:0: connection error: PROTOCOL_ERROR", "2001:db8:0:0:0:0:0:0"},
+		{"http: Accept error: too many open files; retrying in 5ms",
+			"http: Accept error: too many open files; retrying in 5ms", ""},
+	}
+	for _, tt := range tests {
+		got, remote := rewriteServerError(tt.msg)
+		if (tt.want != got) || (tt.remote != remote) {
+			t.Errorf("rewriteServerError(%q) = %q, %q,\nwant %q, %q",
+				tt.msg, got, remote, tt.want, tt.remote)
+		}
+	}
+} // Test_rewriteServerError()
+
+/* _EoF_ */
@@ -0,0 +1,390 @@
+/*
+Copyright © 2024  M.Watermann, 10247 Berlin, Germany
+
+	    All rights reserved
+	EMail : <support@mwat.de>
+*/
+package apachelogger
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+//lint:file-ignore ST1017 – I prefer Yoda conditions
+
+type (
+	// `TSink` is the destination of formatted log entries.
+	//
+	// The background writer calls `Write()` with one or more complete,
+	// newline terminated entries (up to `MaxBatchBytes`), so sinks
+	// sending messages must split the data into lines. `Flush()` is
+	// called after each batch of entries, and `Close()` whenever there
+	// was nothing to log for a while or the log source was closed.
+	// After `Close()` the next `Write()` must reopen the sink if
+	// necessary.
+	TSink interface {
+		// `Write()` sends `aData` to the sink.
+		Write(aData []byte) error
+
+		// `Flush()` forces buffered data (if any) out to the sink.
+		Flush() error
+
+		// `Close()` releases the resources held by the sink.
+		Close() error
+	}
+
+	// `tDiscardSink` throws all log entries away.
+	tDiscardSink struct{}
+
+	// `tFileSink` writes log entries to a file.
+	tFileSink struct {
+		file *os.File // the currently opened logfile
+		name string   // the logfile's name
+	}
+
+	// `tSinkQueue` connects a sink with the queue of its writer.
+	tSinkQueue struct {
+		sink  TSink  // the sink written to
+		queue *tRing // the queue of messages for the sink
+	}
+)
+
+var (
+	// `Discard` is a sink throwing all log entries away, e.g. to
+	// switch off the access log with `SetAccessTarget()`.
+	Discard TSink = tDiscardSink{}
+)
+
+// `Close()` does nothing.
+//
+// Part of the `TSink` interface.
+//
+// Returns:
+// - `error`: Always `nil`.
+func (tDiscardSink) Close() error {
+	return nil
+} // Close()
+
+// `Flush()` does nothing.
+//
+// Part of the `TSink` interface.
+//
+// Returns:
+// - `error`: Always `nil`.
+func (tDiscardSink) Flush() error {
+	return nil
+} // Flush()
+
+// `Write()` throws `aData` away.
+//
+// Part of the `TSink` interface.
+//
+// Parameters:
+// - `aData`: The data to discard.
+//
+// Returns:
+// - `error`: Always `nil`.
+func (tDiscardSink) Write(aData []byte) error {
+	return nil
+} // Write()
+
+/* * * * * * * * * * * * * * * * * * * * * * * * * * * * * * * * * * * * * */
+
+// `NewFileSink()` returns a sink appending to the file `aFilename`.
+//
+// The file is opened on demand and closed whenever there was nothing
+// to log for some seconds, so external tools can rotate the file.
+//
+// Parameters:
+// - `aFilename`: The name of the logfile to write to.
+//
+// Returns:
+// - `TSink`: The sink to use with `WrapSinks()`.
+func NewFileSink(aFilename string) TSink {
+	if 0 < len(aFilename) {
+		if absFile, err := filepath.Abs(aFilename); nil == err {
+			aFilename = absFile
+		}
+	}
+
+	return &tFileSink{name: aFilename}
+} // NewFileSink()
+
+// `Close()` closes the logfile.
+//
+// Part of the `TSink` interface.
+//
+// Returns:
+// - `error`: A possible error while closing the logfile.
+func (fs *tFileSink) Close() (rErr error) {
+	if nil != fs.file {
+		rErr = fs.file.Close()
+		fs.file = nil
+	}
+
+	return
+} // Close()
+
+// `Flush()` does nothing since the logfile is opened for synchronous
+// writing.
+//
+// Part of the `TSink` interface.
+//
+// Returns:
+// - `error`: Always `nil`.
+func (fs *tFileSink) Flush() error {
+	return nil
+} // Flush()
+
+// `open()` opens the logfile for appending.
+//
+// Returns:
+// - `error`: A possible error while opening the logfile.
+func (fs *tFileSink) open() (rErr error) {
+	if fs.file, rErr = os.OpenFile(fs.name, alOpenFlags, 0640); /* #nosec G302 */ nil != rErr {
+		return
+	}
+	writeHeader(fs.file)
+
+	return
+} // open()
+
+// `Write()` appends `aData` to the logfile, opening it if necessary.
+//
+// Part of the `TSink` interface.
+//
+// Parameters:
+// - `aData`: The data to write to the logfile.
+//
+// Returns:
+// - `error`: A possible error while writing the data.
+func (fs *tFileSink) Write(aData []byte) (rErr error) {
+	if nil == fs.file {
+		// Loop until we actually opened the logfile:
+		for nil != fs.open() {
+			time.Sleep(1234)
+		}
+	}
+	_, rErr = fs.file.Write(aData)
+
+	return
+} // Write()
+
+/* * * * * * * * * * * * * * * * * * * * * * * * * * * * * * * * * * * * * */
+
+// `writeHeader()` writes the current header (if any) to `aFile` if
+// that file is empty.
+//
+// Parameters:
+// - `aFile`: The logfile just opened.
+func writeHeader(aFile *os.File) {
+	header := fileHeader()
+	if "" == header {
+		return
+	}
+	if fi, err := aFile.Stat(); (nil == err) && (0 == fi.Size()) {
+		_, _ = aFile.WriteString(header)
+	}
+} // writeHeader()
+
+var (
+	// Log queues of additional logfiles, by absolute filename.
+	alFileQueues = make(map[string]*tRing)
+
+	// Guard for concurrent access to `alFileQueues` and `alSinkQueues`.
+	alFileQueuesMtx sync.Mutex
+
+	// Log queues of the sinks (other than files) of the wrappers.
+	alSinkQueues []tSinkQueue
+)
+
+// `fileQueue()` returns the queue of log messages to write to the file
+// `aFilename`, starting a background writer for that file if necessary.
+//
+// If `aFilename` is empty, the access log's queue is returned.
+//
+// Parameters:
+// - `aFilename`: The name of the logfile to write to.
+//
+// Returns:
+// - `*tRing`: The queue to send log messages to.
+func fileQueue(aFilename string) *tRing {
+	if "" == aFilename {
+		return accessQueue()
+	}
+	if absFile, err := filepath.Abs(aFilename); nil == err {
+		aFilename = absFile
+	}
+
+	alFileQueuesMtx.Lock()
+	defer alFileQueuesMtx.Unlock()
+
+	queue, ok := alFileQueues[aFilename]
+	if !ok {
+		queue = newRing(alRingSize)
+		alFileQueues[aFilename] = queue
+		queue.state.started(true) // for `Flush()` before the writer runs
+		go goDoLogWrite(NewFileSink(aFilename), queue)
+	}
+
+	return queue
+} // fileQueue()
+
+// `logQueues()` returns all queues with a background writer.
+//
+// Returns:
+// - `[]*tRing`: The queues of the access and error log, of the further
+// wrappers' sinks, and of the additional logfiles.
+func logQueues() []*tRing {
+	result := []*tRing{accessQueue(), errorQueue()}
+
+	alFileQueuesMtx.Lock()
+	defer alFileQueuesMtx.Unlock()
+	for _, queue := range alFileQueues {
+		result = append(result, queue)
+	}
+	for _, sq := range alSinkQueues {
+		result = append(result, sq.queue)
+	}
+
+	return result
+} // logQueues()
+
+// `otherQueues()` returns the queues with a background writer besides
+// those of the global access and error log.
+//
+// Returns:
+// - `map[string]*tRing`: The queues of the further wrappers' sinks and
+// of the additional logfiles, by filename or sink.
+func otherQueues() map[string]*tRing {
+	var (
+		access, errLog = accessQueue(), errorQueue()
+		result         = make(map[string]*tRing)
+	)
+
+	alFileQueuesMtx.Lock()
+	defer alFileQueuesMtx.Unlock()
+	for name, queue := range alFileQueues {
+		if (access != queue) && (errLog != queue) {
+			result[name] = queue
+		}
+	}
+	for idx, sq := range alSinkQueues {
+		if (access != sq.queue) && (errLog != sq.queue) {
+			result[fmt.Sprintf("sink %d (%T)", idx+1, sq.sink)] = sq.queue
+		}
+	}
+
+	return result
+} // otherQueues()
+
+// `registerSinkQueue()` records `aQueue` as the queue of the already
+// running writer of `aSink`.
+//
+// The caller must hold `alFileQueuesMtx`.
+//
+// Parameters:
+// - `aSink`: The sink written to (`nil` for `Discard`).
+// - `aQueue`: The queue of messages for the sink.
+func registerSinkQueue(aSink TSink, aQueue *tRing) {
+	if nil == aSink {
+		aSink = Discard
+	}
+	if fs, ok := aSink.(*tFileSink); ok && ("" != fs.name) {
+		if _, ok = alFileQueues[fs.name]; !ok {
+			alFileQueues[fs.name] = aQueue
+		}
+		return
+	}
+	for _, sq := range alSinkQueues {
+		if sameSink(sq.sink, aSink) {
+			return
+		}
+	}
+	alSinkQueues = append(alSinkQueues, tSinkQueue{sink: aSink, queue: aQueue})
+} // registerSinkQueue()
+
+// `sinkQueue()` returns the queue of log messages to write to `aSink`,
+// starting a background writer for that sink if necessary.
+//
+// File sinks are identified by their filename, so several wrappers
+// writing to the same file share a single writer.
+//
+// The caller must hold `alFileQueuesMtx`.
+//
+// Parameters:
+// - `aSink`: The sink to write to (`nil` for `Discard`).
+// - `aShards`: The number of shards of a new queue.
+//
+// Returns:
+// - `*tRing`: The queue to send log messages to.
+func sinkQueue(aSink TSink, aShards int) *tRing {
+	if nil == aSink {
+		aSink = Discard
+	}
+	if fs, ok := aSink.(*tFileSink); ok && ("" != fs.name) {
+		if queue, ok := alFileQueues[fs.name]; ok {
+			return queue
+		}
+	} else {
+		for _, sq := range alSinkQueues {
+			if sameSink(sq.sink, aSink) {
+				return sq.queue
+			}
+		}
+	}
+
+	queue := newShardedRing(alRingSize, aShards)
+	registerSinkQueue(aSink, queue)
+	queue.state.started(true) // for `Flush()` before the writer runs
+	go goDoLogWrite(aSink, queue)
+
+	return queue
+} // sinkQueue()
+
+// `sameSink()` reports whether `aSink1` and `aSink2` are the same sink.
+//
+// Parameters:
+// - `aSink1`: The first sink to compare.
+// - `aSink2`: The second sink to compare.
+//
+// Returns:
+// - `bool`: `true` if both arguments refer to the same sink.
+func sameSink(aSink1, aSink2 TSink) (rSame bool) {
+	defer func() {
+		_ = recover() // panic: comparing uncomparable type
+	}()
+
+	return aSink1 == aSink2
+} // sameSink()
+
+// `sameTarget()` reports whether `aSink1` and `aSink2` share a single
+// background writer, i.e. are the same sink or file sinks of the same
+// file (see `sinkQueue()`).
+//
+// Parameters:
+// - `aSink1`: The first sink to compare (`nil` for `Discard`).
+// - `aSink2`: The second sink to compare (`nil` for `Discard`).
+//
+// Returns:
+// - `bool`: `true` if both sinks share a writer.
+func sameTarget(aSink1, aSink2 TSink) bool {
+	if nil == aSink1 {
+		aSink1 = Discard
+	}
+	if nil == aSink2 {
+		aSink2 = Discard
+	}
+	if fs1, ok := aSink1.(*tFileSink); ok && ("" != fs1.name) {
+		fs2, ok := aSink2.(*tFileSink)
+		return ok && (fs1.name == fs2.name)
+	}
+
+	return sameSink(aSink1, aSink2)
+} // sameTarget()
+
+/* _EoF_ */
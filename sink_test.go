@@ -0,0 +1,170 @@
+/*
+Copyright © 2024  M.Watermann, 10247 Berlin, Germany
+
+	    All rights reserved
+	EMail : <support@mwat.de>
+*/
+package apachelogger
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+//lint:file-ignore ST1017 – I prefer Yoda conditions
+
+type (
+	// `tMemSink` collects the data written to it (for testing).
+	tMemSink struct {
+		data    []byte
+		closed  int
+		flushed int
+		writes  int
+	}
+)
+
+func (ms *tMemSink) Close() error {
+	ms.closed++
+	return nil
+} // Close()
+
+func (ms *tMemSink) Flush() error {
+	ms.flushed++
+	return nil
+} // Flush()
+
+func (ms *tMemSink) Write(aData []byte) error {
+	ms.data = append(ms.data, aData...)
+	ms.writes++
+	return nil
+} // Write()
+
+func Test_tFileSink(t *testing.T) {
+	fName := filepath.Join(t.TempDir(), "access.log")
+	sink := NewFileSink(fName)
+
+	if err := sink.Write([]byte("line 1\n")); nil != err {
+		t.Fatalf("tFileSink.Write() error = %v", err)
+	}
+	if err := sink.Close(); nil != err {
+		t.Fatalf("tFileSink.Close() error = %v", err)
+	}
+	// writing after closing should reopen the file:
+	if err := sink.Write([]byte("line 2\n")); nil != err {
+		t.Fatalf("tFileSink.Write() error = %v", err)
+	}
+	_ = sink.Close()
+
+	got, err := os.ReadFile(fName) // #nosec G304
+	if nil != err {
+		t.Fatalf("os.ReadFile() error = %v", err)
+	}
+	if want := "line 1\nline 2\n"; want != string(got) {
+		t.Errorf("tFileSink content = %q, want %q", got, want)
+	}
+} // Test_tFileSink()
+
+func Test_goDoLogWrite(t *testing.T) {
+	queue := newRing(8)
+	queue.push("msg 1\n")
+	queue.push("msg 2\n")
+	queue.push("msg 3\n")
+	queue.close()
+
+	sink := &tMemSink{}
+	goDoLogWrite(sink, queue)
+
+	if want := "msg 1\nmsg 2\nmsg 3\n"; want != string(sink.data) {
+		t.Errorf("goDoLogWrite() wrote %q, want %q", sink.data, want)
+	}
+	if 0 == sink.closed {
+		t.Error("goDoLogWrite() didn't close the sink")
+	}
+} // Test_goDoLogWrite()
+
+func Test_goDoLogWrite_nil(t *testing.T) {
+	queue := newRing(8)
+	queue.push("dropped\n")
+	done := make(chan struct{})
+	go func() {
+		goDoLogWrite(nil, queue)
+		close(done)
+	}()
+	queue.close()
+
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("goDoLogWrite() didn't return after closing the queue")
+	}
+	if 0 != queue.length() {
+		t.Errorf("goDoLogWrite() left %d messages", queue.length())
+	}
+} // Test_goDoLogWrite_nil()
+
+func Test_goDoLogWrite_batch(t *testing.T) {
+	defer func(aMax int) {
+		MaxBatchBytes = aMax
+	}(MaxBatchBytes)
+
+	tests := []struct {
+		name      string
+		maxBytes  int
+		wantWrite int
+	}{
+		{" 1", 64 << 10, 1},
+		{" 2", 12, 3},
+		{" 3", 0, 5},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			MaxBatchBytes = tt.maxBytes
+			queue := newRing(8)
+			for i := 1; i <= 5; i++ {
+				queue.push(fmt.Sprintf("msg %d\n", i))
+			}
+			queue.close()
+
+			sink := &tMemSink{}
+			goDoLogWrite(sink, queue)
+
+			if want := "msg 1\nmsg 2\nmsg 3\nmsg 4\nmsg 5\n"; want != string(sink.data) {
+				t.Errorf("goDoLogWrite() wrote %q, want %q", sink.data, want)
+			}
+			if sink.writes != tt.wantWrite {
+				t.Errorf("goDoLogWrite() writes = %d, want %d", sink.writes, tt.wantWrite)
+			}
+		})
+	}
+} // Test_goDoLogWrite_batch()
+
+func Test_sameSink(t *testing.T) {
+	s1 := &tMemSink{}
+	s2 := &tMemSink{}
+
+	tests := []struct {
+		name  string
+		sink1 TSink
+		sink2 TSink
+		want  bool
+	}{
+		{" 1", s1, s1, true},
+		{" 2", s1, s2, false},
+		{" 3", s1, nil, false},
+		{" 4", nil, nil, true},
+		// TODO: Add test cases.
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := sameSink(tt.sink1, tt.sink2); got != tt.want {
+				t.Errorf("%q: sameSink() = %v, want %v",
+					tt.name, got, tt.want)
+			}
+		})
+	}
+} // Test_sameSink()
+
+/* _EoF_ */
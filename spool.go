@@ -0,0 +1,233 @@
+/*
+Copyright © 2024  M.Watermann, 10247 Berlin, Germany
+
+	    All rights reserved
+	EMail : <support@mwat.de>
+*/
+package apachelogger
+
+import (
+	"encoding/binary"
+	"os"
+	"sync"
+	"sync/atomic"
+)
+
+//lint:file-ignore ST1017 – I prefer Yoda conditions
+
+type (
+	// `tSpool` is the on-disk overflow of a message queue.
+	//
+	// Each message is stored as its length (four bytes, big-endian)
+	// followed by the text; the file is removed as soon as all its
+	// messages are read.
+	tSpool struct {
+		mtx      sync.Mutex // guard for the other fields but `pending`
+		file     *os.File   // the spool file (`nil` if none)
+		readPos  int64      // offset of the next message to read
+		writePos int64      // offset of the next message to write
+		pending  int64      // number of messages spooled (accessed atomically)
+		spilled  uint64     // number of messages ever spooled (accessed atomically)
+		refused  uint64     // number of messages refused by the full spool (accessed atomically)
+	}
+)
+
+const (
+	// Default max. size of a spool file (see `SetSpoolLimit()`).
+	alSpoolDefaultLimit = 1 << 30
+)
+
+var (
+	// The directory and max. size of the queues' spool files (see
+	// `SetSpoolDir()` and `SetSpoolLimit()`).
+	alSpoolDir struct {
+		sync.RWMutex
+		dir   string
+		limit int64
+	}
+)
+
+func init() {
+	alSpoolDir.limit = alSpoolDefaultLimit
+} // init()
+
+// `spoolDir()` returns the directory and max. size of the spool files.
+//
+// Returns:
+// - `string`: The directory (empty if spooling is disabled).
+// - `int64`: The max. size of a spool file in bytes.
+func spoolDir() (string, int64) {
+	alSpoolDir.RLock()
+	defer alSpoolDir.RUnlock()
+
+	return alSpoolDir.dir, alSpoolDir.limit
+} // spoolDir()
+
+// `isEmpty()` reports whether there's no spooled message.
+//
+// Returns:
+// - `bool`: `true` if the spool is empty.
+func (sp *tSpool) isEmpty() bool {
+	return 0 == atomic.LoadInt64(&sp.pending)
+} // isEmpty()
+
+// `length()` returns the number of spooled messages.
+//
+// Returns:
+// - `int`: The number of messages waiting in the spool.
+func (sp *tSpool) length() int {
+	return int(atomic.LoadInt64(&sp.pending))
+} // length()
+
+// `popBatch()` appends the spooled messages to `aBatch` until it
+// reaches its capacity.
+//
+// If the spool file can't be read its remaining messages are lost
+// (and the file removed), so the queue won't get stuck.
+//
+// Parameters:
+// - `aBatch`: The slice to append the messages to.
+//
+// Returns:
+// - `[]string`: The extended slice.
+func (sp *tSpool) popBatch(aBatch []string) []string {
+	sp.mtx.Lock()
+	defer sp.mtx.Unlock()
+
+	var header [4]byte
+	for (nil != sp.file) && (len(aBatch) < cap(aBatch)) && (sp.readPos < sp.writePos) {
+		if _, err := sp.file.ReadAt(header[:], sp.readPos); nil != err {
+			sp.reset()
+			break
+		}
+		text := make([]byte, binary.BigEndian.Uint32(header[:]))
+		if _, err := sp.file.ReadAt(text, sp.readPos+4); nil != err {
+			sp.reset()
+			break
+		}
+		sp.readPos += 4 + int64(len(text))
+		atomic.AddInt64(&sp.pending, -1)
+		aBatch = append(aBatch, string(text))
+	}
+	if (nil != sp.file) && (sp.readPos >= sp.writePos) {
+		sp.reset()
+	}
+
+	return aBatch
+} // popBatch()
+
+// `reset()` closes and removes the spool file.
+//
+// The caller must hold `sp.mtx`.
+func (sp *tSpool) reset() {
+	if nil != sp.file {
+		_ = sp.file.Close()
+		_ = os.Remove(sp.file.Name())
+		sp.file = nil
+	}
+	sp.readPos, sp.writePos = 0, 0
+	atomic.StoreInt64(&sp.pending, 0)
+} // reset()
+
+// `write()` appends `aText` to the spool file, creating it in `aDir`
+// if needed.
+//
+// Parameters:
+// - `aDir`: The directory of the spool file.
+// - `aLimit`: The max. size of the spool file in bytes.
+// - `aText`: The message to spool.
+//
+// Returns:
+// - `bool`: `false` if the message couldn't be spooled.
+func (sp *tSpool) write(aDir string, aLimit int64, aText string) bool {
+	sp.mtx.Lock()
+	defer sp.mtx.Unlock()
+
+	if aLimit < sp.writePos+4+int64(len(aText)) {
+		atomic.AddUint64(&sp.refused, 1)
+		return false
+	}
+	if nil == sp.file {
+		if "" == aDir {
+			return false
+		}
+		file, err := os.CreateTemp(aDir, "apachelogger-spool-*")
+		if nil != err {
+			return false
+		}
+		sp.file = file
+	}
+	record := make([]byte, 4, 4+len(aText))
+	binary.BigEndian.PutUint32(record, uint32(len(aText)))
+	record = append(record, aText...)
+	if _, err := sp.file.WriteAt(record, sp.writePos); nil != err {
+		return false
+	}
+	sp.writePos += int64(len(record))
+	atomic.AddInt64(&sp.pending, 1)
+	atomic.AddUint64(&sp.spilled, 1)
+
+	return true
+} // write()
+
+/* * * * * * * * * * * * * * * * * * * * * * * * * * * * * * * * * * * * * */
+
+// `spill()` writes `aText` to the queue's spool if spooling is enabled
+// or the spool still holds messages.
+//
+// Parameters:
+// - `aText`: The message to spool.
+//
+// Returns:
+// - `bool`: `false` if the message wasn't spooled.
+func (r *tRing) spill(aText string) bool {
+	dir, limit := spoolDir()
+	if ("" == dir) && r.spool.isEmpty() {
+		return false
+	}
+
+	return r.spool.write(dir, limit, aText)
+} // spill()
+
+// `SetSpoolDir()` lets the queues spill their overflow to temporary
+// files in `aDirectory` instead of dropping the messages (or making the
+// callers wait, see `SetBlockWhenFull()`).
+//
+// Once a queue is full, further messages are appended to a spool file
+// until the writer has caught up: it writes the spooled messages after
+// those queued in memory, so their order is preserved, and removes the
+// file when it's drained. If a message can't be spooled (e.g. because
+// the disk is full, or the spool file reached its max. size set by
+// `SetSpoolLimit()`) it's dropped or the caller waits as usual.
+//
+// An empty `aDirectory` disables spooling (default).
+//
+// Parameters:
+// - `aDirectory`: The directory for the spool files.
+func SetSpoolDir(aDirectory string) {
+	alSpoolDir.Lock()
+	alSpoolDir.dir = aDirectory
+	alSpoolDir.Unlock()
+} // SetSpoolDir()
+
+// `SetSpoolLimit()` sets the max. size of a queue's spool file (see
+// `SetSpoolDir()`), so a writer that can't keep up for a long time
+// won't fill the disk.
+//
+// Messages which would exceed the limit aren't spooled but dropped
+// (or the caller waits, see `SetBlockWhenFull()`); their number is
+// reported as `SpoolFull` by `Stats()`.
+//
+// Parameters:
+// - `aMaxBytes`: The max. size in bytes (`0` or less: 1 GiB, the
+// default).
+func SetSpoolLimit(aMaxBytes int64) {
+	if 0 >= aMaxBytes {
+		aMaxBytes = alSpoolDefaultLimit
+	}
+	alSpoolDir.Lock()
+	alSpoolDir.limit = aMaxBytes
+	alSpoolDir.Unlock()
+} // SetSpoolLimit()
+
+/* _EoF_ */
@@ -0,0 +1,78 @@
+/*
+Copyright © 2024  M.Watermann, 10247 Berlin, Germany
+
+	    All rights reserved
+	EMail : <support@mwat.de>
+*/
+package apachelogger
+
+import (
+	"os"
+	"strconv"
+	"sync/atomic"
+	"testing"
+)
+
+//lint:file-ignore ST1017 – I prefer Yoda conditions
+
+func TestSetSpoolDir(t *testing.T) {
+	dir := t.TempDir()
+	SetSpoolDir(dir)
+	defer SetSpoolDir("")
+
+	r := newRing(2)
+	for idx := 0; 10 > idx; idx++ { // mustn't block without a consumer
+		r.push("msg " + strconv.Itoa(idx) + "\n")
+	}
+	if got := r.stats(); (10 != got.Length) || (8 != got.Spilled) {
+		t.Errorf("stats() = %v, want 10 queued and 8 spilled", got)
+	}
+	if files, _ := os.ReadDir(dir); 1 != len(files) {
+		t.Errorf("spool files = %d, want 1", len(files))
+	}
+
+	// the spooled messages follow those in memory:
+	r.push("msg 10\n")
+	var got []string
+	batch := make([]string, 0, 3)
+	for batch = r.popBatch(batch[:0]); 0 < len(batch); batch = r.popBatch(batch[:0]) {
+		got = append(got, batch...)
+	}
+	if 11 != len(got) {
+		t.Fatalf("popBatch() got %d messages, want 11", len(got))
+	}
+	for idx, msg := range got {
+		if want := "msg " + strconv.Itoa(idx) + "\n"; want != msg {
+			t.Errorf("message %d = %q, want %q", idx, msg, want)
+		}
+	}
+	if !r.isEmpty() {
+		t.Error("isEmpty() = false after draining the spool")
+	}
+	if files, _ := os.ReadDir(dir); 0 != len(files) {
+		t.Errorf("spool files = %d, want 0 after draining", len(files))
+	}
+} // TestSetSpoolDir()
+
+func TestSetSpoolLimit(t *testing.T) {
+	SetSpoolDir(t.TempDir())
+	SetSpoolLimit(30) // three messages
+	defer func() {
+		SetSpoolDir("")
+		SetSpoolLimit(0)
+	}()
+
+	r := newRing(2)
+	for idx := 0; 10 > idx; idx++ {
+		r.push("msg " + strconv.Itoa(idx) + "\n")
+	}
+	got := r.stats()
+	if (5 != got.Length) || (3 != got.Spilled) || (5 != got.SpoolFull) {
+		t.Errorf("stats() = %v, want 5 queued, 3 spilled, and 5 refused", got)
+	}
+	if dropped := atomic.LoadUint64(&r.dropped); 5 != dropped {
+		t.Errorf("dropped = %d, want 5", dropped)
+	}
+} // TestSetSpoolLimit()
+
+/* _EoF_ */
@@ -0,0 +1,334 @@
+/*
+Copyright © 2024  M.Watermann, 10247 Berlin, Germany
+
+	    All rights reserved
+	EMail : <support@mwat.de>
+*/
+package apachelogger
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+//lint:file-ignore ST1017 – I prefer Yoda conditions
+
+type (
+	// `tCustomGeneration` tracks the custom log messages started
+	// between two calls of `Drain()`.
+	tCustomGeneration struct {
+		wg   sync.WaitGroup     // the generation's messages not yet queued
+		prev *tCustomGeneration // the generation before (until it's done)
+		done chan struct{}      // closed once this and all earlier generations are queued
+	}
+)
+
+const (
+	// Time between checks of `Flush()`.
+	alFlushPoll = time.Millisecond
+)
+
+var (
+	// Number of custom log messages not yet queued (accessed atomically).
+	alPendingCustom int64
+
+	// The current generation of custom log messages (see `Drain()`).
+	alCustomGen struct {
+		sync.Mutex
+		current *tCustomGeneration
+	}
+
+	// The error returned by `Flush()` if there's no writer.
+	errNotStarted = errors.New("apachelogger: log writer not started")
+
+	// The error returned by `Barrier()` if its entry was dropped.
+	errBarrierDropped = errors.New("apachelogger: barrier entry dropped by the closed queue")
+)
+
+// `flushed()` reports whether all messages of the queue are written.
+//
+// Returns:
+// - `bool`: `true` if the queue is empty and its writer idle.
+// - `error`: `errNotStarted` if messages wait without a writer.
+func (r *tRing) flushed() (bool, error) {
+	if 0 != atomic.LoadInt32(&r.state.busy) {
+		return false, nil
+	}
+	if r.isEmpty() {
+		return true, nil
+	}
+	r.state.Lock()
+	running := r.state.running
+	r.state.Unlock()
+	if !running {
+		return false, errNotStarted
+	}
+
+	return false, nil
+} // flushed()
+
+// `closeCustomGeneration()` starts a new generation of custom log
+// messages, returning the one started before.
+//
+// Returns:
+// - `*tCustomGeneration`: The closed generation (`nil` if there was
+// none); its `done` channel is closed once all its messages and those
+// of earlier generations are queued.
+func closeCustomGeneration() *tCustomGeneration {
+	alCustomGen.Lock()
+	defer alCustomGen.Unlock()
+
+	closed := alCustomGen.current
+	if nil == closed {
+		return nil
+	}
+	alCustomGen.current = &tCustomGeneration{prev: closed, done: make(chan struct{})}
+	go func() {
+		closed.wg.Wait() // no more messages are added to `closed`
+		if nil != closed.prev {
+			<-closed.prev.done
+			closed.prev = nil // release the older generations
+		}
+		close(closed.done)
+	}()
+
+	return closed
+} // closeCustomGeneration()
+
+// `spawnCustom()` runs `aFunc` in background, counting it as pending
+// for `Flush()` and `Drain()` until it returns.
+//
+// Parameters:
+// - `aFunc`: The function to run, getting the current time.
+func spawnCustom(aFunc func(aNow time.Time)) {
+	now := time.Now()
+	atomic.AddInt64(&alPendingCustom, 1)
+	alCustomGen.Lock()
+	gen := alCustomGen.current
+	if nil == gen {
+		gen = &tCustomGeneration{done: make(chan struct{})}
+		alCustomGen.current = gen
+	}
+	gen.wg.Add(1)
+	alCustomGen.Unlock()
+
+	go func() {
+		defer func() {
+			gen.wg.Done()
+			atomic.AddInt64(&alPendingCustom, -1)
+		}()
+		aFunc(now)
+	}()
+} // spawnCustom()
+
+/* * * * * * * * * * * * * * * * * * * * * * * * * * * * * * * * * * * * * */
+
+// `Drain()` waits until every message queued before the call was
+// written to its sink.
+//
+// Unlike `Flush()`, which waits for the queues to become empty, it
+// doesn't wait for messages logged meanwhile, so it returns even under
+// continuous load. Tests can call it instead of sleeping before they
+// check a logfile, and command line tools before they exit.
+//
+// Parameters:
+// - `aContext`: The context limiting the time to wait.
+//
+// Returns:
+// - `error`: The context's error if it's done before all messages
+// were written, or an error if no writer was started.
+func Drain(aContext context.Context) error {
+	customs := closeCustomGeneration()
+	queues := logQueues()
+	targets := make([]uint64, len(queues))
+	for idx, queue := range queues {
+		targets[idx] = atomic.LoadUint64(&queue.queued)
+	}
+
+	for {
+		if nil != customs {
+			select {
+			case <-customs.done:
+				// the custom messages logged before are queued now:
+				for idx, queue := range queues {
+					targets[idx] = atomic.LoadUint64(&queue.queued)
+				}
+				customs = nil
+			default:
+			}
+		}
+		done := nil == customs
+		for idx, queue := range queues {
+			if atomic.LoadUint64(&queue.written) >= targets[idx] {
+				continue
+			}
+			done = false
+			if _, err := queue.flushed(); nil != err {
+				return err
+			}
+		}
+		if done {
+			return nil
+		}
+
+		select {
+		case <-aContext.Done():
+			return aContext.Err()
+		case <-time.After(alFlushPoll):
+		}
+	}
+} // Drain()
+
+// `Flush()` waits until all messages logged before were written to
+// their sinks.
+//
+// Short-lived programs (like command line tools using `Log()`) should
+// call it before exiting, so no message gets lost.
+//
+// Parameters:
+// - `aContext`: The context limiting the time to wait.
+//
+// Returns:
+// - `error`: The context's error if it's done before all messages
+// were written, or an error if no writer was started.
+func Flush(aContext context.Context) error {
+	for {
+		done := 0 == atomic.LoadInt64(&alPendingCustom)
+		for _, queue := range logQueues() {
+			ok, err := queue.flushed()
+			if nil != err {
+				return err
+			}
+			done = done && ok
+		}
+		if done {
+			return nil
+		}
+
+		select {
+		case <-aContext.Done():
+			return aContext.Err()
+		case <-time.After(alFlushPoll):
+		}
+	}
+} // Flush()
+
+// `Start()` starts the background writers of the access and error
+// log without wrapping a handler, e.g. for command line tools using
+// `Log()` and `Err()` only.
+//
+// Messages logged before are written as soon as the writers run;
+// calling `Wrap()` or `WrapSinks()` later with the same files (or
+// sinks) doesn't start other writers.
+// Call `Flush()` before the program exits.
+//
+// Parameters:
+// - `aAccessLog`: The name of the file to use for access log messages.
+// - `aErrorLog`: The name of the file to use for error log messages.
+//
+// Returns:
+// - `error`: A possible error opening one of the files.
+func Start(aAccessLog, aErrorLog string) error {
+	accessSink, errorSink, err := openSinks(aAccessLog, aErrorLog)
+	if nil != err {
+		return err
+	}
+	StartSinks(accessSink, errorSink)
+
+	return nil
+} // Start()
+
+// `StartSinks()` works like `Start()` but writes the log messages to
+// the given sinks (see `WrapSinks()`).
+//
+// Parameters:
+// - `aAccessSink`: The sink to use for access log messages.
+// - `aErrorSink`: The sink to use for error log messages.
+func StartSinks(aAccessSink, aErrorSink TSink) {
+	startWriters(aAccessSink, aErrorSink)
+} // StartSinks()
+
+// `StartSinksContext()` works like `StartSinks()` but stops the
+// writers once `aContext` is done (see `WithContext()`).
+//
+// Parameters:
+// - `aContext`: The context whose end stops the writers.
+// - `aAccessSink`: The sink to use for access log messages.
+// - `aErrorSink`: The sink to use for error log messages.
+func StartSinksContext(aContext context.Context, aAccessSink, aErrorSink TSink) {
+	accessRing, errorRing := startWriters(aAccessSink, aErrorSink)
+	watchContext(aContext, accessRing, errorRing)
+} // StartSinksContext()
+
+// `stopQueues()` closes `aQueues`, so their writers write the messages
+// queued so far, close their sinks, and return.
+//
+// The queues are removed from the registered sinks, so using their
+// sinks again starts new writers. If one of the global queues (used by
+// e.g. `Log()` and `Err()`) is among them, both global queues are
+// stopped and replaced at once by new ones whose writers discard the
+// messages until the next `Start()` or `Wrap()` starts writers for
+// their sinks again.
+//
+// Parameters:
+// - `aQueues`: The queues to close.
+func stopQueues(aQueues []*tRing) {
+	alFileQueuesMtx.Lock()
+	defer alFileQueuesMtx.Unlock()
+
+	current := alLogQueues.Load().(*tLogQueues)
+	for _, queue := range aQueues {
+		if (queue == current.accessLog) || (queue == current.errorLog) {
+			aQueues = append(aQueues[:len(aQueues):len(aQueues)],
+				current.accessLog, current.errorLog)
+			queues := &tLogQueues{
+				accessLog: newRing(alRingSize),
+				errorLog:  newRing(alRingSize),
+				discard:   true,
+			}
+			queues.accessLog.state.started(true)
+			go goDoLogWrite(Discard, queues.accessLog)
+			queues.errorLog.state.started(true)
+			go goDoLogWrite(Discard, queues.errorLog)
+			alLogQueues.Store(queues)
+			break
+		}
+	}
+
+	for _, queue := range aQueues {
+		for name, fq := range alFileQueues {
+			if fq == queue {
+				delete(alFileQueues, name)
+			}
+		}
+		sinks := make([]tSinkQueue, 0, len(alSinkQueues))
+		for _, sq := range alSinkQueues {
+			if sq.queue != queue {
+				sinks = append(sinks, sq)
+			}
+		}
+		alSinkQueues = sinks
+		queue.close()
+	}
+} // stopQueues()
+
+// `watchContext()` stops the writers of `aQueues` once `aContext` is
+// done.
+//
+// Parameters:
+// - `aContext`: The context whose end stops the writers.
+// - `aQueues`: The queues of the writers to stop.
+func watchContext(aContext context.Context, aQueues ...*tRing) {
+	if (nil == aContext) || (nil == aContext.Done()) {
+		return // never done
+	}
+	go func() {
+		<-aContext.Done()
+		stopQueues(aQueues)
+	}()
+} // watchContext()
+
+/* _EoF_ */
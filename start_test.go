@@ -0,0 +1,202 @@
+/*
+Copyright © 2024  M.Watermann, 10247 Berlin, Germany
+
+	    All rights reserved
+	EMail : <support@mwat.de>
+*/
+package apachelogger
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+//lint:file-ignore ST1017 – I prefer Yoda conditions
+
+// `setLogQueues()` replaces the global log queues (for testing).
+//
+// Parameters:
+// - `aAccess`: The new access queue (`nil` keeps the current one).
+// - `aError`: The new error queue (`nil` keeps the current one).
+//
+// Returns:
+// - `*tRing`: The previous access queue.
+// - `*tRing`: The previous error queue.
+func setLogQueues(aAccess, aError *tRing) (rAccess, rError *tRing) {
+	alFileQueuesMtx.Lock()
+	defer alFileQueuesMtx.Unlock()
+
+	current := alLogQueues.Load().(*tLogQueues)
+	queues := *current
+	if nil != aAccess {
+		queues.accessLog = aAccess
+	}
+	if nil != aError {
+		queues.errorLog = aError
+	}
+	alLogQueues.Store(&queues)
+
+	return current.accessLog, current.errorLog
+} // setLogQueues()
+
+func Test_tRing_flushed(t *testing.T) {
+	queue := newRing(8)
+	if ok, err := queue.flushed(); !ok || (nil != err) {
+		t.Errorf("flushed() = %v, %v, want true, nil", ok, err)
+	}
+	queue.push("msg 1\n")
+	if _, err := queue.flushed(); errNotStarted != err {
+		t.Errorf("flushed() error = %v, want %v", err, errNotStarted)
+	}
+
+	sink := &tMemSink{}
+	done := make(chan struct{})
+	go func() {
+		goDoLogWrite(sink, queue)
+		close(done)
+	}()
+	deadline := time.Now().Add(time.Second)
+	for {
+		if ok, _ := queue.flushed(); ok {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("flushed() didn't report the written message")
+		}
+		time.Sleep(time.Millisecond)
+	}
+	queue.close()
+	<-done
+
+	if want := "msg 1\n"; want != string(sink.data) {
+		t.Errorf("goDoLogWrite() wrote %q, want %q", sink.data, want)
+	}
+} // Test_tRing_flushed()
+
+func TestDrain(t *testing.T) {
+	sink := &tMemSink{}
+	handler := WrapSinks(http.HandlerFunc(func(http.ResponseWriter, *http.Request) {}),
+		sink, Discard)
+	for idx := 0; 3 > idx; idx++ {
+		handler.ServeHTTP(httptest.NewRecorder(),
+			httptest.NewRequest(http.MethodGet, "/drain", nil))
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := Drain(ctx); nil != err {
+		t.Fatalf("Drain() error = %v", err)
+	}
+	if got := strings.Count(string(sink.data), " /drain "); 3 != got {
+		t.Errorf("Drain() returned with %d entries written, want 3", got)
+	}
+} // TestDrain()
+
+func Test_closeCustomGeneration(t *testing.T) {
+	block := make(chan struct{})
+	spawnCustom(func(time.Time) { <-block }) // started before the call
+	gen := closeCustomGeneration()
+
+	// messages started later don't count, even if they finish first:
+	var wg sync.WaitGroup
+	for idx := 0; 5 > idx; idx++ {
+		wg.Add(1)
+		spawnCustom(func(time.Time) { wg.Done() })
+	}
+	wg.Wait()
+	later := closeCustomGeneration()
+	select {
+	case <-gen.done:
+		t.Fatal("generation done while its message is running")
+	case <-later.done:
+		t.Fatal("later generation done before the earlier one")
+	case <-time.After(20 * time.Millisecond):
+	}
+
+	close(block)
+	for _, g := range []*tCustomGeneration{gen, later} {
+		select {
+		case <-g.done:
+		case <-time.After(time.Second):
+			t.Fatal("generation not done after its messages returned")
+		}
+	}
+} // Test_closeCustomGeneration()
+
+func TestFlush(t *testing.T) {
+	atomic.AddInt64(&alPendingCustom, 1)
+	defer atomic.AddInt64(&alPendingCustom, -1)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if err := Flush(ctx); (context.DeadlineExceeded != err) && (errNotStarted != err) {
+		t.Errorf("Flush() error = %v, want %v", err, context.DeadlineExceeded)
+	}
+} // TestFlush()
+
+func TestStartSinksContext(t *testing.T) {
+	previous := alLogQueues.Load().(*tLogQueues)
+	alLogQueues.Store(&tLogQueues{
+		accessLog: newRing(alRingSize),
+		errorLog:  newRing(alRingSize),
+	})
+	defer func() {
+		queues := alLogQueues.Load().(*tLogQueues)
+		queues.accessLog.close()
+		queues.errorLog.close()
+		alLogQueues.Store(previous)
+	}()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	StartSinksContext(ctx, &tMemSink{}, &tMemSink{})
+	started := accessQueue()
+
+	var wg sync.WaitGroup
+	stop := make(chan struct{})
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for {
+			select {
+			case <-stop:
+				return
+			default:
+				Log("TestStartSinksContext", "message")
+				Err("TestStartSinksContext", "error")
+				_ = Health()
+				time.Sleep(50 * time.Microsecond)
+			}
+		}
+	}()
+	time.Sleep(5 * time.Millisecond)
+	cancel()
+
+	deadline := time.Now().Add(5 * time.Second)
+	for started == accessQueue() {
+		if time.Now().After(deadline) {
+			t.Fatal("the global queues weren't replaced after the context was cancelled")
+		}
+		time.Sleep(time.Millisecond)
+	}
+	time.Sleep(5 * time.Millisecond)
+	close(stop)
+	wg.Wait()
+
+	flushCtx, flushCancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer flushCancel()
+	if err := Flush(flushCtx); nil != err {
+		t.Errorf("Flush() error = %v, want the messages discarded", err)
+	}
+	if got, _ := accessQueue().health(); !got.Running {
+		t.Error("the replaced access queue has no writer")
+	}
+} // TestStartSinksContext()
+
+/* _EoF_ */
@@ -0,0 +1,82 @@
+/*
+Copyright © 2024  M.Watermann, 10247 Berlin, Germany
+
+	    All rights reserved
+	EMail : <support@mwat.de>
+*/
+package apachelogger
+
+import (
+	"fmt"
+	"sync/atomic"
+)
+
+//lint:file-ignore ST1017 – I prefer Yoda conditions
+
+type (
+	// `TQueueStats` holds the usage figures of a log message queue.
+	TQueueStats struct {
+		Length    int    // number of messages currently queued
+		Capacity  int    // current number of slots
+		HighWater int    // max. number of queued messages seen
+		FullWaits uint64 // times a caller found the queue full
+		Resizes   uint64 // times the queue was grown or shrunk
+		Spilled   uint64 // messages written to the spool (see `SetSpoolDir()`)
+		SpoolFull uint64 // messages refused by the full spool (see `SetSpoolLimit()`)
+	}
+
+	// `TStats` holds the usage figures of the logger.
+	TStats struct {
+		Access TQueueStats // the access log's queue
+		Error  TQueueStats // the error log's queue
+		Paused bool        // access logging is paused (see `Pause()`)
+	}
+)
+
+// `String()` returns the figures as `key=value` pairs.
+//
+// Returns:
+// - `string`: The textual representation of the figures.
+func (qs TQueueStats) String() string {
+	return fmt.Sprintf("length=%d capacity=%d high_water=%d full_waits=%d resizes=%d spilled=%d spool_full=%d",
+		qs.Length, qs.Capacity, qs.HighWater, qs.FullWaits, qs.Resizes, qs.Spilled, qs.SpoolFull)
+} // String()
+
+// `stats()` returns the usage figures of the queue.
+//
+// The figures of all shards are summed up.
+//
+// Returns:
+// - `TQueueStats`: The queue's figures.
+func (r *tRing) stats() (rStats TQueueStats) {
+	for _, shard := range r.shards {
+		current := shard.current()
+		rStats.Length += current.length()
+		rStats.Capacity += len(current.slots)
+		rStats.HighWater += int(atomic.LoadUint64(&shard.hwm))
+		rStats.FullWaits += atomic.LoadUint64(&shard.full)
+		rStats.Resizes += atomic.LoadUint64(&shard.resizes)
+	}
+	rStats.Length += r.spool.length()
+	rStats.Spilled = atomic.LoadUint64(&r.spool.spilled)
+	rStats.SpoolFull = atomic.LoadUint64(&r.spool.refused)
+
+	return
+} // stats()
+
+// `Stats()` returns the current usage figures of the logger's queues.
+//
+// If access and error messages are written to the same sink both
+// queues' figures are the same.
+//
+// Returns:
+// - `TStats`: The current figures.
+func Stats() TStats {
+	return TStats{
+		Access: accessQueue().stats(),
+		Error:  errorQueue().stats(),
+		Paused: accessPaused(),
+	}
+} // Stats()
+
+/* _EoF_ */
@@ -0,0 +1,41 @@
+/*
+Copyright © 2024  M.Watermann, 10247 Berlin, Germany
+
+	    All rights reserved
+	EMail : <support@mwat.de>
+*/
+package apachelogger
+
+import (
+	"testing"
+)
+
+//lint:file-ignore ST1017 – I prefer Yoda conditions
+
+func Test_tRing_stats(t *testing.T) {
+	r := newShardedRing(4, 2)
+	r.pushKey("a", "msg 1")
+	r.pushKey("a", "msg 2")
+	_ = r.popBatch(make([]string, 0, 1))
+
+	got := r.stats()
+	want := TQueueStats{Length: 1, Capacity: 8, HighWater: 2}
+	if got != want {
+		t.Errorf("stats() = %v,\nwant %v", got, want)
+	}
+	if w := "length=1 capacity=8 high_water=2 full_waits=0 resizes=0 spilled=0 spool_full=0"; w != got.String() {
+		t.Errorf("String() = %q, want %q", got.String(), w)
+	}
+} // Test_tRing_stats()
+
+func TestStats(t *testing.T) {
+	got := Stats()
+	if alRingSize > got.Access.Capacity {
+		t.Errorf("Stats().Access.Capacity = %d, want >= %d", got.Access.Capacity, alRingSize)
+	}
+	if alRingSize > got.Error.Capacity {
+		t.Errorf("Stats().Error.Capacity = %d, want >= %d", got.Error.Capacity, alRingSize)
+	}
+} // TestStats()
+
+/* _EoF_ */
@@ -0,0 +1,237 @@
+/*
+Copyright © 2024  M.Watermann, 10247 Berlin, Germany
+
+	    All rights reserved
+	EMail : <support@mwat.de>
+*/
+package apachelogger
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+//lint:file-ignore ST1017 – I prefer Yoda conditions
+
+const (
+	// The conversion specifiers supported by `appendStrftime()`.
+	alStrftimeSpecifiers = "aAbBcCdDeFgGhHIjklmMnprRsStTuUVwWxXyYzZ%"
+)
+
+// `appendPadded()` appends `aValue` padded to `aWidth` characters
+// with `aPad` characters.
+//
+// Parameters:
+// - `aBuffer`: The buffer to append to.
+// - `aValue`: The (non-negative) number to append.
+// - `aWidth`: The min. number of characters.
+// - `aPad`: The padding character (`'0'` or `' '`).
+//
+// Returns:
+// - `[]byte`: The extended buffer.
+func appendPadded(aBuffer []byte, aValue, aWidth int, aPad byte) []byte {
+	digits := 1
+	for value := aValue; 10 <= value; value /= 10 {
+		digits++
+	}
+	for ; digits < aWidth; digits++ {
+		aBuffer = append(aBuffer, aPad)
+	}
+
+	return strconv.AppendInt(aBuffer, int64(aValue), 10)
+} // appendPadded()
+
+// `appendStrftime()` appends `aTime` formatted according to the C
+// library's `strftime()` conversion specifiers (in the "C" locale).
+//
+// Parameters:
+// - `aBuffer`: The buffer to append to.
+// - `aTime`: The time to format.
+// - `aFormat`: The `strftime()` format.
+//
+// Returns:
+// - `[]byte`: The extended buffer.
+func appendStrftime(aBuffer []byte, aTime time.Time, aFormat string) []byte {
+	for idx := 0; idx < len(aFormat); idx++ {
+		if ('%' != aFormat[idx]) || (idx+1 >= len(aFormat)) {
+			aBuffer = append(aBuffer, aFormat[idx])
+			continue
+		}
+		idx++
+		switch aFormat[idx] {
+		case 'a':
+			aBuffer = append(aBuffer, aTime.Weekday().String()[:3]...)
+		case 'A':
+			aBuffer = append(aBuffer, aTime.Weekday().String()...)
+		case 'b', 'h':
+			aBuffer = append(aBuffer, aTime.Month().String()[:3]...)
+		case 'B':
+			aBuffer = append(aBuffer, aTime.Month().String()...)
+		case 'c':
+			aBuffer = appendStrftime(aBuffer, aTime, "%a %b %e %H:%M:%S %Y")
+		case 'C':
+			aBuffer = appendPadded(aBuffer, aTime.Year()/100, 2, '0')
+		case 'd':
+			aBuffer = appendPadded(aBuffer, aTime.Day(), 2, '0')
+		case 'D', 'x':
+			aBuffer = appendStrftime(aBuffer, aTime, "%m/%d/%y")
+		case 'e':
+			aBuffer = appendPadded(aBuffer, aTime.Day(), 2, ' ')
+		case 'F':
+			aBuffer = appendStrftime(aBuffer, aTime, "%Y-%m-%d")
+		case 'g':
+			year, _ := aTime.ISOWeek()
+			aBuffer = appendPadded(aBuffer, year%100, 2, '0')
+		case 'G':
+			year, _ := aTime.ISOWeek()
+			aBuffer = strconv.AppendInt(aBuffer, int64(year), 10)
+		case 'H':
+			aBuffer = appendPadded(aBuffer, aTime.Hour(), 2, '0')
+		case 'I':
+			aBuffer = appendPadded(aBuffer, hour12(aTime), 2, '0')
+		case 'j':
+			aBuffer = appendPadded(aBuffer, aTime.YearDay(), 3, '0')
+		case 'k':
+			aBuffer = appendPadded(aBuffer, aTime.Hour(), 2, ' ')
+		case 'l':
+			aBuffer = appendPadded(aBuffer, hour12(aTime), 2, ' ')
+		case 'm':
+			aBuffer = appendPadded(aBuffer, int(aTime.Month()), 2, '0')
+		case 'M':
+			aBuffer = appendPadded(aBuffer, aTime.Minute(), 2, '0')
+		case 'n':
+			aBuffer = append(aBuffer, '\n')
+		case 'p':
+			if 12 > aTime.Hour() {
+				aBuffer = append(aBuffer, "AM"...)
+			} else {
+				aBuffer = append(aBuffer, "PM"...)
+			}
+		case 'r':
+			aBuffer = appendStrftime(aBuffer, aTime, "%I:%M:%S %p")
+		case 'R':
+			aBuffer = appendStrftime(aBuffer, aTime, "%H:%M")
+		case 's':
+			aBuffer = strconv.AppendInt(aBuffer, aTime.Unix(), 10)
+		case 'S':
+			aBuffer = appendPadded(aBuffer, aTime.Second(), 2, '0')
+		case 't':
+			aBuffer = append(aBuffer, '\t')
+		case 'T', 'X':
+			aBuffer = appendStrftime(aBuffer, aTime, "%H:%M:%S")
+		case 'u':
+			aBuffer = strconv.AppendInt(aBuffer, int64((int(aTime.Weekday())+6)%7+1), 10)
+		case 'U': // week of the year, starting with the first Sunday
+			week := (aTime.YearDay() + 6 - int(aTime.Weekday())) / 7
+			aBuffer = appendPadded(aBuffer, week, 2, '0')
+		case 'V':
+			_, week := aTime.ISOWeek()
+			aBuffer = appendPadded(aBuffer, week, 2, '0')
+		case 'w':
+			aBuffer = strconv.AppendInt(aBuffer, int64(aTime.Weekday()), 10)
+		case 'W': // week of the year, starting with the first Monday
+			week := (aTime.YearDay() + 6 - (int(aTime.Weekday())+6)%7) / 7
+			aBuffer = appendPadded(aBuffer, week, 2, '0')
+		case 'y':
+			aBuffer = appendPadded(aBuffer, aTime.Year()%100, 2, '0')
+		case 'Y':
+			aBuffer = strconv.AppendInt(aBuffer, int64(aTime.Year()), 10)
+		case 'z':
+			aBuffer = aTime.AppendFormat(aBuffer, "-0700")
+		case 'Z':
+			aBuffer = aTime.AppendFormat(aBuffer, "MST")
+		default: // `%%` and unknown specifiers
+			aBuffer = append(aBuffer, aFormat[idx])
+		}
+	}
+
+	return aBuffer
+} // appendStrftime()
+
+// `appendTimeFormat()` appends `aTime` formatted according to the
+// parameter of Apache's `%{format}t` directive.
+//
+// The format may start with `begin:` or `end:` to select the time the
+// request was received or the time it was finished (default: see
+// `Timestamp`); an empty format is Apache's default `[%d/%b/%Y:%H:%M:%S %z]`.
+// Besides `strftime()` formats the special formats `sec`, `msec`,
+// `usec` (since the epoch), `msec_frac`, and `usec_frac` (fractions
+// of the current second) are supported.
+//
+// Parameters:
+// - `aBuffer`: The buffer to append to.
+// - `aEntry`: The log entry whose time to format.
+// - `aFormat`: The directive's parameter.
+//
+// Returns:
+// - `[]byte`: The extended buffer.
+func appendTimeFormat(aBuffer []byte, aEntry *TEntry, aFormat string) []byte {
+	when := aEntry.timestamp()
+	if strings.HasPrefix(aFormat, "end:") {
+		when = aEntry.inLocation(aEntry.When.Add(aEntry.Duration))
+		aFormat = aFormat[4:]
+	} else if strings.HasPrefix(aFormat, "begin:") {
+		when = aEntry.inLocation(aEntry.When)
+		aFormat = aFormat[6:]
+	}
+
+	switch aFormat {
+	case "":
+		return when.AppendFormat(aBuffer, "[02/Jan/2006:15:04:05 -0700]")
+	case "sec":
+		return strconv.AppendInt(aBuffer, when.Unix(), 10)
+	case "msec":
+		return strconv.AppendInt(aBuffer, when.UnixNano()/int64(time.Millisecond), 10)
+	case "usec":
+		return strconv.AppendInt(aBuffer, when.UnixNano()/int64(time.Microsecond), 10)
+	case "msec_frac":
+		return appendPadded(aBuffer, when.Nanosecond()/int(time.Millisecond), 3, '0')
+	case "usec_frac":
+		return appendPadded(aBuffer, when.Nanosecond()/int(time.Microsecond), 6, '0')
+	}
+
+	return appendStrftime(aBuffer, when, aFormat)
+} // appendTimeFormat()
+
+// `checkStrftime()` checks the `strftime()` conversion specifiers of
+// `aFormat`.
+//
+// Parameters:
+// - `aFormat`: The `strftime()` format to check.
+//
+// Returns:
+// - `error`: A possible error for an unsupported specifier.
+func checkStrftime(aFormat string) error {
+	for idx := 0; idx < len(aFormat); idx++ {
+		if '%' != aFormat[idx] {
+			continue
+		}
+		if idx++; idx >= len(aFormat) {
+			return fmt.Errorf("incomplete time conversion at end of %q", aFormat)
+		}
+		if 0 > strings.IndexByte(alStrftimeSpecifiers, aFormat[idx]) {
+			return fmt.Errorf("unsupported time conversion %%%c", aFormat[idx])
+		}
+	}
+
+	return nil
+} // checkStrftime()
+
+// `hour12()` returns the hour of `aTime` on a 12-hour clock.
+//
+// Parameters:
+// - `aTime`: The time whose hour to return.
+//
+// Returns:
+// - `int`: The hour (1 to 12).
+func hour12(aTime time.Time) int {
+	if hour := aTime.Hour() % 12; 0 != hour {
+		return hour
+	}
+
+	return 12
+} // hour12()
+
+/* _EoF_ */
@@ -0,0 +1,109 @@
+/*
+Copyright © 2024  M.Watermann, 10247 Berlin, Germany
+
+	    All rights reserved
+	EMail : <support@mwat.de>
+*/
+package apachelogger
+
+import (
+	"strconv"
+	"testing"
+	"time"
+)
+
+//lint:file-ignore ST1017 – I prefer Yoda conditions
+
+func Test_appendStrftime(t *testing.T) {
+	tm := time.Date(2024, 1, 7, 9, 5, 3, 123456789, time.FixedZone("CEST", 7200))
+	tests := []struct {
+		format string
+		want   string
+	}{
+		{"%a %A %b %B %h", "Sun Sunday Jan January Jan"},
+		{"%c", "Sun Jan  7 09:05:03 2024"},
+		{"%C %y %Y", "20 24 2024"},
+		{"%d|%e|%j", "07| 7|007"},
+		{"%D %x %F", "01/07/24 01/07/24 2024-01-07"},
+		{"%g %G %V", "24 2024 01"},
+		{"%H %I %k %l %p", "09 09  9  9 AM"},
+		{"%M %S %T %X %R", "05 03 09:05:03 09:05:03 09:05"},
+		{"%r", "09:05:03 AM"},
+		{"%u %w %U %W", "7 0 01 01"},
+		{"%z %Z", "+0200 CEST"},
+		{"%s", strconv.FormatInt(tm.Unix(), 10)},
+		{"%n%t%%", "\n\t%"},
+		{"[%d/%b/%Y:%H:%M:%S %z]", "[07/Jan/2024:09:05:03 +0200]"},
+		{"100%", "100%"},
+	}
+	for _, tt := range tests {
+		if got := string(appendStrftime(nil, tm, tt.format)); got != tt.want {
+			t.Errorf("appendStrftime(%q) = %q, want %q", tt.format, got, tt.want)
+		}
+	}
+
+	tm = time.Date(2023, 12, 31, 0, 30, 0, 0, time.UTC) // ISO week 52 of 2023
+	if got, want := string(appendStrftime(nil, tm, "%I %p %G-%V %U %W %j")), "12 AM 2023-52 53 52 365"; got != want {
+		t.Errorf("appendStrftime() = %q, want %q", got, want)
+	}
+	tm = time.Date(2024, 12, 30, 13, 0, 0, 0, time.UTC) // ISO week 1 of 2025
+	if got, want := string(appendStrftime(nil, tm, "%I %p %g-%V %u")), "01 PM 25-01 1"; got != want {
+		t.Errorf("appendStrftime() = %q, want %q", got, want)
+	}
+} // Test_appendStrftime()
+
+func Test_appendTimeFormat(t *testing.T) {
+	e1 := prepEntry()
+	e1.When = time.Date(2024, 1, 7, 9, 5, 3, 123456789, time.UTC)
+	e1.Duration = 1500 * time.Millisecond
+
+	tests := []struct {
+		format string
+		want   string
+	}{
+		{"%T", "09:05:03"},
+		{"begin:%T", "09:05:03"},
+		{"end:%T", "09:05:04"},
+		{"sec", strconv.FormatInt(e1.When.Unix(), 10)},
+		{"msec", strconv.FormatInt(e1.When.UnixNano()/1e6, 10)},
+		{"usec", strconv.FormatInt(e1.When.UnixNano()/1e3, 10)},
+		{"msec_frac", "123"},
+		{"usec_frac", "123456"},
+		{"end:usec_frac", "623456"},
+		{"", "[07/Jan/2024:09:05:03 +0000]"},
+		{"end:", "[07/Jan/2024:09:05:04 +0000]"},
+	}
+	for _, tt := range tests {
+		if got := string(appendTimeFormat(nil, e1, tt.format)); got != tt.want {
+			t.Errorf("appendTimeFormat(%q) = %q, want %q", tt.format, got, tt.want)
+		}
+	}
+
+	Timestamp = TimestampEnd
+	defer func() { Timestamp = TimestampBegin }()
+	for format, want := range map[string]string{"%T": "09:05:04", "begin:%T": "09:05:03"} {
+		if got := string(appendTimeFormat(nil, e1, format)); got != want {
+			t.Errorf("appendTimeFormat(%q) = %q, want %q (TimestampEnd)", format, got, want)
+		}
+	}
+} // Test_appendTimeFormat()
+
+func Test_checkStrftime(t *testing.T) {
+	tests := []struct {
+		format  string
+		wantErr bool
+	}{
+		{"%d/%b/%Y:%H:%M:%S %z", false},
+		{"plain", false},
+		{"%%", false},
+		{"%Q", true},
+		{"%H:%", true},
+	}
+	for _, tt := range tests {
+		if err := checkStrftime(tt.format); (nil != err) != tt.wantErr {
+			t.Errorf("checkStrftime(%q) error = %v, wantErr %v", tt.format, err, tt.wantErr)
+		}
+	}
+} // Test_checkStrftime()
+
+/* _EoF_ */
@@ -0,0 +1,143 @@
+/*
+Copyright © 2024  M.Watermann, 10247 Berlin, Germany
+
+	    All rights reserved
+	EMail : <support@mwat.de>
+*/
+package apachelogger
+
+import (
+	"fmt"
+	"sync"
+	"time"
+)
+
+//lint:file-ignore ST1017 – I prefer Yoda conditions
+
+var (
+	// The request counts since the last summary entry.
+	alSummary struct {
+		sync.Mutex
+		classes  [5]int        // requests per status class (1xx – 5xx)
+		latency  time.Duration // total of the measured durations
+		measured int           // number of requests with a duration
+		requests int           // total number of requests
+		since    time.Time     // start of the current period
+		stop     chan struct{} // stops the periodic summary
+	}
+)
+
+// `countSummary()` counts the access log entry `aEntry` if summaries
+// are enabled.
+//
+// Parameters:
+// - `aEntry`: The (prepared) access log entry.
+func countSummary(aEntry *TEntry) {
+	alSummary.Lock()
+	defer alSummary.Unlock()
+	if nil == alSummary.stop {
+		return
+	}
+
+	alSummary.requests++
+	if class := aEntry.Status / 100; (1 <= class) && (5 >= class) {
+		alSummary.classes[class-1]++
+	}
+	if 0 < aEntry.Duration {
+		alSummary.latency += aEntry.Duration
+		alSummary.measured++
+	}
+} // countSummary()
+
+// `summaryLine()` returns the summary of the current period and starts
+// the next one.
+//
+// Parameters:
+// - `aNow`: The end of the current period.
+//
+// Returns:
+// - `string`: The summary as a single line of `key=value` pairs.
+func summaryLine(aNow time.Time) string {
+	alSummary.Lock()
+	defer alSummary.Unlock()
+
+	var average time.Duration
+	if 0 < alSummary.measured {
+		average = alSummary.latency / time.Duration(alSummary.measured)
+	}
+	result := fmt.Sprintf("requests=%d 1xx=%d 2xx=%d 3xx=%d 4xx=%d 5xx=%d avg_latency=%s since=%s",
+		alSummary.requests,
+		alSummary.classes[0], alSummary.classes[1], alSummary.classes[2],
+		alSummary.classes[3], alSummary.classes[4],
+		average.Round(time.Microsecond),
+		alSummary.since.Format(time.RFC3339))
+
+	alSummary.classes = [5]int{}
+	alSummary.latency, alSummary.measured, alSummary.requests = 0, 0, 0
+	alSummary.since = aNow
+
+	return result
+} // summaryLine()
+
+// `SetSummaryLog()` starts (or stops) writing a periodic one-line
+// summary of the requests served, e.g.
+//
+//	requests=1234 1xx=0 2xx=1180 3xx=32 4xx=20 5xx=2 avg_latency=3.41ms since=2024-05-01T12:00:00Z
+//
+// with the number of requests per status class and their average
+// latency since the previous summary. This gives basic rate reporting
+// for deployments without any metrics stack. The latency is averaged
+// over the requests whose duration was measured.
+//
+// Parameters:
+// - `aInterval`: The time between two summary entries (`0` stops them).
+// - `aErrorLog`: Whether to write the summaries to the error log
+// (instead of the access log).
+func SetSummaryLog(aInterval time.Duration, aErrorLog bool) {
+	alSummary.Lock()
+	defer alSummary.Unlock()
+
+	if nil != alSummary.stop {
+		close(alSummary.stop)
+		alSummary.stop = nil
+	}
+	alSummary.classes = [5]int{}
+	alSummary.latency, alSummary.measured, alSummary.requests = 0, 0, 0
+	if 0 >= aInterval {
+		return
+	}
+	alSummary.since = time.Now()
+
+	stop := make(chan struct{})
+	alSummary.stop = stop
+	go goSummaryLog(aInterval, aErrorLog, stop)
+} // SetSummaryLog()
+
+// `goSummaryLog()` periodically writes the request summary.
+//
+// Parameters:
+// - `aInterval`: The time between two summary entries.
+// - `aErrorLog`: Whether to write to the error log.
+// - `aStop`: Closed to stop writing summaries.
+func goSummaryLog(aInterval time.Duration, aErrorLog bool, aStop <-chan struct{}) {
+	ticker := time.NewTicker(aInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-aStop:
+			return
+		case now := <-ticker.C:
+			msg := summaryLine(now)
+			if !aErrorLog {
+				Log("ApacheLogger/Summary", msg)
+				continue
+			}
+			spawnCustom(func(aNow time.Time) {
+				goCustomLog("ApacheLogger/Summary", msg, `LOG`, aNow, errorQueue())
+			})
+		}
+	}
+} // goSummaryLog()
+
+/* _EoF_ */
@@ -0,0 +1,47 @@
+/*
+Copyright © 2024  M.Watermann, 10247 Berlin, Germany
+
+	    All rights reserved
+	EMail : <support@mwat.de>
+*/
+package apachelogger
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+//lint:file-ignore ST1017 – I prefer Yoda conditions
+
+func TestSetSummaryLog(t *testing.T) {
+	defer SetSummaryLog(0, false)
+
+	e := prepEntry()
+	countSummary(e) // summaries not active
+	SetSummaryLog(time.Hour, false)
+	if got := summaryLine(time.Now()); !strings.HasPrefix(got, "requests=0 ") {
+		t.Errorf("summaryLine() = %q, want no requests", got)
+	}
+
+	e.Duration = 2 * time.Millisecond
+	countSummary(e)
+	countSummary(e)
+	e2 := prepEntry()
+	e2.Status, e2.Duration = 404, 5*time.Millisecond
+	countSummary(e2)
+	e3 := prepEntry()
+	e3.Status, e3.Duration = 503, 0 // not measured
+	countSummary(e3)
+
+	got := summaryLine(time.Now())
+	want := "requests=4 1xx=0 2xx=2 3xx=0 4xx=1 5xx=1 avg_latency=3ms since="
+	if !strings.HasPrefix(got, want) {
+		t.Errorf("summaryLine() = %q, want prefix %q", got, want)
+	}
+	if got := summaryLine(time.Now()); !strings.HasPrefix(got, "requests=0 ") {
+		t.Errorf("summaryLine() = %q, want the counters reset", got)
+	}
+} // TestSetSummaryLog()
+
+/* _EoF_ */
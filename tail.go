@@ -0,0 +1,205 @@
+/*
+Copyright © 2024  M.Watermann, 10247 Berlin, Germany
+
+	    All rights reserved
+	EMail : <support@mwat.de>
+*/
+package apachelogger
+
+import (
+	"net/http"
+	"strings"
+	"sync"
+	"sync/atomic"
+)
+
+//lint:file-ignore ST1017 – I prefer Yoda conditions
+
+const (
+	// Number of lines buffered per live-tail client.
+	alTailBuffer = 256
+
+	// The page shown to browsers opening the live-tail handler.
+	alTailPage = `<!DOCTYPE html>
+<html><head><meta charset="utf-8"><title>access log</title>
+<style>body{margin:0;background:#111;color:#ddd}pre{margin:0;padding:1ex;font-size:small;white-space:pre-wrap}</style>
+</head><body><pre id="log"></pre><script>
+const log = document.getElementById("log");
+const src = new EventSource(location.href);
+src.onmessage = (ev) => {
+	log.append(ev.data + "\n");
+	while (2000 < log.childNodes.length) { log.removeChild(log.firstChild); }
+	window.scrollTo(0, document.body.scrollHeight);
+};
+</script></body></html>
+`
+)
+
+var (
+	// Channels of the connected live-tail clients.
+	alTailClients = make(map[chan string]struct{})
+
+	// Guard for concurrent access to `alTailClients`.
+	alTailClientsMtx sync.RWMutex
+
+	// Number of connected live-tail clients (accessed atomically).
+	alTailCount int32
+)
+
+// `publishTail()` sends `aLine` to all connected live-tail clients.
+//
+// Clients not keeping up lose lines instead of slowing down the
+// requests.
+//
+// Parameters:
+// - `aEntry`: The access log entry.
+// - `aLine`: The formatted access log entry.
+func publishTail(aEntry *TEntry, aLine string) {
+	if 0 == atomic.LoadInt32(&alTailCount) {
+		return
+	}
+	if BinaryLog { // send text lines only
+		aLine = tailLine(aEntry)
+	}
+	alTailClientsMtx.RLock()
+	defer alTailClientsMtx.RUnlock()
+
+	for client := range alTailClients {
+		select {
+		case client <- aLine:
+		default: // the client is too slow
+		}
+	}
+} // publishTail()
+
+// `sendTail()` writes `aLine` as a Server-Sent Event to `aWriter`.
+//
+// Parameters:
+// - `aWriter`: The response writer of a live-tail client.
+// - `aLine`: The formatted access log entry.
+//
+// Returns:
+// - `error`: A possible error while writing.
+func sendTail(aWriter http.ResponseWriter, aLine string) error {
+	aLine = strings.TrimRight(aLine, "\n")
+	_, err := aWriter.Write([]byte("data: " +
+		strings.Replace(aLine, "\n", "\ndata: ", -1) + "\n\n"))
+
+	return err
+} // sendTail()
+
+// `subscribeTail()` registers a new live-tail client.
+//
+// Returns:
+// - `chan string`: The channel receiving the access log lines.
+func subscribeTail() chan string {
+	result := make(chan string, alTailBuffer)
+	alTailClientsMtx.Lock()
+	alTailClients[result] = struct{}{}
+	atomic.StoreInt32(&alTailCount, int32(len(alTailClients)))
+	alTailClientsMtx.Unlock()
+
+	return result
+} // subscribeTail()
+
+// `tailLine()` returns `aEntry` formatted for the live-tail clients.
+//
+// Parameters:
+// - `aEntry`: The access log entry.
+//
+// Returns:
+// - `string`: The entry formatted as a text line.
+func tailLine(aEntry *TEntry) string {
+	if BinaryLog {
+		return aEntry.String()
+	}
+
+	return formatEntry(aEntry)
+} // tailLine()
+
+// `unsubscribeTail()` removes the live-tail client `aClient`.
+//
+// Parameters:
+// - `aClient`: The channel returned by `subscribeTail()`.
+func unsubscribeTail(aClient chan string) {
+	alTailClientsMtx.Lock()
+	delete(alTailClients, aClient)
+	atomic.StoreInt32(&alTailCount, int32(len(alTailClients)))
+	alTailClientsMtx.Unlock()
+} // unsubscribeTail()
+
+/* * * * * * * * * * * * * * * * * * * * * * * * * * * * * * * * * * * * * */
+
+// `TailHandler()` returns a handler streaming the access log entries
+// to the client as they are logged – a built-in `tail -f` for operators
+// who can't access the logfiles.
+//
+// Requests accepting `text/event-stream` get the entries (formatted as
+// written to the logfile, or as text lines with `BinaryLog` set) as
+// Server-Sent Events; other requests (e.g. a browser opening the URL)
+// get a small page displaying that stream.
+// A new client gets the entries kept by `SetRecentSize()` first, then
+// those logged while it's connected; a client not keeping up loses
+// entries rather than slowing down the server.
+//
+// Since the entries may contain personal data `aAuthorise` should check
+// the request's credentials; it returns `true` to allow the request,
+// otherwise the response's status is `403`. A `nil` function allows
+// all requests, so use it only with a handler protected otherwise.
+//
+// Parameters:
+// - `aAuthorise`: The function deciding whether a request may tail.
+//
+// Returns:
+// - `http.Handler`: The handler to register with your router.
+func TailHandler(aAuthorise func(aRequest *http.Request) bool) http.Handler {
+	return http.HandlerFunc(
+		func(aWriter http.ResponseWriter, aRequest *http.Request) {
+			if (nil != aAuthorise) && !aAuthorise(aRequest) {
+				http.Error(aWriter, http.StatusText(http.StatusForbidden),
+					http.StatusForbidden)
+				return
+			}
+			header := aWriter.Header()
+			header.Set("Cache-Control", "no-store")
+			if !strings.Contains(aRequest.Header.Get("Accept"), "text/event-stream") {
+				header.Set("Content-Type", "text/html; charset=utf-8")
+				_, _ = aWriter.Write([]byte(alTailPage))
+				return
+			}
+			flusher, ok := aWriter.(http.Flusher)
+			if !ok {
+				http.Error(aWriter, "streaming not supported",
+					http.StatusInternalServerError)
+				return
+			}
+
+			client := subscribeTail()
+			defer unsubscribeTail(client)
+
+			header.Set("Content-Type", "text/event-stream")
+			header.Set("X-Accel-Buffering", "no") // disable proxy buffering
+			aWriter.WriteHeader(http.StatusOK)
+			for _, entry := range alRecent.query(TRecentFilter{}) {
+				if nil != sendTail(aWriter, tailLine(entry)) {
+					return
+				}
+			}
+			flusher.Flush()
+
+			for {
+				select {
+				case <-aRequest.Context().Done():
+					return
+
+				case line := <-client:
+					if nil != sendTail(aWriter, line) {
+						return
+					}
+					flusher.Flush()
+				}
+			}
+		})
+} // TailHandler()
+
+/* _EoF_ */
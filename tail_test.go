@@ -0,0 +1,63 @@
+/*
+Copyright © 2024  M.Watermann, 10247 Berlin, Germany
+
+	    All rights reserved
+	EMail : <support@mwat.de>
+*/
+package apachelogger
+
+import (
+	"bufio"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+//lint:file-ignore ST1017 – I prefer Yoda conditions
+
+func TestTailHandler(t *testing.T) {
+	handler := TailHandler(func(aRequest *http.Request) bool {
+		return "secret" == aRequest.URL.Query().Get("token")
+	})
+	server := httptest.NewServer(handler)
+	defer server.Close()
+
+	resp, err := http.Get(server.URL)
+	if nil != err {
+		t.Fatalf("http.Get() error = %v", err)
+	}
+	resp.Body.Close()
+	if http.StatusForbidden != resp.StatusCode {
+		t.Errorf("TailHandler() status = %d, want %d", resp.StatusCode, http.StatusForbidden)
+	}
+
+	resp, err = http.Get(server.URL + "?token=secret")
+	if nil != err {
+		t.Fatalf("http.Get() error = %v", err)
+	}
+	resp.Body.Close()
+	if ct := resp.Header.Get("Content-Type"); !strings.HasPrefix(ct, "text/html") {
+		t.Errorf("TailHandler() Content-Type = %q, want text/html", ct)
+	}
+
+	req, _ := http.NewRequest("GET", server.URL+"?token=secret", nil)
+	req.Header.Set("Accept", "text/event-stream")
+	resp, err = http.DefaultClient.Do(req)
+	if nil != err {
+		t.Fatalf("http.Do() error = %v", err)
+	}
+	defer resp.Body.Close()
+
+	e1 := prepEntry()
+	publishTail(e1, "line 1\n") // the client is subscribed before the headers are sent
+	got, err := bufio.NewReader(resp.Body).ReadString('\n')
+	if nil != err {
+		t.Fatalf("ReadString() error = %v", err)
+	}
+	if want := "data: line 1\n"; want != got {
+		t.Errorf("TailHandler() sent %q, want %q", got, want)
+	}
+} // TestTailHandler()
+
+/* _EoF_ */
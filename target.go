@@ -0,0 +1,66 @@
+/*
+Copyright © 2024  M.Watermann, 10247 Berlin, Germany
+
+	    All rights reserved
+	EMail : <support@mwat.de>
+*/
+package apachelogger
+
+import (
+	"sync/atomic"
+)
+
+//lint:file-ignore ST1017 – I prefer Yoda conditions
+
+var (
+	// `1` while access logging is paused (accessed atomically).
+	alAccessPaused int32
+)
+
+// `accessPaused()` reports whether access logging is paused.
+//
+// Returns:
+// - `bool`: `true` between `Pause()` and `Resume()`.
+func accessPaused() bool {
+	return 1 == atomic.LoadInt32(&alAccessPaused)
+} // accessPaused()
+
+// `Pause()` silences access logging until `Resume()` is called.
+//
+// Entries already queued are still written while new requests and
+// `Log()` messages are not logged. Error logging and other features
+// (e.g. traffic statistics or alerts) are not affected.
+func Pause() {
+	atomic.StoreInt32(&alAccessPaused, 1)
+} // Pause()
+
+// `Resume()` resumes access logging paused by `Pause()`.
+func Resume() {
+	atomic.StoreInt32(&alAccessPaused, 0)
+} // Resume()
+
+// `SetAccessFile()` switches the access log to the file `aFilename`.
+//
+// This is a shortcut for `SetAccessTarget(NewFileSink(aFilename))`.
+//
+// Parameters:
+// - `aFilename`: The name of the new access logfile.
+func SetAccessFile(aFilename string) {
+	SetAccessTarget(NewFileSink(aFilename))
+} // SetAccessFile()
+
+// `SetAccessTarget()` switches the access log to `aSink` at runtime,
+// e.g. to move it to another disk.
+//
+// The current sink is closed, and all entries not yet written go to
+// the new sink, so no entry is lost. A `nil` sink (or `Discard`)
+// discards the access log messages. If the error log uses the same
+// sink as the access log (see `WrapSinks()`) it's switched as well.
+//
+// Parameters:
+// - `aSink`: The sink to use for access log messages from now on.
+func SetAccessTarget(aSink TSink) {
+	accessQueue().retarget(aSink)
+} // SetAccessTarget()
+
+/* _EoF_ */
@@ -0,0 +1,83 @@
+/*
+Copyright © 2024  M.Watermann, 10247 Berlin, Germany
+
+	    All rights reserved
+	EMail : <support@mwat.de>
+*/
+package apachelogger
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+//lint:file-ignore ST1017 – I prefer Yoda conditions
+
+func TestPause(t *testing.T) {
+	defer Resume()
+
+	Pause()
+	if !accessPaused() || !Stats().Paused {
+		t.Error("Pause() didn't pause access logging")
+	}
+	Resume()
+	if accessPaused() || Stats().Paused {
+		t.Error("Resume() didn't resume access logging")
+	}
+} // TestPause()
+
+func Test_goDoLogWrite_retarget(t *testing.T) {
+	queue := newRing(8)
+	queue.push("msg 1\n")
+	queue.push("msg 2\n")
+	sink2 := &tMemSink{}
+	queue.retarget(sink2)
+	queue.close()
+
+	sink1 := &tMemSink{}
+	goDoLogWrite(sink1, queue)
+
+	if 0 != len(sink1.data) {
+		t.Errorf("old sink got %q, want nothing", sink1.data)
+	}
+	if 0 == sink1.closed {
+		t.Error("goDoLogWrite() didn't close the old sink")
+	}
+	if want := "msg 1\nmsg 2\n"; want != string(sink2.data) {
+		t.Errorf("new sink got %q, want %q", sink2.data, want)
+	}
+} // Test_goDoLogWrite_retarget()
+
+func Test_goDoLogWrite_discard(t *testing.T) {
+	queue := newRing(8)
+	queue.push("dropped\n")
+	queue.retarget(nil)
+	go goDoLogWrite(&tMemSink{}, queue)
+
+	deadline := time.Now().Add(time.Second)
+	for 0 < queue.length() {
+		if time.Now().After(deadline) {
+			t.Fatal("the queue wasn't emptied")
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	fName := filepath.Join(t.TempDir(), "access.log")
+	queue.retarget(NewFileSink(fName))
+	queue.push("kept\n")
+	queue.close()
+
+	for {
+		if got, _ := os.ReadFile(fName); /* #nosec G304 */ "kept\n" == string(got) {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("the new sink didn't get the message")
+		}
+		time.Sleep(time.Millisecond)
+	}
+} // Test_goDoLogWrite_discard()
+
+/* _EoF_ */
@@ -0,0 +1,100 @@
+/*
+Copyright © 2024  M.Watermann, 10247 Berlin, Germany
+
+	    All rights reserved
+	EMail : <support@mwat.de>
+*/
+package apachelogger
+
+import (
+	"sync/atomic"
+	"time"
+)
+
+//lint:file-ignore ST1017 – I prefer Yoda conditions
+
+const (
+	// `TimestampBegin` logs the time the request was received
+	// (default).
+	TimestampBegin = iota
+
+	// `TimestampEnd` logs the time the response was finished.
+	TimestampEnd
+)
+
+var (
+	// `Timestamp` decides which time of a request is logged: when it
+	// was received (`TimestampBegin`, default, like Apache) or when the
+	// response was finished (`TimestampEnd`), as assumed by tools
+	// calculating request rates from the logfile.
+	//
+	// It applies to the built-in formats and to the `%t` directive of
+	// `SetLogFormat()`; the `begin:` and `end:` prefixes of `%{…}t`
+	// override it. The entry's `When` always holds the time the
+	// request was received.
+	Timestamp = TimestampBegin
+
+	// The process-wide time zone, a `*time.Location` (see
+	// `SetTimeLocation()`).
+	alTimeLocation atomic.Value
+)
+
+// `inLocation()` returns `aTime` in the process-wide time zone set by
+// `SetTimeLocation()`.
+//
+// Parameters:
+// - `aTime`: The time to convert.
+//
+// Returns:
+// - `time.Time`: The (possibly) converted time.
+func inLocation(aTime time.Time) time.Time {
+	if location, _ := alTimeLocation.Load().(*time.Location); nil != location {
+		return aTime.In(location)
+	}
+
+	return aTime
+} // inLocation()
+
+// `inLocation()` returns `aTime` in the time zone of the entry's log
+// (see `WithLocation()`) or else the process-wide one.
+//
+// Parameters:
+// - `aTime`: The time to convert.
+//
+// Returns:
+// - `time.Time`: The (possibly) converted time.
+func (e *TEntry) inLocation(aTime time.Time) time.Time {
+	return e.settings.in(aTime)
+} // inLocation()
+
+// `timestamp()` returns the entry's time to log according to
+// `Timestamp` and the entry's time zone.
+//
+// Returns:
+// - `time.Time`: The time the request was received or finished.
+func (e *TEntry) timestamp() time.Time {
+	if TimestampEnd == Timestamp {
+		return e.inLocation(e.When.Add(e.Duration))
+	}
+
+	return e.inLocation(e.When)
+} // timestamp()
+
+/* * * * * * * * * * * * * * * * * * * * * * * * * * * * * * * * * * * * * */
+
+// `SetTimeLocation()` sets the process-wide time zone of the logged
+// timestamps and of the day boundaries (see `DayChange`) independent
+// of the process' time zone, e.g. `time.LoadLocation("Europe/Berlin")`
+// for a container running in UTC.
+//
+// It applies to all logs without a time zone of their own (see
+// `WithLocation()`). The W3C format always uses UTC as its
+// specification demands.
+//
+// Parameters:
+// - `aLocation`: The time zone to use (`nil` for `time.Local`).
+func SetTimeLocation(aLocation *time.Location) {
+	alTimeLocation.Store(aLocation)
+} // SetTimeLocation()
+
+/* _EoF_ */
@@ -0,0 +1,74 @@
+/*
+Copyright © 2024  M.Watermann, 10247 Berlin, Germany
+
+	    All rights reserved
+	EMail : <support@mwat.de>
+*/
+package apachelogger
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+//lint:file-ignore ST1017 – I prefer Yoda conditions
+
+func TestTEntry_timestamp(t *testing.T) {
+	defer func() { Timestamp = TimestampBegin }()
+	e1 := prepEntry()
+	e1.Duration = 90 * time.Second
+
+	if got := e1.timestamp(); !got.Equal(e1.When) {
+		t.Errorf("timestamp() = %v, want %v", got, e1.When)
+	}
+	Timestamp = TimestampEnd
+	if got, want := e1.timestamp(), e1.When.Add(90*time.Second); !got.Equal(want) {
+		t.Errorf("timestamp() = %v, want %v", got, want)
+	}
+	if got := e1.String(); !strings.Contains(got, "[25/Apr/2024:20:18:15 +0200]") {
+		t.Errorf("String() = %q, want the completion time", got)
+	}
+	if got := e1.Canonical(); !strings.Contains(got, "time=2024-04-25T20:18:15+02:00") {
+		t.Errorf("Canonical() = %q, want the completion time", got)
+	}
+} // TestTEntry_timestamp()
+
+func TestSetTimeLocation(t *testing.T) {
+	defer SetTimeLocation(nil)
+	SetTimeLocation(time.FixedZone("UTC-5", -5*3600))
+	e1 := prepEntry() // 20:16:45 +0200
+
+	if got := e1.String(); !strings.Contains(got, "[25/Apr/2024:13:16:45 -0500]") {
+		t.Errorf("String() = %q, want the time in the process-wide zone", got)
+	}
+	if got := string(appendTimeFormat(nil, e1, "begin:%H")); "13" != got {
+		t.Errorf("appendTimeFormat() = %q, want %q", got, "13")
+	}
+
+	// the log's own time zone takes precedence:
+	e1.settings = &tLogSettings{location: time.FixedZone("UTC+3", 3*3600)}
+	if got := e1.String(); !strings.Contains(got, "[25/Apr/2024:21:16:45 +0300]") {
+		t.Errorf("String() = %q, want the time in the log's zone", got)
+	}
+
+	SetTimeLocation(time.FixedZone("UTC+2", 2*3600))
+	last := time.Now().Add(-24 * time.Hour)
+	if !compareDayStamps(&last, nil) {
+		t.Error("compareDayStamps() = false, want true for yesterday")
+	}
+	// 23:30 UTC is the next day in UTC+2:
+	day := time.Date(2024, 4, 25, 23, 30, 0, 0, time.UTC)
+	if got := dayMarker(day, nil); !strings.Contains(got, `"DAY 2024-04-26 `) {
+		t.Errorf("dayMarker() = %q, want the day in UTC+2", got)
+	}
+	settings := &tLogSettings{location: time.UTC}
+	if got := dayMarker(day, settings); !strings.Contains(got, `"DAY 2024-04-25 `) {
+		t.Errorf("dayMarker() = %q, want the day in the log's zone", got)
+	}
+	if got, want := rotatedName("access.log", settings.in(day)), "access.log.2024-04-25"; want != got {
+		t.Errorf("rotatedName() = %q, want %q", got, want)
+	}
+} // TestSetTimeLocation()
+
+/* _EoF_ */
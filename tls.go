@@ -0,0 +1,216 @@
+/*
+Copyright © 2024  M.Watermann, 10247 Berlin, Germany
+
+	    All rights reserved
+	EMail : <support@mwat.de>
+*/
+package apachelogger
+
+import (
+	"crypto/tls"
+	"fmt"
+	"net/http"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+)
+
+//lint:file-ignore ST1017 – I prefer Yoda conditions
+
+type (
+	// `tTLSHello` holds the data offered by a client in its TLS
+	// ClientHello message.
+	tTLSHello struct {
+		serverName string    // the requested server name (SNI)
+		versions   []uint16  // the TLS versions offered by the client
+		when       time.Time // time the ClientHello was received
+	}
+)
+
+const (
+	// Max. number of ClientHello messages to remember.
+	alTLSHelloMax = 1024
+
+	// Time to remember a ClientHello message.
+	alTLSHelloTTL = time.Minute
+)
+
+var (
+	// ClientHello data of recent TLS connections, by remote address.
+	alTLSHellos = make(map[string]tTLSHello)
+
+	// Guard for concurrent access to `alTLSHellos`.
+	alTLSHellosMtx sync.Mutex
+
+	// RegEx to match the server's TLS handshake error messages:
+	alTLSErrorRE = regexp.MustCompile(`TLS handshake error from (\S+): (.*)`)
+
+	// `LogALPN` decides whether to add the application protocol
+	// negotiated by ALPN on TLS connections (e.g. `h2` or `http/1.1`)
+	// as the field `alpn` to the access log entries (default: `false`),
+	// so the protocol adoption can be tracked per client population.
+	// Connections without ALPN don't get the field.
+	LogALPN = false
+)
+
+// `addALPN()` adds the field `alpn` to `aEntry` if `LogALPN` is set.
+//
+// Parameters:
+// - `aEntry`: The log entry to extend.
+// - `aRequest`: The request whose connection state to use.
+func addALPN(aEntry *TEntry, aRequest *http.Request) {
+	if LogALPN && (nil != aRequest.TLS) && ("" != aRequest.TLS.NegotiatedProtocol) {
+		aEntry.SetField("alpn", aRequest.TLS.NegotiatedProtocol)
+	}
+} // addALPN()
+
+// `forgetTLSHello()` removes the ClientHello data of `aRemoteAddr`.
+//
+// Parameters:
+// - `aRemoteAddr`: The remote address of the TLS connection.
+//
+// Returns:
+// - `tTLSHello`: The data removed.
+// - `bool`: `true` if there was ClientHello data for `aRemoteAddr`.
+func forgetTLSHello(aRemoteAddr string) (tTLSHello, bool) {
+	alTLSHellosMtx.Lock()
+	defer alTLSHellosMtx.Unlock()
+
+	hello, ok := alTLSHellos[aRemoteAddr]
+	if ok {
+		delete(alTLSHellos, aRemoteAddr)
+	}
+
+	return hello, ok
+} // forgetTLSHello()
+
+// `rememberTLSHello()` stores the ClientHello data of a TLS connection.
+//
+// Parameters:
+// - `aHello`: The client's ClientHello message.
+func rememberTLSHello(aHello *tls.ClientHelloInfo) {
+	if (nil == aHello) || (nil == aHello.Conn) {
+		return
+	}
+	now := time.Now()
+
+	alTLSHellosMtx.Lock()
+	defer alTLSHellosMtx.Unlock()
+
+	if alTLSHelloMax <= len(alTLSHellos) {
+		for addr, hello := range alTLSHellos {
+			if alTLSHelloTTL < now.Sub(hello.when) {
+				delete(alTLSHellos, addr)
+			}
+		}
+		if alTLSHelloMax <= len(alTLSHellos) {
+			return // don't let an attacker exhaust our memory
+		}
+	}
+	alTLSHellos[aHello.Conn.RemoteAddr().String()] = tTLSHello{
+		serverName: aHello.ServerName,
+		versions:   append([]uint16{}, aHello.SupportedVersions...),
+		when:       now,
+	}
+} // rememberTLSHello()
+
+// `rewriteTLSError()` replaces the remote address in a server's TLS
+// handshake error message by the anonymised address, adding the data
+// of the client's ClientHello (if available).
+//
+// Parameters:
+// - `aMessage`: The error message written by the server.
+//
+// Returns:
+// - `string`: The (possibly) rewritten error message.
+func rewriteTLSError(aMessage string) string {
+	idx := alTLSErrorRE.FindStringSubmatchIndex(aMessage)
+	if nil == idx {
+		return aMessage
+	}
+	addr := aMessage[idx[2]:idx[3]]
+	details := ""
+	if hello, ok := forgetTLSHello(addr); ok {
+		serverName := hello.serverName
+		if "" == serverName {
+			serverName = "-"
+		}
+		details = fmt.Sprintf(" (sni=%s, versions=%s)",
+			serverName, tlsVersionNames(hello.versions))
+	}
+
+	return aMessage[:idx[0]] +
+		"TLS handshake error from " + anonymiseAddress(addr, 0) +
+		details + ": " + aMessage[idx[4]:idx[5]] + aMessage[idx[1]:]
+} // rewriteTLSError()
+
+// `tlsVersionNames()` returns the names of the given TLS versions.
+//
+// Parameters:
+// - `aVersions`: The TLS version numbers.
+//
+// Returns:
+// - `string`: The comma-separated list of version names.
+func tlsVersionNames(aVersions []uint16) string {
+	if 0 == len(aVersions) {
+		return "-"
+	}
+	names := make([]string, 0, len(aVersions))
+	for _, version := range aVersions {
+		switch version {
+		case tls.VersionTLS10:
+			names = append(names, "TLS1.0")
+		case tls.VersionTLS11:
+			names = append(names, "TLS1.1")
+		case tls.VersionTLS12:
+			names = append(names, "TLS1.2")
+		case tls.VersionTLS13:
+			names = append(names, "TLS1.3")
+		default:
+			names = append(names, fmt.Sprintf("0x%04x", version))
+		}
+	}
+
+	return strings.Join(names, ",")
+} // tlsVersionNames()
+
+/* * * * * * * * * * * * * * * * * * * * * * * * * * * * * * * * * * * * * */
+
+// `SetTLSLog()` arranges for failed TLS handshakes of `aServer` to be
+// written to the error log.
+//
+// Such failures never reach the HTTP handler, so they can't be seen by
+// `Wrap()`. This function installs a `GetConfigForClient` callback in
+// the server's `TLSConfig` remembering the data offered by the clients
+// (server name and TLS versions), and – if not done already – sets the
+// server's error logger (see `SetErrorLog()`). The server's handshake
+// error messages are then logged with the client's address anonymised
+// and the data of the client's ClientHello added.
+//
+// The function must be called before the server is started.
+//
+// Parameters:
+// - `aServer`: The server instance whose TLS failures are to be logged.
+func SetTLSLog(aServer *http.Server) {
+	if nil == aServer.TLSConfig {
+		aServer.TLSConfig = &tls.Config{} // #nosec G402
+	}
+	if nil == aServer.ErrorLog {
+		SetErrorLog(aServer)
+	} else if _, ok := aServer.ErrorLog.Writer().(tLogLog); !ok {
+		SetErrorLog(aServer)
+	}
+
+	getConfig := aServer.TLSConfig.GetConfigForClient
+	aServer.TLSConfig.GetConfigForClient = func(aHello *tls.ClientHelloInfo) (*tls.Config, error) {
+		rememberTLSHello(aHello)
+		if nil != getConfig {
+			return getConfig(aHello)
+		}
+
+		return nil, nil
+	}
+} // SetTLSLog()
+
+/* _EoF_ */
@@ -0,0 +1,106 @@
+/*
+Copyright © 2024  M.Watermann, 10247 Berlin, Germany
+
+	    All rights reserved
+	EMail : <support@mwat.de>
+*/
+package apachelogger
+
+import (
+	"crypto/tls"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+//lint:file-ignore ST1017 – I prefer Yoda conditions
+
+func Test_addALPN(t *testing.T) {
+	defer func() { LogALPN = false }()
+	var got string
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(aWriter http.ResponseWriter, aRequest *http.Request) {
+		e := prepEntry()
+		addALPN(e, aRequest)
+		got = e.Fields["alpn"]
+	}))
+	server.EnableHTTP2 = true
+	server.StartTLS()
+	defer server.Close()
+
+	get := func() {
+		resp, err := server.Client().Get(server.URL)
+		if nil != err {
+			t.Fatalf("Get() error = %v", err)
+		}
+		_ = resp.Body.Close()
+	}
+	get()
+	if "" != got {
+		t.Errorf("addALPN() = %q, want no field while disabled", got)
+	}
+	LogALPN = true
+	get()
+	if "h2" != got {
+		t.Errorf("addALPN() = %q, want %q", got, "h2")
+	}
+
+	e := prepEntry()
+	addALPN(e, httptest.NewRequest(http.MethodGet, "/", nil)) // no TLS
+	if _, ok := e.Fields["alpn"]; ok {
+		t.Errorf("addALPN() fields = %v, want none without TLS", e.Fields)
+	}
+} // Test_addALPN()
+
+func Test_rewriteTLSError(t *testing.T) {
+	c1, c2 := net.Pipe()
+	defer c1.Close()
+	defer c2.Close()
+	rememberTLSHello(&tls.ClientHelloInfo{
+		ServerName:        "example.com",
+		SupportedVersions: []uint16{tls.VersionTLS13, tls.VersionTLS12},
+		Conn:              c1, // RemoteAddr() == "pipe"
+	})
+
+	m1 := "server.go:3195: http: TLS handshake error from 192.168.1.234:54321: EOF"
+	w1 := "server.go:3195: http: TLS handshake error from 192.168.1.0: EOF"
+	m2 := "http: TLS handshake error from pipe: tls: client offered only unsupported versions"
+	w2 := "http: TLS handshake error from pipe (sni=example.com, versions=TLS1.3,TLS1.2): tls: client offered only unsupported versions"
+	m3 := "http: some other error"
+
+	tests := []struct {
+		name string
+		msg  string
+		want string
+	}{
+		{" 1", m1, w1},
+		{" 2", m2, w2},
+		{" 3", m3, m3},
+		// TODO: Add test cases.
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := rewriteTLSError(tt.msg); got != tt.want {
+				t.Errorf("%q: rewriteTLSError() = %q,\nwant %q",
+					tt.name, got, tt.want)
+			}
+		})
+	}
+} // Test_rewriteTLSError()
+
+func TestSetTLSLog(t *testing.T) {
+	server := &http.Server{}
+	SetTLSLog(server)
+
+	if nil == server.ErrorLog {
+		t.Fatal("SetTLSLog() didn't set the error logger")
+	}
+	if _, ok := server.ErrorLog.Writer().(tLogLog); !ok {
+		t.Errorf("SetTLSLog() ErrorLog writer = %T", server.ErrorLog.Writer())
+	}
+	if (nil == server.TLSConfig) || (nil == server.TLSConfig.GetConfigForClient) {
+		t.Fatal("SetTLSLog() didn't install GetConfigForClient")
+	}
+} // TestSetTLSLog()
+
+/* _EoF_ */
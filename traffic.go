@@ -0,0 +1,296 @@
+/*
+Copyright © 2024  M.Watermann, 10247 Berlin, Germany
+
+	    All rights reserved
+	EMail : <support@mwat.de>
+*/
+package apachelogger
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+//lint:file-ignore ST1017 – I prefer Yoda conditions
+
+type (
+	// `TCount` is the number of requests of a single client, status,
+	// or path.
+	TCount struct {
+		Key   string // the client address, status, or path
+		Count int    // the number of requests
+	}
+
+	// `TTrafficReport` summarises the requests within the tracking
+	// window (see `SetTrafficTracking()`).
+	TTrafficReport struct {
+		Since    time.Time // start of the reported time span
+		Requests int       // total number of requests
+		Clients  []TCount  // the most active (anonymised) clients
+		Statuses []TCount  // the most frequent response statuses
+		Paths    []TCount  // the most requested paths (without query)
+		Agents   []TCount  // the most frequent user agents
+	}
+
+	// `tTrafficBucket` holds the request counts of a time slice.
+	tTrafficBucket struct {
+		start    time.Time      // start of the time slice
+		requests int            // total number of requests
+		clients  map[string]int // requests per client
+		statuses map[string]int // requests per status
+		paths    map[string]int // requests per path
+		agents   map[string]int // requests per user agent
+	}
+)
+
+const (
+	// Number of time slices of the tracking window.
+	alTrafficBuckets = 60
+
+	// Max. number of distinct keys per time slice and counter.
+	alTrafficMaxKeys = 4096
+
+	// Key used for all values exceeding `alTrafficMaxKeys`.
+	alTrafficOther = "(other)"
+)
+
+var (
+	// The request counts of the current tracking window.
+	alTraffic struct {
+		sync.Mutex
+		buckets []*tTrafficBucket // time slices, oldest first
+		span    time.Duration     // length of a time slice
+		stop    chan struct{}     // stops the periodic summary
+		window  time.Duration     // length of the tracking window
+	}
+)
+
+/* * * * * * * * * * * * * * * * * * * * * * * * * * * * * * * * * * * * * */
+
+// `countKey()` increments the count of `aKey` in `aMap`.
+//
+// Parameters:
+// - `aMap`: The counters to update.
+// - `aKey`: The key to count.
+func countKey(aMap map[string]int, aKey string) {
+	if _, ok := aMap[aKey]; !ok && (alTrafficMaxKeys <= len(aMap)) {
+		aKey = alTrafficOther
+	}
+	aMap[aKey]++
+} // countKey()
+
+// `countTraffic()` counts the access log entry `aEntry` if traffic
+// tracking is active.
+//
+// Parameters:
+// - `aEntry`: The (prepared) access log entry.
+func countTraffic(aEntry *TEntry) {
+	alTraffic.Lock()
+	defer alTraffic.Unlock()
+	if 0 == alTraffic.window {
+		return
+	}
+
+	now := time.Now()
+	pruneTraffic(now)
+	var bucket *tTrafficBucket
+	if last := len(alTraffic.buckets) - 1; 0 <= last &&
+		(alTraffic.span > now.Sub(alTraffic.buckets[last].start)) {
+		bucket = alTraffic.buckets[last]
+	} else {
+		bucket = &tTrafficBucket{
+			start:    now,
+			clients:  make(map[string]int),
+			statuses: make(map[string]int),
+			paths:    make(map[string]int),
+			agents:   make(map[string]int),
+		}
+		alTraffic.buckets = append(alTraffic.buckets, bucket)
+	}
+
+	path := aEntry.Path
+	if idx := strings.IndexByte(path, '?'); 0 <= idx {
+		path = path[:idx]
+	}
+	bucket.requests++
+	countKey(bucket.clients, aEntry.Remote)
+	countKey(bucket.statuses, strconv.Itoa(aEntry.Status))
+	countKey(bucket.paths, path)
+	countKey(bucket.agents, aEntry.Agent)
+} // countTraffic()
+
+// `pruneTraffic()` removes the time slices outside the tracking window.
+//
+// The caller must hold the lock of `alTraffic`.
+//
+// Parameters:
+// - `aNow`: The current time.
+func pruneTraffic(aNow time.Time) {
+	idx := 0
+	for (idx < len(alTraffic.buckets)) &&
+		(alTraffic.window < aNow.Sub(alTraffic.buckets[idx].start)) {
+		idx++
+	}
+	if 0 < idx {
+		alTraffic.buckets = alTraffic.buckets[idx:]
+	}
+} // pruneTraffic()
+
+// `topCounts()` returns the `aTop` greatest counts of `aCounts`.
+//
+// Parameters:
+// - `aCounts`: The counters to sort.
+// - `aTop`: The max. number of counts to return (all if `0`).
+//
+// Returns:
+// - `[]TCount`: The counts in descending order.
+func topCounts(aCounts map[string]int, aTop int) []TCount {
+	result := make([]TCount, 0, len(aCounts))
+	for key, count := range aCounts {
+		result = append(result, TCount{key, count})
+	}
+	sort.Slice(result, func(i, j int) bool {
+		if result[i].Count != result[j].Count {
+			return result[i].Count > result[j].Count
+		}
+		return result[i].Key < result[j].Key
+	})
+	if (0 < aTop) && (aTop < len(result)) {
+		result = result[:aTop]
+	}
+
+	return result
+} // topCounts()
+
+/* * * * * * * * * * * * * * * * * * * * * * * * * * * * * * * * * * * * * */
+
+// `String()` returns the report as a single line of `key=value` pairs.
+//
+// Returns:
+// - `string`: The report's textual representation.
+func (tr TTrafficReport) String() string {
+	list := func(aCounts []TCount) string {
+		if 0 == len(aCounts) {
+			return "-"
+		}
+		parts := make([]string, len(aCounts))
+		for idx, count := range aCounts {
+			parts[idx] = fmt.Sprintf("%s:%d", count.Key, count.Count)
+		}
+		return strings.Join(parts, ",")
+	}
+
+	return fmt.Sprintf("requests=%d since=%s top_clients=%s top_statuses=%s top_paths=%s",
+		tr.Requests, tr.Since.Format(time.RFC3339), list(tr.Clients),
+		list(tr.Statuses), list(tr.Paths))
+} // String()
+
+// `TrafficReport()` returns the most active clients, the most frequent
+// statuses and user agents, and the most requested paths within the
+// tracking window (see `SetTrafficTracking()`).
+//
+// Parameters:
+// - `aTop`: The max. number of entries per list (all if `0`).
+//
+// Returns:
+// - `TTrafficReport`: The requests' summary.
+func TrafficReport(aTop int) TTrafficReport {
+	clients := make(map[string]int)
+	statuses := make(map[string]int)
+	paths := make(map[string]int)
+	agents := make(map[string]int)
+	now := time.Now()
+	result := TTrafficReport{Since: now}
+
+	alTraffic.Lock()
+	pruneTraffic(now)
+	if 0 < len(alTraffic.buckets) {
+		result.Since = alTraffic.buckets[0].start
+	}
+	for _, bucket := range alTraffic.buckets {
+		result.Requests += bucket.requests
+		for key, count := range bucket.clients {
+			clients[key] += count
+		}
+		for key, count := range bucket.statuses {
+			statuses[key] += count
+		}
+		for key, count := range bucket.paths {
+			paths[key] += count
+		}
+		for key, count := range bucket.agents {
+			agents[key] += count
+		}
+	}
+	alTraffic.Unlock()
+
+	result.Clients = topCounts(clients, aTop)
+	result.Statuses = topCounts(statuses, aTop)
+	result.Paths = topCounts(paths, aTop)
+	result.Agents = topCounts(agents, aTop)
+
+	return result
+} // TrafficReport()
+
+// `SetTrafficTracking()` starts (or stops) counting the requests per
+// client, status, path, and user agent within a sliding time window,
+// giving basic abuse visibility without shipping the logs anywhere.
+//
+// The clients are counted by their address as written to the access
+// log, i.e. anonymised (or hashed) according to the current settings.
+// The counts can be retrieved by `TrafficReport()`; additionally, if
+// `aSummaryInterval` is greater than zero, a summary of the top ten
+// clients, statuses, and paths is written to the access log in that
+// interval.
+//
+// Parameters:
+// - `aWindow`: The length of the sliding window (`0` stops tracking).
+// - `aSummaryInterval`: The time between two summary entries (`0` for none).
+func SetTrafficTracking(aWindow, aSummaryInterval time.Duration) {
+	alTraffic.Lock()
+	defer alTraffic.Unlock()
+
+	if nil != alTraffic.stop {
+		close(alTraffic.stop)
+		alTraffic.stop = nil
+	}
+	alTraffic.buckets = nil
+	if 0 >= aWindow {
+		alTraffic.window = 0
+		return
+	}
+	alTraffic.window = aWindow
+	alTraffic.span = aWindow / alTrafficBuckets
+
+	if 0 < aSummaryInterval {
+		stop := make(chan struct{})
+		alTraffic.stop = stop
+		go goTrafficSummary(aSummaryInterval, stop)
+	}
+} // SetTrafficTracking()
+
+// `goTrafficSummary()` periodically writes the traffic summary to the
+// access log.
+//
+// Parameters:
+// - `aInterval`: The time between two summary entries.
+// - `aStop`: Closed to stop writing summaries.
+func goTrafficSummary(aInterval time.Duration, aStop <-chan struct{}) {
+	ticker := time.NewTicker(aInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-aStop:
+			return
+		case <-ticker.C:
+			Log("ApacheLogger/Traffic", TrafficReport(10).String())
+		}
+	}
+} // goTrafficSummary()
+
+/* _EoF_ */
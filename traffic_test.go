@@ -0,0 +1,69 @@
+/*
+Copyright © 2024  M.Watermann, 10247 Berlin, Germany
+
+	    All rights reserved
+	EMail : <support@mwat.de>
+*/
+package apachelogger
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+//lint:file-ignore ST1017 – I prefer Yoda conditions
+
+func TestTrafficReport(t *testing.T) {
+	defer SetTrafficTracking(0, 0)
+
+	e := prepEntry()
+	countTraffic(e) // tracking not active
+	if got := TrafficReport(0); 0 != got.Requests {
+		t.Errorf("TrafficReport() requests = %d, want 0", got.Requests)
+	}
+
+	SetTrafficTracking(time.Minute, 0)
+	for i := 0; 3 > i; i++ {
+		countTraffic(e)
+	}
+	e2 := prepEntry()
+	e2.Remote, e2.Path, e2.Status = "10.0.0.0", "/login?user=x", 401
+	countTraffic(e2)
+
+	got := TrafficReport(1)
+	if 4 != got.Requests {
+		t.Errorf("TrafficReport() requests = %d, want 4", got.Requests)
+	}
+	want := []TCount{{"192.168.1.0", 3}}
+	if !reflect.DeepEqual(got.Clients, want) {
+		t.Errorf("TrafficReport() clients = %v, want %v", got.Clients, want)
+	}
+	all := TrafficReport(0)
+	want = []TCount{{"/path/to/file", 3}, {"/login", 1}}
+	if !reflect.DeepEqual(all.Paths, want) {
+		t.Errorf("TrafficReport() paths = %v, want %v", all.Paths, want)
+	}
+	if s := got.String(); !strings.Contains(s, "requests=4 ") ||
+		!strings.Contains(s, " top_statuses=200:3 ") {
+		t.Errorf("String() = %q", s)
+	}
+
+	SetTrafficTracking(time.Minute, 0) // resets the counters
+	if got := TrafficReport(0); (0 != got.Requests) || (0 != len(got.Clients)) {
+		t.Errorf("TrafficReport() = %v, want empty report", got)
+	}
+} // TestTrafficReport()
+
+func Test_countKey(t *testing.T) {
+	counts := make(map[string]int)
+	for i := 0; alTrafficMaxKeys+2 > i; i++ {
+		countKey(counts, time.Duration(i).String())
+	}
+	if (alTrafficMaxKeys+1 != len(counts)) || (2 != counts[alTrafficOther]) {
+		t.Errorf("countKey() keys = %d, other = %d", len(counts), counts[alTrafficOther])
+	}
+} // Test_countKey()
+
+/* _EoF_ */
@@ -0,0 +1,349 @@
+/*
+Copyright © 2024  M.Watermann, 10247 Berlin, Germany
+
+	    All rights reserved
+	EMail : <support@mwat.de>
+*/
+package apachelogger
+
+import (
+	"crypto/tls"
+	"io"
+	"net/http"
+	"net/http/httptrace"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+//lint:file-ignore ST1017 – I prefer Yoda conditions
+
+type (
+	// `tCountingBody` wraps a response body counting the bytes read.
+	tCountingBody struct {
+		io.ReadCloser                 // the original response body
+		done          func(aSize int) // called once the body is done
+		once          sync.Once       // make sure `done` is called once
+		size          int             // number of bytes read so far
+	}
+
+	// `tLogTransport` is a `http.RoundTripper` logging all requests.
+	tLogTransport struct {
+		queue     *tRing            // the queue to send log messages to (`nil`: the access log)
+		transport http.RoundTripper // the transport doing the actual work
+	}
+
+	// `tTraceTimes` collects the timings of an outgoing request.
+	tTraceTimes struct {
+		sync.Mutex
+		dnsStart, dnsDone         time.Time
+		connectStart, connectDone time.Time
+		tlsStart, tlsDone         time.Time
+		firstByte                 time.Time
+		reused                    bool
+	}
+)
+
+var (
+	// `TraceTransport` decides whether `WrapTransport()` records the
+	// DNS, connect, TLS and first-byte timings of outgoing requests
+	// (default: `false`).
+	TraceTransport = false
+)
+
+// `clientTrace()` returns the `httptrace.ClientTrace` hooks filling
+// the timings.
+//
+// Returns:
+// - `*httptrace.ClientTrace`: The hooks to add to the request context.
+func (tt *tTraceTimes) clientTrace() *httptrace.ClientTrace {
+	now := func(aTime *time.Time) {
+		tt.Lock()
+		if aTime.IsZero() {
+			*aTime = time.Now()
+		}
+		tt.Unlock()
+	}
+
+	return &httptrace.ClientTrace{
+		DNSStart: func(httptrace.DNSStartInfo) { now(&tt.dnsStart) },
+		DNSDone:  func(httptrace.DNSDoneInfo) { now(&tt.dnsDone) },
+		ConnectStart: func(string, string) {
+			now(&tt.connectStart)
+		},
+		ConnectDone: func(string, string, error) {
+			now(&tt.connectDone)
+		},
+		TLSHandshakeStart: func() { now(&tt.tlsStart) },
+		TLSHandshakeDone: func(tls.ConnectionState, error) {
+			now(&tt.tlsDone)
+		},
+		GotConn: func(aInfo httptrace.GotConnInfo) {
+			tt.Lock()
+			tt.reused = aInfo.Reused
+			tt.Unlock()
+		},
+		GotFirstResponseByte: func() { now(&tt.firstByte) },
+	}
+} // clientTrace()
+
+// `fields()` returns the collected timings (in microseconds) as log
+// entry fields.
+//
+// Parameters:
+// - `aStart`: The time the request was started.
+//
+// Returns:
+// - `map[string]string`: The timings as entry fields.
+func (tt *tTraceTimes) fields(aStart time.Time) map[string]string {
+	micro := func(aFrom, aTo time.Time) string {
+		return strconv.FormatInt(elapsed(aFrom, aTo).Microseconds(), 10)
+	}
+	tt.Lock()
+	defer tt.Unlock()
+
+	result := make(map[string]string, 5)
+	if !tt.dnsStart.IsZero() && !tt.dnsDone.IsZero() {
+		result["dns_us"] = micro(tt.dnsStart, tt.dnsDone)
+	}
+	if !tt.connectStart.IsZero() && !tt.connectDone.IsZero() {
+		result["connect_us"] = micro(tt.connectStart, tt.connectDone)
+	}
+	if !tt.tlsStart.IsZero() && !tt.tlsDone.IsZero() {
+		result["tls_us"] = micro(tt.tlsStart, tt.tlsDone)
+	}
+	if !tt.firstByte.IsZero() {
+		result["ttfb_us"] = micro(aStart, tt.firstByte)
+	}
+	result["reused"] = strconv.FormatBool(tt.reused)
+
+	return result
+} // fields()
+
+// `Close()` closes the response body and logs the request.
+//
+// Part of the `io.Closer` interface.
+//
+// Returns:
+// - `error`: A possible error closing the body.
+func (cb *tCountingBody) Close() error {
+	err := cb.ReadCloser.Close()
+	cb.once.Do(func() {
+		cb.done(cb.size)
+	})
+
+	return err
+} // Close()
+
+// `Read()` reads from the response body, counting the bytes read.
+//
+// Part of the `io.Reader` interface.
+//
+// Parameters:
+// - `aBuffer`: The buffer to read into.
+//
+// Returns:
+// - `int`: The number of bytes read.
+// - `error`: A possible error reading the body (e.g. `io.EOF`).
+func (cb *tCountingBody) Read(aBuffer []byte) (int, error) {
+	n, err := cb.ReadCloser.Read(aBuffer)
+	cb.size += n
+	if io.EOF == err {
+		cb.once.Do(func() {
+			cb.done(cb.size)
+		})
+	}
+
+	return n, err
+} // Read()
+
+// `logQueue()` returns the queue to send the log messages to.
+//
+// Without a logfile of its own the current access log is used, so a
+// transport created before `Wrap()` (or `WrapWith()` with other sinks)
+// logs to the access log set up later.
+//
+// Returns:
+// - `*tRing`: The queue of the transport's log.
+func (lt *tLogTransport) logQueue() *tRing {
+	if nil == lt.queue {
+		return accessQueue()
+	}
+
+	return lt.queue
+} // logQueue()
+
+// `RoundTrip()` executes a single HTTP transaction and logs it.
+//
+// The log entry is written once the response body was read completely
+// or closed (or immediately if the request failed).
+//
+// Part of the `http.RoundTripper` interface.
+//
+// Parameters:
+// - `aRequest`: The request to send.
+//
+// Returns:
+// - `*http.Response`: The server's response.
+// - `error`: A possible error of processing.
+func (lt *tLogTransport) RoundTrip(aRequest *http.Request) (*http.Response, error) {
+	var timings *tTraceTimes
+
+	start := time.Now()
+	if TraceTransport {
+		timings = &tTraceTimes{}
+		aRequest = aRequest.WithContext(httptrace.WithClientTrace(
+			aRequest.Context(), timings.clientTrace()))
+	}
+	response, err := lt.transport.RoundTrip(aRequest)
+
+	entry := outboundEntry(aRequest, start)
+	if nil != timings {
+		entry.Fields = timings.fields(start)
+	}
+	if nil != err {
+		entry.Duration = elapsed(start, time.Now())
+		entry.SetField("error", err.Error())
+		go goOutboundLog(entry, lt.logQueue())
+
+		return response, err
+	}
+
+	entry.Status = response.StatusCode
+	if "" != response.Proto {
+		entry.Proto = response.Proto
+	}
+	if (http.StatusSwitchingProtocols == response.StatusCode) ||
+		(nil == response.Body) {
+		// Don't hide a possible `io.Writer` of the response body.
+		entry.Duration = elapsed(start, time.Now())
+		go goOutboundLog(entry, lt.logQueue())
+
+		return response, nil
+	}
+
+	response.Body = &tCountingBody{
+		ReadCloser: response.Body,
+		done: func(aSize int) {
+			entry.Size = aSize
+			entry.Duration = elapsed(start, time.Now())
+			go goOutboundLog(entry, lt.logQueue())
+		},
+	}
+
+	return response, nil
+} // RoundTrip()
+
+/* * * * * * * * * * * * * * * * * * * * * * * * * * * * * * * * * * * * * */
+
+// `goOutboundLog()` sends the log entry of an outgoing request to
+// `aLogQueue`.
+//
+// In Apache-like mode the request's duration (in microseconds) is
+// appended to the logfile line (like Apache's `%D`), followed by the
+// request's timings (if recorded) as `key=value` pairs.
+//
+// Parameters:
+// - `aEntry`: The log entry to write.
+// - `aLogQueue`: The queue to send the message to.
+func goOutboundLog(aEntry *TEntry, aLogQueue *tRing) {
+	prepareEntry(aEntry)
+	stampSequence(aEntry, aLogQueue)
+
+	if BinaryLog || CanonicalLogLine || (nil != csvFormat()) ||
+		(nil != w3cFormat()) || (nil != logFormat()) {
+		aLogQueue.push(formatEntry(aEntry))
+		return
+	}
+	var sb strings.Builder
+	sb.WriteString(strings.TrimSuffix(aEntry.String(), "\n"))
+	sb.WriteByte(' ')
+	sb.WriteString(strconv.FormatInt(aEntry.Duration.Microseconds(), 10))
+	appendFields(&sb, aEntry.Fields)
+	sb.WriteByte('\n')
+
+	aLogQueue.push(sb.String())
+} // goOutboundLog()
+
+// `outboundEntry()` returns the log entry for an outgoing request.
+//
+// The entry's remote address is the contacted host, and its path is
+// the request's URL without query and fragment (which might contain
+// credentials).
+//
+// Parameters:
+// - `aRequest`: The outgoing request.
+// - `aStart`: The time the request was started.
+//
+// Returns:
+// - `*TEntry`: The request's log entry.
+func outboundEntry(aRequest *http.Request, aStart time.Time) *TEntry {
+	var host, path string
+
+	if nil != aRequest.URL {
+		host = aRequest.URL.Host
+		path = aRequest.URL.Scheme + "://" + aRequest.URL.Host + aRequest.URL.EscapedPath()
+	}
+	if "" == host {
+		host = "-"
+	}
+	agent := aRequest.UserAgent()
+	if "" == agent {
+		agent = "-"
+	}
+	method := aRequest.Method
+	if "" == method {
+		method = http.MethodGet
+	}
+
+	return &TEntry{
+		Remote:   host,
+		User:     "-",
+		When:     aStart,
+		Method:   method,
+		Path:     path,
+		Proto:    getProto(aRequest),
+		Referrer: getReferrer(&aRequest.Header),
+		Agent:    agent,
+	}
+} // outboundEntry()
+
+// `WrapTransport()` returns a `http.RoundTripper` that writes an
+// Apache-like log entry for every request sent via `aTransport`.
+//
+// The entries contain the contacted host (instead of the remote
+// address), the method, the requested URL without query, the response's
+// status and size, and – appended to the line – the request's duration
+// in microseconds. If `TraceTransport` is `true` the DNS, connect, TLS
+// and first-byte timings (`dns_us`, `connect_us`, `tls_us`, `ttfb_us`)
+// and whether a kept-alive connection was reused are appended as well.
+//
+// Example:
+//
+//	client := &http.Client{
+//		Transport: apachelogger.WrapTransport(nil, "/var/log/upstream.log"),
+//	}
+//
+// Parameters:
+// - `aTransport`: The transport doing the actual work; if `nil`,
+// `http.DefaultTransport` is used.
+// - `aAccessLog`: The name of the logfile to write to; if empty the
+// access log (as set up by `Wrap()` when the request is sent) is used.
+//
+// Returns:
+// - `http.RoundTripper`: The (augmented) `aTransport`.
+func WrapTransport(aTransport http.RoundTripper, aAccessLog string) http.RoundTripper {
+	if nil == aTransport {
+		aTransport = http.DefaultTransport
+	}
+
+	result := &tLogTransport{transport: aTransport}
+	if "" != aAccessLog {
+		result.queue = fileQueue(aAccessLog)
+	}
+
+	return result
+} // WrapTransport()
+
+/* _EoF_ */
@@ -0,0 +1,111 @@
+/*
+Copyright © 2024  M.Watermann, 10247 Berlin, Germany
+
+	    All rights reserved
+	EMail : <support@mwat.de>
+*/
+package apachelogger
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+//lint:file-ignore ST1017 – I prefer Yoda conditions
+
+func Test_tLogTransport_RoundTrip(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(
+		func(aWriter http.ResponseWriter, aRequest *http.Request) {
+			aWriter.WriteHeader(http.StatusTeapot)
+			_, _ = io.WriteString(aWriter, "Hello world!")
+		}))
+	defer server.Close()
+
+	queue := newRing(2)
+	client := &http.Client{
+		Transport: &tLogTransport{queue: queue, transport: http.DefaultTransport},
+	}
+	resp, err := client.Get(server.URL + "/some/path?secret=1")
+	if nil != err {
+		t.Fatalf("client.Get() error = %v", err)
+	}
+	_, _ = io.Copy(io.Discard, resp.Body)
+	_ = resp.Body.Close()
+
+	got, ok := popWithin(queue, time.Second)
+	if !ok {
+		t.Fatal("RoundTrip() didn't log the request")
+	}
+	host := strings.TrimPrefix(server.URL, "http://")
+	want := `"GET ` + server.URL + `/some/path HTTP/1.1" 418 12 "-" "-" `
+	if !strings.HasPrefix(got, host+" - - [") || !strings.Contains(got, want) {
+		t.Errorf("RoundTrip() logged %q,\nwant %q", got, want)
+	}
+	if strings.Contains(got, "secret") {
+		t.Errorf("RoundTrip() logged the query: %q", got)
+	}
+} // Test_tLogTransport_RoundTrip()
+
+func Test_tLogTransport_trace(t *testing.T) {
+	defer func() {
+		TraceTransport = false
+	}()
+	server := httptest.NewServer(http.HandlerFunc(
+		func(aWriter http.ResponseWriter, aRequest *http.Request) {
+			_, _ = io.WriteString(aWriter, "Hello world!")
+		}))
+	defer server.Close()
+
+	TraceTransport = true
+	queue := newRing(2)
+	client := &http.Client{
+		Transport: &tLogTransport{queue: queue, transport: &http.Transport{}},
+	}
+	resp, err := client.Get(server.URL + "/")
+	if nil != err {
+		t.Fatalf("client.Get() error = %v", err)
+	}
+	_, _ = io.Copy(io.Discard, resp.Body)
+	_ = resp.Body.Close()
+
+	got, ok := popWithin(queue, time.Second)
+	if !ok {
+		t.Fatal("RoundTrip() didn't log the request")
+	}
+	for _, want := range []string{" connect_us=", " reused=false", " ttfb_us="} {
+		if !strings.Contains(got, want) {
+			t.Errorf("RoundTrip() logged %q,\nwant %q", got, want)
+		}
+	}
+} // Test_tLogTransport_trace()
+
+func TestWrapTransport(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(
+		func(aWriter http.ResponseWriter, aRequest *http.Request) {
+			_, _ = io.WriteString(aWriter, "Hello world!")
+		}))
+	defer server.Close()
+
+	// the transport is created before the access log is set up:
+	client := &http.Client{Transport: WrapTransport(nil, "")}
+	queue := newRing(2)
+	defer setLogQueues(setLogQueues(queue, nil))
+
+	resp, err := client.Get(server.URL + "/later")
+	if nil != err {
+		t.Fatalf("client.Get() error = %v", err)
+	}
+	_, _ = io.Copy(io.Discard, resp.Body)
+	_ = resp.Body.Close()
+
+	got, ok := popWithin(queue, time.Second)
+	if !ok || !strings.Contains(got, "/later ") {
+		t.Errorf("WrapTransport() logged %q to the current access log, want the request", got)
+	}
+} // TestWrapTransport()
+
+/* _EoF_ */
@@ -0,0 +1,499 @@
+//go:build linux && (amd64 || arm64)
+// +build linux
+// +build amd64 arm64
+
+/*
+Copyright © 2024  M.Watermann, 10247 Berlin, Germany
+
+	    All rights reserved
+	EMail : <support@mwat.de>
+*/
+package apachelogger
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"time"
+	"unsafe"
+)
+
+//lint:file-ignore ST1017 – I prefer Yoda conditions
+
+const (
+	// System calls and constants of the Linux io_uring API.
+	alSysURingSetup    = 425
+	alSysURingEnter    = 426
+	alSysURingRegister = 427
+
+	alURingOffSQRing = 0
+	alURingOffCQRing = 0x8000000
+	alURingOffSQEs   = 0x10000000
+
+	alURingOpFsync = 3
+	alURingOpWrite = 23
+
+	alURingEnterGetEvents = 1
+	alURingRegisterProbe  = 8
+	alURingOpSupported    = 1
+	alURingFsyncDatasync  = 1
+	alURingSQELink        = 4
+
+	// Number of submission queue entries of a sink's ring.
+	alURingEntries = 64
+
+	// Mode of opening the logfile(s); durability is ensured by `Flush()`.
+	alURingOpenFlags = os.O_CREATE | os.O_APPEND | os.O_WRONLY
+)
+
+type (
+	// `tURingSQRingOffsets` mirrors `struct io_sqring_offsets`.
+	tURingSQRingOffsets struct {
+		head, tail, ringMask, ringEntries, flags, dropped, array, resv1 uint32
+		userAddr                                                        uint64
+	}
+
+	// `tURingCQRingOffsets` mirrors `struct io_cqring_offsets`.
+	tURingCQRingOffsets struct {
+		head, tail, ringMask, ringEntries, overflow, cqes, flags, resv1 uint32
+		userAddr                                                        uint64
+	}
+
+	// `tURingParams` mirrors `struct io_uring_params`.
+	tURingParams struct {
+		sqEntries, cqEntries, flags, sqThreadCPU, sqThreadIdle uint32
+		features, wqFD                                         uint32
+		resv                                                   [3]uint32
+		sqOff                                                  tURingSQRingOffsets
+		cqOff                                                  tURingCQRingOffsets
+	}
+
+	// `tURingSQE` mirrors `struct io_uring_sqe`.
+	tURingSQE struct {
+		opcode      uint8
+		flags       uint8
+		ioprio      uint16
+		fd          int32
+		off         uint64
+		addr        uint64
+		len         uint32
+		opFlags     uint32
+		userData    uint64
+		bufIndex    uint16
+		personality uint16
+		spliceFDIn  int32
+		pad         [2]uint64
+	}
+
+	// `tURingCQE` mirrors `struct io_uring_cqe`.
+	tURingCQE struct {
+		userData uint64
+		res      int32
+		flags    uint32
+	}
+
+	// `tURingProbeOp` mirrors `struct io_uring_probe_op`.
+	tURingProbeOp struct {
+		op    uint8
+		resv  uint8
+		flags uint16
+		resv2 uint32
+	}
+
+	// `tURingProbe` mirrors `struct io_uring_probe` with room for all
+	// opcodes.
+	tURingProbe struct {
+		lastOp uint8
+		opsLen uint8
+		resv   uint16
+		resv2  [3]uint32
+		ops    [256]tURingProbeOp
+	}
+
+	// `tURing` is an io_uring instance with its memory mapped queues.
+	tURing struct {
+		fd      int         // the ring's file descriptor
+		sqMem   []byte      // the mapped submission queue ring
+		cqMem   []byte      // the mapped completion queue ring
+		sqeMem  []byte      // the mapped submission queue entries
+		sqTail  *uint32     // the submission queue's tail
+		sqMask  uint32      // mask of submission queue indices
+		sqArray []uint32    // the submission queue's index array
+		sqes    []tURingSQE // the submission queue entries
+		cqHead  *uint32     // the completion queue's head
+		cqTail  *uint32     // the completion queue's tail
+		cqMask  uint32      // mask of completion queue indices
+		cqes    []tURingCQE // the completion queue entries
+		queued  int         // entries prepared but not yet submitted
+	}
+
+	// `tURingFileSink` appends log entries to a file using io_uring.
+	tURingFileSink struct {
+		buffers [][]byte // the data of the queued writes
+		dirty   bool     // data was written since the last sync
+		err     error    // error of writes submitted by `Close()`
+		file    *os.File // the currently opened logfile
+		name    string   // the logfile's name
+		ring    *tURing  // the io_uring instance (`nil` if unavailable)
+	}
+)
+
+var (
+	// Whether the kernel supports io_uring (see `uringSupported()`).
+	alURingSupported bool
+
+	// Make sure to check the io_uring support only once.
+	alURingSupportedOnce sync.Once
+
+	// The error of a write that was cancelled or incomplete.
+	errURingWrite = errors.New("apachelogger: io_uring write failed")
+)
+
+// `newURing()` sets up a new io_uring instance.
+//
+// Parameters:
+// - `aEntries`: The number of submission queue entries.
+//
+// Returns:
+// - `*tURing`: The new instance.
+// - `error`: A possible error setting up the ring.
+func newURing(aEntries int) (*tURing, error) {
+	var params tURingParams
+	fd, _, errno := syscall.Syscall(alSysURingSetup,
+		uintptr(aEntries), uintptr(unsafe.Pointer(&params)), 0)
+	if 0 != errno {
+		return nil, errno
+	}
+	ur := &tURing{fd: int(fd)}
+
+	var err error
+	sqSize := int(params.sqOff.array + params.sqEntries*4)
+	if ur.sqMem, err = syscall.Mmap(ur.fd, alURingOffSQRing, sqSize,
+		syscall.PROT_READ|syscall.PROT_WRITE, syscall.MAP_SHARED|syscall.MAP_POPULATE); nil != err {
+		ur.close()
+		return nil, err
+	}
+	cqSize := int(params.cqOff.cqes + params.cqEntries*uint32(unsafe.Sizeof(tURingCQE{})))
+	if ur.cqMem, err = syscall.Mmap(ur.fd, alURingOffCQRing, cqSize,
+		syscall.PROT_READ|syscall.PROT_WRITE, syscall.MAP_SHARED|syscall.MAP_POPULATE); nil != err {
+		ur.close()
+		return nil, err
+	}
+	sqeSize := int(params.sqEntries) * int(unsafe.Sizeof(tURingSQE{}))
+	if ur.sqeMem, err = syscall.Mmap(ur.fd, alURingOffSQEs, sqeSize,
+		syscall.PROT_READ|syscall.PROT_WRITE, syscall.MAP_SHARED|syscall.MAP_POPULATE); nil != err {
+		ur.close()
+		return nil, err
+	}
+
+	ur.sqTail = (*uint32)(unsafe.Pointer(&ur.sqMem[params.sqOff.tail]))
+	ur.sqMask = *(*uint32)(unsafe.Pointer(&ur.sqMem[params.sqOff.ringMask]))
+	ur.sqArray = unsafe.Slice((*uint32)(unsafe.Pointer(&ur.sqMem[params.sqOff.array])), params.sqEntries)
+	ur.sqes = unsafe.Slice((*tURingSQE)(unsafe.Pointer(&ur.sqeMem[0])), params.sqEntries)
+	ur.cqHead = (*uint32)(unsafe.Pointer(&ur.cqMem[params.cqOff.head]))
+	ur.cqTail = (*uint32)(unsafe.Pointer(&ur.cqMem[params.cqOff.tail]))
+	ur.cqMask = *(*uint32)(unsafe.Pointer(&ur.cqMem[params.cqOff.ringMask]))
+	ur.cqes = unsafe.Slice((*tURingCQE)(unsafe.Pointer(&ur.cqMem[params.cqOff.cqes])), params.cqEntries)
+
+	return ur, nil
+} // newURing()
+
+// `close()` releases the ring's resources.
+func (ur *tURing) close() {
+	for _, mem := range [][]byte{ur.sqeMem, ur.cqMem, ur.sqMem} {
+		if nil != mem {
+			_ = syscall.Munmap(mem)
+		}
+	}
+	ur.sqeMem, ur.cqMem, ur.sqMem = nil, nil, nil
+	_ = syscall.Close(ur.fd)
+} // close()
+
+// `supports()` reports whether the kernel supports all opcodes of
+// `aOpcodes` (the probe itself requires Linux 5.6, like
+// `IORING_OP_WRITE`).
+//
+// Parameters:
+// - `aOpcodes`: The opcodes to check.
+//
+// Returns:
+// - `bool`: `true` if all opcodes are supported.
+func (ur *tURing) supports(aOpcodes ...uint8) bool {
+	var probe tURingProbe
+	_, _, errno := syscall.Syscall6(alSysURingRegister, uintptr(ur.fd),
+		alURingRegisterProbe, uintptr(unsafe.Pointer(&probe)),
+		uintptr(len(probe.ops)), 0, 0)
+	if 0 != errno {
+		return false // kernel older than 5.6
+	}
+	for _, opcode := range aOpcodes {
+		if (opcode > probe.lastOp) ||
+			(0 == probe.ops[opcode].flags&alURingOpSupported) {
+			return false
+		}
+	}
+
+	return true
+} // supports()
+
+// `prepare()` prepares the next submission queue entry.
+//
+// Parameters:
+// - `aEntry`: The entry to queue.
+func (ur *tURing) prepare(aEntry tURingSQE) {
+	tail := atomic.LoadUint32(ur.sqTail) + uint32(ur.queued)
+	idx := tail & ur.sqMask
+	ur.sqes[idx] = aEntry
+	ur.sqArray[idx] = idx
+	ur.queued++
+} // prepare()
+
+// `submitAndWait()` submits all prepared entries and waits for their
+// completion.
+//
+// The entries are linked, so they are executed in order and a failing
+// entry cancels all following ones.
+//
+// Returns:
+// - `error`: The first error of the submitted operations.
+func (ur *tURing) submitAndWait() (rErr error) {
+	count := ur.queued
+	if 0 == count {
+		return nil
+	}
+	tail := atomic.LoadUint32(ur.sqTail)
+	for idx := 0; idx < count; idx++ {
+		sqe := &ur.sqes[(tail+uint32(idx))&ur.sqMask]
+		if idx < count-1 {
+			sqe.flags |= alURingSQELink
+		} else {
+			sqe.flags &^= alURingSQELink
+		}
+	}
+	atomic.StoreUint32(ur.sqTail, tail+uint32(count))
+	ur.queued = 0
+
+	for submit, done := count, 0; done < count; {
+		n, _, errno := syscall.Syscall6(alSysURingEnter, uintptr(ur.fd),
+			uintptr(submit), uintptr(count-done), alURingEnterGetEvents, 0, 0)
+		if 0 != errno {
+			if syscall.EINTR == errno {
+				continue
+			}
+			return errno
+		}
+		submit -= int(n) // the number of entries consumed
+
+		// Reap the completions:
+		head := atomic.LoadUint32(ur.cqHead)
+		for tail := atomic.LoadUint32(ur.cqTail); head != tail; head++ {
+			cqe := ur.cqes[head&ur.cqMask]
+			if (nil == rErr) && (0 > cqe.res) {
+				if syscall.ECANCELED == syscall.Errno(-cqe.res) {
+					rErr = errURingWrite
+				} else {
+					rErr = syscall.Errno(-cqe.res)
+				}
+			} else if (nil == rErr) && (0 < cqe.userData) && (uint64(cqe.res) != cqe.userData) {
+				rErr = errURingWrite // short write
+			}
+			done++
+		}
+		atomic.StoreUint32(ur.cqHead, head)
+	}
+
+	return
+} // submitAndWait()
+
+/* * * * * * * * * * * * * * * * * * * * * * * * * * * * * * * * * * * * * */
+
+// `uringSupported()` reports whether the kernel supports the io_uring
+// operations used by `tURingFileSink`.
+//
+// Returns:
+// - `bool`: `true` if io_uring instances can be set up and support
+// writes and syncs.
+func uringSupported() bool {
+	alURingSupportedOnce.Do(func() {
+		if ur, err := newURing(2); nil == err {
+			alURingSupported = ur.supports(alURingOpWrite, alURingOpFsync)
+			ur.close()
+		}
+	})
+
+	return alURingSupported
+} // uringSupported()
+
+// `NewURingFileSink()` returns a sink appending to the file `aFilename`
+// using Linux's io_uring interface.
+//
+// All data written between two flushes of the background writer is
+// submitted as a single chain of linked writes followed by an
+// `fdatasync`, so a batch of entries costs a single system call.
+// If io_uring isn't available (e.g. disabled by a seccomp profile or
+// a kernel older than 5.6 lacking `IORING_OP_WRITE`) a sink created by
+// `NewFileSink()` is returned instead.
+//
+// The writes are submitted by `Flush()` (or when the ring is full),
+// so their errors are returned by that call; the errors of the writes
+// submitted by `Close()` are returned by the next `Write()`.
+//
+// Parameters:
+// - `aFilename`: The name of the logfile to write to.
+//
+// Returns:
+// - `TSink`: The sink to use with `WrapSinks()`.
+func NewURingFileSink(aFilename string) TSink {
+	if !uringSupported() {
+		return NewFileSink(aFilename)
+	}
+	if 0 < len(aFilename) {
+		if absFile, err := filepath.Abs(aFilename); nil == err {
+			aFilename = absFile
+		}
+	}
+
+	return &tURingFileSink{name: aFilename}
+} // NewURingFileSink()
+
+// `Close()` writes all pending data and closes the logfile.
+//
+// Part of the `TSink` interface.
+//
+// Returns:
+// - `error`: A possible error while writing or closing the logfile.
+func (us *tURingFileSink) Close() (rErr error) {
+	if rErr = us.Flush(); nil != rErr {
+		us.err = rErr // report it by the next `Write()` as well
+	}
+	if nil != us.ring {
+		us.ring.close()
+		us.ring = nil
+	}
+	if nil != us.file {
+		if err := us.file.Close(); nil == rErr {
+			rErr = err
+		}
+		us.file = nil
+	}
+
+	return
+} // Close()
+
+// `Flush()` submits all pending writes followed by an `fdatasync` and
+// waits for their completion.
+//
+// Part of the `TSink` interface.
+//
+// Returns:
+// - `error`: A possible error while writing the data.
+func (us *tURingFileSink) Flush() (rErr error) {
+	if (nil == us.file) || !us.dirty {
+		return nil
+	}
+	if nil == us.ring {
+		rErr = us.file.Sync()
+	} else {
+		us.ring.prepare(tURingSQE{
+			opcode:  alURingOpFsync,
+			fd:      int32(us.file.Fd()),
+			opFlags: alURingFsyncDatasync,
+		})
+		rErr = us.ring.submitAndWait()
+		us.buffers = us.buffers[:0]
+	}
+	us.dirty = false
+
+	return
+} // Flush()
+
+// `open()` opens the logfile for appending and sets up the ring.
+//
+// Returns:
+// - `error`: A possible error while opening the logfile.
+func (us *tURingFileSink) open() (rErr error) {
+	if us.file, rErr = os.OpenFile(us.name, alURingOpenFlags, 0640); /* #nosec G302 */ nil != rErr {
+		return
+	}
+	writeHeader(us.file)
+	if !uringSupported() {
+		return // write synchronously like `tFileSink`
+	}
+	if ur, err := newURing(alURingEntries); nil == err {
+		us.ring = ur
+	}
+
+	return
+} // open()
+
+// `rotate()` writes all pending data, closes the logfile, and renames
+// it for `aDay`.
+//
+// Part of the `tRotator` interface.
+//
+// Parameters:
+// - `aDay`: The day of the entries in the logfile.
+//
+// Returns:
+// - `error`: A possible error while closing or renaming the logfile.
+func (us *tURingFileSink) rotate(aDay time.Time) error {
+	if err := us.Close(); nil != err {
+		return err
+	}
+
+	return rotateFile(us.name, aDay)
+} // rotate()
+
+// `Write()` queues `aData` to be appended to the logfile, opening it
+// if necessary.
+//
+// Part of the `TSink` interface.
+//
+// Parameters:
+// - `aData`: The data to write to the logfile.
+//
+// Returns:
+// - `error`: A possible error while writing the data.
+func (us *tURingFileSink) Write(aData []byte) (rErr error) {
+	if nil == us.file {
+		// Loop until we actually opened the logfile:
+		for nil != us.open() {
+			time.Sleep(1234)
+		}
+	}
+	if rErr, us.err = us.err, nil; 0 == len(aData) {
+		return
+	}
+	us.dirty = true
+	if nil == us.ring {
+		if _, err := us.file.Write(aData); nil != err {
+			rErr = err
+		}
+		return
+	}
+
+	// Keep one entry for the final `fdatasync`:
+	if len(us.ring.sqes)-1 <= us.ring.queued {
+		if err := us.ring.submitAndWait(); nil != err {
+			rErr = err
+		}
+		us.buffers = us.buffers[:0]
+	}
+	data := append([]byte(nil), aData...) // the caller may reuse `aData`
+	us.buffers = append(us.buffers, data)
+	us.ring.prepare(tURingSQE{
+		opcode:   alURingOpWrite,
+		fd:       int32(us.file.Fd()),
+		off:      ^uint64(0), // append at the current position
+		addr:     uint64(uintptr(unsafe.Pointer(&data[0]))),
+		len:      uint32(len(data)),
+		userData: uint64(len(data)),
+	})
+
+	return
+} // Write()
+
+/* _EoF_ */
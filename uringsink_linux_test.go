@@ -0,0 +1,93 @@
+//go:build linux && (amd64 || arm64)
+// +build linux
+// +build amd64 arm64
+
+/*
+Copyright © 2024  M.Watermann, 10247 Berlin, Germany
+
+	    All rights reserved
+	EMail : <support@mwat.de>
+*/
+package apachelogger
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"unsafe"
+)
+
+//lint:file-ignore ST1017 – I prefer Yoda conditions
+
+func Test_tURingLayout(t *testing.T) {
+	if got := unsafe.Sizeof(tURingParams{}); 120 != got {
+		t.Errorf("sizeof(tURingParams) = %d, want 120", got)
+	}
+	if got := unsafe.Sizeof(tURingSQE{}); 64 != got {
+		t.Errorf("sizeof(tURingSQE) = %d, want 64", got)
+	}
+	if got := unsafe.Sizeof(tURingCQE{}); 16 != got {
+		t.Errorf("sizeof(tURingCQE) = %d, want 16", got)
+	}
+	if got := unsafe.Sizeof(tURingProbe{}); 16+256*8 != got {
+		t.Errorf("sizeof(tURingProbe) = %d, want %d", got, 16+256*8)
+	}
+} // Test_tURingLayout()
+
+func Test_tURingFileSink(t *testing.T) {
+	fName := filepath.Join(t.TempDir(), "access.log")
+	sink := NewURingFileSink(fName)
+	if _, ok := sink.(*tURingFileSink); !ok {
+		t.Skip("io_uring not available")
+	}
+
+	var want strings.Builder
+	for i := 0; i < 3*alURingEntries; i++ { // more than one ring's worth
+		line := fmt.Sprintf("line %03d\n", i)
+		want.WriteString(line)
+		if err := sink.Write([]byte(line)); nil != err {
+			t.Fatalf("Write() error = %v", err)
+		}
+	}
+	if err := sink.Flush(); nil != err {
+		t.Fatalf("Flush() error = %v", err)
+	}
+	if err := sink.Close(); nil != err {
+		t.Fatalf("Close() error = %v", err)
+	}
+	// writing after closing should reopen the file:
+	if err := sink.Write([]byte("last line\n")); nil != err {
+		t.Fatalf("Write() error = %v", err)
+	}
+	want.WriteString("last line\n")
+	if err := sink.Close(); nil != err {
+		t.Fatalf("Close() error = %v", err)
+	}
+
+	got, err := os.ReadFile(fName) // #nosec G304
+	if nil != err {
+		t.Fatalf("os.ReadFile() error = %v", err)
+	}
+	if string(got) != want.String() {
+		t.Errorf("tURingFileSink content = %q,\nwant %q", got, want.String())
+	}
+} // Test_tURingFileSink()
+
+func Test_tURingFileSink_deferred(t *testing.T) {
+	sink := &tURingFileSink{
+		name: filepath.Join(t.TempDir(), "access.log"),
+		err:  errURingWrite, // as left by a failing `Close()`
+	}
+	defer sink.Close()
+
+	if err := sink.Write([]byte("line\n")); errURingWrite != err {
+		t.Errorf("Write() error = %v, want %v", err, errURingWrite)
+	}
+	if err := sink.Write([]byte("line\n")); nil != err {
+		t.Errorf("Write() error = %v, want the error reported once", err)
+	}
+} // Test_tURingFileSink_deferred()
+
+/* _EoF_ */
@@ -0,0 +1,28 @@
+//go:build !linux || !(amd64 || arm64)
+// +build !linux !amd64,!arm64
+
+/*
+Copyright © 2024  M.Watermann, 10247 Berlin, Germany
+
+	    All rights reserved
+	EMail : <support@mwat.de>
+*/
+package apachelogger
+
+//lint:file-ignore ST1017 – I prefer Yoda conditions
+
+// `NewURingFileSink()` returns a sink appending to the file `aFilename`.
+//
+// Linux's io_uring interface isn't available on this platform, so a
+// sink created by `NewFileSink()` is returned.
+//
+// Parameters:
+// - `aFilename`: The name of the logfile to write to.
+//
+// Returns:
+// - `TSink`: The sink to use with `WrapSinks()`.
+func NewURingFileSink(aFilename string) TSink {
+	return NewFileSink(aFilename)
+} // NewURingFileSink()
+
+/* _EoF_ */
@@ -0,0 +1,227 @@
+/*
+Copyright © 2024  M.Watermann, 10247 Berlin, Germany
+
+	    All rights reserved
+	EMail : <support@mwat.de>
+*/
+package apachelogger
+
+import (
+	"strings"
+	"sync"
+)
+
+//lint:file-ignore ST1017 – I prefer Yoda conditions
+
+type (
+	// `TUserAgent` holds the data extracted from a user agent string.
+	TUserAgent struct {
+		Family string // browser family, e.g. `Firefox`
+		Major  string // browser's major version, e.g. `115`
+		OS     string // operating system, e.g. `Linux`
+		Device string // device class: `desktop`, `mobile`, `tablet`, `bot`
+	}
+
+	// `tUAToken` maps a version token of a user agent to a family.
+	tUAToken struct {
+		token  string // text preceding the version number
+		family string // the resulting browser family
+	}
+)
+
+const (
+	// Max. number of parsed user agents to cache.
+	alUACacheMax = 1024
+)
+
+var (
+	// `ParseUserAgents` decides whether to add the browser family
+	// (`ua_family`), major version (`ua_major`), operating system
+	// (`ua_os`) and device class (`ua_device`) as fields to the access
+	// log entries (default: `false`).
+	ParseUserAgents = false
+
+	// Browser tokens in order of precedence.
+	alUABrowsers = []tUAToken{
+		{"Edg/", "Edge"},
+		{"EdgA/", "Edge"},
+		{"EdgiOS/", "Edge"},
+		{"OPR/", "Opera"},
+		{"SamsungBrowser/", "Samsung Internet"},
+		{"FxiOS/", "Firefox"},
+		{"Firefox/", "Firefox"},
+		{"CriOS/", "Chrome"},
+		{"Chromium/", "Chromium"},
+		{"Chrome/", "Chrome"},
+		{"Version/", "Safari"}, // only if "Safari/" is present as well
+		{"MSIE ", "IE"},
+		{"rv:", "IE"}, // only if "Trident/" is present as well
+	}
+
+	// Substrings identifying bots and command line clients.
+	alUABots = []string{
+		"bot", "crawl", "spider", "slurp", "curl/", "wget/",
+		"python-requests", "go-http-client", "java/", "libwww",
+	}
+
+	// Cache of parsed user agents.
+	alUACache = make(map[string]TUserAgent)
+
+	// Guard for concurrent access to `alUACache`.
+	alUACacheMtx sync.RWMutex
+)
+
+// `addUserAgentFields()` adds the parsed data of `aAgent` as fields
+// to `aEntry`.
+//
+// Parameters:
+// - `aEntry`: The log entry to augment.
+// - `aAgent`: The user agent string.
+func addUserAgentFields(aEntry *TEntry, aAgent string) {
+	ua := ParseUserAgent(aAgent)
+	aEntry.SetField("ua_family", ua.Family)
+	aEntry.SetField("ua_major", ua.Major)
+	aEntry.SetField("ua_os", ua.OS)
+	aEntry.SetField("ua_device", ua.Device)
+} // addUserAgentFields()
+
+// `uaVersion()` returns the major version number following `aToken`
+// in `aAgent`.
+//
+// Parameters:
+// - `aAgent`: The user agent string.
+// - `aToken`: The text preceding the version number.
+//
+// Returns:
+// - `string`: The major version number or `-` if not found.
+func uaVersion(aAgent, aToken string) string {
+	idx := strings.Index(aAgent, aToken)
+	if 0 > idx {
+		return "-"
+	}
+	version := aAgent[idx+len(aToken):]
+	end := 0
+	for (end < len(version)) && ('0' <= version[end]) && ('9' >= version[end]) {
+		end++
+	}
+	if 0 == end {
+		return "-"
+	}
+
+	return version[:end]
+} // uaVersion()
+
+// `ParseUserAgent()` extracts browser family, major version, operating
+// system and device class from the user agent string `aAgent`.
+//
+// The parser uses simple heuristics covering the common browsers; it
+// is meant for statistics, not for browser sniffing. Unknown values
+// are returned as `Other` (or `-` for the version).
+//
+// Parameters:
+// - `aAgent`: The user agent string.
+//
+// Returns:
+// - `TUserAgent`: The extracted data.
+func ParseUserAgent(aAgent string) TUserAgent {
+	alUACacheMtx.RLock()
+	result, ok := alUACache[aAgent]
+	alUACacheMtx.RUnlock()
+	if ok {
+		return result
+	}
+
+	result = parseUserAgent(aAgent)
+
+	alUACacheMtx.Lock()
+	if alUACacheMax <= len(alUACache) {
+		alUACache = make(map[string]TUserAgent)
+	}
+	alUACache[aAgent] = result
+	alUACacheMtx.Unlock()
+
+	return result
+} // ParseUserAgent()
+
+// `parseUserAgent()` does the actual work for `ParseUserAgent()`.
+//
+// Parameters:
+// - `aAgent`: The user agent string.
+//
+// Returns:
+// - `TUserAgent`: The extracted data.
+func parseUserAgent(aAgent string) TUserAgent {
+	result := TUserAgent{Family: "Other", Major: "-", OS: "Other"}
+	lower := strings.ToLower(aAgent)
+
+	for _, bot := range alUABots {
+		if strings.Contains(lower, bot) {
+			result.Device = "bot"
+			// Use the product token (e.g. `curl`) as family:
+			product := aAgent
+			if idx := strings.LastIndex(aAgent, "compatible; "); 0 <= idx {
+				product = aAgent[idx+len("compatible; "):]
+			}
+			if idx := strings.IndexAny(product, "/ ;)"); 0 < idx {
+				result.Family = product[:idx]
+				result.Major = uaVersion(product, product[:idx]+"/")
+			}
+			break
+		}
+	}
+
+	if "bot" != result.Device {
+		for _, browser := range alUABrowsers {
+			if !strings.Contains(aAgent, browser.token) {
+				continue
+			}
+			if ("Version/" == browser.token) && !strings.Contains(aAgent, "Safari/") {
+				continue
+			}
+			if ("rv:" == browser.token) && !strings.Contains(aAgent, "Trident/") {
+				continue
+			}
+			result.Family = browser.family
+			result.Major = uaVersion(aAgent, browser.token)
+			break
+		}
+	}
+
+	switch {
+	case strings.Contains(aAgent, "Windows"):
+		result.OS = "Windows"
+	case strings.Contains(aAgent, "Android"):
+		result.OS = "Android"
+	case strings.Contains(aAgent, "iPhone"),
+		strings.Contains(aAgent, "iPad"),
+		strings.Contains(aAgent, "iPod"):
+		result.OS = "iOS"
+	case strings.Contains(aAgent, "Mac OS X"),
+		strings.Contains(aAgent, "Macintosh"):
+		result.OS = "macOS"
+	case strings.Contains(aAgent, "CrOS"):
+		result.OS = "ChromeOS"
+	case strings.Contains(aAgent, "Linux"),
+		strings.Contains(aAgent, "X11"):
+		result.OS = "Linux"
+	}
+
+	if "" == result.Device {
+		switch {
+		case strings.Contains(aAgent, "iPad"),
+			strings.Contains(aAgent, "Tablet"),
+			("Android" == result.OS) && !strings.Contains(aAgent, "Mobile"):
+			result.Device = "tablet"
+		case strings.Contains(aAgent, "Mobi"),
+			strings.Contains(aAgent, "iPhone"),
+			strings.Contains(aAgent, "iPod"):
+			result.Device = "mobile"
+		default:
+			result.Device = "desktop"
+		}
+	}
+
+	return result
+} // parseUserAgent()
+
+/* _EoF_ */
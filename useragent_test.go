@@ -0,0 +1,47 @@
+/*
+Copyright © 2024  M.Watermann, 10247 Berlin, Germany
+
+	    All rights reserved
+	EMail : <support@mwat.de>
+*/
+package apachelogger
+
+import (
+	"testing"
+)
+
+//lint:file-ignore ST1017 – I prefer Yoda conditions
+
+func TestParseUserAgent(t *testing.T) {
+	tests := []struct {
+		name  string
+		agent string
+		want  TUserAgent
+	}{
+		{" 1", "Mozilla/5.0 (X11; Linux x86_64; rv:56.0) Gecko/20100101 Firefox/56.0",
+			TUserAgent{"Firefox", "56", "Linux", "desktop"}},
+		{" 2", "Mozilla/5.0 (iPhone; CPU iPhone OS 12_2 like Mac OS X) AppleWebKit/605.1.15 (KHTML, like Gecko) Version/12.1 Mobile/15E148 Safari/604.1",
+			TUserAgent{"Safari", "12", "iOS", "mobile"}},
+		{" 3", "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/120.0.0.0 Safari/537.36 Edg/120.0.2210.91",
+			TUserAgent{"Edge", "120", "Windows", "desktop"}},
+		{" 4", "Mozilla/5.0 (Linux; Android 13; SM-X700) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/119.0.0.0 Safari/537.36",
+			TUserAgent{"Chrome", "119", "Android", "tablet"}},
+		{" 5", "Mozilla/5.0 (compatible; Googlebot/2.1; +http://www.google.com/bot.html)",
+			TUserAgent{"Googlebot", "2", "Other", "bot"}},
+		{" 6", "curl/8.4.0",
+			TUserAgent{"curl", "8", "Other", "bot"}},
+		{" 7", "-",
+			TUserAgent{"Other", "-", "Other", "desktop"}},
+		// TODO: Add test cases.
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := ParseUserAgent(tt.agent); got != tt.want {
+				t.Errorf("%q: ParseUserAgent() = %v, want %v",
+					tt.name, got, tt.want)
+			}
+		})
+	}
+} // TestParseUserAgent()
+
+/* _EoF_ */
@@ -0,0 +1,280 @@
+/*
+Copyright © 2024  M.Watermann, 10247 Berlin, Germany
+
+	    All rights reserved
+	EMail : <support@mwat.de>
+*/
+package apachelogger
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+//lint:file-ignore ST1017 – I prefer Yoda conditions
+
+type (
+	// `tValidator` is implemented by sinks that can check whether
+	// they're able to take log entries.
+	tValidator interface {
+		// `validate()` checks the sink's destination.
+		validate() error
+	}
+
+	// `TValidationError` holds the problems found by `Validate()`.
+	TValidationError struct {
+		Errors []error // the problems of the single sinks
+	}
+)
+
+var (
+	// `ValidateMinFree` is the min. free space (in bytes) `Validate()`
+	// demands on the filesystems of the logfiles (default: `0`, i.e. not
+	// checked). The free space is checked on Linux only.
+	ValidateMinFree uint64
+)
+
+// `Error()` returns the problems found, separated by semicolons.
+//
+// Part of the `error` interface.
+//
+// Returns:
+// - `string`: The problems' description.
+func (ve *TValidationError) Error() string {
+	msgs := make([]string, len(ve.Errors))
+	for idx, err := range ve.Errors {
+		msgs[idx] = err.Error()
+	}
+
+	return strings.Join(msgs, "; ")
+} // Error()
+
+// `Unwrap()` returns the problems found, so `errors.Is()` and
+// `errors.As()` can inspect them.
+//
+// Returns:
+// - `[]error`: The problems of the single sinks.
+func (ve *TValidationError) Unwrap() []error {
+	return ve.Errors
+} // Unwrap()
+
+/* * * * * * * * * * * * * * * * * * * * * * * * * * * * * * * * * * * * * */
+
+// `validateFile()` checks whether `aFilename` can be opened (or
+// created) for writing and its filesystem has `ValidateMinFree` bytes
+// available.
+//
+// Parameters:
+// - `aFilename`: The name of the logfile to check.
+//
+// Returns:
+// - `error`: A possible problem with the logfile.
+func validateFile(aFilename string) error {
+	if "" == aFilename {
+		return nil
+	}
+	file, err := os.OpenFile(aFilename, alOpenFlags, 0640) // #nosec G302
+	if nil != err {
+		return fmt.Errorf("apachelogger: can't open logfile: %w", err)
+	}
+	_ = file.Close()
+
+	if 0 == ValidateMinFree {
+		return nil
+	}
+	if free, ok := freeSpace(filepath.Dir(aFilename)); ok && (free < ValidateMinFree) {
+		return fmt.Errorf("apachelogger: %s: only %d bytes free (min. %d)",
+			aFilename, free, ValidateMinFree)
+	}
+
+	return nil
+} // validateFile()
+
+// `validate()` checks whether the logfile can be written.
+//
+// Part of the `tValidator` interface.
+//
+// Returns:
+// - `error`: A possible problem with the logfile.
+func (fs *tFileSink) validate() error {
+	return validateFile(fs.name)
+} // validate()
+
+// `validate()` checks whether the logfile can be written.
+//
+// Part of the `tValidator` interface.
+//
+// Returns:
+// - `error`: A possible problem with the logfile.
+func (gs *tGzipFileSink) validate() error {
+	return validateFile(gs.name)
+} // validate()
+
+// `validate()` checks whether the topic can be reached with the
+// configured credentials.
+//
+// Part of the `tValidator` interface.
+//
+// Returns:
+// - `error`: A possible problem reaching the topic.
+func (ps *tPubSubSink) validate() error {
+	// a copy, so the token cache of the running writer isn't touched:
+	sink := &tPubSubSink{opts: ps.opts}
+	token, err := sink.accessToken()
+	if nil != err {
+		return fmt.Errorf("apachelogger: Pub/Sub token: %w", err)
+	}
+
+	target := fmt.Sprintf("%s/v1/projects/%s/topics/%s",
+		ps.opts.Endpoint, url.PathEscape(ps.opts.Project),
+		url.PathEscape(ps.opts.Topic))
+	req, err := http.NewRequest(http.MethodGet, target, nil)
+	if nil != err {
+		return err
+	}
+	req.Header.Set("Authorization", "Bearer "+token)
+
+	resp, err := ps.opts.Client.Do(req)
+	if nil != err {
+		return fmt.Errorf("apachelogger: Pub/Sub topic: %w", err)
+	}
+	defer resp.Body.Close()
+	_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, 4096))
+	if http.StatusOK != resp.StatusCode {
+		return fmt.Errorf("apachelogger: Pub/Sub topic %s: %s",
+			ps.opts.Topic, resp.Status)
+	}
+
+	return nil
+} // validate()
+
+// `validate()` checks whether the broker can be reached with the
+// configured credentials.
+//
+// Part of the `tValidator` interface.
+//
+// Returns:
+// - `error`: A possible problem reaching the broker.
+func (as *tAMQPSink) validate() error {
+	// a separate connection, so the running writer isn't touched:
+	sink := &tAMQPSink{opts: as.opts}
+	if err := sink.connect(); nil != err {
+		return fmt.Errorf("apachelogger: AMQP broker: %w", err)
+	}
+
+	return sink.Close()
+} // validate()
+
+// `validate()` checks whether files can be created in the sink's
+// directory.
+//
+// Part of the `tValidator` interface.
+//
+// Returns:
+// - `error`: A possible problem with the directory.
+func (ps *tParquetSink) validate() error {
+	file, err := os.CreateTemp(ps.opts.Directory, ".apachelogger-*")
+	if nil != err {
+		return fmt.Errorf("apachelogger: Parquet directory: %w", err)
+	}
+	_ = file.Close()
+	_ = os.Remove(file.Name())
+
+	if 0 == ValidateMinFree {
+		return nil
+	}
+	if free, ok := freeSpace(ps.opts.Directory); ok && (free < ValidateMinFree) {
+		return fmt.Errorf("apachelogger: %s: only %d bytes free (min. %d)",
+			ps.opts.Directory, free, ValidateMinFree)
+	}
+
+	return nil
+} // validate()
+
+// `validateSinks()` checks the logfiles `aFiles` and the sinks `aSinks`.
+//
+// Parameters:
+// - `aFiles`: The names of the logfiles to check.
+// - `aSinks`: The sinks to check.
+//
+// Returns:
+// - `error`: A `*TValidationError` holding all problems found, or
+// `nil` if all sinks are usable.
+func validateSinks(aFiles []string, aSinks []TSink) error {
+	var (
+		result  TValidationError
+		checked = make(map[string]bool)
+	)
+	for _, name := range aFiles {
+		checked[name] = true
+		if err := validateFile(name); nil != err {
+			result.Errors = append(result.Errors, err)
+		}
+	}
+	for _, sink := range aSinks {
+		if fs, ok := sink.(*tFileSink); ok {
+			if checked[fs.name] {
+				continue
+			}
+			checked[fs.name] = true
+		}
+		if validator, ok := sink.(tValidator); ok {
+			if err := validator.validate(); nil != err {
+				result.Errors = append(result.Errors, err)
+			}
+		}
+	}
+	if 0 == len(result.Errors) {
+		return nil
+	}
+
+	return &result
+} // validateSinks()
+
+/* * * * * * * * * * * * * * * * * * * * * * * * * * * * * * * * * * * * * */
+
+// `Validate()` checks all sinks configured so far (by `Wrap()`,
+// `WrapSinks()`, `SetAccessFile()` etc.) and the given `aSinks`.
+//
+// Logfiles are opened (i.e. created if necessary) to check the write
+// permission, and their filesystem must have at least `ValidateMinFree`
+// bytes available; remote sinks (like `NewPubSubSink()`) check that
+// their destination can be reached. Sinks without such checks (e.g.
+// custom `TSink` implementations) are accepted as they are.
+//
+// Call it during startup, before the server accepts connections, to
+// fail early instead of losing the first log entries, e.g.
+//
+//	if err := apachelogger.Validate(); nil != err {
+//		log.Fatal(err)
+//	}
+//
+// Parameters:
+// - `aSinks`: Further sinks to check (e.g. those not used yet).
+//
+// Returns:
+// - `error`: A `*TValidationError` holding all problems found, or
+// `nil` if all sinks are usable.
+func Validate(aSinks ...TSink) error {
+	var (
+		files []string
+		sinks []TSink
+	)
+	alFileQueuesMtx.Lock()
+	for name := range alFileQueues {
+		files = append(files, name)
+	}
+	for _, sq := range alSinkQueues {
+		sinks = append(sinks, sq.sink)
+	}
+	alFileQueuesMtx.Unlock()
+
+	return validateSinks(files, append(sinks, aSinks...))
+} // Validate()
+
+/* _EoF_ */
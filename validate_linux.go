@@ -0,0 +1,44 @@
+//go:build linux
+// +build linux
+
+/*
+Copyright © 2024  M.Watermann, 10247 Berlin, Germany
+
+	    All rights reserved
+	EMail : <support@mwat.de>
+*/
+package apachelogger
+
+import "syscall"
+
+//lint:file-ignore ST1017 – I prefer Yoda conditions
+
+// `freeSpace()` returns the space available to unprivileged users on
+// the filesystem of `aDirectory`.
+//
+// Parameters:
+// - `aDirectory`: A directory of the filesystem to check.
+//
+// Returns:
+// - `uint64`: The number of bytes available.
+// - `bool`: `false` if the free space couldn't be determined.
+func freeSpace(aDirectory string) (uint64, bool) {
+	var stat syscall.Statfs_t
+	if err := syscall.Statfs(aDirectory, &stat); nil != err {
+		return 0, false
+	}
+
+	return stat.Bavail * uint64(stat.Bsize), true // #nosec G115
+} // freeSpace()
+
+// `validate()` checks whether the logfile can be written.
+//
+// Part of the `tValidator` interface.
+//
+// Returns:
+// - `error`: A possible problem with the logfile.
+func (us *tURingFileSink) validate() error {
+	return validateFile(us.name)
+} // validate()
+
+/* _EoF_ */
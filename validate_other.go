@@ -0,0 +1,27 @@
+//go:build !linux
+// +build !linux
+
+/*
+Copyright © 2024  M.Watermann, 10247 Berlin, Germany
+
+	    All rights reserved
+	EMail : <support@mwat.de>
+*/
+package apachelogger
+
+//lint:file-ignore ST1017 – I prefer Yoda conditions
+
+// `freeSpace()` returns the space available on the filesystem of
+// `aDirectory`; it's not supported on this platform.
+//
+// Parameters:
+// - `aDirectory`: A directory of the filesystem to check.
+//
+// Returns:
+// - `uint64`: Always `0`.
+// - `bool`: Always `false`.
+func freeSpace(aDirectory string) (uint64, bool) {
+	return 0, false
+} // freeSpace()
+
+/* _EoF_ */
@@ -0,0 +1,89 @@
+/*
+Copyright © 2024  M.Watermann, 10247 Berlin, Germany
+
+	    All rights reserved
+	EMail : <support@mwat.de>
+*/
+package apachelogger
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+//lint:file-ignore ST1017 – I prefer Yoda conditions
+
+func Test_validateFile(t *testing.T) {
+	dir := t.TempDir()
+	defer func() { ValidateMinFree = 0 }()
+
+	name := filepath.Join(dir, "access.log")
+	if err := validateFile(name); nil != err {
+		t.Errorf("validateFile() error = %v, want nil", err)
+	}
+	if _, err := os.Stat(name); nil != err {
+		t.Errorf("validateFile() didn't create the logfile: %v", err)
+	}
+	if err := validateFile(filepath.Join(dir, "missing", "access.log")); nil == err {
+		t.Error("validateFile() expected an error for a missing directory")
+	}
+
+	if _, ok := freeSpace(dir); !ok {
+		t.Skip("free space not supported")
+	}
+	ValidateMinFree = 1 << 62
+	if err := validateFile(name); (nil == err) || !strings.Contains(err.Error(), "bytes free") {
+		t.Errorf("validateFile() error = %v, want too little free space", err)
+	}
+} // Test_validateFile()
+
+func Test_validateSinks(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(aWriter http.ResponseWriter, aRequest *http.Request) {
+		if "Bearer secret" != aRequest.Header.Get("Authorization") {
+			aWriter.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		if !strings.HasSuffix(aRequest.URL.Path, "/topics/logs") {
+			aWriter.WriteHeader(http.StatusNotFound)
+			return
+		}
+		_, _ = aWriter.Write([]byte(`{"name":"projects/p/topics/logs"}`))
+	}))
+	defer server.Close()
+	token := func() (string, error) { return "secret", nil }
+
+	good := NewPubSubSink(TPubSubOptions{Project: "p", Topic: "logs",
+		Endpoint: server.URL, Token: token})
+	if err := validateSinks(nil, []TSink{good, Discard}); nil != err {
+		t.Errorf("validateSinks() error = %v, want nil", err)
+	}
+
+	dir := t.TempDir()
+	missing := filepath.Join(dir, "missing", "error.log")
+	bad := NewPubSubSink(TPubSubOptions{Project: "p", Topic: "other",
+		Endpoint: server.URL, Token: token})
+	err := validateSinks([]string{filepath.Join(dir, "access.log")},
+		[]TSink{NewFileSink(filepath.Join(dir, "access.log")),
+			NewGzipFileSink(missing, 0), bad})
+	var ve *TValidationError
+	if !errors.As(err, &ve) {
+		t.Fatalf("validateSinks() error = %v, want a *TValidationError", err)
+	}
+	if 2 != len(ve.Errors) {
+		t.Errorf("validateSinks() found %d problems, want 2: %v", len(ve.Errors), err)
+	}
+	if msg := err.Error(); !strings.Contains(msg, "missing") ||
+		!strings.Contains(msg, "404") {
+		t.Errorf("validateSinks() error = %q, want the logfile and the topic", msg)
+	}
+	if !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("errors.Is(%v, os.ErrNotExist) = false, want true", err)
+	}
+} // Test_validateSinks()
+
+/* _EoF_ */
@@ -0,0 +1,275 @@
+/*
+Copyright © 2024  M.Watermann, 10247 Berlin, Germany
+
+	    All rights reserved
+	EMail : <support@mwat.de>
+*/
+package apachelogger
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+//lint:file-ignore ST1017 – I prefer Yoda conditions
+
+type (
+	// `tW3CFormat` holds the settings of the W3C extended output.
+	tW3CFormat struct {
+		fields  []string // the field identifiers to write
+		headers []string // names of the request headers needed
+	}
+)
+
+var (
+	// The fields written if no fields are given to `SetW3CFormat()`.
+	alW3CFields = []string{
+		"date", "time", "c-ip", "cs-username", "cs-method",
+		"cs-uri-stem", "cs-uri-query", "cs-version", "sc-status",
+		"sc-bytes", "time-taken", "cs(User-Agent)", "cs(Referer)",
+	}
+
+	// The current W3C extended output settings (`nil` if off).
+	alW3CFormat *tW3CFormat
+
+	// Guard for concurrent access to `alW3CFormat`.
+	alW3CFormatMtx sync.RWMutex
+)
+
+// `appendW3CValue()` appends `aValue` with spaces replaced by `+` so
+// the field separators stay unambiguous (like IIS does).
+//
+// Parameters:
+// - `aBuffer`: The buffer to append to.
+// - `aValue`: The value to append.
+//
+// Returns:
+// - `[]byte`: The extended buffer.
+func appendW3CValue(aBuffer []byte, aValue string) []byte {
+	if ("" == aValue) || ("-" == aValue) {
+		return append(aBuffer, '-')
+	}
+	for idx := 0; idx < len(aValue); idx++ {
+		switch ch := aValue[idx]; {
+		case ' ' == ch:
+			aBuffer = append(aBuffer, '+')
+		case (' ' > ch) || (0x7f == ch):
+			aBuffer = append(aBuffer, fmt.Sprintf(`\x%02x`, ch)...)
+		default:
+			aBuffer = append(aBuffer, ch)
+		}
+	}
+
+	return aBuffer
+} // appendW3CValue()
+
+// `headerName()` returns the name of the request header referenced by
+// the W3C field identifier `aField` (e.g. `cs(User-Agent)`).
+//
+// Parameters:
+// - `aField`: The field identifier.
+//
+// Returns:
+// - `string`: The header's name (empty if `aField` isn't a header field).
+func headerName(aField string) string {
+	if strings.HasPrefix(aField, "cs(") && strings.HasSuffix(aField, ")") {
+		return http.CanonicalHeaderKey(aField[3 : len(aField)-1])
+	}
+
+	return ""
+} // headerName()
+
+// `checkW3CField()` checks whether `aField` is a supported W3C field
+// identifier.
+//
+// Parameters:
+// - `aField`: The field identifier to check.
+//
+// Returns:
+// - `error`: A possible error for an unsupported identifier.
+func checkW3CField(aField string) error {
+	switch aField {
+	case "date", "time", "c-ip", "cs-username", "cs-method",
+		"cs-uri-stem", "cs-uri-query", "cs-uri", "cs-version",
+		"sc-status", "sc-bytes", "time-taken":
+		return nil
+	}
+	if strings.HasPrefix(aField, "x-") && (2 < len(aField)) {
+		return nil
+	}
+	if name := headerName(aField); "" != name {
+		return nil
+	}
+
+	return fmt.Errorf("apachelogger: unsupported W3C field %q", aField)
+} // checkW3CField()
+
+/* * * * * * * * * * * * * * * * * * * * * * * * * * * * * * * * * * * * * */
+
+// `appendTo()` appends `aEntry` as a W3C extended log line to `aBuffer`.
+//
+// Parameters:
+// - `aBuffer`: The buffer to append to.
+// - `aEntry`: The log entry to format.
+//
+// Returns:
+// - `[]byte`: The extended buffer.
+func (wf *tW3CFormat) appendTo(aBuffer []byte, aEntry *TEntry) []byte {
+	when := aEntry.timestamp().UTC()
+	for idx, field := range wf.fields {
+		if 0 < idx {
+			aBuffer = append(aBuffer, ' ')
+		}
+		switch field {
+		case "date":
+			aBuffer = when.AppendFormat(aBuffer, "2006-01-02")
+		case "time":
+			aBuffer = when.AppendFormat(aBuffer, "15:04:05")
+		case "c-ip":
+			aBuffer = appendW3CValue(aBuffer, aEntry.Remote)
+		case "cs-username":
+			aBuffer = appendW3CValue(aBuffer, aEntry.User)
+		case "cs-method":
+			aBuffer = appendW3CValue(aBuffer, aEntry.Method)
+		case "cs-uri":
+			aBuffer = appendW3CValue(aBuffer, aEntry.Path)
+		case "cs-uri-stem":
+			path := aEntry.Path
+			if idx := strings.IndexByte(path, '?'); 0 <= idx {
+				path = path[:idx]
+			}
+			aBuffer = appendW3CValue(aBuffer, path)
+		case "cs-uri-query":
+			query := ""
+			if idx := strings.IndexByte(aEntry.Path, '?'); 0 <= idx {
+				query = aEntry.Path[idx+1:]
+			}
+			aBuffer = appendW3CValue(aBuffer, query)
+		case "cs-version":
+			aBuffer = appendW3CValue(aBuffer, aEntry.Proto)
+		case "sc-status":
+			aBuffer = strconv.AppendInt(aBuffer, int64(aEntry.Status), 10)
+		case "sc-bytes":
+			aBuffer = strconv.AppendInt(aBuffer, int64(aEntry.Size), 10)
+		case "time-taken":
+			aBuffer = strconv.AppendFloat(aBuffer, aEntry.Duration.Seconds(), 'f', 3, 64)
+		default:
+			if name := headerName(field); "" != name {
+				aBuffer = appendW3CValue(aBuffer, aEntry.header(name))
+			} else {
+				aBuffer = appendW3CValue(aBuffer, aEntry.Fields[field[2:]])
+			}
+		}
+	}
+
+	return append(aBuffer, '\n')
+} // appendTo()
+
+// `header()` returns the directives to write at the start of a new
+// logfile.
+//
+// Parameters:
+// - `aNow`: The time the logfile is started.
+//
+// Returns:
+// - `string`: The `#Version`, `#Software`, `#Date`, and `#Fields` lines.
+func (wf *tW3CFormat) header(aNow time.Time) string {
+	return "#Version: 1.0\n" +
+		"#Software: mwat56/apachelogger\n" +
+		"#Date: " + aNow.UTC().Format("2006-01-02 15:04:05") + "\n" +
+		"#Fields: " + strings.Join(wf.fields, " ") + "\n"
+} // header()
+
+/* * * * * * * * * * * * * * * * * * * * * * * * * * * * * * * * * * * * * */
+
+// `w3cFormat()` returns the current W3C extended output settings.
+//
+// Returns:
+// - `*tW3CFormat`: The current settings (`nil` if that mode is off).
+func w3cFormat() *tW3CFormat {
+	alW3CFormatMtx.RLock()
+	defer alW3CFormatMtx.RUnlock()
+
+	return alW3CFormat
+} // w3cFormat()
+
+// `w3cLine()` returns `aEntry` as a W3C extended log line if that
+// output mode is active.
+//
+// Parameters:
+// - `aEntry`: The log entry to format.
+//
+// Returns:
+// - `string`: The formatted line.
+// - `bool`: `true` if the W3C extended output mode is active.
+func w3cLine(aEntry *TEntry) (string, bool) {
+	wf := w3cFormat()
+	if nil == wf {
+		return "", false
+	}
+	buf := getLineBuffer()
+	*buf = wf.appendTo(*buf, aEntry)
+	result := string(*buf)
+	putLineBuffer(buf)
+
+	return result, true
+} // w3cLine()
+
+// `ClearW3CFormat()` switches the W3C extended output mode off.
+func ClearW3CFormat() {
+	alW3CFormatMtx.Lock()
+	alW3CFormat = nil
+	alW3CFormatMtx.Unlock()
+} // ClearW3CFormat()
+
+// `SetW3CFormat()` switches to writing the log entries in the W3C
+// Extended Log File Format (as used e.g. by IIS).
+//
+// Every new (empty) logfile – at startup as well as after an external
+// rotation – starts with the `#Version`, `#Software`, `#Date`, and
+// `#Fields` directives, so the files remain self-describing even if
+// the selected fields change between deployments.
+//
+// Supported field identifiers are `date`, `time` (both UTC), `c-ip`,
+// `cs-username`, `cs-method`, `cs-uri`, `cs-uri-stem`, `cs-uri-query`,
+// `cs-version`, `sc-status`, `sc-bytes`, `time-taken` (in seconds),
+// `cs(Header)` for any request header, and `x-name` for the additional
+// field `name` (see `SetField()`); without `aFields` a set resembling
+// the combined log format is written.
+//
+// Example:
+//
+//	err := apachelogger.SetW3CFormat("date", "time", "c-ip",
+//		"cs-method", "cs-uri-stem", "sc-status", "time-taken")
+//
+// Parameters:
+// - `aFields`: The identifiers of the fields to write.
+//
+// Returns:
+// - `error`: A possible error for an unsupported field identifier.
+func SetW3CFormat(aFields ...string) error {
+	if 0 == len(aFields) {
+		aFields = alW3CFields
+	}
+	wf := &tW3CFormat{fields: append([]string{}, aFields...)}
+	for _, field := range wf.fields {
+		if err := checkW3CField(field); nil != err {
+			return err
+		}
+		if name := headerName(field); "" != name {
+			wf.headers = append(wf.headers, name)
+		}
+	}
+
+	alW3CFormatMtx.Lock()
+	alW3CFormat = wf
+	alW3CFormatMtx.Unlock()
+
+	return nil
+} // SetW3CFormat()
+
+/* _EoF_ */
@@ -0,0 +1,93 @@
+/*
+Copyright © 2024  M.Watermann, 10247 Berlin, Germany
+
+	    All rights reserved
+	EMail : <support@mwat.de>
+*/
+package apachelogger
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+//lint:file-ignore ST1017 – I prefer Yoda conditions
+
+func TestSetW3CFormat(t *testing.T) {
+	defer ClearW3CFormat()
+
+	for _, field := range []string{"", "x-", "cs()", "c-port", "date time"} {
+		if err := SetW3CFormat(field); nil == err {
+			t.Errorf("SetW3CFormat(%q) expected an error", field)
+		}
+	}
+
+	e1 := prepEntry()
+	e1.Agent = "Mozilla/5.0 (X11; Linux)"
+	e1.Duration = 1500 * time.Millisecond
+	e1.SetField("trace_id", "abc")
+	if err := SetW3CFormat(); nil != err {
+		t.Fatalf("SetW3CFormat() error = %v", err)
+	}
+	w1 := "2024-04-25 18:16:45 192.168.1.0 - GET /path/to/file lang=en HTTP/1.1 200 27155 1.500 Mozilla/5.0+(X11;+Linux) -\n"
+	if got := formatEntry(e1); got != w1 {
+		t.Errorf("formatEntry() = %q,\nwant %q", got, w1)
+	}
+
+	if err := SetW3CFormat("cs-uri", "x-trace_id", "x-missing", "cs(accept)"); nil != err {
+		t.Fatalf("SetW3CFormat() error = %v", err)
+	}
+	e1.headers = map[string]string{"Accept": "text/html"}
+	w2 := "/path/to/file?lang=en abc - text/html\n"
+	if got := formatEntry(e1); got != w2 {
+		t.Errorf("formatEntry() = %q, want %q", got, w2)
+	}
+	header := fileHeader()
+	if !strings.HasPrefix(header, "#Version: 1.0\n") ||
+		!strings.HasSuffix(header, "\n#Fields: cs-uri x-trace_id x-missing cs(accept)\n") ||
+		!strings.Contains(header, "\n#Date: ") {
+		t.Errorf("fileHeader() = %q", header)
+	}
+
+	ClearW3CFormat()
+	if got := formatEntry(e1); got != e1.String() {
+		t.Errorf("formatEntry() = %q, want %q", got, e1.String())
+	}
+	if got := fileHeader(); "" != got {
+		t.Errorf("fileHeader() = %q, want %q", got, "")
+	}
+} // TestSetW3CFormat()
+
+func TestSetW3CFormat_rotation(t *testing.T) {
+	defer ClearW3CFormat()
+	if err := SetW3CFormat("c-ip", "sc-status"); nil != err {
+		t.Fatalf("SetW3CFormat() error = %v", err)
+	}
+	name := filepath.Join(t.TempDir(), "access.log")
+
+	for i := 0; 2 > i; i++ {
+		if 1 == i { // simulate an external rotation
+			_ = os.Rename(name, name+".1")
+		}
+		sink := NewFileSink(name)
+		if err := sink.Write([]byte("1.2.3.0 200\n")); nil != err {
+			t.Fatalf("Write() error = %v", err)
+		}
+		_ = sink.Close()
+	}
+	for _, fName := range []string{name + ".1", name} {
+		data, err := os.ReadFile(fName)
+		if nil != err {
+			t.Fatal(err)
+		}
+		if !strings.HasPrefix(string(data), "#Version: 1.0\n") ||
+			!strings.HasSuffix(string(data), "#Fields: c-ip sc-status\n1.2.3.0 200\n") {
+			t.Errorf("%s = %q", fName, data)
+		}
+	}
+} // TestSetW3CFormat_rotation()
+
+/* _EoF_ */
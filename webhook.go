@@ -0,0 +1,322 @@
+/*
+Copyright © 2024  M.Watermann, 10247 Berlin, Germany
+
+	    All rights reserved
+	EMail : <support@mwat.de>
+*/
+package apachelogger
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+)
+
+//lint:file-ignore ST1017 – I prefer Yoda conditions
+
+const (
+	// `WebhookJSON` posts the events as a generic JSON document:
+	//
+	//	{"events":[{"time":"…","level":"ERR","sender":"…","message":"…"}],"dropped":0}
+	WebhookJSON = "json"
+
+	// `WebhookSlack` posts the events as a Slack message (`{"text":"…"}`).
+	WebhookSlack = "slack"
+
+	// `WebhookDiscord` posts the events as a Discord message
+	// (`{"content":"…"}`).
+	WebhookDiscord = "discord"
+)
+
+type (
+	// `TWebhook` configures a webhook notified about log events.
+	TWebhook struct {
+		// The URL to post the notifications to.
+		URL string
+
+		// The payload style: `WebhookJSON` (default), `WebhookSlack`,
+		// or `WebhookDiscord`.
+		Style string
+
+		// The levels of the events to notify about: `ERR` for `Err()`
+		// and the server's error messages, `LOG` for `Log()` (default:
+		// `ERR` only).
+		Levels []string
+
+		// Regular expression the event's `sender: message` text must
+		// match (default: all events).
+		Pattern string
+
+		// Time to wait for further events after the first one before
+		// posting a notification (default: 2 seconds).
+		BatchDelay time.Duration
+
+		// Min. time between two notifications (default: 30 seconds);
+		// events occurring in between are collected into the next one.
+		MinInterval time.Duration
+
+		// Max. number of events per notification (default: 50); further
+		// events are only counted.
+		MaxBatch int
+
+		// The HTTP client to use (default: a client with a 10 second
+		// timeout).
+		Client *http.Client
+	}
+
+	// `tWebhookEvent` is a single event to notify about.
+	tWebhookEvent struct {
+		Time    string `json:"time"`
+		Level   string `json:"level"`
+		Sender  string `json:"sender"`
+		Message string `json:"message"`
+	}
+
+	// `tWebhook` is a registered webhook with its pending events.
+	tWebhook struct {
+		TWebhook
+		dropped  int             // number of events exceeding `MaxBatch`
+		lastPost time.Time       // time of the last notification
+		mtx      sync.Mutex      // guard for the fields below
+		pattern  *regexp.Regexp  // the compiled `Pattern`
+		pending  []tWebhookEvent // events not yet posted
+		timer    *time.Timer     // timer of the next notification
+	}
+)
+
+var (
+	// List of registered webhooks.
+	alWebhooks []*tWebhook
+
+	// Guard for concurrent access to `alWebhooks`.
+	alWebhooksMtx sync.RWMutex
+)
+
+// `add()` adds `aEvent` to the pending events, scheduling the next
+// notification if necessary.
+//
+// Parameters:
+// - `aEvent`: The event to notify about.
+func (wh *tWebhook) add(aEvent tWebhookEvent) {
+	wh.mtx.Lock()
+	defer wh.mtx.Unlock()
+
+	if wh.MaxBatch <= len(wh.pending) {
+		wh.dropped++
+		return
+	}
+	wh.pending = append(wh.pending, aEvent)
+	if nil != wh.timer {
+		return // a notification is already scheduled
+	}
+	delay := wh.BatchDelay
+	if wait := time.Until(wh.lastPost.Add(wh.MinInterval)); delay < wait {
+		delay = wait
+	}
+	wh.timer = time.AfterFunc(delay, wh.post)
+} // add()
+
+// `matches()` reports whether the webhook is interested in the event.
+//
+// Parameters:
+// - `aEvent`: The event to check.
+//
+// Returns:
+// - `bool`: `true` if the webhook should be notified about `aEvent`.
+func (wh *tWebhook) matches(aEvent tWebhookEvent) bool {
+	levelOK := false
+	for _, level := range wh.Levels {
+		if strings.EqualFold(level, aEvent.Level) {
+			levelOK = true
+			break
+		}
+	}
+	if !levelOK {
+		return false
+	}
+
+	return (nil == wh.pattern) ||
+		wh.pattern.MatchString(aEvent.Sender+": "+aEvent.Message)
+} // matches()
+
+// `payload()` returns the notification's body for the given events.
+//
+// Parameters:
+// - `aEvents`: The events to notify about.
+// - `aDropped`: The number of events not included.
+//
+// Returns:
+// - `[]byte`: The JSON document to post.
+func (wh *tWebhook) payload(aEvents []tWebhookEvent, aDropped int) []byte {
+	var result interface{}
+
+	switch wh.Style {
+	case WebhookSlack, WebhookDiscord:
+		var sb strings.Builder
+		for _, event := range aEvents {
+			fmt.Fprintf(&sb, "`%s` *%s* %s: %s\n",
+				event.Time, event.Level, event.Sender, event.Message)
+		}
+		if 0 < aDropped {
+			fmt.Fprintf(&sb, "… and %d more events\n", aDropped)
+		}
+		text := strings.TrimSuffix(sb.String(), "\n")
+		if WebhookSlack == wh.Style {
+			result = map[string]string{"text": text}
+		} else {
+			if 2000 < len([]rune(text)) { // Discord's message limit
+				text = string([]rune(text)[:1999]) + "…"
+			}
+			result = map[string]string{"content": text}
+		}
+
+	default:
+		result = struct {
+			Events  []tWebhookEvent `json:"events"`
+			Dropped int             `json:"dropped"`
+		}{aEvents, aDropped}
+	}
+	data, _ := json.Marshal(result) // can't fail for these types
+
+	return data
+} // payload()
+
+// `post()` sends the pending events to the webhook's URL.
+//
+// Failures are silently ignored since reporting them to the error log
+// might cause further notifications.
+func (wh *tWebhook) post() {
+	wh.mtx.Lock()
+	events, dropped := wh.pending, wh.dropped
+	wh.pending, wh.dropped, wh.timer = nil, 0, nil
+	wh.lastPost = time.Now()
+	wh.mtx.Unlock()
+	if 0 == len(events) {
+		return
+	}
+
+	resp, err := wh.Client.Post(wh.URL, "application/json",
+		bytes.NewReader(wh.payload(events, dropped)))
+	if nil != err {
+		return
+	}
+	_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, 4096))
+	_ = resp.Body.Close()
+} // post()
+
+/* * * * * * * * * * * * * * * * * * * * * * * * * * * * * * * * * * * * * */
+
+// `notifyWebhooks()` passes the custom log entry `aEntry` (written by
+// `Err()` or `Log()`) to all interested webhooks.
+//
+// Parameters:
+// - `aEntry`: The (prepared) log entry.
+func notifyWebhooks(aEntry *TEntry) {
+	alWebhooksMtx.RLock()
+	defer alWebhooksMtx.RUnlock()
+	if 0 == len(alWebhooks) {
+		return
+	}
+
+	event := tWebhookEvent{
+		Time:    aEntry.When.Format(time.RFC3339),
+		Level:   aEntry.Method,
+		Sender:  aEntry.Referrer,
+		Message: aEntry.Path,
+	}
+	for _, hook := range alWebhooks {
+		if hook.matches(event) {
+			hook.add(event)
+		}
+	}
+} // notifyWebhooks()
+
+// `AddWebhook()` registers a webhook to be notified about error log
+// events, e.g. to alert a chat channel without fragile tail-and-alert
+// scripts.
+//
+// Events matching the webhook's levels and pattern are collected for
+// `BatchDelay` and then posted in a single notification; at most one
+// notification is posted per `MinInterval`. The events' messages are
+// passed through the registered redactions (see `AddRedaction()`)
+// before.
+//
+// Example (alerting Slack about panics):
+//
+//	err := apachelogger.AddWebhook(apachelogger.TWebhook{
+//		URL:     "https://hooks.slack.com/services/…",
+//		Style:   apachelogger.WebhookSlack,
+//		Pattern: `catchPanic`,
+//	})
+//
+// Parameters:
+// - `aHook`: The webhook's configuration.
+//
+// Returns:
+// - `error`: A possible error for a missing URL or invalid pattern.
+func AddWebhook(aHook TWebhook) error {
+	if "" == aHook.URL {
+		return fmt.Errorf("apachelogger: missing webhook URL")
+	}
+	switch aHook.Style {
+	case "":
+		aHook.Style = WebhookJSON
+	case WebhookJSON, WebhookSlack, WebhookDiscord:
+	default:
+		return fmt.Errorf("apachelogger: unknown webhook style %q", aHook.Style)
+	}
+	hook := &tWebhook{}
+	if "" != aHook.Pattern {
+		re, err := regexp.Compile(aHook.Pattern)
+		if nil != err {
+			return err
+		}
+		hook.pattern = re
+	}
+	if 0 == len(aHook.Levels) {
+		aHook.Levels = []string{"ERR"}
+	}
+	if 0 >= aHook.BatchDelay {
+		aHook.BatchDelay = 2 * time.Second
+	}
+	if 0 >= aHook.MinInterval {
+		aHook.MinInterval = 30 * time.Second
+	}
+	if 0 >= aHook.MaxBatch {
+		aHook.MaxBatch = 50
+	}
+	if nil == aHook.Client {
+		aHook.Client = &http.Client{Timeout: 10 * time.Second}
+	}
+	hook.TWebhook = aHook
+
+	alWebhooksMtx.Lock()
+	alWebhooks = append(alWebhooks, hook)
+	alWebhooksMtx.Unlock()
+
+	return nil
+} // AddWebhook()
+
+// `ClearWebhooks()` removes all registered webhooks; pending events
+// are discarded.
+func ClearWebhooks() {
+	alWebhooksMtx.Lock()
+	for _, hook := range alWebhooks {
+		hook.mtx.Lock()
+		if nil != hook.timer {
+			hook.timer.Stop()
+		}
+		hook.pending, hook.timer = nil, nil
+		hook.mtx.Unlock()
+	}
+	alWebhooks = nil
+	alWebhooksMtx.Unlock()
+} // ClearWebhooks()
+
+/* _EoF_ */
@@ -0,0 +1,100 @@
+/*
+Copyright © 2024  M.Watermann, 10247 Berlin, Germany
+
+	    All rights reserved
+	EMail : <support@mwat.de>
+*/
+package apachelogger
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+//lint:file-ignore ST1017 – I prefer Yoda conditions
+
+func TestAddWebhook(t *testing.T) {
+	defer ClearWebhooks()
+	bodies := make(chan []byte, 4)
+	srv := httptest.NewServer(http.HandlerFunc(func(aWriter http.ResponseWriter, aRequest *http.Request) {
+		data, _ := io.ReadAll(aRequest.Body)
+		bodies <- data
+	}))
+	defer srv.Close()
+
+	if err := AddWebhook(TWebhook{}); nil == err {
+		t.Error("AddWebhook() expected an error for missing URL")
+	}
+	if err := AddWebhook(TWebhook{URL: srv.URL, Style: "teams"}); nil == err {
+		t.Error("AddWebhook() expected an error for unknown style")
+	}
+	if err := AddWebhook(TWebhook{URL: srv.URL, Pattern: "("}); nil == err {
+		t.Error("AddWebhook() expected an error for invalid pattern")
+	}
+
+	if err := AddWebhook(TWebhook{
+		URL:         srv.URL,
+		Pattern:     `db`,
+		BatchDelay:  20 * time.Millisecond,
+		MinInterval: 20 * time.Millisecond,
+		MaxBatch:    2,
+	}); nil != err {
+		t.Fatalf("AddWebhook() error = %v", err)
+	}
+	if err := AddWebhook(TWebhook{
+		URL:        srv.URL,
+		Style:      WebhookSlack,
+		Levels:     []string{"log"},
+		BatchDelay: 20 * time.Millisecond,
+	}); nil != err {
+		t.Fatalf("AddWebhook() error = %v", err)
+	}
+
+	event := func(aLevel, aSender, aMessage string) *TEntry {
+		return &TEntry{When: time.Now(), Method: aLevel,
+			Referrer: aSender, Path: aMessage}
+	}
+	notifyWebhooks(event("ERR", "db", "connection lost"))
+	notifyWebhooks(event("ERR", "http", "timeout"))
+	notifyWebhooks(event("ERR", "db", "connection lost again"))
+	notifyWebhooks(event("ERR", "db", "still lost"))
+	notifyWebhooks(event("LOG", "main", "started"))
+
+	var gotJSON, gotSlack bool
+	for i := 0; 2 > i; i++ {
+		select {
+		case data := <-bodies:
+			if strings.HasPrefix(string(data), `{"text":`) {
+				gotSlack = true
+				if !strings.Contains(string(data), "*LOG* main: started") {
+					t.Errorf("Slack payload = %s", data)
+				}
+				continue
+			}
+			gotJSON = true
+			var payload struct {
+				Events  []tWebhookEvent `json:"events"`
+				Dropped int             `json:"dropped"`
+			}
+			if err := json.Unmarshal(data, &payload); nil != err {
+				t.Fatal(err)
+			}
+			if (2 != len(payload.Events)) || (1 != payload.Dropped) ||
+				("connection lost" != payload.Events[0].Message) {
+				t.Errorf("JSON payload = %s", data)
+			}
+		case <-time.After(2 * time.Second):
+			t.Fatal("webhook not called")
+		}
+	}
+	if !gotJSON || !gotSlack {
+		t.Errorf("got JSON = %v, Slack = %v", gotJSON, gotSlack)
+	}
+} // TestAddWebhook()
+
+/* _EoF_ */